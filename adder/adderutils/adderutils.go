@@ -14,6 +14,7 @@ import (
 	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
 
 	cid "github.com/ipfs/go-cid"
+	files "github.com/ipfs/go-ipfs-files"
 	logging "github.com/ipfs/go-log"
 	rpc "github.com/libp2p/go-libp2p-gorpc"
 )
@@ -23,7 +24,8 @@ var logger = logging.Logger("adder")
 // AddMultipartHTTPHandler is a helper function to add content
 // uploaded using a multipart request. The outputTransform parameter
 // allows to customize the http response output format to something
-// else than api.AddedOutput objects.
+// else than api.AddedOutput objects. Outputs transformed to nil are
+// left out of the response.
 func AddMultipartHTTPHandler(
 	ctx context.Context,
 	rpc *rpc.Client,
@@ -32,6 +34,28 @@ func AddMultipartHTTPHandler(
 	w http.ResponseWriter,
 	outputTransform func(*api.AddedOutput) interface{},
 ) (cid.Cid, error) {
+	return AddMultipartHTTPHandlerThen(ctx, rpc, params, reader, w, outputTransform, nil, nil)
+}
+
+// AddMultipartHTTPHandlerThen works like AddMultipartHTTPHandler, but
+// calls before with the root of the added content right before it is
+// pinned, and then with the same root before finishing the response.
+// Errors returned by either are sent to the client like any other adding
+// error. Both may be nil.
+func AddMultipartHTTPHandlerThen(
+	ctx context.Context,
+	rpc *rpc.Client,
+	params *api.AddParams,
+	reader *multipart.Reader,
+	w http.ResponseWriter,
+	outputTransform func(*api.AddedOutput) interface{},
+	before func(root cid.Cid) error,
+	then func(root cid.Cid) error,
+) (cid.Cid, error) {
+	if then == nil {
+		then = func(cid.Cid) error { return nil }
+	}
+
 	var dags adder.ClusterDAGService
 	output := make(chan *api.AddedOutput, 200)
 
@@ -40,6 +64,9 @@ func AddMultipartHTTPHandler(
 	} else {
 		dags = local.New(rpc, params.PinOptions)
 	}
+	if before != nil {
+		dags = &finalizeHook{ClusterDAGService: dags, before: before}
+	}
 
 	if outputTransform == nil {
 		outputTransform = func(in *api.AddedOutput) interface{} { return in }
@@ -69,6 +96,9 @@ func AddMultipartHTTPHandler(
 		enc := json.NewEncoder(w)
 		add := adder.New(dags, params, output)
 		root, err := add.FromMultipart(ctx, reader)
+		if err == nil {
+			err = then(root)
+		}
 		if err != nil { // Send an error
 			logger.Error(err)
 			w.WriteHeader(http.StatusInternalServerError)
@@ -103,6 +133,9 @@ func AddMultipartHTTPHandler(
 	}()
 	add := adder.New(dags, params, output)
 	root, err := add.FromMultipart(ctx, reader)
+	if err == nil {
+		err = then(root)
+	}
 	if err != nil {
 		logger.Error(err)
 		// Set trailer with error
@@ -112,11 +145,68 @@ func AddMultipartHTTPHandler(
 	return root, err
 }
 
+// AddFiles adds the given files to the cluster, using the sharding
+// adder when params.Shard is set, and returns the root of the added
+// content. The progress output is discarded. Like in
+// AddMultipartHTTPHandlerThen, before is called with the root right
+// before it is pinned, unless it is nil.
+func AddFiles(
+	ctx context.Context,
+	rpc *rpc.Client,
+	params *api.AddParams,
+	f files.Directory,
+	before func(root cid.Cid) error,
+) (cid.Cid, error) {
+	var dags adder.ClusterDAGService
+	output := make(chan *api.AddedOutput, 200)
+
+	if params.Shard {
+		dags = sharding.New(rpc, params.PinOptions, output)
+	} else {
+		dags = local.New(rpc, params.PinOptions)
+	}
+	if before != nil {
+		dags = &finalizeHook{ClusterDAGService: dags, before: before}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range output {
+		}
+	}()
+
+	add := adder.New(dags, params, output)
+	root, err := add.FromFiles(ctx, f)
+	<-done
+	return root, err
+}
+
+// finalizeHook is a ClusterDAGService which calls before with the root of
+// the added content before finalizing it.
+type finalizeHook struct {
+	adder.ClusterDAGService
+	before func(root cid.Cid) error
+}
+
+// Finalize calls the hook and then finalizes the root, which pins it.
+func (fh *finalizeHook) Finalize(ctx context.Context, root cid.Cid) (cid.Cid, error) {
+	err := fh.before(root)
+	if err != nil {
+		return root, err
+	}
+	return fh.ClusterDAGService.Finalize(ctx, root)
+}
+
 func streamOutput(w http.ResponseWriter, output chan *api.AddedOutput, transform func(*api.AddedOutput) interface{}) {
 	flusher, flush := w.(http.Flusher)
 	enc := json.NewEncoder(w)
 	for v := range output {
-		err := enc.Encode(transform(v))
+		tv := transform(v)
+		if tv == nil {
+			continue
+		}
+		err := enc.Encode(tv)
 		if err != nil {
 			logger.Error(err)
 			break
@@ -130,7 +220,9 @@ func streamOutput(w http.ResponseWriter, output chan *api.AddedOutput, transform
 func buildOutput(output chan *api.AddedOutput, transform func(*api.AddedOutput) interface{}) []interface{} {
 	var finalOutput []interface{}
 	for v := range output {
-		finalOutput = append(finalOutput, transform(v))
+		if tv := transform(v); tv != nil {
+			finalOutput = append(finalOutput, tv)
+		}
 	}
 	return finalOutput
 }
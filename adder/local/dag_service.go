@@ -30,6 +30,9 @@ type DAGService struct {
 
 	dests   []peer.ID
 	pinOpts api.PinOptions
+	// cumulative sizes of the added nodes, to set the size of the
+	// root pin.
+	sizes map[string]uint64
 }
 
 // New returns a new Adder with the given rpc Client. The client is used
@@ -39,6 +42,7 @@ func New(rpc *rpc.Client, opts api.PinOptions) *DAGService {
 		rpcClient: rpc,
 		dests:     nil,
 		pinOpts:   opts,
+		sizes:     make(map[string]uint64),
 	}
 }
 
@@ -56,6 +60,7 @@ func (dgs *DAGService) Add(ctx context.Context, node ipld.Node) error {
 	if err != nil {
 		return err
 	}
+	dgs.sizes[node.Cid().String()] = size
 	nodeSerial := &api.NodeWithMeta{
 		Cid:     node.Cid().String(),
 		Data:    node.RawData(),
@@ -70,8 +75,10 @@ func (dgs *DAGService) Finalize(ctx context.Context, root cid.Cid) (cid.Cid, err
 	// Cluster pin the result
 	rootPin := api.PinWithOpts(root, dgs.pinOpts)
 	rootPin.Allocations = dgs.dests
+	rootPin.Size = dgs.sizes[root.String()]
 
 	dgs.dests = nil
+	dgs.sizes = make(map[string]uint64)
 
 	return root, dgs.rpcClient.CallContext(
 		ctx,
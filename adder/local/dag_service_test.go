@@ -75,9 +75,11 @@ func TestAdd(t *testing.T) {
 			}
 		}
 
-		_, ok := rpcObj.pins.Load(test.ShardingDirBalancedRootCIDWrapped)
+		pin, ok := rpcObj.pins.Load(test.ShardingDirBalancedRootCIDWrapped)
 		if !ok {
 			t.Error("the tree wasn't pinned")
+		} else if pin.(api.PinSerial).Size == 0 {
+			t.Error("the size of the tree should be set in the pin")
 		}
 	})
 
@@ -110,7 +110,7 @@ func (dgs *DAGService) Finalize(ctx context.Context, dataRoot cid.Cid) (cid.Cid,
 	clusterDAG := clusterDAGNodes[0].Cid()
 
 	dgs.sendOutput(&api.AddedOutput{
-		Name: fmt.Sprintf("%s-clusterDAG", dgs.pinOpts.Name),
+		Name: clusterDAGOutputName(dgs.pinOpts.Name),
 		Cid:  clusterDAG.String(),
 		Size: dgs.totalSize,
 	})
@@ -245,6 +245,25 @@ Ingest Rate: %s/s
 
 }
 
+func shardOutputName(n int) string {
+	return fmt.Sprintf("shard-%d", n)
+}
+
+func clusterDAGOutputName(name string) string {
+	return fmt.Sprintf("%s-clusterDAG", name)
+}
+
+// IsMetadataOutput returns true when the given output was sent by a
+// sharding DAGService for one of its shards or for the Cluster DAG, rather
+// than for the added content. Clients expecting the IPFS add output can
+// use it to leave them out. Shards and Cluster DAG nodes are the only
+// dag-cbor nodes produced when adding, so they are told apart by their
+// CID, whatever the names of the added files.
+func IsMetadataOutput(out *api.AddedOutput) bool {
+	c, err := cid.Decode(out.Cid)
+	return err == nil && c.Type() == cid.DagCBOR
+}
+
 func (dgs *DAGService) sendOutput(ao *api.AddedOutput) {
 	if dgs.output != nil {
 		dgs.output <- ao
@@ -269,7 +288,7 @@ func (dgs *DAGService) flushCurrentShard(ctx context.Context) (cid.Cid, error) {
 	dgs.previousShard = shardCid
 	dgs.currentShard = nil
 	dgs.sendOutput(&api.AddedOutput{
-		Name: fmt.Sprintf("shard-%d", lens),
+		Name: shardOutputName(lens),
 		Cid:  shardCid.String(),
 		Size: shard.Size(),
 	})
@@ -253,3 +253,19 @@ func TestFromMultipart_Errors(t *testing.T) {
 		f.Close()
 	}
 }
+
+func TestIsMetadataOutput(t *testing.T) {
+	tcs := []struct {
+		out      api.AddedOutput
+		expected bool
+	}{
+		{api.AddedOutput{Name: "shard-0", Cid: test.TestShardCid}, true},
+		{api.AddedOutput{Name: "shard-0", Cid: test.TestCid1}, false},
+		{api.AddedOutput{Name: "file", Cid: test.TestCid1}, false},
+	}
+	for _, tc := range tcs {
+		if IsMetadataOutput(&tc.out) != tc.expected {
+			t.Errorf("%s (%s): expected %t", tc.out.Name, tc.out.Cid, tc.expected)
+		}
+	}
+}
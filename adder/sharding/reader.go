@@ -0,0 +1,178 @@
+package sharding
+
+// reader.go allows reading files added to cluster, fetching their blocks
+// from the IPFS daemons of the peers which hold them. This is needed for
+// sharded content, since no single IPFS daemon holds the full DAG.
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	uio "github.com/ipfs/go-unixfs/io"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+// NewReader returns a reader for the file with the given root CID. When
+// the root is a meta-pin, the blocks are read from the IPFS daemons of the
+// peers allocated to its shards, so that the original file is rebuilt
+// from them. Blocks which none of them hold, and the blocks of content
+// which is not sharded, are fetched by the IPFS daemon of the local peer.
+func NewReader(ctx context.Context, rpcClient *rpc.Client, root cid.Cid) (uio.DagReader, error) {
+	peers, err := shardPeers(ctx, rpcClient, root)
+	if err != nil {
+		return nil, err
+	}
+
+	ng := &rpcNodeGetter{
+		rpcClient: rpcClient,
+		peers:     peers,
+	}
+	n, err := ng.Get(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	return uio.NewDagReader(ctx, n, ng)
+}
+
+// shardPeers returns the peers allocated to the shards of root, when root
+// is a meta-pin.
+func shardPeers(ctx context.Context, rpcClient *rpc.Client, root cid.Cid) ([]peer.ID, error) {
+	var peers []peer.ID
+
+	var metaPinS api.PinSerial
+	err := rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"PinGet",
+		api.PinCid(root).ToSerial(),
+		&metaPinS,
+	)
+	if err != nil {
+		// Not tracked by cluster. We can still try to read it
+		// from the local IPFS daemon.
+		return peers, nil
+	}
+	metaPin := metaPinS.ToPin()
+	if metaPin.Type != api.MetaType {
+		return peers, nil
+	}
+
+	var clusterDAGBlock []byte
+	err = rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"IPFSBlockGet",
+		api.PinCid(metaPin.Reference).ToSerial(),
+		&clusterDAGBlock,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error reading clusterDAG block: %s", err)
+	}
+	clusterDAGNode, err := CborDataToNode(clusterDAGBlock, "cbor")
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[peer.ID]struct{})
+	for _, l := range clusterDAGNode.Links() {
+		var shardPinS api.PinSerial
+		err := rpcClient.CallContext(
+			ctx,
+			"",
+			"Cluster",
+			"PinGet",
+			api.PinCid(l.Cid).ToSerial(),
+			&shardPinS,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error getting shard pin %s: %s", l.Cid, err)
+		}
+		for _, p := range shardPinS.ToPin().Allocations {
+			if _, ok := seen[p]; ok {
+				continue
+			}
+			seen[p] = struct{}{}
+			peers = append(peers, p)
+		}
+	}
+	return peers, nil
+}
+
+// rpcNodeGetter is an ipld.NodeGetter which reads blocks from the IPFS
+// daemons of the given peers, in order, until one of them holds it. The
+// daemons are not allowed to look for the block in the network, since a
+// daemon which does not hold it would block until the request times out.
+// As a last resort, the block is fetched by the IPFS daemon of the local
+// peer.
+type rpcNodeGetter struct {
+	rpcClient *rpc.Client
+	peers     []peer.ID
+}
+
+// Get returns the node with the given CID.
+func (ng *rpcNodeGetter) Get(ctx context.Context, c cid.Cid) (ipld.Node, error) {
+	for _, p := range ng.peers {
+		data, err := ng.blockGet(ctx, p, "IPFSBlockGetLocal", c)
+		if err != nil {
+			logger.Debugf("error getting block %s from %s: %s", c, p, err)
+			continue
+		}
+		return decodeBlock(data, c)
+	}
+
+	data, err := ng.blockGet(ctx, "", "IPFSBlockGet", c)
+	if err != nil {
+		return nil, fmt.Errorf("block %s could not be fetched from any peer: %s", c, err)
+	}
+	return decodeBlock(data, c)
+}
+
+func (ng *rpcNodeGetter) blockGet(ctx context.Context, p peer.ID, method string, c cid.Cid) ([]byte, error) {
+	var data []byte
+	err := ng.rpcClient.CallContext(
+		ctx,
+		p,
+		"Cluster",
+		method,
+		api.PinCid(c).ToSerial(),
+		&data,
+	)
+	return data, err
+}
+
+func decodeBlock(data []byte, c cid.Cid) (ipld.Node, error) {
+	b, err := blocks.NewBlockWithCid(data, c)
+	if err != nil {
+		return nil, err
+	}
+	return ipld.Decode(b)
+}
+
+// GetMany returns the nodes with the given CIDs. They are fetched one by
+// one.
+func (ng *rpcNodeGetter) GetMany(ctx context.Context, cids []cid.Cid) <-chan *ipld.NodeOption {
+	out := make(chan *ipld.NodeOption, len(cids))
+	go func() {
+		defer close(out)
+		for _, c := range cids {
+			n, err := ng.Get(ctx, c)
+			select {
+			case out <- &ipld.NodeOption{Node: n, Err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return out
+}
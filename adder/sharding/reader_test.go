@@ -0,0 +1,128 @@
+package sharding
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+
+	adder "github.com/elastos/Elastos.NET.Hive.Cluster/adder"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+
+	files "github.com/ipfs/go-ipfs-files"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+)
+
+// readerRPC exposes the blocks and pins stored by testRPC through the
+// RPC methods used by NewReader. When missing is set, the IPFS daemons
+// do not hold the blocks and they can only be fetched from the network.
+type readerRPC struct {
+	*testRPC
+	missing bool
+	fetched int32
+}
+
+func (rpcs *readerRPC) PinGet(ctx context.Context, in api.PinSerial, out *api.PinSerial) error {
+	p, err := rpcs.testRPC.PinGet(in.ToPin().Cid)
+	if err != nil {
+		return err
+	}
+	*out = p.ToSerial()
+	return nil
+}
+
+func (rpcs *readerRPC) IPFSBlockGet(ctx context.Context, in api.PinSerial, out *[]byte) error {
+	b, err := rpcs.testRPC.BlockGet(in.ToPin().Cid)
+	if err != nil {
+		return errors.New("not found")
+	}
+	atomic.AddInt32(&rpcs.fetched, 1)
+	*out = b
+	return nil
+}
+
+func (rpcs *readerRPC) IPFSBlockGetLocal(ctx context.Context, in api.PinSerial, out *[]byte) error {
+	if rpcs.missing {
+		return errors.New("not found")
+	}
+	b, err := rpcs.testRPC.BlockGet(in.ToPin().Cid)
+	if err != nil {
+		return errors.New("not found")
+	}
+	*out = b
+	return nil
+}
+
+func TestNewReader(t *testing.T) {
+	t.Run("shards held by their peers", func(t *testing.T) {
+		rpcs := testReader(t, false)
+		// Only the Cluster DAG is fetched.
+		if n := atomic.LoadInt32(&rpcs.fetched); n != 1 {
+			t.Errorf("expected 1 block fetched, got %d", n)
+		}
+	})
+
+	t.Run("local peer without the shards", func(t *testing.T) {
+		rpcs := testReader(t, true)
+		if atomic.LoadInt32(&rpcs.fetched) <= 1 {
+			t.Error("the blocks should have been fetched")
+		}
+	})
+}
+
+// testReader adds a sharded file and checks that it can be read back.
+func testReader(t *testing.T, missing bool) *readerRPC {
+	rpcObj := &testRPC{}
+	rpcs := &readerRPC{testRPC: rpcObj}
+	server := rpc.NewServer(nil, "mock")
+	err := server.RegisterName("Cluster", rpcs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.NewClientWithServer(nil, "mock", server)
+
+	data := make([]byte, 1024*1024*3)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	p := api.DefaultAddParams()
+	p.ShardSize = 1024 * 1024 // 1MB
+	p.Name = "testingFile"
+	p.Shard = true
+
+	dags := New(client, p.PinOptions, nil)
+	add := adder.New(dags, p, nil)
+	dir := files.NewMapDirectory(map[string]files.Node{
+		"file": files.NewBytesFile(data),
+	})
+	root, err := add.FromFiles(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metaPin, err := rpcObj.PinGet(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metaPin.Type != api.MetaType {
+		t.Fatal("the file should have been sharded")
+	}
+
+	rpcs.missing = missing
+	r, err := NewReader(context.Background(), client, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	read, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(read, data) {
+		t.Error("the file read from the shards does not match the original")
+	}
+	return rpcs
+}
@@ -0,0 +1,233 @@
+package ipfscluster
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p-peer"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/rpcutil"
+)
+
+// Alert policies decide how the cluster responds to alerts on a peer.
+const (
+	// AlertPolicyNotify only logs and lists the alert.
+	AlertPolicyNotify = "notify"
+	// AlertPolicyStopAllocating makes the peer not receive new
+	// allocations while the alert is active.
+	AlertPolicyStopAllocating = "stop_allocating"
+	// AlertPolicyMovePins additionally makes the cluster leader repin
+	// everything allocated to the peer elsewhere.
+	AlertPolicyMovePins = "move_pins"
+)
+
+// AlertThreshold triggers alerts when the value of an informer metric
+// is below or above (Condition) the given Value.
+type AlertThreshold struct {
+	Metric    string  `json:"metric"`
+	Condition string  `json:"condition"`
+	Value     float64 `json:"value"`
+	Policy    string  `json:"policy"`
+}
+
+func (th AlertThreshold) validate() error {
+	if th.Metric == "" {
+		return errors.New("cluster.alert_thresholds: metric is empty")
+	}
+	if th.Condition != "below" && th.Condition != "above" {
+		return fmt.Errorf("cluster.alert_thresholds: bad condition for %s", th.Metric)
+	}
+	if !isAlertPolicyValid(th.Policy) {
+		return fmt.Errorf("cluster.alert_thresholds: bad policy for %s", th.Metric)
+	}
+	return nil
+}
+
+// crossed returns true when the given metric value triggers an alert.
+func (th AlertThreshold) crossed(value string) bool {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return false
+	}
+	if th.Condition == "below" {
+		return v < th.Value
+	}
+	return v > th.Value
+}
+
+func isAlertPolicyValid(policy string) bool {
+	switch policy {
+	case AlertPolicyNotify, AlertPolicyStopAllocating, AlertPolicyMovePins:
+		return true
+	}
+	return false
+}
+
+// alertPolicy returns the policy which applies to an alert.
+func (c *Cluster) alertPolicy(alrt api.Alert) string {
+	switch alrt.MetricName {
+	case pingMetricName:
+		return AlertPolicyMovePins
+	case pinHealthAlertName:
+		return AlertPolicyNotify
+	case api.IPFSDownAlertName:
+		return c.config.IPFSDownPolicy
+	}
+	for _, th := range c.config.AlertThresholds {
+		if th.Metric == alrt.MetricName {
+			return th.Policy
+		}
+	}
+	return AlertPolicyNotify
+}
+
+// recordAlert stores an alert until it expires, setting its policy and
+// timestamps. It returns true when the alert was not active already.
+func (c *Cluster) recordAlert(alrt *api.Alert) bool {
+	now := time.Now()
+	alrt.Policy = c.alertPolicy(*alrt)
+	alrt.TS = now
+	alrt.Expire = now.Add(c.config.AlertTTL)
+
+	c.alertsMux.Lock()
+	defer c.alertsMux.Unlock()
+	peerAlerts, ok := c.alerts[alrt.Peer]
+	if !ok {
+		peerAlerts = make(map[string]api.Alert)
+		c.alerts[alrt.Peer] = peerAlerts
+	}
+	prev, ok := peerAlerts[alrt.MetricName]
+	peerAlerts[alrt.MetricName] = *alrt
+	return !ok || now.After(prev.Expire)
+}
+
+// pruneAlerts forgets the alerts which have expired.
+func (c *Cluster) pruneAlerts() {
+	now := time.Now()
+	c.alertsMux.Lock()
+	defer c.alertsMux.Unlock()
+	for p, peerAlerts := range c.alerts {
+		for name, alrt := range peerAlerts {
+			if !now.Before(alrt.Expire) {
+				delete(peerAlerts, name)
+			}
+		}
+		if len(peerAlerts) == 0 {
+			delete(c.alerts, p)
+		}
+	}
+}
+
+// Alerts returns the alerts which are currently active, as recorded by
+// this peer. All peers record the alerts they receive, so that every one
+// of them honors the stop_allocating policy.
+func (c *Cluster) Alerts() []api.Alert {
+	now := time.Now()
+	c.alertsMux.RLock()
+	defer c.alertsMux.RUnlock()
+
+	alerts := make([]api.Alert, 0)
+	for _, peerAlerts := range c.alerts {
+		for _, alrt := range peerAlerts {
+			if now.Before(alrt.Expire) {
+				alerts = append(alerts, alrt)
+			}
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Peer != alerts[j].Peer {
+			return alerts[i].Peer < alerts[j].Peer
+		}
+		return alerts[i].MetricName < alerts[j].MetricName
+	})
+	return alerts
+}
+
+// allocationBlocked returns true when a peer has active alerts which
+// prevent allocating new pins to it.
+func (c *Cluster) allocationBlocked(p peer.ID) bool {
+	now := time.Now()
+	c.alertsMux.RLock()
+	defer c.alertsMux.RUnlock()
+	for _, alrt := range c.alerts[p] {
+		if alrt.Policy != AlertPolicyNotify && now.Before(alrt.Expire) {
+			return true
+		}
+	}
+	return false
+}
+
+// SendAlert delivers an alert to the PeerMonitor of every cluster peer.
+// It is used by components, like the IPFS connector, which detect
+// problems in this peer. Alerts without a peer are about this one.
+func (c *Cluster) SendAlert(alrt api.Alert) error {
+	if alrt.Peer == "" {
+		alrt.Peer = c.id
+	}
+
+	peers, err := c.consensus.Peers()
+	if err != nil {
+		return err
+	}
+
+	ctxs, cancels := rpcutil.CtxsWithCancel(c.ctx, len(peers))
+	defer rpcutil.MultiCancel(cancels)
+
+	errs := c.rpcClient.MultiCall(
+		ctxs,
+		peers,
+		"Cluster",
+		"PeerMonitorSendAlert",
+		alrt,
+		rpcutil.RPCDiscardReplies(len(peers)),
+	)
+	for i, err := range errs {
+		if err != nil {
+			logger.Errorf("error sending alert to %s: %s", peers[i].Pretty(), err)
+		}
+	}
+	return nil
+}
+
+// alertThresholdsWatcher checks, every MonitorPingInterval, the latest
+// metrics of all peers against the AlertThresholds and sends alerts for
+// those crossing them. Every peer runs it.
+func (c *Cluster) alertThresholdsWatcher() {
+	if len(c.config.AlertThresholds) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(c.config.MonitorPingInterval)
+	for {
+		select {
+		case <-c.ctx.Done():
+			ticker.Stop()
+			return
+		case <-ticker.C:
+			c.checkAlertThresholds()
+		}
+	}
+}
+
+func (c *Cluster) checkAlertThresholds() {
+	for _, th := range c.config.AlertThresholds {
+		for _, m := range c.monitor.LatestMetrics(th.Metric) {
+			if m.Discard() || !th.crossed(m.Value) {
+				continue
+			}
+			err := c.monitor.SendAlert(api.Alert{
+				Peer:       m.Peer,
+				MetricName: m.Name,
+				Value:      m.Value,
+				Message:    fmt.Sprintf("%s is %s %g", m.Name, th.Condition, th.Value),
+			})
+			if err != nil {
+				logger.Error(err)
+			}
+		}
+	}
+}
@@ -18,11 +18,14 @@ import (
 // The allocation process has several steps:
 //
 // * Find which peers are pinning a CID
-// * Obtain the last values for the configured informer metrics from the
-//   monitor component
+// * Obtain the last values for the metrics used by the allocator (and
+//   the tags metric when the pin options use tags) from the monitor
+//   component. Peers missing any of them are not considered. The metrics
+//   of other informers are not needed to allocate.
 // * Divide the metrics between "current" (peers already pinning the CID)
 //   and "candidates" (peers that could pin the CID), as long as their metrics
-//   are valid.
+//   are valid. Draining peers and peers with active alerts which stop
+//   allocations are never candidates, so no allocator can pick them.
 // * Given the candidates:
 //   * Check if we are overpinning an item
 //   * Check if there are not enough candidates for the "needed" replication
@@ -35,8 +38,12 @@ import (
 //     * Take as many final candidates from the list as we can, until
 //       ReplicationFactorMax is reached. Error if there are less than
 //       ReplicationFactorMin.
+//     * When the Pin options carry tag constraints, check that the new
+//       allocations match the required and excluded tags and that all
+//       the allocations span at least ReplicationFactorMin distinct
+//       values of the spread tag. Error otherwise.
 
-// allocate finds peers to allocate a hash using the informer and the monitor
+// allocate finds peers to allocate a pin using the informers and the monitor
 // it should only be used with valid replicationFactors (if rplMin and rplMax
 // are > 0, then rplMin <= rplMax).
 // It always returns allocations, but if no new allocations are needed,
@@ -44,7 +51,9 @@ import (
 // into account if the given CID was previously in a "pin everywhere" mode,
 // and will consider such Pins as currently unallocated ones, providing
 // new allocations as available.
-func (c *Cluster) allocate(hash cid.Cid, rplMin, rplMax int, blacklist []peer.ID, prioritylist []peer.ID) ([]peer.ID, error) {
+func (c *Cluster) allocate(pin api.Pin, blacklist []peer.ID, prioritylist []peer.ID) ([]peer.ID, error) {
+	rplMin := pin.ReplicationFactorMin
+	rplMax := pin.ReplicationFactorMax
 	if (rplMin + rplMax) == 0 {
 		return nil, fmt.Errorf("bad replication factors: %d/%d", rplMin, rplMax)
 	}
@@ -53,36 +62,45 @@ func (c *Cluster) allocate(hash cid.Cid, rplMin, rplMax int, blacklist []peer.ID
 		return []peer.ID{}, nil
 	}
 
+	if pin.HasTagConstraints() && !c.hasInformer(api.TagsMetricName) {
+		return nil, errors.New("pin options use peer tags but no tags informer is running")
+	}
+
 	// Figure out who is holding the CID
-	currentPin, _ := c.PinGet(hash)
+	currentPin, _ := c.PinGet(pin.Cid)
 	currentAllocs := currentPin.Allocations
-	metrics := c.monitor.LatestMetrics(c.informer.Name())
+	metrics := c.latestMetricsSets(c.allocationMetrics(pin))
+	draining := c.drainingPeers()
 
-	currentMetrics := make(map[peer.ID]api.Metric)
-	candidatesMetrics := make(map[peer.ID]api.Metric)
-	priorityMetrics := make(map[peer.ID]api.Metric)
+	currentMetrics := make(map[peer.ID]api.MetricsSet)
+	candidatesMetrics := make(map[peer.ID]api.MetricsSet)
+	priorityMetrics := make(map[peer.ID]api.MetricsSet)
 
 	// Divide metrics between current and candidates.
 	// All metrics in metrics are valid (at least the
 	// moment they were compiled by the monitor)
-	for _, m := range metrics {
+	for p, set := range metrics {
 		switch {
-		case containsPeer(blacklist, m.Peer):
+		case containsPeer(blacklist, p):
 			// discard blacklisted peers
 			continue
-		case containsPeer(currentAllocs, m.Peer):
-			currentMetrics[m.Peer] = m
-		case containsPeer(prioritylist, m.Peer):
-			priorityMetrics[m.Peer] = m
+		case containsPeer(currentAllocs, p):
+			currentMetrics[p] = set
+		case containsPeer(draining, p):
+			// draining peers get no new allocations
+			continue
+		case c.allocationBlocked(p):
+			// nor peers with alerts which forbid it
+			continue
+		case containsPeer(prioritylist, p):
+			priorityMetrics[p] = set
 		default:
-			candidatesMetrics[m.Peer] = m
+			candidatesMetrics[p] = set
 		}
 	}
 
 	newAllocs, err := c.obtainAllocations(
-		hash,
-		rplMin,
-		rplMax,
+		pin,
 		currentMetrics,
 		candidatesMetrics,
 		priorityMetrics,
@@ -96,6 +114,75 @@ func (c *Cluster) allocate(hash cid.Cid, rplMin, rplMax int, blacklist []peer.ID
 	return newAllocs, nil
 }
 
+// hasInformer returns true when one of the informers of this peer
+// produces metrics with the given name.
+func (c *Cluster) hasInformer(name string) bool {
+	return providesMetric(c.informers, name)
+}
+
+// providesMetric returns true when one of the given informers produces
+// metrics with the given name.
+func providesMetric(informers []Informer, name string) bool {
+	for _, informer := range informers {
+		if informer.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// allocationMetrics returns the names of the metrics that peers need to
+// be allocated the given pin: those used by the allocator and, when the
+// pin options use peer tags, the tags metric.
+func (c *Cluster) allocationMetrics(pin api.Pin) []string {
+	names := append([]string{}, c.allocator.Metrics()...)
+	if !pin.HasTagConstraints() {
+		return names
+	}
+	for _, name := range names {
+		if name == api.TagsMetricName {
+			return names
+		}
+	}
+	return append(names, api.TagsMetricName)
+}
+
+// latestMetricsSets returns the latest valid values of the given metrics,
+// grouped by peer. Peers which lack any of them are left out. The metrics
+// of other informers are not included, so they never keep a peer from
+// being allocated.
+func (c *Cluster) latestMetricsSets(names []string) map[peer.ID]api.MetricsSet {
+	latest := make(map[string][]api.Metric, len(names))
+	for _, name := range names {
+		latest[name] = c.monitor.LatestMetrics(name)
+	}
+	return metricsSets(latest)
+}
+
+// metricsSets groups the given metrics, indexed by name, by peer. Peers
+// which lack any of the metrics are left out.
+func metricsSets(latest map[string][]api.Metric) map[peer.ID]api.MetricsSet {
+	sets := make(map[peer.ID]api.MetricsSet)
+	for name, metrics := range latest {
+		for _, m := range metrics {
+			set, ok := sets[m.Peer]
+			if !ok {
+				set = make(api.MetricsSet)
+				sets[m.Peer] = set
+			}
+			set[name] = m
+		}
+	}
+
+	for p, set := range sets {
+		if len(set) < len(latest) {
+			logger.Debugf("%s lacks some metrics. Not an allocation candidate", p.Pretty())
+			delete(sets, p)
+		}
+	}
+	return sets
+}
+
 // allocationError logs an allocation error
 func allocationError(hash cid.Cid, needed, wanted int, candidatesValid []peer.ID) error {
 	logger.Errorf("Not enough candidates to allocate %s:", hash)
@@ -113,12 +200,83 @@ func allocationError(hash cid.Cid, needed, wanted int, candidatesValid []peer.ID
 	return errors.New(errorMsg)
 }
 
+// tagsError logs and returns an error when allocations do not satisfy
+// the tag constraints of a pin.
+func tagsError(hash cid.Cid, format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	logger.Errorf("Cannot allocate %s: %s", hash, msg)
+	return errors.New("cannot satisfy the tag constraints of the pin: " + msg)
+}
+
+// checkTagConstraints verifies that the new allocations match the required
+// and excluded tags of the pin and that, together with the current ones,
+// they span at least ReplicationFactorMin distinct values of the spread
+// tag. It fails when fewer values are available, i.e. when a region is
+// down, rather than placing the replicas in fewer of them. The tags of
+// every peer are read from the given metrics.
+func checkTagConstraints(pin api.Pin, current, newAllocs []peer.ID, sets ...map[peer.ID]api.MetricsSet) error {
+	tagsOf := func(p peer.ID) (map[string]string, bool) {
+		for _, s := range sets {
+			set, ok := s[p]
+			if !ok {
+				continue
+			}
+			m, ok := set[api.TagsMetricName]
+			if !ok {
+				return nil, false
+			}
+			tags, err := api.ParseTags(m.Value)
+			return tags, err == nil
+		}
+		return nil, false
+	}
+
+	for _, p := range newAllocs {
+		tags, ok := tagsOf(p)
+		if !ok {
+			return tagsError(pin.Cid, "no valid tags for %s", p.Pretty())
+		}
+		if !pin.MatchTags(tags) {
+			return tagsError(pin.Cid, "%s does not match the required or excluded tags", p.Pretty())
+		}
+	}
+
+	if pin.SpreadTag == "" {
+		return nil
+	}
+
+	values := make(map[string]struct{})
+	for _, allocs := range [][]peer.ID{current, newAllocs} {
+		for _, p := range allocs {
+			tags, ok := tagsOf(p)
+			if !ok {
+				continue
+			}
+			if v, ok := tags[pin.SpreadTag]; ok {
+				values[v] = struct{}{}
+			}
+		}
+	}
+
+	// Pins allocated everywhere have no minimum.
+	needed := pin.ReplicationFactorMin
+	if len(values) < needed {
+		return tagsError(
+			pin.Cid,
+			"allocations span %d distinct values of tag %q. Needed at least: %d",
+			len(values),
+			pin.SpreadTag,
+			needed,
+		)
+	}
+	return nil
+}
+
 func (c *Cluster) obtainAllocations(
-	hash cid.Cid,
-	rplMin, rplMax int,
-	currentValidMetrics map[peer.ID]api.Metric,
-	candidatesMetrics map[peer.ID]api.Metric,
-	priorityMetrics map[peer.ID]api.Metric,
+	pin api.Pin,
+	currentValidMetrics map[peer.ID]api.MetricsSet,
+	candidatesMetrics map[peer.ID]api.MetricsSet,
+	priorityMetrics map[peer.ID]api.MetricsSet,
 ) ([]peer.ID, error) {
 
 	// The list of peers in current
@@ -127,6 +285,9 @@ func (c *Cluster) obtainAllocations(
 		validAllocations = append(validAllocations, k)
 	}
 
+	hash := pin.Cid
+	rplMin := pin.ReplicationFactorMin
+	rplMax := pin.ReplicationFactorMax
 	nCurrentValid := len(validAllocations)
 	nCandidatesValid := len(candidatesMetrics) + len(priorityMetrics)
 	needed := rplMin - nCurrentValid // The minimum we need
@@ -167,7 +328,7 @@ func (c *Cluster) obtainAllocations(
 
 	// the allocator returns a list of peers ordered by priority
 	finalAllocs, err := c.allocator.Allocate(
-		hash,
+		pin,
 		currentValidMetrics,
 		candidatesMetrics,
 		priorityMetrics,
@@ -185,8 +346,23 @@ func (c *Cluster) obtainAllocations(
 	}
 
 	allocationsToUse := minInt(wanted, len(finalAllocs))
+	finalAllocs = finalAllocs[0:allocationsToUse]
+
+	if pin.HasTagConstraints() {
+		err := checkTagConstraints(
+			pin,
+			validAllocations,
+			finalAllocs,
+			currentValidMetrics,
+			candidatesMetrics,
+			priorityMetrics,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// the final result is the currently valid allocations
 	// along with the ones provided by the allocator
-	return append(validAllocations, finalAllocs[0:allocationsToUse]...), nil
+	return append(validAllocations, finalAllocs...), nil
 }
@@ -8,7 +8,6 @@ import (
 	"github.com/elastos/Elastos.NET.Hive.Cluster/allocator/util"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
 
-	cid "github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log"
 	rpc "github.com/libp2p/go-libp2p-gorpc"
 	peer "github.com/libp2p/go-libp2p-peer"
@@ -17,11 +16,16 @@ import (
 var logger = logging.Logger("ascendalloc")
 
 // AscendAllocator extends the SimpleAllocator
-type AscendAllocator struct{}
+type AscendAllocator struct {
+	metricName string
+}
 
-// NewAllocator returns an initialized AscendAllocator
-func NewAllocator() AscendAllocator {
-	return AscendAllocator{}
+// NewAllocator returns an initialized AscendAllocator which sorts peers
+// using the metrics of the given name.
+func NewAllocator(metricName string) AscendAllocator {
+	return AscendAllocator{
+		metricName: metricName,
+	}
 }
 
 // SetClient does nothing in this allocator
@@ -30,14 +34,20 @@ func (alloc AscendAllocator) SetClient(c *rpc.Client) {}
 // Shutdown does nothing in this allocator
 func (alloc AscendAllocator) Shutdown() error { return nil }
 
+// Metrics returns the name of the metric used to sort the peers.
+func (alloc AscendAllocator) Metrics() []string {
+	return []string{alloc.metricName}
+}
+
 // Allocate returns where to allocate a pin request based on metrics which
 // carry a numeric value such as "used disk". We do not pay attention to
 // the metrics of the currently allocated peers and we just sort the
-// candidates based on their metric values (smallest to largest).
-func (alloc AscendAllocator) Allocate(c cid.Cid, current,
-	candidates, priority map[peer.ID]api.Metric) ([]peer.ID, error) {
+// candidates based on their metric values (smallest to largest). Only
+// the metric this allocator was created for is taken into account.
+func (alloc AscendAllocator) Allocate(pin api.Pin, current,
+	candidates, priority map[peer.ID]api.MetricsSet) ([]peer.ID, error) {
 	// sort our metrics
-	first := util.SortNumeric(priority, false)
-	last := util.SortNumeric(candidates, false)
+	first := util.SortNumeric(api.MetricsByName(priority, alloc.metricName), false)
+	last := util.SortNumeric(api.MetricsByName(candidates, alloc.metricName), false)
 	return append(first, last...), nil
 }
@@ -8,7 +8,6 @@ import (
 	"github.com/elastos/Elastos.NET.Hive.Cluster/allocator/util"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
 
-	cid "github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log"
 	rpc "github.com/libp2p/go-libp2p-gorpc"
 	peer "github.com/libp2p/go-libp2p-peer"
@@ -17,11 +16,16 @@ import (
 var logger = logging.Logger("descendalloc")
 
 // DescendAllocator extends the SimpleAllocator
-type DescendAllocator struct{}
+type DescendAllocator struct {
+	metricName string
+}
 
-// NewAllocator returns an initialized DescendAllocator
-func NewAllocator() DescendAllocator {
-	return DescendAllocator{}
+// NewAllocator returns an initialized DescendAllocator which sorts peers
+// using the metrics of the given name.
+func NewAllocator(metricName string) DescendAllocator {
+	return DescendAllocator{
+		metricName: metricName,
+	}
 }
 
 // SetClient does nothing in this allocator
@@ -30,13 +34,19 @@ func (alloc DescendAllocator) SetClient(c *rpc.Client) {}
 // Shutdown does nothing in this allocator
 func (alloc DescendAllocator) Shutdown() error { return nil }
 
+// Metrics returns the name of the metric used to sort the peers.
+func (alloc DescendAllocator) Metrics() []string {
+	return []string{alloc.metricName}
+}
+
 // Allocate returns where to allocate a pin request based on metrics which
 // carry a numeric value such as "used disk". We do not pay attention to
 // the metrics of the currently allocated peers and we just sort the
-// candidates based on their metric values (largest to smallest).
-func (alloc DescendAllocator) Allocate(c cid.Cid, current, candidates, priority map[peer.ID]api.Metric) ([]peer.ID, error) {
+// candidates based on their metric values (largest to smallest). Only
+// the metric this allocator was created for is taken into account.
+func (alloc DescendAllocator) Allocate(pin api.Pin, current, candidates, priority map[peer.ID]api.MetricsSet) ([]peer.ID, error) {
 	// sort our metrics
-	first := util.SortNumeric(priority, true)
-	last := util.SortNumeric(candidates, true)
+	first := util.SortNumeric(api.MetricsByName(priority, alloc.metricName), true)
+	last := util.SortNumeric(api.MetricsByName(candidates, alloc.metricName), true)
 	return append(first, last...), nil
 }
@@ -95,11 +95,19 @@ var testCases = []testcase{
 	},
 }
 
+func metricsSets(metrics map[peer.ID]api.Metric) map[peer.ID]api.MetricsSet {
+	sets := make(map[peer.ID]api.MetricsSet, len(metrics))
+	for p, m := range metrics {
+		sets[p] = api.MetricsSet{m.Name: m}
+	}
+	return sets
+}
+
 func Test(t *testing.T) {
-	alloc := &DescendAllocator{}
+	alloc := NewAllocator("some-metric")
 	for i, tc := range testCases {
 		t.Logf("Test case %d", i)
-		res, err := alloc.Allocate(testCid, tc.current, tc.candidates, nil)
+		res, err := alloc.Allocate(api.PinCid(testCid), metricsSets(tc.current), metricsSets(tc.candidates), nil)
 		if err != nil {
 			t.Fatal(err)
 		}
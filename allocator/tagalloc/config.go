@@ -0,0 +1,96 @@
+package tagalloc
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/config"
+)
+
+const configKey = "tagalloc"
+
+// Metric orders.
+const (
+	// OrderAscending prefers peers with smaller metric values.
+	OrderAscending = "asc"
+	// OrderDescending prefers peers with larger metric values.
+	OrderDescending = "desc"
+)
+
+// Default values for Config.
+const (
+	DefaultSortMetric = "freespace"
+	DefaultSortOrder  = OrderDescending
+)
+
+// Config allows to initialize an Allocator.
+type Config struct {
+	config.Saver
+
+	// SortMetric is the name of the numeric metric used to order the
+	// peers carrying the same value of the spread tag. The cluster peer
+	// runs an informer for it, along with the tags informer.
+	SortMetric string
+
+	// SortOrder is either "asc" (smaller values first) or "desc"
+	// (larger values first).
+	SortOrder string
+}
+
+type jsonConfig struct {
+	SortMetric string `json:"sort_metric"`
+	SortOrder  string `json:"sort_order"`
+}
+
+// ConfigKey returns a human-friendly identifier for this
+// Config's type.
+func (cfg *Config) ConfigKey() string {
+	return configKey
+}
+
+// Default initializes this Config with sensible values: peers with more
+// free space are preferred.
+func (cfg *Config) Default() error {
+	cfg.SortMetric = DefaultSortMetric
+	cfg.SortOrder = DefaultSortOrder
+	return nil
+}
+
+// Validate checks that the fields of this configuration have
+// sensible values.
+func (cfg *Config) Validate() error {
+	if cfg.SortMetric == "" {
+		return errors.New("tagalloc.sort_metric is empty")
+	}
+
+	if cfg.SortOrder != OrderAscending && cfg.SortOrder != OrderDescending {
+		return errors.New("tagalloc.sort_order is invalid")
+	}
+	return nil
+}
+
+// LoadJSON parses a raw JSON byte-slice as generated by ToJSON().
+func (cfg *Config) LoadJSON(raw []byte) error {
+	jcfg := &jsonConfig{}
+	err := json.Unmarshal(raw, jcfg)
+	if err != nil {
+		return err
+	}
+
+	cfg.Default()
+
+	config.SetIfNotDefault(jcfg.SortMetric, &cfg.SortMetric)
+	config.SetIfNotDefault(jcfg.SortOrder, &cfg.SortOrder)
+
+	return cfg.Validate()
+}
+
+// ToJSON generates a human-friendly JSON representation of this Config.
+func (cfg *Config) ToJSON() ([]byte, error) {
+	jcfg := &jsonConfig{
+		SortMetric: cfg.SortMetric,
+		SortOrder:  cfg.SortOrder,
+	}
+
+	return config.DefaultJSONMarshal(jcfg)
+}
@@ -0,0 +1,140 @@
+// Package tagalloc implements an ipfscluster.PinAllocator which takes into
+// account the tags published by the peers through the tags informer.
+// Peers which do not carry the tags required by a pin, or carry any of the
+// excluded ones, are discarded. When the pin names a tag to spread on,
+// the allocations are spread across the distinct values of that tag
+// (i.e. regions) before placing a second allocation on any of them. Peers
+// with the same tag values are ordered by a configurable numeric metric.
+package tagalloc
+
+import (
+	"github.com/elastos/Elastos.NET.Hive.Cluster/allocator/util"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+
+	logging "github.com/ipfs/go-log"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+var logger = logging.Logger("tagalloc")
+
+// Allocator spreads allocations across peer tags.
+type Allocator struct {
+	config *Config
+}
+
+// New returns an initialized Allocator.
+func New(cfg *Config) (*Allocator, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Allocator{
+		config: cfg,
+	}, nil
+}
+
+// SetClient does nothing in this allocator
+func (alloc *Allocator) SetClient(c *rpc.Client) {}
+
+// Shutdown does nothing in this allocator
+func (alloc *Allocator) Shutdown() error { return nil }
+
+// Metrics returns the tags metric and the metric used to sort the peers.
+func (alloc *Allocator) Metrics() []string {
+	return []string{api.TagsMetricName, alloc.config.SortMetric}
+}
+
+// Allocate returns where to allocate a pin request. Candidates which do
+// not match the tags of the pin options are left out. The rest are sorted
+// by the configured metric, priority peers first. When the pin has
+// a spread tag, peers are then picked so that every new allocation goes
+// to the value of the tag with the fewest allocations so far, counting
+// those of the currently allocated peers.
+func (alloc *Allocator) Allocate(pin api.Pin, current, candidates, priority map[peer.ID]api.MetricsSet) ([]peer.ID, error) {
+	tags := make(map[peer.ID]map[string]string)
+	alloc.collectTags(tags, pin, priority)
+	alloc.collectTags(tags, pin, candidates)
+
+	reverse := alloc.config.SortOrder == OrderDescending
+	first := util.SortNumeric(api.MetricsByName(filter(priority, tags), alloc.config.SortMetric), reverse)
+	last := util.SortNumeric(api.MetricsByName(filter(candidates, tags), alloc.config.SortMetric), reverse)
+	sorted := append(first, last...)
+
+	if pin.SpreadTag == "" {
+		return sorted, nil
+	}
+
+	counts := make(map[string]int)
+	for _, set := range current {
+		m, ok := set[api.TagsMetricName]
+		if !ok {
+			continue
+		}
+		currentTags, err := api.ParseTags(m.Value)
+		if err != nil {
+			continue
+		}
+		if v, ok := currentTags[pin.SpreadTag]; ok {
+			counts[v]++
+		}
+	}
+
+	return spread(sorted, tags, pin.SpreadTag, counts), nil
+}
+
+// collectTags places in tagsMap the tags of the given peers which match
+// the tag constraints of the pin.
+func (alloc *Allocator) collectTags(tagsMap map[peer.ID]map[string]string, pin api.Pin, sets map[peer.ID]api.MetricsSet) {
+	for p, set := range sets {
+		m, ok := set[api.TagsMetricName]
+		if !ok || m.Discard() {
+			logger.Debugf("%s: no tags metric", p.Pretty())
+			continue
+		}
+		tags, err := api.ParseTags(m.Value)
+		if err != nil {
+			logger.Debugf("%s: bad tags metric value: %s", p.Pretty(), m.Value)
+			continue
+		}
+		if !pin.MatchTags(tags) {
+			continue
+		}
+		tagsMap[p] = tags
+	}
+}
+
+// filter returns the metric sets of the peers in tagsMap.
+func filter(sets map[peer.ID]api.MetricsSet, tagsMap map[peer.ID]map[string]string) map[peer.ID]api.MetricsSet {
+	filtered := make(map[peer.ID]api.MetricsSet, len(sets))
+	for p, set := range sets {
+		if _, ok := tagsMap[p]; ok {
+			filtered[p] = set
+		}
+	}
+	return filtered
+}
+
+// spread re-orders the sorted peers so that every next peer carries the
+// value of the spread tag which has been used the fewest times (as
+// recorded in counts). Among those, the order of sorted is kept.
+func spread(sorted []peer.ID, tagsMap map[peer.ID]map[string]string, tag string, counts map[string]int) []peer.ID {
+	remaining := make([]peer.ID, len(sorted))
+	copy(remaining, sorted)
+	result := make([]peer.ID, 0, len(sorted))
+
+	for len(remaining) > 0 {
+		best := 0
+		for i, p := range remaining {
+			if counts[tagsMap[p][tag]] < counts[tagsMap[remaining[best]][tag]] {
+				best = i
+			}
+		}
+		p := remaining[best]
+		counts[tagsMap[p][tag]]++
+		result = append(result, p)
+		remaining = append(remaining[:best], remaining[best+1:]...)
+	}
+	return result
+}
@@ -0,0 +1,138 @@
+package tagalloc
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+var (
+	peer0      = peer.ID("QmUQ6Nsejt1SuZAu8yL8WgqQZHHAYreLVYYa4VPsLUCed7")
+	peer1      = peer.ID("QmUZ13osndQ5uL4tPWHXe3iBgBgq9gfewcBMSCAuMBsDJ6")
+	peer2      = peer.ID("QmPrSBATWGAN56fiiEWEhKX3L1F3mTghEQR7vQwaeo7zHi")
+	peer3      = peer.ID("QmPGDFvBkgWhvzEK9qaTWrWurSwqXNmhnK3hgELPdZZNPa")
+	peer4      = peer.ID("QmSGCzHkz8gC9fNndMtaCZdf9RFtwtbTEEsGo4zkVfcykD")
+	testCid, _ = cid.Decode("QmP63DkAFEnDYNjDYBpyNDfttu1fvUw99x1brscPzpqmmq")
+)
+
+var inAMinute = time.Now().Add(time.Minute).UnixNano()
+
+func metricsSet(freespace string, tags map[string]string) api.MetricsSet {
+	value, _ := json.Marshal(tags)
+	return api.MetricsSet{
+		"freespace": api.Metric{
+			Name:   "freespace",
+			Value:  freespace,
+			Expire: inAMinute,
+			Valid:  true,
+		},
+		api.TagsMetricName: api.Metric{
+			Name:   api.TagsMetricName,
+			Value:  string(value),
+			Expire: inAMinute,
+			Valid:  true,
+		},
+	}
+}
+
+func testAllocator(t *testing.T) *Allocator {
+	cfg := &Config{}
+	cfg.Default()
+	alloc, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return alloc
+}
+
+func checkAllocs(t *testing.T, res, expected []peer.ID) {
+	if len(res) != len(expected) {
+		t.Fatalf("expected %d allocations but got %d", len(expected), len(res))
+	}
+	for i, r := range res {
+		if e := expected[i]; r != e {
+			t.Errorf("expect r[%d]=%s but got %s", i, e.Pretty(), r.Pretty())
+		}
+	}
+}
+
+func testCandidates() map[peer.ID]api.MetricsSet {
+	return map[peer.ID]api.MetricsSet{
+		peer0: metricsSet("500", map[string]string{"region": "eu", "tier": "ssd"}),
+		peer1: metricsSet("400", map[string]string{"region": "eu", "tier": "ssd"}),
+		peer2: metricsSet("300", map[string]string{"region": "us", "tier": "hdd"}),
+		peer3: metricsSet("200", map[string]string{"region": "asia", "tier": "ssd"}),
+		peer4: metricsSet("100", map[string]string{"tier": "ssd"}),
+	}
+}
+
+func TestAllocateNoTags(t *testing.T) {
+	alloc := testAllocator(t)
+	res, err := alloc.Allocate(api.PinCid(testCid), nil, testCandidates(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAllocs(t, res, []peer.ID{peer0, peer1, peer2, peer3, peer4})
+}
+
+func TestAllocateSpread(t *testing.T) {
+	alloc := testAllocator(t)
+	pin := api.PinWithOpts(testCid, api.PinOptions{SpreadTag: "region"})
+	res, err := alloc.Allocate(pin, nil, testCandidates(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// peer4 has no region.
+	checkAllocs(t, res, []peer.ID{peer0, peer2, peer3, peer1})
+}
+
+func TestAllocateSpreadWithCurrent(t *testing.T) {
+	alloc := testAllocator(t)
+	pin := api.PinWithOpts(testCid, api.PinOptions{SpreadTag: "region"})
+	candidates := testCandidates()
+	current := map[peer.ID]api.MetricsSet{
+		peer0: candidates[peer0],
+	}
+	delete(candidates, peer0)
+
+	res, err := alloc.Allocate(pin, current, candidates, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAllocs(t, res, []peer.ID{peer2, peer3, peer1})
+}
+
+func TestAllocateRequiredExcluded(t *testing.T) {
+	alloc := testAllocator(t)
+	pin := api.PinWithOpts(testCid, api.PinOptions{
+		SpreadTag:    "region",
+		RequiredTags: map[string]string{"tier": "ssd"},
+		ExcludedTags: map[string]string{"region": "asia"},
+	})
+	res, err := alloc.Allocate(pin, nil, testCandidates(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAllocs(t, res, []peer.ID{peer0, peer1})
+}
+
+func TestAllocatePriority(t *testing.T) {
+	alloc := testAllocator(t)
+	pin := api.PinWithOpts(testCid, api.PinOptions{SpreadTag: "region"})
+	candidates := testCandidates()
+	priority := map[peer.ID]api.MetricsSet{
+		peer1: candidates[peer1],
+	}
+	delete(candidates, peer1)
+
+	res, err := alloc.Allocate(pin, nil, candidates, priority)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAllocs(t, res, []peer.ID{peer1, peer2, peer3, peer0})
+}
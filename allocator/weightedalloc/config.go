@@ -0,0 +1,155 @@
+package weightedalloc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/config"
+)
+
+const configKey = "weightedalloc"
+
+// Metric orders.
+const (
+	// OrderAscending prefers peers with smaller metric values.
+	OrderAscending = "asc"
+	// OrderDescending prefers peers with larger metric values.
+	OrderDescending = "desc"
+)
+
+// Normalization methods.
+const (
+	// NormalizeMinMax scales metric values linearly between the smallest
+	// and the largest value among the candidates.
+	NormalizeMinMax = "minmax"
+	// NormalizeRank scores metric values by their position when the
+	// candidates are sorted, ignoring how far apart the values are.
+	NormalizeRank = "rank"
+)
+
+// Default values for Config.
+const (
+	DefaultNormalization = NormalizeMinMax
+)
+
+// MetricWeight configures how much a metric contributes to the score of
+// a peer and whether smaller or larger values are preferred.
+type MetricWeight struct {
+	Name   string  `json:"name"`
+	Weight float64 `json:"weight"`
+	Order  string  `json:"order"`
+}
+
+// Config allows to initialize an Allocator.
+type Config struct {
+	config.Saver
+
+	// Metrics lists the informer metrics combined by the allocator.
+	// The cluster peer runs an informer for each of them.
+	Metrics []MetricWeight
+
+	// Normalization is the method used to bring the values of
+	// different metrics to a common [0, 1] scale before weighting them.
+	Normalization string
+}
+
+type jsonConfig struct {
+	Metrics       []MetricWeight `json:"metrics"`
+	Normalization string         `json:"normalization"`
+}
+
+// ConfigKey returns a human-friendly identifier for this
+// Config's type.
+func (cfg *Config) ConfigKey() string {
+	return configKey
+}
+
+// Default initializes this Config with sensible values: peers with more
+// free space and fewer pins are preferred, with the same weight.
+func (cfg *Config) Default() error {
+	cfg.Metrics = []MetricWeight{
+		{
+			Name:   "freespace",
+			Weight: 1,
+			Order:  OrderDescending,
+		},
+		{
+			Name:   "numpin",
+			Weight: 1,
+			Order:  OrderAscending,
+		},
+	}
+	cfg.Normalization = DefaultNormalization
+	return nil
+}
+
+// Validate checks that the fields of this configuration have
+// sensible values.
+func (cfg *Config) Validate() error {
+	if len(cfg.Metrics) == 0 {
+		return errors.New("weightedalloc.metrics is empty")
+	}
+
+	names := make(map[string]struct{})
+	for _, m := range cfg.Metrics {
+		if m.Name == "" {
+			return errors.New("weightedalloc.metrics: empty metric name")
+		}
+		if _, ok := names[m.Name]; ok {
+			return fmt.Errorf("weightedalloc.metrics: duplicated metric %s", m.Name)
+		}
+		names[m.Name] = struct{}{}
+
+		if m.Weight <= 0 {
+			return fmt.Errorf("weightedalloc.metrics: invalid weight for %s", m.Name)
+		}
+		if m.Order != OrderAscending && m.Order != OrderDescending {
+			return fmt.Errorf("weightedalloc.metrics: invalid order for %s", m.Name)
+		}
+	}
+
+	switch cfg.Normalization {
+	case NormalizeMinMax, NormalizeRank:
+	default:
+		return errors.New("weightedalloc.normalization is invalid")
+	}
+	return nil
+}
+
+// LoadJSON parses a raw JSON byte-slice as generated by ToJSON().
+func (cfg *Config) LoadJSON(raw []byte) error {
+	jcfg := &jsonConfig{}
+	err := json.Unmarshal(raw, jcfg)
+	if err != nil {
+		return err
+	}
+
+	cfg.Default()
+
+	if jcfg.Metrics != nil {
+		cfg.Metrics = jcfg.Metrics
+	}
+	config.SetIfNotDefault(jcfg.Normalization, &cfg.Normalization)
+
+	return cfg.Validate()
+}
+
+// ToJSON generates a human-friendly JSON representation of this Config.
+func (cfg *Config) ToJSON() ([]byte, error) {
+	jcfg := &jsonConfig{
+		Metrics:       cfg.Metrics,
+		Normalization: cfg.Normalization,
+	}
+
+	return config.DefaultJSONMarshal(jcfg)
+}
+
+// MetricNames returns the names of the metrics combined by the allocator.
+func (cfg *Config) MetricNames() []string {
+	names := make([]string, len(cfg.Metrics), len(cfg.Metrics))
+	for i, m := range cfg.Metrics {
+		names[i] = m.Name
+	}
+	return names
+}
@@ -0,0 +1,95 @@
+package weightedalloc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var cfgJSON = []byte(`
+{
+      "metrics": [
+            {
+                  "name": "freespace",
+                  "weight": 2,
+                  "order": "desc"
+            },
+            {
+                  "name": "numpin",
+                  "weight": 0.5,
+                  "order": "asc"
+            }
+      ],
+      "normalization": "rank"
+}
+`)
+
+func TestLoadJSON(t *testing.T) {
+	cfg := &Config{}
+	err := cfg.LoadJSON(cfgJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Metrics) != 2 || cfg.Metrics[1].Weight != 0.5 {
+		t.Error("metrics not loaded")
+	}
+	if cfg.Normalization != NormalizeRank {
+		t.Error("normalization not loaded")
+	}
+
+	j := &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.Metrics[0].Order = "sideways"
+	tst, _ := json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error decoding order")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.Normalization = "none"
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error decoding normalization")
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	cfg := &Config{}
+	cfg.LoadJSON(cfgJSON)
+	newjson, err := cfg.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = &Config{}
+	err = cfg.LoadJSON(newjson)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	cfg := &Config{}
+	cfg.Default()
+	if cfg.Validate() != nil {
+		t.Fatal("error validating")
+	}
+
+	cfg.Metrics[0].Weight = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.Metrics[1].Name = cfg.Metrics[0].Name
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.Metrics = nil
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+}
@@ -0,0 +1,188 @@
+// Package weightedalloc implements an ipfscluster.PinAllocator which
+// combines the metrics of several informers. The values of every metric
+// are normalized among the candidate peers, weighted and added into a
+// single score. Peers with the highest scores are first in the list.
+// All the metrics must carry numeric values.
+package weightedalloc
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+
+	logging "github.com/ipfs/go-log"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+var logger = logging.Logger("weightedalloc")
+
+// Allocator scores peers using a weighted combination of their metrics.
+type Allocator struct {
+	config *Config
+}
+
+// New returns an initialized Allocator.
+func New(cfg *Config) (*Allocator, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Allocator{
+		config: cfg,
+	}, nil
+}
+
+// SetClient does nothing in this allocator
+func (alloc *Allocator) SetClient(c *rpc.Client) {}
+
+// Shutdown does nothing in this allocator
+func (alloc *Allocator) Shutdown() error { return nil }
+
+// Metrics returns the names of the metrics combined by the allocator.
+func (alloc *Allocator) Metrics() []string {
+	return alloc.config.MetricNames()
+}
+
+// Allocate returns where to allocate a pin request. Priority peers come
+// first, followed by the rest of candidates, each group sorted by score.
+// Metrics are normalized among priority peers and candidates together so
+// that their scores are comparable. Peers which lack any of the configured
+// metrics, or carry non-numeric values for them, are discarded. The
+// metrics of the currently allocated peers are not taken into account.
+func (alloc *Allocator) Allocate(pin api.Pin, current, candidates, priority map[peer.ID]api.MetricsSet) ([]peer.ID, error) {
+	values := make(map[peer.ID][]float64)
+	alloc.collect(values, priority)
+	alloc.collect(values, candidates)
+
+	scores := alloc.score(values)
+
+	first := sortByScore(priority, scores)
+	last := sortByScore(candidates, scores)
+	return append(first, last...), nil
+}
+
+// collect places the values of the configured metrics of the given peers
+// in the values map, in the same order as the configured metrics.
+func (alloc *Allocator) collect(values map[peer.ID][]float64, sets map[peer.ID]api.MetricsSet) {
+	for p, set := range sets {
+		vals := make([]float64, 0, len(alloc.config.Metrics))
+		for _, mw := range alloc.config.Metrics {
+			m, ok := set[mw.Name]
+			if !ok || m.Discard() {
+				break
+			}
+			v, err := strconv.ParseFloat(m.Value, 64)
+			if err != nil {
+				logger.Debugf("%s: bad %s metric value: %s", p.Pretty(), m.Name, m.Value)
+				break
+			}
+			vals = append(vals, v)
+		}
+		if len(vals) == len(alloc.config.Metrics) {
+			values[p] = vals
+		}
+	}
+}
+
+// score normalizes every metric to the [0, 1] range, where 1 is the most
+// preferred value, and returns the weighted sum for every peer.
+func (alloc *Allocator) score(values map[peer.ID][]float64) map[peer.ID]float64 {
+	scores := make(map[peer.ID]float64, len(values))
+	for p := range values {
+		scores[p] = 0
+	}
+
+	for i, mw := range alloc.config.Metrics {
+		column := make(map[peer.ID]float64, len(values))
+		for p, vals := range values {
+			column[p] = vals[i]
+		}
+
+		var normalized map[peer.ID]float64
+		switch alloc.config.Normalization {
+		case NormalizeRank:
+			normalized = normalizeRank(column)
+		default:
+			normalized = normalizeMinMax(column)
+		}
+
+		for p, n := range normalized {
+			if mw.Order == OrderAscending {
+				n = 1 - n
+			}
+			scores[p] += mw.Weight * n
+		}
+	}
+	return scores
+}
+
+// normalizeMinMax maps the smallest value to 0 and the largest to 1. When
+// all values are equal, they are all mapped to 1.
+func normalizeMinMax(column map[peer.ID]float64) map[peer.ID]float64 {
+	first := true
+	var min, max float64
+	for _, v := range column {
+		if first || v < min {
+			min = v
+		}
+		if first || v > max {
+			max = v
+		}
+		first = false
+	}
+
+	normalized := make(map[peer.ID]float64, len(column))
+	for p, v := range column {
+		if max == min {
+			normalized[p] = 1
+			continue
+		}
+		normalized[p] = (v - min) / (max - min)
+	}
+	return normalized
+}
+
+// normalizeRank maps every value to the fraction of the other values
+// which are strictly smaller. Equal values get the same rank. A single
+// value is mapped to 1.
+func normalizeRank(column map[peer.ID]float64) map[peer.ID]float64 {
+	sorted := make([]float64, 0, len(column))
+	for _, v := range column {
+		sorted = append(sorted, v)
+	}
+	sort.Float64s(sorted)
+
+	normalized := make(map[peer.ID]float64, len(column))
+	for p, v := range column {
+		if len(sorted) == 1 {
+			normalized[p] = 1
+			continue
+		}
+		smaller := sort.SearchFloat64s(sorted, v)
+		normalized[p] = float64(smaller) / float64(len(sorted)-1)
+	}
+	return normalized
+}
+
+// sortByScore returns the peers in the given map which have a score,
+// from the highest to the lowest score. Ties are sorted by peer ID.
+func sortByScore(sets map[peer.ID]api.MetricsSet, scores map[peer.ID]float64) []peer.ID {
+	peers := make([]peer.ID, 0, len(sets))
+	for p := range sets {
+		if _, ok := scores[p]; ok {
+			peers = append(peers, p)
+		}
+	}
+
+	sort.Slice(peers, func(i, j int) bool {
+		si, sj := scores[peers[i]], scores[peers[j]]
+		if si != sj {
+			return si > sj
+		}
+		return peers[i] < peers[j]
+	})
+	return peers
+}
@@ -0,0 +1,138 @@
+package weightedalloc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+var (
+	peer0      = peer.ID("QmUQ6Nsejt1SuZAu8yL8WgqQZHHAYreLVYYa4VPsLUCed7")
+	peer1      = peer.ID("QmUZ13osndQ5uL4tPWHXe3iBgBgq9gfewcBMSCAuMBsDJ6")
+	peer2      = peer.ID("QmPrSBATWGAN56fiiEWEhKX3L1F3mTghEQR7vQwaeo7zHi")
+	peer3      = peer.ID("QmPGDFvBkgWhvzEK9qaTWrWurSwqXNmhnK3hgELPdZZNPa")
+	testCid, _ = cid.Decode("QmP63DkAFEnDYNjDYBpyNDfttu1fvUw99x1brscPzpqmmq")
+)
+
+var inAMinute = time.Now().Add(time.Minute).UnixNano()
+
+func metricsSet(freespace, numpin string) api.MetricsSet {
+	set := make(api.MetricsSet)
+	if freespace != "" {
+		set["freespace"] = api.Metric{
+			Name:   "freespace",
+			Value:  freespace,
+			Expire: inAMinute,
+			Valid:  true,
+		}
+	}
+	if numpin != "" {
+		set["numpin"] = api.Metric{
+			Name:   "numpin",
+			Value:  numpin,
+			Expire: inAMinute,
+			Valid:  true,
+		}
+	}
+	return set
+}
+
+func testAllocator(t *testing.T, normalization string) *Allocator {
+	cfg := &Config{}
+	cfg.Default()
+	cfg.Normalization = normalization
+	alloc, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return alloc
+}
+
+func checkAllocs(t *testing.T, res, expected []peer.ID) {
+	if len(res) != len(expected) {
+		t.Fatalf("expected %d allocations but got %d", len(expected), len(res))
+	}
+	for i, r := range res {
+		if e := expected[i]; r != e {
+			t.Errorf("expected r[%d]=%s but got %s", i, e, r)
+		}
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	candidates := map[peer.ID]api.MetricsSet{
+		peer0: metricsSet("100", "10"),
+		peer1: metricsSet("50", "0"),
+		peer2: metricsSet("100", "0"),
+		peer3: metricsSet("0", "10"),
+	}
+
+	for _, norm := range []string{NormalizeMinMax, NormalizeRank} {
+		t.Run(norm, func(t *testing.T) {
+			alloc := testAllocator(t, norm)
+			res, err := alloc.Allocate(api.PinCid(testCid), nil, candidates, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkAllocs(t, res, []peer.ID{peer2, peer1, peer0, peer3})
+		})
+	}
+}
+
+func TestAllocateWeights(t *testing.T) {
+	candidates := map[peer.ID]api.MetricsSet{
+		peer0: metricsSet("100", "10"),
+		peer1: metricsSet("0", "0"),
+	}
+
+	alloc := testAllocator(t, NormalizeMinMax)
+	alloc.config.Metrics[0].Weight = 3
+	res, _ := alloc.Allocate(api.PinCid(testCid), nil, candidates, nil)
+	checkAllocs(t, res, []peer.ID{peer0, peer1})
+
+	alloc.config.Metrics[1].Weight = 5
+	res, _ = alloc.Allocate(api.PinCid(testCid), nil, candidates, nil)
+	checkAllocs(t, res, []peer.ID{peer1, peer0})
+}
+
+func TestAllocateDiscards(t *testing.T) {
+	candidates := map[peer.ID]api.MetricsSet{
+		peer0: metricsSet("100", ""),
+		peer1: metricsSet("abc", "0"),
+		peer2: metricsSet("10", "5"),
+	}
+	invalid := metricsSet("100", "0")
+	m := invalid["numpin"]
+	m.Valid = false
+	invalid["numpin"] = m
+	candidates[peer3] = invalid
+
+	alloc := testAllocator(t, NormalizeMinMax)
+	res, err := alloc.Allocate(api.PinCid(testCid), nil, candidates, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAllocs(t, res, []peer.ID{peer2})
+}
+
+func TestAllocatePriority(t *testing.T) {
+	candidates := map[peer.ID]api.MetricsSet{
+		peer0: metricsSet("100", "0"),
+		peer1: metricsSet("50", "0"),
+	}
+	priority := map[peer.ID]api.MetricsSet{
+		peer2: metricsSet("0", "10"),
+		peer3: metricsSet("10", "10"),
+	}
+
+	alloc := testAllocator(t, NormalizeMinMax)
+	res, err := alloc.Allocate(api.PinCid(testCid), nil, candidates, priority)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAllocs(t, res, []peer.ID{peer3, peer2, peer0, peer1})
+}
@@ -4,7 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 // DefaultShardSize is the shard size for params objects created with DefaultParams().
@@ -82,6 +84,60 @@ func parseIntParam(q url.Values, name string, dest *int) error {
 	return nil
 }
 
+func parseTagsParam(q url.Values, name string) (map[string]string, error) {
+	values := q[name]
+	if len(values) == 0 {
+		return nil, nil
+	}
+	tags := make(map[string]string, len(values))
+	for _, v := range values {
+		kv := strings.SplitN(v, ":", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("parameter %s invalid: use key:value", name)
+		}
+		tags[kv[0]] = kv[1]
+	}
+	return tags, nil
+}
+
+// PinOptionsTagsFromQuery parses the "spread-tag", "require-tag" and
+// "exclude-tag" parameters of a URL.Query() into the given PinOptions.
+// The last two take "key:value" and can be repeated.
+func PinOptionsTagsFromQuery(query url.Values, opts *PinOptions) error {
+	required, err := parseTagsParam(query, "require-tag")
+	if err != nil {
+		return err
+	}
+	excluded, err := parseTagsParam(query, "exclude-tag")
+	if err != nil {
+		return err
+	}
+	opts.SpreadTag = query.Get("spread-tag")
+	opts.RequiredTags = required
+	opts.ExcludedTags = excluded
+	return nil
+}
+
+// AddTagsToQuery sets the tag parameters of the given PinOptions in
+// a URL.Query(). See PinOptionsTagsFromQuery().
+func AddTagsToQuery(query url.Values, opts PinOptions) {
+	if opts.SpreadTag != "" {
+		query.Set("spread-tag", opts.SpreadTag)
+	}
+	add := func(name string, tags map[string]string) {
+		keys := make([]string, 0, len(tags))
+		for k := range tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			query.Add(name, k+":"+tags[k])
+		}
+	}
+	add("require-tag", opts.RequiredTags)
+	add("exclude-tag", opts.ExcludedTags)
+}
+
 // AddParamsFromQuery parses the AddParams object from
 // a URL.Query().
 func AddParamsFromQuery(query url.Values) (*AddParams, error) {
@@ -133,6 +189,11 @@ func AddParamsFromQuery(query url.Values) (*AddParams, error) {
 		return nil, err
 	}
 
+	err = parseBoolParam(query, "public", &params.Public)
+	if err != nil {
+		return nil, err
+	}
+
 	err = parseIntParam(query, "replication-min", &params.ReplicationFactorMin)
 	if err != nil {
 		return nil, err
@@ -147,6 +208,17 @@ func AddParamsFromQuery(query url.Values) (*AddParams, error) {
 		return nil, err
 	}
 
+	priority, err := PinPriorityFromString(query.Get("priority"))
+	if err != nil {
+		return nil, err
+	}
+	params.Priority = priority
+
+	err = PinOptionsTagsFromQuery(query, &params.PinOptions)
+	if err != nil {
+		return nil, err
+	}
+
 	if v := query.Get("shard-size"); v != "" {
 		shardSize, err := strconv.ParseUint(v, 10, 64)
 		if err != nil {
@@ -171,6 +243,7 @@ func (p *AddParams) ToQueryString() string {
 	query.Set("name", p.Name)
 	query.Set("shard", fmt.Sprintf("%t", p.Shard))
 	query.Set("shard-size", fmt.Sprintf("%d", p.ShardSize))
+	query.Set("priority", p.Priority.String())
 	query.Set("recursive", fmt.Sprintf("%t", p.Recursive))
 	query.Set("layout", p.Layout)
 	query.Set("chunker", p.Chunker)
@@ -178,9 +251,11 @@ func (p *AddParams) ToQueryString() string {
 	query.Set("hidden", fmt.Sprintf("%t", p.Hidden))
 	query.Set("wrap-with-directory", fmt.Sprintf("%t", p.Wrap))
 	query.Set("progress", fmt.Sprintf("%t", p.Progress))
+	query.Set("public", fmt.Sprintf("%t", p.Public))
 	query.Set("cid-version", fmt.Sprintf("%d", p.CidVersion))
 	query.Set("hash", p.HashFun)
 	query.Set("stream-channels", fmt.Sprintf("%t", p.StreamChannels))
+	AddTagsToQuery(query, p.PinOptions)
 	return query.Encode()
 }
 
@@ -192,6 +267,11 @@ func (p *AddParams) Equals(p2 *AddParams) bool {
 		p.Recursive == p2.Recursive &&
 		p.Shard == p2.Shard &&
 		p.ShardSize == p2.ShardSize &&
+		p.Priority == p2.Priority &&
+		p.SpreadTag == p2.SpreadTag &&
+		tagsEqual(p.RequiredTags, p2.RequiredTags) &&
+		tagsEqual(p.ExcludedTags, p2.ExcludedTags) &&
+		p.Public == p2.Public &&
 		p.Layout == p2.Layout &&
 		p.Chunker == p2.Chunker &&
 		p.RawLeaves == p2.RawLeaves &&
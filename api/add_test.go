@@ -6,7 +6,7 @@ import (
 )
 
 func TestAddParams_FromQuery(t *testing.T) {
-	qStr := "layout=balanced&chunker=size-262144&name=test&raw-leaves=true&hidden=true&shard=true&replication-min=2&replication-max=4&shard-size=1"
+	qStr := "layout=balanced&chunker=size-262144&name=test&raw-leaves=true&hidden=true&shard=true&replication-min=2&replication-max=4&shard-size=1&priority=high"
 
 	q, err := url.ParseQuery(qStr)
 	if err != nil {
@@ -23,7 +23,8 @@ func TestAddParams_FromQuery(t *testing.T) {
 		!p.RawLeaves || !p.Hidden || !p.Shard ||
 		p.ReplicationFactorMin != 2 ||
 		p.ReplicationFactorMax != 4 ||
-		p.ShardSize != 1 {
+		p.ShardSize != 1 ||
+		p.Priority != PriorityHigh {
 		t.Fatal("did not parse the query correctly")
 	}
 }
@@ -35,6 +36,10 @@ func TestAddParams_ToQueryString(t *testing.T) {
 	p.Name = "something"
 	p.RawLeaves = true
 	p.ShardSize = 1020
+	p.Priority = PriorityBulk
+	p.SpreadTag = "region"
+	p.RequiredTags = map[string]string{"tier": "ssd"}
+	p.ExcludedTags = map[string]string{"rack": "r1", "region": "eu"}
 	qstr := p.ToQueryString()
 
 	q, err := url.ParseQuery(qstr)
@@ -51,3 +56,42 @@ func TestAddParams_ToQueryString(t *testing.T) {
 		t.Error("generated and parsed params should be equal")
 	}
 }
+
+func TestAddParams_FromQueryBadPriority(t *testing.T) {
+	q, err := url.ParseQuery("priority=urgent")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = AddParamsFromQuery(q)
+	if err == nil {
+		t.Error("expected an error parsing an invalid priority")
+	}
+}
+
+func TestAddParams_FromQueryTags(t *testing.T) {
+	q, err := url.ParseQuery("spread-tag=region&require-tag=tier:ssd&exclude-tag=rack:r1&exclude-tag=rack:r2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := AddParamsFromQuery(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.SpreadTag != "region" ||
+		p.RequiredTags["tier"] != "ssd" ||
+		len(p.ExcludedTags) != 1 ||
+		p.ExcludedTags["rack"] != "r2" {
+		t.Fatal("did not parse the tags correctly")
+	}
+
+	q, err = url.ParseQuery("require-tag=ssd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = AddParamsFromQuery(q)
+	if err == nil {
+		t.Error("expected an error parsing a tag without value")
+	}
+}
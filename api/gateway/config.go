@@ -0,0 +1,228 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	ma "github.com/multiformats/go-multiaddr"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/config"
+)
+
+const (
+	configKey    = "gateway"
+	envConfigKey = "cluster_gateway"
+)
+
+// Default values for Config.
+const (
+	DefaultEnabled           = false
+	DefaultListenAddr        = "/ip4/127.0.0.1/tcp/9097"
+	DefaultReadTimeout       = 0
+	DefaultReadHeaderTimeout = 5 * time.Second
+	DefaultWriteTimeout      = 0
+	DefaultIdleTimeout       = 60 * time.Second
+	DefaultPublicFolder      = "public"
+	DefaultCacheMaxAge       = time.Minute
+	DefaultRateLimit         = 10
+	DefaultRateBurst         = 20
+)
+
+// Config allows to enable and customize the read-only HTTP gateway.
+// It implements the config.ComponentConfig interface.
+type Config struct {
+	config.Saver
+
+	// Enabled starts the gateway listener.
+	Enabled bool
+
+	// Listen parameters for the gateway.
+	ListenAddr ma.Multiaddr
+
+	// Maximum duration before timing out reading a full request
+	ReadTimeout time.Duration
+
+	// Maximum duration before timing out reading the headers of a request
+	ReadHeaderTimeout time.Duration
+
+	// Maximum duration before timing out write of the response
+	WriteTimeout time.Duration
+
+	// Server-side amount of time a Keep-Alive connection will be
+	// kept idle before being reused
+	IdleTimeout time.Duration
+
+	// Folder inside every UID home which is publicly served under
+	// /hive/<uid>/. Nothing else in the homes is served.
+	PublicFolder string
+
+	// How long clients may cache content which can change (/ipns/ and
+	// /hive/ paths). Content under /ipfs/ is cached forever.
+	CacheMaxAge time.Duration
+
+	// Number of requests per second allowed for every client address.
+	// 0 disables rate limiting.
+	RateLimit float64
+
+	// Number of requests a client can make at once before being rate
+	// limited.
+	RateBurst int
+}
+
+type jsonConfig struct {
+	Enabled            bool   `json:"enabled"`
+	ListenMultiaddress string `json:"listen_multiaddress"`
+
+	ReadTimeout       string `json:"read_timeout"`
+	ReadHeaderTimeout string `json:"read_header_timeout"`
+	WriteTimeout      string `json:"write_timeout"`
+	IdleTimeout       string `json:"idle_timeout"`
+
+	PublicFolder string  `json:"public_folder"`
+	CacheMaxAge  string  `json:"cache_max_age"`
+	RateLimit    float64 `json:"rate_limit"`
+	RateBurst    int     `json:"rate_burst"`
+}
+
+// ConfigKey provides a human-friendly identifier for this type of Config.
+func (cfg *Config) ConfigKey() string {
+	return configKey
+}
+
+// Default sets the fields of this Config to sensible default values.
+func (cfg *Config) Default() error {
+	listen, err := ma.NewMultiaddr(DefaultListenAddr)
+	if err != nil {
+		return err
+	}
+	cfg.Enabled = DefaultEnabled
+	cfg.ListenAddr = listen
+	cfg.ReadTimeout = DefaultReadTimeout
+	cfg.ReadHeaderTimeout = DefaultReadHeaderTimeout
+	cfg.WriteTimeout = DefaultWriteTimeout
+	cfg.IdleTimeout = DefaultIdleTimeout
+	cfg.PublicFolder = DefaultPublicFolder
+	cfg.CacheMaxAge = DefaultCacheMaxAge
+	cfg.RateLimit = DefaultRateLimit
+	cfg.RateBurst = DefaultRateBurst
+	return nil
+}
+
+// Validate checks that the fields of this Config have sensible values,
+// at least in appearance.
+func (cfg *Config) Validate() error {
+	var err error
+	if cfg.ListenAddr == nil {
+		err = errors.New("gateway.listen_multiaddress not set")
+	}
+
+	if cfg.ReadTimeout < 0 {
+		err = errors.New("gateway.read_timeout is invalid")
+	}
+
+	if cfg.ReadHeaderTimeout < 0 {
+		err = errors.New("gateway.read_header_timeout is invalid")
+	}
+
+	if cfg.WriteTimeout < 0 {
+		err = errors.New("gateway.write_timeout is invalid")
+	}
+
+	if cfg.IdleTimeout < 0 {
+		err = errors.New("gateway.idle_timeout invalid")
+	}
+
+	if cfg.PublicFolder == "" {
+		err = errors.New("gateway.public_folder should not be empty")
+	}
+
+	if cfg.CacheMaxAge < 0 {
+		err = errors.New("gateway.cache_max_age is invalid")
+	}
+
+	if cfg.RateLimit < 0 {
+		err = errors.New("gateway.rate_limit is invalid")
+	}
+
+	if cfg.RateLimit > 0 && cfg.RateBurst <= 0 {
+		err = errors.New("gateway.rate_burst is invalid")
+	}
+
+	return err
+}
+
+// LoadJSON parses a JSON representation of this Config as generated by ToJSON.
+func (cfg *Config) LoadJSON(raw []byte) error {
+	jcfg := &jsonConfig{}
+	err := json.Unmarshal(raw, jcfg)
+	if err != nil {
+		logger.Error("Error unmarshaling gateway config")
+		return err
+	}
+
+	err = cfg.Default()
+	if err != nil {
+		return fmt.Errorf("error setting config to default values: %s", err)
+	}
+
+	// override json config with env var
+	err = envconfig.Process(envConfigKey, jcfg)
+	if err != nil {
+		return err
+	}
+
+	listenAddr, err := ma.NewMultiaddr(jcfg.ListenMultiaddress)
+	if err != nil {
+		return fmt.Errorf("error parsing gateway listen_multiaddress: %s", err)
+	}
+
+	cfg.Enabled = jcfg.Enabled
+	cfg.ListenAddr = listenAddr
+	cfg.RateLimit = jcfg.RateLimit
+	cfg.RateBurst = jcfg.RateBurst
+
+	err = config.ParseDurations(
+		"gateway",
+		&config.DurationOpt{Duration: jcfg.ReadTimeout, Dst: &cfg.ReadTimeout, Name: "read_timeout"},
+		&config.DurationOpt{Duration: jcfg.ReadHeaderTimeout, Dst: &cfg.ReadHeaderTimeout, Name: "read_header_timeout"},
+		&config.DurationOpt{Duration: jcfg.WriteTimeout, Dst: &cfg.WriteTimeout, Name: "write_timeout"},
+		&config.DurationOpt{Duration: jcfg.IdleTimeout, Dst: &cfg.IdleTimeout, Name: "idle_timeout"},
+		&config.DurationOpt{Duration: jcfg.CacheMaxAge, Dst: &cfg.CacheMaxAge, Name: "cache_max_age"},
+	)
+	if err != nil {
+		return err
+	}
+
+	config.SetIfNotDefault(jcfg.PublicFolder, &cfg.PublicFolder)
+
+	return cfg.Validate()
+}
+
+// ToJSON generates a human-friendly JSON representation of this Config.
+func (cfg *Config) ToJSON() (raw []byte, err error) {
+	// Multiaddress String() may panic
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s", r)
+		}
+	}()
+
+	jcfg := &jsonConfig{
+		Enabled:            cfg.Enabled,
+		ListenMultiaddress: cfg.ListenAddr.String(),
+		ReadTimeout:        cfg.ReadTimeout.String(),
+		ReadHeaderTimeout:  cfg.ReadHeaderTimeout.String(),
+		WriteTimeout:       cfg.WriteTimeout.String(),
+		IdleTimeout:        cfg.IdleTimeout.String(),
+		PublicFolder:       cfg.PublicFolder,
+		CacheMaxAge:        cfg.CacheMaxAge.String(),
+		RateLimit:          cfg.RateLimit,
+		RateBurst:          cfg.RateBurst,
+	}
+
+	raw, err = config.DefaultJSONMarshal(jcfg)
+	return
+}
@@ -0,0 +1,123 @@
+package gateway
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+var cfgJSON = []byte(`
+{
+      "enabled": true,
+      "listen_multiaddress": "/ip4/127.0.0.1/tcp/9097",
+      "read_timeout": "0s",
+      "read_header_timeout": "5s",
+      "write_timeout": "0s",
+      "idle_timeout": "1m0s",
+      "public_folder": "shared",
+      "cache_max_age": "5m0s",
+      "rate_limit": 5,
+      "rate_burst": 10
+}
+`)
+
+func TestLoadJSON(t *testing.T) {
+	cfg := &Config{}
+	err := cfg.LoadJSON(cfgJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !cfg.Enabled {
+		t.Error("expected enabled")
+	}
+	if cfg.PublicFolder != "shared" {
+		t.Error("error parsing public_folder")
+	}
+	if cfg.CacheMaxAge != 5*time.Minute {
+		t.Error("error parsing cache_max_age")
+	}
+	if cfg.RateLimit != 5 || cfg.RateBurst != 10 {
+		t.Error("error parsing rate limits")
+	}
+
+	j := &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.ListenMultiaddress = "abc"
+	tst, _ := json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error parsing listen_multiaddress")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.RateBurst = 0
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error in rate_burst")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.CacheMaxAge = "-1s"
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error in cache_max_age")
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	cfg := &Config{}
+	cfg.LoadJSON(cfgJSON)
+	newjson, err := cfg.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = &Config{}
+	err = cfg.LoadJSON(newjson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.PublicFolder != "shared" {
+		t.Error("public_folder not preserved")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	cfg := &Config{}
+	cfg.Default()
+	if cfg.Validate() != nil {
+		t.Fatal("error validating")
+	}
+	if cfg.Enabled {
+		t.Error("the gateway should be disabled by default")
+	}
+
+	cfg.PublicFolder = ""
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.RateLimit = -1
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+}
+
+func TestApplyEnvVars(t *testing.T) {
+	os.Setenv("CLUSTER_GATEWAY_PUBLICFOLDER", "www")
+	defer os.Unsetenv("CLUSTER_GATEWAY_PUBLICFOLDER")
+	cfg := &Config{}
+	err := cfg.LoadJSON(cfgJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.PublicFolder != "www" {
+		t.Error("public_folder should have been overridden")
+	}
+}
@@ -0,0 +1,490 @@
+// Package gateway implements an IPFS Cluster API component which serves
+// public Hive content over plain HTTP, so that clients which only need to
+// download shared files do not have to use the IPFS API. It is read-only
+// and only serves:
+//
+//   - /ipfs/<cid>/<path>: content whose root is pinned in the cluster
+//     with the public option, which grants access to it.
+//   - /ipns/<uid>/<path>: the name published with the key of a Hive UID,
+//     when it points to public content or to the public folder of the UID.
+//   - /hive/<uid>/<path>: the public folder of a UID home.
+//
+// Content is read through the IPFSConnector of the peer, so homes held by
+// any of the IPFS daemons it uses can be served. Directories are listed,
+// unless they hold an index.html file. Files support range requests and
+// conditional requests on their CID.
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/adder/sharding"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/rpcutil"
+
+	mux "github.com/gorilla/mux"
+	cid "github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+	manet "github.com/multiformats/go-multiaddr-net"
+)
+
+var logger = logging.Logger("gateway")
+
+// immutableCacheControl is used for /ipfs/ content, which never changes.
+const immutableCacheControl = "public, max-age=29030400, immutable"
+
+// rateLimitCleanupInterval is how often idle clients are forgotten by
+// the rate limiter.
+var rateLimitCleanupInterval = time.Minute
+
+var errNotFound = errors.New("not found")
+
+// Server is an API component which serves public content over HTTP when
+// enabled in the configuration. Otherwise it does nothing.
+type Server struct {
+	ctx    context.Context
+	cancel func()
+
+	config *Config
+
+	rpcClient *rpc.Client
+	rpcReady  chan struct{}
+
+	listener net.Listener
+	server   *http.Server
+	limiter  *rateLimiter
+
+	shutdownLock sync.Mutex
+	shutdown     bool
+	wg           sync.WaitGroup
+}
+
+// unixfs type of directories in "ls" responses.
+const lsTypeDirectory = 1
+
+// New creates a gateway Server and starts listening when enabled.
+func New(cfg *Config) (*Server, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	gw := &Server{
+		ctx:      ctx,
+		cancel:   cancel,
+		config:   cfg,
+		rpcReady: make(chan struct{}, 1),
+	}
+	if !cfg.Enabled {
+		return gw, nil
+	}
+
+	n, addr, err := manet.DialArgs(cfg.ListenAddr)
+	if err != nil {
+		return nil, err
+	}
+	l, err := net.Listen(n, addr)
+	if err != nil {
+		return nil, err
+	}
+	gw.listener = l
+
+	router := mux.NewRouter()
+	sub := router.Methods(http.MethodGet, http.MethodHead).Subrouter()
+	sub.PathPrefix("/ipfs/").HandlerFunc(gw.ipfsHandler).Name("IPFS")
+	sub.PathPrefix("/ipns/").HandlerFunc(gw.ipnsHandler).Name("IPNS")
+	sub.PathPrefix("/hive/").HandlerFunc(gw.hiveHandler).Name("Hive")
+
+	var handler http.Handler = router
+	if cfg.RateLimit > 0 {
+		gw.limiter = newRateLimiter(cfg.RateLimit, cfg.RateBurst)
+		handler = gw.limiter.middleware(router)
+	}
+
+	gw.server = &http.Server{
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		Handler:           handler,
+	}
+
+	go gw.run()
+	return gw, nil
+}
+
+// launches the gateway when we receive the rpcReady signal.
+func (gw *Server) run() {
+	<-gw.rpcReady
+
+	// Do not shutdown while launching threads
+	// -- prevents race conditions with gw.wg.
+	gw.shutdownLock.Lock()
+	defer gw.shutdownLock.Unlock()
+
+	if gw.shutdown {
+		return
+	}
+
+	gw.wg.Add(1)
+	go func() {
+		defer gw.wg.Done()
+		logger.Infof("Hive gateway: http://%s", gw.listener.Addr())
+		err := gw.server.Serve(gw.listener) // hangs here
+		if err != nil && !strings.Contains(err.Error(), "closed network connection") {
+			logger.Error(err)
+		}
+	}()
+
+	if gw.limiter != nil {
+		gw.wg.Add(1)
+		go gw.cleanupLimiter()
+	}
+}
+
+// cleanupLimiter regularly forgets idle clients in the rate limiter.
+func (gw *Server) cleanupLimiter() {
+	defer gw.wg.Done()
+	ticker := time.NewTicker(rateLimitCleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-gw.ctx.Done():
+			return
+		case now := <-ticker.C:
+			gw.limiter.cleanup(now)
+		}
+	}
+}
+
+// Addr returns the address the gateway listens on, or nil when it is
+// disabled.
+func (gw *Server) Addr() net.Addr {
+	if gw.listener == nil {
+		return nil
+	}
+	return gw.listener.Addr()
+}
+
+// SetClient makes the component ready to perform RPC
+// requests.
+func (gw *Server) SetClient(c *rpc.Client) {
+	gw.rpcClient = c
+	gw.rpcReady <- struct{}{}
+}
+
+// Shutdown stops the gateway listener.
+func (gw *Server) Shutdown() error {
+	gw.shutdownLock.Lock()
+	defer gw.shutdownLock.Unlock()
+
+	if gw.shutdown {
+		logger.Debug("already shutdown")
+		return nil
+	}
+
+	logger.Info("stopping Hive gateway")
+
+	gw.cancel()
+	close(gw.rpcReady)
+	if gw.listener != nil {
+		gw.server.SetKeepAlivesEnabled(false)
+		gw.listener.Close()
+	}
+
+	gw.wg.Wait()
+	gw.shutdown = true
+	return nil
+}
+
+// splitPath returns the first segment of p after prefix and the rest of
+// the path, which is empty or starts with a slash.
+func splitPath(p, prefix string) (string, string) {
+	p = strings.TrimPrefix(p, prefix)
+	i := strings.Index(p, "/")
+	if i < 0 {
+		return p, ""
+	}
+	return p[:i], p[i:]
+}
+
+func (gw *Server) ipfsHandler(w http.ResponseWriter, r *http.Request) {
+	first, rest := splitPath(r.URL.Path, "/ipfs/")
+	c, err := cid.Decode(first)
+	if err != nil {
+		http.Error(w, "invalid CID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var pinS api.PinSerial
+	err = gw.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PinGet",
+		api.PinCid(c).ToSerial(),
+		&pinS,
+	)
+	if err != nil || !pinS.Public {
+		// Only content pinned in the cluster as public is served.
+		http.Error(w, errNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	pin := pinS.ToPin()
+
+	if pin.Type == api.MetaType && rest == "" {
+		gw.serveSharded(w, r, c)
+		return
+	}
+	gw.serveIPFS(w, r, "/ipfs/"+c.String()+rest, immutableCacheControl)
+}
+
+func (gw *Server) ipnsHandler(w http.ResponseWriter, r *http.Request) {
+	uid, rest := splitPath(r.URL.Path, "/ipns/")
+
+	var secret api.UIDSecret
+	err := gw.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"UidInfo",
+		uid,
+		&secret,
+	)
+	if err != nil || secret.PeerID == "" {
+		http.Error(w, errNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	var resolved string
+	err = gw.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"IPFSNameResolve",
+		secret.PeerID,
+		&resolved,
+	)
+	if err != nil {
+		http.Error(w, errNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	// The name may point to anything, so the root it resolves to
+	// must be public, like the content under /ipfs/ and /hive/.
+	root, _ := splitPath(resolved, "/ipfs/")
+	if !strings.HasPrefix(resolved, "/ipfs/") || !gw.isPublicRoot(r.Context(), uid, root) {
+		http.Error(w, errNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	gw.serveIPFS(w, r, resolved+rest, gw.cacheControl())
+}
+
+// isPublicRoot tells whether the given root can be served for the name of
+// uid: it must be pinned in the cluster as public, or be the public folder
+// of the UID home.
+func (gw *Server) isPublicRoot(ctx context.Context, uid, root string) bool {
+	c, err := cid.Decode(root)
+	if err != nil {
+		return false
+	}
+
+	var pinS api.PinSerial
+	err = gw.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"PinGet",
+		api.PinCid(c).ToSerial(),
+		&pinS,
+	)
+	if err == nil && pinS.Public {
+		return true
+	}
+
+	var stat api.FilesStat
+	err = gw.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"IPFSFilesStat",
+		[]string{uid, path.Join("/", gw.config.PublicFolder), "", "", "", ""},
+		&stat,
+	)
+	if err != nil {
+		return false
+	}
+	folder, err := cid.Decode(stat.Hash)
+	return err == nil && folder.Equals(c)
+}
+
+func (gw *Server) hiveHandler(w http.ResponseWriter, r *http.Request) {
+	uid, rest := splitPath(r.URL.Path, "/hive/")
+	if uid == "" || uid == "." || uid == ".." {
+		http.Error(w, errNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	// Cleaning a rooted path removes any ".." which would escape the
+	// public folder.
+	p := path.Join("/", gw.config.PublicFolder, path.Clean("/"+rest))
+
+	// The home is read from the IPFS daemon which holds it.
+	var stat api.FilesStat
+	err := gw.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"IPFSFilesStat",
+		[]string{uid, p, "", "", "", ""},
+		&stat,
+	)
+	if err != nil {
+		logger.Debugf("error serving %s: %s", r.URL.Path, err)
+		http.Error(w, errNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	gw.serve(w, r, stat, path.Base(p), gw.cacheControl())
+}
+
+// cacheControl returns the Cache-Control header for mutable content.
+func (gw *Server) cacheControl() string {
+	return fmt.Sprintf("public, max-age=%d", int(gw.config.CacheMaxAge.Seconds()))
+}
+
+// serveIPFS sends the file or directory at the given IPFS path.
+func (gw *Server) serveIPFS(w http.ResponseWriter, r *http.Request, p, cacheControl string) {
+	p = strings.TrimSuffix(p, "/")
+	var stat api.FilesStat
+	err := gw.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"IPFSStat",
+		p,
+		&stat,
+	)
+	if err != nil {
+		logger.Debugf("error serving %s: %s", p, err)
+		http.Error(w, errNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	gw.serve(w, r, stat, path.Base(p), cacheControl)
+}
+
+// serve sends the file or directory with the given statistics.
+func (gw *Server) serve(w http.ResponseWriter, r *http.Request, stat api.FilesStat, name, cacheControl string) {
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("Etag", `"`+stat.Hash+`"`)
+
+	if stat.Type != "directory" {
+		gw.serveFile(w, r, stat.Hash, name, int64(stat.Size))
+		return
+	}
+
+	// Relative links in listings and index pages need the slash.
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+		return
+	}
+	gw.serveDirectory(w, r, stat.Hash)
+}
+
+// serveFile sends a file, supporting range and conditional requests.
+func (gw *Server) serveFile(w http.ResponseWriter, r *http.Request, hash, name string, size int64) {
+	reader := rpcutil.NewCatReader(r.Context(), gw.rpcClient, "/ipfs/"+hash, size)
+	http.ServeContent(w, r, name, time.Time{}, reader)
+}
+
+// serveSharded sends a file which was added with sharding.
+func (gw *Server) serveSharded(w http.ResponseWriter, r *http.Request, c cid.Cid) {
+	reader, err := sharding.NewReader(r.Context(), gw.rpcClient, c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer reader.Close()
+
+	w.Header().Set("Cache-Control", immutableCacheControl)
+	w.Header().Set("Etag", `"`+c.String()+`"`)
+	http.ServeContent(w, r, "", time.Time{}, reader)
+}
+
+var listingTemplate = template.Must(template.New("listing").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Path}}</title></head>
+<body>
+<h1>{{.Path}}</h1>
+<table>
+{{range .Entries}}<tr><td><a href="{{.Href}}">{{.Name}}</a></td><td>{{.Size}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+type listingEntry struct {
+	Name string
+	Href string
+	Size uint64
+}
+
+// serveDirectory sends the index.html file in a directory, or a listing
+// of its entries when there is none.
+func (gw *Server) serveDirectory(w http.ResponseWriter, r *http.Request, hash string) {
+	if r.Header.Get("If-None-Match") == w.Header().Get("Etag") {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	var ls api.FilesLs
+	err := gw.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"IPFSLs",
+		"/ipfs/"+hash,
+		&ls,
+	)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	entries := []listingEntry{}
+	for _, l := range ls.Entries {
+		if l.Name == "index.html" && l.Type != lsTypeDirectory {
+			gw.serveFile(w, r, l.Hash, l.Name, int64(l.Size))
+			return
+		}
+		href := url.PathEscape(l.Name)
+		if l.Type == lsTypeDirectory {
+			href += "/"
+		}
+		entries = append(entries, listingEntry{
+			Name: l.Name,
+			Href: href,
+			Size: l.Size,
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = listingTemplate.Execute(w, struct {
+		Path    string
+		Entries []listingEntry
+	}{r.URL.Path, entries})
+	if err != nil {
+		logger.Error(err)
+	}
+}
@@ -0,0 +1,331 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/test"
+
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// testGateway starts a gateway which reads content through a mock RPC
+// client. The configuration can be modified with f before starting it.
+func testGateway(t *testing.T, f func(cfg *Config)) *Server {
+	return testGatewayWithClient(t, f, test.NewMockRPCClient(t))
+}
+
+// testGatewayWithClient starts a gateway which reads content through the
+// given RPC client.
+func testGatewayWithClient(t *testing.T, f func(cfg *Config), c *rpc.Client) *Server {
+	listenMAddr, _ := ma.NewMultiaddr("/ip4/127.0.0.1/tcp/0")
+
+	cfg := &Config{}
+	cfg.Default()
+	cfg.Enabled = true
+	cfg.ListenAddr = listenMAddr
+	cfg.RateLimit = 0
+	if f != nil {
+		f(cfg)
+	}
+
+	gw, err := New(cfg)
+	if err != nil {
+		t.Fatal("creating a gateway should work: ", err)
+	}
+	gw.server.SetKeepAlivesEnabled(false)
+	gw.SetClient(c)
+	return gw
+}
+
+func gatewayURL(gw *Server) string {
+	return fmt.Sprintf("http://%s", gw.Addr())
+}
+
+// noRedirect is a client which does not follow redirects.
+var noRedirect = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+func get(t *testing.T, gw *Server, p string, hdrs map[string]string) (*http.Response, string) {
+	req, _ := http.NewRequest("GET", gatewayURL(gw)+p, nil)
+	for k, v := range hdrs {
+		req.Header.Set(k, v)
+	}
+	res, err := noRedirect.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res, string(body)
+}
+
+func TestDisabled(t *testing.T) {
+	cfg := &Config{}
+	cfg.Default()
+	gw, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gw.Addr() != nil {
+		t.Error("a disabled gateway should not listen")
+	}
+	gw.SetClient(test.NewMockRPCClient(t))
+	gw.Shutdown()
+}
+
+func TestGatewayIPFS(t *testing.T) {
+	gw := testGateway(t, nil)
+	defer gw.Shutdown()
+
+	res, body := get(t, gw, "/ipfs/"+test.TestCid1, nil)
+	if res.StatusCode != http.StatusOK || body != test.TestCid1Data {
+		t.Fatalf("unexpected response: %d: %s", res.StatusCode, body)
+	}
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "text/html") {
+		t.Error("unexpected Content-Type:", res.Header.Get("Content-Type"))
+	}
+	if res.Header.Get("Cache-Control") != immutableCacheControl {
+		t.Error("unexpected Cache-Control:", res.Header.Get("Cache-Control"))
+	}
+	etag := res.Header.Get("Etag")
+	if etag != `"`+test.TestCid1+`"` {
+		t.Error("unexpected Etag:", etag)
+	}
+
+	res, body = get(t, gw, "/ipfs/"+test.TestCid1, map[string]string{"Range": "bytes=6-9"})
+	if res.StatusCode != http.StatusPartialContent || body != test.TestCid1Data[6:10] {
+		t.Errorf("unexpected range response: %d: %s", res.StatusCode, body)
+	}
+
+	res, _ = get(t, gw, "/ipfs/"+test.TestCid1, map[string]string{"If-None-Match": etag})
+	if res.StatusCode != http.StatusNotModified {
+		t.Error("expected 304 with a matching Etag:", res.StatusCode)
+	}
+
+	// A file in a directory
+	res, body = get(t, gw, "/ipfs/"+test.TestCid2+"/file", nil)
+	if res.StatusCode != http.StatusOK || body != test.TestCid1Data {
+		t.Errorf("unexpected response: %d: %s", res.StatusCode, body)
+	}
+
+	// Not pinned in the cluster
+	res, _ = get(t, gw, "/ipfs/"+test.TestSlowCid1, nil)
+	if res.StatusCode != http.StatusNotFound {
+		t.Error("unpinned content should not be served:", res.StatusCode)
+	}
+
+	// Pinned without the public option
+	res, _ = get(t, gw, "/ipfs/"+test.TestCid3, nil)
+	if res.StatusCode != http.StatusNotFound {
+		t.Error("pins which are not public should not be served:", res.StatusCode)
+	}
+
+	res, _ = get(t, gw, "/ipfs/abc", nil)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Error("expected a bad request:", res.StatusCode)
+	}
+}
+
+func TestGatewayDirectory(t *testing.T) {
+	gw := testGateway(t, nil)
+	defer gw.Shutdown()
+
+	res, _ := get(t, gw, "/ipfs/"+test.TestCid2, nil)
+	if res.StatusCode != http.StatusMovedPermanently {
+		t.Fatal("expected a redirect:", res.StatusCode)
+	}
+	if res.Header.Get("Location") != "/ipfs/"+test.TestCid2+"/" {
+		t.Error("unexpected Location:", res.Header.Get("Location"))
+	}
+
+	res, body := get(t, gw, "/ipfs/"+test.TestCid2+"/", nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status:", res.StatusCode)
+	}
+	if !strings.Contains(body, `<a href="file">file</a>`) {
+		t.Error("the listing should link the file:", body)
+	}
+}
+
+func TestGatewayIPNS(t *testing.T) {
+	gw := testGateway(t, nil)
+	defer gw.Shutdown()
+
+	res, body := get(t, gw, "/ipns/"+test.TestUID+"/file", nil)
+	if res.StatusCode != http.StatusOK || body != test.TestCid1Data {
+		t.Fatalf("unexpected response: %d: %s", res.StatusCode, body)
+	}
+	if res.Header.Get("Cache-Control") != "public, max-age=60" {
+		t.Error("unexpected Cache-Control:", res.Header.Get("Cache-Control"))
+	}
+
+	res, _ = get(t, gw, "/ipns/unknown-uid/file", nil)
+	if res.StatusCode != http.StatusNotFound {
+		t.Error("unknown UIDs should not be served:", res.StatusCode)
+	}
+}
+
+// ipnsRPC resolves the name of every UID to TestCid3, a file holding
+// TestCid1Data which is not pinned as public. The public folder of the UID
+// homes is the given one.
+type ipnsRPC struct {
+	publicFolder string
+}
+
+func (rpcapi *ipnsRPC) UidInfo(ctx context.Context, in string, out *api.UIDSecret) error {
+	*out = api.UIDSecret{UID: in, PeerID: test.TestPeerID1.Pretty()}
+	return nil
+}
+
+func (rpcapi *ipnsRPC) IPFSNameResolve(ctx context.Context, in string, out *string) error {
+	*out = "/ipfs/" + test.TestCid3
+	return nil
+}
+
+func (rpcapi *ipnsRPC) PinGet(ctx context.Context, in api.PinSerial, out *api.PinSerial) error {
+	*out = in
+	return nil
+}
+
+func (rpcapi *ipnsRPC) IPFSFilesStat(ctx context.Context, in []string, out *api.FilesStat) error {
+	*out = api.FilesStat{Hash: rpcapi.publicFolder, Type: "directory"}
+	return nil
+}
+
+func (rpcapi *ipnsRPC) IPFSStat(ctx context.Context, in string, out *api.FilesStat) error {
+	if in != "/ipfs/"+test.TestCid3 {
+		return errors.New("file does not exist")
+	}
+	*out = api.FilesStat{
+		Hash: test.TestCid3,
+		Size: uint64(len(test.TestCid1Data)),
+		Type: "file",
+	}
+	return nil
+}
+
+func (rpcapi *ipnsRPC) IPFSCat(ctx context.Context, in api.FileCat, out *[]byte) error {
+	data := []byte(test.TestCid1Data)
+	*out = data[in.Offset : in.Offset+in.Length]
+	return nil
+}
+
+func TestGatewayIPNSPrivate(t *testing.T) {
+	testIPNS := func(publicFolder string, status int) {
+		s := rpc.NewServer(nil, "mock")
+		err := s.RegisterName("Cluster", &ipnsRPC{publicFolder})
+		if err != nil {
+			t.Fatal(err)
+		}
+		gw := testGatewayWithClient(t, nil, rpc.NewClientWithServer(nil, "mock", s))
+		defer gw.Shutdown()
+
+		res, _ := get(t, gw, "/ipns/"+test.TestUID, nil)
+		if res.StatusCode != status {
+			t.Errorf("public folder %s: expected %d, got %d", publicFolder, status, res.StatusCode)
+		}
+	}
+
+	// Names pointing to content which is not public are not served.
+	testIPNS(test.TestCid2, http.StatusNotFound)
+	// Unless it is the public folder of the UID.
+	testIPNS(test.TestCid3, http.StatusOK)
+}
+
+func TestGatewayHive(t *testing.T) {
+	gw := testGateway(t, nil)
+	defer gw.Shutdown()
+
+	res, body := get(t, gw, "/hive/"+test.TestUID+"/file", nil)
+	if res.StatusCode != http.StatusOK || body != test.TestCid1Data {
+		t.Fatalf("unexpected response: %d: %s", res.StatusCode, body)
+	}
+
+	res, body = get(t, gw, "/hive/"+test.TestUID+"/", nil)
+	if res.StatusCode != http.StatusOK || !strings.Contains(body, `href="file"`) {
+		t.Errorf("unexpected listing: %d: %s", res.StatusCode, body)
+	}
+}
+
+func TestGatewayHivePrivate(t *testing.T) {
+	gw := testGateway(t, func(cfg *Config) {
+		cfg.PublicFolder = "missing"
+	})
+	defer gw.Shutdown()
+
+	// The mock homes only hold a "public" folder, so "file" is only
+	// found outside the configured public folder.
+	res, _ := get(t, gw, "/hive/"+test.TestUID+"/file", nil)
+	if res.StatusCode != http.StatusNotFound {
+		t.Error("files outside the public folder should not be served:", res.StatusCode)
+	}
+}
+
+func TestGatewayReadOnly(t *testing.T) {
+	gw := testGateway(t, nil)
+	defer gw.Shutdown()
+
+	res, err := http.Post(gatewayURL(gw)+"/ipfs/"+test.TestCid1, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusOK {
+		t.Error("POST requests should not be accepted")
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	rl := newRateLimiter(1, 2)
+	now := time.Now()
+
+	if !rl.allow("a", now) || !rl.allow("a", now) {
+		t.Fatal("the burst should be allowed")
+	}
+	if rl.allow("a", now) {
+		t.Error("the third request should be limited")
+	}
+	if !rl.allow("b", now) {
+		t.Error("clients should be limited separately")
+	}
+	if !rl.allow("a", now.Add(time.Second)) {
+		t.Error("tokens should be refilled over time")
+	}
+
+	rl.cleanup(now.Add(time.Minute))
+	if len(rl.buckets) != 0 {
+		t.Error("idle clients should be forgotten")
+	}
+}
+
+func TestGatewayRateLimit(t *testing.T) {
+	gw := testGateway(t, func(cfg *Config) {
+		cfg.RateLimit = 0.001
+		cfg.RateBurst = 1
+	})
+	defer gw.Shutdown()
+
+	res, _ := get(t, gw, "/ipfs/"+test.TestCid1, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatal("the first request should be allowed:", res.StatusCode)
+	}
+	res, _ = get(t, gw, "/ipfs/"+test.TestCid1, nil)
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Error("the second request should be rate limited:", res.StatusCode)
+	}
+}
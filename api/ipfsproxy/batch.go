@@ -0,0 +1,57 @@
+package ipfsproxy
+
+// batch.go serves files/batch, which runs several file operations in a UID
+// home as a single unit.
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+)
+
+// maxBatchBodySize limits the size of the JSON body of files/batch. Large
+// files should be added first and copied from their /ipfs/ path instead
+// of being written in the batch.
+const maxBatchBodySize = 32 << 20
+
+func (proxy *Server) filesBatchHandler(w http.ResponseWriter, r *http.Request) {
+	proxy.setHeaders(w.Header(), r)
+
+	uid := r.URL.Query().Get("uid")
+	if uid == "" {
+		ipfsErrorResponder(w, "error reading request: "+r.URL.String())
+		return
+	}
+
+	err := proxy.uidSpawn(uid)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+
+	batch := api.FilesBatch{}
+	err = json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodySize)).Decode(&batch)
+	if err != nil {
+		ipfsErrorResponder(w, "error decoding batch: "+err.Error())
+		return
+	}
+	batch.UID = uid
+
+	res := api.FilesBatchResult{}
+	err = proxy.rpcClient.Call(
+		"",
+		"Cluster",
+		"FilesBatch",
+		batch,
+		&res,
+	)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+
+	resBytes, _ := json.Marshal(res)
+	w.WriteHeader(http.StatusOK)
+	w.Write(resBytes)
+}
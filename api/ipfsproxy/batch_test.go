@@ -0,0 +1,68 @@
+package ipfsproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/test"
+)
+
+func TestProxyFilesBatch(t *testing.T) {
+	proxy, mock := testIPFSProxy(t)
+	defer mock.Close()
+	defer proxy.Shutdown()
+
+	post := func(t *testing.T, body string) *http.Response {
+		url := fmt.Sprintf("%s/files/batch?uid=uid-test", proxyURL(proxy))
+		res, err := http.Post(url, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal("should have succeeded: ", err)
+		}
+		return res
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		res := post(t, `{"Ops": [
+			{"Op": "mkdir", "Path": "/app", "Parents": true},
+			{"Op": "write", "Path": "/app/config.json", "Data": "e30="},
+			{"Op": "mv", "Path": "/app", "Dest": "/app2"},
+			{"Op": "rm", "Path": "/docs/file"}
+		]}`)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatal("unexpected status:", res.StatusCode)
+		}
+
+		var resp api.FilesBatchResult
+		err := json.NewDecoder(res.Body).Decode(&resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Root != test.TestCid3 {
+			t.Error("unexpected root:", resp.Root)
+		}
+		if len(resp.Trashed) != 1 || resp.Trashed[0].ID != test.TestTrashID {
+			t.Errorf("unexpected trashed entries: %+v", resp.Trashed)
+		}
+	})
+
+	t.Run("failed operation", func(t *testing.T) {
+		res := post(t, `{"Ops": [{"Op": "rm", "Path": "/missing"}]}`)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusInternalServerError {
+			t.Error("expected an error:", res.StatusCode)
+		}
+	})
+
+	t.Run("bad body", func(t *testing.T) {
+		res := post(t, `{"Ops": `)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusInternalServerError {
+			t.Error("expected an error:", res.StatusCode)
+		}
+	})
+}
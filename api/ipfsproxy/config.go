@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"path/filepath"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
@@ -28,6 +29,9 @@ const (
 	DefaultIdleTimeout        = 60 * time.Second
 	DefaultExtractHeadersPath = "/api/v0/version"
 	DefaultExtractHeadersTTL  = 5 * time.Minute
+	DefaultUploadsFolder      = "uploads"
+	DefaultUploadSessionTTL   = 24 * time.Hour
+	DefaultMaxUploadSize      = 4 << 30 // 4 GiB
 )
 
 // Config allows to customize behaviour of IPFSProxy.
@@ -74,6 +78,23 @@ type Config struct {
 	// Establishes how long we should remember extracted headers before we
 	// refresh them with a new request. 0 means always.
 	ExtractHeadersTTL time.Duration
+
+	// Folder, relative to the configuration folder, where the chunks
+	// of resumable uploads are stored until they are finished.
+	UploadsFolder string
+
+	// Time after which an upload session which has not received any
+	// chunk is discarded.
+	UploadSessionTTL time.Duration
+
+	// Maximum size, in bytes, of the files uploaded with resumable
+	// upload sessions. 0 means no limit.
+	MaxUploadSize uint64
+
+	// Maximum cumulative size, in bytes, of a UID home after adding
+	// content to it with /file/add. Adds which would exceed it are
+	// undone. 0 means no limit.
+	HomeQuota uint64
 }
 
 type jsonConfig struct {
@@ -90,6 +111,11 @@ type jsonConfig struct {
 	ExtractHeadersPath  string   `json:"extract_headers_path,omitempty"`
 	ExtractHeadersTTL   string   `json:"extract_headers_ttl,omitempty"`
 
+	UploadsFolder    string  `json:"uploads_folder,omitempty"`
+	UploadSessionTTL string  `json:"upload_session_ttl,omitempty"`
+	MaxUploadSize    *uint64 `json:"max_upload_size,omitempty"`
+	HomeQuota        uint64  `json:"home_quota,omitempty"`
+
 	// Below fields are only here to maintain backward compatibility
 	// They will be removed in future
 	ProxyListenMultiaddress string `json:"proxy_listen_multiaddress,omitempty"`
@@ -147,6 +173,10 @@ func (cfg *Config) Default() error {
 	cfg.ExtractHeadersExtra = nil
 	cfg.ExtractHeadersPath = DefaultExtractHeadersPath
 	cfg.ExtractHeadersTTL = DefaultExtractHeadersTTL
+	cfg.UploadsFolder = DefaultUploadsFolder
+	cfg.UploadSessionTTL = DefaultUploadSessionTTL
+	cfg.MaxUploadSize = DefaultMaxUploadSize
+	cfg.HomeQuota = 0
 
 	return nil
 }
@@ -186,6 +216,14 @@ func (cfg *Config) Validate() error {
 		err = errors.New("ipfsproxy.extract_headers_ttl is invalid")
 	}
 
+	if cfg.UploadsFolder == "" {
+		err = errors.New("ipfsproxy.uploads_folder should not be empty")
+	}
+
+	if cfg.UploadSessionTTL <= 0 {
+		err = errors.New("ipfsproxy.upload_session_ttl is invalid")
+	}
+
 	return err
 }
 
@@ -233,6 +271,7 @@ func (cfg *Config) LoadJSON(raw []byte) error {
 		&config.DurationOpt{Duration: jcfg.WriteTimeout, Dst: &cfg.WriteTimeout, Name: "write_timeout"},
 		&config.DurationOpt{Duration: jcfg.IdleTimeout, Dst: &cfg.IdleTimeout, Name: "idle_timeout"},
 		&config.DurationOpt{Duration: jcfg.ExtractHeadersTTL, Dst: &cfg.ExtractHeadersTTL, Name: "extract_header_ttl"},
+		&config.DurationOpt{Duration: jcfg.UploadSessionTTL, Dst: &cfg.UploadSessionTTL, Name: "upload_session_ttl"},
 	)
 	if err != nil {
 		return err
@@ -242,6 +281,11 @@ func (cfg *Config) LoadJSON(raw []byte) error {
 		cfg.ExtractHeadersExtra = extra
 	}
 	config.SetIfNotDefault(jcfg.ExtractHeadersPath, &cfg.ExtractHeadersPath)
+	config.SetIfNotDefault(jcfg.UploadsFolder, &cfg.UploadsFolder)
+	if jcfg.MaxUploadSize != nil { // 0 removes the limit
+		cfg.MaxUploadSize = *jcfg.MaxUploadSize
+	}
+	cfg.HomeQuota = jcfg.HomeQuota
 
 	return cfg.Validate()
 }
@@ -268,7 +312,21 @@ func (cfg *Config) ToJSON() (raw []byte, err error) {
 	jcfg.ExtractHeadersExtra = cfg.ExtractHeadersExtra
 	jcfg.ExtractHeadersPath = cfg.ExtractHeadersPath
 	jcfg.ExtractHeadersTTL = cfg.ExtractHeadersTTL.String()
+	jcfg.UploadsFolder = cfg.UploadsFolder
+	jcfg.UploadSessionTTL = cfg.UploadSessionTTL.String()
+	jcfg.MaxUploadSize = &cfg.MaxUploadSize
+	jcfg.HomeQuota = cfg.HomeQuota
 
 	raw, err = config.DefaultJSONMarshal(jcfg)
 	return
 }
+
+// GetUploadsPath returns the full path of the folder where resumable
+// uploads are stored, obtained by concatenating UploadsFolder with the
+// BaseDir of the configuration when it is relative.
+func (cfg *Config) GetUploadsPath() string {
+	if filepath.IsAbs(cfg.UploadsFolder) {
+		return cfg.UploadsFolder
+	}
+	return filepath.Join(cfg.BaseDir, cfg.UploadsFolder)
+}
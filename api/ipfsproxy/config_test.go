@@ -124,4 +124,10 @@ func TestDefault(t *testing.T) {
 	if cfg.Validate() == nil {
 		t.Fatal("expected error validating")
 	}
+
+	cfg.Default()
+	cfg.UploadSessionTTL = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
 }
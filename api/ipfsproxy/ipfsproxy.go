@@ -6,20 +6,21 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"mime/multipart"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
-	"os"
+	"path"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/elastos/Elastos.NET.Hive.Cluster/adder/adderutils"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/adder/sharding"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/observations"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/rpcutil"
 
 	mux "github.com/gorilla/mux"
@@ -30,7 +31,6 @@ import (
 	madns "github.com/multiformats/go-multiaddr-dns"
 	manet "github.com/multiformats/go-multiaddr-net"
 	uuid "github.com/satori/go.uuid"
-	tar "github.com/whyrusleeping/tar-utils"
 )
 
 // DNSTimeout is used when resolving DNS multiaddresses in this module
@@ -60,6 +60,8 @@ type Server struct {
 
 	ipfsHeadersStore sync.Map
 
+	uploads *uploadStore
+
 	shutdownLock sync.Mutex
 	shutdown     bool
 	wg           sync.WaitGroup
@@ -172,6 +174,7 @@ func New(cfg *Config) (*Server, error) {
 		listener:         l,
 		server:           s,
 		ipfsRoundTripper: reverseProxy.Transport,
+		uploads:          newUploadStore(cfg.GetUploadsPath(), cfg.UploadSessionTTL),
 	}
 
 	// Ideally, we should only intercept POST requests, but
@@ -183,6 +186,7 @@ func New(cfg *Config) (*Server, error) {
 		Methods(http.MethodPost, http.MethodGet, http.MethodPut).
 		PathPrefix("/api/v0").
 		Subrouter()
+	hijackSubrouter.Use(observeRoute)
 
 	// Add hijacked routes
 	hijackSubrouter.
@@ -237,8 +241,28 @@ func New(cfg *Config) (*Server, error) {
 
 	hijackSubrouter.
 		Path("/file/add").
-		HandlerFunc(proxy.addHandler).
+		HandlerFunc(proxy.fileAddHandler).
 		Name("FileAdd")
+	hijackSubrouter.
+		Path("/file/upload/new").
+		HandlerFunc(proxy.uploadNewHandler).
+		Name("FileUploadNew")
+	hijackSubrouter.
+		Path("/file/upload/chunk").
+		HandlerFunc(proxy.uploadChunkHandler).
+		Name("FileUploadChunk")
+	hijackSubrouter.
+		Path("/file/upload/status").
+		HandlerFunc(proxy.uploadStatusHandler).
+		Name("FileUploadStatus")
+	hijackSubrouter.
+		Path("/file/upload/finish").
+		HandlerFunc(proxy.uploadFinishHandler).
+		Name("FileUploadFinish")
+	hijackSubrouter.
+		Path("/file/upload/cancel").
+		HandlerFunc(proxy.uploadCancelHandler).
+		Name("FileUploadCancel")
 	hijackSubrouter.
 		Path("/file/get").
 		HandlerFunc(proxy.fileGetHandler).
@@ -253,6 +277,10 @@ func New(cfg *Config) (*Server, error) {
 	// 	HandlerFunc(proxy.fileLsHandler).
 	// 	Name("FileLs")
 
+	hijackSubrouter.
+		Path("/files/batch").
+		HandlerFunc(proxy.filesBatchHandler).
+		Name("FilesBatch")
 	hijackSubrouter.
 		Path("/files/cp").
 		HandlerFunc(proxy.filesCpHandler).
@@ -281,6 +309,26 @@ func New(cfg *Config) (*Server, error) {
 		Path("/files/rm").
 		HandlerFunc(proxy.filesRmHandler).
 		Name("FilesRm")
+	hijackSubrouter.
+		Path("/files/trash/ls").
+		HandlerFunc(proxy.trashLsHandler).
+		Name("FilesTrashLs")
+	hijackSubrouter.
+		Path("/files/trash/restore").
+		HandlerFunc(proxy.trashRestoreHandler).
+		Name("FilesTrashRestore")
+	hijackSubrouter.
+		Path("/files/trash/empty").
+		HandlerFunc(proxy.trashEmptyHandler).
+		Name("FilesTrashEmpty")
+	hijackSubrouter.
+		Path("/files/search").
+		HandlerFunc(proxy.filesSearchHandler).
+		Name("FilesSearch")
+	hijackSubrouter.
+		Path("/files/search/rebuild").
+		HandlerFunc(proxy.filesSearchRebuildHandler).
+		Name("FilesSearchRebuild")
 	hijackSubrouter.
 		Path("/files/stat").
 		HandlerFunc(proxy.filesStatHandler).
@@ -302,6 +350,21 @@ func New(cfg *Config) (*Server, error) {
 	return proxy, nil
 }
 
+// observeRoute is a middleware which counts and times the requests
+// to the hijacked routes, by route name.
+func observeRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := "unknown"
+		if route := mux.CurrentRoute(r); route != nil && route.GetName() != "" {
+			name = route.GetName()
+		}
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		observations.ProxyRequests.Inc(name)
+		observations.ProxyRequestLatency.Observe(time.Since(start).Seconds(), name)
+	})
+}
+
 // SetClient makes the component ready to perform RPC
 // requests.
 func (proxy *Server) SetClient(c *rpc.Client) {
@@ -355,6 +418,9 @@ func (proxy *Server) run() {
 			logger.Error(err)
 		}
 	}()
+
+	proxy.wg.Add(1)
+	go proxy.cleanupUploads()
 }
 
 // ipfsErrorResponder writes an http error response just like IPFS would.
@@ -459,6 +525,35 @@ func (proxy *Server) pinLsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(resBytes)
 }
 
+// ipfsAddOutput transforms the output of the cluster adder into the
+// format used by the IPFS add endpoint.
+func ipfsAddOutput(in *api.AddedOutput) interface{} {
+	r := &ipfsAddResp{
+		Name:  in.Name,
+		Hash:  in.Cid,
+		Bytes: int64(in.Bytes),
+	}
+	if in.Size != 0 {
+		r.Size = strconv.FormatUint(in.Size, 10)
+	}
+	return r
+}
+
+// ipfsAddOutputFor returns the output transform for an add with the given
+// params. The outputs for the shards and the Cluster DAG of a sharded add
+// are left out, so that IPFS clients see the content root last, as usual.
+func ipfsAddOutputFor(params *api.AddParams) func(*api.AddedOutput) interface{} {
+	if !params.Shard {
+		return ipfsAddOutput
+	}
+	return func(in *api.AddedOutput) interface{} {
+		if sharding.IsMetadataOutput(in) {
+			return nil
+		}
+		return ipfsAddOutput(in)
+	}
+}
+
 func (proxy *Server) addHandler(w http.ResponseWriter, r *http.Request) {
 	proxy.setHeaders(w.Header(), r)
 
@@ -489,25 +584,13 @@ func (proxy *Server) addHandler(w http.ResponseWriter, r *http.Request) {
 
 	logger.Warningf("Proxy/add does not support all IPFS params. Current options: %+v", params)
 
-	outputTransform := func(in *api.AddedOutput) interface{} {
-		r := &ipfsAddResp{
-			Name:  in.Name,
-			Hash:  in.Cid,
-			Bytes: int64(in.Bytes),
-		}
-		if in.Size != 0 {
-			r.Size = strconv.FormatUint(in.Size, 10)
-		}
-		return r
-	}
-
 	root, err := adderutils.AddMultipartHTTPHandler(
 		proxy.ctx,
 		proxy.rpcClient,
 		params,
 		reader,
 		w,
-		outputTransform,
+		ipfsAddOutputFor(params),
 	)
 
 	// any errors have been sent as Trailer
@@ -535,6 +618,186 @@ func (proxy *Server) addHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// fileAddHandler adds content to the cluster like addHandler. When a uid
+// is given, the resulting DAG is also linked into the home of that UID,
+// at the given path, before the response is finished. The add fails when
+// the home would exceed the HomeQuota.
+func (proxy *Server) fileAddHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	uid := q.Get("uid")
+	if uid == "" {
+		proxy.addHandler(w, r)
+		return
+	}
+
+	proxy.setHeaders(w.Header(), r)
+
+	dest := q.Get("path")
+	if dest == "" || dest == "/" {
+		ipfsErrorResponder(w, "a destination path is needed to add to a home")
+		return
+	}
+	if q.Get("only-hash") == "true" || q.Get("pin") == "false" {
+		ipfsErrorResponder(w, "only-hash and pin=false are not supported when adding to a home")
+		return
+	}
+	parents := q.Get("parents") == "true"
+
+	err := proxy.uidSpawn(uid)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		ipfsErrorResponder(w, "error reading request: "+err.Error())
+		return
+	}
+
+	params, err := api.AddParamsFromQuery(q)
+	if err != nil {
+		ipfsErrorResponder(w, "error parsing options:"+err.Error())
+		return
+	}
+	if q.Get("trickle") == "true" {
+		params.Layout = "trickle"
+	}
+
+	// The root may already be pinned, i.e. by another user adding the
+	// same content. Such pins must be kept when linking fails.
+	var pinned bool
+	checkPinned := func(root cid.Cid) error {
+		pinned = proxy.isPinned(root)
+		return nil
+	}
+	link := func(root cid.Cid) error {
+		return proxy.addToHome(uid, dest, root, parents, !pinned)
+	}
+
+	adderutils.AddMultipartHTTPHandlerThen(
+		proxy.ctx,
+		proxy.rpcClient,
+		params,
+		reader,
+		w,
+		ipfsAddOutputFor(params),
+		checkPinned,
+		link,
+	)
+	// any errors have been sent as Trailer
+}
+
+// addToHome links the DAG of an added root into the home of a UID at the
+// given path, creating its parent folders when asked to. The DAG is
+// already pinned by the adder and it is linked with files/cp, like any
+// other write to the home. When it cannot be linked, or the home would
+// exceed its quota, the home is left as it was and the root is unpinned
+// if unpin is set, that is, when the add created its pin.
+func (proxy *Server) addToHome(uid, dest string, root cid.Cid, parents, unpin bool) error {
+	err := proxy.linkToHome(uid, dest, root, parents)
+	if err == nil {
+		err = proxy.checkHomeQuota(uid)
+		if err != nil {
+			proxy.unlinkFromHome(uid, dest)
+		}
+	}
+	if err != nil && unpin {
+		unpinErr := proxy.rpcClient.CallContext(
+			proxy.ctx,
+			"",
+			"Cluster",
+			"Unpin",
+			api.PinCid(root).ToSerial(),
+			&struct{}{},
+		)
+		if unpinErr != nil {
+			logger.Errorf("error unpinning %s after failing to add it to %s: %s", root, uid, unpinErr)
+		}
+	}
+	return err
+}
+
+// isPinned returns true when the given root is pinned in the cluster.
+func (proxy *Server) isPinned(root cid.Cid) bool {
+	var pinS api.PinSerial
+	err := proxy.rpcClient.CallContext(
+		proxy.ctx,
+		"",
+		"Cluster",
+		"PinGet",
+		api.PinCid(root).ToSerial(),
+		&pinS,
+	)
+	return err == nil
+}
+
+func (proxy *Server) linkToHome(uid, dest string, root cid.Cid, parents bool) error {
+	if parents {
+		err := proxy.rpcClient.CallContext(
+			proxy.ctx,
+			"",
+			"Cluster",
+			"IPFSFilesMkdir",
+			[]string{uid, path.Dir(path.Clean("/" + dest)), "true"},
+			&struct{}{},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return proxy.rpcClient.CallContext(
+		proxy.ctx,
+		"",
+		"Cluster",
+		"IPFSFilesCp",
+		[]string{uid, "/ipfs/" + root.String(), dest},
+		&struct{}{},
+	)
+}
+
+func (proxy *Server) unlinkFromHome(uid, dest string) {
+	err := proxy.rpcClient.CallContext(
+		proxy.ctx,
+		"",
+		"Cluster",
+		"IPFSFilesRm",
+		[]string{uid, dest, "true"},
+		&struct{}{},
+	)
+	if err != nil {
+		logger.Errorf("error removing %s from %s: %s", dest, uid, err)
+	}
+}
+
+// checkHomeQuota returns an error when the home of a UID is larger than
+// the HomeQuota.
+func (proxy *Server) checkHomeQuota(uid string) error {
+	if proxy.config.HomeQuota == 0 {
+		return nil
+	}
+
+	var stat api.FilesStat
+	err := proxy.rpcClient.CallContext(
+		proxy.ctx,
+		"",
+		"Cluster",
+		"IPFSFilesStat",
+		[]string{uid, "", "", "", "", ""},
+		&stat,
+	)
+	if err != nil {
+		return err
+	}
+	if stat.CumulativeSize > proxy.config.HomeQuota {
+		return fmt.Errorf(
+			"the home of %s would exceed its quota (%d > %d bytes)",
+			uid, stat.CumulativeSize, proxy.config.HomeQuota,
+		)
+	}
+	return nil
+}
+
 func (proxy *Server) repoStatHandler(w http.ResponseWriter, r *http.Request) {
 	proxy.setHeaders(w.Header(), r)
 
@@ -793,11 +1056,18 @@ func (proxy *Server) uidLoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The former home is kept in the trash of the new one, unless
+	// permanent=true is given.
+	permanent := q.Get("permanent")
+	if permanent == "" {
+		permanent = "false"
+	}
+
 	err = proxy.rpcClient.Call(
 		"",
 		"Cluster",
 		"UidLogin",
-		[]string{uid, hash},
+		[]string{uid, hash, permanent},
 		&struct{}{},
 	)
 	if err != nil {
@@ -812,101 +1082,35 @@ func (proxy *Server) uidLoginHandler(w http.ResponseWriter, r *http.Request) {
 func (proxy *Server) fileGetHandler(w http.ResponseWriter, r *http.Request) {
 	proxy.setHeaders(w.Header(), r)
 
-	var FileGet []byte
-
-	q := r.URL.Query()
-
-	arg := q.Get("arg")
+	arg := r.URL.Query().Get("arg")
 	if arg == "" {
 		ipfsErrorResponder(w, "error reading request: "+r.URL.String())
 		return
 	}
 
-	output := q.Get("output")
-	archive := q.Get("archive")
-	compress := q.Get("compress")
-	compressionLevel := q.Get("compression-level")
-
-	err := proxy.rpcClient.Call(
-		"",
-		"Cluster",
-		"IPFSFileGet",
-		[]string{arg, output, archive, compress, compressionLevel},
-		&FileGet,
-	)
-	if err != nil {
-		ipfsErrorResponder(w, err.Error())
+	if c, ok := proxy.shardedRoot(arg); ok {
+		proxy.getSharded(w, r, c)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write(FileGet)
-	return
+	proxy.getStream(w, r, arg)
 }
 
 func (proxy *Server) fileCatHandler(w http.ResponseWriter, r *http.Request) {
 	proxy.setHeaders(w.Header(), r)
 
-	var FileGet []byte
-
-	q := r.URL.Query()
-
-	arg := q.Get("arg")
+	arg := r.URL.Query().Get("arg")
 	if arg == "" {
 		ipfsErrorResponder(w, "error reading request: "+r.URL.String())
 		return
 	}
 
-	output := q.Get("output")
-	archive := q.Get("archive")
-	compress := q.Get("compress")
-	compressionLevel := q.Get("compression-level")
-
-	err := proxy.rpcClient.Call(
-		"",
-		"Cluster",
-		"IPFSFileGet",
-		[]string{arg, output, archive, compress, compressionLevel},
-		&FileGet,
-	)
-	if err != nil {
-		ipfsErrorResponder(w, err.Error())
-		return
-	}
-
-	// create io.Reader
-	rspbuf := bytes.NewReader(FileGet)
-
-	// create path
-	fpath, err := ioutil.TempDir("", "ipfsget")
-	if err != nil {
-		ipfsErrorResponder(w, err.Error())
+	if c, ok := proxy.shardedRoot(arg); ok {
+		proxy.catSharded(w, r, c)
 		return
 	}
-	defer os.RemoveAll(fpath)
 
-	// extract
-	extractor := &tar.Extractor{Path: fpath}
-	extractor.Extract(rspbuf)
-
-	// read files from the path
-	files, err := ioutil.ReadDir(fpath)
-	if err != nil {
-		ipfsErrorResponder(w, err.Error())
-		return
-	}
-
-	w.WriteHeader(http.StatusOK)
-	for _, file := range files {
-		buf, err := ioutil.ReadFile(fpath + "/" + file.Name())
-		if err != nil {
-			ipfsErrorResponder(w, err.Error())
-			return
-		}
-		w.Write(buf)
-	}
-
-	return
+	proxy.catStream(w, r, arg)
 }
 
 func (proxy *Server) filesCpHandler(w http.ResponseWriter, r *http.Request) {
@@ -1197,6 +1401,31 @@ func (proxy *Server) filesRmHandler(w http.ResponseWriter, r *http.Request) {
 		recursive = "false"
 	}
 
+	// Deleted entries go to the trash of the home unless
+	// permanent is set. Like IPFS, the response is empty, unless
+	// trash-entry is set to get the new trash entry.
+	if q.Get("permanent") != "true" {
+		entry := api.TrashEntry{}
+		err = proxy.rpcClient.Call(
+			"",
+			"Cluster",
+			"Trash",
+			[]string{uid, path, recursive},
+			&entry,
+		)
+		if err != nil {
+			ipfsErrorResponder(w, err.Error())
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if q.Get("trash-entry") == "true" {
+			resBytes, _ := json.Marshal(entry)
+			w.Write(resBytes)
+		}
+		return
+	}
+
 	err = proxy.rpcClient.Call(
 		"",
 		"Cluster",
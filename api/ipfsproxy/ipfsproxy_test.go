@@ -1,7 +1,9 @@
 package ipfsproxy
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -14,6 +16,7 @@ import (
 	"github.com/elastos/Elastos.NET.Hive.Cluster/test"
 
 	logging "github.com/ipfs/go-log"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
 	ma "github.com/multiformats/go-multiaddr"
 )
 
@@ -422,6 +425,14 @@ func TestProxyAdd(t *testing.T) {
 			query:       "trickle=true",
 			expectedCid: test.ShardingDirTrickleRootCID,
 		},
+		testcase{
+			query:       "shard=true",
+			expectedCid: test.ShardingDirBalancedRootCID,
+		},
+		testcase{
+			query:       "shard=true&shard-size=1048576&name=test",
+			expectedCid: test.ShardingDirBalancedRootCID,
+		},
 	}
 
 	reqs := make([]*http.Request, len(testcases), len(testcases))
@@ -467,6 +478,148 @@ func TestProxyAdd(t *testing.T) {
 	}
 }
 
+func TestProxyFileAddToHome(t *testing.T) {
+	proxy, mock := testIPFSProxy(t)
+	defer mock.Close()
+	defer proxy.Shutdown()
+
+	sth := test.NewShardingTestHelper()
+	defer sth.Clean(t)
+
+	post := func(t *testing.T, query string) *http.Response {
+		mr, closer := sth.GetTreeMultiReader(t)
+		defer closer.Close()
+		url := fmt.Sprintf("%s/file/add?%s", proxyURL(proxy), query)
+		req, _ := http.NewRequest("POST", url, mr)
+		req.Header.Set("Content-Type", "multipart/form-data; boundary="+mr.Boundary())
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal("should have succeeded: ", err)
+		}
+		return res
+	}
+
+	t.Run("linked", func(t *testing.T) {
+		res := post(t, "uid=uid-test&path=/docs/tree&parents=true")
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("Bad response status: got = %d, want = %d", res.StatusCode, http.StatusOK)
+		}
+
+		var resp ipfsAddResp
+		dec := json.NewDecoder(res.Body)
+		for dec.More() {
+			err := dec.Decode(&resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if resp.Hash != test.ShardingDirBalancedRootCID {
+			t.Error("expected CID does not match")
+		}
+		if e := res.Trailer.Get("X-Stream-Error"); e != "" {
+			t.Error("unexpected error:", e)
+		}
+	})
+
+	t.Run("link error", func(t *testing.T) {
+		res := post(t, "uid=uid-test&path=/missing/tree")
+		defer res.Body.Close()
+		ioutil.ReadAll(res.Body)
+		if res.Trailer.Get("X-Stream-Error") == "" {
+			t.Error("expected an error linking into the home")
+		}
+	})
+
+	t.Run("over quota", func(t *testing.T) {
+		proxy.config.HomeQuota = 100
+		defer func() { proxy.config.HomeQuota = 0 }()
+		res := post(t, "uid=uid-test&path=/docs/tree")
+		defer res.Body.Close()
+		ioutil.ReadAll(res.Body)
+		if e := res.Trailer.Get("X-Stream-Error"); !strings.Contains(e, "quota") {
+			t.Error("expected a quota error:", e)
+		}
+	})
+
+	t.Run("no path", func(t *testing.T) {
+		res := post(t, "uid=uid-test")
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusInternalServerError {
+			t.Errorf("wrong status code: got = %d, want = %d", res.StatusCode, http.StatusInternalServerError)
+		}
+	})
+}
+
+// homeRPC is a minimal Cluster RPC service to check which roots are
+// unpinned when they cannot be added to a home. Homes are always too
+// large for their quota.
+type homeRPC struct {
+	pinned   map[string]bool
+	unpinned []string
+}
+
+func (h *homeRPC) PinGet(ctx context.Context, in api.PinSerial, out *api.PinSerial) error {
+	if !h.pinned[in.Cid] {
+		return errors.New("not found")
+	}
+	*out = in
+	return nil
+}
+
+func (h *homeRPC) Unpin(ctx context.Context, in api.PinSerial, out *struct{}) error {
+	h.unpinned = append(h.unpinned, in.Cid)
+	return nil
+}
+
+func (h *homeRPC) IPFSFilesCp(ctx context.Context, in []string, out *struct{}) error {
+	return nil
+}
+
+func (h *homeRPC) IPFSFilesRm(ctx context.Context, in []string, out *struct{}) error {
+	return nil
+}
+
+func (h *homeRPC) IPFSFilesStat(ctx context.Context, in []string, out *api.FilesStat) error {
+	out.CumulativeSize = 1000
+	return nil
+}
+
+func TestProxyAddToHomeKeepsPins(t *testing.T) {
+	proxy, mock := testIPFSProxy(t)
+	defer mock.Close()
+	defer proxy.Shutdown()
+
+	svc := &homeRPC{pinned: map[string]bool{test.TestCid1: true}}
+	s := rpc.NewServer(nil, "mock")
+	err := s.RegisterName("Cluster", svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy.rpcClient = rpc.NewClientWithServer(nil, "mock", s)
+	proxy.config.HomeQuota = 100
+
+	// Already pinned, i.e. by another user
+	c := test.MustDecodeCid(test.TestCid1)
+	err = proxy.addToHome("uid-test", "/docs/file", c, false, !proxy.isPinned(c))
+	if err == nil || !strings.Contains(err.Error(), "quota") {
+		t.Fatal("expected a quota error:", err)
+	}
+	if len(svc.unpinned) != 0 {
+		t.Error("pins which existed before the add should be kept:", svc.unpinned)
+	}
+
+	// Pinned by the add
+	c = test.MustDecodeCid(test.TestCid2)
+	err = proxy.addToHome("uid-test", "/docs/file", c, false, !proxy.isPinned(c))
+	if err == nil {
+		t.Fatal("expected a quota error")
+	}
+	if len(svc.unpinned) != 1 || svc.unpinned[0] != test.TestCid2 {
+		t.Error("the pin created by the add should be removed:", svc.unpinned)
+	}
+}
+
 func TestProxyAddError(t *testing.T) {
 	proxy, mock := testIPFSProxy(t)
 	defer mock.Close()
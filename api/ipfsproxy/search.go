@@ -0,0 +1,142 @@
+package ipfsproxy
+
+// search.go serves files/search, which queries the metadata index of the
+// home of a UID.
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+)
+
+type filesSearchResp struct {
+	Entries []api.FileMeta
+}
+
+type filesSearchRebuildResp struct {
+	Entries int
+}
+
+// parseSearchQuery reads a search query from the request parameters:
+// path, name (glob), type, min-size, max-size, after and before (RFC3339)
+// and limit.
+func parseSearchQuery(q url.Values) (api.SearchQuery, error) {
+	query := api.SearchQuery{
+		UID:  q.Get("uid"),
+		Path: q.Get("path"),
+		Name: q.Get("name"),
+		Type: q.Get("type"),
+	}
+
+	switch query.Type {
+	case "", "file", "directory":
+	default:
+		return query, fmt.Errorf("type is invalid: %s", query.Type)
+	}
+
+	minSize, err := queryInt64(q, "min-size", 0)
+	if err != nil {
+		return query, err
+	}
+	maxSize, err := queryInt64(q, "max-size", 0)
+	if err != nil {
+		return query, err
+	}
+	query.MinSize = uint64(minSize)
+	query.MaxSize = uint64(maxSize)
+
+	for k, dst := range map[string]*time.Time{"after": &query.After, "before": &query.Before} {
+		v := q.Get(k)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return query, fmt.Errorf("%s is invalid: %s", k, v)
+		}
+		*dst = t
+	}
+
+	if v := q.Get("limit"); v != "" {
+		query.Limit, err = strconv.Atoi(v)
+		if err != nil || query.Limit < 0 {
+			return query, fmt.Errorf("limit is invalid: %s", v)
+		}
+	}
+	return query, nil
+}
+
+func (proxy *Server) filesSearchHandler(w http.ResponseWriter, r *http.Request) {
+	proxy.setHeaders(w.Header(), r)
+
+	query, err := parseSearchQuery(r.URL.Query())
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+	if query.UID == "" {
+		ipfsErrorResponder(w, "error reading request: "+r.URL.String())
+		return
+	}
+
+	err = proxy.uidSpawn(query.UID)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+
+	resp := filesSearchResp{}
+	err = proxy.rpcClient.Call(
+		"",
+		"Cluster",
+		"FilesSearch",
+		query,
+		&resp.Entries,
+	)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+
+	resBytes, _ := json.Marshal(resp)
+	w.WriteHeader(http.StatusOK)
+	w.Write(resBytes)
+}
+
+func (proxy *Server) filesSearchRebuildHandler(w http.ResponseWriter, r *http.Request) {
+	proxy.setHeaders(w.Header(), r)
+
+	uid := r.URL.Query().Get("uid")
+	if uid == "" {
+		ipfsErrorResponder(w, "error reading request: "+r.URL.String())
+		return
+	}
+
+	err := proxy.uidSpawn(uid)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+
+	resp := filesSearchRebuildResp{}
+	err = proxy.rpcClient.Call(
+		"",
+		"Cluster",
+		"FilesSearchRebuild",
+		uid,
+		&resp.Entries,
+	)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+
+	resBytes, _ := json.Marshal(resp)
+	w.WriteHeader(http.StatusOK)
+	w.Write(resBytes)
+}
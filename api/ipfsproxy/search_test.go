@@ -0,0 +1,80 @@
+package ipfsproxy
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/test"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	q, err := parseSearchQuery(url.Values{
+		"uid":      {"uid-test"},
+		"name":     {"*.jpg"},
+		"type":     {"file"},
+		"min-size": {"10"},
+		"max-size": {"20"},
+		"after":    {"2018-10-20T00:00:00Z"},
+		"limit":    {"5"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.UID != "uid-test" || q.Name != "*.jpg" || q.Type != "file" ||
+		q.MinSize != 10 || q.MaxSize != 20 || q.Limit != 5 {
+		t.Errorf("unexpected query: %+v", q)
+	}
+	if !q.After.Equal(time.Date(2018, 10, 20, 0, 0, 0, 0, time.UTC)) || !q.Before.IsZero() {
+		t.Errorf("unexpected time range: %s - %s", q.After, q.Before)
+	}
+
+	for _, bad := range []url.Values{
+		{"type": {"link"}},
+		{"min-size": {"-1"}},
+		{"before": {"yesterday"}},
+		{"limit": {"many"}},
+	} {
+		if _, err := parseSearchQuery(bad); err == nil {
+			t.Errorf("%v should not be valid", bad)
+		}
+	}
+}
+
+func TestProxyFilesSearch(t *testing.T) {
+	proxy, mock := testIPFSProxy(t)
+	defer mock.Close()
+	defer proxy.Shutdown()
+
+	var resp filesSearchResp
+	st := postProxy(t, proxy, "files/search?uid=uid-test&name=fi*&type=file", &resp)
+	if st != http.StatusOK {
+		t.Fatal("unexpected status:", st)
+	}
+	if len(resp.Entries) != 1 || resp.Entries[0].Hash != test.TestCid1 {
+		t.Errorf("unexpected entries: %+v", resp.Entries)
+	}
+
+	resp = filesSearchResp{}
+	st = postProxy(t, proxy, "files/search?uid=uid-test&type=directory", &resp)
+	if st != http.StatusOK || len(resp.Entries) != 0 {
+		t.Errorf("unexpected response: %d: %+v", st, resp.Entries)
+	}
+
+	st = postProxy(t, proxy, "files/search?uid=uid-test&limit=-1", nil)
+	if st != http.StatusInternalServerError {
+		t.Error("expected an error with a bad query:", st)
+	}
+
+	st = postProxy(t, proxy, "files/search?name=*", nil)
+	if st != http.StatusInternalServerError {
+		t.Error("expected an error without uid:", st)
+	}
+
+	var rebuilt filesSearchRebuildResp
+	st = postProxy(t, proxy, "files/search/rebuild?uid=uid-test", &rebuilt)
+	if st != http.StatusOK || rebuilt.Entries != 2 {
+		t.Errorf("unexpected response: %d: %+v", st, rebuilt)
+	}
+}
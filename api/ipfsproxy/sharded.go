@@ -0,0 +1,111 @@
+package ipfsproxy
+
+// sharded.go serves files which were added with sharding. Their blocks
+// are spread across the IPFS daemons of several peers, so they cannot be
+// read by handing the request off to a single daemon. They are read from
+// the peers allocated to each shard, which need not include this one.
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/adder/sharding"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// shardedRoot returns the CID in the given /ipfs/ path or CID argument
+// when it is the root of sharded content (a meta-pin).
+func (proxy *Server) shardedRoot(arg string) (cid.Cid, bool) {
+	c, err := cid.Decode(strings.TrimPrefix(arg, "/ipfs/"))
+	if err != nil {
+		return cid.Undef, false
+	}
+
+	var pinS api.PinSerial
+	err = proxy.rpcClient.CallContext(
+		proxy.ctx,
+		"",
+		"Cluster",
+		"PinGet",
+		api.PinCid(c).ToSerial(),
+		&pinS,
+	)
+	if err != nil {
+		return cid.Undef, false
+	}
+	return c, pinS.ToPin().Type == api.MetaType
+}
+
+// catSharded streams a sharded file, supporting Range requests.
+func (proxy *Server) catSharded(w http.ResponseWriter, r *http.Request, c cid.Cid) {
+	reader, err := sharding.NewReader(r.Context(), proxy.rpcClient, c)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+	defer reader.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", time.Time{}, reader)
+}
+
+// getSharded sends a sharded file as a tar archive, like "ipfs get"
+// does, optionally compressed with gzip.
+func (proxy *Server) getSharded(w http.ResponseWriter, r *http.Request, c cid.Cid) {
+	q := r.URL.Query()
+
+	level, err := compressionLevel(q)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+
+	reader, err := sharding.NewReader(r.Context(), proxy.rpcClient, c)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+	defer reader.Close()
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.WriteHeader(http.StatusOK)
+
+	var out io.Writer = w
+	if q.Get("compress") == "true" {
+		gzw, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			logger.Error(err)
+			return
+		}
+		defer gzw.Close()
+		out = gzw
+	}
+
+	name := q.Get("output")
+	if name == "" {
+		name = c.String()
+	}
+
+	tw := tar.NewWriter(out)
+	defer tw.Close()
+	err = tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(reader.Size()),
+		ModTime:  time.Now(),
+		Typeflag: tar.TypeReg,
+	})
+	if err == nil {
+		_, err = io.Copy(tw, reader)
+	}
+	if err != nil {
+		// The status has been sent already.
+		logger.Errorf("error sending sharded file %s: %s", c, err)
+	}
+}
@@ -0,0 +1,106 @@
+package ipfsproxy
+
+import (
+	"archive/tar"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/test"
+)
+
+func TestProxyCatSharded(t *testing.T) {
+	proxy, mock := testIPFSProxy(t)
+	defer mock.Close()
+	defer proxy.Shutdown()
+
+	cat := func(t *testing.T, rng string) (int, string) {
+		url := fmt.Sprintf("%s/file/cat?arg=/ipfs/%s", proxyURL(proxy), test.TestCid4)
+		req, _ := http.NewRequest("POST", url, nil)
+		if rng != "" {
+			req.Header.Set("Range", rng)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.StatusCode, string(body)
+	}
+
+	code, body := cat(t, "")
+	if code != http.StatusOK || body != test.TestCid4Data {
+		t.Errorf("unexpected response: %d: %s", code, body)
+	}
+
+	code, body = cat(t, "bytes=2-")
+	if code != http.StatusPartialContent || body != test.TestCid4Data[2:] {
+		t.Errorf("unexpected range response: %d: %s", code, body)
+	}
+}
+
+func TestProxyGetSharded(t *testing.T) {
+	proxy, mock := testIPFSProxy(t)
+	defer mock.Close()
+	defer proxy.Shutdown()
+
+	url := fmt.Sprintf("%s/file/get?arg=%s&output=file", proxyURL(proxy), test.TestCid4)
+	res, err := http.Post(url, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("Bad response status: got = %d, want = %d", res.StatusCode, http.StatusOK)
+	}
+
+	tr := tar.NewReader(res.Body)
+	hdr, err := tr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Name != "file" {
+		t.Error("unexpected name:", hdr.Name)
+	}
+	data, err := ioutil.ReadAll(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != test.TestCid4Data {
+		t.Errorf("unexpected data: %s", data)
+	}
+}
+
+func TestProxyCatShardedNotLocal(t *testing.T) {
+	proxy, mock := testIPFSProxy(t)
+	defer mock.Close()
+	defer proxy.Shutdown()
+
+	// The local IPFS daemon does not hold the shard of TestCid4, so
+	// its blocks must be fetched rather than read locally.
+	var data []byte
+	err := proxy.rpcClient.Call("", "Cluster", "IPFSBlockGetLocal", api.PinCid(test.MustDecodeCid(test.TestCid4)).ToSerial(), &data)
+	if err == nil {
+		t.Fatal("the local peer should not hold the shard")
+	}
+
+	url := fmt.Sprintf("%s/file/cat?arg=%s", proxyURL(proxy), test.TestCid4)
+	res, err := http.Post(url, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK || string(body) != test.TestCid4Data {
+		t.Errorf("unexpected response: %d: %s", res.StatusCode, body)
+	}
+}
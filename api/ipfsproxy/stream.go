@@ -0,0 +1,350 @@
+package ipfsproxy
+
+// stream.go serves file/cat and file/get by streaming files from IPFS
+// through the IPFSConnector, without buffering whole files in memory or
+// on disk.
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/rpcutil"
+)
+
+// sniffLen is the number of bytes used to detect the content type.
+const sniffLen = 512
+
+var errMultipleRanges = errors.New("multiple ranges are not supported")
+
+// ipfsStat returns the statistics of the given IPFS path.
+func (proxy *Server) ipfsStat(ctx context.Context, p string) (api.FilesStat, error) {
+	var stat api.FilesStat
+	err := proxy.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"IPFSStat",
+		p,
+		&stat,
+	)
+	return stat, err
+}
+
+// ipfsPath returns arg as an IPFS path, prefixing plain CIDs with /ipfs/.
+func ipfsPath(arg string) string {
+	if strings.HasPrefix(arg, "/") {
+		return arg
+	}
+	return "/ipfs/" + arg
+}
+
+// queryInt64 parses a non-negative integer query parameter. It returns
+// def when the parameter is not set.
+func queryInt64(q url.Values, key string, def int64) (int64, error) {
+	v := q.Get(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s is invalid: %s", key, v)
+	}
+	return n, nil
+}
+
+// parseRange parses a Range header for content of the given size and
+// returns the requested [start, end) interval. Only single ranges are
+// supported. errMultipleRanges is returned for several ranges, so that
+// the whole content can be served instead.
+func parseRange(h string, size int64) (int64, int64, error) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(h, prefix) {
+		return 0, 0, errors.New("invalid range")
+	}
+	spec := strings.TrimSpace(h[len(prefix):])
+	if strings.Contains(spec, ",") {
+		return 0, 0, errMultipleRanges
+	}
+
+	i := strings.Index(spec, "-")
+	if i < 0 {
+		return 0, 0, errors.New("invalid range")
+	}
+	startStr := strings.TrimSpace(spec[:i])
+	endStr := strings.TrimSpace(spec[i+1:])
+
+	// Suffix range: the last n bytes.
+	if startStr == "" {
+		n, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || n <= 0 || size == 0 {
+			return 0, 0, errors.New("invalid range")
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size, nil
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, errors.New("invalid range")
+	}
+	end := size
+	if endStr != "" {
+		last, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || last < start {
+			return 0, 0, errors.New("invalid range")
+		}
+		if last+1 < size {
+			end = last + 1
+		}
+	}
+	return start, end, nil
+}
+
+// catStream streams the file at the given IPFS path with "ipfs cat",
+// honoring the offset and length parameters and the Range header. Ranges
+// are relative to the window selected by offset and length.
+func (proxy *Server) catStream(w http.ResponseWriter, r *http.Request, arg string) {
+	ctx := r.Context()
+	q := r.URL.Query()
+	p := ipfsPath(arg)
+
+	offset, err := queryInt64(q, "offset", 0)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+	length, err := queryInt64(q, "length", -1)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+
+	stat, err := proxy.ipfsStat(ctx, p)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+	if stat.Type == "directory" {
+		ipfsErrorResponder(w, "this dag node is a directory")
+		return
+	}
+
+	size := int64(stat.Size)
+	winStart := offset
+	if winStart > size {
+		winStart = size
+	}
+	winEnd := size
+	if length >= 0 && winStart+length < winEnd {
+		winEnd = winStart + length
+	}
+	winSize := winEnd - winStart
+
+	status := http.StatusOK
+	start, end := winStart, winEnd
+	if h := r.Header.Get("Range"); h != "" {
+		s, e, err := parseRange(h, winSize)
+		switch {
+		case err == errMultipleRanges:
+		case err != nil:
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", winSize))
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		default:
+			status = http.StatusPartialContent
+			start, end = winStart+s, winStart+e
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", s, e-1, winSize))
+		}
+	}
+
+	reader := rpcutil.NewCatReader(ctx, proxy.rpcClient, p, end)
+	reader.Seek(start, io.SeekStart)
+	// Reading ahead reports errors before the headers are sent.
+	body := bufio.NewReaderSize(reader, sniffLen)
+	head, err := body.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+
+	ctype := mime.TypeByExtension(path.Ext(p))
+	if ctype == "" {
+		ctype = proxy.sniffContentType(ctx, p, start, head)
+	}
+
+	w.Header().Set("Content-Type", ctype)
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Content-Length", strconv.FormatInt(end-start, 10))
+	w.WriteHeader(status)
+
+	_, err = io.Copy(w, body)
+	if err != nil {
+		// The status has been sent already.
+		logger.Errorf("error sending %s: %s", p, err)
+	}
+}
+
+// sniffContentType detects the content type from the first bytes of the
+// file. head holds them when the response starts at the beginning of the
+// file. Otherwise, they are requested separately.
+func (proxy *Server) sniffContentType(ctx context.Context, p string, start int64, head []byte) string {
+	if start == 0 {
+		return http.DetectContentType(head)
+	}
+
+	head = nil
+	err := proxy.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"IPFSCat",
+		api.FileCat{Path: p, Length: sniffLen},
+		&head,
+	)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return http.DetectContentType(head)
+}
+
+// compressionLevel parses the compression-level parameter of "get".
+func compressionLevel(q url.Values) (int, error) {
+	l := q.Get("compression-level")
+	if l == "" {
+		return gzip.DefaultCompression, nil
+	}
+	level, err := strconv.Atoi(l)
+	if err != nil || level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		return 0, errors.New("compression-level is invalid")
+	}
+	return level, nil
+}
+
+// getStream sends the file or directory at the given IPFS path as a tar
+// archive, like "ipfs get" does, optionally compressed with gzip. A file
+// requested with compression but without archive is sent gzipped on its
+// own.
+func (proxy *Server) getStream(w http.ResponseWriter, r *http.Request, arg string) {
+	ctx := r.Context()
+	q := r.URL.Query()
+	p := ipfsPath(arg)
+
+	level, err := compressionLevel(q)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+
+	stat, err := proxy.ipfsStat(ctx, p)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+
+	compress := q.Get("compress") == "true"
+	ctype := "application/x-tar"
+	if compress {
+		ctype = "application/gzip"
+	}
+	w.Header().Set("Content-Type", ctype)
+	w.WriteHeader(http.StatusOK)
+
+	var out io.Writer = w
+	if compress {
+		gzw, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			logger.Error(err)
+			return
+		}
+		defer gzw.Close()
+		out = gzw
+	}
+
+	if compress && q.Get("archive") != "true" && stat.Type != "directory" {
+		_, err = io.Copy(out, rpcutil.NewCatReader(ctx, proxy.rpcClient, p, int64(stat.Size)))
+	} else {
+		name := q.Get("output")
+		if name == "" {
+			name = path.Base(p)
+		}
+		tw := tar.NewWriter(out)
+		err = proxy.writeTar(ctx, tw, p, name, stat)
+		if err == nil {
+			err = tw.Close()
+		}
+	}
+	if err != nil {
+		// The status has been sent already.
+		logger.Errorf("error sending %s: %s", p, err)
+	}
+}
+
+// writeTar writes the file or directory at the given IPFS path to tw,
+// under the given name. Directories are walked with IPFSLs and files are
+// read with IPFSCat.
+func (proxy *Server) writeTar(ctx context.Context, tw *tar.Writer, p, name string, stat api.FilesStat) error {
+	if stat.Type != "directory" {
+		err := tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(stat.Size),
+			ModTime:  time.Now(),
+			Typeflag: tar.TypeReg,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, rpcutil.NewCatReader(ctx, proxy.rpcClient, p, int64(stat.Size)))
+		return err
+	}
+
+	err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0755,
+		ModTime:  time.Now(),
+		Typeflag: tar.TypeDir,
+	})
+	if err != nil {
+		return err
+	}
+
+	var ls api.FilesLs
+	err = proxy.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"IPFSLs",
+		p,
+		&ls,
+	)
+	if err != nil {
+		return err
+	}
+	for _, e := range ls.Entries {
+		entryPath := "/ipfs/" + e.Hash
+		entryStat, err := proxy.ipfsStat(ctx, entryPath)
+		if err != nil {
+			return err
+		}
+		err = proxy.writeTar(ctx, tw, entryPath, path.Join(name, e.Name), entryStat)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package ipfsproxy
+
+import (
+	"archive/tar"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/test"
+)
+
+func TestParseRange(t *testing.T) {
+	type testcase struct {
+		header string
+		start  int64
+		end    int64
+		err    bool
+	}
+
+	tcs := []testcase{
+		{"bytes=0-", 0, 10, false},
+		{"bytes=2-4", 2, 5, false},
+		{"bytes=5-100", 5, 10, false},
+		{"bytes=-3", 7, 10, false},
+		{"bytes=-30", 0, 10, false},
+		{"bytes=10-", 0, 0, true},
+		{"bytes=4-2", 0, 0, true},
+		{"bytes=-0", 0, 0, true},
+		{"bytes=a-b", 0, 0, true},
+		{"items=1-2", 0, 0, true},
+	}
+
+	for _, tc := range tcs {
+		start, end, err := parseRange(tc.header, 10)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", tc.header)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tc.header, err)
+			continue
+		}
+		if start != tc.start || end != tc.end {
+			t.Errorf("%s: got [%d, %d), want [%d, %d)", tc.header, start, end, tc.start, tc.end)
+		}
+	}
+
+	_, _, err := parseRange("bytes=1-2,4-5", 10)
+	if err != errMultipleRanges {
+		t.Error("expected errMultipleRanges")
+	}
+}
+
+func TestProxyCat(t *testing.T) {
+	proxy, mock := testIPFSProxy(t)
+	defer mock.Close()
+	defer proxy.Shutdown()
+
+	data := test.TestCid1Data
+	size := len(data)
+
+	cat := func(t *testing.T, query, rng string) *http.Response {
+		url := fmt.Sprintf("%s/file/cat?arg=%s%s", proxyURL(proxy), test.TestCid1, query)
+		req, _ := http.NewRequest("POST", url, nil)
+		if rng != "" {
+			req.Header.Set("Range", rng)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	type testcase struct {
+		query  string
+		rng    string
+		status int
+		body   string
+		crange string
+	}
+
+	tcs := []testcase{
+		{"", "", http.StatusOK, data, ""},
+		{"&offset=6&length=4", "", http.StatusOK, data[6:10], ""},
+		{"&offset=100", "", http.StatusOK, "", ""},
+		{"", "bytes=6-9", http.StatusPartialContent, data[6:10], fmt.Sprintf("bytes 6-9/%d", size)},
+		{"", "bytes=-7", http.StatusPartialContent, data[size-7:], fmt.Sprintf("bytes %d-%d/%d", size-7, size-1, size)},
+		{"&offset=6", "bytes=0-3", http.StatusPartialContent, data[6:10], fmt.Sprintf("bytes 0-3/%d", size-6)},
+		{"", "bytes=1-2,4-5", http.StatusOK, data, ""},
+		{"", fmt.Sprintf("bytes=%d-", size), http.StatusRequestedRangeNotSatisfiable, "", fmt.Sprintf("bytes */%d", size)},
+	}
+
+	for _, tc := range tcs {
+		res := cat(t, tc.query, tc.rng)
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != tc.status {
+			t.Errorf("%q %q: got status %d, want %d", tc.query, tc.rng, res.StatusCode, tc.status)
+			continue
+		}
+		if tc.status == http.StatusRequestedRangeNotSatisfiable {
+			if res.Header.Get("Content-Range") != tc.crange {
+				t.Errorf("%q %q: unexpected Content-Range: %s", tc.query, tc.rng, res.Header.Get("Content-Range"))
+			}
+			continue
+		}
+		if string(body) != tc.body {
+			t.Errorf("%q %q: got %q, want %q", tc.query, tc.rng, body, tc.body)
+		}
+		if res.Header.Get("Content-Range") != tc.crange {
+			t.Errorf("%q %q: unexpected Content-Range: %s", tc.query, tc.rng, res.Header.Get("Content-Range"))
+		}
+		if !strings.HasPrefix(res.Header.Get("Content-Type"), "text/html") {
+			t.Errorf("%q %q: unexpected Content-Type: %s", tc.query, tc.rng, res.Header.Get("Content-Type"))
+		}
+	}
+
+	res := cat(t, "&offset=-1", "")
+	res.Body.Close()
+	if res.StatusCode != http.StatusInternalServerError {
+		t.Error("expected an error with a negative offset")
+	}
+
+	url := fmt.Sprintf("%s/file/cat?arg=%s", proxyURL(proxy), test.ErrorCid)
+	res, err := http.Post(url, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusInternalServerError {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestProxyGet(t *testing.T) {
+	proxy, mock := testIPFSProxy(t)
+	defer mock.Close()
+	defer proxy.Shutdown()
+
+	url := fmt.Sprintf("%s/file/get?arg=/ipfs/%s", proxyURL(proxy), test.TestCid1)
+	res, err := http.Post(url, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("Bad response status: got = %d, want = %d", res.StatusCode, http.StatusOK)
+	}
+	if res.Header.Get("Content-Type") != "application/x-tar" {
+		t.Error("unexpected Content-Type:", res.Header.Get("Content-Type"))
+	}
+
+	tr := tar.NewReader(res.Body)
+	hdr, err := tr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Name != test.TestCid1 {
+		t.Error("unexpected name:", hdr.Name)
+	}
+	data, err := ioutil.ReadAll(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != test.TestCid1Data {
+		t.Errorf("unexpected data: %s", data)
+	}
+}
+
+func TestProxyGetDirectory(t *testing.T) {
+	proxy, mock := testIPFSProxy(t)
+	defer mock.Close()
+	defer proxy.Shutdown()
+
+	url := fmt.Sprintf("%s/file/get?arg=%s&output=dir", proxyURL(proxy), test.TestCid2)
+	res, err := http.Post(url, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("Bad response status: got = %d, want = %d", res.StatusCode, http.StatusOK)
+	}
+
+	tr := tar.NewReader(res.Body)
+	hdr, err := tr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Name != "dir" || hdr.Typeflag != tar.TypeDir {
+		t.Error("unexpected directory entry:", hdr.Name)
+	}
+	hdr, err = tr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Name != "dir/file" {
+		t.Error("unexpected file entry:", hdr.Name)
+	}
+	data, err := ioutil.ReadAll(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != test.TestCid1Data {
+		t.Errorf("unexpected data: %s", data)
+	}
+}
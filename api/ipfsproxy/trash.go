@@ -0,0 +1,117 @@
+package ipfsproxy
+
+// trash.go serves the trash of the UID homes, where files/rm moves the
+// deleted entries unless permanent=true is given.
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+)
+
+type trashRestoreResp struct {
+	Path string
+}
+
+func (proxy *Server) trashLsHandler(w http.ResponseWriter, r *http.Request) {
+	proxy.setHeaders(w.Header(), r)
+
+	uid := r.URL.Query().Get("uid")
+	if uid == "" {
+		ipfsErrorResponder(w, "error reading request: "+r.URL.String())
+		return
+	}
+
+	err := proxy.uidSpawn(uid)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+
+	trashLs := api.TrashLs{}
+	err = proxy.rpcClient.Call(
+		"",
+		"Cluster",
+		"TrashList",
+		uid,
+		&trashLs.Entries,
+	)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+
+	resBytes, _ := json.Marshal(trashLs)
+	w.WriteHeader(http.StatusOK)
+	w.Write(resBytes)
+}
+
+func (proxy *Server) trashRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	proxy.setHeaders(w.Header(), r)
+
+	q := r.URL.Query()
+
+	uid := q.Get("uid")
+	id := q.Get("id")
+	if uid == "" || id == "" {
+		ipfsErrorResponder(w, "error reading request: "+r.URL.String())
+		return
+	}
+
+	err := proxy.uidSpawn(uid)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+
+	var dest string
+	err = proxy.rpcClient.Call(
+		"",
+		"Cluster",
+		"TrashRestore",
+		[]string{uid, id, q.Get("dest")},
+		&dest,
+	)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+
+	resBytes, _ := json.Marshal(trashRestoreResp{Path: dest})
+	w.WriteHeader(http.StatusOK)
+	w.Write(resBytes)
+}
+
+func (proxy *Server) trashEmptyHandler(w http.ResponseWriter, r *http.Request) {
+	proxy.setHeaders(w.Header(), r)
+
+	q := r.URL.Query()
+
+	uid := q.Get("uid")
+	if uid == "" {
+		ipfsErrorResponder(w, "error reading request: "+r.URL.String())
+		return
+	}
+
+	err := proxy.uidSpawn(uid)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+
+	// Without an id, the whole trash is emptied.
+	err = proxy.rpcClient.Call(
+		"",
+		"Cluster",
+		"TrashEmpty",
+		[]string{uid, q.Get("id")},
+		&struct{}{},
+	)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
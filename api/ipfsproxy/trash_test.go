@@ -0,0 +1,110 @@
+package ipfsproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/test"
+)
+
+func postProxy(t *testing.T, proxy *Server, path string, resp interface{}) int {
+	res, err := http.Post(fmt.Sprintf("%s/%s", proxyURL(proxy), path), "", nil)
+	if err != nil {
+		t.Fatal("should have succeeded: ", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusOK && resp != nil {
+		err = json.NewDecoder(res.Body).Decode(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return res.StatusCode
+}
+
+func TestProxyFilesRmToTrash(t *testing.T) {
+	proxy, mock := testIPFSProxy(t)
+	defer mock.Close()
+	defer proxy.Shutdown()
+
+	// Like IPFS, nothing is returned by default.
+	res, err := http.Post(proxyURL(proxy)+"/files/rm?uid=uid-test&path=/docs/file", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || len(body) != 0 {
+		t.Fatalf("unexpected response: %d: %s", res.StatusCode, body)
+	}
+
+	var entry api.TrashEntry
+	st := postProxy(t, proxy, "files/rm?uid=uid-test&path=/docs/file&trash-entry=true", &entry)
+	if st != http.StatusOK {
+		t.Fatal("unexpected status:", st)
+	}
+	if entry.ID != test.TestTrashID || entry.Path != "/docs/file" {
+		t.Errorf("unexpected trash entry: %+v", entry)
+	}
+
+	st = postProxy(t, proxy, "files/rm?uid=uid-test&path=/missing", nil)
+	if st != http.StatusInternalServerError {
+		t.Error("expected an error moving a missing file to the trash:", st)
+	}
+
+	st = postProxy(t, proxy, "files/rm?uid=uid-test&path=/missing&permanent=true", nil)
+	if st != http.StatusOK {
+		t.Error("permanent removals should bypass the trash:", st)
+	}
+}
+
+func TestProxyTrash(t *testing.T) {
+	proxy, mock := testIPFSProxy(t)
+	defer mock.Close()
+	defer proxy.Shutdown()
+
+	t.Run("ls", func(t *testing.T) {
+		var ls api.TrashLs
+		st := postProxy(t, proxy, "files/trash/ls?uid=uid-test", &ls)
+		if st != http.StatusOK {
+			t.Fatal("unexpected status:", st)
+		}
+		if len(ls.Entries) != 1 || ls.Entries[0].ID != test.TestTrashID {
+			t.Errorf("unexpected entries: %+v", ls.Entries)
+		}
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		var resp trashRestoreResp
+		st := postProxy(t, proxy, "files/trash/restore?uid=uid-test&id="+test.TestTrashID, &resp)
+		if st != http.StatusOK || resp.Path != "/docs/file" {
+			t.Errorf("unexpected response: %d: %+v", st, resp)
+		}
+
+		st = postProxy(t, proxy, "files/trash/restore?uid=uid-test&id="+test.TestTrashID+"&dest=/other", &resp)
+		if st != http.StatusOK || resp.Path != "/other" {
+			t.Errorf("unexpected response: %d: %+v", st, resp)
+		}
+
+		st = postProxy(t, proxy, "files/trash/restore?uid=uid-test", nil)
+		if st != http.StatusInternalServerError {
+			t.Error("expected an error without id:", st)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		st := postProxy(t, proxy, "files/trash/empty?uid=uid-test", nil)
+		if st != http.StatusOK {
+			t.Error("unexpected status:", st)
+		}
+
+		st = postProxy(t, proxy, "files/trash/empty?uid=uid-test&id=123-abc", nil)
+		if st != http.StatusInternalServerError {
+			t.Error("expected an error with an unknown id:", st)
+		}
+	})
+}
@@ -0,0 +1,636 @@
+package ipfsproxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/adder/adderutils"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+	files "github.com/ipfs/go-ipfs-files"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Errors returned by the upload sessions.
+var (
+	ErrUploadNotFound   = errors.New("upload session not found")
+	ErrUploadIncomplete = errors.New("upload session has not received all the data")
+	ErrUploadOutOfRange = errors.New("chunk is out of the range of the upload")
+	ErrUploadFinishing  = errors.New("upload session is being finished")
+	ErrUploadTooLarge   = errors.New("upload is larger than the maximum upload size")
+)
+
+const (
+	uploadMetaFile = "session.json"
+	uploadDataFile = "data"
+)
+
+// uploadCleanupInterval specifies how often expired upload sessions are
+// removed.
+var uploadCleanupInterval = time.Minute
+
+// byteRange is a range of received bytes, from Start (included) to End
+// (excluded).
+type byteRange struct {
+	Start int64
+	End   int64
+}
+
+// uploadSession is a resumable upload of a file to a path in a UID home.
+type uploadSession struct {
+	ID       string `json:"Session"`
+	UID      string
+	Path     string
+	Size     int64
+	Received []byteRange
+	Expires  time.Time
+}
+
+// complete returns true when every byte of the file has been received.
+func (s *uploadSession) complete() bool {
+	if s.Size == 0 {
+		return true
+	}
+	return len(s.Received) == 1 &&
+		s.Received[0].Start == 0 &&
+		s.Received[0].End == s.Size
+}
+
+// addRange records a received range, merging it with the ones it
+// overlaps or touches.
+func (s *uploadSession) addRange(r byteRange) {
+	ranges := append(s.Received, r)
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Start < ranges[j].Start
+	})
+
+	merged := ranges[:1]
+	for _, next := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if next.Start <= last.End {
+			if next.End > last.End {
+				last.End = next.End
+			}
+			continue
+		}
+		merged = append(merged, next)
+	}
+	s.Received = merged
+}
+
+// uploadStore keeps the upload sessions on disk. Each session is a
+// folder with its metadata and the data received so far.
+//
+// The store lock only protects the in-memory state of the sessions. The
+// metadata of a session is read and written under its own lock, and the
+// data of a chunk is copied from the network to disk without holding any
+// lock, so that slow clients do not hold back the other uploads.
+type uploadStore struct {
+	folder string
+	ttl    time.Duration
+
+	mu     sync.Mutex
+	states map[string]*uploadState
+}
+
+// uploadState is the in-memory state of an upload session.
+type uploadState struct {
+	mu sync.Mutex
+	// number of chunks being written
+	writers int
+	// set while the upload is being added to the cluster
+	finishing bool
+}
+
+func newUploadStore(folder string, ttl time.Duration) *uploadStore {
+	return &uploadStore{
+		folder: folder,
+		ttl:    ttl,
+		states: make(map[string]*uploadState),
+	}
+}
+
+func (st *uploadStore) sessionPath(id string) string {
+	return filepath.Join(st.folder, id)
+}
+
+// dataPath returns the path of the file holding the data of a session.
+func (st *uploadStore) dataPath(id string) string {
+	return filepath.Join(st.sessionPath(id), uploadDataFile)
+}
+
+// state returns the in-memory state of a session, creating it when
+// needed.
+func (st *uploadStore) state(id string) *uploadState {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	ss, ok := st.states[id]
+	if !ok {
+		ss = &uploadState{}
+		st.states[id] = ss
+	}
+	return ss
+}
+
+func (st *uploadStore) forget(id string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	delete(st.states, id)
+}
+
+// create starts a new upload session.
+func (st *uploadStore) create(uid, path string, size int64) (*uploadSession, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &uploadSession{
+		ID:       id.String(),
+		UID:      uid,
+		Path:     path,
+		Size:     size,
+		Received: []byteRange{},
+		Expires:  time.Now().Add(st.ttl),
+	}
+
+	err = os.MkdirAll(st.sessionPath(s.ID), 0700)
+	if err != nil {
+		return nil, err
+	}
+	err = ioutil.WriteFile(st.dataPath(s.ID), nil, 0600)
+	if err != nil {
+		return nil, err
+	}
+	err = st.save(s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// get returns an upload session of the given UID which has not expired.
+func (st *uploadStore) get(id, uid string) (*uploadSession, error) {
+	if !validUploadID(id) {
+		return nil, ErrUploadNotFound
+	}
+	ss := st.state(id)
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return st.loadFor(id, uid)
+}
+
+// writeChunk writes the data read from r at the given offset of an
+// upload of the given UID and extends its expiration. The length of the
+// chunk is checked before writing anything when it is known (not
+// negative). Otherwise, nothing is written past the end of the upload.
+// Chunks of the same upload may be written concurrently.
+func (st *uploadStore) writeChunk(id, uid string, offset, length int64, r io.Reader) (*uploadSession, error) {
+	if !validUploadID(id) {
+		return nil, ErrUploadNotFound
+	}
+	ss := st.state(id)
+
+	ss.mu.Lock()
+	s, err := st.loadFor(id, uid)
+	switch {
+	case err != nil:
+	case ss.finishing:
+		err = ErrUploadFinishing
+	case offset < 0 || offset > s.Size:
+		err = ErrUploadOutOfRange
+	case length >= 0 && offset+length > s.Size:
+		err = ErrUploadOutOfRange
+	}
+	if err != nil {
+		ss.mu.Unlock()
+		return nil, err
+	}
+	ss.writers++
+	ss.mu.Unlock()
+
+	n, err := st.writeData(id, offset, s.Size, r)
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.writers--
+
+	// Other chunks may have been received meanwhile.
+	s, lerr := st.load(id)
+	if lerr != nil {
+		return nil, lerr
+	}
+	if err == ErrUploadOutOfRange {
+		// the chunk is rejected, even if the part which fits
+		// was written.
+		return nil, err
+	}
+	if n > 0 {
+		// whatever was written before an error counts as
+		// received.
+		s.addRange(byteRange{Start: offset, End: offset + n})
+	}
+	s.Expires = time.Now().Add(st.ttl)
+	if serr := st.save(s); serr != nil {
+		return nil, serr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// writeData copies the data read from r to the given offset of the data
+// file of an upload. Only the data which fits in the upload is written:
+// it returns ErrUploadOutOfRange when there is more.
+func (st *uploadStore) writeData(id string, offset, size int64, r io.Reader) (int64, error) {
+	f, err := os.OpenFile(st.dataPath(id), os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	_, err = f.Seek(offset, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(f, io.LimitReader(r, size-offset))
+	if err != nil {
+		return n, err
+	}
+	// any byte left means the chunk is too long.
+	if m, _ := io.ReadFull(r, make([]byte, 1)); m > 0 {
+		return n, ErrUploadOutOfRange
+	}
+	return n, nil
+}
+
+// startFinish marks a complete upload of the given UID as finishing, so
+// that it receives no more chunks and it is not removed while it is
+// added. Either finished or abortFinish must be called afterwards.
+func (st *uploadStore) startFinish(id, uid string) (*uploadSession, error) {
+	if !validUploadID(id) {
+		return nil, ErrUploadNotFound
+	}
+	ss := st.state(id)
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	s, err := st.loadFor(id, uid)
+	if err != nil {
+		return nil, err
+	}
+	if ss.finishing {
+		return nil, ErrUploadFinishing
+	}
+	if ss.writers > 0 || !s.complete() {
+		return nil, ErrUploadIncomplete
+	}
+	ss.finishing = true
+	return s, nil
+}
+
+// abortFinish allows an upload which failed to finish to be finished
+// again.
+func (st *uploadStore) abortFinish(id string) {
+	ss := st.state(id)
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.finishing = false
+}
+
+// finished removes an upload which has been finished.
+func (st *uploadStore) finished(id string) error {
+	defer st.forget(id)
+	return os.RemoveAll(st.sessionPath(id))
+}
+
+// remove deletes an upload session of the given UID and its data, unless
+// it is being finished.
+func (st *uploadStore) remove(id, uid string) error {
+	if !validUploadID(id) {
+		return ErrUploadNotFound
+	}
+	ss := st.state(id)
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if ss.finishing {
+		return ErrUploadFinishing
+	}
+	if _, err := os.Stat(st.sessionPath(id)); os.IsNotExist(err) {
+		st.forget(id)
+		return ErrUploadNotFound
+	}
+	if _, err := st.loadFor(id, uid); err != nil {
+		return err
+	}
+	defer st.forget(id)
+	return os.RemoveAll(st.sessionPath(id))
+}
+
+// cleanup removes the sessions which have expired.
+func (st *uploadStore) cleanup() {
+	entries, err := ioutil.ReadDir(st.folder)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Error(err)
+		}
+		return
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		id := e.Name()
+		ss := st.state(id)
+		ss.mu.Lock()
+		_, err := st.load(id)
+		if err == ErrUploadNotFound && ss.writers == 0 && !ss.finishing {
+			logger.Infof("removing expired upload session %s", id)
+			os.RemoveAll(st.sessionPath(id))
+			st.forget(id)
+		}
+		ss.mu.Unlock()
+	}
+}
+
+func validUploadID(id string) bool {
+	return id != "" && filepath.Base(id) == id
+}
+
+// load reads a session from disk. Expired or unreadable sessions are
+// reported as not found. The caller must hold the lock of the session.
+func (st *uploadStore) load(id string) (*uploadSession, error) {
+	if !validUploadID(id) {
+		return nil, ErrUploadNotFound
+	}
+
+	raw, err := ioutil.ReadFile(filepath.Join(st.sessionPath(id), uploadMetaFile))
+	if err != nil {
+		return nil, ErrUploadNotFound
+	}
+
+	s := &uploadSession{}
+	err = json.Unmarshal(raw, s)
+	if err != nil {
+		logger.Errorf("error reading upload session %s: %s", id, err)
+		return nil, ErrUploadNotFound
+	}
+	if time.Now().After(s.Expires) {
+		return nil, ErrUploadNotFound
+	}
+	return s, nil
+}
+
+// loadFor reads a session of the given UID. The sessions of other UIDs
+// are reported as not found. The caller must hold the lock of the
+// session.
+func (st *uploadStore) loadFor(id, uid string) (*uploadSession, error) {
+	s, err := st.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if s.UID != uid {
+		return nil, ErrUploadNotFound
+	}
+	return s, nil
+}
+
+// save writes the metadata of a session. The file is replaced
+// atomically. The caller must hold the lock of the session, unless it
+// is being created.
+func (st *uploadStore) save(s *uploadSession) error {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(st.sessionPath(s.ID), uploadMetaFile)
+	tmp := path + ".tmp"
+	err = ioutil.WriteFile(tmp, raw, 0600)
+	if err != nil {
+		return fmt.Errorf("error saving upload session: %s", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// cleanupUploads removes the expired upload sessions regularly.
+func (proxy *Server) cleanupUploads() {
+	defer proxy.wg.Done()
+
+	ticker := time.NewTicker(uploadCleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-proxy.ctx.Done():
+			return
+		case <-ticker.C:
+			proxy.uploads.cleanup()
+		}
+	}
+}
+
+func (proxy *Server) sendUploadSession(w http.ResponseWriter, s *uploadSession) {
+	resBytes, _ := json.Marshal(s)
+	w.WriteHeader(http.StatusOK)
+	w.Write(resBytes)
+}
+
+// uploadNewHandler starts a resumable upload of a file of the given size
+// to a path in the home of a UID. The other upload requests must give the
+// same uid as the session.
+func (proxy *Server) uploadNewHandler(w http.ResponseWriter, r *http.Request) {
+	proxy.setHeaders(w.Header(), r)
+
+	q := r.URL.Query()
+
+	uid := q.Get("uid")
+	if uid == "" {
+		ipfsErrorResponder(w, "error reading request: "+r.URL.String())
+		return
+	}
+
+	dest := q.Get("path")
+	if dest == "" || dest == "/" {
+		ipfsErrorResponder(w, "error reading request: "+r.URL.String())
+		return
+	}
+
+	size, err := strconv.ParseInt(q.Get("size"), 10, 64)
+	if err != nil || size < 0 {
+		ipfsErrorResponder(w, "invalid size: "+q.Get("size"))
+		return
+	}
+	if max := proxy.config.MaxUploadSize; max > 0 && uint64(size) > max {
+		ipfsErrorResponder(w, ErrUploadTooLarge.Error())
+		return
+	}
+
+	err = proxy.uidSpawn(uid)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+
+	s, err := proxy.uploads.create(uid, dest, size)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+	proxy.sendUploadSession(w, s)
+}
+
+// uploadChunkHandler writes the body of the request at the given offset
+// of an upload.
+func (proxy *Server) uploadChunkHandler(w http.ResponseWriter, r *http.Request) {
+	proxy.setHeaders(w.Header(), r)
+
+	q := r.URL.Query()
+
+	offset, err := strconv.ParseInt(q.Get("offset"), 10, 64)
+	if err != nil {
+		ipfsErrorResponder(w, "invalid offset: "+q.Get("offset"))
+		return
+	}
+
+	s, err := proxy.uploads.writeChunk(q.Get("session"), q.Get("uid"), offset, r.ContentLength, r.Body)
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+	proxy.sendUploadSession(w, s)
+}
+
+// uploadStatusHandler returns an upload session with the ranges received
+// so far.
+func (proxy *Server) uploadStatusHandler(w http.ResponseWriter, r *http.Request) {
+	proxy.setHeaders(w.Header(), r)
+
+	q := r.URL.Query()
+
+	s, err := proxy.uploads.get(q.Get("session"), q.Get("uid"))
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+	proxy.sendUploadSession(w, s)
+}
+
+// uploadFinishHandler adds the data of a complete upload to the cluster,
+// links it into the home of the UID and removes the session. Adding
+// options are taken from the query like in /add. The session receives no
+// more chunks while it is finished.
+func (proxy *Server) uploadFinishHandler(w http.ResponseWriter, r *http.Request) {
+	proxy.setHeaders(w.Header(), r)
+
+	q := r.URL.Query()
+
+	params, err := api.AddParamsFromQuery(q)
+	if err != nil {
+		ipfsErrorResponder(w, "error parsing options:"+err.Error())
+		return
+	}
+	if q.Get("trickle") == "true" {
+		params.Layout = "trickle"
+	}
+	// The file is added on its own: the name is only used in the
+	// response.
+	params.Wrap = false
+
+	s, err := proxy.uploads.startFinish(q.Get("session"), q.Get("uid"))
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+
+	name, root, err := proxy.finishUpload(s, params, q.Get("parents") == "true")
+	if err != nil {
+		proxy.uploads.abortFinish(s.ID)
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+
+	err = proxy.uploads.finished(s.ID)
+	if err != nil {
+		logger.Errorf("error removing upload session %s: %s", s.ID, err)
+	}
+
+	res := ipfsAddResp{
+		Name: name,
+		Hash: root.String(),
+		Size: strconv.FormatInt(s.Size, 10),
+	}
+	resBytes, _ := json.Marshal(res)
+	w.WriteHeader(http.StatusOK)
+	w.Write(resBytes)
+}
+
+// finishUpload adds the data of an upload and links it into the home of
+// its UID. It returns the name of the file and its root.
+func (proxy *Server) finishUpload(s *uploadSession, params *api.AddParams, parents bool) (string, cid.Cid, error) {
+	err := proxy.uidSpawn(s.UID)
+	if err != nil {
+		return "", cid.Undef, err
+	}
+
+	dataPath := proxy.uploads.dataPath(s.ID)
+	stat, err := os.Stat(dataPath)
+	if err != nil {
+		return "", cid.Undef, err
+	}
+	f, err := files.NewSerialFile(dataPath, false, stat)
+	if err != nil {
+		return "", cid.Undef, err
+	}
+	defer f.Close()
+
+	// As in file/add, pins which existed before are kept when
+	// linking fails.
+	var pinned bool
+	checkPinned := func(root cid.Cid) error {
+		pinned = proxy.isPinned(root)
+		return nil
+	}
+
+	name := path.Base(s.Path)
+	dir := files.NewMapDirectory(map[string]files.Node{name: f})
+	root, err := adderutils.AddFiles(proxy.ctx, proxy.rpcClient, params, dir, checkPinned)
+	if err != nil {
+		return "", cid.Undef, err
+	}
+
+	err = proxy.addToHome(s.UID, s.Path, root, parents, !pinned)
+	if err != nil {
+		return "", cid.Undef, err
+	}
+	return name, root, nil
+}
+
+// uploadCancelHandler removes an upload session and its data.
+func (proxy *Server) uploadCancelHandler(w http.ResponseWriter, r *http.Request) {
+	proxy.setHeaders(w.Header(), r)
+
+	q := r.URL.Query()
+
+	err := proxy.uploads.remove(q.Get("session"), q.Get("uid"))
+	if err != nil {
+		ipfsErrorResponder(w, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
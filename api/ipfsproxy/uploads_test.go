@@ -0,0 +1,343 @@
+package ipfsproxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+func testUploadStore(t *testing.T, ttl time.Duration) (*uploadStore, func()) {
+	dir, err := ioutil.TempDir("", "proxy-uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newUploadStore(dir, ttl), func() { os.RemoveAll(dir) }
+}
+
+func TestUploadSessionRanges(t *testing.T) {
+	s := &uploadSession{Size: 10}
+	s.addRange(byteRange{4, 6})
+	s.addRange(byteRange{0, 2})
+	s.addRange(byteRange{5, 8})
+	if len(s.Received) != 2 || s.Received[0] != (byteRange{0, 2}) || s.Received[1] != (byteRange{4, 8}) {
+		t.Errorf("unexpected ranges: %+v", s.Received)
+	}
+	if s.complete() {
+		t.Error("session should not be complete")
+	}
+
+	s.addRange(byteRange{2, 4})
+	s.addRange(byteRange{8, 10})
+	if !s.complete() {
+		t.Errorf("session should be complete: %+v", s.Received)
+	}
+}
+
+func TestUploadStore(t *testing.T) {
+	st, clean := testUploadStore(t, time.Minute)
+	defer clean()
+
+	s, err := st.create("uid-test", "/docs/file.txt", 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = st.writeChunk(s.ID, "uid-test", 6, 5, strings.NewReader("world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = st.writeChunk(s.ID, "uid-test", 8, 8, strings.NewReader("too long"))
+	if err != ErrUploadOutOfRange {
+		t.Error("expected ErrUploadOutOfRange")
+	}
+	s, err = st.writeChunk(s.ID, "uid-test", 0, 6, strings.NewReader("hello "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.complete() {
+		t.Fatalf("upload should be complete: %+v", s.Received)
+	}
+
+	data, err := ioutil.ReadFile(st.dataPath(s.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello world" {
+		t.Errorf("unexpected data: %q", data)
+	}
+
+	_, err = st.get("../"+s.ID, "uid-test")
+	if err != ErrUploadNotFound {
+		t.Error("expected ErrUploadNotFound")
+	}
+
+	err = st.remove(s.ID, "uid-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = st.get(s.ID, "uid-test")
+	if err != ErrUploadNotFound {
+		t.Error("expected ErrUploadNotFound after removing")
+	}
+}
+
+func TestUploadStoreChunkTooLong(t *testing.T) {
+	st, clean := testUploadStore(t, time.Minute)
+	defer clean()
+
+	s, err := st.create("uid-test", "/file.txt", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = st.writeChunk(s.ID, "uid-test", 0, 5, strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The length of the chunk is unknown until it is read.
+	_, err = st.writeChunk(s.ID, "uid-test", 3, -1, strings.NewReader("p me"))
+	if err != ErrUploadOutOfRange {
+		t.Error("expected ErrUploadOutOfRange")
+	}
+	data, err := ioutil.ReadFile(st.dataPath(s.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "help " {
+		t.Errorf("nothing should be written past the end of the upload: %q", data)
+	}
+}
+
+func TestUploadStoreOtherUID(t *testing.T) {
+	st, clean := testUploadStore(t, time.Minute)
+	defer clean()
+
+	s, err := st.create("uid-test", "/file.txt", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = st.writeChunk(s.ID, "uid-other", 0, 5, strings.NewReader("hello"))
+	if err != ErrUploadNotFound {
+		t.Error("chunks of other UIDs should not be written")
+	}
+	_, err = st.get(s.ID, "uid-other")
+	if err != ErrUploadNotFound {
+		t.Error("sessions of other UIDs should not be found")
+	}
+	_, err = st.writeChunk(s.ID, "uid-test", 0, 5, strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = st.startFinish(s.ID, "uid-other")
+	if err != ErrUploadNotFound {
+		t.Error("sessions of other UIDs should not be finished")
+	}
+	err = st.remove(s.ID, "uid-other")
+	if err != ErrUploadNotFound {
+		t.Error("sessions of other UIDs should not be removed")
+	}
+	_, err = st.get(s.ID, "uid-test")
+	if err != nil {
+		t.Error("the session should still exist:", err)
+	}
+}
+
+func TestUploadStoreFinishing(t *testing.T) {
+	st, clean := testUploadStore(t, time.Minute)
+	defer clean()
+
+	s, err := st.create("uid-test", "/file.txt", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = st.startFinish(s.ID, "uid-test")
+	if err != ErrUploadIncomplete {
+		t.Error("expected ErrUploadIncomplete")
+	}
+	_, err = st.writeChunk(s.ID, "uid-test", 0, 5, strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = st.startFinish(s.ID, "uid-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = st.writeChunk(s.ID, "uid-test", 0, 5, strings.NewReader("world"))
+	if err != ErrUploadFinishing {
+		t.Error("chunks should not be written while finishing")
+	}
+	if st.remove(s.ID, "uid-test") != ErrUploadFinishing {
+		t.Error("sessions should not be removed while finishing")
+	}
+	_, err = st.startFinish(s.ID, "uid-test")
+	if err != ErrUploadFinishing {
+		t.Error("a session should only be finished once at a time")
+	}
+
+	st.abortFinish(s.ID)
+	_, err = st.startFinish(s.ID, "uid-test")
+	if err != nil {
+		t.Fatal("the session should be finished again after aborting:", err)
+	}
+	err = st.finished(s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = st.get(s.ID, "uid-test")
+	if err != ErrUploadNotFound {
+		t.Error("expected ErrUploadNotFound after finishing")
+	}
+}
+
+func TestUploadStoreSlowChunk(t *testing.T) {
+	st, clean := testUploadStore(t, time.Minute)
+	defer clean()
+
+	slow, err := st.create("uid-test", "/slow.txt", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fast, err := st.create("uid-test", "/fast.txt", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan error)
+	go func() {
+		_, err := st.writeChunk(slow.ID, "uid-test", 0, -1, pr)
+		done <- err
+	}()
+	pw.Write([]byte("hello"))
+
+	// A chunk which is still being received does not block the
+	// other sessions, nor the status of its own.
+	_, err = st.writeChunk(fast.ID, "uid-test", 0, 5, strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = st.get(slow.ID, "uid-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = st.writeChunk(slow.ID, "uid-test", 5, 5, strings.NewReader("world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pw.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	s, err := st.get(slow.ID, "uid-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.complete() {
+		t.Errorf("both chunks should have been recorded: %+v", s.Received)
+	}
+}
+
+func TestUploadStoreExpire(t *testing.T) {
+	st, clean := testUploadStore(t, 100*time.Millisecond)
+	defer clean()
+
+	s, err := st.create("uid-test", "/file.txt", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	_, err = st.writeChunk(s.ID, "uid-test", 0, 5, strings.NewReader("hello"))
+	if err != ErrUploadNotFound {
+		t.Error("expired sessions should not be found")
+	}
+
+	st.cleanup()
+	if _, err := os.Stat(st.sessionPath(s.ID)); !os.IsNotExist(err) {
+		t.Error("expired session should have been removed")
+	}
+}
+
+func TestProxyUpload(t *testing.T) {
+	proxy, mock := testIPFSProxy(t)
+	defer mock.Close()
+	defer proxy.Shutdown()
+
+	st, clean := testUploadStore(t, time.Minute)
+	defer clean()
+	proxy.uploads = st
+
+	call := func(t *testing.T, path string, body []byte, out interface{}) int {
+		res, err := http.Post(
+			fmt.Sprintf("%s/%s", proxyURL(proxy), path),
+			"application/octet-stream",
+			bytes.NewReader(body),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if out != nil {
+			json.NewDecoder(res.Body).Decode(out)
+		}
+		return res.StatusCode
+	}
+
+	proxy.config.MaxUploadSize = 10
+	code := call(t, "file/upload/new?uid=uid-test&path=/docs/file.txt&size=11", nil, nil)
+	if code != http.StatusInternalServerError {
+		t.Error("uploads larger than the maximum size should be rejected")
+	}
+	proxy.config.MaxUploadSize = 0
+
+	var s uploadSession
+	code = call(t, "file/upload/new?uid=uid-test&path=/docs/file.txt&size=11", nil, &s)
+	if code != http.StatusOK || s.ID == "" {
+		t.Fatal("could not create upload session")
+	}
+
+	call(t, "file/upload/chunk?uid=uid-test&session="+s.ID+"&offset=0", []byte("hello "), &s)
+	if len(s.Received) != 1 || s.Received[0].End != 6 {
+		t.Errorf("unexpected ranges: %+v", s.Received)
+	}
+
+	code = call(t, "file/upload/finish?uid=uid-test&session="+s.ID, nil, nil)
+	if code != http.StatusInternalServerError {
+		t.Error("finishing an incomplete upload should fail")
+	}
+
+	call(t, "file/upload/chunk?uid=uid-test&session="+s.ID+"&offset=6", []byte("world"), nil)
+	call(t, "file/upload/status?uid=uid-test&session="+s.ID, nil, &s)
+	if !s.complete() {
+		t.Fatalf("upload should be complete: %+v", s.Received)
+	}
+
+	var resp ipfsAddResp
+	code = call(t, "file/upload/finish?uid=uid-test&session="+s.ID, nil, &resp)
+	if code != http.StatusOK {
+		t.Fatal("finishing should have worked")
+	}
+	if resp.Name != "file.txt" || resp.Size != "11" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if _, err := cid.Decode(resp.Hash); err != nil {
+		t.Error("expected a cid:", err)
+	}
+
+	code = call(t, "file/upload/status?uid=uid-test&session="+s.ID, nil, nil)
+	if code != http.StatusInternalServerError {
+		t.Error("the session should be removed after finishing")
+	}
+}
@@ -6,6 +6,7 @@ package client
 import (
 	"context"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"time"
@@ -48,21 +49,39 @@ type Client interface {
 	PeerAdd(pid peer.ID) (api.ID, error)
 	// PeerRm removes a current peer from the cluster
 	PeerRm(pid peer.ID) error
+	// PeerDrain marks a peer as draining: it gets no new allocations
+	// and its pins are gradually moved to other peers.
+	PeerDrain(pid peer.ID) error
+	// PeerUndrain clears the draining mark of a peer.
+	PeerUndrain(pid peer.ID) error
+	// DrainStatus reports how many pins remain allocated to every
+	// draining peer.
+	DrainStatus() ([]api.DrainInfo, error)
 
 	// Add imports files to the cluster from the given paths.
 	Add(paths []string, params *api.AddParams, out chan<- *api.AddedOutput) error
 	// AddMultiFile imports new files from a MultiFileReader.
 	AddMultiFile(multiFileR *files.MultiFileReader, params *api.AddParams, out chan<- *api.AddedOutput) error
+	// Cat returns the contents of a file added to the cluster. Sharded
+	// files are rebuilt from their shards. The reader must be closed.
+	Cat(ctx context.Context, ci cid.Cid) (io.ReadCloser, error)
 
 	// Pin tracks a Cid with the given replication factor and a name for
 	// human-friendliness.
 	Pin(ci cid.Cid, replicationFactorMin, replicationFactorMax int, name string) error
+	// PinWithOptions is like Pin but takes all the PinOptions, including
+	// the pin priority and the tags used to allocate it.
+	PinWithOptions(ci cid.Cid, opts api.PinOptions) error
 	// Unpin untracks a Cid from cluster.
 	Unpin(ci cid.Cid) error
 
 	// Allocations returns the consensus state listing all tracked items
 	// and the peers that should be pinning them.
 	Allocations(filter api.PinType) ([]api.Pin, error)
+	// AllocationsStream is like Allocations but fetches the listing in
+	// pages and sends every item to the given channel as soon as it
+	// arrives. The channel is closed when done.
+	AllocationsStream(filter api.PinType, out chan<- api.Pin) error
 	// Allocation returns the current allocations for a given Cid.
 	Allocation(ci cid.Cid) (api.Pin, error)
 
@@ -72,6 +91,24 @@ type Client interface {
 	Status(ci cid.Cid, local bool) (api.GlobalPinInfo, error)
 	// StatusAll gathers Status() for all tracked items.
 	StatusAll(filter api.TrackerStatus, local bool) ([]api.GlobalPinInfo, error)
+	// StatusAllStream is like StatusAll but fetches the status in pages
+	// and sends every item to the given channel as soon as it arrives.
+	// The channel is closed when done.
+	StatusAllStream(filter api.TrackerStatus, local bool, out chan<- api.GlobalPinInfo) error
+	// StatusAllIter is like StatusAll but returns an iterator which
+	// fetches the status in pages of the given size as it advances.
+	StatusAllIter(filter api.TrackerStatus, local bool, pageSize int) *StatusIterator
+
+	// Operations lists the pin and unpin operations which are queued,
+	// in progress or have failed. If local is true, only the operations
+	// of the current peer are listed.
+	Operations(local bool) ([]api.Operation, error)
+	// CancelOperation cancels a queued or in-progress operation for a
+	// Cid. If local is true, only the current peer is affected.
+	CancelOperation(ci cid.Cid, local bool) error
+	// SetOperationPriority changes the priority of a queued operation
+	// for a Cid. Operations with higher priority are processed first.
+	SetOperationPriority(ci cid.Cid, priority int, local bool) error
 
 	// Sync makes sure the state of a Cid corresponds to the state reported
 	// by the ipfs daemon, and returns it. If local is true, this operation
@@ -104,9 +141,34 @@ type Client interface {
 	// serialized version, strings instead of pids, is returned
 	GetConnectGraph() (api.ConnectGraphSerial, error)
 
+	// PinsHealth returns a report on the replication health of the
+	// pinset computed by the cluster leader. At most limit unhealthy
+	// pins are listed, or all of them when limit is 0.
+	PinsHealth(limit int) (api.PinsHealthReport, error)
+
+	// Alerts returns the alerts which are active in the peer, such as
+	// informer metrics crossing their thresholds or an unreachable
+	// IPFS daemon.
+	Alerts() ([]api.Alert, error)
+	// Rebalance asks the cluster leader to move allocations from the
+	// most loaded to the least loaded peers. With dryRun, the moves
+	// are only planned.
+	Rebalance(dryRun bool) (api.RebalanceReport, error)
+
+	// Events returns a channel receiving the events produced by the
+	// peer which match the filter. With follow, events are sent as they
+	// happen until the context is cancelled. Otherwise, only the most
+	// recent events are sent. The channel is closed when done.
+	Events(ctx context.Context, filter api.EventFilter, follow bool) (<-chan api.Event, error)
+
 	// Metrics returns a map with the latest metrics of matching name
 	// for the current cluster peers.
 	Metrics(name string) ([]api.Metric, error)
+
+	// MetricsHistory returns the recent metrics of matching name, with
+	// their timestamps and some aggregates, for the given peer or for
+	// every peer when pid is empty.
+	MetricsHistory(name string, pid peer.ID) ([]api.MetricHistory, error)
 }
 
 // Config allows to configure the parameters to connect
@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"os"
 	"path"
@@ -61,19 +63,54 @@ func (c *defaultClient) PeerRm(id peer.ID) error {
 	return c.do("DELETE", fmt.Sprintf("/peers/%s", id.Pretty()), nil, nil, nil)
 }
 
+// PeerDrain marks a peer as draining: it gets no new allocations and its
+// pins are gradually moved to other peers.
+func (c *defaultClient) PeerDrain(id peer.ID) error {
+	return c.do("POST", fmt.Sprintf("/peers/%s/drain", id.Pretty()), nil, nil, nil)
+}
+
+// PeerUndrain clears the draining mark of a peer.
+func (c *defaultClient) PeerUndrain(id peer.ID) error {
+	return c.do("DELETE", fmt.Sprintf("/peers/%s/drain", id.Pretty()), nil, nil, nil)
+}
+
+// DrainStatus reports how many pins remain allocated to every draining
+// peer.
+func (c *defaultClient) DrainStatus() ([]api.DrainInfo, error) {
+	var infosS []api.DrainInfoSerial
+	err := c.do("GET", "/peers/drain", nil, nil, &infosS)
+	infos := make([]api.DrainInfo, len(infosS), len(infosS))
+	for i, dis := range infosS {
+		infos[i] = dis.ToDrainInfo()
+	}
+	return infos, err
+}
+
 // Pin tracks a Cid with the given replication factor and a name for
 // human-friendliness.
 func (c *defaultClient) Pin(ci cid.Cid, replicationFactorMin, replicationFactorMax int, name string) error {
-	escName := url.QueryEscape(name)
+	return c.PinWithOptions(ci, api.PinOptions{
+		ReplicationFactorMin: replicationFactorMin,
+		ReplicationFactorMax: replicationFactorMax,
+		Name:                 name,
+	})
+}
+
+// PinWithOptions is like Pin but takes all the PinOptions, including the
+// pin priority and the tags used to allocate it.
+func (c *defaultClient) PinWithOptions(ci cid.Cid, opts api.PinOptions) error {
+	query := url.Values{}
+	query.Set("replication-min", fmt.Sprintf("%d", opts.ReplicationFactorMin))
+	query.Set("replication-max", fmt.Sprintf("%d", opts.ReplicationFactorMax))
+	query.Set("name", opts.Name)
+	query.Set("priority", opts.Priority.String())
+	api.AddTagsToQuery(query, opts)
+	if opts.Public {
+		query.Set("public", "true")
+	}
 	err := c.do(
 		"POST",
-		fmt.Sprintf(
-			"/pins/%s?replication-min=%d&replication-max=%d&name=%s",
-			ci.String(),
-			replicationFactorMin,
-			replicationFactorMax,
-			escName,
-		),
+		fmt.Sprintf("/pins/%s?%s", ci.String(), query.Encode()),
 		nil,
 		nil,
 		nil,
@@ -91,6 +128,44 @@ func (c *defaultClient) Unpin(ci cid.Cid) error {
 func (c *defaultClient) Allocations(filter api.PinType) ([]api.Pin, error) {
 	var pins []api.PinSerial
 
+	f := url.QueryEscape(pinTypeFilterString(filter))
+	err := c.do("GET", fmt.Sprintf("/allocations?filter=%s", f), nil, nil, &pins)
+	result := make([]api.Pin, len(pins))
+	for i, p := range pins {
+		result[i] = p.ToPin()
+	}
+	return result, err
+}
+
+// AllocationsStream is like Allocations but fetches the listing in pages
+// and sends every item to the given channel as soon as it arrives. The
+// channel is closed when done.
+func (c *defaultClient) AllocationsStream(filter api.PinType, out chan<- api.Pin) error {
+	defer close(out)
+
+	handler := func(dec *json.Decoder) error {
+		var obj api.PinSerial
+		err := dec.Decode(&obj)
+		if err != nil {
+			return err
+		}
+		out <- obj.ToPin()
+		return nil
+	}
+
+	f := url.QueryEscape(pinTypeFilterString(filter))
+	return c.doStream(
+		"GET",
+		fmt.Sprintf("/allocations?filter=%s&stream=true", f),
+		nil,
+		nil,
+		handler,
+	)
+}
+
+// pinTypeFilterString returns the value for the "filter" parameter
+// in allocations requests.
+func pinTypeFilterString(filter api.PinType) string {
 	types := []api.PinType{
 		api.DataType,
 		api.MetaType,
@@ -109,14 +184,7 @@ func (c *defaultClient) Allocations(filter api.PinType) ([]api.Pin, error) {
 			}
 		}
 	}
-
-	f := url.QueryEscape(strings.Join(strFilter, ","))
-	err := c.do("GET", fmt.Sprintf("/allocations?filter=%s", f), nil, nil, &pins)
-	result := make([]api.Pin, len(pins))
-	for i, p := range pins {
-		result[i] = p.ToPin()
-	}
-	return result, err
+	return strings.Join(strFilter, ",")
 }
 
 // Allocation returns the current allocations for a given Cid.
@@ -143,15 +211,12 @@ func (c *defaultClient) Status(ci cid.Cid, local bool) (api.GlobalPinInfo, error
 func (c *defaultClient) StatusAll(filter api.TrackerStatus, local bool) ([]api.GlobalPinInfo, error) {
 	var gpis []api.GlobalPinInfoSerial
 
-	filterStr := ""
-	if filter != api.TrackerStatusUndefined { // undefined filter means "all"
-		filterStr = filter.String()
-		if filterStr == "" {
-			return nil, errors.New("invalid filter value")
-		}
+	filterStr, err := trackerStatusFilterString(filter)
+	if err != nil {
+		return nil, err
 	}
 
-	err := c.do("GET", fmt.Sprintf("/pins?local=%t&filter=%s", local, url.QueryEscape(filterStr)), nil, nil, &gpis)
+	err = c.do("GET", fmt.Sprintf("/pins?local=%t&filter=%s", local, url.QueryEscape(filterStr)), nil, nil, &gpis)
 	result := make([]api.GlobalPinInfo, len(gpis))
 	for i, p := range gpis {
 		result[i] = p.ToGlobalPinInfo()
@@ -159,6 +224,188 @@ func (c *defaultClient) StatusAll(filter api.TrackerStatus, local bool) ([]api.G
 	return result, err
 }
 
+// StatusAllStream is like StatusAll but fetches the status in pages and
+// sends every item to the given channel as soon as it arrives, so that
+// large pinsets can be processed incrementally. The channel is closed
+// when done.
+func (c *defaultClient) StatusAllStream(filter api.TrackerStatus, local bool, out chan<- api.GlobalPinInfo) error {
+	defer close(out)
+
+	filterStr, err := trackerStatusFilterString(filter)
+	if err != nil {
+		return err
+	}
+
+	handler := func(dec *json.Decoder) error {
+		var obj api.GlobalPinInfoSerial
+		err := dec.Decode(&obj)
+		if err != nil {
+			return err
+		}
+		out <- obj.ToGlobalPinInfo()
+		return nil
+	}
+
+	return c.doStream(
+		"GET",
+		fmt.Sprintf("/pins?local=%t&filter=%s&stream=true", local, url.QueryEscape(filterStr)),
+		nil,
+		nil,
+		handler,
+	)
+}
+
+// statusPageSize is the number of items fetched at once by the
+// iterators returned by StatusAllIter when no page size is given.
+const statusPageSize = 500
+
+// StatusIterator walks over the status of all tracked items, fetching them
+// from the API one page at a time as it advances. It is obtained with
+// StatusAllIter:
+//
+//	it := client.StatusAllIter(0, false, 0)
+//	for it.Next() {
+//		gpi := it.GlobalPinInfo()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type StatusIterator struct {
+	c      *defaultClient
+	path   string
+	cursor string
+	done   bool
+	items  []api.GlobalPinInfo
+	cur    api.GlobalPinInfo
+	err    error
+}
+
+// StatusAllIter is like StatusAll but returns an iterator which fetches
+// the status in pages of the given size (or a default size when 0), so
+// that large pinsets can be walked without holding them in memory.
+func (c *defaultClient) StatusAllIter(filter api.TrackerStatus, local bool, pageSize int) *StatusIterator {
+	it := &StatusIterator{c: c}
+
+	filterStr, err := trackerStatusFilterString(filter)
+	if err != nil {
+		it.err = err
+		return it
+	}
+	if pageSize <= 0 {
+		pageSize = statusPageSize
+	}
+
+	it.path = fmt.Sprintf(
+		"/pins?local=%t&filter=%s&limit=%d",
+		local,
+		url.QueryEscape(filterStr),
+		pageSize,
+	)
+	return it
+}
+
+// Next advances the iterator to the next item, fetching a new page if
+// needed. It returns false when all the items have been walked or when
+// an error happened, which is then returned by Err().
+func (it *StatusIterator) Next() bool {
+	for len(it.items) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+		it.fetch()
+	}
+	it.cur = it.items[0]
+	it.items = it.items[1:]
+	return true
+}
+
+// GlobalPinInfo returns the item the iterator is at.
+func (it *StatusIterator) GlobalPinInfo() api.GlobalPinInfo {
+	return it.cur
+}
+
+// Err returns the error which stopped the iterator, if any.
+func (it *StatusIterator) Err() error {
+	return it.err
+}
+
+// fetch requests the page after the current cursor. The API sends the
+// cursor for the following page in the X-Next-Cursor header, which is
+// missing in the last one. Pages may be shorter than the limit, or even
+// empty, when a filter is used.
+func (it *StatusIterator) fetch() {
+	resp, err := it.c.doRequest(
+		"GET",
+		it.path+"&cursor="+url.QueryEscape(it.cursor),
+		nil,
+		nil,
+	)
+	if err != nil {
+		it.err = &api.Error{Code: 0, Message: err.Error()}
+		return
+	}
+	next := resp.Header.Get("X-Next-Cursor")
+
+	var gpis []api.GlobalPinInfoSerial
+	err = it.c.handleResponse(resp, &gpis)
+	if err != nil {
+		it.err = err
+		return
+	}
+
+	it.items = make([]api.GlobalPinInfo, len(gpis))
+	for i, gpi := range gpis {
+		it.items[i] = gpi.ToGlobalPinInfo()
+	}
+	it.cursor = next
+	it.done = next == ""
+}
+
+// trackerStatusFilterString returns the value for the "filter" parameter
+// in status requests.
+func trackerStatusFilterString(filter api.TrackerStatus) (string, error) {
+	if filter == api.TrackerStatusUndefined { // undefined filter means "all"
+		return "", nil
+	}
+	filterStr := filter.String()
+	if filterStr == "" {
+		return "", errors.New("invalid filter value")
+	}
+	return filterStr, nil
+}
+
+// Operations lists the pin and unpin operations which are queued, in
+// progress or have failed. If local is true, only the operations of the
+// current peer are listed.
+func (c *defaultClient) Operations(local bool) ([]api.Operation, error) {
+	var ops []api.OperationSerial
+	err := c.do("GET", fmt.Sprintf("/operations?local=%t", local), nil, nil, &ops)
+	result := make([]api.Operation, len(ops))
+	for i, op := range ops {
+		result[i] = op.ToOperation()
+	}
+	return result, err
+}
+
+// CancelOperation cancels a queued or in-progress operation for a Cid.
+// If local is true, only the current peer is affected.
+func (c *defaultClient) CancelOperation(ci cid.Cid, local bool) error {
+	return c.do("DELETE", fmt.Sprintf("/operations/%s?local=%t", ci.String(), local), nil, nil, nil)
+}
+
+// SetOperationPriority changes the priority of a queued operation for a
+// Cid. Operations with higher priority are processed first.
+func (c *defaultClient) SetOperationPriority(ci cid.Cid, priority int, local bool) error {
+	return c.do(
+		"POST",
+		fmt.Sprintf("/operations/%s/priority?priority=%d&local=%t", ci.String(), priority, local),
+		nil,
+		nil,
+		nil,
+	)
+}
+
 // Sync makes sure the state of a Cid corresponds to the state reported by
 // the ipfs daemon, and returns it. If local is true, this operation only
 // happens on the current peer, otherwise it happens on every cluster peer.
@@ -219,6 +466,97 @@ func (c *defaultClient) GetConnectGraph() (api.ConnectGraphSerial, error) {
 	return graphS, err
 }
 
+// PinsHealth returns a report on the replication health of the pinset
+// computed by the cluster leader. At most limit unhealthy pins are listed,
+// or all of them when limit is 0.
+func (c *defaultClient) PinsHealth(limit int) (api.PinsHealthReport, error) {
+	var reportS api.PinsHealthReportSerial
+	err := c.do("GET", fmt.Sprintf("/health/pins?limit=%d", limit), nil, nil, &reportS)
+	return reportS.ToPinsHealthReport(), err
+}
+
+// Alerts returns the alerts which are active in the peer, such as informer
+// metrics crossing their thresholds or an unreachable IPFS daemon.
+func (c *defaultClient) Alerts() ([]api.Alert, error) {
+	var alertsS []api.AlertSerial
+	err := c.do("GET", "/alerts", nil, nil, &alertsS)
+	alerts := make([]api.Alert, len(alertsS), len(alertsS))
+	for i, as := range alertsS {
+		alerts[i] = as.ToAlert()
+	}
+	return alerts, err
+}
+
+// Events returns a channel receiving the events produced by the peer which
+// match the filter. With follow, events are sent as they happen until the
+// context is cancelled. Otherwise, only the most recent events are sent.
+// The channel is closed when done.
+func (c *defaultClient) Events(ctx context.Context, filter api.EventFilter, follow bool) (<-chan api.Event, error) {
+	query := url.Values{}
+	query.Set("follow", fmt.Sprintf("%t", follow))
+	if len(filter.Types) > 0 {
+		strTypes := make([]string, len(filter.Types))
+		for i, t := range filter.Types {
+			strTypes[i] = string(t)
+		}
+		query.Set("type", strings.Join(strTypes, ","))
+	}
+	if filter.Cid.Defined() {
+		query.Set("cid", filter.Cid.String())
+	}
+	if filter.UID != "" {
+		query.Set("uid", filter.UID)
+	}
+
+	resp, err := c.doRequestContext(ctx, "GET", "/events?"+query.Encode(), nil, nil)
+	if err != nil {
+		return nil, &api.Error{Code: 0, Message: err.Error()}
+	}
+	if resp.StatusCode != http.StatusOK {
+		err := c.handleResponse(resp, nil)
+		if err == nil {
+			err = &api.Error{
+				Code:    resp.StatusCode,
+				Message: "expected streaming response with code 200",
+			}
+		}
+		return nil, err
+	}
+
+	out := make(chan api.Event, 100)
+	go func() {
+		defer close(out)
+		defer resp.Body.Close()
+
+		dec := json.NewDecoder(resp.Body)
+		for {
+			var evs api.EventSerial
+			err := dec.Decode(&evs)
+			if err != nil {
+				if err != io.EOF && ctx.Err() == nil {
+					logger.Error(err)
+				}
+				return
+			}
+			select {
+			case out <- evs.ToEvent():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Rebalance asks the cluster leader to move allocations from the most
+// loaded to the least loaded peers and returns the planned moves. With
+// dryRun, nothing is moved.
+func (c *defaultClient) Rebalance(dryRun bool) (api.RebalanceReport, error) {
+	var reportS api.RebalanceReportSerial
+	err := c.do("POST", fmt.Sprintf("/rebalance?dry-run=%t", dryRun), nil, nil, &reportS)
+	return reportS.ToRebalanceReport(), err
+}
+
 // Metrics returns a map with the latest valid metrics of the given name
 // for the current cluster peers.
 func (c *defaultClient) Metrics(name string) ([]api.Metric, error) {
@@ -230,6 +568,27 @@ func (c *defaultClient) Metrics(name string) ([]api.Metric, error) {
 	return metrics, err
 }
 
+// MetricsHistory returns the recent metrics of matching name, with their
+// timestamps and some aggregates, for the given peer or for every peer
+// when pid is empty.
+func (c *defaultClient) MetricsHistory(name string, pid peer.ID) ([]api.MetricHistory, error) {
+	if name == "" {
+		return nil, errors.New("bad metric name")
+	}
+	path := fmt.Sprintf("/monitor/metrics/%s/history", name)
+	if pid != "" {
+		path += "?peer=" + peer.IDB58Encode(pid)
+	}
+
+	var historyS []api.MetricHistorySerial
+	err := c.do("GET", path, nil, nil, &historyS)
+	history := make([]api.MetricHistory, len(historyS), len(historyS))
+	for i, hs := range historyS {
+		history[i] = hs.ToMetricHistory()
+	}
+	return history, err
+}
+
 // WaitFor is a utility function that allows for a caller to wait for a
 // paticular status for a CID (as defined by StatusFilterParams).
 // It returns the final status for that CID and an error, if there was.
@@ -470,3 +829,23 @@ func (c *defaultClient) AddMultiFile(
 	)
 	return err
 }
+
+// Cat returns the contents of a file added to the cluster. Sharded files
+// are rebuilt from their shards. The reader must be closed.
+func (c *defaultClient) Cat(ctx context.Context, ci cid.Cid) (io.ReadCloser, error) {
+	resp, err := c.doRequestContext(ctx, "GET", "/cat/"+ci.String(), nil, nil)
+	if err != nil {
+		return nil, &api.Error{Code: 0, Message: err.Error()}
+	}
+	if resp.StatusCode != http.StatusOK {
+		err := c.handleResponse(resp, nil)
+		if err == nil {
+			err = &api.Error{
+				Code:    resp.StatusCode,
+				Message: "expected a response with code 200",
+			}
+		}
+		return nil, err
+	}
+	return resp.Body, nil
+}
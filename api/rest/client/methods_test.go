@@ -2,6 +2,7 @@ package client
 
 import (
 	"context"
+	"io/ioutil"
 	"sync"
 	"testing"
 	"time"
@@ -131,6 +132,41 @@ func TestPeerRm(t *testing.T) {
 	testClients(t, api, testF)
 }
 
+func TestPeerDrain(t *testing.T) {
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		err := c.PeerDrain(test.TestPeerID2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = c.PeerUndrain(test.TestPeerID2)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestDrainStatus(t *testing.T) {
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		infos, err := c.DrainStatus()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(infos) != 1 || infos[0].Peer != test.TestPeerID2 || infos[0].Remaining != 2 {
+			t.Error("bad drain status")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
 func TestPin(t *testing.T) {
 	api := testAPI(t)
 	defer shutdown(api)
@@ -146,6 +182,30 @@ func TestPin(t *testing.T) {
 	testClients(t, api, testF)
 }
 
+func TestPinWithOptions(t *testing.T) {
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		ci, _ := cid.Decode(test.TestCid1)
+		opts := types.PinOptions{
+			ReplicationFactorMin: 6,
+			ReplicationFactorMax: 7,
+			Name:                 "hello there",
+			Priority:             types.PriorityHigh,
+			SpreadTag:            "region",
+			RequiredTags:         map[string]string{"tier": "ssd"},
+			ExcludedTags:         map[string]string{"rack": "r1"},
+		}
+		err := c.PinWithOptions(ci, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
 func TestUnpin(t *testing.T) {
 	api := testAPI(t)
 	defer shutdown(api)
@@ -178,6 +238,28 @@ func TestAllocations(t *testing.T) {
 	testClients(t, api, testF)
 }
 
+func TestAllocationsStream(t *testing.T) {
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		out := make(chan types.Pin, 10)
+		err := c.AllocationsStream(types.DataType|types.MetaType, out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var pins []types.Pin
+		for p := range out {
+			pins = append(pins, p)
+		}
+		if len(pins) != 3 {
+			t.Error("there should be three pins")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
 func TestAllocation(t *testing.T) {
 	api := testAPI(t)
 	defer shutdown(api)
@@ -263,6 +345,180 @@ func TestStatusAll(t *testing.T) {
 	testClients(t, api, testF)
 }
 
+func TestStatusAllIter(t *testing.T) {
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		collect := func(filter types.TrackerStatus, local bool) ([]types.GlobalPinInfo, error) {
+			var gpis []types.GlobalPinInfo
+			it := c.StatusAllIter(filter, local, 2)
+			for it.Next() {
+				gpis = append(gpis, it.GlobalPinInfo())
+			}
+			return gpis, it.Err()
+		}
+
+		pins, err := collect(0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pins) != 3 {
+			t.Fatal("there should be three pins")
+		}
+		seen := make(map[string]bool)
+		for _, gpi := range pins {
+			seen[gpi.Cid.String()] = true
+		}
+		if len(seen) != 3 {
+			t.Error("pins should not be repeated across pages")
+		}
+
+		pins, err = collect(0, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pins) != 2 {
+			t.Error("there should be two pins")
+		}
+
+		pins, err = collect(types.TrackerStatusPinning, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pins) != 1 {
+			t.Error("there should be one pin")
+		}
+
+		_, err = collect(1<<25, false)
+		if err == nil {
+			t.Error("expected an error")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestStatusAllStream(t *testing.T) {
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		collect := func(filter types.TrackerStatus, local bool) ([]types.GlobalPinInfo, error) {
+			out := make(chan types.GlobalPinInfo, 10)
+			var gpis []types.GlobalPinInfo
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for gpi := range out {
+					gpis = append(gpis, gpi)
+				}
+			}()
+			err := c.StatusAllStream(filter, local, out)
+			<-done
+			return gpis, err
+		}
+
+		pins, err := collect(0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pins) != 3 {
+			t.Error("there should be three pins")
+		}
+
+		pins, err = collect(0, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pins) != 2 {
+			t.Error("there should be two pins")
+		}
+
+		pins, err = collect(types.TrackerStatusPinning, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pins) != 1 {
+			t.Error("there should be one pin")
+		}
+
+		_, err = collect(1<<25, false)
+		if err == nil {
+			t.Error("expected an error")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestOperations(t *testing.T) {
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		ops, err := c.Operations(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ops) != 2 {
+			t.Fatal("expected two operations")
+		}
+
+		ops, err = c.Operations(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ops) != 2 {
+			t.Fatal("expected two operations")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestCancelOperation(t *testing.T) {
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		ci, _ := cid.Decode(test.TestCid1)
+		err := c.CancelOperation(ci, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ci2, _ := cid.Decode(test.TestCid2)
+		err = c.CancelOperation(ci2, true)
+		if err == nil {
+			t.Error("expected an error")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestSetOperationPriority(t *testing.T) {
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		ci, _ := cid.Decode(test.TestCid1)
+		err := c.SetOperationPriority(ci, 10, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ci2, _ := cid.Decode(test.TestCid2)
+		err = c.SetOperationPriority(ci2, 10, true)
+		if err == nil {
+			t.Error("expected an error")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
 func TestSync(t *testing.T) {
 	api := testAPI(t)
 	defer shutdown(api)
@@ -349,6 +605,165 @@ func TestGetConnectGraph(t *testing.T) {
 	testClients(t, api, testF)
 }
 
+func TestPinsHealth(t *testing.T) {
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		report, err := c.PinsHealth(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Total != 3 {
+			t.Error("bad total")
+		}
+		if len(report.Worst) != 1 || report.Worst[0].Cid.String() != test.TestCid1 {
+			t.Error("bad worst pins")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestAlerts(t *testing.T) {
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		alerts, err := c.Alerts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(alerts) != 1 || alerts[0].Peer != test.TestPeerID2 || alerts[0].Policy != "stop_allocating" {
+			t.Error("bad alerts")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestCat(t *testing.T) {
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		ci, _ := cid.Decode(test.TestCid4)
+		r, err := c.Cat(context.Background(), ci)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != test.TestCid4Data {
+			t.Errorf("unexpected data: %s", data)
+		}
+
+		ci, _ = cid.Decode(test.TestCid1)
+		_, err = c.Cat(context.Background(), ci)
+		if err == nil {
+			t.Error("expected an error when the blocks are missing")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestEvents(t *testing.T) {
+	api := testAPI(t)
+	defer shutdown(api)
+
+	c1, _ := cid.Decode(test.TestCid1)
+	api.HandleEvent(types.Event{Type: types.EventPinDone, Cid: c1, Peer: test.TestPeerID1})
+	api.HandleEvent(types.Event{Type: types.EventUidCreated, UID: "uid-test", Peer: test.TestPeerID1})
+
+	testF := func(t *testing.T, c Client) {
+		ch, err := c.Events(context.Background(), types.EventFilter{UID: "uid-test"}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var evs []types.Event
+		for ev := range ch {
+			evs = append(evs, ev)
+		}
+		if len(evs) != 1 || evs[0].Type != types.EventUidCreated || evs[0].Peer != test.TestPeerID1 {
+			t.Errorf("unexpected events: %+v", evs)
+		}
+
+		_, err = c.Events(context.Background(), types.EventFilter{Types: []types.EventType{"abc"}}, false)
+		if err == nil {
+			t.Error("expected an error with an unknown event type")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestEventsFollow(t *testing.T) {
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client, ci cid.Cid) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		filter := types.EventFilter{
+			Types: []types.EventType{types.EventPinError},
+			Cid:   ci,
+		}
+		ch, err := c.Events(ctx, filter, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		timeout := time.After(5 * time.Second)
+		for {
+			api.HandleEvent(types.Event{Type: types.EventPinDone, Cid: ci})
+			api.HandleEvent(types.Event{Type: types.EventPinError, Cid: ci})
+			select {
+			case ev := <-ch:
+				if ev.Type != types.EventPinError || !ev.Cid.Equals(ci) {
+					t.Errorf("unexpected event: %+v", ev)
+				}
+				cancel()
+				for range ch {
+				}
+				return
+			case <-time.After(100 * time.Millisecond):
+			case <-timeout:
+				t.Fatal("no event received")
+			}
+		}
+	}
+
+	c1, _ := cid.Decode(test.TestCid1)
+	c2, _ := cid.Decode(test.TestCid2)
+	t.Run("http", func(t *testing.T) { testF(t, testClientHTTP(t, api), c1) })
+	t.Run("libp2p", func(t *testing.T) { testF(t, testClientLibp2p(t, api), c2) })
+}
+
+func TestRebalance(t *testing.T) {
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		report, err := c.Rebalance(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !report.DryRun {
+			t.Error("expected a dry run")
+		}
+		if len(report.Moves) != 1 || report.Moves[0].To != test.TestPeerID2 {
+			t.Error("bad moves")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
 func TestMetrics(t *testing.T) {
 	api := testAPI(t)
 	defer shutdown(api)
@@ -367,6 +782,39 @@ func TestMetrics(t *testing.T) {
 	testClients(t, api, testF)
 }
 
+func TestMetricsHistory(t *testing.T) {
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		history, err := c.MetricsHistory("freespace", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history) != 1 || len(history[0].Samples) != 2 {
+			t.Fatal("expected the history of one peer")
+		}
+		if history[0].Peer != test.TestPeerID1 || history[0].Avg != 15 {
+			t.Errorf("unexpected history: %+v", history[0])
+		}
+
+		history, err = c.MetricsHistory("freespace", test.TestPeerID2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history) != 0 {
+			t.Error("history should be filtered by peer")
+		}
+
+		_, err = c.MetricsHistory("", "")
+		if err == nil {
+			t.Error("expected an error with an empty name")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
 type waitService struct {
 	l        sync.Mutex
 	pinStart time.Time
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"io/ioutil"
@@ -45,6 +46,17 @@ func (c *defaultClient) doRequest(
 	headers map[string]string,
 	body io.Reader,
 ) (*http.Response, error) {
+	return c.doRequestContext(c.ctx, method, path, headers, body)
+}
+
+// doRequestContext is like doRequest but the request is cancelled along
+// with the given context.
+func (c *defaultClient) doRequestContext(
+	ctx context.Context,
+	method, path string,
+	headers map[string]string,
+	body io.Reader,
+) (*http.Response, error) {
 
 	urlpath := c.net + "://" + c.hostname + "/" + strings.TrimPrefix(path, "/")
 	logger.Debugf("%s: %s", method, urlpath)
@@ -53,6 +65,7 @@ func (c *defaultClient) doRequest(
 	if err != nil {
 		return nil, err
 	}
+	r = r.WithContext(ctx)
 	if c.config.DisableKeepAlives {
 		r.Close = true
 	}
@@ -16,6 +16,7 @@ import (
 	"math/rand"
 	"net"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
@@ -24,6 +25,7 @@ import (
 	"github.com/rs/cors"
 
 	"github.com/elastos/Elastos.NET.Hive.Cluster/adder/adderutils"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/adder/sharding"
 	types "github.com/elastos/Elastos.NET.Hive.Cluster/api"
 
 	mux "github.com/gorilla/mux"
@@ -60,6 +62,21 @@ var (
 // Used by sendResponse to set the right status
 const autoStatus = -1
 
+// Number of items fetched from the cluster for every page of a streamed
+// listing when no limit is given.
+const streamPageSize = 500
+
+// Number of recent events kept to answer non-follow /events requests.
+const recentEventsSize = 100
+
+// Number of events buffered for every /events subscriber. Events are
+// dropped for subscribers which do not keep up.
+const eventsSubscriberBuffer = 256
+
+// EventsKeepAlive is the interval at which /events streams send a
+// keep-alive line when there is no activity.
+var EventsKeepAlive = 15 * time.Second
+
 // For making a random sharding ID
 var letterRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 
@@ -81,6 +98,10 @@ type API struct {
 	httpListener   net.Listener
 	libp2pListener net.Listener
 
+	eventsMux    sync.Mutex
+	eventSubs    map[chan types.Event]types.EventFilter
+	recentEvents []types.Event
+
 	shutdownLock sync.Mutex
 	shutdown     bool
 	wg           sync.WaitGroup
@@ -133,12 +154,13 @@ func NewAPIWithHost(cfg *Config, h host.Host) (*API, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	api := &API{
-		ctx:      ctx,
-		cancel:   cancel,
-		config:   cfg,
-		server:   s,
-		host:     h,
-		rpcReady: make(chan struct{}, 2),
+		ctx:       ctx,
+		cancel:    cancel,
+		config:    cfg,
+		server:    s,
+		host:      h,
+		rpcReady:  make(chan struct{}, 2),
+		eventSubs: make(map[chan types.Event]types.EventFilter),
 	}
 	api.addRoutes(router)
 
@@ -324,12 +346,36 @@ func (api *API) routes() []route {
 			"/peers/{peer}",
 			api.peerRemoveHandler,
 		},
+		{
+			"DrainStatus",
+			"GET",
+			"/peers/drain",
+			api.drainStatusHandler,
+		},
+		{
+			"PeerDrain",
+			"POST",
+			"/peers/{peer}/drain",
+			api.peerDrainHandler,
+		},
+		{
+			"PeerUndrain",
+			"DELETE",
+			"/peers/{peer}/drain",
+			api.peerUndrainHandler,
+		},
 		{
 			"Add",
 			"POST",
 			"/add",
 			api.addHandler,
 		},
+		{
+			"Cat",
+			"GET",
+			"/cat/{hash}",
+			api.catHandler,
+		},
 		{
 			"Allocations",
 			"GET",
@@ -390,18 +436,66 @@ func (api *API) routes() []route {
 			"/pins/{hash}/recover",
 			api.recoverHandler,
 		},
+		{
+			"Operations",
+			"GET",
+			"/operations",
+			api.operationsHandler,
+		},
+		{
+			"CancelOperation",
+			"DELETE",
+			"/operations/{hash}",
+			api.cancelOperationHandler,
+		},
+		{
+			"OperationPriority",
+			"POST",
+			"/operations/{hash}/priority",
+			api.operationPriorityHandler,
+		},
 		{
 			"ConnectionGraph",
 			"GET",
 			"/health/graph",
 			api.graphHandler,
 		},
+		{
+			"PinsHealth",
+			"GET",
+			"/health/pins",
+			api.pinsHealthHandler,
+		},
+		{
+			"Alerts",
+			"GET",
+			"/alerts",
+			api.alertsHandler,
+		},
+		{
+			"Rebalance",
+			"POST",
+			"/rebalance",
+			api.rebalanceHandler,
+		},
 		{
 			"Metrics",
 			"GET",
 			"/monitor/metrics/{name}",
 			api.metricsHandler,
 		},
+		{
+			"MetricsHistory",
+			"GET",
+			"/monitor/metrics/{name}/history",
+			api.metricsHistoryHandler,
+		},
+		{
+			"Events",
+			"GET",
+			"/events",
+			api.eventsHandler,
+		},
 	}
 }
 
@@ -491,6 +585,56 @@ func (api *API) SetClient(c *rpc.Client) {
 	api.rpcReady <- struct{}{}
 }
 
+// HandleEvent receives the events produced by this peer and forwards them
+// to the /events subscribers. It implements ipfscluster.EventSink.
+func (api *API) HandleEvent(ev types.Event) {
+	api.eventsMux.Lock()
+	defer api.eventsMux.Unlock()
+
+	api.recentEvents = append(api.recentEvents, ev)
+	if len(api.recentEvents) > recentEventsSize {
+		api.recentEvents = api.recentEvents[len(api.recentEvents)-recentEventsSize:]
+	}
+
+	for ch, filter := range api.eventSubs {
+		if !filter.Match(ev) {
+			continue
+		}
+		select {
+		case ch <- ev:
+		default:
+			logger.Warningf("events subscriber is not keeping up. Dropping %s event", ev.Type)
+		}
+	}
+}
+
+func (api *API) subscribeEvents(filter types.EventFilter) chan types.Event {
+	ch := make(chan types.Event, eventsSubscriberBuffer)
+	api.eventsMux.Lock()
+	api.eventSubs[ch] = filter
+	api.eventsMux.Unlock()
+	return ch
+}
+
+func (api *API) unsubscribeEvents(ch chan types.Event) {
+	api.eventsMux.Lock()
+	delete(api.eventSubs, ch)
+	api.eventsMux.Unlock()
+}
+
+func (api *API) matchingRecentEvents(filter types.EventFilter) []types.Event {
+	api.eventsMux.Lock()
+	defer api.eventsMux.Unlock()
+
+	var evs []types.Event
+	for _, ev := range api.recentEvents {
+		if filter.Match(ev) {
+			evs = append(evs, ev)
+		}
+	}
+	return evs
+}
+
 func (api *API) idHandler(w http.ResponseWriter, r *http.Request) {
 	idSerial := types.IDSerial{}
 	err := api.rpcClient.CallContext(
@@ -532,6 +676,57 @@ func (api *API) graphHandler(w http.ResponseWriter, r *http.Request) {
 	api.sendResponse(w, autoStatus, err, graph)
 }
 
+func (api *API) pinsHealthHandler(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 0 {
+			api.sendResponse(w, http.StatusBadRequest, errors.New("invalid limit value"), nil)
+			return
+		}
+	}
+
+	var report types.PinsHealthReportSerial
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PinsHealth",
+		limit,
+		&report,
+	)
+	api.sendResponse(w, autoStatus, err, report)
+}
+
+func (api *API) alertsHandler(w http.ResponseWriter, r *http.Request) {
+	var alerts []types.AlertSerial
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"Alerts",
+		struct{}{},
+		&alerts,
+	)
+	api.sendResponse(w, autoStatus, err, alerts)
+}
+
+func (api *API) rebalanceHandler(w http.ResponseWriter, r *http.Request) {
+	dryRun := r.URL.Query().Get("dry-run") == "true"
+
+	var report types.RebalanceReportSerial
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"Rebalance",
+		dryRun,
+		&report,
+	)
+	api.sendResponse(w, autoStatus, err, report)
+}
+
 func (api *API) metricsHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["name"]
@@ -548,6 +743,171 @@ func (api *API) metricsHandler(w http.ResponseWriter, r *http.Request) {
 	api.sendResponse(w, autoStatus, err, metrics)
 }
 
+// metricsHistoryHandler returns the recent metrics of the given name
+// for every peer, or only for the one given in the "peer" query
+// parameter.
+func (api *API) metricsHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	var pid peer.ID
+	if p := r.URL.Query().Get("peer"); p != "" {
+		var err error
+		pid, err = peer.IDB58Decode(p)
+		if err != nil {
+			api.sendResponse(w, http.StatusBadRequest, errors.New("error decoding peer: "+err.Error()), nil)
+			return
+		}
+	}
+
+	var history []types.MetricHistorySerial
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PeerMonitorMetricsHistory",
+		name,
+		&history,
+	)
+	if err != nil || pid == "" {
+		api.sendResponse(w, autoStatus, err, history)
+		return
+	}
+
+	filtered := []types.MetricHistorySerial{}
+	for _, h := range history {
+		if h.Peer == peer.IDB58Encode(pid) {
+			filtered = append(filtered, h)
+		}
+	}
+	api.sendResponse(w, autoStatus, nil, filtered)
+}
+
+// eventsHandler streams the events produced by this peer as they happen,
+// or the most recent ones when follow=false. Events are sent as
+// newline-delimited JSON, or as server-sent events when requested with
+// format=sse or an "Accept: text/event-stream" header.
+func (api *API) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	queryValues := r.URL.Query()
+	filter, err := parseEventFilter(queryValues)
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+	follow := queryValues.Get("follow") != "false"
+	sse := queryValues.Get("format") == "sse" ||
+		strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+
+	var ch chan types.Event
+	if follow {
+		ch = api.subscribeEvents(filter)
+		defer api.unsubscribeEvents(ch)
+	}
+
+	for header, values := range api.config.Headers {
+		for _, val := range values {
+			w.Header().Add(header, val)
+		}
+	}
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, canFlush := w.(http.Flusher)
+	flush := func() {
+		if canFlush {
+			flusher.Flush()
+		}
+	}
+	send := func(ev types.Event) error {
+		body, err := json.Marshal(ev.ToSerial())
+		if err != nil {
+			return err
+		}
+		if sse {
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, body)
+		} else {
+			_, err = fmt.Fprintf(w, "%s\n", body)
+		}
+		return err
+	}
+
+	if !follow {
+		for _, ev := range api.matchingRecentEvents(filter) {
+			if err := send(ev); err != nil {
+				logger.Error(err)
+				return
+			}
+		}
+		return
+	}
+
+	flush()
+	keepAlive := time.NewTicker(EventsKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-api.ctx.Done():
+			return
+		case <-keepAlive.C:
+			// a comment line for SSE clients, an empty
+			// line for JSON decoders.
+			if sse {
+				_, err = fmt.Fprint(w, ":\n\n")
+			} else {
+				_, err = fmt.Fprint(w, "\n")
+			}
+			if err != nil {
+				return
+			}
+			flush()
+		case ev := <-ch:
+			if err := send(ev); err != nil {
+				logger.Debug(err)
+				return
+			}
+			flush()
+		}
+	}
+}
+
+// parseEventFilter reads the type, cid and uid parameters of an /events
+// request. Several types can be given separated by commas.
+func parseEventFilter(queryValues url.Values) (types.EventFilter, error) {
+	var filter types.EventFilter
+
+	if typesStr := queryValues.Get("type"); typesStr != "" {
+		known := make(map[types.EventType]struct{})
+		for _, t := range types.EventTypes() {
+			known[t] = struct{}{}
+		}
+		for _, t := range strings.Split(typesStr, ",") {
+			et := types.EventType(strings.TrimSpace(t))
+			if _, ok := known[et]; !ok {
+				return filter, fmt.Errorf("unknown event type: %s", t)
+			}
+			filter.Types = append(filter.Types, et)
+		}
+	}
+
+	if cidStr := queryValues.Get("cid"); cidStr != "" {
+		c, err := cid.Decode(cidStr)
+		if err != nil {
+			return filter, fmt.Errorf("error decoding cid: %s", err)
+		}
+		filter.Cid = c
+	}
+
+	filter.UID = queryValues.Get("uid")
+	return filter, nil
+}
+
 func (api *API) addHandler(w http.ResponseWriter, r *http.Request) {
 	reader, err := r.MultipartReader()
 	if err != nil {
@@ -576,6 +936,28 @@ func (api *API) addHandler(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// catHandler streams the contents of a file. Sharded files are rebuilt
+// from the IPFS daemons holding their shards. Range requests are
+// supported.
+func (api *API) catHandler(w http.ResponseWriter, r *http.Request) {
+	c, err := cid.Decode(mux.Vars(r)["hash"])
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, errors.New("error decoding Cid: "+err.Error()), nil)
+		return
+	}
+
+	reader, err := sharding.NewReader(r.Context(), api.rpcClient, c)
+	if err != nil {
+		api.sendResponse(w, autoStatus, err, nil)
+		return
+	}
+	defer reader.Close()
+
+	api.setHeaders(w)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", time.Time{}, reader)
+}
+
 func (api *API) peerListHandler(w http.ResponseWriter, r *http.Request) {
 	var peersSerial []types.IDSerial
 	err := api.rpcClient.CallContext(
@@ -632,11 +1014,73 @@ func (api *API) peerRemoveHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (api *API) peerDrainHandler(w http.ResponseWriter, r *http.Request) {
+	if p := api.parsePidOrError(w, r); p != "" {
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"PeerDrain",
+			p,
+			&struct{}{},
+		)
+		api.sendResponse(w, autoStatus, err, nil)
+	}
+}
+
+func (api *API) peerUndrainHandler(w http.ResponseWriter, r *http.Request) {
+	if p := api.parsePidOrError(w, r); p != "" {
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"PeerUndrain",
+			p,
+			&struct{}{},
+		)
+		api.sendResponse(w, autoStatus, err, nil)
+	}
+}
+
+func (api *API) drainStatusHandler(w http.ResponseWriter, r *http.Request) {
+	var infos []types.DrainInfoSerial
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"DrainStatus",
+		struct{}{},
+		&infos,
+	)
+	api.sendResponse(w, autoStatus, err, infos)
+}
+
 func (api *API) pinHandler(w http.ResponseWriter, r *http.Request) {
 	if ps := api.parseCidOrError(w, r); ps.Cid != "" {
 		logger.Debugf("rest api pinHandler: %s", ps.Cid)
 
-		err := api.rpcClient.CallContext(
+		priority, err := types.PinPriorityFromString(r.URL.Query().Get("priority"))
+		if err != nil {
+			api.sendResponse(w, http.StatusBadRequest, err, nil)
+			return
+		}
+		ps.Priority = priority
+
+		err = types.PinOptionsTagsFromQuery(r.URL.Query(), &ps.PinOptions)
+		if err != nil {
+			api.sendResponse(w, http.StatusBadRequest, err, nil)
+			return
+		}
+
+		if public := r.URL.Query().Get("public"); public != "" {
+			ps.Public, err = strconv.ParseBool(public)
+			if err != nil {
+				api.sendResponse(w, http.StatusBadRequest, errors.New("parameter public invalid"), nil)
+				return
+			}
+		}
+
+		err = api.rpcClient.CallContext(
 			r.Context(),
 			"",
 			"Cluster",
@@ -672,21 +1116,60 @@ func (api *API) allocationsHandler(w http.ResponseWriter, r *http.Request) {
 	for _, f := range strings.Split(filterStr, ",") {
 		filter |= types.PinTypeFromString(f)
 	}
-	var pins []types.PinSerial
-	err := api.rpcClient.CallContext(
-		r.Context(),
-		"",
-		"Cluster",
-		"Pins",
-		struct{}{},
-		&pins,
-	)
-	outPins := make([]types.PinSerial, 0)
-	for _, pinS := range pins {
-		if uint64(filter)&pinS.Type > 0 {
-			// add this pin to output
-			outPins = append(outPins, pinS)
+
+	page, err := parseListPage(queryValues)
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	fetch := func(page types.ListPage) ([]interface{}, string, error) {
+		var pins []types.PinSerial
+		var err error
+		if page == (types.ListPage{}) {
+			err = api.rpcClient.CallContext(
+				r.Context(),
+				"",
+				"Cluster",
+				"Pins",
+				struct{}{},
+				&pins,
+			)
+		} else {
+			err = api.rpcClient.CallContext(
+				r.Context(),
+				"",
+				"Cluster",
+				"PinsPage",
+				page,
+				&pins,
+			)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		next := ""
+		if n := len(pins); n > 0 && n == page.Limit {
+			next = pins[n-1].Cid
+		}
+		outPins := make([]interface{}, 0)
+		for _, pinS := range pins {
+			if uint64(filter)&pinS.Type > 0 {
+				// add this pin to output
+				outPins = append(outPins, pinS)
+			}
 		}
+		return outPins, next, nil
+	}
+
+	if queryValues.Get("stream") == "true" {
+		api.streamPages(w, page, fetch)
+		return
+	}
+
+	outPins, next, err := fetch(page)
+	if next != "" {
+		w.Header().Set("X-Next-Cursor", next)
 	}
 	api.sendResponse(w, autoStatus, err, outPins)
 }
@@ -738,8 +1221,6 @@ func (api *API) statusAllHandler(w http.ResponseWriter, r *http.Request) {
 	queryValues := r.URL.Query()
 	local := queryValues.Get("local")
 
-	var globalPinInfos []types.GlobalPinInfoSerial
-
 	filterStr := queryValues.Get("filter")
 	filter := types.TrackerStatusFromString(filterStr)
 	if filter == types.TrackerStatusUndefined && filterStr != "" {
@@ -747,39 +1228,82 @@ func (api *API) statusAllHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if local == "true" {
-		var pinInfos []types.PinInfoSerial
+	page, err := parseListPage(queryValues)
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
 
-		err := api.rpcClient.CallContext(
-			r.Context(),
-			"",
-			"Cluster",
-			"StatusAllLocal",
-			struct{}{},
-			&pinInfos,
-		)
-		if err != nil {
-			api.sendResponse(w, autoStatus, err, nil)
-			return
+	fetch := func(page types.ListPage) ([]interface{}, string, error) {
+		var globalPinInfos []types.GlobalPinInfoSerial
+
+		// Requests without pagination obtain the full, unsorted
+		// listing in a single call.
+		var in interface{} = page
+		method := "StatusAllPage"
+		if local == "true" {
+			method = "StatusAllLocalPage"
 		}
-		globalPinInfos = pinInfosToGlobal(pinInfos)
-	} else {
-		err := api.rpcClient.CallContext(
-			r.Context(),
-			"",
-			"Cluster",
-			"StatusAll",
-			struct{}{},
-			&globalPinInfos,
-		)
-		if err != nil {
-			api.sendResponse(w, autoStatus, err, nil)
-			return
+		if page == (types.ListPage{}) {
+			in = struct{}{}
+			method = strings.TrimSuffix(method, "Page")
+		}
+
+		if local == "true" {
+			var pinInfos []types.PinInfoSerial
+
+			err := api.rpcClient.CallContext(
+				r.Context(),
+				"",
+				"Cluster",
+				method,
+				in,
+				&pinInfos,
+			)
+			if err != nil {
+				return nil, "", err
+			}
+			globalPinInfos = pinInfosToGlobal(pinInfos)
+		} else {
+			err := api.rpcClient.CallContext(
+				r.Context(),
+				"",
+				"Cluster",
+				method,
+				in,
+				&globalPinInfos,
+			)
+			if err != nil {
+				return nil, "", err
+			}
 		}
+
+		next := ""
+		if n := len(globalPinInfos); n > 0 && n == page.Limit {
+			next = globalPinInfos[n-1].Cid
+		}
+
+		globalPinInfos = filterGlobalPinInfos(globalPinInfos, filter)
+		items := make([]interface{}, len(globalPinInfos), len(globalPinInfos))
+		for i, gpi := range globalPinInfos {
+			items[i] = gpi
+		}
+		return items, next, nil
 	}
 
-	globalPinInfos = filterGlobalPinInfos(globalPinInfos, filter)
+	if queryValues.Get("stream") == "true" {
+		api.streamPages(w, page, fetch)
+		return
+	}
 
+	globalPinInfos, next, err := fetch(page)
+	if err != nil {
+		api.sendResponse(w, autoStatus, err, nil)
+		return
+	}
+	if next != "" {
+		w.Header().Set("X-Next-Cursor", next)
+	}
 	api.sendResponse(w, autoStatus, nil, globalPinInfos)
 }
 
@@ -924,6 +1448,95 @@ func (api *API) recoverHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (api *API) operationsHandler(w http.ResponseWriter, r *http.Request) {
+	queryValues := r.URL.Query()
+	method := "Operations"
+	if queryValues.Get("local") == "true" {
+		method = "OperationsLocal"
+	}
+
+	var ops []types.OperationSerial
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		method,
+		struct{}{},
+		&ops,
+	)
+	if err != nil {
+		api.sendResponse(w, autoStatus, err, nil)
+		return
+	}
+
+	typeFilter := queryValues.Get("type")
+	phaseFilter := queryValues.Get("phase")
+	outOps := make([]types.OperationSerial, 0)
+	for _, op := range ops {
+		if typeFilter != "" && op.Type != typeFilter {
+			continue
+		}
+		if phaseFilter != "" && op.Phase != phaseFilter {
+			continue
+		}
+		outOps = append(outOps, op)
+	}
+	api.sendResponse(w, autoStatus, nil, outOps)
+}
+
+func (api *API) cancelOperationHandler(w http.ResponseWriter, r *http.Request) {
+	if ps := api.parseCidOrError(w, r); ps.Cid != "" {
+		method := "CancelOperation"
+		if r.URL.Query().Get("local") == "true" {
+			method = "CancelOperationLocal"
+		}
+
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			method,
+			types.OperationSerial{Cid: ps.Cid},
+			&struct{}{},
+		)
+		if err != nil { // errors here are 404s
+			api.sendResponse(w, http.StatusNotFound, err, nil)
+			return
+		}
+		api.sendResponse(w, autoStatus, nil, nil)
+	}
+}
+
+func (api *API) operationPriorityHandler(w http.ResponseWriter, r *http.Request) {
+	if ps := api.parseCidOrError(w, r); ps.Cid != "" {
+		queryValues := r.URL.Query()
+		priority, err := strconv.Atoi(queryValues.Get("priority"))
+		if err != nil {
+			api.sendResponse(w, http.StatusBadRequest, errors.New("invalid priority value"), nil)
+			return
+		}
+
+		method := "SetOperationPriority"
+		if queryValues.Get("local") == "true" {
+			method = "SetOperationPriorityLocal"
+		}
+
+		err = api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			method,
+			types.OperationSerial{Cid: ps.Cid, Priority: priority},
+			&struct{}{},
+		)
+		if err != nil { // errors here are 404s
+			api.sendResponse(w, http.StatusNotFound, err, nil)
+			return
+		}
+		api.sendResponse(w, autoStatus, nil, nil)
+	}
+}
+
 func (api *API) parseCidOrError(w http.ResponseWriter, r *http.Request) types.PinSerial {
 	vars := mux.Vars(r)
 	hash := vars["hash"]
@@ -980,6 +1593,72 @@ func (api *API) parsePidOrError(w http.ResponseWriter, r *http.Request) peer.ID
 	return pid
 }
 
+// parseListPage reads the "cursor" and "limit" query parameters used to
+// paginate listings.
+func parseListPage(queryValues url.Values) (types.ListPage, error) {
+	page := types.ListPage{
+		Cursor: queryValues.Get("cursor"),
+	}
+	if limitStr := queryValues.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 0 {
+			return page, errors.New("invalid limit value")
+		}
+		page.Limit = limit
+	}
+	return page, nil
+}
+
+// pageFetcher retrieves the items in a page of a listing, ready to be
+// sent, along with the cursor for the next page. The cursor is empty when
+// the listing is exhausted.
+type pageFetcher func(page types.ListPage) ([]interface{}, string, error)
+
+// streamPages sends a listing as a stream of JSON objects, one per line,
+// fetching and flushing it page by page, starting at the given page. The
+// page limit is used as the page size. Errors happening after the stream
+// has started are sent in the X-Stream-Error trailer.
+func (api *API) streamPages(w http.ResponseWriter, page types.ListPage, fetch pageFetcher) {
+	if page.Limit <= 0 {
+		page.Limit = streamPageSize
+	}
+
+	items, next, err := fetch(page)
+	if err != nil {
+		api.sendResponse(w, autoStatus, err, nil)
+		return
+	}
+
+	api.setHeaders(w)
+	w.Header().Set("Trailer", "X-Stream-Error")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, flush := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	for {
+		for _, item := range items {
+			if err := enc.Encode(item); err != nil {
+				logger.Error(err)
+				return
+			}
+		}
+		if flush {
+			flusher.Flush()
+		}
+		if next == "" {
+			return
+		}
+
+		page.Cursor = next
+		items, next, err = fetch(page)
+		if err != nil {
+			logger.Errorf("error streaming listing: %s", err)
+			w.Header().Set("X-Stream-Error", err.Error())
+			return
+		}
+	}
+}
+
 func pinInfoToGlobal(pInfo types.PinInfoSerial) types.GlobalPinInfoSerial {
 	return types.GlobalPinInfoSerial{
 		Cid: pInfo.Cid,
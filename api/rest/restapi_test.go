@@ -1,6 +1,7 @@
 package rest
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/tls"
@@ -18,6 +19,7 @@ import (
 	"github.com/elastos/Elastos.NET.Hive.Cluster/test"
 
 	p2phttp "github.com/hsanjuan/go-libp2p-http"
+	cid "github.com/ipfs/go-cid"
 	libp2p "github.com/libp2p/go-libp2p"
 	host "github.com/libp2p/go-libp2p-host"
 	peer "github.com/libp2p/go-libp2p-peer"
@@ -261,6 +263,45 @@ func makeStreamingPost(t *testing.T, rest *API, url string, body io.Reader, cont
 	checkHeaders(t, rest, url, httpResp.Header)
 }
 
+// makeStreamingGet collects all the objects in a streamed response into
+// resp, which should be a pointer to a slice.
+func makeStreamingGet(t *testing.T, rest *API, url string, resp interface{}) {
+	h := makeHost(t, rest)
+	defer h.Close()
+	c := httpClient(t, h, isHTTPS(url))
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Origin", clientOrigin)
+	httpResp, err := c.Do(req)
+	if err != nil {
+		t.Fatal("error making streaming request: ", err)
+	}
+	defer httpResp.Body.Close()
+
+	items := make([]json.RawMessage, 0)
+	dec := json.NewDecoder(httpResp.Body)
+	for {
+		var item json.RawMessage
+		err := dec.Decode(&item)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, item)
+	}
+	if errTrailer := httpResp.Trailer.Get("X-Stream-Error"); errTrailer != "" {
+		t.Error("stream error: ", errTrailer)
+	}
+	checkHeaders(t, rest, url, httpResp.Header)
+
+	all, _ := json.Marshal(items)
+	err = json.Unmarshal(all, resp)
+	if err != nil {
+		t.Fatal("error parsing json: ", err)
+	}
+}
+
 type testF func(t *testing.T, url urlF)
 
 func testBothEndpoints(t *testing.T, test testF) {
@@ -467,6 +508,53 @@ func TestAPIAddFileEndpointShard(t *testing.T) {
 	testBothEndpoints(t, tf)
 }
 
+func TestAPICatEndpoint(t *testing.T) {
+	rest := testAPI(t)
+	defer rest.Shutdown()
+
+	tf := func(t *testing.T, url urlF) {
+		h := makeHost(t, rest)
+		defer h.Close()
+		c := httpClient(t, h, isHTTPS(url(rest)))
+
+		httpResp, err := c.Get(url(rest) + "/cat/" + test.TestCid4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer httpResp.Body.Close()
+		body, err := ioutil.ReadAll(httpResp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if httpResp.StatusCode != http.StatusOK || string(body) != test.TestCid4Data {
+			t.Errorf("unexpected response: %d: %s", httpResp.StatusCode, body)
+		}
+
+		req, _ := http.NewRequest("GET", url(rest)+"/cat/"+test.TestCid4, nil)
+		req.Header.Set("Range", "bytes=1-3")
+		httpResp, err = c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer httpResp.Body.Close()
+		body, err = ioutil.ReadAll(httpResp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if httpResp.StatusCode != http.StatusPartialContent || string(body) != test.TestCid4Data[1:4] {
+			t.Errorf("unexpected range response: %d: %s", httpResp.StatusCode, body)
+		}
+
+		errResp := api.Error{}
+		makeGet(t, rest, url(rest)+"/cat/"+test.TestCid1, &errResp)
+		if errResp.Code != 500 {
+			t.Error("expected an error when the blocks are missing")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
 func TestAPIAddFileEndpoint_StreamChannelsFalse(t *testing.T) {
 	rest := testAPI(t)
 	defer rest.Shutdown()
@@ -514,6 +602,24 @@ func TestAPIPeerRemoveEndpoint(t *testing.T) {
 	testBothEndpoints(t, tf)
 }
 
+func TestAPIPeerDrainEndpoint(t *testing.T) {
+	rest := testAPI(t)
+	defer rest.Shutdown()
+
+	tf := func(t *testing.T, url urlF) {
+		makePost(t, rest, url(rest)+"/peers/"+test.TestPeerID2.Pretty()+"/drain", []byte{}, &struct{}{})
+		makeDelete(t, rest, url(rest)+"/peers/"+test.TestPeerID2.Pretty()+"/drain", &struct{}{})
+
+		var infos []api.DrainInfoSerial
+		makeGet(t, rest, url(rest)+"/peers/drain", &infos)
+		if len(infos) != 1 || infos[0].Peer != test.TestPeerID2.Pretty() || infos[0].Remaining != 2 {
+			t.Errorf("unexpected drain status: %+v", infos)
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
 func TestConnectGraphEndpoint(t *testing.T) {
 	rest := testAPI(t)
 	defer rest.Shutdown()
@@ -565,6 +671,22 @@ func TestAPIPinEndpoint(t *testing.T) {
 		if errResp.Code != 400 {
 			t.Error("should fail with bad Cid")
 		}
+
+		makePost(t, rest, url(rest)+"/pins/"+test.TestCid1+"?priority=high", []byte{}, &struct{}{})
+
+		errResp = api.Error{}
+		makePost(t, rest, url(rest)+"/pins/"+test.TestCid1+"?priority=urgent", []byte{}, &errResp)
+		if errResp.Code != 400 {
+			t.Error("should fail with bad priority")
+		}
+
+		makePost(t, rest, url(rest)+"/pins/"+test.TestCid1+"?spread-tag=region&require-tag=tier:ssd&exclude-tag=rack:r1", []byte{}, &struct{}{})
+
+		errResp = api.Error{}
+		makePost(t, rest, url(rest)+"/pins/"+test.TestCid1+"?require-tag=tier", []byte{}, &errResp)
+		if errResp.Code != 400 {
+			t.Error("should fail with bad tag")
+		}
 	}
 
 	testBothEndpoints(t, tf)
@@ -593,6 +715,72 @@ func TestAPIUnpinEndpoint(t *testing.T) {
 	testBothEndpoints(t, tf)
 }
 
+func TestAPIOperationsEndpoint(t *testing.T) {
+	rest := testAPI(t)
+	defer rest.Shutdown()
+
+	tf := func(t *testing.T, url urlF) {
+		var resp []api.OperationSerial
+		makeGet(t, rest, url(rest)+"/operations", &resp)
+		if len(resp) != 2 {
+			t.Fatal("expected two operations: ", resp)
+		}
+
+		var resp2 []api.OperationSerial
+		makeGet(t, rest, url(rest)+"/operations?local=true&phase=queued", &resp2)
+		if len(resp2) != 1 || resp2[0].Cid != test.TestCid1 || resp2[0].Type != "pin" {
+			t.Error("expected one queued operation: ", resp2)
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPICancelOperationEndpoint(t *testing.T) {
+	rest := testAPI(t)
+	defer rest.Shutdown()
+
+	tf := func(t *testing.T, url urlF) {
+		makeDelete(t, rest, url(rest)+"/operations/"+test.TestCid1, &struct{}{})
+
+		errResp := api.Error{}
+		makeDelete(t, rest, url(rest)+"/operations/"+test.TestCid2+"?local=true", &errResp)
+		if errResp.Code != 404 {
+			t.Error("cancelling a missing operation should fail with 404")
+		}
+
+		makeDelete(t, rest, url(rest)+"/operations/abcd", &errResp)
+		if errResp.Code != 400 {
+			t.Error("should fail with bad Cid")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPIOperationPriorityEndpoint(t *testing.T) {
+	rest := testAPI(t)
+	defer rest.Shutdown()
+
+	tf := func(t *testing.T, url urlF) {
+		makePost(t, rest, url(rest)+"/operations/"+test.TestCid1+"/priority?priority=5", []byte{}, &struct{}{})
+
+		errResp := api.Error{}
+		makePost(t, rest, url(rest)+"/operations/"+test.TestCid1+"/priority?priority=abc", []byte{}, &errResp)
+		if errResp.Code != 400 {
+			t.Error("should fail with bad priority")
+		}
+
+		errResp = api.Error{}
+		makePost(t, rest, url(rest)+"/operations/"+test.TestCid2+"/priority?priority=5", []byte{}, &errResp)
+		if errResp.Code != 404 {
+			t.Error("changing the priority of a missing operation should fail with 404")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
 func TestAPIAllocationsEndpoint(t *testing.T) {
 	rest := testAPI(t)
 	defer rest.Shutdown()
@@ -610,6 +798,36 @@ func TestAPIAllocationsEndpoint(t *testing.T) {
 	testBothEndpoints(t, tf)
 }
 
+func TestAPIAllocationsEndpointPaged(t *testing.T) {
+	rest := testAPI(t)
+	defer rest.Shutdown()
+
+	tf := func(t *testing.T, url urlF) {
+		// Pages are sorted by Cid: TestCid2, TestCid3, TestCid1
+		var resp []api.PinSerial
+		makeGet(t, rest, url(rest)+"/allocations?filter=pin&limit=2", &resp)
+		if len(resp) != 2 ||
+			resp[0].Cid != test.TestCid2 || resp[1].Cid != test.TestCid3 {
+			t.Error("unexpected first page: ", resp)
+		}
+
+		var resp2 []api.PinSerial
+		makeGet(t, rest, url(rest)+"/allocations?filter=pin&limit=2&cursor="+test.TestCid3, &resp2)
+		if len(resp2) != 1 || resp2[0].Cid != test.TestCid1 {
+			t.Error("unexpected second page: ", resp2)
+		}
+
+		var resp3 []api.PinSerial
+		makeStreamingGet(t, rest, url(rest)+"/allocations?filter=pin&stream=true&limit=1", &resp3)
+		if len(resp3) != 3 ||
+			resp3[0].Cid != test.TestCid2 || resp3[2].Cid != test.TestCid1 {
+			t.Error("unexpected streamed pin list: ", resp3)
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
 func TestAPIAllocationEndpoint(t *testing.T) {
 	rest := testAPI(t)
 	defer rest.Shutdown()
@@ -654,6 +872,231 @@ func TestAPIMetricsEndpoint(t *testing.T) {
 	testBothEndpoints(t, tf)
 }
 
+func TestAPIMetricsHistoryEndpoint(t *testing.T) {
+	rest := testAPI(t)
+	defer rest.Shutdown()
+
+	tf := func(t *testing.T, url urlF) {
+		var resp []api.MetricHistorySerial
+		makeGet(t, rest, url(rest)+"/monitor/metrics/freespace/history", &resp)
+		if len(resp) != 1 {
+			t.Fatal("expected history for one peer")
+		}
+		h := resp[0].ToMetricHistory()
+		if h.Name != "freespace" || h.Peer != test.TestPeerID1 {
+			t.Error("unexpected name or peer")
+		}
+		if len(h.Samples) != 2 || h.Max != 20 || h.Rate != 10 {
+			t.Errorf("unexpected history: %+v", h)
+		}
+
+		resp = nil
+		makeGet(t, rest, url(rest)+"/monitor/metrics/freespace/history?peer="+test.TestPeerID2.Pretty(), &resp)
+		if len(resp) != 0 {
+			t.Error("history should be filtered by peer")
+		}
+
+		errResp := api.Error{}
+		makeGet(t, rest, url(rest)+"/monitor/metrics/freespace/history?peer=abc", &errResp)
+		if errResp.Code != 400 {
+			t.Error("expected bad request for a wrong peer")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPIPinsHealthEndpoint(t *testing.T) {
+	rest := testAPI(t)
+	defer rest.Shutdown()
+
+	tf := func(t *testing.T, url urlF) {
+		var resp api.PinsHealthReportSerial
+		makeGet(t, rest, url(rest)+"/health/pins", &resp)
+		report := resp.ToPinsHealthReport()
+		if report.Peer != test.TestPeerID1 {
+			t.Error("unexpected report peer: ", resp.Peer)
+		}
+		if report.Total != 3 || report.Summary[api.PinHealthUnderReplicated] != 1 {
+			t.Errorf("unexpected summary: %+v", resp.Summary)
+		}
+		if len(report.Worst) != 2 || report.Worst[0].State != api.PinHealthUnderReplicated {
+			t.Errorf("unexpected worst pins: %+v", resp.Worst)
+		}
+
+		var limited api.PinsHealthReportSerial
+		makeGet(t, rest, url(rest)+"/health/pins?limit=1", &limited)
+		if len(limited.Worst) != 1 || limited.Worst[0].Cid != test.TestCid1 {
+			t.Errorf("expected only the worst pin: %+v", limited.Worst)
+		}
+
+		errResp := api.Error{}
+		makeGet(t, rest, url(rest)+"/health/pins?limit=-1", &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected a bad request for a negative limit")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPIAlertsEndpoint(t *testing.T) {
+	rest := testAPI(t)
+	defer rest.Shutdown()
+
+	tf := func(t *testing.T, url urlF) {
+		var alerts []api.AlertSerial
+		makeGet(t, rest, url(rest)+"/alerts", &alerts)
+		if len(alerts) != 1 || alerts[0].MetricName != "freespace" || alerts[0].Peer != test.TestPeerID2.Pretty() {
+			t.Errorf("unexpected alerts: %+v", alerts)
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPIEventsEndpoint(t *testing.T) {
+	rest := testAPI(t)
+	defer rest.Shutdown()
+
+	c1, _ := cid.Decode(test.TestCid1)
+	c2, _ := cid.Decode(test.TestCid2)
+	rest.HandleEvent(api.Event{Type: api.EventPinDone, Cid: c1, Peer: test.TestPeerID1})
+	rest.HandleEvent(api.Event{Type: api.EventPinError, Cid: c1, Peer: test.TestPeerID1})
+	rest.HandleEvent(api.Event{Type: api.EventPinDone, Cid: c2, Peer: test.TestPeerID1})
+	rest.HandleEvent(api.Event{Type: api.EventUidCreated, UID: "uid-test", Peer: test.TestPeerID1})
+
+	tf := func(t *testing.T, url urlF) {
+		var evs []api.EventSerial
+		makeStreamingGet(t, rest, url(rest)+"/events?follow=false", &evs)
+		if len(evs) != 4 {
+			t.Errorf("expected 4 events, got %d", len(evs))
+		}
+
+		evs = nil
+		makeStreamingGet(t, rest, url(rest)+"/events?follow=false&type=pin_done", &evs)
+		if len(evs) != 2 {
+			t.Errorf("expected 2 pin_done events, got %d", len(evs))
+		}
+
+		evs = nil
+		makeStreamingGet(t, rest, url(rest)+"/events?follow=false&type=pin_done,pin_error&cid="+test.TestCid1, &evs)
+		if len(evs) != 2 || evs[0].Cid != test.TestCid1 || evs[1].Cid != test.TestCid1 {
+			t.Errorf("unexpected events filtering by cid: %+v", evs)
+		}
+
+		evs = nil
+		makeStreamingGet(t, rest, url(rest)+"/events?follow=false&uid=uid-test", &evs)
+		if len(evs) != 1 || evs[0].Type != string(api.EventUidCreated) {
+			t.Errorf("unexpected events filtering by uid: %+v", evs)
+		}
+
+		errResp := api.Error{}
+		makeGet(t, rest, url(rest)+"/events?follow=false&type=abc", &errResp)
+		if errResp.Code != 400 {
+			t.Error("expected bad request with an unknown event type")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPIEventsEndpointFollow(t *testing.T) {
+	rest := testAPI(t)
+	defer rest.Shutdown()
+
+	tf := func(t *testing.T, url urlF, format string, c cid.Cid) {
+		h := makeHost(t, rest)
+		defer h.Close()
+		client := httpClient(t, h, isHTTPS(url(rest)))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		req, _ := http.NewRequest(
+			http.MethodGet,
+			url(rest)+"/events?type=pin_error&format="+format+"&cid="+c.String(),
+			nil,
+		)
+		req = req.WithContext(ctx)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		lines := make(chan string, 10)
+		go func() {
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				if l := scanner.Text(); l != "" {
+					lines <- l
+				}
+			}
+		}()
+
+		// publish until the subscription is in place and the
+		// event is received.
+		var line string
+		timeout := time.After(5 * time.Second)
+	WAIT:
+		for {
+			rest.HandleEvent(api.Event{Type: api.EventPinDone, Cid: c})
+			rest.HandleEvent(api.Event{Type: api.EventPinError, Cid: c})
+			select {
+			case line = <-lines:
+				break WAIT
+			case <-time.After(100 * time.Millisecond):
+			case <-timeout:
+				t.Fatal("no event received")
+			}
+		}
+
+		if format == "sse" {
+			if line != "event: pin_error" {
+				t.Fatalf("unexpected sse line: %s", line)
+			}
+			line = strings.TrimPrefix(<-lines, "data: ")
+		}
+		var ev api.EventSerial
+		err = json.Unmarshal([]byte(line), &ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.Type != string(api.EventPinError) || ev.Cid != c.String() {
+			t.Errorf("unexpected event: %+v", ev)
+		}
+	}
+
+	c1, _ := cid.Decode(test.TestCid1)
+	c2, _ := cid.Decode(test.TestCid2)
+	t.Run("ndjson", func(t *testing.T) { tf(t, httpURL, "ndjson", c1) })
+	t.Run("sse-libp2p", func(t *testing.T) { tf(t, p2pURL, "sse", c2) })
+}
+
+func TestAPIRebalanceEndpoint(t *testing.T) {
+	rest := testAPI(t)
+	defer rest.Shutdown()
+
+	tf := func(t *testing.T, url urlF) {
+		var resp api.RebalanceReportSerial
+		makePost(t, rest, url(rest)+"/rebalance?dry-run=true", []byte{}, &resp)
+		if !resp.DryRun {
+			t.Error("expected a dry run")
+		}
+		if len(resp.Moves) != 1 || resp.Moves[0].Cid != test.TestCid1 {
+			t.Errorf("unexpected moves: %+v", resp.Moves)
+		}
+
+		var real api.RebalanceReportSerial
+		makePost(t, rest, url(rest)+"/rebalance", []byte{}, &real)
+		if real.DryRun {
+			t.Error("expected a real run")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
 func TestAPIStatusAllEndpoint(t *testing.T) {
 	rest := testAPI(t)
 	defer rest.Shutdown()
@@ -663,7 +1106,8 @@ func TestAPIStatusAllEndpoint(t *testing.T) {
 		makeGet(t, rest, url(rest)+"/pins", &resp)
 		if len(resp) != 3 ||
 			resp[0].Cid != test.TestCid1 ||
-			resp[1].PeerMap[test.TestPeerID1.Pretty()].Status != "pinning" {
+			resp[1].PeerMap[test.TestPeerID1.Pretty()].Status != "pinning" ||
+			resp[1].PeerMap[test.TestPeerID1.Pretty()].Progress != 42 {
 			t.Errorf("unexpected statusAll resp:\n %+v", resp)
 		}
 
@@ -709,6 +1153,57 @@ func TestAPIStatusAllEndpoint(t *testing.T) {
 	testBothEndpoints(t, tf)
 }
 
+func TestAPIStatusAllEndpointPaged(t *testing.T) {
+	rest := testAPI(t)
+	defer rest.Shutdown()
+
+	tf := func(t *testing.T, url urlF) {
+		// Pages are sorted by Cid: TestCid2, TestCid3, TestCid1
+		var resp []api.GlobalPinInfoSerial
+		makeGet(t, rest, url(rest)+"/pins?limit=2", &resp)
+		if len(resp) != 2 ||
+			resp[0].Cid != test.TestCid2 ||
+			resp[1].Cid != test.TestCid3 {
+			t.Errorf("unexpected first page:\n %+v", resp)
+		}
+
+		var resp2 []api.GlobalPinInfoSerial
+		makeGet(t, rest, url(rest)+"/pins?limit=2&cursor="+test.TestCid3, &resp2)
+		if len(resp2) != 1 || resp2[0].Cid != test.TestCid1 {
+			t.Errorf("unexpected second page:\n %+v", resp2)
+		}
+
+		var errResp api.Error
+		makeGet(t, rest, url(rest)+"/pins?limit=abc", &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("a bad limit should fail")
+		}
+
+		var resp3 []api.GlobalPinInfoSerial
+		makeStreamingGet(t, rest, url(rest)+"/pins?stream=true&limit=1", &resp3)
+		if len(resp3) != 3 ||
+			resp3[0].Cid != test.TestCid2 ||
+			resp3[0].PeerMap[test.TestPeerID1.Pretty()].Status != "pinning" ||
+			resp3[2].Cid != test.TestCid1 {
+			t.Errorf("unexpected streamed status:\n %+v", resp3)
+		}
+
+		var resp4 []api.GlobalPinInfoSerial
+		makeStreamingGet(t, rest, url(rest)+"/pins?stream=true&local=true", &resp4)
+		if len(resp4) != 2 {
+			t.Errorf("unexpected streamed status+local:\n %+v", resp4)
+		}
+
+		var resp5 []api.GlobalPinInfoSerial
+		makeStreamingGet(t, rest, url(rest)+"/pins?stream=true&limit=1&filter=pinned", &resp5)
+		if len(resp5) != 1 || resp5[0].Cid != test.TestCid1 {
+			t.Errorf("unexpected streamed status+filter=pinned:\n %+v", resp5)
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
 func TestAPIStatusEndpoint(t *testing.T) {
 	rest := testAPI(t)
 	defer rest.Shutdown()
@@ -12,8 +12,10 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"path"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -222,12 +224,17 @@ var ipfsPinStatus2TrackerStatusMap = map[IPFSPinStatus]TrackerStatus{
 type GlobalPinInfo struct {
 	Cid     cid.Cid
 	PeerMap map[peer.ID]PinInfo
+	// Shards holds the status of every shard when Cid is a
+	// meta-pin. It is only filled in when requesting the status of
+	// a single Cid.
+	Shards []GlobalPinInfo
 }
 
 // GlobalPinInfoSerial is the serializable version of GlobalPinInfo.
 type GlobalPinInfoSerial struct {
 	Cid     string                   `json:"cid"`
 	PeerMap map[string]PinInfoSerial `json:"peer_map"`
+	Shards  []GlobalPinInfoSerial    `json:"shards,omitempty"`
 }
 
 // ToSerial converts a GlobalPinInfo to its serializable version.
@@ -240,6 +247,9 @@ func (gpi GlobalPinInfo) ToSerial() GlobalPinInfoSerial {
 	for k, v := range gpi.PeerMap {
 		s.PeerMap[peer.IDB58Encode(k)] = v.ToSerial()
 	}
+	for _, shard := range gpi.Shards {
+		s.Shards = append(s.Shards, shard.ToSerial())
+	}
 	return s
 }
 
@@ -260,6 +270,9 @@ func (gpis GlobalPinInfoSerial) ToGlobalPinInfo() GlobalPinInfo {
 		}
 		gpi.PeerMap[p] = v.ToPinInfo()
 	}
+	for _, shard := range gpis.Shards {
+		gpi.Shards = append(gpi.Shards, shard.ToGlobalPinInfo())
+	}
 	return gpi
 }
 
@@ -271,6 +284,9 @@ type PinInfo struct {
 	Status   TrackerStatus
 	TS       time.Time
 	Error    string
+	// Progress is the number of blocks fetched so far by an ongoing
+	// pin operation.
+	Progress uint64
 }
 
 // PinInfoSerial is a serializable version of PinInfo.
@@ -282,6 +298,7 @@ type PinInfoSerial struct {
 	Status   string `json:"status"`
 	TS       string `json:"timestamp"`
 	Error    string `json:"error"`
+	Progress uint64 `json:"progress,omitempty"`
 }
 
 // ToSerial converts a PinInfo to its serializable version.
@@ -302,6 +319,7 @@ func (pi PinInfo) ToSerial() PinInfoSerial {
 		Status:   pi.Status.String(),
 		TS:       pi.TS.UTC().Format(time.RFC3339),
 		Error:    pi.Error,
+		Progress: pi.Progress,
 	}
 }
 
@@ -326,6 +344,772 @@ func (pis PinInfoSerial) ToPinInfo() PinInfo {
 		Status:   TrackerStatusFromString(pis.Status),
 		TS:       ts,
 		Error:    pis.Error,
+		Progress: pis.Progress,
+	}
+}
+
+// Operation describes an operation (pin, unpin...) handled by the pin
+// tracker of a cluster peer.
+type Operation struct {
+	Cid      cid.Cid
+	Peer     peer.ID
+	PeerName string
+	Type     string
+	Phase    string
+	Priority int
+	Attempts int
+	Progress uint64
+	Error    string
+	Created  time.Time
+	TS       time.Time
+	// NextRetry is set when a failed operation is waiting to be
+	// retried.
+	NextRetry time.Time
+}
+
+// OperationSerial is a serializable version of Operation.
+type OperationSerial struct {
+	Cid       string `json:"cid"`
+	Peer      string `json:"peer"`
+	PeerName  string `json:"peername"`
+	Type      string `json:"type"`
+	Phase     string `json:"phase"`
+	Priority  int    `json:"priority"`
+	Attempts  int    `json:"attempts"`
+	Progress  uint64 `json:"progress"`
+	Error     string `json:"error"`
+	Created   string `json:"created"`
+	TS        string `json:"timestamp"`
+	NextRetry string `json:"next_retry,omitempty"`
+}
+
+// ToSerial converts an Operation to its serializable version.
+func (op Operation) ToSerial() OperationSerial {
+	c := ""
+	if op.Cid.Defined() {
+		c = op.Cid.String()
+	}
+	p := ""
+	if op.Peer != "" {
+		p = peer.IDB58Encode(op.Peer)
+	}
+	next := ""
+	if !op.NextRetry.IsZero() {
+		next = op.NextRetry.UTC().Format(time.RFC3339)
+	}
+
+	return OperationSerial{
+		Cid:       c,
+		Peer:      p,
+		PeerName:  op.PeerName,
+		Type:      op.Type,
+		Phase:     op.Phase,
+		Priority:  op.Priority,
+		Attempts:  op.Attempts,
+		Progress:  op.Progress,
+		Error:     op.Error,
+		Created:   op.Created.UTC().Format(time.RFC3339),
+		TS:        op.TS.UTC().Format(time.RFC3339),
+		NextRetry: next,
+	}
+}
+
+// ToOperation converts an OperationSerial to its native version.
+func (ops OperationSerial) ToOperation() Operation {
+	c, err := cid.Decode(ops.Cid)
+	if err != nil {
+		logger.Debug(ops.Cid, err)
+	}
+	p, err := peer.IDB58Decode(ops.Peer)
+	if err != nil {
+		logger.Debug(ops.Peer, err)
+	}
+	created, err := time.Parse(time.RFC3339, ops.Created)
+	if err != nil {
+		logger.Debug(ops.Created, err)
+	}
+	ts, err := time.Parse(time.RFC3339, ops.TS)
+	if err != nil {
+		logger.Debug(ops.TS, err)
+	}
+	var next time.Time
+	if ops.NextRetry != "" {
+		next, err = time.Parse(time.RFC3339, ops.NextRetry)
+		if err != nil {
+			logger.Debug(ops.NextRetry, err)
+		}
+	}
+	return Operation{
+		Cid:       c,
+		Peer:      p,
+		PeerName:  ops.PeerName,
+		Type:      ops.Type,
+		Phase:     ops.Phase,
+		Priority:  ops.Priority,
+		Attempts:  ops.Attempts,
+		Progress:  ops.Progress,
+		Error:     ops.Error,
+		Created:   created,
+		TS:        ts,
+		NextRetry: next,
+	}
+}
+
+// ListPage selects a portion of a listing sorted by Cid. Only items whose
+// Cid string sorts strictly after Cursor are selected, up to Limit items.
+// An empty Cursor starts from the beginning and a Limit of 0 means no limit.
+type ListPage struct {
+	Cursor string `json:"cursor"`
+	Limit  int    `json:"limit"`
+}
+
+// bounds returns the [start, end) range of a listing of n items, sorted by
+// key, that falls within the page.
+func (lp ListPage) bounds(n int, key func(i int) string) (int, int) {
+	start := sort.Search(n, func(i int) bool {
+		return key(i) > lp.Cursor
+	})
+	end := n
+	if lp.Limit > 0 && start+lp.Limit < n {
+		end = start + lp.Limit
+	}
+	return start, end
+}
+
+// PinInfos sorts the given PinInfos by Cid and returns those selected
+// by the page.
+func (lp ListPage) PinInfos(pinfos []PinInfo) []PinInfo {
+	sort.Slice(pinfos, func(i, j int) bool {
+		return pinfos[i].Cid.String() < pinfos[j].Cid.String()
+	})
+	start, end := lp.bounds(len(pinfos), func(i int) string {
+		return pinfos[i].Cid.String()
+	})
+	return pinfos[start:end]
+}
+
+// GlobalPinInfos sorts the given GlobalPinInfos by Cid and returns those
+// selected by the page.
+func (lp ListPage) GlobalPinInfos(gpis []GlobalPinInfo) []GlobalPinInfo {
+	sort.Slice(gpis, func(i, j int) bool {
+		return gpis[i].Cid.String() < gpis[j].Cid.String()
+	})
+	start, end := lp.bounds(len(gpis), func(i int) string {
+		return gpis[i].Cid.String()
+	})
+	return gpis[start:end]
+}
+
+// Cids sorts the given Cid strings and returns those selected by the page.
+func (lp ListPage) Cids(cids []string) []string {
+	sort.Strings(cids)
+	start, end := lp.bounds(len(cids), func(i int) string {
+		return cids[i]
+	})
+	return cids[start:end]
+}
+
+// Pins sorts the given Pins by Cid and returns those selected by the page.
+func (lp ListPage) Pins(pins []Pin) []Pin {
+	sort.Slice(pins, func(i, j int) bool {
+		return pins[i].Cid.String() < pins[j].Cid.String()
+	})
+	start, end := lp.bounds(len(pins), func(i int) string {
+		return pins[i].Cid.String()
+	})
+	return pins[start:end]
+}
+
+// PinHealthState classifies pins according to how well they are
+// replicated across the cluster.
+type PinHealthState int
+
+// PinHealthState values, from best to worst.
+const (
+	// PinHealthOK means that every allocation is pinned on a
+	// live peer.
+	PinHealthOK PinHealthState = iota
+	// PinHealthDegraded means that there are at least
+	// ReplicationFactorMin pinned copies, but some allocations are
+	// down, in error or not pinned yet.
+	PinHealthDegraded
+	// PinHealthUnderReplicated means that there are fewer pinned
+	// copies than ReplicationFactorMin.
+	PinHealthUnderReplicated
+	// PinHealthLost means that no live peer has the item pinned.
+	PinHealthLost
+)
+
+// String returns a printable value to identify the PinHealthState.
+func (st PinHealthState) String() string {
+	switch st {
+	case PinHealthOK:
+		return "ok"
+	case PinHealthDegraded:
+		return "degraded"
+	case PinHealthUnderReplicated:
+		return "under_replicated"
+	case PinHealthLost:
+		return "lost"
+	default:
+		return ""
+	}
+}
+
+// PinHealthStateFromString is the inverse of String. Unknown names
+// are parsed as PinHealthOK.
+func PinHealthStateFromString(str string) PinHealthState {
+	switch str {
+	case "degraded":
+		return PinHealthDegraded
+	case "under_replicated":
+		return PinHealthUnderReplicated
+	case "lost":
+		return PinHealthLost
+	default:
+		return PinHealthOK
+	}
+}
+
+// PinHealth describes the replication health of a single pin. Pinned
+// counts the peers which have the item pinned and are alive. Down lists
+// the peers expected to pin the item which are not alive, and Errored
+// those which report an error for it.
+type PinHealth struct {
+	Cid                  cid.Cid
+	Name                 string
+	ReplicationFactorMin int
+	ReplicationFactorMax int
+	Allocations          []peer.ID
+	Pinned               int
+	Down                 []peer.ID
+	Errored              []peer.ID
+	State                PinHealthState
+}
+
+// Missing returns how many more pinned copies are needed for the pin to
+// reach its expected replication: ReplicationFactorMin, or the number
+// of peers in the cluster for pins which are allocated everywhere.
+func (ph PinHealth) Missing(clusterSize int) int {
+	expected := ph.ReplicationFactorMin
+	if expected < 0 {
+		expected = clusterSize
+	}
+	if ph.Pinned >= expected {
+		return 0
+	}
+	return expected - ph.Pinned
+}
+
+// PinHealthSerial is a serializable version of PinHealth.
+type PinHealthSerial struct {
+	Cid                  string   `json:"cid"`
+	Name                 string   `json:"name"`
+	ReplicationFactorMin int      `json:"replication_factor_min"`
+	ReplicationFactorMax int      `json:"replication_factor_max"`
+	Allocations          []string `json:"allocations"`
+	Pinned               int      `json:"pinned"`
+	Down                 []string `json:"down"`
+	Errored              []string `json:"errored"`
+	State                string   `json:"state"`
+}
+
+// ToSerial converts a PinHealth to its serializable version.
+func (ph PinHealth) ToSerial() PinHealthSerial {
+	c := ""
+	if ph.Cid.Defined() {
+		c = ph.Cid.String()
+	}
+	return PinHealthSerial{
+		Cid:                  c,
+		Name:                 ph.Name,
+		ReplicationFactorMin: ph.ReplicationFactorMin,
+		ReplicationFactorMax: ph.ReplicationFactorMax,
+		Allocations:          PeersToStrings(ph.Allocations),
+		Pinned:               ph.Pinned,
+		Down:                 PeersToStrings(ph.Down),
+		Errored:              PeersToStrings(ph.Errored),
+		State:                ph.State.String(),
+	}
+}
+
+// ToPinHealth converts a PinHealthSerial to its native version.
+func (phs PinHealthSerial) ToPinHealth() PinHealth {
+	c, err := cid.Decode(phs.Cid)
+	if err != nil {
+		logger.Debug(phs.Cid, err)
+	}
+	return PinHealth{
+		Cid:                  c,
+		Name:                 phs.Name,
+		ReplicationFactorMin: phs.ReplicationFactorMin,
+		ReplicationFactorMax: phs.ReplicationFactorMax,
+		Allocations:          StringsToPeers(phs.Allocations),
+		Pinned:               phs.Pinned,
+		Down:                 StringsToPeers(phs.Down),
+		Errored:              StringsToPeers(phs.Errored),
+		State:                PinHealthStateFromString(phs.State),
+	}
+}
+
+// PinsHealthReport summarizes the replication health of the pinset as
+// seen by Peer, normally the cluster leader, at the given time. Summary
+// counts the pins in each PinHealthState and Worst lists unhealthy pins,
+// from the worst to the least bad.
+type PinsHealthReport struct {
+	Peer        peer.ID
+	TS          time.Time
+	ClusterSize int
+	Total       int
+	Summary     map[PinHealthState]int
+	Worst       []PinHealth
+}
+
+// SortWorst sorts the Worst pins by State, then by the number of missing
+// copies and finally by Cid.
+func (r *PinsHealthReport) SortWorst() {
+	sort.Slice(r.Worst, func(i, j int) bool {
+		a, b := r.Worst[i], r.Worst[j]
+		if a.State != b.State {
+			return a.State > b.State
+		}
+		ma, mb := a.Missing(r.ClusterSize), b.Missing(r.ClusterSize)
+		if ma != mb {
+			return ma > mb
+		}
+		return a.Cid.String() < b.Cid.String()
+	})
+}
+
+// PinsHealthReportSerial is a serializable version of PinsHealthReport.
+type PinsHealthReportSerial struct {
+	Peer        string            `json:"peer"`
+	TS          string            `json:"timestamp"`
+	ClusterSize int               `json:"cluster_size"`
+	Total       int               `json:"total"`
+	Summary     map[string]int    `json:"summary"`
+	Worst       []PinHealthSerial `json:"worst"`
+}
+
+// ToSerial converts a PinsHealthReport to its serializable version.
+func (r PinsHealthReport) ToSerial() PinsHealthReportSerial {
+	p := ""
+	if r.Peer != "" {
+		p = peer.IDB58Encode(r.Peer)
+	}
+	summary := make(map[string]int, len(r.Summary))
+	for st, n := range r.Summary {
+		summary[st.String()] = n
+	}
+	worst := make([]PinHealthSerial, len(r.Worst), len(r.Worst))
+	for i, ph := range r.Worst {
+		worst[i] = ph.ToSerial()
+	}
+	return PinsHealthReportSerial{
+		Peer:        p,
+		TS:          r.TS.UTC().Format(time.RFC3339),
+		ClusterSize: r.ClusterSize,
+		Total:       r.Total,
+		Summary:     summary,
+		Worst:       worst,
+	}
+}
+
+// ToPinsHealthReport converts a PinsHealthReportSerial to its native
+// version.
+func (rs PinsHealthReportSerial) ToPinsHealthReport() PinsHealthReport {
+	p, err := peer.IDB58Decode(rs.Peer)
+	if err != nil {
+		logger.Debug(rs.Peer, err)
+	}
+	ts, err := time.Parse(time.RFC3339, rs.TS)
+	if err != nil {
+		logger.Debug(rs.TS, err)
+	}
+	summary := make(map[PinHealthState]int, len(rs.Summary))
+	for st, n := range rs.Summary {
+		summary[PinHealthStateFromString(st)] = n
+	}
+	worst := make([]PinHealth, len(rs.Worst), len(rs.Worst))
+	for i, phs := range rs.Worst {
+		worst[i] = phs.ToPinHealth()
+	}
+	return PinsHealthReport{
+		Peer:        p,
+		TS:          ts,
+		ClusterSize: rs.ClusterSize,
+		Total:       rs.Total,
+		Summary:     summary,
+		Worst:       worst,
+	}
+}
+
+// RebalanceMove describes moving one allocation of a pin from a peer
+// to another.
+type RebalanceMove struct {
+	Cid  cid.Cid
+	Name string
+	From peer.ID
+	To   peer.ID
+}
+
+// RebalanceMoveSerial is a serializable version of RebalanceMove.
+type RebalanceMoveSerial struct {
+	Cid  string `json:"cid"`
+	Name string `json:"name"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ToSerial converts a RebalanceMove to its serializable version.
+func (m RebalanceMove) ToSerial() RebalanceMoveSerial {
+	c := ""
+	if m.Cid.Defined() {
+		c = m.Cid.String()
+	}
+	return RebalanceMoveSerial{
+		Cid:  c,
+		Name: m.Name,
+		From: peer.IDB58Encode(m.From),
+		To:   peer.IDB58Encode(m.To),
+	}
+}
+
+// ToRebalanceMove converts a RebalanceMoveSerial to its native version.
+func (ms RebalanceMoveSerial) ToRebalanceMove() RebalanceMove {
+	c, err := cid.Decode(ms.Cid)
+	if err != nil {
+		logger.Debug(ms.Cid, err)
+	}
+	from, err := peer.IDB58Decode(ms.From)
+	if err != nil {
+		logger.Debug(ms.From, err)
+	}
+	to, err := peer.IDB58Decode(ms.To)
+	if err != nil {
+		logger.Debug(ms.To, err)
+	}
+	return RebalanceMove{
+		Cid:  c,
+		Name: ms.Name,
+		From: from,
+		To:   to,
+	}
+}
+
+// RebalanceReport describes a rebalancing round, as decided by Peer
+// (normally the cluster leader) at the given time. Loads carries the values
+// of Metric for every peer which was considered. When DryRun is set, the
+// Moves were only planned.
+type RebalanceReport struct {
+	Peer   peer.ID
+	TS     time.Time
+	Metric string
+	DryRun bool
+	Loads  map[peer.ID]string
+	Moves  []RebalanceMove
+}
+
+// RebalanceReportSerial is a serializable version of RebalanceReport.
+type RebalanceReportSerial struct {
+	Peer   string                `json:"peer"`
+	TS     string                `json:"timestamp"`
+	Metric string                `json:"metric"`
+	DryRun bool                  `json:"dry_run"`
+	Loads  map[string]string     `json:"loads"`
+	Moves  []RebalanceMoveSerial `json:"moves"`
+}
+
+// ToSerial converts a RebalanceReport to its serializable version.
+func (r RebalanceReport) ToSerial() RebalanceReportSerial {
+	p := ""
+	if r.Peer != "" {
+		p = peer.IDB58Encode(r.Peer)
+	}
+	loads := make(map[string]string, len(r.Loads))
+	for pid, v := range r.Loads {
+		loads[peer.IDB58Encode(pid)] = v
+	}
+	moves := make([]RebalanceMoveSerial, len(r.Moves), len(r.Moves))
+	for i, m := range r.Moves {
+		moves[i] = m.ToSerial()
+	}
+	return RebalanceReportSerial{
+		Peer:   p,
+		TS:     r.TS.UTC().Format(time.RFC3339),
+		Metric: r.Metric,
+		DryRun: r.DryRun,
+		Loads:  loads,
+		Moves:  moves,
+	}
+}
+
+// ToRebalanceReport converts a RebalanceReportSerial to its native
+// version.
+func (rs RebalanceReportSerial) ToRebalanceReport() RebalanceReport {
+	p, err := peer.IDB58Decode(rs.Peer)
+	if err != nil {
+		logger.Debug(rs.Peer, err)
+	}
+	ts, err := time.Parse(time.RFC3339, rs.TS)
+	if err != nil {
+		logger.Debug(rs.TS, err)
+	}
+	loads := make(map[peer.ID]string, len(rs.Loads))
+	for pidStr, v := range rs.Loads {
+		pid, err := peer.IDB58Decode(pidStr)
+		if err != nil {
+			logger.Debug(pidStr, err)
+			continue
+		}
+		loads[pid] = v
+	}
+	moves := make([]RebalanceMove, len(rs.Moves), len(rs.Moves))
+	for i, ms := range rs.Moves {
+		moves[i] = ms.ToRebalanceMove()
+	}
+	return RebalanceReport{
+		Peer:   p,
+		TS:     ts,
+		Metric: rs.Metric,
+		DryRun: rs.DryRun,
+		Loads:  loads,
+		Moves:  moves,
+	}
+}
+
+// DrainInfo reports the progress of draining a peer: Remaining is the
+// number of pins still allocated to it.
+type DrainInfo struct {
+	Peer      peer.ID
+	Remaining int
+}
+
+// DrainInfoSerial is a serializable version of DrainInfo.
+type DrainInfoSerial struct {
+	Peer      string `json:"peer"`
+	Remaining int    `json:"remaining"`
+}
+
+// ToSerial converts a DrainInfo to its serializable version.
+func (di DrainInfo) ToSerial() DrainInfoSerial {
+	return DrainInfoSerial{
+		Peer:      peer.IDB58Encode(di.Peer),
+		Remaining: di.Remaining,
+	}
+}
+
+// ToDrainInfo converts a DrainInfoSerial to its native version.
+func (dis DrainInfoSerial) ToDrainInfo() DrainInfo {
+	p, err := peer.IDB58Decode(dis.Peer)
+	if err != nil {
+		logger.Debug(dis.Peer, err)
+	}
+	return DrainInfo{
+		Peer:      p,
+		Remaining: dis.Remaining,
+	}
+}
+
+// EventType identifies the kind of an Event.
+type EventType string
+
+// Event types
+const (
+	// EventPinQueued is sent when a peer queues an item for pinning.
+	EventPinQueued EventType = "pin_queued"
+	// EventPinning is sent when a peer starts pinning an item.
+	EventPinning EventType = "pinning"
+	// EventPinDone is sent when a peer finishes pinning an item.
+	EventPinDone EventType = "pin_done"
+	// EventPinError is sent when a peer fails to pin an item.
+	EventPinError EventType = "pin_error"
+	// EventUnpinQueued is sent when a peer queues an item for unpinning.
+	EventUnpinQueued EventType = "unpin_queued"
+	// EventUnpinning is sent when a peer starts unpinning an item.
+	EventUnpinning EventType = "unpinning"
+	// EventUnpinDone is sent when a peer finishes unpinning an item.
+	EventUnpinDone EventType = "unpin_done"
+	// EventUnpinError is sent when a peer fails to unpin an item.
+	EventUnpinError EventType = "unpin_error"
+	// EventPinReallocated is sent by the cluster leader when the
+	// allocations of an item change.
+	EventPinReallocated EventType = "pin_reallocated"
+	// EventUidCreated is sent when a new UID is created.
+	EventUidCreated EventType = "uid_created"
+	// EventUidRenamed is sent when a UID is renamed.
+	EventUidRenamed EventType = "uid_renamed"
+	// EventPeerJoined is sent when a peer joins the cluster peerset.
+	EventPeerJoined EventType = "peer_joined"
+	// EventPeerLeft is sent when a peer leaves the cluster peerset.
+	EventPeerLeft EventType = "peer_left"
+	// EventAlert is sent when the monitor raises a new alert.
+	EventAlert EventType = "alert"
+	// EventFileOp is sent when the files of a UID are modified.
+	EventFileOp EventType = "file_op"
+)
+
+// EventTypes returns all the known event types.
+func EventTypes() []EventType {
+	return []EventType{
+		EventPinQueued,
+		EventPinning,
+		EventPinDone,
+		EventPinError,
+		EventUnpinQueued,
+		EventUnpinning,
+		EventUnpinDone,
+		EventUnpinError,
+		EventPinReallocated,
+		EventUidCreated,
+		EventUidRenamed,
+		EventPeerJoined,
+		EventPeerLeft,
+		EventAlert,
+		EventFileOp,
+	}
+}
+
+// Event describes something which happened in a cluster peer. Only the
+// fields relevant to the event Type are set.
+type Event struct {
+	Type        EventType
+	TS          time.Time
+	Peer        peer.ID
+	Cid         cid.Cid
+	Name        string
+	Allocations []peer.ID
+	UID         string
+	OldUID      string
+	// Target is the peer an event refers to (peer_joined, peer_left,
+	// alert), as opposed to the Peer which produced it.
+	Target peer.ID
+	// Path and Op describe file operations.
+	Path    string
+	Op      string
+	Message string
+}
+
+// EventFilter selects events by type, CID or UID. Empty fields
+// match any event.
+type EventFilter struct {
+	Types []EventType
+	Cid   cid.Cid
+	UID   string
+}
+
+// Match returns true when the event passes the filter.
+func (f EventFilter) Match(ev Event) bool {
+	if len(f.Types) > 0 {
+		found := false
+		for _, t := range f.Types {
+			if t == ev.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Cid.Defined() && !f.Cid.Equals(ev.Cid) {
+		return false
+	}
+	if f.UID != "" && f.UID != ev.UID && f.UID != ev.OldUID {
+		return false
+	}
+	return true
+}
+
+// EventSerial is a serializable version of Event.
+type EventSerial struct {
+	Type        string   `json:"type"`
+	TS          string   `json:"timestamp"`
+	Peer        string   `json:"peer"`
+	Cid         string   `json:"cid,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	Allocations []string `json:"allocations,omitempty"`
+	UID         string   `json:"uid,omitempty"`
+	OldUID      string   `json:"old_uid,omitempty"`
+	Target      string   `json:"target,omitempty"`
+	Path        string   `json:"path,omitempty"`
+	Op          string   `json:"op,omitempty"`
+	Message     string   `json:"message,omitempty"`
+}
+
+// ToSerial converts an Event to its serializable version.
+func (ev Event) ToSerial() EventSerial {
+	c := ""
+	if ev.Cid.Defined() {
+		c = ev.Cid.String()
+	}
+	p := ""
+	if ev.Peer != "" {
+		p = peer.IDB58Encode(ev.Peer)
+	}
+	target := ""
+	if ev.Target != "" {
+		target = peer.IDB58Encode(ev.Target)
+	}
+	return EventSerial{
+		Type:        string(ev.Type),
+		TS:          ev.TS.UTC().Format(time.RFC3339Nano),
+		Peer:        p,
+		Cid:         c,
+		Name:        ev.Name,
+		Allocations: PeersToStrings(ev.Allocations),
+		UID:         ev.UID,
+		OldUID:      ev.OldUID,
+		Target:      target,
+		Path:        ev.Path,
+		Op:          ev.Op,
+		Message:     ev.Message,
+	}
+}
+
+// ToEvent converts an EventSerial to its native version.
+func (evs EventSerial) ToEvent() Event {
+	var c cid.Cid
+	if evs.Cid != "" {
+		var err error
+		c, err = cid.Decode(evs.Cid)
+		if err != nil {
+			logger.Debug(evs.Cid, err)
+		}
+	}
+	var p peer.ID
+	if evs.Peer != "" {
+		var err error
+		p, err = peer.IDB58Decode(evs.Peer)
+		if err != nil {
+			logger.Debug(evs.Peer, err)
+		}
+	}
+	var target peer.ID
+	if evs.Target != "" {
+		var err error
+		target, err = peer.IDB58Decode(evs.Target)
+		if err != nil {
+			logger.Debug(evs.Target, err)
+		}
+	}
+	ts, err := time.Parse(time.RFC3339Nano, evs.TS)
+	if err != nil {
+		logger.Debug(evs.TS, err)
+	}
+	return Event{
+		Type:        EventType(evs.Type),
+		TS:          ts,
+		Peer:        p,
+		Cid:         c,
+		Name:        evs.Name,
+		Allocations: StringsToPeers(evs.Allocations),
+		UID:         evs.UID,
+		OldUID:      evs.OldUID,
+		Target:      target,
+		Path:        evs.Path,
+		Op:          evs.Op,
+		Message:     evs.Message,
 	}
 }
 
@@ -702,12 +1486,145 @@ func (pT PinType) String() string {
 	}
 }
 
+// PinPriority classifies pins according to how urgently the pin trackers
+// should process them. Pins with a higher priority are pinned and unpinned
+// before those with a lower one.
+type PinPriority int
+
+// PinPriority values.
+const (
+	// PriorityBulk is meant for large imports which can wait behind
+	// everything else.
+	PriorityBulk PinPriority = -10
+	// PriorityNormal is the default priority.
+	PriorityNormal PinPriority = 0
+	// PriorityHigh is meant for user home roots and small pins which
+	// users expect to be available right away. Pins created with the
+	// default priority are given this one automatically when they are
+	// either.
+	PriorityHigh PinPriority = 10
+)
+
+// PinPriorityFromString is the inverse of String. Besides the names of the
+// priority classes, it accepts plain numbers. An empty string corresponds
+// to PriorityNormal.
+func PinPriorityFromString(str string) (PinPriority, error) {
+	switch str {
+	case "bulk":
+		return PriorityBulk, nil
+	case "normal", "":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	default:
+		n, err := strconv.Atoi(str)
+		if err != nil {
+			return PriorityNormal, fmt.Errorf("invalid priority: %s", str)
+		}
+		return PinPriority(n), nil
+	}
+}
+
+// String returns a printable value to identify the PinPriority. Values
+// not matching any class are printed as numbers.
+func (pp PinPriority) String() string {
+	switch pp {
+	case PriorityBulk:
+		return "bulk"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return strconv.Itoa(int(pp))
+	}
+}
+
 // PinOptions wraps user-defined options for Pins
 type PinOptions struct {
-	ReplicationFactorMin int    `json:"replication_factor_min"`
-	ReplicationFactorMax int    `json:"replication_factor_max"`
-	Name                 string `json:"name"`
-	ShardSize            uint64 `json:"shard_size"`
+	ReplicationFactorMin int         `json:"replication_factor_min"`
+	ReplicationFactorMax int         `json:"replication_factor_max"`
+	Name                 string      `json:"name"`
+	ShardSize            uint64      `json:"shard_size"`
+	Priority             PinPriority `json:"priority"`
+
+	// SpreadTag names a peer tag (i.e. "region") whose values the
+	// allocations should be spread across. When set, the allocations
+	// must carry at least ReplicationFactorMin distinct values, so the
+	// pin cannot be allocated while fewer values are available.
+	SpreadTag string `json:"spread_tag,omitempty"`
+	// RequiredTags are tags that a peer must carry with the given
+	// values to be allocated the pin.
+	RequiredTags map[string]string `json:"required_tags,omitempty"`
+	// ExcludedTags are tags that a peer must not carry with the given
+	// values to be allocated the pin.
+	ExcludedTags map[string]string `json:"excluded_tags,omitempty"`
+
+	// Public grants everyone access to the pinned content through the
+	// gateway, under /ipfs/<cid>.
+	Public bool `json:"public,omitempty"`
+}
+
+// TagsMetricName is the name of the metrics carrying the tags of a peer,
+// as a JSON-encoded object of string values. See ParseTags().
+const TagsMetricName = "tags"
+
+// ParseTags decodes the value of a tags metric.
+func ParseTags(value string) (map[string]string, error) {
+	tags := make(map[string]string)
+	err := json.Unmarshal([]byte(value), &tags)
+	return tags, err
+}
+
+// HasTagConstraints returns true when the options restrict which peers
+// can be allocated a pin based on their tags.
+func (po PinOptions) HasTagConstraints() bool {
+	return po.SpreadTag != "" || len(po.RequiredTags) > 0 || len(po.ExcludedTags) > 0
+}
+
+// MatchTags returns true when a peer with the given tags carries all the
+// required tags and none of the excluded ones. When a spread tag is set,
+// the peer must carry it too.
+func (po PinOptions) MatchTags(tags map[string]string) bool {
+	for k, v := range po.RequiredTags {
+		if tags[k] != v {
+			return false
+		}
+	}
+	for k, v := range po.ExcludedTags {
+		if tv, ok := tags[k]; ok && tv == v {
+			return false
+		}
+	}
+	if po.SpreadTag != "" {
+		if _, ok := tags[po.SpreadTag]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	if tags == nil {
+		return nil
+	}
+	cp := make(map[string]string, len(tags))
+	for k, v := range tags {
+		cp[k] = v
+	}
+	return cp
+}
+
+func tagsEqual(tags1, tags2 map[string]string) bool {
+	if len(tags1) != len(tags2) {
+		return false
+	}
+	for k, v := range tags1 {
+		if v2, ok := tags2[k]; !ok || v2 != v {
+			return false
+		}
+	}
+	return true
 }
 
 // Pin carries all the information associated to a CID that is pinned
@@ -753,6 +1670,11 @@ func PinWithOpts(c cid.Cid, opts PinOptions) Pin {
 	p.ReplicationFactorMax = opts.ReplicationFactorMax
 	p.Name = opts.Name
 	p.ShardSize = opts.ShardSize
+	p.Priority = opts.Priority
+	p.SpreadTag = opts.SpreadTag
+	p.RequiredTags = copyTags(opts.RequiredTags)
+	p.ExcludedTags = copyTags(opts.ExcludedTags)
+	p.Public = opts.Public
 	return p
 }
 
@@ -765,6 +1687,7 @@ type PinSerial struct {
 	Allocations []string `json:"allocations"`
 	MaxDepth    int      `json:"max_depth"`
 	Reference   string   `json:"reference"`
+	Size        uint64   `json:"size,omitempty"`
 }
 
 // ToSerial converts a Pin to PinSerial.
@@ -787,11 +1710,17 @@ func (pin Pin) ToSerial() PinSerial {
 		Type:        uint64(pin.Type),
 		MaxDepth:    pin.MaxDepth,
 		Reference:   ref,
+		Size:        pin.Size,
 		PinOptions: PinOptions{
 			Name:                 n,
 			ReplicationFactorMin: pin.ReplicationFactorMin,
 			ReplicationFactorMax: pin.ReplicationFactorMax,
 			ShardSize:            pin.ShardSize,
+			Priority:             pin.Priority,
+			SpreadTag:            pin.SpreadTag,
+			RequiredTags:         copyTags(pin.RequiredTags),
+			ExcludedTags:         copyTags(pin.ExcludedTags),
+			Public:               pin.Public,
 		},
 	}
 }
@@ -823,6 +1752,23 @@ func (pin Pin) Equals(pin2 Pin) bool {
 		return false
 	}
 
+	if pin1s.Priority != pin2s.Priority {
+		return false
+	}
+
+	if pin1s.SpreadTag != pin2s.SpreadTag {
+		return false
+	}
+
+	if !tagsEqual(pin1s.RequiredTags, pin2s.RequiredTags) ||
+		!tagsEqual(pin1s.ExcludedTags, pin2s.ExcludedTags) {
+		return false
+	}
+
+	if pin1s.Public != pin2s.Public {
+		return false
+	}
+
 	sort.Strings(pin1s.Allocations)
 	sort.Strings(pin2s.Allocations)
 
@@ -880,11 +1826,17 @@ func (pins PinSerial) ToPin() Pin {
 		Type:        PinType(pins.Type),
 		MaxDepth:    pins.MaxDepth,
 		Reference:   ref,
+		Size:        pins.Size,
 		PinOptions: PinOptions{
 			Name:                 pins.Name,
 			ReplicationFactorMin: pins.ReplicationFactorMin,
 			ReplicationFactorMax: pins.ReplicationFactorMax,
 			ShardSize:            pins.ShardSize,
+			Priority:             pins.Priority,
+			SpreadTag:            pins.SpreadTag,
+			RequiredTags:         copyTags(pins.RequiredTags),
+			ExcludedTags:         copyTags(pins.ExcludedTags),
+			Public:               pins.Public,
 		},
 	}
 }
@@ -895,6 +1847,8 @@ func (pins PinSerial) Clone() PinSerial {
 	// slices are pointers. We need to explicitally copy them.
 	new.Allocations = make([]string, len(pins.Allocations))
 	copy(new.Allocations, pins.Allocations)
+	new.RequiredTags = copyTags(pins.RequiredTags)
+	new.ExcludedTags = copyTags(pins.ExcludedTags)
 	return new
 }
 
@@ -1002,10 +1956,176 @@ func (m *Metric) UnmarshalJSON(j []byte) error {
 	return nil
 }
 
-// Alert carries alerting information about a peer. WIP.
+// MetricsSet holds the latest metrics of a peer for several informers,
+// indexed by metric name.
+type MetricsSet map[string]Metric
+
+// MetricsByName extracts the metrics of the given name from a map of
+// MetricsSets. Peers without such metric are left out.
+func MetricsByName(sets map[peer.ID]MetricsSet, name string) map[peer.ID]Metric {
+	metrics := make(map[peer.ID]Metric, len(sets))
+	for p, set := range sets {
+		if m, ok := set[name]; ok {
+			metrics[p] = m
+		}
+	}
+	return metrics
+}
+
+// MetricSample is a metric value as it was received by a peer.
+type MetricSample struct {
+	Value string
+	Valid bool
+	TS    time.Time
+}
+
+// MetricSampleSerial is a serializable version of MetricSample.
+type MetricSampleSerial struct {
+	Value string `json:"value"`
+	Valid bool   `json:"valid"`
+	TS    string `json:"timestamp"`
+}
+
+// MetricHistory holds the recent values of a metric from a peer, oldest
+// first, along with some aggregates over the numeric values of the valid
+// samples. Rate is the change of the value per second between the first
+// and the last of those samples.
+type MetricHistory struct {
+	Name    string
+	Peer    peer.ID
+	Samples []MetricSample
+	Min     float64
+	Max     float64
+	Avg     float64
+	Rate    float64
+}
+
+// MetricHistorySerial is a serializable version of MetricHistory.
+type MetricHistorySerial struct {
+	Name    string               `json:"name"`
+	Peer    string               `json:"peer"`
+	Samples []MetricSampleSerial `json:"samples"`
+	Min     float64              `json:"min"`
+	Max     float64              `json:"max"`
+	Avg     float64              `json:"avg"`
+	Rate    float64              `json:"rate"`
+}
+
+// ToSerial converts a MetricHistory to its serializable version.
+func (h MetricHistory) ToSerial() MetricHistorySerial {
+	samples := make([]MetricSampleSerial, len(h.Samples))
+	for i, s := range h.Samples {
+		samples[i] = MetricSampleSerial{
+			Value: s.Value,
+			Valid: s.Valid,
+			TS:    s.TS.UTC().Format(time.RFC3339Nano),
+		}
+	}
+	return MetricHistorySerial{
+		Name:    h.Name,
+		Peer:    peer.IDB58Encode(h.Peer),
+		Samples: samples,
+		Min:     h.Min,
+		Max:     h.Max,
+		Avg:     h.Avg,
+		Rate:    h.Rate,
+	}
+}
+
+// ToMetricHistory converts a MetricHistorySerial to its native version.
+func (hs MetricHistorySerial) ToMetricHistory() MetricHistory {
+	p, err := peer.IDB58Decode(hs.Peer)
+	if err != nil {
+		logger.Debug(hs.Peer, err)
+	}
+	samples := make([]MetricSample, len(hs.Samples))
+	for i, s := range hs.Samples {
+		ts, err := time.Parse(time.RFC3339Nano, s.TS)
+		if err != nil {
+			logger.Debug(s.TS, err)
+		}
+		samples[i] = MetricSample{
+			Value: s.Value,
+			Valid: s.Valid,
+			TS:    ts,
+		}
+	}
+	return MetricHistory{
+		Name:    hs.Name,
+		Peer:    p,
+		Samples: samples,
+		Min:     hs.Min,
+		Max:     hs.Max,
+		Avg:     hs.Avg,
+		Rate:    hs.Rate,
+	}
+}
+
+// IPFSDownAlertName is the MetricName of the alerts sent when a peer
+// cannot reach its IPFS daemon.
+const IPFSDownAlertName = "ipfs_down"
+
+// Alert carries alerting information about a peer. MetricName identifies
+// the metric or the condition which triggered it and Value carries the
+// offending metric value, if any. Policy, TS and Expire are set by the
+// cluster peer which records the alert.
 type Alert struct {
 	Peer       peer.ID
 	MetricName string
+	Value      string
+	Message    string
+	Policy     string
+	TS         time.Time
+	Expire     time.Time
+}
+
+// AlertSerial is a serializable version of Alert.
+type AlertSerial struct {
+	Peer       string `json:"peer"`
+	MetricName string `json:"metric"`
+	Value      string `json:"value,omitempty"`
+	Message    string `json:"message,omitempty"`
+	Policy     string `json:"policy"`
+	TS         string `json:"timestamp"`
+	Expire     string `json:"expire"`
+}
+
+// ToSerial converts an Alert to its serializable version.
+func (a Alert) ToSerial() AlertSerial {
+	return AlertSerial{
+		Peer:       peer.IDB58Encode(a.Peer),
+		MetricName: a.MetricName,
+		Value:      a.Value,
+		Message:    a.Message,
+		Policy:     a.Policy,
+		TS:         a.TS.UTC().Format(time.RFC3339),
+		Expire:     a.Expire.UTC().Format(time.RFC3339),
+	}
+}
+
+// ToAlert converts an AlertSerial to its native version.
+func (as AlertSerial) ToAlert() Alert {
+	p, err := peer.IDB58Decode(as.Peer)
+	if err != nil {
+		logger.Debug(as.Peer, err)
+	}
+	ts, err := time.Parse(time.RFC3339, as.TS)
+	if err != nil {
+		logger.Debug(as.TS, err)
+	}
+	expire, err := time.Parse(time.RFC3339, as.Expire)
+	if err != nil {
+		logger.Debug(as.Expire, err)
+	}
+	return Alert{
+		Peer:       p,
+		MetricName: as.MetricName,
+		Value:      as.Value,
+		Message:    as.Message,
+		Policy:     as.Policy,
+		TS:         ts,
+		Expire:     expire,
+	}
 }
 
 // Error can be used by APIs to return errors.
@@ -1057,6 +2177,14 @@ type FileLsEntrie struct {
 	Hash string
 }
 
+// FileCat selects the slice of a file read by IPFSConnector.Cat: up to
+// Length bytes from Offset.
+type FileCat struct {
+	Path   string
+	Offset uint64
+	Length uint64
+}
+
 type FilesStat struct {
 	Hash           string
 	Size           uint64
@@ -1068,6 +2196,146 @@ type FilesStat struct {
 	SizeLocal      uint64
 }
 
+// TrashEntry describes an entry moved to the trash of a UID home.
+type TrashEntry struct {
+	// ID is the name of the entry inside the trash folder.
+	ID string
+	// Path is where the entry was in the home before being deleted.
+	Path    string
+	Deleted time.Time
+	Type    string
+	Size    uint64
+	Hash    string
+}
+
+// TrashLs wraps the entries in the trash of a UID home.
+type TrashLs struct {
+	Entries []TrashEntry
+}
+
+// FileOp is one of the operations in a batch run on the files of a UID
+// home. Op is one of "mkdir", "write", "cp", "mv" or "rm". Paths are
+// relative to the home.
+type FileOp struct {
+	Op   string
+	Path string
+	// Source is what cp copies: an /ipfs/ path or a path in the home.
+	Source string
+	// Dest is where mv moves Path.
+	Dest string
+	// Data is what write writes to Path, replacing its content.
+	Data []byte
+	// Parents creates the missing parent directories.
+	Parents bool
+	// Recursive allows rm to remove directories.
+	Recursive bool
+	// Permanent makes rm skip the trash.
+	Permanent bool
+}
+
+// FilesBatch is a list of operations to run on the home of a UID as a
+// single unit.
+type FilesBatch struct {
+	UID string
+	Ops []FileOp
+}
+
+// FilesBatchResult is the outcome of a successful FilesBatch.
+type FilesBatchResult struct {
+	// Root is the hash of the new home root.
+	Root string
+	// Trashed are the entries moved to the trash by rm operations.
+	Trashed []TrashEntry
+}
+
+// StagingHomePrefix starts the names of the temporary homes where changes
+// to a UID home are staged before replacing it, i.e. by batches and
+// logins. They live next to the UID homes, under /nodes, but are not
+// homes themselves.
+const StagingHomePrefix = ".batch-"
+
+// NewStagingHome returns a new name for a temporary home staging changes
+// to the home of the given UID.
+func NewStagingHome(uid string) string {
+	return fmt.Sprintf("%s%s-%d", StagingHomePrefix, uid, time.Now().UnixNano())
+}
+
+// IsStagingHome returns true when a name in the homes folder is a staged
+// copy of a home rather than a UID home.
+func IsStagingHome(name string) bool {
+	return strings.HasPrefix(name, StagingHomePrefix)
+}
+
+// StagedUID returns the UID whose home is staged under the given name,
+// as returned by NewStagingHome.
+func StagedUID(name string) string {
+	name = strings.TrimPrefix(name, StagingHomePrefix)
+	if i := strings.LastIndex(name, "-"); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
+
+// FileMeta is the metadata of a file or directory in a UID home, as kept
+// by the search index.
+type FileMeta struct {
+	Path string
+	Name string
+	// Size is the file size, or the cumulative size for directories.
+	Size uint64
+	Type string
+	Hash string
+	// Modified is when the search index found the entry changed, not a
+	// modification time kept by IPFS: MFS does not keep them. Entries
+	// found when a home is first indexed have the time of the indexing.
+	Modified time.Time
+}
+
+// SearchQuery selects entries of the search index of a UID home. Empty
+// fields match any entry.
+type SearchQuery struct {
+	UID string
+	// Path limits the search to the entries below a directory.
+	Path string
+	// Name is a glob matched against the entry names (i.e. "*.jpg").
+	Name string
+	// Type is "file" or "directory".
+	Type    string
+	MinSize uint64
+	// MaxSize is ignored when 0.
+	MaxSize uint64
+	// After and Before limit the modification time.
+	After  time.Time
+	Before time.Time
+	// Limit is the maximum number of results, unlimited when 0.
+	Limit int
+}
+
+// Match returns true when the entry passes the query.
+func (q SearchQuery) Match(m FileMeta) bool {
+	if q.Path != "" && q.Path != "/" && !strings.HasPrefix(m.Path, strings.TrimSuffix(q.Path, "/")+"/") {
+		return false
+	}
+	if q.Name != "" {
+		if ok, _ := path.Match(q.Name, m.Name); !ok {
+			return false
+		}
+	}
+	if q.Type != "" && q.Type != m.Type {
+		return false
+	}
+	if m.Size < q.MinSize || (q.MaxSize > 0 && m.Size > q.MaxSize) {
+		return false
+	}
+	if !q.After.IsZero() && m.Modified.Before(q.After) {
+		return false
+	}
+	if !q.Before.IsZero() && !m.Modified.Before(q.Before) {
+		return false
+	}
+	return true
+}
+
 type FilesWrite struct {
 	ContentType string
 	BodyBuf     *bytes.Buffer
@@ -49,6 +49,28 @@ func TestIPFSPinStatusFromString(t *testing.T) {
 	}
 }
 
+func TestPinPriorityFromString(t *testing.T) {
+	for _, pp := range []PinPriority{PriorityBulk, PriorityNormal, PriorityHigh, PinPriority(3)} {
+		pp2, err := PinPriorityFromString(pp.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pp2 != pp {
+			t.Errorf("%s does not match PinPriority %d", pp, pp2)
+		}
+	}
+
+	pp, err := PinPriorityFromString("")
+	if err != nil || pp != PriorityNormal {
+		t.Error("expected normal priority for empty strings")
+	}
+
+	_, err = PinPriorityFromString("xyz")
+	if err == nil {
+		t.Error("expected an error for bad strings")
+	}
+}
+
 func TestGlobalPinInfoConv(t *testing.T) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -67,6 +89,7 @@ func TestGlobalPinInfoConv(t *testing.T) {
 			},
 		},
 	}
+	gpi.Shards = []GlobalPinInfo{gpi}
 
 	newgpi := gpi.ToSerial().ToGlobalPinInfo()
 	if gpi.Cid.String() != newgpi.Cid.String() {
@@ -79,6 +102,10 @@ func TestGlobalPinInfoConv(t *testing.T) {
 	if !gpi.PeerMap[testPeerID1].TS.Equal(newgpi.PeerMap[testPeerID1].TS) {
 		t.Error("bad time")
 	}
+
+	if len(newgpi.Shards) != 1 || newgpi.Shards[0].Cid.String() != gpi.Cid.String() {
+		t.Error("mismatching shards")
+	}
 }
 
 func TestIDConv(t *testing.T) {
@@ -198,10 +225,14 @@ func TestPinConv(t *testing.T) {
 		Allocations: []peer.ID{testPeerID1},
 		Reference:   testCid2,
 		MaxDepth:    -1,
+		Size:        1024,
 		PinOptions: PinOptions{
 			ReplicationFactorMax: -1,
 			ReplicationFactorMin: -1,
 			Name:                 "A test pin",
+			Priority:             PriorityHigh,
+			SpreadTag:            "region",
+			RequiredTags:         map[string]string{"tier": "ssd"},
 		},
 	}
 
@@ -212,7 +243,11 @@ func TestPinConv(t *testing.T) {
 		c.ReplicationFactorMax != newc.ReplicationFactorMax ||
 		c.MaxDepth != newc.MaxDepth ||
 		!c.Reference.Equals(newc.Reference) ||
-		c.Name != newc.Name || c.Type != newc.Type {
+		c.Name != newc.Name || c.Type != newc.Type ||
+		c.Priority != newc.Priority ||
+		c.Size != newc.Size ||
+		c.SpreadTag != newc.SpreadTag ||
+		newc.RequiredTags["tier"] != "ssd" {
 
 		fmt.Printf("c: %+v\ncnew: %+v\n", c, newc)
 		t.Fatal("mismatch")
@@ -221,6 +256,40 @@ func TestPinConv(t *testing.T) {
 	if !c.Equals(newc) {
 		t.Error("all pin fields are equal but Equals returns false")
 	}
+
+	newc.ExcludedTags = map[string]string{"rack": "r1"}
+	if c.Equals(newc) {
+		t.Error("pins with different tags should not be equal")
+	}
+}
+
+func TestPinOptionsMatchTags(t *testing.T) {
+	po := PinOptions{
+		SpreadTag:    "region",
+		RequiredTags: map[string]string{"tier": "ssd"},
+		ExcludedTags: map[string]string{"rack": "r1"},
+	}
+
+	testcases := []struct {
+		tags  map[string]string
+		match bool
+	}{
+		{map[string]string{"region": "eu", "tier": "ssd", "rack": "r2"}, true},
+		{map[string]string{"region": "eu", "tier": "hdd", "rack": "r2"}, false},
+		{map[string]string{"region": "eu", "tier": "ssd", "rack": "r1"}, false},
+		{map[string]string{"tier": "ssd"}, false},
+		{nil, false},
+	}
+
+	for i, tc := range testcases {
+		if po.MatchTags(tc.tags) != tc.match {
+			t.Errorf("testcase %d: expected match to be %t", i, tc.match)
+		}
+	}
+
+	if !(PinOptions{}).MatchTags(nil) {
+		t.Error("options without tags should match any peer")
+	}
 }
 
 func TestMetric(t *testing.T) {
@@ -263,6 +332,280 @@ func TestMetric(t *testing.T) {
 	}
 }
 
+func TestListPage(t *testing.T) {
+	pins := []Pin{
+		PinCid(testCid3),
+		PinCid(testCid1),
+		PinCid(testCid4),
+		PinCid(testCid2),
+	}
+
+	page := ListPage{}.Pins(pins)
+	if len(page) != 4 {
+		t.Fatal("an empty page should select all pins")
+	}
+	if !page[0].Cid.Equals(testCid1) || !page[3].Cid.Equals(testCid3) {
+		t.Error("pins should be sorted by cid")
+	}
+
+	page = ListPage{Limit: 2}.Pins(pins)
+	if len(page) != 2 || !page[1].Cid.Equals(testCid2) {
+		t.Fatal("expected the first two pins")
+	}
+
+	page = ListPage{Cursor: page[1].Cid.String(), Limit: 2}.Pins(pins)
+	if len(page) != 2 || !page[0].Cid.Equals(testCid4) || !page[1].Cid.Equals(testCid3) {
+		t.Fatal("expected the last two pins")
+	}
+
+	page = ListPage{Cursor: testCid3.String(), Limit: 2}.Pins(pins)
+	if len(page) != 0 {
+		t.Error("expected an empty page after the last cid")
+	}
+}
+
+func TestPinsHealthReportConv(t *testing.T) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatal("paniced")
+		}
+	}()
+
+	report := PinsHealthReport{
+		Peer:        testPeerID1,
+		TS:          testTime,
+		ClusterSize: 3,
+		Total:       4,
+		Summary: map[PinHealthState]int{
+			PinHealthOK:   3,
+			PinHealthLost: 1,
+		},
+		Worst: []PinHealth{
+			{
+				Cid:                  testCid1,
+				Name:                 "a",
+				ReplicationFactorMin: 1,
+				ReplicationFactorMax: 2,
+				Allocations:          []peer.ID{testPeerID2, testPeerID3},
+				Pinned:               0,
+				Down:                 []peer.ID{testPeerID2},
+				Errored:              []peer.ID{testPeerID3},
+				State:                PinHealthLost,
+			},
+		},
+	}
+
+	newReport := report.ToSerial().ToPinsHealthReport()
+	if !reflect.DeepEqual(report, newReport) {
+		t.Error("the new report should be equivalent to the old")
+	}
+}
+
+func TestRebalanceReportConv(t *testing.T) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatal("paniced")
+		}
+	}()
+
+	report := RebalanceReport{
+		Peer:   testPeerID1,
+		TS:     testTime,
+		Metric: "numpin",
+		DryRun: true,
+		Loads: map[peer.ID]string{
+			testPeerID2: "10",
+			testPeerID3: "2",
+		},
+		Moves: []RebalanceMove{
+			{
+				Cid:  testCid1,
+				Name: "a",
+				From: testPeerID2,
+				To:   testPeerID3,
+			},
+		},
+	}
+
+	newReport := report.ToSerial().ToRebalanceReport()
+	if !reflect.DeepEqual(report, newReport) {
+		t.Error("the new report should be equivalent to the old")
+	}
+}
+
+func TestDrainInfoConv(t *testing.T) {
+	di := DrainInfo{
+		Peer:      testPeerID1,
+		Remaining: 3,
+	}
+	if di.ToSerial().ToDrainInfo() != di {
+		t.Error("the new drain info should be equivalent to the old")
+	}
+}
+
+func TestAlertConv(t *testing.T) {
+	ts, _ := time.Parse(time.RFC3339, "2018-10-18T10:00:00Z")
+	a := Alert{
+		Peer:       testPeerID1,
+		MetricName: "freespace",
+		Value:      "1024",
+		Message:    "freespace below 2048",
+		Policy:     "stop_allocating",
+		TS:         ts,
+		Expire:     ts.Add(time.Minute),
+	}
+	newA := a.ToSerial().ToAlert()
+	if !reflect.DeepEqual(a, newA) {
+		t.Error("the new alert should be equivalent to the old")
+	}
+}
+
+func TestMetricHistoryConv(t *testing.T) {
+	ts, _ := time.Parse(time.RFC3339Nano, "2018-10-18T10:00:00.5Z")
+	h := MetricHistory{
+		Name: "freespace",
+		Peer: testPeerID1,
+		Samples: []MetricSample{
+			{Value: "10", Valid: true, TS: ts},
+			{Value: "30", Valid: true, TS: ts.Add(time.Second)},
+		},
+		Min:  10,
+		Max:  30,
+		Avg:  20,
+		Rate: 20,
+	}
+	newH := h.ToSerial().ToMetricHistory()
+	if !reflect.DeepEqual(h, newH) {
+		t.Error("the new metric history should be equivalent to the old")
+	}
+}
+
+func TestEventConv(t *testing.T) {
+	ts, _ := time.Parse(time.RFC3339Nano, "2018-10-18T10:00:00.5Z")
+	ev := Event{
+		Type:        EventPinReallocated,
+		TS:          ts,
+		Peer:        testPeerID1,
+		Cid:         testCid1,
+		Name:        "a",
+		Allocations: []peer.ID{testPeerID2, testPeerID3},
+	}
+	newEv := ev.ToSerial().ToEvent()
+	if !reflect.DeepEqual(ev, newEv) {
+		t.Error("the new event should be equivalent to the old")
+	}
+
+	ev = Event{
+		Type:   EventPeerJoined,
+		TS:     ts,
+		Peer:   testPeerID1,
+		Target: testPeerID2,
+	}
+	newEv = ev.ToSerial().ToEvent()
+	if !reflect.DeepEqual(ev, newEv) {
+		t.Error("the new event should be equivalent to the old")
+	}
+}
+
+func TestEventFilterMatch(t *testing.T) {
+	ev := Event{
+		Type: EventPinDone,
+		Cid:  testCid1,
+		UID:  "uid-a",
+	}
+
+	if !(EventFilter{}).Match(ev) {
+		t.Error("empty filter should match")
+	}
+	if !(EventFilter{Types: []EventType{EventPinError, EventPinDone}}).Match(ev) {
+		t.Error("filter by type should match")
+	}
+	if (EventFilter{Types: []EventType{EventPinError}}).Match(ev) {
+		t.Error("filter by type should not match")
+	}
+	if !(EventFilter{Cid: testCid1}).Match(ev) {
+		t.Error("filter by cid should match")
+	}
+	if (EventFilter{Cid: testCid2}).Match(ev) {
+		t.Error("filter by cid should not match")
+	}
+	if !(EventFilter{UID: "uid-a"}).Match(ev) {
+		t.Error("filter by uid should match")
+	}
+	if (EventFilter{UID: "uid-b", Cid: testCid1}).Match(ev) {
+		t.Error("filter by uid should not match")
+	}
+}
+
+func TestSearchQueryMatch(t *testing.T) {
+	now := time.Now()
+	m := FileMeta{
+		Path:     "/photos/2018/beach.jpg",
+		Name:     "beach.jpg",
+		Size:     2048,
+		Type:     "file",
+		Modified: now,
+	}
+
+	if !(SearchQuery{}).Match(m) {
+		t.Error("empty query should match")
+	}
+	if !(SearchQuery{Path: "/photos/", Name: "*.jpg", Type: "file"}).Match(m) {
+		t.Error("query by path, name and type should match")
+	}
+	if (SearchQuery{Path: "/photo"}).Match(m) {
+		t.Error("query by path should not match a path prefix")
+	}
+	if (SearchQuery{Name: "*.png"}).Match(m) {
+		t.Error("query by name should not match")
+	}
+	if (SearchQuery{Type: "directory"}).Match(m) {
+		t.Error("query by type should not match")
+	}
+	if !(SearchQuery{MinSize: 1024, MaxSize: 4096}).Match(m) {
+		t.Error("query by size should match")
+	}
+	if (SearchQuery{MaxSize: 1024}).Match(m) {
+		t.Error("query by size should not match")
+	}
+	if !(SearchQuery{After: now.Add(-time.Hour), Before: now.Add(time.Hour)}).Match(m) {
+		t.Error("query by time should match")
+	}
+	if (SearchQuery{Before: now}).Match(m) {
+		t.Error("query by time should not match")
+	}
+}
+
+func TestPinsHealthReportSortWorst(t *testing.T) {
+	report := PinsHealthReport{
+		ClusterSize: 4,
+		Worst: []PinHealth{
+			{Cid: testCid1, ReplicationFactorMin: 2, Pinned: 2, State: PinHealthDegraded},
+			{Cid: testCid2, ReplicationFactorMin: 3, Pinned: 2, State: PinHealthUnderReplicated},
+			{Cid: testCid3, ReplicationFactorMin: -1, Pinned: 1, State: PinHealthUnderReplicated},
+			{Cid: testCid4, ReplicationFactorMin: 2, Pinned: 0, State: PinHealthLost},
+		},
+	}
+	report.SortWorst()
+
+	expected := []cid.Cid{testCid4, testCid3, testCid2, testCid1}
+	for i, c := range expected {
+		if !report.Worst[i].Cid.Equals(c) {
+			t.Errorf("unexpected pin at position %d: %s", i, report.Worst[i].Cid)
+		}
+	}
+}
+
+func TestStagingHome(t *testing.T) {
+	name := NewStagingHome("uid-test")
+	if !IsStagingHome(name) || IsStagingHome("uid-test") {
+		t.Error("staging homes not detected")
+	}
+	if uid := StagedUID(name); uid != "uid-test" {
+		t.Error("unexpected staged UID:", uid)
+	}
+}
+
 func BenchmarkPinSerial_ToPin(b *testing.B) {
 	pin := Pin{
 		Cid:         testCid1,
@@ -0,0 +1,223 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/config"
+)
+
+const (
+	configKey    = "webhook"
+	envConfigKey = "cluster_webhook"
+)
+
+// Default values for Config.
+const (
+	DefaultQueueFile        = "webhook_queue.json"
+	DefaultMaxQueueSize     = 10000
+	DefaultMaxRetries       = 10
+	DefaultRetryInterval    = 5 * time.Second
+	DefaultMaxRetryInterval = 10 * time.Minute
+	DefaultRequestTimeout   = 10 * time.Second
+)
+
+// Endpoint describes a URL which receives the events produced by
+// this peer.
+type Endpoint struct {
+	// URL to POST the events to.
+	URL string `json:"url"`
+
+	// Events lists the types of events sent to this endpoint. All
+	// events are sent when empty.
+	Events []api.EventType `json:"events,omitempty"`
+
+	// Secret is used to sign the body of the requests with
+	// HMAC-SHA256. Requests are not signed when empty.
+	Secret string `json:"secret,omitempty"`
+}
+
+// wants returns true if the endpoint is subscribed to the given
+// event type.
+func (e Endpoint) wants(t api.EventType) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, et := range e.Events {
+		if et == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Config allows to customize the behaviour of the webhook sink.
+// It implements the config.ComponentConfig interface.
+type Config struct {
+	config.Saver
+
+	// Endpoints receiving events. The sink does nothing when empty.
+	Endpoints []Endpoint
+
+	// File, relative to the configuration folder, where pending
+	// deliveries are journaled so that they survive restarts.
+	QueueFile string
+
+	// Maximum number of pending deliveries of every endpoint. New
+	// events for an endpoint are dropped when its queue is full.
+	MaxQueueSize int
+
+	// Number of times a failed delivery is retried before giving up.
+	MaxRetries int
+
+	// Time to wait before the first retry. It doubles on every
+	// further attempt up to MaxRetryInterval.
+	RetryInterval time.Duration
+
+	// Maximum time to wait between retries.
+	MaxRetryInterval time.Duration
+
+	// Timeout for every request made to an endpoint.
+	RequestTimeout time.Duration
+}
+
+type jsonConfig struct {
+	Endpoints        []Endpoint `json:"endpoints"`
+	QueueFile        string     `json:"queue_file,omitempty"`
+	MaxQueueSize     int        `json:"max_queue_size"`
+	MaxRetries       int        `json:"max_retries"`
+	RetryInterval    string     `json:"retry_interval"`
+	MaxRetryInterval string     `json:"max_retry_interval"`
+	RequestTimeout   string     `json:"request_timeout"`
+}
+
+// ConfigKey provides a human-friendly identifier for this type of Config.
+func (cfg *Config) ConfigKey() string {
+	return configKey
+}
+
+// Default sets the fields of this Config to sensible default values.
+func (cfg *Config) Default() error {
+	cfg.Endpoints = []Endpoint{}
+	cfg.QueueFile = ""
+	cfg.MaxQueueSize = DefaultMaxQueueSize
+	cfg.MaxRetries = DefaultMaxRetries
+	cfg.RetryInterval = DefaultRetryInterval
+	cfg.MaxRetryInterval = DefaultMaxRetryInterval
+	cfg.RequestTimeout = DefaultRequestTimeout
+	return nil
+}
+
+// Validate checks that the fields of this Config have sensible values,
+// at least in appearance.
+func (cfg *Config) Validate() error {
+	validTypes := make(map[api.EventType]struct{})
+	for _, t := range api.EventTypes() {
+		validTypes[t] = struct{}{}
+	}
+
+	for _, e := range cfg.Endpoints {
+		u, err := url.Parse(e.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook.endpoints: invalid url: %s", e.URL)
+		}
+		for _, t := range e.Events {
+			if _, ok := validTypes[t]; !ok {
+				return fmt.Errorf("webhook.endpoints: unknown event type: %s", t)
+			}
+		}
+	}
+
+	switch {
+	case cfg.MaxQueueSize <= 0:
+		return errors.New("webhook.max_queue_size must be greater than 0")
+	case cfg.MaxRetries < 0:
+		return errors.New("webhook.max_retries is invalid")
+	case cfg.RetryInterval <= 0:
+		return errors.New("webhook.retry_interval must be greater than 0")
+	case cfg.MaxRetryInterval < cfg.RetryInterval:
+		return errors.New("webhook.max_retry_interval must be greater or equal than retry_interval")
+	case cfg.RequestTimeout <= 0:
+		return errors.New("webhook.request_timeout must be greater than 0")
+	}
+	return nil
+}
+
+// LoadJSON parses a JSON representation of this Config as generated by ToJSON.
+func (cfg *Config) LoadJSON(raw []byte) error {
+	jcfg := &jsonConfig{}
+	err := json.Unmarshal(raw, jcfg)
+	if err != nil {
+		logger.Error("Error unmarshaling webhook config")
+		return err
+	}
+
+	cfg.Default()
+
+	// override json config with env var
+	err = envconfig.Process(envConfigKey, jcfg)
+	if err != nil {
+		return err
+	}
+
+	if jcfg.Endpoints != nil {
+		cfg.Endpoints = jcfg.Endpoints
+	}
+	config.SetIfNotDefault(jcfg.QueueFile, &cfg.QueueFile)
+	config.SetIfNotDefault(jcfg.MaxQueueSize, &cfg.MaxQueueSize)
+	config.SetIfNotDefault(jcfg.MaxRetries, &cfg.MaxRetries)
+
+	err = config.ParseDurations(
+		configKey,
+		&config.DurationOpt{Duration: jcfg.RetryInterval, Dst: &cfg.RetryInterval, Name: "retry_interval"},
+		&config.DurationOpt{Duration: jcfg.MaxRetryInterval, Dst: &cfg.MaxRetryInterval, Name: "max_retry_interval"},
+		&config.DurationOpt{Duration: jcfg.RequestTimeout, Dst: &cfg.RequestTimeout, Name: "request_timeout"},
+	)
+	if err != nil {
+		return err
+	}
+
+	return cfg.Validate()
+}
+
+// ToJSON generates a human-friendly JSON representation of this Config.
+func (cfg *Config) ToJSON() ([]byte, error) {
+	jcfg := &jsonConfig{
+		Endpoints:        cfg.Endpoints,
+		QueueFile:        cfg.QueueFile,
+		MaxQueueSize:     cfg.MaxQueueSize,
+		MaxRetries:       cfg.MaxRetries,
+		RetryInterval:    cfg.RetryInterval.String(),
+		MaxRetryInterval: cfg.MaxRetryInterval.String(),
+		RequestTimeout:   cfg.RequestTimeout.String(),
+	}
+	if jcfg.Endpoints == nil {
+		jcfg.Endpoints = []Endpoint{}
+	}
+
+	return config.DefaultJSONMarshal(jcfg)
+}
+
+// GetQueuePath returns the full path of the file used to persist the
+// pending deliveries, obtained by concatenating QueueFile with the
+// BaseDir of the configuration. An empty string is returned when
+// BaseDir is not set, in which case the queue is only kept in memory.
+func (cfg *Config) GetQueuePath() string {
+	if cfg.BaseDir == "" {
+		return ""
+	}
+
+	filename := DefaultQueueFile
+	if cfg.QueueFile != "" {
+		filename = cfg.QueueFile
+	}
+
+	return filepath.Join(cfg.BaseDir, filename)
+}
@@ -0,0 +1,142 @@
+package webhook
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+)
+
+var cfgJSON = []byte(`
+{
+      "endpoints": [
+            {
+                  "url": "http://127.0.0.1:8080/events",
+                  "events": ["pin_done", "pin_error"],
+                  "secret": "abc"
+            },
+            {
+                  "url": "https://example.com/hook"
+            }
+      ],
+      "max_queue_size": 100,
+      "max_retries": 3,
+      "retry_interval": "1s",
+      "max_retry_interval": "1m",
+      "request_timeout": "5s"
+}
+`)
+
+func TestLoadJSON(t *testing.T) {
+	cfg := &Config{}
+	err := cfg.LoadJSON(cfgJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cfg.Endpoints) != 2 ||
+		cfg.Endpoints[0].Secret != "abc" ||
+		len(cfg.Endpoints[0].Events) != 2 {
+		t.Error("error parsing endpoints")
+	}
+
+	if cfg.MaxQueueSize != 100 ||
+		cfg.MaxRetries != 3 ||
+		cfg.RetryInterval != time.Second ||
+		cfg.MaxRetryInterval != time.Minute ||
+		cfg.RequestTimeout != 5*time.Second {
+		t.Error("error parsing values")
+	}
+
+	j := &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.Endpoints[0].URL = "ftp://abc"
+	tst, _ := json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error in endpoint url")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.Endpoints[0].Events = []api.EventType{"abc"}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error in endpoint events")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.RetryInterval = "-1s"
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error in retry_interval")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.MaxRetryInterval = "10ms"
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error in max_retry_interval")
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	cfg := &Config{}
+	cfg.LoadJSON(cfgJSON)
+	newjson, err := cfg.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = &Config{}
+	err = cfg.LoadJSON(newjson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Endpoints) != 2 {
+		t.Error("endpoints were not preserved")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	cfg := &Config{}
+	cfg.Default()
+	if cfg.Validate() != nil {
+		t.Fatal("error validating")
+	}
+
+	cfg.MaxQueueSize = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.RequestTimeout = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+}
+
+func TestGetQueuePath(t *testing.T) {
+	cfg := &Config{}
+	cfg.Default()
+	if cfg.GetQueuePath() != "" {
+		t.Error("expected empty path without base dir")
+	}
+
+	cfg.SetBaseDir("/tmp/cluster")
+	if cfg.GetQueuePath() != filepath.Join("/tmp/cluster", DefaultQueueFile) {
+		t.Error("unexpected queue path")
+	}
+
+	cfg.QueueFile = "q.json"
+	if cfg.GetQueuePath() != filepath.Join("/tmp/cluster", "q.json") {
+		t.Error("unexpected queue path")
+	}
+}
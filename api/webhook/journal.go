@@ -0,0 +1,326 @@
+package webhook
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The journal is compacted when it holds more than journalCompactRatio
+// records per pending delivery, plus journalCompactMin.
+var (
+	journalCompactRatio = 4
+	journalCompactMin   = 1000
+)
+
+// Operations recorded in the journal.
+const (
+	opAdd   = "add"
+	opRetry = "retry"
+	opDone  = "done"
+)
+
+// record is a line of the journal.
+type record struct {
+	Op          string    `json:"op"`
+	ID          uint64    `json:"id"`
+	Delivery    *delivery `json:"delivery,omitempty"`
+	Attempts    int       `json:"attempts,omitempty"`
+	NextAttempt time.Time `json:"next_attempt,omitempty"`
+}
+
+// journal persists the pending deliveries as an append-only log of JSON
+// records, one per line, so that queuing or completing a delivery does
+// not rewrite the whole queue. Records are written by a background
+// goroutine and never block the callers. The log is compacted when it is
+// opened and when it grows much larger than the set of pending
+// deliveries.
+//
+// A journal with an empty path keeps nothing on disk.
+type journal struct {
+	path string
+	file *os.File
+
+	mux     sync.Mutex
+	lastID  uint64
+	state   map[uint64]delivery // pending deliveries, as recorded
+	pending []record            // records not written yet
+	records int                 // records in the file
+
+	notify chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// openJournal reads the journal at path, compacts it and starts writing
+// to it. It returns the pending deliveries in the order they were queued.
+func openJournal(path string) (*journal, []*delivery, error) {
+	j := &journal{
+		path:   path,
+		state:  make(map[uint64]delivery),
+		notify: make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	if path != "" {
+		err := j.load()
+		if err != nil {
+			return nil, nil, err
+		}
+		err = j.compact(j.snapshot())
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	go j.run()
+
+	snapshot := j.snapshot()
+	deliveries := make([]*delivery, len(snapshot))
+	for i := range snapshot {
+		d := snapshot[i]
+		deliveries[i] = &d
+	}
+	return j, deliveries, nil
+}
+
+// load replays the journal file, if any.
+func (j *journal) load() error {
+	raw, err := ioutil.ReadFile(j.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		var rec record
+		err := json.Unmarshal(scanner.Bytes(), &rec)
+		if err != nil {
+			// i.e. the last line, when the peer stopped
+			// while writing it.
+			logger.Warningf("skipping bad record in webhook queue %s: %s", j.path, err)
+			continue
+		}
+		j.apply(rec)
+	}
+	return scanner.Err()
+}
+
+// apply updates the state with a record. The caller must hold mux,
+// except while loading.
+func (j *journal) apply(rec record) {
+	if rec.ID > j.lastID {
+		j.lastID = rec.ID
+	}
+	switch rec.Op {
+	case opAdd:
+		if rec.Delivery != nil {
+			d := *rec.Delivery
+			d.ID = rec.ID
+			j.state[rec.ID] = d
+		}
+	case opRetry:
+		if d, ok := j.state[rec.ID]; ok {
+			d.Attempts = rec.Attempts
+			d.NextAttempt = rec.NextAttempt
+			j.state[rec.ID] = d
+		}
+	case opDone:
+		delete(j.state, rec.ID)
+	}
+}
+
+// snapshot returns the pending deliveries sorted by ID.
+func (j *journal) snapshot() []delivery {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	return j.sortedState()
+}
+
+func (j *journal) sortedState() []delivery {
+	snapshot := make([]delivery, 0, len(j.state))
+	for _, d := range j.state {
+		snapshot = append(snapshot, d)
+	}
+	sort.Slice(snapshot, func(a, b int) bool {
+		return snapshot[a].ID < snapshot[b].ID
+	})
+	return snapshot
+}
+
+// add assigns an ID to a new delivery and records it.
+func (j *journal) add(d *delivery) {
+	j.mux.Lock()
+	j.lastID++
+	d.ID = j.lastID
+	dcopy := *d
+	j.record(record{Op: opAdd, ID: d.ID, Delivery: &dcopy})
+	j.mux.Unlock()
+}
+
+// retry records the attempts of a delivery which failed.
+func (j *journal) retry(d *delivery) {
+	j.mux.Lock()
+	j.record(record{
+		Op:          opRetry,
+		ID:          d.ID,
+		Attempts:    d.Attempts,
+		NextAttempt: d.NextAttempt,
+	})
+	j.mux.Unlock()
+}
+
+// finish records that a delivery is no longer pending.
+func (j *journal) finish(d *delivery) {
+	j.mux.Lock()
+	j.record(record{Op: opDone, ID: d.ID})
+	j.mux.Unlock()
+}
+
+// record applies rec and queues it to be written. The caller must
+// hold mux.
+func (j *journal) record(rec record) {
+	if j.path == "" {
+		return
+	}
+	j.apply(rec)
+	j.pending = append(j.pending, rec)
+	select {
+	case j.notify <- struct{}{}:
+	default:
+	}
+}
+
+// run writes the queued records until the journal is closed.
+func (j *journal) run() {
+	defer close(j.done)
+	for {
+		select {
+		case <-j.notify:
+			j.flush()
+		case <-j.stop:
+			j.flush()
+			if j.file != nil {
+				j.file.Close()
+			}
+			return
+		}
+	}
+}
+
+// flush writes the queued records, or compacts the journal when it has
+// grown too much. Since the state already includes the queued records,
+// a compaction replaces them. When the compaction fails, they are
+// written as usual. Records which cannot be written are kept for the
+// next flush.
+func (j *journal) flush() {
+	j.mux.Lock()
+	recs := j.pending
+	j.pending = nil
+	var snapshot []delivery
+	if j.records+len(recs) > journalCompactRatio*len(j.state)+journalCompactMin {
+		snapshot = j.sortedState()
+	}
+	j.mux.Unlock()
+
+	if snapshot != nil {
+		err := j.compact(snapshot)
+		if err == nil {
+			return
+		}
+		logger.Errorf("error compacting webhook queue: %s", err)
+	}
+	if len(recs) == 0 {
+		return
+	}
+
+	if j.file == nil {
+		// a compaction failed after replacing the file.
+		f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			logger.Errorf("error reopening webhook queue: %s", err)
+			j.mux.Lock()
+			j.pending = append(recs, j.pending...)
+			j.mux.Unlock()
+			return
+		}
+		j.file = f
+	}
+
+	w := bufio.NewWriter(j.file)
+	enc := json.NewEncoder(w)
+	for _, rec := range recs {
+		err := enc.Encode(rec)
+		if err != nil {
+			logger.Error(err)
+		}
+	}
+	err := w.Flush()
+	if err != nil {
+		logger.Errorf("error saving webhook queue: %s", err)
+	}
+	j.records += len(recs)
+}
+
+// compact replaces the journal file with one holding only the given
+// deliveries, and reopens it for appending. The current file is kept
+// when it cannot be replaced. When the new one cannot be reopened, the
+// journal is left without a file.
+func (j *journal) compact(snapshot []delivery) error {
+	tmp := j.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for i := range snapshot {
+		d := snapshot[i]
+		err = enc.Encode(record{Op: opAdd, ID: d.ID, Delivery: &d})
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp, j.path)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	// The current file has been replaced: writing to it would be
+	// lost.
+	if j.file != nil {
+		j.file.Close()
+		j.file = nil
+	}
+	j.records = len(snapshot)
+	j.file, err = os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		j.file = nil
+		return err
+	}
+	return nil
+}
+
+// close writes the remaining records and stops the journal.
+func (j *journal) close() {
+	close(j.stop)
+	<-j.done
+}
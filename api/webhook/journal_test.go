@@ -0,0 +1,129 @@
+package webhook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+)
+
+func TestJournal(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "webhook-journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+	path := filepath.Join(tdir, "queue")
+
+	j, pending, err := openJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Fatal("a new journal should be empty")
+	}
+
+	var ds []*delivery
+	for i := 0; i < 3; i++ {
+		d := &delivery{URL: "http://a", Event: api.EventSerial{Type: "pin_done"}}
+		j.add(d)
+		ds = append(ds, d)
+	}
+	ds[1].Attempts = 2
+	ds[1].NextAttempt = time.Now().Add(time.Minute)
+	j.retry(ds[1])
+	j.finish(ds[0])
+	j.close()
+
+	j, pending, err = openJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.close()
+	if len(pending) != 2 || pending[0].ID != ds[1].ID || pending[1].ID != ds[2].ID {
+		t.Fatalf("unexpected pending deliveries: %+v", pending)
+	}
+	if pending[0].Attempts != 2 || pending[0].NextAttempt.IsZero() {
+		t.Error("the retries should be kept")
+	}
+
+	// New deliveries do not reuse IDs.
+	d := &delivery{URL: "http://a"}
+	j.add(d)
+	if d.ID <= ds[2].ID {
+		t.Error("IDs should keep growing:", d.ID)
+	}
+}
+
+func TestJournalCompact(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "webhook-journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+	path := filepath.Join(tdir, "queue")
+
+	j, _, err := openJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < journalCompactMin; i++ {
+		d := &delivery{URL: "http://a"}
+		j.add(d)
+		j.finish(d)
+	}
+	j.close()
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() > 1024 {
+		t.Error("the journal should have been compacted:", fi.Size())
+	}
+}
+
+func TestJournalCompactError(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "webhook-journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+	path := filepath.Join(tdir, "queue")
+
+	j, _, err := openJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The compactions fail while the temporary file cannot be
+	// created.
+	err = os.Mkdir(path+".tmp", 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < journalCompactMin; i++ {
+		d := &delivery{URL: "http://a"}
+		j.add(d)
+		j.finish(d)
+	}
+	kept := &delivery{URL: "http://b"}
+	j.add(kept)
+	j.close()
+
+	err = os.Remove(path + ".tmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, pending, err := openJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.close()
+	if len(pending) != 1 || pending[0].ID != kept.ID {
+		t.Errorf("records should be kept when compacting fails: %+v", pending)
+	}
+}
@@ -0,0 +1,338 @@
+// Package webhook implements an IPFS Cluster API component which forwards
+// the events produced by a cluster peer to external HTTP endpoints.
+//
+// Events are POSTed as JSON. When an endpoint has a secret, the body is
+// signed with HMAC-SHA256 and the signature is sent in the
+// X-Hive-Signature header. Failed deliveries are retried with an
+// exponential backoff. Every endpoint has its own queue and worker, and
+// pending deliveries are persisted to a journal on disk so that they
+// survive restarts.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+
+	logging "github.com/ipfs/go-log"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+)
+
+var logger = logging.Logger("webhook")
+
+// Headers set on every request made to an endpoint.
+const (
+	SignatureHeader = "X-Hive-Signature"
+	EventHeader     = "X-Hive-Event"
+)
+
+// delivery is an event pending to be sent to an endpoint.
+type delivery struct {
+	ID          uint64          `json:"id"`
+	URL         string          `json:"url"`
+	Event       api.EventSerial `json:"event"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt time.Time       `json:"next_attempt"`
+}
+
+// Webhook is an API component which implements ipfscluster.EventSink
+// and delivers the cluster events to the configured endpoints.
+type Webhook struct {
+	ctx    context.Context
+	cancel func()
+
+	config    *Config
+	client    *http.Client
+	rpcClient *rpc.Client
+
+	journal *journal
+	queues  []*endpointQueue
+
+	shutdownLock sync.Mutex
+	shutdown     bool
+	wg           sync.WaitGroup
+}
+
+// endpointQueue holds the pending deliveries of one endpoint. Every
+// endpoint has its own worker, so a slow or failing endpoint does not
+// delay the deliveries to the others.
+type endpointQueue struct {
+	wh       *Webhook
+	endpoint Endpoint
+
+	mux    sync.Mutex
+	queue  []*delivery
+	notify chan struct{}
+}
+
+// New creates a Webhook component with the given configuration and
+// starts delivering any events left in the persistent queue.
+func New(cfg *Config) (*Webhook, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	jrnl, pending, err := openJournal(cfg.GetQueuePath())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wh := &Webhook{
+		ctx:     ctx,
+		cancel:  cancel,
+		config:  cfg,
+		client:  &http.Client{Timeout: cfg.RequestTimeout},
+		journal: jrnl,
+	}
+
+	for _, e := range cfg.Endpoints {
+		wh.queues = append(wh.queues, &endpointQueue{
+			wh:       wh,
+			endpoint: e,
+			notify:   make(chan struct{}, 1),
+		})
+	}
+
+	for _, d := range pending {
+		q := wh.endpointQueue(d.URL)
+		if q == nil {
+			// the endpoint was removed from the configuration
+			// since the event was queued.
+			logger.Warningf("dropping %s event for unknown endpoint %s", d.Event.Type, d.URL)
+			jrnl.finish(d)
+			continue
+		}
+		q.queue = append(q.queue, d)
+	}
+	if len(pending) > 0 {
+		logger.Infof("%d webhook deliveries pending", len(pending))
+	}
+
+	for _, q := range wh.queues {
+		wh.wg.Add(1)
+		go q.run()
+	}
+	return wh, nil
+}
+
+// SetClient makes the component ready to perform RPC requests.
+func (wh *Webhook) SetClient(c *rpc.Client) {
+	wh.rpcClient = c
+}
+
+// Shutdown stops any delivery in progress. Pending deliveries
+// remain in the persistent queue.
+func (wh *Webhook) Shutdown() error {
+	wh.shutdownLock.Lock()
+	defer wh.shutdownLock.Unlock()
+
+	if wh.shutdown {
+		logger.Debug("already shutdown")
+		return nil
+	}
+
+	logger.Info("stopping webhook sink")
+	wh.cancel()
+	wh.wg.Wait()
+	wh.journal.close()
+	wh.shutdown = true
+	return nil
+}
+
+// HandleEvent queues the event for every endpoint subscribed to its
+// type. It does not block.
+func (wh *Webhook) HandleEvent(ev api.Event) {
+	serial := ev.ToSerial()
+	for _, q := range wh.queues {
+		if q.endpoint.wants(ev.Type) {
+			q.push(serial)
+		}
+	}
+}
+
+func (wh *Webhook) endpointQueue(url string) *endpointQueue {
+	for _, q := range wh.queues {
+		if q.endpoint.URL == url {
+			return q
+		}
+	}
+	return nil
+}
+
+// push queues an event for the endpoint, unless its queue is full.
+func (q *endpointQueue) push(ev api.EventSerial) {
+	q.mux.Lock()
+	if len(q.queue) >= q.wh.config.MaxQueueSize {
+		q.mux.Unlock()
+		logger.Errorf("webhook queue is full: dropping %s event for %s", ev.Type, q.endpoint.URL)
+		return
+	}
+	d := &delivery{
+		URL:   q.endpoint.URL,
+		Event: ev,
+	}
+	q.wh.journal.add(d)
+	q.queue = append(q.queue, d)
+	q.mux.Unlock()
+
+	q.wakeUp()
+}
+
+func (q *endpointQueue) wakeUp() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers the queued events until the component is shut down.
+func (q *endpointQueue) run() {
+	defer q.wh.wg.Done()
+
+	for {
+		wait := q.deliverDue()
+		timer := time.NewTimer(wait)
+		select {
+		case <-q.wh.ctx.Done():
+			timer.Stop()
+			return
+		case <-q.notify:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// deliverDue attempts every delivery whose time has come, in the order
+// they were queued, and returns how long to wait until the next one is
+// due.
+func (q *endpointQueue) deliverDue() time.Duration {
+	wh := q.wh
+
+	q.mux.Lock()
+	now := time.Now()
+	var due []*delivery
+	for _, d := range q.queue {
+		if !d.NextAttempt.After(now) {
+			due = append(due, d)
+		}
+	}
+	q.mux.Unlock()
+
+	for _, d := range due {
+		if wh.ctx.Err() != nil {
+			break
+		}
+		err := wh.send(q.endpoint, d)
+		q.mux.Lock()
+		if err == nil {
+			q.remove(d)
+			wh.journal.finish(d)
+		} else {
+			d.Attempts++
+			if d.Attempts > wh.config.MaxRetries {
+				logger.Errorf(
+					"giving up delivering %s event to %s after %d attempts: %s",
+					d.Event.Type, d.URL, d.Attempts, err,
+				)
+				q.remove(d)
+				wh.journal.finish(d)
+			} else {
+				logger.Warningf("delivering %s event to %s: %s", d.Event.Type, d.URL, err)
+				d.NextAttempt = time.Now().Add(wh.backoff(d.Attempts))
+				wh.journal.retry(d)
+			}
+		}
+		q.mux.Unlock()
+	}
+
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	wait := wh.config.MaxRetryInterval
+	now = time.Now()
+	for _, d := range q.queue {
+		if w := d.NextAttempt.Sub(now); w < wait {
+			wait = w
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+// backoff returns the time to wait before the next attempt of a delivery
+// which has failed the given number of times.
+func (wh *Webhook) backoff(attempts int) time.Duration {
+	wait := wh.config.RetryInterval
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= wh.config.MaxRetryInterval {
+			return wh.config.MaxRetryInterval
+		}
+	}
+	return wait
+}
+
+// remove takes a delivery out of the queue. The caller must
+// hold mux.
+func (q *endpointQueue) remove(d *delivery) {
+	for i, qd := range q.queue {
+		if qd == d {
+			q.queue = append(q.queue[:i], q.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// send POSTs a delivery to an endpoint.
+func (wh *Webhook) send(e Endpoint, d *delivery) error {
+	body, err := json.Marshal(d.Event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", e.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(wh.ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, d.Event.Type)
+	if e.Secret != "" {
+		req.Header.Set(SignatureHeader, "sha256="+Sign([]byte(e.Secret), body))
+	}
+
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the hex-encoded HMAC-SHA256 of body using the given
+// secret. Receivers can use it to verify the X-Hive-Signature header.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
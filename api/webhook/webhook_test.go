@@ -0,0 +1,269 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/test"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// receiver is a local stand-in for a webhook endpoint. It fails the
+// first failures requests and records the events received afterwards.
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	events   []api.EventSerial
+	sigs     []string
+	bodies   [][]byte
+}
+
+func (rcv *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	if rcv.failures > 0 {
+		rcv.failures--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var ev api.EventSerial
+	err := json.Unmarshal(body, &ev)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rcv.events = append(rcv.events, ev)
+	rcv.sigs = append(rcv.sigs, r.Header.Get(SignatureHeader))
+	rcv.bodies = append(rcv.bodies, body)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (rcv *receiver) received() []api.EventSerial {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return append([]api.EventSerial{}, rcv.events...)
+}
+
+func waitFor(t *testing.T, rcv *receiver, n int) []api.EventSerial {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		evs := rcv.received()
+		if len(evs) >= n {
+			return evs
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("expected %d events, got %d", n, len(rcv.received()))
+	return nil
+}
+
+func testConfig(urls ...string) *Config {
+	cfg := &Config{}
+	cfg.Default()
+	cfg.RetryInterval = 50 * time.Millisecond
+	cfg.MaxRetryInterval = 200 * time.Millisecond
+	cfg.RequestTimeout = time.Second
+	for _, u := range urls {
+		cfg.Endpoints = append(cfg.Endpoints, Endpoint{URL: u})
+	}
+	return cfg
+}
+
+func testEvent(t api.EventType) api.Event {
+	c, _ := cid.Decode(test.TestCid1)
+	return api.Event{
+		Type: t,
+		TS:   time.Now(),
+		Peer: test.TestPeerID1,
+		Cid:  c,
+		Name: "test",
+	}
+}
+
+func TestWebhookDeliverSigned(t *testing.T) {
+	rcv := &receiver{}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	cfg := testConfig(srv.URL)
+	cfg.Endpoints[0].Secret = "secret"
+	wh, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wh.Shutdown()
+
+	wh.HandleEvent(testEvent(api.EventPinDone))
+	evs := waitFor(t, rcv, 1)
+	if evs[0].Type != string(api.EventPinDone) || evs[0].Cid != test.TestCid1 {
+		t.Errorf("unexpected event: %+v", evs[0])
+	}
+
+	rcv.mu.Lock()
+	sig, body := rcv.sigs[0], rcv.bodies[0]
+	rcv.mu.Unlock()
+	if sig != "sha256="+Sign([]byte("secret"), body) {
+		t.Error("bad signature")
+	}
+}
+
+func TestWebhookFilter(t *testing.T) {
+	rcv := &receiver{}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	cfg := testConfig(srv.URL)
+	cfg.Endpoints[0].Events = []api.EventType{api.EventPinError}
+	wh, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wh.Shutdown()
+
+	wh.HandleEvent(testEvent(api.EventPinDone))
+	wh.HandleEvent(testEvent(api.EventPinError))
+	waitFor(t, rcv, 1)
+	time.Sleep(200 * time.Millisecond)
+	evs := rcv.received()
+	if len(evs) != 1 || evs[0].Type != string(api.EventPinError) {
+		t.Errorf("expected only the pin_error event: %+v", evs)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	rcv := &receiver{failures: 2}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	wh, err := New(testConfig(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wh.Shutdown()
+
+	wh.HandleEvent(testEvent(api.EventUnpinDone))
+	evs := waitFor(t, rcv, 1)
+	if evs[0].Type != string(api.EventUnpinDone) {
+		t.Error("unexpected event")
+	}
+}
+
+func TestWebhookGiveUp(t *testing.T) {
+	rcv := &receiver{failures: 100}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	cfg := testConfig(srv.URL)
+	cfg.MaxRetries = 1
+	wh, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wh.Shutdown()
+
+	wh.HandleEvent(testEvent(api.EventPinDone))
+	time.Sleep(500 * time.Millisecond)
+	q := wh.queues[0]
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	if len(q.queue) != 0 {
+		t.Error("expected the delivery to be dropped")
+	}
+}
+
+func TestWebhookPersistentQueue(t *testing.T) {
+	tdir, err := ioutil.TempDir("", "webhook-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tdir)
+
+	rcv := &receiver{failures: 1000}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	cfg := testConfig(srv.URL)
+	cfg.SetBaseDir(tdir)
+	cfg.RetryInterval = time.Minute
+	cfg.MaxRetryInterval = time.Minute
+	wh, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wh.HandleEvent(testEvent(api.EventUidCreated))
+	time.Sleep(200 * time.Millisecond)
+	wh.Shutdown()
+
+	if _, err := os.Stat(cfg.GetQueuePath()); err != nil {
+		t.Fatal("queue file was not written:", err)
+	}
+
+	// The endpoint recovers and a new sink picks up the
+	// pending delivery.
+	rcv.mu.Lock()
+	rcv.failures = 0
+	rcv.mu.Unlock()
+
+	cfg.RetryInterval = 50 * time.Millisecond
+	cfg.MaxRetryInterval = 200 * time.Millisecond
+	wh2, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wh2.Shutdown()
+
+	q := wh2.queues[0]
+	q.mux.Lock()
+	if len(q.queue) != 1 {
+		t.Error("expected one pending delivery:", len(q.queue))
+	}
+	for _, d := range q.queue {
+		d.NextAttempt = time.Now()
+	}
+	q.mux.Unlock()
+	q.wakeUp()
+
+	evs := waitFor(t, rcv, 1)
+	if evs[0].Type != string(api.EventUidCreated) {
+		t.Error("unexpected event")
+	}
+}
+
+func TestWebhookSlowEndpoint(t *testing.T) {
+	block := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer slow.Close()
+	defer close(block)
+
+	rcv := &receiver{}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	cfg := testConfig(slow.URL, srv.URL)
+	cfg.RequestTimeout = time.Minute
+	wh, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wh.Shutdown()
+
+	// The deliveries to the slow endpoint do not hold the
+	// others back.
+	wh.HandleEvent(testEvent(api.EventPinDone))
+	wh.HandleEvent(testEvent(api.EventUnpinDone))
+	waitFor(t, rcv, 2)
+}
@@ -0,0 +1,222 @@
+package ipfscluster
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"path"
+	"strings"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+)
+
+// validateFileOps checks a batch of file operations before running any
+// of them.
+func validateFileOps(ops []api.FileOp) error {
+	if len(ops) == 0 {
+		return errors.New("the batch has no operations")
+	}
+
+	for i, op := range ops {
+		paths := []string{op.Path}
+		switch op.Op {
+		case "mkdir", "write", "rm":
+		case "cp":
+			if op.Source == "" {
+				return fmt.Errorf("operation %d: cp needs a source", i)
+			}
+			if !strings.HasPrefix(op.Source, "/ipfs/") {
+				paths = append(paths, op.Source)
+			}
+		case "mv":
+			if op.Dest == "" {
+				return fmt.Errorf("operation %d: mv needs a destination", i)
+			}
+			paths = append(paths, op.Dest)
+		default:
+			return fmt.Errorf("operation %d: unknown operation: %s", i, op.Op)
+		}
+
+		for _, p := range paths {
+			p = cleanHomePath(p)
+			if p == "/" {
+				return fmt.Errorf("operation %d: %s can not be used on the home root", i, op.Op)
+			}
+			if inTrash(p) {
+				return fmt.Errorf("operation %d: %s can not be used in the trash", i, op.Op)
+			}
+		}
+	}
+	return nil
+}
+
+// FilesBatch runs a list of file operations on the home of a UID as a
+// single unit. The operations are run in order on a staged copy of the
+// home, which replaces the home only when all of them succeed. Otherwise,
+// the home is left untouched. The caller must hold the lock of the home,
+// so that it is not modified while the batch runs.
+func (c *Cluster) FilesBatch(uid string, ops []api.FileOp) (api.FilesBatchResult, error) {
+	var res api.FilesBatchResult
+
+	err := validateFileOps(ops)
+	if err != nil {
+		return res, err
+	}
+
+	base, err := c.ipfs.FilesStat([]string{uid, "", "", "", "", ""})
+	if err != nil {
+		return res, err
+	}
+
+	staging := api.NewStagingHome(uid)
+	err = c.ipfs.FilesCp([]string{staging, "/ipfs/" + base.Hash, ""})
+	if err != nil {
+		return res, err
+	}
+	defer func() {
+		err := c.ipfs.FilesRm([]string{staging, "", "true"})
+		if err != nil {
+			logger.Errorf("error removing the staged home %s: %s", staging, err)
+		}
+	}()
+
+	res.Trashed = make([]api.TrashEntry, 0)
+	for i, op := range ops {
+		entry, err := c.runFileOp(staging, op)
+		if err != nil {
+			return api.FilesBatchResult{}, fmt.Errorf("operation %d (%s %s) failed: %s", i, op.Op, op.Path, err)
+		}
+		if entry != nil {
+			res.Trashed = append(res.Trashed, *entry)
+		}
+	}
+
+	staged, err := c.ipfs.FilesStat([]string{staging, "", "", "", "", ""})
+	if err != nil {
+		return api.FilesBatchResult{}, err
+	}
+
+	current, err := c.ipfs.FilesStat([]string{uid, "", "", "", "", ""})
+	if err != nil {
+		return api.FilesBatchResult{}, err
+	}
+	if current.Hash != base.Hash {
+		return api.FilesBatchResult{}, errors.New("the home of " + uid + " was modified while running the batch")
+	}
+
+	err = c.ipfs.UidLogin([]string{uid, staged.Hash})
+	if err != nil {
+		return api.FilesBatchResult{}, err
+	}
+
+	logger.Infof("ran a batch of %d operations in the home of %s: %s", len(ops), uid, staged.Hash)
+	c.publishFileOp("batch", uid, "/")
+	res.Root = staged.Hash
+	return res, nil
+}
+
+// removeStagingHomes removes the staging homes left behind by batches and
+// logins which were interrupted, i.e. when the peer stopped. The lock of
+// the staged home is taken so that running batches are not disturbed.
+func (c *Cluster) removeStagingHomes() {
+	homes, err := c.ipfs.FilesLs([]string{"", ""})
+	if err != nil {
+		logger.Errorf("error listing homes to remove stale staging homes: %s", err)
+		return
+	}
+
+	for _, home := range homes.Entries {
+		if !api.IsStagingHome(home.Name) {
+			continue
+		}
+		unlock := c.homeLocks.lock(api.StagedUID(home.Name))
+		_, err := c.ipfs.FilesStat([]string{home.Name, "", "", "", "", ""})
+		if err == nil {
+			err = c.ipfs.FilesRm([]string{home.Name, "", "true"})
+			if err != nil {
+				logger.Errorf("error removing the stale staging home %s: %s", home.Name, err)
+			} else {
+				logger.Infof("removed the stale staging home %s", home.Name)
+			}
+		}
+		unlock()
+	}
+}
+
+// runFileOp runs a single operation of a batch in the given home. It
+// returns the trash entry created by rm, if any.
+func (c *Cluster) runFileOp(home string, op api.FileOp) (*api.TrashEntry, error) {
+	p := cleanHomePath(op.Path)
+	parents := fmt.Sprintf("%t", op.Parents)
+
+	mkParents := func(p string) error {
+		if !op.Parents || path.Dir(p) == "/" {
+			return nil
+		}
+		return c.ipfs.FilesMkdir([]string{home, path.Dir(p), "true"})
+	}
+
+	switch op.Op {
+	case "mkdir":
+		return nil, c.ipfs.FilesMkdir([]string{home, p, parents})
+	case "write":
+		err := mkParents(p)
+		if err != nil {
+			return nil, err
+		}
+		return nil, c.writeFile(home, p, op.Data)
+	case "cp":
+		err := mkParents(p)
+		if err != nil {
+			return nil, err
+		}
+		source := op.Source
+		if !strings.HasPrefix(source, "/ipfs/") {
+			source = path.Join("/nodes", home, cleanHomePath(source))
+		}
+		return nil, c.ipfs.FilesCp([]string{home, source, p})
+	case "mv":
+		dest := cleanHomePath(op.Dest)
+		err := mkParents(dest)
+		if err != nil {
+			return nil, err
+		}
+		return nil, c.ipfs.FilesMv([]string{home, p, dest})
+	case "rm":
+		if op.Permanent {
+			return nil, c.ipfs.FilesRm([]string{home, p, fmt.Sprintf("%t", op.Recursive)})
+		}
+		entry, err := c.moveToTrash(home, p, op.Recursive)
+		if err != nil {
+			return nil, err
+		}
+		return &entry, nil
+	default:
+		return nil, errors.New("unknown operation: " + op.Op)
+	}
+}
+
+// writeFile creates or replaces the file at p with the given data.
+func (c *Cluster) writeFile(home, p string, data []byte) error {
+	bodyBuf := &bytes.Buffer{}
+	writer := multipart.NewWriter(bodyBuf)
+	fileWriter, err := writer.CreateFormFile("file", "upload")
+	if err != nil {
+		return err
+	}
+	_, err = fileWriter.Write(data)
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+
+	return c.ipfs.FilesWrite(api.FilesWrite{
+		ContentType: writer.FormDataContentType(),
+		BodyBuf:     bodyBuf,
+		Params:      []string{home, p, "", "true", "true", "", "", "", ""},
+	})
+}
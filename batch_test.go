@@ -0,0 +1,75 @@
+package ipfscluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+)
+
+func TestValidateFileOps(t *testing.T) {
+	valid := []api.FileOp{
+		{Op: "mkdir", Path: "/app", Parents: true},
+		{Op: "write", Path: "/app/config.json", Data: []byte("{}")},
+		{Op: "cp", Path: "/app/logo.png", Source: "/ipfs/QmP63DkAFEnDYNjDYBpyNDfttu1fvUw99x1brscPzpqmmb"},
+		{Op: "cp", Path: "/app/readme", Source: "/docs/readme"},
+		{Op: "mv", Path: "/old", Dest: "/app/old"},
+		{Op: "rm", Path: "/tmp", Recursive: true},
+	}
+	if err := validateFileOps(valid); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := [][]api.FileOp{
+		nil,
+		{{Op: "chmod", Path: "/app"}},
+		{{Op: "rm", Path: "/"}},
+		{{Op: "write", Path: ""}},
+		{{Op: "cp", Path: "/app"}},
+		{{Op: "mv", Path: "/app"}},
+		{{Op: "mv", Path: "/app", Dest: "/.trash/app"}},
+		{{Op: "rm", Path: "/.trash"}},
+	}
+	for _, ops := range invalid {
+		if err := validateFileOps(ops); err == nil {
+			t.Errorf("%+v should not be valid", ops)
+		}
+	}
+}
+
+func TestHomeLocks(t *testing.T) {
+	var hl homeLocks
+
+	unlock := hl.rlock("uid-test")
+	runlock := hl.rlock("uid-test")
+	locked := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		unlock := hl.lock("uid-test")
+		close(locked)
+		unlock()
+		close(done)
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("the home should not be locked while it is read")
+	case <-time.After(50 * time.Millisecond):
+	}
+	hl.lock("uid-other")()
+
+	unlock()
+	runlock()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("the home should be locked once it is not read")
+	}
+	<-done
+
+	hl.mux.Lock()
+	defer hl.mux.Unlock()
+	if len(hl.locks) != 0 {
+		t.Error("unused locks should be forgotten:", hl.locks)
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/elastos/Elastos.NET.Hive.Cluster/adder/sharding"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/keystore"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/observations"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/pstoremgr"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/rpcutil"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/state"
@@ -61,7 +62,7 @@ type Cluster struct {
 	tracker   PinTracker
 	monitor   PeerMonitor
 	allocator PinAllocator
-	informer  Informer
+	informers []Informer
 
 	doneCh  chan struct{}
 	readyCh chan struct{}
@@ -71,6 +72,27 @@ type Cluster struct {
 	// peerAdd
 	paMux sync.Mutex
 
+	// set to 1 while a rebalancing round moves allocations
+	rebalancing int32
+	// set to 1 while allocations are moved away from draining peers
+	drainMoving int32
+
+	// active alerts by peer and metric name
+	alertsMux sync.RWMutex
+	alerts    map[peer.ID]map[string]api.Alert
+
+	// locks of the user homes
+	homeLocks homeLocks
+
+	// metadata index of the user homes. nil when disabled.
+	search *searchIndex
+
+	// event bus
+	eventsCh chan api.Event
+
+	// removes the metrics collect hook
+	removeMetricsHook func()
+
 	// shutdown function and related variables
 	shutdownLock sync.Mutex
 	shutdownB    bool
@@ -83,6 +105,9 @@ type Cluster struct {
 // The new cluster peer may still be performing initialization tasks when
 // this call returns (consensus may still be bootstrapping). Use Cluster.Ready()
 // if you need to wait until the peer is fully up.
+//
+// At least one Informer must be provided. The metrics from all of them are
+// published and handed to the PinAllocator.
 func NewCluster(
 	host host.Host,
 	cfg *Config,
@@ -93,7 +118,7 @@ func NewCluster(
 	tracker PinTracker,
 	monitor PeerMonitor,
 	allocator PinAllocator,
-	informer Informer,
+	informers ...Informer,
 ) (*Cluster, error) {
 	err := cfg.Validate()
 	if err != nil {
@@ -104,6 +129,14 @@ func NewCluster(
 		return nil, errors.New("cluster host is nil")
 	}
 
+	if len(informers) == 0 {
+		return nil, errors.New("no informers provided")
+	}
+
+	if cfg.RebalanceInterval > 0 && !providesMetric(informers, cfg.RebalanceMetric) {
+		return nil, fmt.Errorf("no informer provides the rebalance metric: %s", cfg.RebalanceMetric)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	listenAddrs := ""
@@ -146,13 +179,18 @@ func NewCluster(
 		tracker:     tracker,
 		monitor:     monitor,
 		allocator:   allocator,
-		informer:    informer,
+		informers:   informers,
 		peerManager: peerManager,
 		shutdownB:   false,
 		removed:     false,
 		doneCh:      make(chan struct{}),
 		readyCh:     make(chan struct{}),
 		readyB:      false,
+		alerts:      make(map[peer.ID]map[string]api.Alert),
+		eventsCh:    make(chan api.Event, EventsChannelCap),
+	}
+	if cfg.SearchIndex {
+		c.search = newSearchIndex(ipfs)
 	}
 
 	err = c.setupRPC()
@@ -161,6 +199,10 @@ func NewCluster(
 		return nil, err
 	}
 	c.setupRPCClients()
+	if producer, ok := c.tracker.(EventProducer); ok {
+		producer.SetEventPublisher(c.PublishEvent)
+	}
+	c.removeMetricsHook = observations.DefaultRegistry.AddCollectHook(c.collectMetrics)
 	go func() {
 		c.ready(ReadyTimeout)
 		c.run()
@@ -177,6 +219,7 @@ func (c *Cluster) setupRPC() error {
 	c.rpcServer = rpcServer
 	rpcClient := rpc.NewClientWithServer(c.host, version.RPCProtocol, rpcServer)
 	c.rpcClient = rpcClient
+	c.host.SetStreamHandler(version.StatusProtocol, c.handleStatusStream)
 	return nil
 }
 
@@ -189,7 +232,9 @@ func (c *Cluster) setupRPCClients() {
 	c.consensus.SetClient(c.rpcClient)
 	c.monitor.SetClient(c.rpcClient)
 	c.allocator.SetClient(c.rpcClient)
-	c.informer.SetClient(c.rpcClient)
+	for _, informer := range c.informers {
+		informer.SetClient(c.rpcClient)
+	}
 }
 
 // syncWatcher loops and triggers StateSync and SyncAllLocal from time to time
@@ -212,16 +257,16 @@ func (c *Cluster) syncWatcher() {
 	}
 }
 
-func (c *Cluster) sendInformerMetric() (api.Metric, error) {
-	metric := c.informer.GetMetric()
+func (c *Cluster) sendInformerMetric(informer Informer) (api.Metric, error) {
+	metric := informer.GetMetric()
 	metric.Peer = c.id
 	return metric, c.monitor.PublishMetric(metric)
 }
 
-// pushInformerMetrics loops and publishes informers metrics using the
-// cluster monitor. Metrics are pushed normally at a TTL/2 rate. If an error
-// occurs, they are pushed at a TTL/4 rate.
-func (c *Cluster) pushInformerMetrics() {
+// pushInformerMetrics loops and publishes the given informer's metrics using
+// the cluster monitor. Metrics are pushed normally at a TTL/2 rate. If an
+// error occurs, they are pushed at a TTL/4 rate.
+func (c *Cluster) pushInformerMetrics(informer Informer) {
 	timer := time.NewTimer(0) // fire immediately first
 
 	// retries counts how many retries we have made
@@ -240,7 +285,7 @@ func (c *Cluster) pushInformerMetrics() {
 			// wait
 		}
 
-		metric, err := c.sendInformerMetric()
+		metric, err := c.sendInformerMetric(informer)
 
 		if err != nil {
 			if (retries % retryWarnMod) == 0 {
@@ -277,22 +322,36 @@ func (c *Cluster) pushPingMetrics() {
 	}
 }
 
-// read the alerts channel from the monitor and triggers repins
+// read the alerts channel from the monitor and triggers repins. Expired
+// alerts are forgotten every AlertTTL.
 func (c *Cluster) alertsHandler() {
+	ticker := time.NewTicker(c.config.AlertTTL)
+	defer ticker.Stop()
 	for {
 		select {
 		case <-c.ctx.Done():
 			return
+		case <-ticker.C:
+			c.pruneAlerts()
 		case alrt := <-c.monitor.Alerts():
+			// every peer records alerts so that the
+			// allocations it makes honor them.
+			isNew := c.recordAlert(&alrt)
+			if isNew {
+				c.publishAlert(alrt)
+			}
+
 			// only the leader handles alerts
 			leader, err := c.consensus.Leader()
 			if err == nil && leader == c.id {
 				logger.Warningf(
-					"Peer %s received alert for %s in %s",
-					c.id, alrt.MetricName, alrt.Peer,
+					"Peer %s received alert for %s in %s (policy: %s)",
+					c.id, alrt.MetricName, alrt.Peer, alrt.Policy,
 				)
-				switch alrt.MetricName {
-				case pingMetricName:
+				switch {
+				case alrt.MetricName == pingMetricName:
+					c.repinFromPeer(alrt.Peer)
+				case alrt.Policy == AlertPolicyMovePins && isNew:
 					c.repinFromPeer(alrt.Peer)
 				}
 			}
@@ -304,6 +363,7 @@ func (c *Cluster) alertsHandler() {
 // detects that we have been removed from the peerset, it shuts down this peer.
 func (c *Cluster) watchPeers() {
 	ticker := time.NewTicker(c.config.PeerWatchInterval)
+	var known map[peer.ID]struct{}
 
 	for {
 		select {
@@ -323,6 +383,7 @@ func (c *Cluster) watchPeers() {
 					break
 				}
 			}
+			known = c.publishPeersetChanges(known, peers)
 
 			if !hasMe {
 				c.shutdownLock.Lock()
@@ -363,9 +424,18 @@ func (c *Cluster) repinFromPeer(p peer.ID) {
 func (c *Cluster) run() {
 	go c.syncWatcher()
 	go c.pushPingMetrics()
-	go c.pushInformerMetrics()
+	for _, informer := range c.informers {
+		go c.pushInformerMetrics(informer)
+	}
 	go c.watchPeers()
 	go c.alertsHandler()
+	go c.pinHealthWatcher()
+	go c.rebalanceWatcher()
+	go c.trashWatcher()
+	go c.removeStagingHomes()
+	go c.drainWatcher()
+	go c.alertThresholdsWatcher()
+	go c.dispatchEvents()
 }
 
 func (c *Cluster) ready(timeout time.Duration) {
@@ -445,6 +515,10 @@ func (c *Cluster) Shutdown() error {
 
 	logger.Info("shutting down Cluster")
 
+	if c.removeMetricsHook != nil {
+		c.removeMetricsHook()
+	}
+
 	// Try to store peerset file for all known peers whatsoever
 	// if we got ready (otherwise, don't overwrite anything)
 	if c.readyB {
@@ -495,6 +569,8 @@ func (c *Cluster) Shutdown() error {
 		}
 	}
 
+	c.search.wait()
+
 	if err := c.ipfs.Shutdown(); err != nil {
 		logger.Errorf("error stopping IPFS Connector: %s", err)
 		return err
@@ -760,7 +836,27 @@ func (c *Cluster) StateSync() error {
 // If an error happens, the slice will contain as much information as
 // could be fetched from other peers.
 func (c *Cluster) StatusAll() ([]api.GlobalPinInfo, error) {
-	return c.globalPinInfoSlice("TrackerStatusAll")
+	return c.globalPinInfoSlice("TrackerStatusAll", struct{}{})
+}
+
+// StatusAllPage returns the GlobalPinInfo for the tracked Cids selected
+// by the given page, sorted by Cid. The status of every peer is streamed
+// and merged as it arrives (see StatusAllStream), so the full status is
+// never transferred nor held at once. As with StatusAll(), if an error
+// happens the slice will contain as much information as could be fetched
+// from other peers.
+func (c *Cluster) StatusAllPage(page api.ListPage) ([]api.GlobalPinInfo, error) {
+	out := make(chan api.GlobalPinInfo, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.StatusAllStream(c.ctx, page, out)
+	}()
+
+	infos := make([]api.GlobalPinInfo, 0)
+	for gpi := range out {
+		infos = append(infos, gpi)
+	}
+	return infos, <-errCh
 }
 
 // StatusAllLocal returns the PinInfo for all the tracked Cids in this peer.
@@ -768,11 +864,42 @@ func (c *Cluster) StatusAllLocal() []api.PinInfo {
 	return c.tracker.StatusAll()
 }
 
+// StatusAllLocalPage returns the PinInfo for the Cids tracked by this peer
+// which are selected by the given page, sorted by Cid.
+func (c *Cluster) StatusAllLocalPage(page api.ListPage) []api.PinInfo {
+	return c.tracker.StatusAllPage(page)
+}
+
 // Status returns the GlobalPinInfo for a given Cid as fetched from all
 // current peers. If an error happens, the GlobalPinInfo should contain
-// as much information as could be fetched from the other peers.
+// as much information as could be fetched from the other peers. When the
+// Cid is a meta-pin, the status of each of its shards is included too.
 func (c *Cluster) Status(h cid.Cid) (api.GlobalPinInfo, error) {
-	return c.globalPinInfoCid("TrackerStatus", h)
+	gpi, err := c.globalPinInfoCid("TrackerStatus", h)
+	if err != nil {
+		return gpi, err
+	}
+
+	pin, err := c.PinGet(h)
+	if err != nil || pin.Type != api.MetaType {
+		return gpi, nil
+	}
+
+	cids, err := c.cidsFromMetaPin(h)
+	if err != nil {
+		return gpi, err
+	}
+	// cidsFromMetaPin returns the shards, in reverse order, followed
+	// by the ClusterDAG and the meta-pin.
+	shards := cids[:len(cids)-2]
+	for i := len(shards) - 1; i >= 0; i-- {
+		shardInfo, err := c.globalPinInfoCid("TrackerStatus", shards[i])
+		if err != nil {
+			return gpi, err
+		}
+		gpi.Shards = append(gpi.Shards, shardInfo)
+	}
+	return gpi, nil
 }
 
 // StatusLocal returns this peer's PinInfo for a given Cid.
@@ -785,7 +912,7 @@ func (c *Cluster) StatusLocal(h cid.Cid) api.PinInfo {
 // and returning the results as GlobalPinInfo. If an error happens, the slice
 // will contain as much information as could be fetched from the peers.
 func (c *Cluster) SyncAll() ([]api.GlobalPinInfo, error) {
-	return c.globalPinInfoSlice("SyncAllLocal")
+	return c.globalPinInfoSlice("SyncAllLocal", struct{}{})
 }
 
 // SyncAllLocal makes sure that the current state for all tracked items
@@ -858,6 +985,112 @@ func (c *Cluster) RecoverLocal(h cid.Cid) (pInfo api.PinInfo, err error) {
 	return c.localPinInfoOp(h, c.tracker.Recover)
 }
 
+// Operations returns the pin and unpin operations which are queued, in
+// progress or in error in all cluster peers. Peers which cannot be
+// contacted are skipped.
+func (c *Cluster) Operations() ([]api.Operation, error) {
+	members, err := c.consensus.Peers()
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+	lenMembers := len(members)
+
+	replies := make([][]api.OperationSerial, lenMembers, lenMembers)
+
+	ctxs, cancels := rpcutil.CtxsWithCancel(c.ctx, lenMembers)
+	defer rpcutil.MultiCancel(cancels)
+
+	errs := c.rpcClient.MultiCall(
+		ctxs,
+		members,
+		"Cluster",
+		"TrackerOperations",
+		struct{}{},
+		rpcutil.CopyOperationSerialSliceToIfaces(replies),
+	)
+
+	ops := make([]api.Operation, 0)
+	for i, r := range replies {
+		if e := errs[i]; e != nil {
+			logger.Errorf("%s: error in broadcast response from %s: %s ", c.id, members[i], e)
+			continue
+		}
+		for _, opS := range r {
+			ops = append(ops, opS.ToOperation())
+		}
+	}
+	return ops, nil
+}
+
+// OperationsLocal returns the pin and unpin operations which are queued,
+// in progress or in error in this peer.
+func (c *Cluster) OperationsLocal() []api.Operation {
+	return c.tracker.Operations()
+}
+
+// CancelOperation cancels the queued or in-progress operations for the
+// given Cid in all cluster peers. It fails when no peer had an operation
+// to cancel.
+func (c *Cluster) CancelOperation(h cid.Cid) error {
+	return c.broadcastOperationChange(
+		"TrackerCancelOperation",
+		api.Operation{Cid: h}.ToSerial(),
+	)
+}
+
+// CancelOperationLocal cancels the queued or in-progress operation for the
+// given Cid in this peer.
+func (c *Cluster) CancelOperationLocal(h cid.Cid) error {
+	return c.tracker.CancelOperation(h)
+}
+
+// SetOperationPriority changes the priority of the queued operations for
+// the given Cid in all cluster peers. It fails when no peer had a queued
+// operation for it.
+func (c *Cluster) SetOperationPriority(h cid.Cid, priority int) error {
+	return c.broadcastOperationChange(
+		"TrackerSetOperationPriority",
+		api.Operation{Cid: h, Priority: priority}.ToSerial(),
+	)
+}
+
+// SetOperationPriorityLocal changes the priority of the queued operation
+// for the given Cid in this peer.
+func (c *Cluster) SetOperationPriorityLocal(h cid.Cid, priority int) error {
+	return c.tracker.SetOperationPriority(h, priority)
+}
+
+// broadcastOperationChange calls the given tracker method in all peers. It
+// succeeds if it succeeded in at least one of them.
+func (c *Cluster) broadcastOperationChange(method string, arg api.OperationSerial) error {
+	members, err := c.consensus.Peers()
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+	lenMembers := len(members)
+
+	ctxs, cancels := rpcutil.CtxsWithCancel(c.ctx, lenMembers)
+	defer rpcutil.MultiCancel(cancels)
+
+	errs := c.rpcClient.MultiCall(
+		ctxs,
+		members,
+		"Cluster",
+		method,
+		arg,
+		rpcutil.RPCDiscardReplies(lenMembers),
+	)
+
+	for _, e := range errs {
+		if e == nil {
+			return nil
+		}
+	}
+	return rpcutil.CheckErrs(errs)
+}
+
 // Pins returns the list of Cids managed by Cluster and which are part
 // of the current global state. This is the source of truth as to which
 // pins are managed and their allocation, but does not indicate if
@@ -872,6 +1105,12 @@ func (c *Cluster) Pins() []api.Pin {
 
 }
 
+// PinsPage returns the pins in the current global state which are selected
+// by the given page, sorted by Cid. See Pins().
+func (c *Cluster) PinsPage(page api.ListPage) []api.Pin {
+	return page.Pins(c.Pins())
+}
+
 // PinGet returns information for a single Cid managed by Cluster.
 // The information is obtained from the current global state. The
 // returned api.Pin provides information about the allocations
@@ -906,11 +1145,56 @@ func (c *Cluster) PinGet(h cid.Cid) (api.Pin, error) {
 // this set then the remaining peers are allocated in order from the rest of
 // the cluster.  Priority allocations are best effort.  If any priority peers
 // are unavailable then Pin will simply allocate from the rest of the cluster.
+//
+// Pins with the default priority are classified first (see classifyPin).
 func (c *Cluster) Pin(pin api.Pin) error {
+	c.classifyPin(&pin)
 	_, err := c.pin(pin, []peer.ID{}, pin.Allocations)
 	return err
 }
 
+// classifyPin gives a high priority to the new data pins left at the
+// default one which are either smaller than the HighPrioritySize or the
+// root of one of the user homes in this peer.
+func (c *Cluster) classifyPin(pin *api.Pin) {
+	if pin.Type != api.DataType || pin.Priority != api.PriorityNormal {
+		return
+	}
+	if existing, err := c.PinGet(pin.Cid); err == nil {
+		// Repinning keeps the former priority.
+		pin.Priority = existing.Priority
+		return
+	}
+
+	small := pin.Size > 0 && pin.Size <= c.config.HighPrioritySize
+	if small || c.isHomeRoot(pin.Cid) {
+		logger.Debugf("%s is given a high priority", pin.Cid)
+		pin.Priority = api.PriorityHigh
+	}
+}
+
+// isHomeRoot returns true when the given Cid is the current root of one
+// of the user homes hosted by this peer.
+func (c *Cluster) isHomeRoot(ci cid.Cid) bool {
+	homes, err := c.ipfs.FilesLs([]string{"", ""})
+	if err != nil {
+		logger.Warningf("error listing homes to classify %s: %s", ci, err)
+		return false
+	}
+
+	hash := ci.String()
+	for _, home := range homes.Entries {
+		if api.IsStagingHome(home.Name) {
+			continue
+		}
+		stat, err := c.ipfs.FilesStat([]string{home.Name, "", "", "", "", ""})
+		if err == nil && stat.Hash == hash {
+			return true
+		}
+	}
+	return false
+}
+
 // sets the default replication factor in a pin when it's set to 0
 func (c *Cluster) setupReplicationFactor(pin *api.Pin) error {
 	rplMin := pin.ReplicationFactorMin
@@ -1000,13 +1284,7 @@ func (c *Cluster) pin(pin api.Pin, blacklist []peer.ID, prioritylist []peer.ID)
 		return true, c.consensus.LogPin(pin)
 	}
 
-	allocs, err := c.allocate(
-		pin.Cid,
-		pin.ReplicationFactorMin,
-		pin.ReplicationFactorMax,
-		blacklist,
-		prioritylist,
-	)
+	allocs, err := c.allocate(pin, blacklist, prioritylist)
 	if err != nil {
 		return false, err
 	}
@@ -1208,7 +1486,7 @@ func (c *Cluster) globalPinInfoCid(method string, h cid.Cid) (api.GlobalPinInfo,
 	return pin, nil
 }
 
-func (c *Cluster) globalPinInfoSlice(method string) ([]api.GlobalPinInfo, error) {
+func (c *Cluster) globalPinInfoSlice(method string, arg interface{}) ([]api.GlobalPinInfo, error) {
 	infos := make([]api.GlobalPinInfo, 0)
 	fullMap := make(map[string]api.GlobalPinInfo)
 
@@ -1229,7 +1507,7 @@ func (c *Cluster) globalPinInfoSlice(method string) ([]api.GlobalPinInfo, error)
 		members,
 		"Cluster",
 		method,
-		struct{}{},
+		arg,
 		rpcutil.CopyPinInfoSerialSliceToIfaces(replies),
 	)
 
@@ -1548,9 +1826,15 @@ func (c *Cluster) SyncUidRenew(l []string) (api.UIDRenew, error) {
 		}
 
 		if err == nil {
+			c.search.drop(peersUIDRenew[i].OldUID, peersUIDRenew[i].UID)
+			c.publishUidRenamed(peersUIDRenew[i])
 			return peersUIDRenew[i], nil
 		}
 	}
 
+	if localErr == nil {
+		c.search.drop(uidRenew.OldUID, uidRenew.UID)
+		c.publishUidRenamed(uidRenew)
+	}
 	return uidRenew, localErr
 }
@@ -32,6 +32,21 @@ const (
 	DefaultIPFSSyncInterval    = 130 * time.Second
 	DefaultMonitorPingInterval = 15 * time.Second
 	DefaultPeerWatchInterval   = 5 * time.Second
+	DefaultPinHealthInterval   = 5 * time.Minute
+	DefaultHomeReplication     = 1
+	DefaultHomePlacementMetric = "homes"
+	DefaultTrashRetention      = 30 * 24 * time.Hour
+	DefaultSearchIndex         = false
+	DefaultHighPrioritySize    = 1024 * 1024 // 1 MiB
+	DefaultRebalanceInterval   = 0
+	DefaultRebalanceMetric     = "numpin"
+	DefaultRebalanceThreshold  = 0.2
+	DefaultRebalanceMaxMoves   = 10
+	DefaultRebalancePinTimeout = 10 * time.Minute
+	DefaultDrainMaxMoves       = 10
+	DefaultDrainPinTimeout     = 30 * time.Minute
+	DefaultAlertTTL            = time.Minute
+	DefaultIPFSDownPolicy      = AlertPolicyStopAllocating
 	DefaultReplicationFactor   = -1
 	DefaultLeaveOnShutdown     = false
 	DefaultDisableRepinning    = false
@@ -111,6 +126,86 @@ type Config struct {
 	// been removed from a cluster.
 	PeerWatchInterval time.Duration
 
+	// PinHealthInterval is the frequency with which the cluster leader
+	// checks the replication health of the pinset, logging warnings and
+	// sending alerts when pins are under-replicated or in error.
+	PinHealthInterval time.Duration
+
+	// HomeReplication is the number of peers, including the one where
+	// a UID is created, which host a copy of the new user home.
+	HomeReplication int
+
+	// HomePlacementMetric is the name of the metric used to pick which
+	// peers replicate a new user home. Peers with the smallest values
+	// are preferred, so that tenants are spread evenly. A "tenants"
+	// informer producing it is run when HomeReplication is above 1.
+	HomePlacementMetric string
+
+	// TrashRetention is how long entries deleted from user homes are
+	// kept in their trash before being purged. Trash entries are never
+	// purged automatically when 0.
+	TrashRetention time.Duration
+
+	// SearchIndex enables the metadata index of the user homes used by
+	// files/search. The index of a home is built the first time it is
+	// searched and kept up to date on every modification of the home.
+	SearchIndex bool
+
+	// HighPrioritySize is the cumulative size, in bytes, up to which
+	// pins created with the default priority are given a high one, so
+	// that small files are available right away. Only pins whose size is
+	// known (those of added content) are classified by size. Pins of the
+	// user home roots are always given a high priority. 0 disables the
+	// classification by size.
+	HighPrioritySize uint64
+
+	// RebalanceInterval is the frequency with which the cluster leader
+	// moves allocations from the most loaded to the least loaded peer.
+	// Automatic rebalancing is disabled when 0.
+	RebalanceInterval time.Duration
+
+	// RebalanceMetric is the name of the metric used to compare the
+	// load of the peers. Larger values mean more load, unless
+	// RebalanceMetricInverse is set (i.e. for "freespace"). It must be
+	// produced by one of the informers of the peer, which the daemon
+	// runs for this purpose when automatic rebalancing is enabled.
+	RebalanceMetric        string
+	RebalanceMetricInverse bool
+
+	// RebalanceThreshold is the relative difference between the most
+	// and the least loaded peers above which allocations are moved.
+	RebalanceThreshold float64
+
+	// RebalanceMaxMoves is the maximum number of allocations moved in
+	// every rebalancing round.
+	RebalanceMaxMoves int
+
+	// RebalancePinTimeout is how long a moved allocation may take to be
+	// pinned in its new peer before the move is reverted.
+	RebalancePinTimeout time.Duration
+
+	// DrainMaxMoves is the maximum number of allocations moved away
+	// from draining peers at a time. A new batch starts once the
+	// previous one is done.
+	DrainMaxMoves int
+
+	// DrainPinTimeout is how long an allocation moved away from a
+	// draining peer may take to be pinned in its new peer before the
+	// move is reverted.
+	DrainPinTimeout time.Duration
+
+	// AlertThresholds trigger alerts when the informer metrics of a
+	// peer cross the given values (i.e. freespace below some bytes).
+	AlertThresholds []AlertThreshold
+
+	// IPFSDownPolicy is the alert policy applied to peers which
+	// report that their IPFS daemon is unreachable.
+	IPFSDownPolicy string
+
+	// AlertTTL is how long an alert stays active after it was last
+	// triggered.
+	AlertTTL time.Duration
+
 	// If true, DisableRepinning, ensures that no repinning happens
 	// when a node goes down.
 	// This is useful when doing certain types of maintainance, or simply
@@ -126,23 +221,40 @@ type Config struct {
 // saved using JSON. Most configuration keys are converted into simple types
 // like strings, and key names aim to be self-explanatory for the user.
 type configJSON struct {
-	ID                   string   `json:"id"`
-	Peername             string   `json:"peername"`
-	PrivateKey           string   `json:"private_key"`
-	Secret               string   `json:"secret"`
-	Peers                []string `json:"peers,omitempty"`     // DEPRECATED
-	Bootstrap            []string `json:"bootstrap,omitempty"` // DEPRECATED
-	LeaveOnShutdown      bool     `json:"leave_on_shutdown"`
-	ListenMultiaddress   string   `json:"listen_multiaddress"`
-	StateSyncInterval    string   `json:"state_sync_interval"`
-	IPFSSyncInterval     string   `json:"ipfs_sync_interval"`
-	ReplicationFactor    int      `json:"replication_factor,omitempty"` // legacy
-	ReplicationFactorMin int      `json:"replication_factor_min"`
-	ReplicationFactorMax int      `json:"replication_factor_max"`
-	MonitorPingInterval  string   `json:"monitor_ping_interval"`
-	PeerWatchInterval    string   `json:"peer_watch_interval"`
-	DisableRepinning     bool     `json:"disable_repinning"`
-	PeerstoreFile        string   `json:"peerstore_file,omitempty"`
+	ID                   string           `json:"id"`
+	Peername             string           `json:"peername"`
+	PrivateKey           string           `json:"private_key"`
+	Secret               string           `json:"secret"`
+	Peers                []string         `json:"peers,omitempty"`     // DEPRECATED
+	Bootstrap            []string         `json:"bootstrap,omitempty"` // DEPRECATED
+	LeaveOnShutdown      bool             `json:"leave_on_shutdown"`
+	ListenMultiaddress   string           `json:"listen_multiaddress"`
+	StateSyncInterval    string           `json:"state_sync_interval"`
+	IPFSSyncInterval     string           `json:"ipfs_sync_interval"`
+	ReplicationFactor    int              `json:"replication_factor,omitempty"` // legacy
+	ReplicationFactorMin int              `json:"replication_factor_min"`
+	ReplicationFactorMax int              `json:"replication_factor_max"`
+	MonitorPingInterval  string           `json:"monitor_ping_interval"`
+	PeerWatchInterval    string           `json:"peer_watch_interval"`
+	PinHealthInterval    string           `json:"pin_health_interval"`
+	HomeReplication      int              `json:"home_replication"`
+	HomePlacementMetric  string           `json:"home_placement_metric"`
+	TrashRetention       string           `json:"trash_retention"`
+	SearchIndex          bool             `json:"search_index"`
+	HighPrioritySize     *uint64          `json:"high_priority_size,omitempty"`
+	RebalanceInterval    string           `json:"rebalance_interval"`
+	RebalanceMetric      string           `json:"rebalance_metric"`
+	RebalanceInverse     bool             `json:"rebalance_metric_inverse"`
+	RebalanceThreshold   float64          `json:"rebalance_threshold"`
+	RebalanceMaxMoves    int              `json:"rebalance_max_moves"`
+	RebalancePinTimeout  string           `json:"rebalance_pin_timeout"`
+	DrainMaxMoves        int              `json:"drain_max_moves"`
+	DrainPinTimeout      string           `json:"drain_pin_timeout"`
+	AlertThresholds      []AlertThreshold `json:"alert_thresholds"`
+	IPFSDownPolicy       string           `json:"ipfs_down_policy"`
+	AlertTTL             string           `json:"alert_ttl"`
+	DisableRepinning     bool             `json:"disable_repinning"`
+	PeerstoreFile        string           `json:"peerstore_file,omitempty"`
 }
 
 // ConfigKey returns a human-readable string to identify
@@ -218,6 +330,64 @@ func (cfg *Config) Validate() error {
 		return errors.New("cluster.peer_watch_interval is invalid")
 	}
 
+	if cfg.PinHealthInterval <= 0 {
+		return errors.New("cluster.pin_health_interval is invalid")
+	}
+
+	if cfg.HomeReplication <= 0 {
+		return errors.New("cluster.home_replication is invalid")
+	}
+
+	if cfg.HomePlacementMetric == "" {
+		return errors.New("cluster.home_placement_metric is invalid")
+	}
+
+	if cfg.TrashRetention < 0 {
+		return errors.New("cluster.trash_retention is invalid")
+	}
+
+	if cfg.RebalanceInterval < 0 {
+		return errors.New("cluster.rebalance_interval is invalid")
+	}
+
+	if cfg.RebalanceMetric == "" {
+		return errors.New("cluster.rebalance_metric is invalid")
+	}
+
+	if cfg.RebalanceThreshold <= 0 {
+		return errors.New("cluster.rebalance_threshold is invalid")
+	}
+
+	if cfg.RebalanceMaxMoves <= 0 {
+		return errors.New("cluster.rebalance_max_moves is invalid")
+	}
+
+	if cfg.RebalancePinTimeout <= 0 {
+		return errors.New("cluster.rebalance_pin_timeout is invalid")
+	}
+
+	if cfg.DrainMaxMoves <= 0 {
+		return errors.New("cluster.drain_max_moves is invalid")
+	}
+
+	if cfg.DrainPinTimeout <= 0 {
+		return errors.New("cluster.drain_pin_timeout is invalid")
+	}
+
+	for _, th := range cfg.AlertThresholds {
+		if err := th.validate(); err != nil {
+			return err
+		}
+	}
+
+	if !isAlertPolicyValid(cfg.IPFSDownPolicy) {
+		return errors.New("cluster.ipfs_down_policy is invalid")
+	}
+
+	if cfg.AlertTTL <= 0 {
+		return errors.New("cluster.alert_ttl is invalid")
+	}
+
 	rfMax := cfg.ReplicationFactorMax
 	rfMin := cfg.ReplicationFactorMin
 
@@ -265,6 +435,23 @@ func (cfg *Config) setDefaults() {
 	cfg.ReplicationFactorMax = DefaultReplicationFactor
 	cfg.MonitorPingInterval = DefaultMonitorPingInterval
 	cfg.PeerWatchInterval = DefaultPeerWatchInterval
+	cfg.PinHealthInterval = DefaultPinHealthInterval
+	cfg.HomeReplication = DefaultHomeReplication
+	cfg.HomePlacementMetric = DefaultHomePlacementMetric
+	cfg.TrashRetention = DefaultTrashRetention
+	cfg.SearchIndex = DefaultSearchIndex
+	cfg.HighPrioritySize = DefaultHighPrioritySize
+	cfg.RebalanceInterval = DefaultRebalanceInterval
+	cfg.RebalanceMetric = DefaultRebalanceMetric
+	cfg.RebalanceMetricInverse = false
+	cfg.RebalanceThreshold = DefaultRebalanceThreshold
+	cfg.RebalanceMaxMoves = DefaultRebalanceMaxMoves
+	cfg.RebalancePinTimeout = DefaultRebalancePinTimeout
+	cfg.DrainMaxMoves = DefaultDrainMaxMoves
+	cfg.DrainPinTimeout = DefaultDrainPinTimeout
+	cfg.AlertThresholds = []AlertThreshold{}
+	cfg.IPFSDownPolicy = DefaultIPFSDownPolicy
+	cfg.AlertTTL = DefaultAlertTTL
 	cfg.DisableRepinning = DefaultDisableRepinning
 	cfg.PeerstoreFile = "" // empty so it gets ommited.
 }
@@ -366,14 +553,48 @@ for more information.`)
 	ipfsSyncInterval := parseDuration(jcfg.IPFSSyncInterval)
 	monitorPingInterval := parseDuration(jcfg.MonitorPingInterval)
 	peerWatchInterval := parseDuration(jcfg.PeerWatchInterval)
+	pinHealthInterval := parseDuration(jcfg.PinHealthInterval)
+	rebalanceInterval := parseDuration(jcfg.RebalanceInterval)
+	rebalancePinTimeout := parseDuration(jcfg.RebalancePinTimeout)
+	drainPinTimeout := parseDuration(jcfg.DrainPinTimeout)
+	alertTTL := parseDuration(jcfg.AlertTTL)
 
 	config.SetIfNotDefault(stateSyncInterval, &cfg.StateSyncInterval)
 	config.SetIfNotDefault(ipfsSyncInterval, &cfg.IPFSSyncInterval)
 	config.SetIfNotDefault(monitorPingInterval, &cfg.MonitorPingInterval)
 	config.SetIfNotDefault(peerWatchInterval, &cfg.PeerWatchInterval)
+	config.SetIfNotDefault(pinHealthInterval, &cfg.PinHealthInterval)
+	config.SetIfNotDefault(jcfg.HomeReplication, &cfg.HomeReplication)
+	config.SetIfNotDefault(jcfg.HomePlacementMetric, &cfg.HomePlacementMetric)
+	if jcfg.TrashRetention != "" { // 0 disables purging
+		trashRetention, err := time.ParseDuration(jcfg.TrashRetention)
+		if err != nil {
+			return fmt.Errorf("error parsing cluster.trash_retention: %s", err)
+		}
+		cfg.TrashRetention = trashRetention
+	}
+	if jcfg.HighPrioritySize != nil { // 0 disables it
+		cfg.HighPrioritySize = *jcfg.HighPrioritySize
+	}
+	config.SetIfNotDefault(rebalanceInterval, &cfg.RebalanceInterval)
+	config.SetIfNotDefault(jcfg.RebalanceMetric, &cfg.RebalanceMetric)
+	config.SetIfNotDefault(jcfg.RebalanceMaxMoves, &cfg.RebalanceMaxMoves)
+	config.SetIfNotDefault(rebalancePinTimeout, &cfg.RebalancePinTimeout)
+	config.SetIfNotDefault(jcfg.DrainMaxMoves, &cfg.DrainMaxMoves)
+	config.SetIfNotDefault(drainPinTimeout, &cfg.DrainPinTimeout)
+	if jcfg.RebalanceThreshold != 0 {
+		cfg.RebalanceThreshold = jcfg.RebalanceThreshold
+	}
+	if jcfg.AlertThresholds != nil {
+		cfg.AlertThresholds = jcfg.AlertThresholds
+	}
+	config.SetIfNotDefault(jcfg.IPFSDownPolicy, &cfg.IPFSDownPolicy)
+	config.SetIfNotDefault(alertTTL, &cfg.AlertTTL)
 
 	cfg.LeaveOnShutdown = jcfg.LeaveOnShutdown
 	cfg.DisableRepinning = jcfg.DisableRepinning
+	cfg.SearchIndex = jcfg.SearchIndex
+	cfg.RebalanceMetricInverse = jcfg.RebalanceInverse
 
 	return cfg.Validate()
 }
@@ -409,6 +630,23 @@ func (cfg *Config) ToJSON() (raw []byte, err error) {
 	jcfg.IPFSSyncInterval = cfg.IPFSSyncInterval.String()
 	jcfg.MonitorPingInterval = cfg.MonitorPingInterval.String()
 	jcfg.PeerWatchInterval = cfg.PeerWatchInterval.String()
+	jcfg.PinHealthInterval = cfg.PinHealthInterval.String()
+	jcfg.HomeReplication = cfg.HomeReplication
+	jcfg.HomePlacementMetric = cfg.HomePlacementMetric
+	jcfg.TrashRetention = cfg.TrashRetention.String()
+	jcfg.SearchIndex = cfg.SearchIndex
+	jcfg.HighPrioritySize = &cfg.HighPrioritySize
+	jcfg.RebalanceInterval = cfg.RebalanceInterval.String()
+	jcfg.RebalanceMetric = cfg.RebalanceMetric
+	jcfg.RebalanceInverse = cfg.RebalanceMetricInverse
+	jcfg.RebalanceThreshold = cfg.RebalanceThreshold
+	jcfg.RebalanceMaxMoves = cfg.RebalanceMaxMoves
+	jcfg.RebalancePinTimeout = cfg.RebalancePinTimeout.String()
+	jcfg.DrainMaxMoves = cfg.DrainMaxMoves
+	jcfg.DrainPinTimeout = cfg.DrainPinTimeout.String()
+	jcfg.AlertThresholds = cfg.AlertThresholds
+	jcfg.IPFSDownPolicy = cfg.IPFSDownPolicy
+	jcfg.AlertTTL = cfg.AlertTTL.String()
 	jcfg.DisableRepinning = cfg.DisableRepinning
 	jcfg.PeerstoreFile = cfg.PeerstoreFile
 
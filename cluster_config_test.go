@@ -188,6 +188,58 @@ func TestLoadJSON(t *testing.T) {
 		}
 	})
 
+	t.Run("trash retention", func(t *testing.T) {
+		cfg, err := loadJSON(t)
+		if err != nil {
+			t.Error(err)
+		}
+		if cfg.TrashRetention != DefaultTrashRetention {
+			t.Error("expected default trash_retention")
+		}
+
+		cfg, err = loadJSON2(t, func(j *configJSON) { j.TrashRetention = "0s" })
+		if err != nil {
+			t.Error(err)
+		}
+		if cfg.TrashRetention != 0 {
+			t.Error("expected trash_retention to be disabled")
+		}
+
+		_, err = loadJSON2(t, func(j *configJSON) { j.TrashRetention = "abc" })
+		if err == nil {
+			t.Error("expected error parsing trash_retention")
+		}
+	})
+
+	t.Run("search index", func(t *testing.T) {
+		cfg, err := loadJSON2(t, func(j *configJSON) { j.SearchIndex = true })
+		if err != nil {
+			t.Error(err)
+		}
+		if !cfg.SearchIndex {
+			t.Error("expected search_index to be enabled")
+		}
+	})
+
+	t.Run("high priority size", func(t *testing.T) {
+		cfg, err := loadJSON(t)
+		if err != nil {
+			t.Error(err)
+		}
+		if cfg.HighPrioritySize != DefaultHighPrioritySize {
+			t.Error("expected default high_priority_size")
+		}
+
+		var size uint64
+		cfg, err = loadJSON2(t, func(j *configJSON) { j.HighPrioritySize = &size })
+		if err != nil {
+			t.Error(err)
+		}
+		if cfg.HighPrioritySize != 0 {
+			t.Error("expected high_priority_size to be disabled")
+		}
+	})
+
 	t.Run("env var override", func(t *testing.T) {
 		os.Setenv("CLUSTER_PEERNAME", "envsetpeername")
 		cfg := &Config{}
@@ -234,6 +286,96 @@ func TestValidate(t *testing.T) {
 		t.Fatal("expected error validating")
 	}
 
+	cfg.Default()
+	cfg.PinHealthInterval = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.HomeReplication = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.HomePlacementMetric = ""
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.TrashRetention = -1
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.RebalanceInterval = -1
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.RebalanceMetric = ""
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.RebalanceThreshold = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.RebalanceMaxMoves = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.RebalancePinTimeout = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.DrainMaxMoves = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.DrainPinTimeout = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.IPFSDownPolicy = "reboot"
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.AlertTTL = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.AlertThresholds = []AlertThreshold{{Metric: "freespace", Condition: "equal", Policy: AlertPolicyNotify}}
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.AlertThresholds = []AlertThreshold{{Metric: "freespace", Condition: "below", Policy: "reboot"}}
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
 	cfg.Default()
 	cfg.ReplicationFactorMin = 10
 	cfg.ReplicationFactorMax = 5
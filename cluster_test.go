@@ -14,7 +14,9 @@ import (
 	"github.com/elastos/Elastos.NET.Hive.Cluster/allocator/ascendalloc"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/consensus/raft"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/informer/disk"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/informer/numpin"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/informer/tenants"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/state"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/state/mapstate"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/test"
@@ -40,6 +42,21 @@ func (c *mockComponent) SetClient(client *rpc.Client) {
 
 type mockAPI struct {
 	mockComponent
+
+	eventsMux sync.Mutex
+	events    []api.Event
+}
+
+func (mapi *mockAPI) HandleEvent(ev api.Event) {
+	mapi.eventsMux.Lock()
+	defer mapi.eventsMux.Unlock()
+	mapi.events = append(mapi.events, ev)
+}
+
+func (mapi *mockAPI) receivedEvents() []api.Event {
+	mapi.eventsMux.Lock()
+	defer mapi.eventsMux.Unlock()
+	return append([]api.Event{}, mapi.events...)
 }
 
 type mockProxy struct {
@@ -123,6 +140,10 @@ func (ipfs *mockConnector) BlockGet(c cid.Cid) ([]byte, error) {
 	return d.([]byte), nil
 }
 
+func (ipfs *mockConnector) BlockGetLocal(c cid.Cid) ([]byte, error) {
+	return ipfs.BlockGet(c)
+}
+
 func testingCluster(t *testing.T) (*Cluster, *mockAPI, *mockConnector, state.State, PinTracker) {
 	clusterCfg, _, _, _, consensusCfg, maptrackerCfg, statelesstrackerCfg, bmonCfg, psmonCfg, _ := testingConfigs()
 
@@ -143,7 +164,7 @@ func testingCluster(t *testing.T) (*Cluster, *mockAPI, *mockConnector, state.Sta
 	psmonCfg.CheckInterval = 2 * time.Second
 	mon := makeMonitor(t, host, bmonCfg, psmonCfg)
 
-	alloc := ascendalloc.NewAllocator()
+	alloc := ascendalloc.NewAllocator(numpin.MetricName)
 	numpinCfg := &numpin.Config{}
 	numpinCfg.Default()
 	inf, _ := numpin.NewInformer(numpinCfg)
@@ -261,6 +282,302 @@ func TestClusterPin(t *testing.T) {
 	}
 }
 
+func TestClusterEvents(t *testing.T) {
+	cl, mapi, _, _, _ := testingCluster(t)
+	defer cleanRaft()
+	defer cl.Shutdown()
+
+	c, _ := cid.Decode(test.TestCid1)
+	err := cl.Pin(api.PinCid(c))
+	if err != nil {
+		t.Fatal("pin should have worked:", err)
+	}
+	pinDelay()
+
+	cl.PublishEvent(api.Event{Type: api.EventUidCreated, UID: "uid-test"})
+	time.Sleep(200 * time.Millisecond)
+
+	var pinDone, uidCreated bool
+	for _, ev := range mapi.receivedEvents() {
+		if ev.Peer != cl.id {
+			t.Error("event peer should be set")
+		}
+		switch ev.Type {
+		case api.EventPinDone:
+			pinDone = pinDone || ev.Cid.Equals(c)
+		case api.EventUidCreated:
+			uidCreated = ev.UID == "uid-test" && !ev.TS.IsZero()
+		}
+	}
+	if !pinDone {
+		t.Error("expected a pin_done event")
+	}
+	if !uidCreated {
+		t.Error("expected a uid_created event")
+	}
+}
+
+func TestClusterPinTagsWithoutInformer(t *testing.T) {
+	cl, _, _, _, _ := testingCluster(t)
+	defer cleanRaft()
+	defer cl.Shutdown()
+
+	c, _ := cid.Decode(test.TestCid1)
+	pin := api.PinWithOpts(c, api.PinOptions{
+		ReplicationFactorMin: 1,
+		ReplicationFactorMax: 1,
+		SpreadTag:            "region",
+	})
+	err := cl.Pin(pin)
+	if err == nil {
+		t.Error("expected an error pinning with tags and no tags informer")
+	}
+}
+
+func TestCheckTagConstraintsSpread(t *testing.T) {
+	tags := func(region string) api.MetricsSet {
+		return api.MetricsSet{
+			api.TagsMetricName: api.Metric{
+				Name:  api.TagsMetricName,
+				Value: `{"region":"` + region + `"}`,
+				Valid: true,
+			},
+		}
+	}
+	sets := map[peer.ID]api.MetricsSet{
+		test.TestPeerID1: tags("eu"),
+		test.TestPeerID2: tags("eu"),
+		test.TestPeerID3: tags("us"),
+	}
+	c, _ := cid.Decode(test.TestCid1)
+	pin := api.PinWithOpts(c, api.PinOptions{
+		ReplicationFactorMin: 2,
+		ReplicationFactorMax: 3,
+		SpreadTag:            "region",
+	})
+
+	allocs := []peer.ID{test.TestPeerID1, test.TestPeerID3, test.TestPeerID2}
+	if err := checkTagConstraints(pin, nil, allocs, sets); err != nil {
+		t.Error(err)
+	}
+
+	allocs = []peer.ID{test.TestPeerID1, test.TestPeerID2}
+	if err := checkTagConstraints(pin, nil, allocs, sets); err == nil {
+		t.Error("expected an error when a single region is used")
+	}
+
+	// Only two regions exist: three replicas cannot be spread.
+	pin.ReplicationFactorMin = 3
+	allocs = []peer.ID{test.TestPeerID1, test.TestPeerID3, test.TestPeerID2}
+	if err := checkTagConstraints(pin, nil, allocs, sets); err == nil {
+		t.Error("expected an error when fewer regions than needed are available")
+	}
+
+	// Pins allocated everywhere have no minimum.
+	pin.ReplicationFactorMin = -1
+	pin.ReplicationFactorMax = -1
+	if err := checkTagConstraints(pin, nil, allocs, sets); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMetricsSets(t *testing.T) {
+	metric := func(name string, p peer.ID) api.Metric {
+		return api.Metric{Name: name, Peer: p, Value: "1", Valid: true}
+	}
+	latest := map[string][]api.Metric{
+		"freespace": {
+			metric("freespace", test.TestPeerID1),
+			metric("freespace", test.TestPeerID2),
+		},
+		"numpin": {
+			metric("numpin", test.TestPeerID1),
+		},
+	}
+
+	sets := metricsSets(latest)
+	if len(sets) != 1 || len(sets[test.TestPeerID1]) != 2 {
+		t.Errorf("only peers with all the metrics should be kept: %v", sets)
+	}
+
+	delete(latest, "numpin")
+	sets = metricsSets(latest)
+	if len(sets) != 2 {
+		t.Errorf("both peers should be kept: %v", sets)
+	}
+}
+
+func TestClusterAllocationMetrics(t *testing.T) {
+	cl, _, _, _, _ := testingCluster(t)
+	defer cleanRaft()
+	defer cl.Shutdown()
+
+	// Informers not used by the allocator, like the one for the
+	// placement of homes or those run for alert thresholds, are not
+	// needed to allocate.
+	tntCfg := &tenants.Config{}
+	tntCfg.Default()
+	tnt, _ := tenants.NewInformer(tntCfg)
+	dskCfg := &disk.Config{}
+	dskCfg.Default()
+	dsk, _ := disk.NewInformer(dskCfg)
+	cl.informers = append(cl.informers, tnt, dsk)
+
+	c, _ := cid.Decode(test.TestCid1)
+	names := cl.allocationMetrics(api.PinCid(c))
+	if len(names) != 1 || names[0] != numpin.MetricName {
+		t.Errorf("unexpected allocation metrics: %s", names)
+	}
+
+	pin := api.PinWithOpts(c, api.PinOptions{SpreadTag: "region"})
+	names = cl.allocationMetrics(pin)
+	if len(names) != 2 || names[1] != api.TagsMetricName {
+		t.Errorf("the tags metric is needed for pins with tags: %s", names)
+	}
+}
+
+func TestClusterHomePlacementMetrics(t *testing.T) {
+	cl, _, _, _, _ := testingCluster(t)
+	defer cleanRaft()
+	defer cl.Shutdown()
+
+	cl.config.AlertThresholds = []AlertThreshold{
+		{Metric: "freespace", Condition: "below", Value: 1000, Policy: AlertPolicyStopAllocating},
+	}
+	alrt := api.Alert{Peer: test.TestPeerID2, MetricName: "freespace", Value: "10"}
+	cl.recordAlert(&alrt)
+
+	var metrics []api.Metric
+	for _, p := range []peer.ID{test.TestPeerID1, test.TestPeerID2, test.TestPeerID3} {
+		m := api.Metric{Name: "homes", Peer: p, Value: "1", Valid: true}
+		m.SetTTL(time.Minute)
+		metrics = append(metrics, m)
+	}
+
+	candidates := cl.homePlacementMetrics(metrics)
+	if len(candidates) != 2 {
+		t.Fatalf("unexpected candidates: %v", candidates)
+	}
+	for _, m := range candidates {
+		if m.Peer == test.TestPeerID2 {
+			t.Error("peers blocked by alerts should not receive homes")
+		}
+	}
+}
+
+func TestHomePlacement(t *testing.T) {
+	metric := func(p peer.ID, v string) api.Metric {
+		return api.Metric{
+			Name:  "homes",
+			Peer:  p,
+			Value: v,
+			Valid: true,
+		}
+	}
+	metrics := []api.Metric{
+		metric(test.TestPeerID1, "1"),
+		metric(test.TestPeerID2, "10"),
+		metric(test.TestPeerID3, "5"),
+		metric(test.TestPeerID4, "2"),
+	}
+	for i := range metrics {
+		metrics[i].SetTTL(time.Minute)
+	}
+
+	peers := homePlacement(metrics, test.TestPeerID1, 2)
+	if len(peers) != 2 || peers[0] != test.TestPeerID4 || peers[1] != test.TestPeerID3 {
+		t.Errorf("unexpected placement: %s", peers)
+	}
+
+	peers = homePlacement(metrics, test.TestPeerID1, 5)
+	if len(peers) != 3 {
+		t.Errorf("expected all the other peers: %s", peers)
+	}
+}
+
+func TestMostAndLeastLoaded(t *testing.T) {
+	loads := map[peer.ID]float64{
+		test.TestPeerID1: 10,
+		test.TestPeerID2: 2,
+		test.TestPeerID3: 5,
+	}
+	most, least, ok := mostAndLeastLoaded(loads, 0.2)
+	if !ok || most != test.TestPeerID1 || least != test.TestPeerID2 {
+		t.Errorf("unexpected peers: %s %s", most, least)
+	}
+
+	_, _, ok = mostAndLeastLoaded(loads, 0.9)
+	if ok {
+		t.Error("difference should be below the threshold")
+	}
+
+	// inverse metrics (i.e. freespace) are negated
+	loads = map[peer.ID]float64{
+		test.TestPeerID1: -100,
+		test.TestPeerID2: -1000,
+	}
+	most, least, ok = mostAndLeastLoaded(loads, 0.2)
+	if !ok || most != test.TestPeerID1 || least != test.TestPeerID2 {
+		t.Errorf("unexpected peers: %s %s", most, least)
+	}
+
+	_, _, ok = mostAndLeastLoaded(map[peer.ID]float64{test.TestPeerID1: 1}, 0.2)
+	if ok {
+		t.Error("a single peer cannot be rebalanced")
+	}
+}
+
+func TestClusterRebalanceDryRun(t *testing.T) {
+	cl, _, _, _, _ := testingCluster(t)
+	defer cleanRaft()
+	defer cl.Shutdown()
+
+	report, err := cl.Rebalance(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.DryRun || report.Metric != cl.config.RebalanceMetric {
+		t.Error("unexpected report")
+	}
+	if len(report.Moves) != 0 {
+		t.Error("a single peer cluster has nothing to move")
+	}
+
+	cl.config.RebalanceMetric = "freespace"
+	_, err = cl.Rebalance(true)
+	if err == nil {
+		t.Error("expected an error without an informer for the rebalance metric")
+	}
+}
+
+func TestClusterRebalanceLoads(t *testing.T) {
+	cl, _, _, _, _ := testingCluster(t)
+	defer cleanRaft()
+	defer cl.Shutdown()
+
+	cl.config.AlertThresholds = []AlertThreshold{
+		{Metric: "freespace", Condition: "below", Value: 1000, Policy: AlertPolicyStopAllocating},
+	}
+	alrt := api.Alert{Peer: test.TestPeerID2, MetricName: "freespace", Value: "10"}
+	cl.recordAlert(&alrt)
+
+	var metrics []api.Metric
+	for _, p := range []peer.ID{test.TestPeerID1, test.TestPeerID2, test.TestPeerID3} {
+		m := api.Metric{Name: "numpin", Peer: p, Value: "10", Valid: true}
+		m.SetTTL(time.Minute)
+		metrics = append(metrics, m)
+	}
+
+	report := api.RebalanceReport{Loads: make(map[peer.ID]string)}
+	loads := cl.rebalanceLoads(metrics, &report)
+	if _, ok := loads[test.TestPeerID2]; ok {
+		t.Error("peers blocked by alerts should not take part in a rebalance")
+	}
+	if len(loads) != 2 || len(report.Loads) != 2 {
+		t.Errorf("unexpected loads: %v", loads)
+	}
+}
+
 func TestAddFile(t *testing.T) {
 	cl, _, _, _, _ := testingCluster(t)
 	defer cleanRaft()
@@ -317,6 +634,19 @@ func TestAddFile(t *testing.T) {
 
 		// We know that this produces 14 shards.
 		sharding.VerifyShards(t, c, cl, cl.ipfs, 14)
+
+		gpi, err := cl.Status(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(gpi.Shards) != 14 {
+			t.Fatalf("expected the status of 14 shards, got %d", len(gpi.Shards))
+		}
+		for _, shard := range gpi.Shards {
+			if st := shard.PeerMap[cl.id].Status; st != api.TrackerStatusPinned {
+				t.Errorf("shard %s should be pinned but is %s", shard.Cid, st)
+			}
+		}
 	})
 }
 
@@ -773,6 +1103,110 @@ func TestClusterPeers(t *testing.T) {
 	}
 }
 
+func TestClusterPeerDrain(t *testing.T) {
+	cl, _, _, _, _ := testingCluster(t)
+	defer cleanRaft()
+	defer cl.Shutdown()
+
+	err := cl.PeerDrain(test.TestPeerID2)
+	if err == nil {
+		t.Error("expected an error draining a non-cluster peer")
+	}
+
+	err = cl.PeerDrain(cl.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	infos, err := cl.DrainStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Peer != cl.id || infos[0].Remaining != 0 {
+		t.Errorf("unexpected drain status: %+v", infos)
+	}
+
+	// the only peer is draining, so nothing can be allocated
+	c, _ := cid.Decode(test.TestCid1)
+	pin := api.PinWithOpts(c, api.PinOptions{
+		ReplicationFactorMin: 1,
+		ReplicationFactorMax: 1,
+	})
+	err = cl.Pin(pin)
+	if err == nil {
+		t.Error("expected an error allocating to a draining peer")
+	}
+
+	err = cl.PeerUndrain(cl.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	infos, _ = cl.DrainStatus()
+	if len(infos) != 0 {
+		t.Error("expected no draining peers")
+	}
+}
+
+func TestAlertThresholdCrossed(t *testing.T) {
+	below := AlertThreshold{Metric: "freespace", Condition: "below", Value: 1000}
+	if !below.crossed("999") || below.crossed("1000") || below.crossed("abc") {
+		t.Error("bad below threshold")
+	}
+	above := AlertThreshold{Metric: "repousage", Condition: "above", Value: 90}
+	if !above.crossed("91") || above.crossed("90") {
+		t.Error("bad above threshold")
+	}
+}
+
+func TestClusterAlerts(t *testing.T) {
+	cl, _, _, _, _ := testingCluster(t)
+	defer cleanRaft()
+	defer cl.Shutdown()
+
+	cl.config.AlertThresholds = []AlertThreshold{
+		{Metric: "freespace", Condition: "below", Value: 1000, Policy: AlertPolicyStopAllocating},
+	}
+
+	alrt := api.Alert{Peer: test.TestPeerID2, MetricName: "freespace", Value: "10"}
+	if !cl.recordAlert(&alrt) {
+		t.Error("the alert should be new")
+	}
+	if alrt.Policy != AlertPolicyStopAllocating {
+		t.Error("the threshold policy should apply")
+	}
+	if cl.recordAlert(&alrt) {
+		t.Error("the alert should be active already")
+	}
+	if !cl.allocationBlocked(test.TestPeerID2) || cl.allocationBlocked(test.TestPeerID3) {
+		t.Error("only the alerted peer should be blocked")
+	}
+
+	notify := api.Alert{Peer: test.TestPeerID3, MetricName: pinHealthAlertName}
+	cl.recordAlert(&notify)
+	if cl.allocationBlocked(test.TestPeerID3) {
+		t.Error("notify alerts should not block allocations")
+	}
+
+	alerts := cl.Alerts()
+	if len(alerts) != 2 {
+		t.Fatalf("expected 2 active alerts: %+v", alerts)
+	}
+
+	cl.alertsMux.Lock()
+	expired := cl.alerts[test.TestPeerID2]["freespace"]
+	expired.Expire = time.Now().Add(-time.Second)
+	cl.alerts[test.TestPeerID2]["freespace"] = expired
+	cl.alertsMux.Unlock()
+
+	cl.pruneAlerts()
+	cl.alertsMux.RLock()
+	_, ok := cl.alerts[test.TestPeerID2]
+	n := len(cl.alerts)
+	cl.alertsMux.RUnlock()
+	if ok || n != 1 {
+		t.Error("expired alerts should be forgotten")
+	}
+}
+
 func TestVersion(t *testing.T) {
 	cl, _, _, _, _ := testingCluster(t)
 	defer cleanRaft()
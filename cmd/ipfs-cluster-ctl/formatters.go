@@ -39,6 +39,14 @@ func jsonFormatObject(resp interface{}) {
 	case api.Metric:
 		serial := resp.(api.Metric)
 		textFormatPrintMetric(&serial)
+	case api.Operation:
+		jsonFormatPrint(resp.(api.Operation).ToSerial())
+	case api.PinsHealthReport:
+		jsonFormatPrint(resp.(api.PinsHealthReport).ToSerial())
+	case api.RebalanceReport:
+		jsonFormatPrint(resp.(api.RebalanceReport).ToSerial())
+	case api.Event:
+		jsonFormatPrint(resp.(api.Event).ToSerial())
 	case api.Error:
 		jsonFormatPrint(resp.(api.Error))
 	case []api.ID:
@@ -78,6 +86,34 @@ func jsonFormatObject(resp interface{}) {
 	case []api.Metric:
 		serials := resp.([]api.Metric)
 		jsonFormatPrint(serials)
+	case []api.Operation:
+		r := resp.([]api.Operation)
+		serials := make([]api.OperationSerial, len(r), len(r))
+		for i, item := range r {
+			serials[i] = item.ToSerial()
+		}
+		jsonFormatPrint(serials)
+	case []api.DrainInfo:
+		r := resp.([]api.DrainInfo)
+		serials := make([]api.DrainInfoSerial, len(r), len(r))
+		for i, item := range r {
+			serials[i] = item.ToSerial()
+		}
+		jsonFormatPrint(serials)
+	case []api.Alert:
+		r := resp.([]api.Alert)
+		serials := make([]api.AlertSerial, len(r), len(r))
+		for i, item := range r {
+			serials[i] = item.ToSerial()
+		}
+		jsonFormatPrint(serials)
+	case []api.MetricHistory:
+		r := resp.([]api.MetricHistory)
+		serials := make([]api.MetricHistorySerial, len(r), len(r))
+		for i, item := range r {
+			serials[i] = item.ToSerial()
+		}
+		jsonFormatPrint(serials)
 	default:
 		checkErr("", errors.New("unsupported type returned"))
 	}
@@ -117,6 +153,27 @@ func textFormatObject(resp interface{}) {
 	case api.Metric:
 		serial := resp.(api.Metric)
 		textFormatPrintMetric(&serial)
+	case api.Operation:
+		serial := resp.(api.Operation).ToSerial()
+		textFormatPrintOperation(&serial)
+	case api.PinsHealthReport:
+		serial := resp.(api.PinsHealthReport).ToSerial()
+		textFormatPrintPinsHealthReport(&serial)
+	case api.RebalanceReport:
+		serial := resp.(api.RebalanceReport).ToSerial()
+		textFormatPrintRebalanceReport(&serial)
+	case api.DrainInfo:
+		serial := resp.(api.DrainInfo).ToSerial()
+		textFormatPrintDrainInfo(&serial)
+	case api.Alert:
+		serial := resp.(api.Alert).ToSerial()
+		textFormatPrintAlert(&serial)
+	case api.MetricHistory:
+		serial := resp.(api.MetricHistory).ToSerial()
+		textFormatPrintMetricHistory(&serial)
+	case api.Event:
+		serial := resp.(api.Event).ToSerial()
+		textFormatPrintEvent(&serial)
 	case []api.ID:
 		for _, item := range resp.([]api.ID) {
 			textFormatObject(item)
@@ -141,6 +198,30 @@ func textFormatObject(resp interface{}) {
 		for _, item := range resp.([]api.Metric) {
 			textFormatObject(item)
 		}
+	case []api.Operation:
+		for _, item := range resp.([]api.Operation) {
+			textFormatObject(item)
+		}
+	case []api.DrainInfo:
+		r := resp.([]api.DrainInfo)
+		if len(r) == 0 {
+			fmt.Println("No draining peers")
+		}
+		for _, item := range r {
+			textFormatObject(item)
+		}
+	case []api.Alert:
+		r := resp.([]api.Alert)
+		if len(r) == 0 {
+			fmt.Println("No active alerts")
+		}
+		for _, item := range r {
+			textFormatObject(item)
+		}
+	case []api.MetricHistory:
+		for _, item := range resp.([]api.MetricHistory) {
+			textFormatObject(item)
+		}
 	default:
 		checkErr("", errors.New("unsupported type returned"))
 	}
@@ -196,8 +277,29 @@ func textFormatPrintGPInfo(obj *api.GlobalPinInfoSerial) {
 		if v.Error != "" {
 			fmt.Printf(": %s", v.Error)
 		}
+		if v.Progress > 0 && v.Status == api.TrackerStatusPinning.String() {
+			fmt.Printf(" (%d blocks)", v.Progress)
+		}
 		fmt.Printf(" | %s\n", v.TS)
 	}
+
+	if len(obj.Shards) == 0 {
+		return
+	}
+	fmt.Printf("    shards (%d):\n", len(obj.Shards))
+	for _, shard := range obj.Shards {
+		// Count the peers by status
+		counts := make(map[string]int)
+		for _, v := range shard.PeerMap {
+			counts[strings.ToUpper(v.Status)]++
+		}
+		statuses := make(sort.StringSlice, 0, len(counts))
+		for st, n := range counts {
+			statuses = append(statuses, fmt.Sprintf("%s: %d", st, n))
+		}
+		statuses.Sort()
+		fmt.Printf("      - %s : %s\n", shard.Cid, strings.Join(statuses, ", "))
+	}
 }
 
 func textFormatPrintPInfo(obj *api.PinInfoSerial) {
@@ -256,6 +358,140 @@ func textFormatPrintMetric(obj *api.Metric) {
 	fmt.Printf("%s: %s | Expire: %s\n", peer.IDB58Encode(obj.Peer), obj.Value, date)
 }
 
+func textFormatPrintOperation(obj *api.OperationSerial) {
+	peerStr := obj.PeerName
+	if peerStr == "" {
+		peerStr = obj.Peer
+	}
+	age := time.Since(obj.ToOperation().Created).Truncate(time.Second)
+	fmt.Printf("%s | %s | %s | %s | Priority: %d | Attempts: %d | Blocks: %d | Age: %s",
+		obj.Cid, peerStr, strings.ToUpper(obj.Type), strings.ToUpper(obj.Phase),
+		obj.Priority, obj.Attempts, obj.Progress, age)
+	if obj.Error != "" {
+		fmt.Printf(" | ERROR: %s", obj.Error)
+	}
+	if next := obj.ToOperation().NextRetry; !next.IsZero() {
+		fmt.Printf(" | Retry in: %s", time.Until(next).Truncate(time.Second))
+	}
+	fmt.Println()
+}
+
+func textFormatPrintPinsHealthReport(obj *api.PinsHealthReportSerial) {
+	fmt.Printf("Pinset health as seen by %s at %s:\n", obj.Peer, obj.TS)
+	fmt.Printf("  Total: %d\n", obj.Total)
+	for _, st := range []api.PinHealthState{
+		api.PinHealthOK,
+		api.PinHealthDegraded,
+		api.PinHealthUnderReplicated,
+		api.PinHealthLost,
+	} {
+		fmt.Printf("  %s: %d\n", strings.ToUpper(st.String()), obj.Summary[st.String()])
+	}
+	if len(obj.Worst) == 0 {
+		return
+	}
+
+	fmt.Println("Worst pins:")
+	for _, ph := range obj.Worst {
+		fmt.Printf("%s | %s | %s | Pinned: %d | Repl. Factor: %d--%d",
+			ph.Cid, ph.Name, strings.ToUpper(ph.State),
+			ph.Pinned, ph.ReplicationFactorMin, ph.ReplicationFactorMax)
+		if len(ph.Down) > 0 {
+			fmt.Printf(" | Down: %s", ph.Down)
+		}
+		if len(ph.Errored) > 0 {
+			fmt.Printf(" | Errored: %s", ph.Errored)
+		}
+		fmt.Println()
+	}
+}
+
+func textFormatPrintAlert(obj *api.AlertSerial) {
+	fmt.Printf("%s | %s | %s", obj.Peer, obj.MetricName, strings.ToUpper(obj.Policy))
+	if obj.Message != "" {
+		fmt.Printf(" | %s", obj.Message)
+	}
+	if obj.Value != "" {
+		fmt.Printf(" | Value: %s", obj.Value)
+	}
+	fmt.Printf(" | Last: %s | Expire: %s\n", obj.TS, obj.Expire)
+}
+
+func textFormatPrintMetricHistory(obj *api.MetricHistorySerial) {
+	fmt.Printf(
+		"%s | %s | Min: %g | Max: %g | Avg: %g | Rate: %g/s\n",
+		obj.Peer, obj.Name, obj.Min, obj.Max, obj.Avg, obj.Rate,
+	)
+	for _, s := range obj.Samples {
+		valid := ""
+		if !s.Valid {
+			valid = " (invalid)"
+		}
+		fmt.Printf("  > %s: %s%s\n", s.TS, s.Value, valid)
+	}
+}
+
+func textFormatPrintEvent(obj *api.EventSerial) {
+	fmt.Printf("%s | %s | %s", obj.TS, obj.Peer, obj.Type)
+	if obj.Cid != "" {
+		fmt.Printf(" | %s", obj.Cid)
+	}
+	if obj.Name != "" {
+		fmt.Printf(" | %s", obj.Name)
+	}
+	if len(obj.Allocations) > 0 {
+		fmt.Printf(" | Allocations: %s", strings.Join(obj.Allocations, ", "))
+	}
+	if obj.OldUID != "" {
+		fmt.Printf(" | %s -> %s", obj.OldUID, obj.UID)
+	} else if obj.UID != "" {
+		fmt.Printf(" | %s", obj.UID)
+	}
+	if obj.Target != "" {
+		fmt.Printf(" | %s", obj.Target)
+	}
+	if obj.Op != "" {
+		fmt.Printf(" | %s %s", obj.Op, obj.Path)
+	}
+	if obj.Message != "" {
+		fmt.Printf(" | %s", obj.Message)
+	}
+	fmt.Println()
+}
+
+func textFormatPrintDrainInfo(obj *api.DrainInfoSerial) {
+	if obj.Remaining == 0 {
+		fmt.Printf("%s | drained\n", obj.Peer)
+		return
+	}
+	fmt.Printf("%s | draining | Remaining pins: %d\n", obj.Peer, obj.Remaining)
+}
+
+func textFormatPrintRebalanceReport(obj *api.RebalanceReportSerial) {
+	fmt.Printf("Rebalance by %s at %s (metric: %s):\n", obj.Peer, obj.TS, obj.Metric)
+	peers := make([]string, 0, len(obj.Loads))
+	for p := range obj.Loads {
+		peers = append(peers, p)
+	}
+	sort.Strings(peers)
+	for _, p := range peers {
+		fmt.Printf("  %s: %s\n", p, obj.Loads[p])
+	}
+	if len(obj.Moves) == 0 {
+		fmt.Println("Nothing to move")
+		return
+	}
+
+	if obj.DryRun {
+		fmt.Println("Planned moves (dry run):")
+	} else {
+		fmt.Println("Moves:")
+	}
+	for _, m := range obj.Moves {
+		fmt.Printf("%s | %s | %s -> %s\n", m.Cid, m.Name, m.From, m.To)
+	}
+}
+
 func textFormatPrintError(obj *api.Error) {
 	fmt.Printf("An error occurred:\n")
 	fmt.Printf("  Code: %d\n", obj.Code)
@@ -7,7 +7,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -231,6 +233,63 @@ cluster peers.
 						return nil
 					},
 				},
+				{
+					Name:  "drain",
+					Usage: "move all pins away from a peer before maintenance",
+					Description: `
+This command marks a peer as draining in the shared state. A draining peer
+receives no new allocations and the cluster leader gradually moves the pins
+allocated to it to other peers, pinning every item in its new peer before
+removing it from the drained one.
+
+Use "peers draining" to follow the progress and "peers undrain" to let the
+peer receive allocations again.
+`,
+					ArgsUsage: "<peer ID>",
+					Flags:     []cli.Flag{},
+					Action: func(c *cli.Context) error {
+						pid := c.Args().First()
+						p, err := peer.IDB58Decode(pid)
+						checkErr("parsing peer ID", err)
+						cerr := globalClient.PeerDrain(p)
+						formatResponse(c, nil, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "undrain",
+					Usage: "let a drained peer receive new allocations again",
+					Description: `
+This command clears the draining mark of a peer. Pins which were already
+moved away from it stay where they are.
+`,
+					ArgsUsage: "<peer ID>",
+					Flags:     []cli.Flag{},
+					Action: func(c *cli.Context) error {
+						pid := c.Args().First()
+						p, err := peer.IDB58Decode(pid)
+						checkErr("parsing peer ID", err)
+						cerr := globalClient.PeerUndrain(p)
+						formatResponse(c, nil, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "draining",
+					Usage: "list draining peers and the pins still allocated to them",
+					Description: `
+This command lists the peers marked as draining along with the number of
+pins which remain allocated to each of them. A peer is fully drained, and can
+be taken down safely, when none remain.
+`,
+					Flags:     []cli.Flag{},
+					ArgsUsage: " ",
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.DrainStatus()
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
 			},
 		},
 		{
@@ -254,16 +313,15 @@ ipfs add, but the result is a fully replicated CID on completion.
 If you prefer faster adding, add directly to the local IPFS and trigger a
 cluster "pin add".
 
+Cluster Add supports handling huge files and sharding the resulting DAG among
+several ipfs daemons (--shard). In this case, a single ipfs daemon will not
+contain the full dag, but only parts of it (shards). Desired shard size can
+be provided with the --shard-size flag. Use "cat" to read a sharded file and
+"pin rm" on its root to remove it along with all its shards.
+
+We recommend setting a --name for sharded pins. Otherwise, it will be
+automatically generated.
 `,
-			/*
-				Cluster Add supports handling huge files and sharding the resulting DAG among
-				several ipfs daemons (--shard). In this case, a single ipfs daemon will not
-				contain the full dag, but only parts of it (shards). Desired shard size can
-				be provided with the --shard-size flag.
-
-				We recommend setting a --name for sharded pins. Otherwise, it will be
-				automatically generated.
-			*/
 			Flags: []cli.Flag{
 				cli.BoolFlag{
 					Name:  "recursive, r",
@@ -328,22 +386,40 @@ cluster "pin add".
 					Value: defaultAddParams.ReplicationFactorMax,
 					Usage: "Sets the maximum replication factor for pinning this file",
 				},
-				// TODO: Uncomment when sharding is supported.
-				// cli.BoolFlag{
-				//	Name:  "shard",
-				//	Usage: "Break the file into pieces (shards) and distributed among peers",
-				// },
-				// cli.Uint64Flag{
-				//	Name:  "shard-size",
-				//	Value: defaultAddParams.ShardSize,
-				//	Usage: "Sets the maximum replication factor for pinning this file",
-				// },
-				// TODO: Figure progress over total bar.
-				// cli.BoolFlag{
-				//	Name:  "progress, p",
-				//	Usage: "Stream progress data",
-				// },
-
+				cli.StringFlag{
+					Name:  "priority",
+					Value: "normal",
+					Usage: "Sets the priority for pinning this file: high, normal or bulk",
+				},
+				cli.StringFlag{
+					Name:  "spread-tag",
+					Usage: "Spread the allocations across the values of this peer tag (i.e. region)",
+				},
+				cli.StringSliceFlag{
+					Name:  "require-tag",
+					Usage: "Only allocate to peers with this tag (key:value). Can be repeated",
+				},
+				cli.StringSliceFlag{
+					Name:  "exclude-tag",
+					Usage: "Do not allocate to peers with this tag (key:value). Can be repeated",
+				},
+				cli.BoolFlag{
+					Name:  "public",
+					Usage: "Serve the added content through the gateway, under /ipfs/<cid>",
+				},
+				cli.BoolFlag{
+					Name:  "shard",
+					Usage: "Break the file into pieces (shards) and distributed among peers",
+				},
+				cli.Uint64Flag{
+					Name:  "shard-size",
+					Value: defaultAddParams.ShardSize,
+					Usage: "Sets the maximum size of every shard, in bytes",
+				},
+				cli.BoolFlag{
+					Name:  "progress, p",
+					Usage: "Print the number of bytes added so far",
+				},
 			},
 			Action: func(c *cli.Context) error {
 				shard := c.Bool("shard")
@@ -372,9 +448,14 @@ cluster "pin add".
 				p.ReplicationFactorMin = c.Int("replication-min")
 				p.ReplicationFactorMax = c.Int("replication-max")
 				p.Name = name
-				//p.Shard = shard
-				//p.ShardSize = c.Uint64("shard-size")
-				p.Shard = false
+				priority, err := api.PinPriorityFromString(c.String("priority"))
+				checkErr("parsing priority", err)
+				p.Priority = priority
+				checkErr("parsing tags", parseTagFlags(c, &p.PinOptions))
+				p.Public = c.Bool("public")
+				p.Shard = shard
+				p.ShardSize = c.Uint64("shard-size")
+				p.Progress = c.Bool("progress")
 				p.Recursive = c.Bool("recursive")
 				p.Layout = c.String("layout")
 				p.Chunker = c.String("chunker")
@@ -402,6 +483,12 @@ cluster "pin add".
 					var q = c.Bool("quiet") || qq
 					var bufferResults = c.Bool("no-stream")
 					for v := range out {
+						if v.Cid == "" { // progress update
+							if !q {
+								fmt.Fprintf(os.Stderr, "adding %s: %d bytes\n", v.Name, v.Bytes)
+							}
+							continue
+						}
 						added := addedOutputQuiet{v, q}
 						lastBuf[0] = added
 						if bufferResults {
@@ -434,6 +521,26 @@ cluster "pin add".
 				return cerr
 			},
 		},
+		{
+			Name:  "cat",
+			Usage: "Print the contents of a file added to the cluster",
+			Description: `
+This command writes the contents of a file added to the cluster to the
+standard output. Files added with --shard are rebuilt from the ipfs daemons
+holding each of their shards, so no single daemon needs to hold all of them.
+`,
+			ArgsUsage: "<CID>",
+			Action: func(c *cli.Context) error {
+				ci, err := cid.Decode(c.Args().First())
+				checkErr("parsing cid", err)
+				r, err := globalClient.Cat(context.Background(), ci)
+				checkErr("reading file", err)
+				defer r.Close()
+				_, err = io.Copy(os.Stdout, r)
+				checkErr("reading file", err)
+				return nil
+			},
+		},
 		{
 			Name:        "pin",
 			Usage:       "Pin and unpin and list items in IPFS Cluster",
@@ -453,6 +560,18 @@ in the cluster and should be part of the list offered by "pin ls".
 An optional replication factor can be provided: -1 means "pin everywhere"
 and 0 means use cluster's default setting. Positive values indicate how many
 peers should pin this content.
+
+The --priority flag sets the priority class of the pin: "high" pins are
+processed by the peers before "normal" ones, and these before "bulk" ones.
+Failed pins are retried automatically a number of times.
+
+When the peers publish tags (tags informer and "tags" allocator), the
+--spread-tag flag spreads the allocations across the distinct values of
+a tag, so that at least replication-min values are used. The
+--require-tag and --exclude-tag flags (key:value) restrict which peers
+can be allocated the pin.
+
+The gateway only serves /ipfs/<cid> for pins added with --public.
 `,
 					ArgsUsage: "<CID>",
 					Flags: []cli.Flag{
@@ -476,6 +595,27 @@ peers should pin this content.
 							Value: "",
 							Usage: "Sets a name for this pin",
 						},
+						cli.StringFlag{
+							Name:  "priority, p",
+							Value: "normal",
+							Usage: "Sets the priority of this pin: high, normal or bulk",
+						},
+						cli.StringFlag{
+							Name:  "spread-tag",
+							Usage: "Spread the allocations across the values of this peer tag (i.e. region)",
+						},
+						cli.StringSliceFlag{
+							Name:  "require-tag",
+							Usage: "Only allocate to peers with this tag (key:value). Can be repeated",
+						},
+						cli.StringSliceFlag{
+							Name:  "exclude-tag",
+							Usage: "Do not allocate to peers with this tag (key:value). Can be repeated",
+						},
+						cli.BoolFlag{
+							Name:  "public",
+							Usage: "Serve this pin through the gateway, under /ipfs/<cid>",
+						},
 						cli.BoolFlag{
 							Name:  "no-status, ns",
 							Usage: "Prevents fetching pin status after pinning (faster, quieter)",
@@ -503,7 +643,19 @@ peers should pin this content.
 							rplMax = rpl
 						}
 
-						cerr := globalClient.Pin(ci, rplMin, rplMax, c.String("name"))
+						priority, err := api.PinPriorityFromString(c.String("priority"))
+						checkErr("parsing priority", err)
+
+						opts := api.PinOptions{
+							ReplicationFactorMin: rplMin,
+							ReplicationFactorMax: rplMax,
+							Name:                 c.String("name"),
+							Priority:             priority,
+							Public:               c.Bool("public"),
+						}
+						checkErr("parsing tags", parseTagFlags(c, &opts))
+
+						cerr := globalClient.PinWithOptions(ci, opts)
 						if cerr != nil {
 							formatResponse(c, nil, cerr)
 							return nil
@@ -621,6 +773,9 @@ item.  Metadata CIDs are included in the status response
 The status of a CID may not be accurate. A manual sync can be triggered
 with "sync".
 
+CIDs which are being pinned show the number of blocks fetched so far.
+Pins which make no progress for a while are cancelled and retried.
+
 When the --local flag is passed, it will only fetch the status from the
 contacted cluster peer. By default, status will be fetched from all peers.
 
@@ -628,7 +783,13 @@ When the --filter flag is passed, it will only fetch the peer information
 where status of the pin matches at least one of the filter values (a comma
 separated list). The following are valid status values:
 
-` + trackerStatusAllString(),
+` + trackerStatusAllString() + `
+
+When listing all items, the results are fetched in pages and printed as they
+arrive. Note that this does not produce a JSON array with --enc=json, but one
+JSON object per line. The --no-stream flag fetches and prints all the results
+at once instead.
+`,
 			ArgsUsage: "[CID]",
 			Flags: []cli.Flag{
 				localFlag(),
@@ -636,6 +797,10 @@ separated list). The following are valid status values:
 					Name:  "filter",
 					Usage: "comma-separated list of filters",
 				},
+				cli.BoolFlag{
+					Name:  "no-stream",
+					Usage: "Print all results at once rather than as they arrive",
+				},
 			},
 			Action: func(c *cli.Context) error {
 				cidStr := c.Args().First()
@@ -650,12 +815,106 @@ separated list). The following are valid status values:
 					if filter == api.TrackerStatusUndefined && filterFlag != "" {
 						checkErr("parsing filter flag", errors.New("invalid filter name"))
 					}
-					resp, cerr := globalClient.StatusAll(filter, c.Bool("local"))
-					formatResponse(c, resp, cerr)
+					if c.Bool("no-stream") {
+						resp, cerr := globalClient.StatusAll(filter, c.Bool("local"))
+						formatResponse(c, resp, cerr)
+						return nil
+					}
+
+					out := make(chan api.GlobalPinInfo, 1)
+					var wg sync.WaitGroup
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						for gpi := range out {
+							formatResponse(c, gpi, nil)
+						}
+					}()
+
+					cerr := globalClient.StatusAllStream(filter, c.Bool("local"), out)
+					wg.Wait()
+					formatResponse(c, nil, cerr)
 				}
 				return nil
 			},
 		},
+		{
+			Name:        "queue",
+			Usage:       "List and manage pending pin operations",
+			Description: "List and manage pending pin operations",
+			Subcommands: []cli.Command{
+				{
+					Name:  "ls",
+					Usage: "List queued, in-progress and failed operations",
+					Description: `
+This command lists the pin and unpin operations which are waiting in the
+queues of the Cluster peers, are in progress, or have failed. For every
+operation it shows the peer, the type, the phase, the priority, the number
+of attempts, how long ago it was created and the last error, if any.
+
+When the --local flag is passed, it will only list the operations of the
+contacted cluster peer. By default, operations are fetched from all peers.
+`,
+					ArgsUsage: " ",
+					Flags: []cli.Flag{
+						localFlag(),
+					},
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.Operations(c.Bool("local"))
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "cancel",
+					Usage: "Cancel a queued or in-progress operation",
+					Description: `
+This command cancels the queued or in-progress pin or unpin operation for
+a CID. Cancelled operations are left in error state and can be retried
+with "recover".
+
+When the --local flag is passed, the operation is only cancelled in the
+contacted cluster peer. By default, it is cancelled everywhere.
+`,
+					ArgsUsage: "<CID>",
+					Flags: []cli.Flag{
+						localFlag(),
+					},
+					Action: func(c *cli.Context) error {
+						ci, err := cid.Decode(c.Args().First())
+						checkErr("parsing cid", err)
+						cerr := globalClient.CancelOperation(ci, c.Bool("local"))
+						formatResponse(c, nil, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "priority",
+					Usage: "Change the priority of a queued operation",
+					Description: `
+This command changes the priority of the queued operation for a CID.
+Operations with higher priority are processed first. Operations with
+the same priority are processed in the order they were queued.
+
+When the --local flag is passed, the priority is only changed in the
+contacted cluster peer. By default, it is changed everywhere.
+`,
+					ArgsUsage: "<CID> <priority>",
+					Flags: []cli.Flag{
+						localFlag(),
+					},
+					Action: func(c *cli.Context) error {
+						ci, err := cid.Decode(c.Args().Get(0))
+						checkErr("parsing cid", err)
+						priority, err := strconv.Atoi(c.Args().Get(1))
+						checkErr("parsing priority", err)
+						cerr := globalClient.SetOperationPriority(ci, priority, c.Bool("local"))
+						formatResponse(c, nil, cerr)
+						return nil
+					},
+				},
+			},
+		},
 		{
 			Name:  "sync",
 			Usage: "Sync status of tracked items",
@@ -740,6 +999,91 @@ to check that it matches the CLI version (shown by -v).
 				return nil
 			},
 		},
+		{
+			Name:  "rebalance",
+			Usage: "Move allocations from the most to the least loaded peers",
+			Description: `
+This command asks the cluster leader to compare the load of the cluster peers,
+according to the "rebalance_metric" in the configuration, and move the
+allocations of some pins from the most loaded peer to the least loaded one.
+
+Every item is pinned in its new peer before it is removed from the old one.
+The command returns the planned moves, which happen in the background.
+With --dry-run, the moves are only displayed.
+`,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "only display the planned moves",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				resp, cerr := globalClient.Rebalance(c.Bool("dry-run"))
+				formatResponse(c, resp, cerr)
+				return nil
+			},
+		},
+		{
+			Name:  "events",
+			Usage: "Show the events produced by a cluster peer",
+			Description: `
+This command shows the events produced by the contacted cluster peer: pin and
+unpin operations being queued, started, finished or failed, pins being
+re-allocated, peers joining or leaving, monitor alerts, and UID and file
+operations.
+
+By default, the most recent events are listed. With --follow, events are
+displayed as they happen until the command is interrupted.
+
+Events can be filtered by --type (comma-separated list), --cid and --uid.
+`,
+			ArgsUsage: " ",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "follow, f",
+					Usage: "keep displaying events as they happen",
+				},
+				cli.StringFlag{
+					Name:  "type",
+					Usage: "comma-separated list of event types to display",
+				},
+				cli.StringFlag{
+					Name:  "cid",
+					Usage: "only display events for the given CID",
+				},
+				cli.StringFlag{
+					Name:  "uid",
+					Usage: "only display events for the given UID",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				filter := api.EventFilter{
+					UID: c.String("uid"),
+				}
+				if t := c.String("type"); t != "" {
+					for _, et := range strings.Split(t, ",") {
+						filter.Types = append(filter.Types, api.EventType(et))
+					}
+				}
+				if cidStr := c.String("cid"); cidStr != "" {
+					ci, err := cid.Decode(cidStr)
+					checkErr("parsing cid", err)
+					filter.Cid = ci
+				}
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				events, cerr := globalClient.Events(ctx, filter, c.Bool("follow"))
+				if cerr != nil {
+					formatResponse(c, nil, cerr)
+					return nil
+				}
+				for ev := range events {
+					formatResponse(c, ev, nil)
+				}
+				return nil
+			},
+		},
 		{
 			Name:        "health",
 			Usage:       "Cluster monitoring information",
@@ -785,6 +1129,56 @@ graph of the connections.  Output is a dot file encoding the cluster's connectio
 						return nil
 					},
 				},
+				{
+					Name:  "pins",
+					Usage: "Summarize the replication health of the pinset",
+					Description: `
+This command asks the cluster leader for a report on the replication health
+of all the pins. Pins are classified as:
+
+- ok: every allocation is pinned on a live peer
+- degraded: replication_factor_min is met but some allocations are down,
+  in error or not pinned yet
+- under_replicated: fewer than replication_factor_min peers have the item
+  pinned
+- lost: no live peer has the item pinned
+
+The summary is followed by the worst offenders, up to --limit of them.
+`,
+					Flags: []cli.Flag{
+						cli.IntFlag{
+							Name:  "limit, l",
+							Value: 10,
+							Usage: "maximum number of unhealthy pins to list (0 for all)",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.PinsHealth(c.Int("limit"))
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "alerts",
+					Usage: "List the active alerts recorded by this peer",
+					Description: `
+This command lists the alerts which are active in this peer, along with the
+policy applied to each of them. Alerts are triggered when:
+
+- the metrics of a peer stop arriving (ping)
+- an informer metric crosses one of the "alert_thresholds" in the
+  configuration (i.e. freespace below some bytes)
+- a peer cannot reach its IPFS daemon (ipfs_down)
+
+Policies are "notify", "stop_allocating" (the peer receives no new
+allocations) and "move_pins" (its pins are also re-allocated elsewhere).
+`,
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.Alerts()
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
 				{
 					Name:  "metrics",
 					Usage: "List latest metrics logged by this peer",
@@ -792,6 +1186,10 @@ graph of the connections.  Output is a dot file encoding the cluster's connectio
 This commands displays the latest valid metrics of the given type logged
 by this peer for all current cluster peers.
 
+With --history, it displays instead the recent metrics kept by this peer
+for every peer, with their timestamps, along with their minimum, maximum,
+average and rate of change. Use --peer to show a single peer.
+
 Currently supported metrics depend on the informer component used,
 but usually are:
 
@@ -799,13 +1197,35 @@ but usually are:
 - ping
 `,
 					ArgsUsage: "<metric name>",
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "history",
+							Usage: "show the recent metrics of each peer with min, max, average and rate",
+						},
+						cli.StringFlag{
+							Name:  "peer",
+							Usage: "only show the history of the given peer",
+						},
+					},
 					Action: func(c *cli.Context) error {
 						metric := c.Args().First()
 						if metric == "" {
 							checkErr("", errors.New("provide a metric name"))
 						}
 
-						resp, cerr := globalClient.Metrics(metric)
+						if !c.Bool("history") {
+							resp, cerr := globalClient.Metrics(metric)
+							formatResponse(c, resp, cerr)
+							return nil
+						}
+
+						var pid peer.ID
+						if p := c.String("peer"); p != "" {
+							var err error
+							pid, err = peer.IDB58Decode(p)
+							checkErr("parsing peer ID", err)
+						}
+						resp, cerr := globalClient.MetricsHistory(metric, pid)
 						formatResponse(c, resp, cerr)
 						return nil
 					},
@@ -835,6 +1255,17 @@ func parseFlag(t int) cli.IntFlag {
 	}
 }
 
+// parseTagFlags sets the tag options from the --spread-tag,
+// --require-tag and --exclude-tag flags.
+func parseTagFlags(c *cli.Context, opts *api.PinOptions) error {
+	query := url.Values{
+		"require-tag": c.StringSlice("require-tag"),
+		"exclude-tag": c.StringSlice("exclude-tag"),
+	}
+	query.Set("spread-tag", c.String("spread-tag"))
+	return api.PinOptionsTagsFromQuery(query, opts)
+}
+
 func localFlag() cli.BoolFlag {
 	return cli.BoolFlag{
 		Name:  "local",
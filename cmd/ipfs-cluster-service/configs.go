@@ -5,15 +5,23 @@ import (
 	"path/filepath"
 
 	ipfscluster "github.com/elastos/Elastos.NET.Hive.Cluster"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/allocator/tagalloc"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/allocator/weightedalloc"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api/gateway"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/api/ipfsproxy"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/api/rest"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api/webhook"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/config"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/consensus/raft"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/informer/disk"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/informer/numpin"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/informer/tags"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/informer/tenants"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/ipfsconn/ipfshttp"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/ipfsconn/ipfspool"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/monitor/basic"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/monitor/pubsubmon"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/observations"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/pintracker/maptracker"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/pintracker/stateless"
 )
@@ -22,7 +30,11 @@ type cfgs struct {
 	clusterCfg          *ipfscluster.Config
 	apiCfg              *rest.Config
 	ipfsproxyCfg        *ipfsproxy.Config
+	webhookCfg          *webhook.Config
+	metricsCfg          *observations.Config
+	gatewayCfg          *gateway.Config
 	ipfshttpCfg         *ipfshttp.Config
+	ipfspoolCfg         *ipfspool.Config
 	consensusCfg        *raft.Config
 	maptrackerCfg       *maptracker.Config
 	statelessTrackerCfg *stateless.Config
@@ -30,6 +42,10 @@ type cfgs struct {
 	pubsubmonCfg        *pubsubmon.Config
 	diskInfCfg          *disk.Config
 	numpinInfCfg        *numpin.Config
+	tagsInfCfg          *tags.Config
+	tenantsInfCfg       *tenants.Config
+	weightedAllocCfg    *weightedalloc.Config
+	tagAllocCfg         *tagalloc.Config
 }
 
 func makeConfigs() (*config.Manager, *cfgs) {
@@ -37,7 +53,11 @@ func makeConfigs() (*config.Manager, *cfgs) {
 	clusterCfg := &ipfscluster.Config{}
 	apiCfg := &rest.Config{}
 	ipfsproxyCfg := &ipfsproxy.Config{}
+	webhookCfg := &webhook.Config{}
+	metricsCfg := &observations.Config{}
+	gatewayCfg := &gateway.Config{}
 	ipfshttpCfg := &ipfshttp.Config{}
+	ipfspoolCfg := &ipfspool.Config{}
 	consensusCfg := &raft.Config{}
 	maptrackerCfg := &maptracker.Config{}
 	statelessCfg := &stateless.Config{}
@@ -45,10 +65,18 @@ func makeConfigs() (*config.Manager, *cfgs) {
 	pubsubmonCfg := &pubsubmon.Config{}
 	diskInfCfg := &disk.Config{}
 	numpinInfCfg := &numpin.Config{}
+	tagsInfCfg := &tags.Config{}
+	tenantsInfCfg := &tenants.Config{}
+	weightedAllocCfg := &weightedalloc.Config{}
+	tagAllocCfg := &tagalloc.Config{}
 	cfg.RegisterComponent(config.Cluster, clusterCfg)
 	cfg.RegisterComponent(config.API, apiCfg)
 	cfg.RegisterComponent(config.API, ipfsproxyCfg)
+	cfg.RegisterComponent(config.API, webhookCfg)
+	cfg.RegisterComponent(config.API, metricsCfg)
+	cfg.RegisterComponent(config.API, gatewayCfg)
 	cfg.RegisterComponent(config.IPFSConn, ipfshttpCfg)
+	cfg.RegisterComponent(config.IPFSConn, ipfspoolCfg)
 	cfg.RegisterComponent(config.Consensus, consensusCfg)
 	cfg.RegisterComponent(config.PinTracker, maptrackerCfg)
 	cfg.RegisterComponent(config.PinTracker, statelessCfg)
@@ -56,11 +84,19 @@ func makeConfigs() (*config.Manager, *cfgs) {
 	cfg.RegisterComponent(config.Monitor, pubsubmonCfg)
 	cfg.RegisterComponent(config.Informer, diskInfCfg)
 	cfg.RegisterComponent(config.Informer, numpinInfCfg)
+	cfg.RegisterComponent(config.Informer, tagsInfCfg)
+	cfg.RegisterComponent(config.Informer, tenantsInfCfg)
+	cfg.RegisterComponent(config.Allocator, weightedAllocCfg)
+	cfg.RegisterComponent(config.Allocator, tagAllocCfg)
 	return cfg, &cfgs{
 		clusterCfg,
 		apiCfg,
 		ipfsproxyCfg,
+		webhookCfg,
+		metricsCfg,
+		gatewayCfg,
 		ipfshttpCfg,
+		ipfspoolCfg,
 		consensusCfg,
 		maptrackerCfg,
 		statelessCfg,
@@ -68,6 +104,10 @@ func makeConfigs() (*config.Manager, *cfgs) {
 		pubsubmonCfg,
 		diskInfCfg,
 		numpinInfCfg,
+		tagsInfCfg,
+		tenantsInfCfg,
+		weightedAllocCfg,
+		tagAllocCfg,
 	}
 }
 
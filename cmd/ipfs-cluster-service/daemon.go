@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
@@ -14,14 +15,22 @@ import (
 	ipfscluster "github.com/elastos/Elastos.NET.Hive.Cluster"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/allocator/ascendalloc"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/allocator/descendalloc"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/allocator/tagalloc"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/allocator/weightedalloc"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api/gateway"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/api/ipfsproxy"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/api/rest"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api/webhook"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/consensus/raft"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/informer/disk"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/informer/numpin"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/informer/tags"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/informer/tenants"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/ipfsconn/ipfshttp"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/ipfsconn/ipfspool"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/monitor/basic"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/monitor/pubsubmon"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/observations"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/pintracker/maptracker"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/pintracker/stateless"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/pstoremgr"
@@ -114,9 +123,18 @@ func createCluster(
 	proxy, err := ipfsproxy.New(cfgs.ipfsproxyCfg)
 	checkErr("creating IPFS Proxy component", err)
 
-	apis := []ipfscluster.API{api, proxy}
+	hooks, err := webhook.New(cfgs.webhookCfg)
+	checkErr("creating webhook component", err)
 
-	connector, err := ipfshttp.NewConnector(cfgs.ipfshttpCfg)
+	metrics, err := observations.New(cfgs.metricsCfg)
+	checkErr("creating metrics component", err)
+
+	gw, err := gateway.New(cfgs.gatewayCfg)
+	checkErr("creating gateway component", err)
+
+	apis := []ipfscluster.API{api, proxy, hooks, metrics, gw}
+
+	connector, err := setupIPFSConnector(cfgs)
 	checkErr("creating IPFS Connector component", err)
 
 	state := mapstate.NewMapState()
@@ -134,7 +152,30 @@ func createCluster(
 
 	tracker := setupPinTracker(c.String("pintracker"), host, cfgs.maptrackerCfg, cfgs.statelessTrackerCfg, cfgs.clusterCfg.Peername)
 	mon := setupMonitor(c.String("monitor"), host, cfgs.monCfg, cfgs.pubsubmonCfg)
-	informer, alloc := setupAllocation(c.String("alloc"), cfgs.diskInfCfg, cfgs.numpinInfCfg)
+	informers, alloc := setupAllocation(c.String("alloc"), cfgs)
+
+	// The placement of new user homes relies on its own metric. Like
+	// any informer not used by the allocator, it is not needed for a
+	// peer to be allocated pins.
+	homeMetric := cfgs.clusterCfg.HomePlacementMetric
+	if cfgs.clusterCfg.HomeReplication > 1 && !hasInformer(informers, homeMetric) {
+		informers = append(informers, setupInformer(homeMetric, cfgs))
+	}
+
+	// Alert thresholds are checked on informer metrics. These are not
+	// needed to allocate either: a peer missing them just raises no
+	// threshold alerts.
+	for _, th := range cfgs.clusterCfg.AlertThresholds {
+		if !hasInformer(informers, th.Metric) {
+			informers = append(informers, setupInformer(th.Metric, cfgs))
+		}
+	}
+
+	// So is automatic rebalancing.
+	rebalanceMetric := cfgs.clusterCfg.RebalanceMetric
+	if cfgs.clusterCfg.RebalanceInterval > 0 && !hasInformer(informers, rebalanceMetric) {
+		informers = append(informers, setupInformer(rebalanceMetric, cfgs))
+	}
 
 	ipfscluster.ReadyTimeout = cfgs.consensusCfg.WaitForLeaderTimeout + 5*time.Second
 
@@ -148,7 +189,7 @@ func createCluster(
 		tracker,
 		mon,
 		alloc,
-		informer,
+		informers...,
 	)
 }
 
@@ -210,24 +251,36 @@ Note that this may corrupt the local cluster state.
 	}
 }
 
-func setupAllocation(
-	name string,
-	diskInfCfg *disk.Config,
-	numpinInfCfg *numpin.Config,
-) (ipfscluster.Informer, ipfscluster.PinAllocator) {
+func setupAllocation(name string, cfgs *cfgs) ([]ipfscluster.Informer, ipfscluster.PinAllocator) {
 	switch name {
 	case "disk", "disk-freespace":
-		informer, err := disk.NewInformer(diskInfCfg)
+		informer, err := disk.NewInformer(cfgs.diskInfCfg)
 		checkErr("creating informer", err)
-		return informer, descendalloc.NewAllocator()
+		return []ipfscluster.Informer{informer}, descendalloc.NewAllocator(informer.Name())
 	case "disk-reposize":
-		informer, err := disk.NewInformer(diskInfCfg)
+		informer, err := disk.NewInformer(cfgs.diskInfCfg)
 		checkErr("creating informer", err)
-		return informer, ascendalloc.NewAllocator()
+		return []ipfscluster.Informer{informer}, ascendalloc.NewAllocator(informer.Name())
 	case "numpin", "pincount":
-		informer, err := numpin.NewInformer(numpinInfCfg)
+		informer, err := numpin.NewInformer(cfgs.numpinInfCfg)
 		checkErr("creating informer", err)
-		return informer, ascendalloc.NewAllocator()
+		return []ipfscluster.Informer{informer}, ascendalloc.NewAllocator(informer.Name())
+	case "weighted":
+		var informers []ipfscluster.Informer
+		for _, metric := range cfgs.weightedAllocCfg.MetricNames() {
+			informers = append(informers, setupInformer(metric, cfgs))
+		}
+		alloc, err := weightedalloc.New(cfgs.weightedAllocCfg)
+		checkErr("creating allocator", err)
+		return informers, alloc
+	case "tags":
+		informers := []ipfscluster.Informer{
+			setupInformer(tags.MetricName, cfgs),
+			setupInformer(cfgs.tagAllocCfg.SortMetric, cfgs),
+		}
+		alloc, err := tagalloc.New(cfgs.tagAllocCfg)
+		checkErr("creating allocator", err)
+		return informers, alloc
 	default:
 		err := errors.New("unknown allocation strategy")
 		checkErr("", err)
@@ -235,6 +288,73 @@ func setupAllocation(
 	}
 }
 
+// setupInformer returns an informer producing the metric of the given name.
+func setupInformer(metric string, cfgs *cfgs) ipfscluster.Informer {
+	switch metric {
+	case "freespace", "reposize", "repousage":
+		// The weighted allocator may need several disk metrics, so
+		// the metric type is not taken from the disk configuration.
+		cfg := &disk.Config{
+			MetricTTL: cfgs.diskInfCfg.MetricTTL,
+			Type:      disk.MetricFreeSpace,
+		}
+		switch metric {
+		case "reposize":
+			cfg.Type = disk.MetricRepoSize
+		case "repousage":
+			cfg.Type = disk.MetricRepoUsage
+		}
+		informer, err := disk.NewInformer(cfg)
+		checkErr("creating informer", err)
+		return informer
+	case "homes", "homessize":
+		// Same as for the disk metrics.
+		cfg := &tenants.Config{
+			MetricTTL: cfgs.tenantsInfCfg.MetricTTL,
+			Type:      tenants.MetricHomes,
+		}
+		if metric == "homessize" {
+			cfg.Type = tenants.MetricHomesSize
+		}
+		informer, err := tenants.NewInformer(cfg)
+		checkErr("creating informer", err)
+		return informer
+	case numpin.MetricName:
+		informer, err := numpin.NewInformer(cfgs.numpinInfCfg)
+		checkErr("creating informer", err)
+		return informer
+	case tags.MetricName:
+		informer, err := tags.NewInformer(cfgs.tagsInfCfg)
+		checkErr("creating informer", err)
+		return informer
+	default:
+		checkErr("", fmt.Errorf("no informer provides the %s metric", metric))
+		return nil
+	}
+}
+
+// hasInformer returns true when one of the informers produces the metric
+// of the given name.
+func hasInformer(informers []ipfscluster.Informer, metric string) bool {
+	for _, informer := range informers {
+		if informer.Name() == metric {
+			return true
+		}
+	}
+	return false
+}
+
+// setupIPFSConnector returns a pooled connector when several IPFS daemons
+// are configured in the "ipfspool" section, and a single daemon connector
+// otherwise.
+func setupIPFSConnector(cfgs *cfgs) (ipfscluster.IPFSConnector, error) {
+	if cfgs.ipfspoolCfg.Enabled() {
+		logger.Debugf("IPFS pool loaded with %d daemons", len(cfgs.ipfspoolCfg.NodeAddrs))
+		return ipfspool.NewConnector(cfgs.ipfshttpCfg, cfgs.ipfspoolCfg)
+	}
+	return ipfshttp.NewConnector(cfgs.ipfshttpCfg)
+}
+
 func setupMonitor(
 	name string,
 	h host.Host,
@@ -275,7 +275,7 @@ configuration.
 				cli.StringFlag{
 					Name:  "alloc, a",
 					Value: defaultAllocation,
-					Usage: "allocation strategy to use [disk-freespace,disk-reposize,numpin].",
+					Usage: "allocation strategy to use [disk-freespace,disk-reposize,numpin,weighted,tags].",
 				},
 				cli.StringFlag{
 					Name:   "monitor",
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/observations"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/state"
 
 	logging "github.com/ipfs/go-log"
@@ -24,6 +25,10 @@ import (
 
 var logger = logging.Logger("consensus")
 
+// LeaderWatchInterval specifies how often the Raft leader is checked
+// to report leader changes in the metrics.
+var LeaderWatchInterval = time.Second
+
 // Consensus handles the work of keeping a shared-state between
 // the peers of an IPFS Cluster, as well as modifying that state and
 // applying any updates in a thread-safe manner.
@@ -158,9 +163,39 @@ func (cc *Consensus) finishBootstrap() {
 	}
 	logger.Debug("Raft state is now up to date")
 	logger.Debug("consensus ready")
+	go cc.watchLeader()
 	cc.readyCh <- struct{}{}
 }
 
+// watchLeader keeps track of the changes of Raft leader for
+// the metrics.
+func (cc *Consensus) watchLeader() {
+	ticker := time.NewTicker(LeaderWatchInterval)
+	defer ticker.Stop()
+
+	var last string
+	for {
+		select {
+		case <-cc.ctx.Done():
+			return
+		case <-ticker.C:
+			leader := cc.raft.Leader()
+			if leader == "" || leader == last {
+				continue
+			}
+			if last != "" {
+				observations.ConsensusLeaderChanges.Inc()
+			}
+			last = leader
+			isLeader := 0.0
+			if leader == peer.IDB58Encode(cc.host.ID()) {
+				isLeader = 1
+			}
+			observations.ConsensusIsLeader.Set(isLeader)
+		}
+	}
+}
+
 // Shutdown stops the component so it will not process any
 // more updates. The underlying consensus is permanently
 // shutdown, along with the libp2p transport.
@@ -290,7 +325,10 @@ func (cc *Consensus) commit(op *LogOp, rpcOp string, redirectArg interface{}) er
 
 		// now commit the changes to our state
 		cc.shutdownLock.RLock() // do not shut down while committing
+		prev, hadPrev := cc.previousPin(op)
+		start := time.Now()
 		_, finalErr = cc.consensus.CommitOp(op)
+		observations.ConsensusCommitLatency.Observe(time.Since(start).Seconds())
 		cc.shutdownLock.RUnlock()
 		if finalErr != nil {
 			goto RETRY
@@ -299,6 +337,9 @@ func (cc *Consensus) commit(op *LogOp, rpcOp string, redirectArg interface{}) er
 		switch op.Type {
 		case LogOpPin:
 			logger.Infof("pin committed to global state: %s", op.Cid.Cid)
+			if hadPrev {
+				cc.publishReallocation(prev, op.Cid.ToPin())
+			}
 		case LogOpUnpin:
 			logger.Infof("unpin committed to global state: %s", op.Cid.Cid)
 		}
@@ -331,6 +372,25 @@ func (cc *Consensus) LogUnpin(pin api.Pin) error {
 	return nil
 }
 
+// LogDrain marks a peer as draining in the shared state of the cluster.
+func (cc *Consensus) LogDrain(pid peer.ID) error {
+	op := &LogOp{
+		Peer: peer.IDB58Encode(pid),
+		Type: LogOpDrain,
+	}
+	return cc.commit(op, "ConsensusLogDrain", pid)
+}
+
+// LogUndrain clears the draining mark of a peer in the shared state of
+// the cluster.
+func (cc *Consensus) LogUndrain(pid peer.ID) error {
+	op := &LogOp{
+		Peer: peer.IDB58Encode(pid),
+		Type: LogOpUndrain,
+	}
+	return cc.commit(op, "ConsensusLogUndrain", pid)
+}
+
 // AddPeer adds a new peer to participate in this consensus. It will
 // forward the operation to the leader if this is not it.
 func (cc *Consensus) AddPeer(pid peer.ID) error {
@@ -385,6 +445,63 @@ func (cc *Consensus) RmPeer(pid peer.ID) error {
 	return finalErr
 }
 
+// previousPin returns the pin currently in the state for the Cid
+// of a pin operation, before it is committed.
+func (cc *Consensus) previousPin(op *LogOp) (api.Pin, bool) {
+	if op.Type != LogOpPin {
+		return api.Pin{}, false
+	}
+	st, err := cc.State()
+	if err != nil {
+		return api.Pin{}, false
+	}
+	return st.Get(op.Cid.ToPin().Cid)
+}
+
+// publishReallocation emits a pin_reallocated event when a committed
+// pin changes the allocations of an existing one. Only the peer
+// committing the operation (the leader) emits it, so that it is not
+// repeated by every peer applying the log.
+func (cc *Consensus) publishReallocation(prev, pin api.Pin) {
+	if cc.rpcClient == nil || len(pin.Allocations) == 0 {
+		return
+	}
+	if peersEqual(prev.Allocations, pin.Allocations) {
+		return
+	}
+
+	ev := api.Event{
+		Type:        api.EventPinReallocated,
+		Cid:         pin.Cid,
+		Name:        pin.Name,
+		Allocations: pin.Allocations,
+	}
+	cc.rpcClient.Go(
+		"",
+		"Cluster",
+		"PublishEvent",
+		ev.ToSerial(),
+		&struct{}{},
+		nil,
+	)
+}
+
+func peersEqual(a, b []peer.ID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[peer.ID]struct{}, len(a))
+	for _, p := range a {
+		set[p] = struct{}{}
+	}
+	for _, p := range b {
+		if _, ok := set[p]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
 // State retrieves the current consensus State. It may error
 // if no State has been agreed upon or the state is not
 // consistent. The returned State is the last agreed-upon
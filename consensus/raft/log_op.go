@@ -7,12 +7,15 @@ import (
 	"github.com/elastos/Elastos.NET.Hive.Cluster/state"
 
 	consensus "github.com/libp2p/go-libp2p-consensus"
+	peer "github.com/libp2p/go-libp2p-peer"
 )
 
 // Type of consensus operation
 const (
 	LogOpPin = iota + 1
 	LogOpUnpin
+	LogOpDrain
+	LogOpUndrain
 )
 
 // LogOpType expresses the type of a consensus Operation
@@ -23,6 +26,7 @@ type LogOpType int
 // Consensus component.
 type LogOp struct {
 	Cid       api.PinSerial
+	Peer      string `codec:",omitempty"` // for drain operations
 	Type      LogOpType
 	consensus *Consensus
 }
@@ -72,6 +76,20 @@ func (op *LogOp) ApplyTo(cstate consensus.State) (consensus.State, error) {
 			&struct{}{},
 			nil,
 		)
+	case LogOpDrain, LogOpUndrain:
+		var pid peer.ID
+		pid, err = peer.IDB58Decode(op.Peer)
+		if err != nil {
+			goto ROLLBACK
+		}
+		if op.Type == LogOpDrain {
+			err = state.Drain(pid)
+		} else {
+			err = state.Undrain(pid)
+		}
+		if err != nil {
+			goto ROLLBACK
+		}
 	default:
 		logger.Error("unknown LogOp type. Ignoring")
 	}
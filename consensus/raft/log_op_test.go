@@ -48,6 +48,30 @@ func TestApplyToUnpin(t *testing.T) {
 	}
 }
 
+func TestApplyToDrain(t *testing.T) {
+	cc := testingConsensus(t, 1)
+	op := &LogOp{
+		Peer:      test.TestPeerID1.Pretty(),
+		Type:      LogOpDrain,
+		consensus: cc,
+	}
+	defer cleanRaft(1)
+	defer cc.Shutdown()
+
+	st := mapstate.NewMapState()
+	op.ApplyTo(st)
+	draining := st.Draining()
+	if len(draining) != 1 || draining[0] != test.TestPeerID1 {
+		t.Error("the state was not modified correctly")
+	}
+
+	op.Type = LogOpUndrain
+	op.ApplyTo(st)
+	if len(st.Draining()) != 0 {
+		t.Error("the state was not modified correctly")
+	}
+}
+
 func TestApplyToBadState(t *testing.T) {
 	defer func() {
 		if r := recover(); r == nil {
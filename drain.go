@@ -0,0 +1,160 @@
+package ipfscluster
+
+import (
+	"errors"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p-peer"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/state"
+)
+
+// PeerDrain marks a cluster peer as draining in the shared state. Draining
+// peers receive no new allocations and the cluster leader gradually moves
+// the pins allocated to them to other peers, pinning every item in its
+// new peer before removing it from the drained one. This allows taking a
+// peer down for maintenance without abrupt repinning.
+func (c *Cluster) PeerDrain(pid peer.ID) error {
+	peers, err := c.consensus.Peers()
+	if err != nil {
+		return err
+	}
+	if !containsPeer(peers, pid) {
+		return errors.New("not a cluster peer: " + pid.Pretty())
+	}
+	logger.Infof("draining %s", pid.Pretty())
+	return c.consensus.LogDrain(pid)
+}
+
+// PeerUndrain clears the draining mark of a cluster peer so that it
+// receives new allocations again. Pins already moved stay where they are.
+func (c *Cluster) PeerUndrain(pid peer.ID) error {
+	logger.Infof("undraining %s", pid.Pretty())
+	return c.consensus.LogUndrain(pid)
+}
+
+// DrainStatus reports the progress of every draining peer: the number of
+// pins which are still allocated to it.
+func (c *Cluster) DrainStatus() ([]api.DrainInfo, error) {
+	cState, err := c.consensus.State()
+	if err != nil {
+		return nil, err
+	}
+
+	draining := cState.Draining()
+	infos := make([]api.DrainInfo, len(draining), len(draining))
+	for i, p := range draining {
+		infos[i].Peer = p
+	}
+	for _, pin := range cState.List() {
+		for i := range infos {
+			if containsPeer(pin.Allocations, infos[i].Peer) {
+				infos[i].Remaining++
+			}
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Peer < infos[j].Peer
+	})
+	return infos, nil
+}
+
+// drainingPeers returns the peers marked as draining in the shared state.
+func (c *Cluster) drainingPeers() []peer.ID {
+	cState, err := c.consensus.State()
+	if err != nil {
+		logger.Debug(err)
+		return nil
+	}
+	return cState.Draining()
+}
+
+// drainWatcher moves, every PeerWatchInterval and when this peer is the
+// cluster leader, up to DrainMaxMoves pins away from draining peers. The
+// moves run in the background and no new batch is planned until they
+// are done.
+func (c *Cluster) drainWatcher() {
+	ticker := time.NewTicker(c.config.PeerWatchInterval)
+	for {
+		select {
+		case <-c.ctx.Done():
+			ticker.Stop()
+			return
+		case <-ticker.C:
+			leader, err := c.consensus.Leader()
+			if err != nil || leader != c.id {
+				continue
+			}
+			cState, err := c.consensus.State()
+			if err != nil {
+				continue
+			}
+			if !atomic.CompareAndSwapInt32(&c.drainMoving, 0, 1) {
+				continue
+			}
+			moves := c.planDrain(cState, c.config.DrainMaxMoves)
+			if len(moves) == 0 {
+				atomic.StoreInt32(&c.drainMoving, 0)
+				continue
+			}
+			logger.Infof("draining: moving %d allocations", len(moves))
+			go func() {
+				defer atomic.StoreInt32(&c.drainMoving, 0)
+				c.moveAllocations(moves, c.config.DrainPinTimeout)
+			}()
+		}
+	}
+}
+
+// planDrain picks at most limit allocations of draining peers and finds
+// new peers for them. Allocations which do not need replacing, because
+// the remaining ones satisfy the replication factor, are dropped
+// right away.
+func (c *Cluster) planDrain(cState state.State, limit int) []api.RebalanceMove {
+	draining := cState.Draining()
+	if len(draining) == 0 {
+		return nil
+	}
+
+	var moves []api.RebalanceMove
+	for _, pin := range cState.List() {
+		for _, p := range draining {
+			if len(moves) >= limit {
+				return moves
+			}
+			if !containsPeer(pin.Allocations, p) {
+				continue
+			}
+
+			allocs, err := c.allocate(pin, []peer.ID{p}, nil)
+			if err != nil {
+				logger.Warningf("draining: cannot re-allocate %s: %s", pin.Cid, err)
+				continue
+			}
+
+			var to []peer.ID
+			for _, a := range allocs {
+				if !containsPeer(pin.Allocations, a) {
+					to = append(to, a)
+				}
+			}
+			if len(to) == 0 {
+				logger.Infof("draining: dropping %s from %s", pin.Cid, p.Pretty())
+				c.replaceAllocation(pin.Cid, p, "")
+				continue
+			}
+			for _, a := range to {
+				moves = append(moves, api.RebalanceMove{
+					Cid:  pin.Cid,
+					Name: pin.Name,
+					From: p,
+					To:   a,
+				})
+			}
+		}
+	}
+	return moves
+}
@@ -0,0 +1,112 @@
+package ipfscluster
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+// EventsChannelCap specifies how many events can wait to be delivered to
+// the event sinks. Events are dropped when it is full.
+var EventsChannelCap = 1024
+
+// PublishEvent places an event in the event bus of this peer. The bus
+// delivers it to every API component implementing EventSink. Events are
+// produced by the pin trackers, the consensus component and the UID
+// operations. Publishing never blocks.
+func (c *Cluster) PublishEvent(ev api.Event) {
+	if ev.TS.IsZero() {
+		ev.TS = time.Now()
+	}
+	if ev.Peer == "" {
+		ev.Peer = c.id
+	}
+
+	select {
+	case c.eventsCh <- ev:
+	default:
+		logger.Errorf("events channel is full. Dropping %s event", ev.Type)
+	}
+}
+
+// dispatchEvents delivers the events in the bus to the event sinks.
+func (c *Cluster) dispatchEvents() {
+	var sinks []EventSink
+	for _, api := range c.apis {
+		if sink, ok := api.(EventSink); ok {
+			sinks = append(sinks, sink)
+		}
+	}
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case ev := <-c.eventsCh:
+			for _, sink := range sinks {
+				sink.HandleEvent(ev)
+			}
+		}
+	}
+}
+
+func (c *Cluster) publishUidRenamed(renew api.UIDRenew) {
+	c.PublishEvent(api.Event{
+		Type:   api.EventUidRenamed,
+		UID:    renew.UID,
+		OldUID: renew.OldUID,
+	})
+}
+
+// publishPeersetChanges compares the current peerset with the one seen
+// last time and publishes the peers which joined or left. It returns the
+// new set. Nothing is published the first time, when known is nil.
+func (c *Cluster) publishPeersetChanges(known map[peer.ID]struct{}, peers []peer.ID) map[peer.ID]struct{} {
+	current := make(map[peer.ID]struct{}, len(peers))
+	for _, p := range peers {
+		current[p] = struct{}{}
+	}
+	if known == nil {
+		return current
+	}
+
+	for p := range current {
+		if _, ok := known[p]; !ok {
+			c.PublishEvent(api.Event{Type: api.EventPeerJoined, Target: p})
+		}
+	}
+	for p := range known {
+		if _, ok := current[p]; !ok {
+			c.PublishEvent(api.Event{Type: api.EventPeerLeft, Target: p})
+		}
+	}
+	return current
+}
+
+func (c *Cluster) publishAlert(alrt api.Alert) {
+	msg := alrt.MetricName
+	if alrt.Message != "" {
+		msg = fmt.Sprintf("%s: %s", alrt.MetricName, alrt.Message)
+	}
+	c.PublishEvent(api.Event{
+		Type:    api.EventAlert,
+		Target:  alrt.Peer,
+		Message: msg,
+	})
+}
+
+// publishFileOp publishes a modification of the files of a UID. The
+// path is the one which was created, written, moved or removed. The
+// search index of the home is queued to be updated as well.
+func (c *Cluster) publishFileOp(op, uid, path string) {
+	c.search.update(uid, path)
+	c.PublishEvent(api.Event{
+		Type: api.EventFileOp,
+		UID:  uid,
+		Op:   op,
+		Path: path,
+	})
+}
@@ -0,0 +1,75 @@
+package ipfscluster
+
+import "sync"
+
+// homeLocks serializes the operations on the files of every UID home, so
+// that a batch or a login replacing a home does not lose the changes made
+// while it runs. Reads share the lock of a home, modifications take it
+// exclusively.
+//
+// The zero value is ready to use.
+type homeLocks struct {
+	mux   sync.Mutex
+	locks map[string]*homeLock
+}
+
+// homeLock is the lock of a home and the number of operations holding or
+// waiting for it. It is forgotten when there are none.
+type homeLock struct {
+	sync.RWMutex
+	refs int
+}
+
+func (hl *homeLocks) get(uid string) *homeLock {
+	hl.mux.Lock()
+	defer hl.mux.Unlock()
+	if hl.locks == nil {
+		hl.locks = make(map[string]*homeLock)
+	}
+	l, ok := hl.locks[uid]
+	if !ok {
+		l = &homeLock{}
+		hl.locks[uid] = l
+	}
+	l.refs++
+	return l
+}
+
+func (hl *homeLocks) put(uid string) {
+	hl.mux.Lock()
+	defer hl.mux.Unlock()
+	l := hl.locks[uid]
+	l.refs--
+	if l.refs == 0 {
+		delete(hl.locks, uid)
+	}
+}
+
+// lock locks the home of uid for a modification. It returns the function
+// which unlocks it. Operations without a UID, i.e. on all the homes, are
+// not locked.
+func (hl *homeLocks) lock(uid string) func() {
+	if uid == "" {
+		return func() {}
+	}
+	l := hl.get(uid)
+	l.Lock()
+	return func() {
+		l.Unlock()
+		hl.put(uid)
+	}
+}
+
+// rlock locks the home of uid for reading. It returns the function which
+// unlocks it.
+func (hl *homeLocks) rlock(uid string) func() {
+	if uid == "" {
+		return func() {}
+	}
+	l := hl.get(uid)
+	l.RLock()
+	return func() {
+		l.RUnlock()
+		hl.put(uid)
+	}
+}
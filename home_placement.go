@@ -0,0 +1,105 @@
+package ipfscluster
+
+import (
+	peer "github.com/libp2p/go-libp2p-peer"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/allocator/util"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/rpcutil"
+)
+
+// UidNew creates a new UID and its home on this peer. The home is then
+// replicated on as many other peers as needed to reach HomeReplication,
+// picking those hosting the fewest tenants. Failing to replicate the home
+// does not make UidNew fail: the home is still copied to any other peer
+// when the UID is first used there.
+func (c *Cluster) UidNew(name string) (api.UIDSecret, error) {
+	secret, err := c.ipfs.UidNew(name)
+	if err != nil {
+		return secret, err
+	}
+
+	c.PublishEvent(api.Event{
+		Type: api.EventUidCreated,
+		UID:  secret.UID,
+	})
+	c.replicateHome(secret.UID)
+	return secret, nil
+}
+
+// replicateHome asks the peers chosen by homePlacement() to fetch the key
+// and the home of a UID created in this peer.
+func (c *Cluster) replicateHome(uid string) {
+	n := c.config.HomeReplication - 1
+	if n <= 0 {
+		return
+	}
+
+	metrics := c.homePlacementMetrics(c.monitor.LatestMetrics(c.config.HomePlacementMetric))
+	peers := homePlacement(metrics, c.id, n)
+	if len(peers) < n {
+		logger.Warningf(
+			"home of %s wanted in %d more peers but only %d have a valid %s metric",
+			uid,
+			n,
+			len(peers),
+			c.config.HomePlacementMetric,
+		)
+	}
+	if len(peers) == 0 {
+		return
+	}
+
+	logger.Infof("replicating home of %s in %s", uid, peers)
+
+	ctxs, cancels := rpcutil.CtxsWithCancel(c.ctx, len(peers))
+	defer rpcutil.MultiCancel(cancels)
+
+	errs := c.rpcClient.MultiCall(
+		ctxs,
+		peers,
+		"Cluster",
+		"SyncKey",
+		uid,
+		rpcutil.RPCDiscardReplies(len(peers)),
+	)
+	for i, err := range errs {
+		if err != nil {
+			logger.Errorf("error replicating home of %s in %s: %s", uid, peers[i].Pretty(), err)
+		}
+	}
+}
+
+// homePlacementMetrics returns the metrics of the peers which can receive
+// new homes. As with pin allocations, draining peers and peers blocked by
+// an alert are left out.
+func (c *Cluster) homePlacementMetrics(metrics []api.Metric) []api.Metric {
+	draining := c.drainingPeers()
+	candidates := make([]api.Metric, 0)
+	for _, m := range metrics {
+		if containsPeer(draining, m.Peer) || c.allocationBlocked(m.Peer) {
+			continue
+		}
+		candidates = append(candidates, m)
+	}
+	return candidates
+}
+
+// homePlacement returns at most n peers, other than self, from those with
+// the given metrics. Peers with the smallest metric values (i.e. the
+// fewest homes) come first so that tenants spread evenly.
+func homePlacement(metrics []api.Metric, self peer.ID, n int) []peer.ID {
+	candidates := make(map[peer.ID]api.Metric, len(metrics))
+	for _, m := range metrics {
+		if m.Peer == self {
+			continue
+		}
+		candidates[m.Peer] = m
+	}
+
+	sorted := util.SortNumeric(candidates, false)
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
@@ -23,6 +23,8 @@ func (t MetricType) String() string {
 		return "freespace"
 	case MetricRepoSize:
 		return "reposize"
+	case MetricRepoUsage:
+		return "repousage"
 	}
 	return ""
 }
@@ -84,6 +86,8 @@ func (cfg *Config) LoadJSON(raw []byte) error {
 		cfg.Type = MetricRepoSize
 	case "freespace":
 		cfg.Type = MetricFreeSpace
+	case "repousage":
+		cfg.Type = MetricRepoUsage
 	default:
 		return errors.New("disk.metric_type is invalid")
 	}
@@ -19,6 +19,9 @@ const (
 	MetricFreeSpace = iota
 	// MetricRepoSize provides the used space reported by IPFS
 	MetricRepoSize
+	// MetricRepoUsage provides the used space as a percentage of the
+	// maximum storage reported by IPFS
+	MetricRepoUsage
 )
 
 var logger = logging.Logger("diskinfo")
@@ -89,6 +92,10 @@ func (disk *Informer) GetMetric() api.Metric {
 			metric = repoStat.StorageMax - repoStat.RepoSize
 		case MetricRepoSize:
 			metric = repoStat.RepoSize
+		case MetricRepoUsage:
+			if repoStat.StorageMax > 0 {
+				metric = repoStat.RepoSize * 100 / repoStat.StorageMax
+			}
 		}
 	}
 
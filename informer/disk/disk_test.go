@@ -97,6 +97,27 @@ func TestRepoSize(t *testing.T) {
 	}
 }
 
+func TestRepoUsage(t *testing.T) {
+	cfg := &Config{}
+	cfg.Default()
+	cfg.Type = MetricRepoUsage
+
+	inf, err := NewInformer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inf.Shutdown()
+	inf.SetClient(test.NewMockRPCClient(t))
+	m := inf.GetMetric()
+	if !m.Valid {
+		t.Error("metric should be valid")
+	}
+	// The mock client reports 100KB and 2 pins of 1 KB
+	if m.Value != "2" {
+		t.Error("bad metric value")
+	}
+}
+
 func TestWithErrors(t *testing.T) {
 	cfg := &Config{}
 	cfg.Default()
@@ -0,0 +1,89 @@
+package tags
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/config"
+)
+
+const configKey = "tags"
+
+// These are the default values for a Config.
+const (
+	DefaultMetricTTL = 30 * time.Second
+)
+
+// Config allows to initialize an Informer.
+type Config struct {
+	config.Saver
+
+	MetricTTL time.Duration
+	// Tags are static labels for this peer, such as the region,
+	// the rack or the storage tier.
+	Tags map[string]string
+}
+
+type jsonConfig struct {
+	MetricTTL string            `json:"metric_ttl"`
+	Tags      map[string]string `json:"tags"`
+}
+
+// ConfigKey returns a human-friendly identifier for this
+// Config's type.
+func (cfg *Config) ConfigKey() string {
+	return configKey
+}
+
+// Default initializes this Config with sensible values.
+func (cfg *Config) Default() error {
+	cfg.MetricTTL = DefaultMetricTTL
+	cfg.Tags = make(map[string]string)
+	return nil
+}
+
+// Validate checks that the fields of this configuration have
+// sensible values.
+func (cfg *Config) Validate() error {
+	if cfg.MetricTTL <= 0 {
+		return errors.New("tags.metric_ttl is invalid")
+	}
+
+	for k := range cfg.Tags {
+		if k == "" {
+			return errors.New("tags.tags: empty tag names are not allowed")
+		}
+	}
+
+	return nil
+}
+
+// LoadJSON parses a raw JSON byte-slice as generated by ToJSON().
+func (cfg *Config) LoadJSON(raw []byte) error {
+	jcfg := &jsonConfig{}
+	err := json.Unmarshal(raw, jcfg)
+	if err != nil {
+		return err
+	}
+
+	t, _ := time.ParseDuration(jcfg.MetricTTL)
+	cfg.MetricTTL = t
+
+	cfg.Tags = make(map[string]string, len(jcfg.Tags))
+	for k, v := range jcfg.Tags {
+		cfg.Tags[k] = v
+	}
+
+	return cfg.Validate()
+}
+
+// ToJSON generates a human-friendly JSON representation of this Config.
+func (cfg *Config) ToJSON() ([]byte, error) {
+	jcfg := &jsonConfig{}
+
+	jcfg.MetricTTL = cfg.MetricTTL.String()
+	jcfg.Tags = cfg.Tags
+
+	return config.DefaultJSONMarshal(jcfg)
+}
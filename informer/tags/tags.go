@@ -0,0 +1,65 @@
+// Package tags implements an ipfs-cluster informer which publishes the
+// static tags of a peer (i.e. region, rack or tier), as set in the
+// configuration, so that allocators can take them into account.
+package tags
+
+import (
+	"encoding/json"
+
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+)
+
+// MetricName specifies the name of our metric
+var MetricName = api.TagsMetricName
+
+// Informer is a simple object to implement the ipfscluster.Informer
+// and Component interfaces
+type Informer struct {
+	config *Config
+}
+
+// NewInformer returns an initialized Informer.
+func NewInformer(cfg *Config) (*Informer, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Informer{
+		config: cfg,
+	}, nil
+}
+
+// SetClient does nothing in this informer.
+func (tagsi *Informer) SetClient(c *rpc.Client) {}
+
+// Shutdown does nothing in this informer.
+func (tagsi *Informer) Shutdown() error {
+	return nil
+}
+
+// Name returns the name of this informer
+func (tagsi *Informer) Name() string {
+	return MetricName
+}
+
+// GetMetric returns a metric whose value is the JSON-encoded
+// object of configured tags. See api.ParseTags().
+func (tagsi *Informer) GetMetric() api.Metric {
+	tags := tagsi.config.Tags
+	if tags == nil {
+		tags = make(map[string]string)
+	}
+	value, err := json.Marshal(tags)
+
+	m := api.Metric{
+		Name:  MetricName,
+		Value: string(value),
+		Valid: err == nil,
+	}
+
+	m.SetTTL(tagsi.config.MetricTTL)
+	return m
+}
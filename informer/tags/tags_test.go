@@ -0,0 +1,33 @@
+package tags
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+)
+
+func Test(t *testing.T) {
+	cfg := &Config{}
+	cfg.Default()
+	cfg.Tags["region"] = "eu-west"
+	cfg.Tags["tier"] = "ssd"
+	inf, err := NewInformer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := inf.GetMetric()
+	if !m.Valid {
+		t.Fatal("metric should be valid")
+	}
+	if m.Name != api.TagsMetricName {
+		t.Error("bad metric name")
+	}
+
+	tags, err := api.ParseTags(m.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 2 || tags["region"] != "eu-west" || tags["tier"] != "ssd" {
+		t.Error("bad metric value: ", m.Value)
+	}
+}
@@ -0,0 +1,149 @@
+// Package tenants implements an ipfs-cluster informer which reports how many
+// user homes (the /nodes/<uid> directories of the virtual IPFS peers) this
+// Hive peer hosts, or how large they are, as an api.Metric.
+package tenants
+
+import (
+	"fmt"
+	"sync"
+
+	logging "github.com/ipfs/go-log"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+)
+
+// MetricType identifies the type of metric to produce.
+type MetricType int
+
+const (
+	// MetricHomes provides the number of user homes
+	MetricHomes = iota
+	// MetricHomesSize provides the total cumulative size of the user homes
+	MetricHomesSize
+)
+
+var logger = logging.Logger("tenantsinfo")
+
+// Informer is a simple object to implement the ipfscluster.Informer
+// and Component interfaces.
+type Informer struct {
+	config    *Config
+	rpcClient *rpc.Client
+
+	sizesMux sync.Mutex
+	sizes    map[string]uint64
+}
+
+// NewInformer returns an initialized informer using the given Config.
+func NewInformer(cfg *Config) (*Informer, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Informer{
+		config: cfg,
+	}, nil
+}
+
+// Name returns the user-facing name of this informer.
+func (tnt *Informer) Name() string {
+	return tnt.config.Type.String()
+}
+
+// SetClient provides us with an rpc.Client which allows
+// contacting other components in the cluster.
+func (tnt *Informer) SetClient(c *rpc.Client) {
+	tnt.rpcClient = c
+}
+
+// Shutdown is called on cluster shutdown. We just invalidate
+// any metrics from this point.
+func (tnt *Informer) Shutdown() error {
+	tnt.rpcClient = nil
+	return nil
+}
+
+// GetMetric lists the user homes in IPFS and returns either how many
+// there are or the sum of their cumulative sizes.
+func (tnt *Informer) GetMetric() api.Metric {
+	if tnt.rpcClient == nil {
+		return api.Metric{
+			Name:  tnt.Name(),
+			Valid: false,
+		}
+	}
+
+	metric, err := tnt.measure()
+	if err != nil {
+		logger.Error(err)
+	}
+
+	m := api.Metric{
+		Name:  tnt.Name(),
+		Value: fmt.Sprintf("%d", metric),
+		Valid: err == nil,
+	}
+
+	m.SetTTL(tnt.config.MetricTTL)
+	return m
+}
+
+func (tnt *Informer) measure() (uint64, error) {
+	var homes api.FilesLs
+	err := tnt.rpcClient.Call("",
+		"Cluster",
+		"IPFSFilesLs",
+		[]string{"", ""}, // the /nodes directory
+		&homes)
+	if err != nil {
+		return 0, err
+	}
+
+	// Staging homes are temporary copies of homes, not tenants.
+	var uids []string
+	for _, home := range homes.Entries {
+		if !api.IsStagingHome(home.Name) {
+			uids = append(uids, home.Name)
+		}
+	}
+
+	if tnt.config.Type == MetricHomes {
+		return uint64(len(uids)), nil
+	}
+
+	var size uint64
+	sizes := make(map[string]uint64, len(uids))
+	for _, uid := range uids {
+		var stat api.FilesStat
+		err := tnt.rpcClient.Call("",
+			"Cluster",
+			"IPFSFilesStat",
+			[]string{uid, "", "", "", "", ""},
+			&stat)
+		if err != nil {
+			return 0, err
+		}
+		sizes[uid] = stat.CumulativeSize
+		size += stat.CumulativeSize
+	}
+
+	tnt.sizesMux.Lock()
+	tnt.sizes = sizes
+	tnt.sizesMux.Unlock()
+	return size, nil
+}
+
+// HomeSizes returns the cumulative size of every user home, by UID, as
+// last measured by GetMetric(). It is empty unless the informer provides
+// the size of the homes.
+func (tnt *Informer) HomeSizes() map[string]uint64 {
+	tnt.sizesMux.Lock()
+	defer tnt.sizesMux.Unlock()
+	sizes := make(map[string]uint64, len(tnt.sizes))
+	for uid, size := range tnt.sizes {
+		sizes[uid] = size
+	}
+	return sizes
+}
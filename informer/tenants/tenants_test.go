@@ -0,0 +1,107 @@
+package tenants
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+)
+
+type mockService struct{}
+
+func mockRPCClient(t *testing.T) *rpc.Client {
+	s := rpc.NewServer(nil, "mock")
+	c := rpc.NewClientWithServer(nil, "mock", s)
+	err := s.RegisterName("Cluster", &mockService{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func (mock *mockService) IPFSFilesLs(ctx context.Context, in []string, out *api.FilesLs) error {
+	if in[0] != "" || in[1] != "" {
+		return errors.New("expected a listing of /nodes")
+	}
+	*out = api.FilesLs{
+		Entries: []api.FileLsEntrie{
+			{Name: "uid-1"},
+			{Name: "uid-2"},
+			{Name: "uid-3"},
+			// not a home
+			{Name: api.NewStagingHome("uid-1")},
+		},
+	}
+	return nil
+}
+
+func (mock *mockService) IPFSFilesStat(ctx context.Context, in []string, out *api.FilesStat) error {
+	*out = api.FilesStat{
+		CumulativeSize: 1000,
+	}
+	return nil
+}
+
+func testInformer(t *testing.T, mtype MetricType) *Informer {
+	cfg := &Config{}
+	cfg.Default()
+	cfg.Type = mtype
+	inf, err := NewInformer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inf
+}
+
+func TestHomes(t *testing.T) {
+	inf := testInformer(t, MetricHomes)
+	defer inf.Shutdown()
+	m := inf.GetMetric()
+	if m.Valid {
+		t.Error("metric should be invalid")
+	}
+	inf.SetClient(mockRPCClient(t))
+	m = inf.GetMetric()
+	if !m.Valid {
+		t.Error("metric should be valid")
+	}
+	if m.Name != "homes" || m.Value != "3" {
+		t.Error("bad metric: ", m.Name, m.Value)
+	}
+}
+
+func TestHomesSize(t *testing.T) {
+	inf := testInformer(t, MetricHomesSize)
+	defer inf.Shutdown()
+	inf.SetClient(mockRPCClient(t))
+	m := inf.GetMetric()
+	if !m.Valid {
+		t.Error("metric should be valid")
+	}
+	if m.Name != "homessize" || m.Value != "3000" {
+		t.Error("bad metric: ", m.Name, m.Value)
+	}
+}
+
+func TestHomeSizes(t *testing.T) {
+	inf := testInformer(t, MetricHomesSize)
+	defer inf.Shutdown()
+	if len(inf.HomeSizes()) != 0 {
+		t.Error("no homes should have been measured yet")
+	}
+
+	inf.SetClient(mockRPCClient(t))
+	inf.GetMetric()
+	sizes := inf.HomeSizes()
+	if len(sizes) != 3 {
+		t.Fatal("expected the sizes of 3 homes:", sizes)
+	}
+	for _, uid := range []string{"uid-1", "uid-2", "uid-3"} {
+		if sizes[uid] != 1000 {
+			t.Errorf("bad size for %s: %d", uid, sizes[uid])
+		}
+	}
+}
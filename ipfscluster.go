@@ -42,6 +42,10 @@ type Consensus interface {
 	LogPin(c api.Pin) error
 	// Logs an unpin operation
 	LogUnpin(c api.Pin) error
+	// Logs marking a peer as draining
+	LogDrain(p peer.ID) error
+	// Logs clearing the draining mark of a peer
+	LogUndrain(p peer.ID) error
 	AddPeer(p peer.ID) error
 	RmPeer(p peer.ID) error
 	State() (state.State, error)
@@ -63,6 +67,20 @@ type API interface {
 	Component
 }
 
+// EventSink is implemented by API components which want to receive the
+// events produced in this cluster peer, i.e. to forward them to external
+// services. HandleEvent must not block.
+type EventSink interface {
+	HandleEvent(api.Event)
+}
+
+// EventProducer is implemented by components which publish events
+// frequently, like the pin trackers. They are given direct access to the
+// event bus, so that their events do not go through RPC.
+type EventProducer interface {
+	SetEventPublisher(func(api.Event))
+}
+
 // IPFSConnector is a component which allows cluster to interact with
 // an IPFS daemon. This is a base component.
 type IPFSConnector interface {
@@ -87,6 +105,10 @@ type IPFSConnector interface {
 	BlockPut(api.NodeWithMeta) error
 	// BlockGet retrieves the raw data of an IPFS block
 	BlockGet(cid.Cid) ([]byte, error)
+	// BlockGetLocal retrieves the raw data of an IPFS block only when
+	// it is stored by the IPFS daemon, without fetching it from the
+	// network.
+	BlockGetLocal(cid.Cid) ([]byte, error)
 	// UidNew registers a uid in hive cluster
 	UidNew(name string) (api.UIDSecret, error)
 	// UidRenew is used to change uid
@@ -97,6 +119,12 @@ type IPFSConnector interface {
 	UidLogin([]string) error
 	// FileGet downloads file from ipfs service
 	FileGet(fg []string) ([]byte, error)
+	// Cat reads a slice of the file at an IPFS path.
+	Cat(api.FileCat) ([]byte, error)
+	// Ls lists the directory at an IPFS path.
+	Ls(path string) (api.FilesLs, error)
+	// Stat returns the statistics of an IPFS path.
+	Stat(path string) (api.FilesStat, error)
 	// FilesCp is used to copy file
 	FilesCp([]string) error
 	// FilesFlush is used to change uid
@@ -117,6 +145,8 @@ type IPFSConnector interface {
 	FilesWrite(api.FilesWrite) error
 	// NamePublish publish ipfs path with uid
 	NamePublish(np []string) (api.NamePublish, error)
+	// NameResolve returns the IPFS path an IPNS name points to.
+	NameResolve(name string) (string, error)
 }
 
 // Peered represents a component which needs to be aware of the peers
@@ -140,6 +170,9 @@ type PinTracker interface {
 	Untrack(cid.Cid) error
 	// StatusAll returns the list of pins with their local status.
 	StatusAll() []api.PinInfo
+	// StatusAllPage returns the local status of the pins selected by
+	// the given page, sorted by Cid, without building the full list.
+	StatusAllPage(api.ListPage) []api.PinInfo
 	// Status returns the local status of a given Cid.
 	Status(cid.Cid) api.PinInfo
 	// SyncAll makes sure that all tracked Cids reflect the real IPFS status.
@@ -152,6 +185,18 @@ type PinTracker interface {
 	RecoverAll() ([]api.PinInfo, error)
 	// Recover retriggers a Pin/Unpin operation in a Cids with error status.
 	Recover(cid.Cid) (api.PinInfo, error)
+	// Operations returns the pin and unpin operations which are
+	// queued, in progress or in error.
+	Operations() []api.Operation
+	// CancelOperation cancels the queued or in-progress operation for a
+	// Cid, leaving it in error status.
+	CancelOperation(cid.Cid) error
+	// SetOperationPriority changes the priority of the queued operation
+	// for a Cid.
+	SetOperationPriority(cid.Cid, int) error
+	// SetProgress records the number of blocks fetched so far by the
+	// in-progress pin operation for a Cid.
+	SetProgress(cid.Cid, uint64) error
 }
 
 // Informer provides Metric information from a peer. The metrics produced by
@@ -175,8 +220,13 @@ type PinAllocator interface {
 	// least). The "current" map contains valid metrics for peers
 	// which are currently pinning the content. The candidates map
 	// contains the metrics for all peers which are eligible for pinning
-	// the content.
-	Allocate(c cid.Cid, current, candidates, priority map[peer.ID]api.Metric) ([]peer.ID, error)
+	// the content. Every peer comes with the latest values of the
+	// metrics returned by Metrics(). The pin carries the options which may
+	// constrain the allocation, such as the tags to spread on.
+	Allocate(pin api.Pin, current, candidates, priority map[peer.ID]api.MetricsSet) ([]peer.ID, error)
+	// Metrics returns the names of the metrics the allocator needs.
+	// Only peers with valid values for all of them are candidates.
+	Metrics() []string
 }
 
 // PeerMonitor is a component in charge of publishing a peer's metrics and
@@ -197,8 +247,15 @@ type PeerMonitor interface {
 	// LatestMetrics returns a map with the latest metrics of matching name
 	// for the current cluster peers.
 	LatestMetrics(name string) []api.Metric
+	// MetricsHistory returns the recent metrics of matching name for
+	// every peer, along with some aggregates over them.
+	MetricsHistory(name string) []api.MetricHistory
 	// Alerts delivers alerts generated when this peer monitor detects
 	// a problem (i.e. metrics not arriving as expected). Alerts can be used
 	// to trigger self-healing measures or re-pinnings of content.
 	Alerts() <-chan api.Alert
+	// SendAlert delivers an alert on the Alerts channel. It allows
+	// other components to report problems which are not detected
+	// by the monitor itself.
+	SendAlert(api.Alert) error
 }
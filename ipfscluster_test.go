@@ -172,9 +172,9 @@ func createComponents(t *testing.T, i int, clusterSecret []byte, staging bool) (
 
 	mon := makeMonitor(t, host, bmonCfg, psmonCfg)
 
-	alloc := descendalloc.NewAllocator()
 	inf, err := disk.NewInformer(diskInfCfg)
 	checkErr(t, err)
+	alloc := descendalloc.NewAllocator(inf.Name())
 	raftCon, err := raft.NewConsensus(host, consensusCfg, state, staging)
 	checkErr(t, err)
 
@@ -533,6 +533,111 @@ func TestClustersStatusAll(t *testing.T) {
 	runF(t, clusters, f)
 }
 
+func TestClustersStatusAllPage(t *testing.T) {
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+	h1, _ := cid.Decode(test.TestCid1)
+	h2, _ := cid.Decode(test.TestCid2)
+	clusters[0].Pin(api.PinCid(h1))
+	clusters[0].Pin(api.PinCid(h2))
+	pinDelay()
+
+	f := func(t *testing.T, c *Cluster) {
+		// TestCid2 sorts before TestCid1
+		statuses, err := c.StatusAllPage(api.ListPage{Limit: 1})
+		if err != nil {
+			t.Error(err)
+		}
+		if len(statuses) != 1 || !statuses[0].Cid.Equals(h2) {
+			t.Fatal("expected TestCid2 in the first page")
+		}
+		if len(statuses[0].PeerMap) != nClusters {
+			t.Error("bad info in status")
+		}
+
+		statuses, err = c.StatusAllPage(api.ListPage{
+			Cursor: statuses[0].Cid.String(),
+			Limit:  1,
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if len(statuses) != 1 || !statuses[0].Cid.Equals(h1) {
+			t.Fatal("expected TestCid1 in the second page")
+		}
+
+		pins := c.PinsPage(api.ListPage{Cursor: h1.String()})
+		if len(pins) != 0 {
+			t.Error("expected no pins after the last cid")
+		}
+	}
+	runF(t, clusters, f)
+}
+
+func TestClustersStatusAllStream(t *testing.T) {
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+	h1, _ := cid.Decode(test.TestCid1)
+	h2, _ := cid.Decode(test.TestCid2)
+	clusters[0].Pin(api.PinCid(h1))
+	clusters[0].Pin(api.PinCid(h2))
+	pinDelay()
+
+	f := func(t *testing.T, c *Cluster) {
+		out := make(chan api.GlobalPinInfo, 1)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- c.StatusAllStream(context.Background(), api.ListPage{}, out)
+		}()
+
+		var statuses []api.GlobalPinInfo
+		for gpi := range out {
+			statuses = append(statuses, gpi)
+		}
+		if err := <-errCh; err != nil {
+			t.Error(err)
+		}
+
+		// TestCid2 sorts before TestCid1
+		if len(statuses) != 2 || !statuses[0].Cid.Equals(h2) || !statuses[1].Cid.Equals(h1) {
+			t.Fatal("expected TestCid2 and TestCid1 in order:", statuses)
+		}
+		for _, gpi := range statuses {
+			if len(gpi.PeerMap) != nClusters {
+				t.Error("bad info in status")
+			}
+			for _, pi := range gpi.PeerMap {
+				if pi.Status != api.TrackerStatusPinned {
+					t.Error("the status should show the hash as pinned")
+				}
+			}
+		}
+	}
+	runF(t, clusters, f)
+}
+
+func TestClustersPinsHealth(t *testing.T) {
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+	h, _ := cid.Decode(test.TestCid1)
+	clusters[0].Pin(api.PinCid(h))
+	pinDelay()
+
+	f := func(t *testing.T, c *Cluster) {
+		report, err := c.PinsHealth(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Total != 1 || report.Summary[api.PinHealthOK] != 1 {
+			t.Errorf("expected a healthy pin: %+v", report.Summary)
+		}
+		if len(report.Worst) != 0 {
+			t.Error("expected no unhealthy pins")
+		}
+	}
+	runF(t, clusters, f)
+}
+
 func TestClustersStatusAllWithErrors(t *testing.T) {
 	clusters, mock := createClusters(t)
 	defer shutdownClusters(t, clusters, mock)
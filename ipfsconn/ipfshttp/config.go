@@ -21,6 +21,7 @@ const (
 	DefaultIPFSRequestTimeout = 5 * time.Minute
 	DefaultPinTimeout         = 24 * time.Hour
 	DefaultUnpinTimeout       = 3 * time.Hour
+	DefaultHealthInterval     = 15 * time.Second
 )
 
 // Config is used to initialize a Connector and allows to customize
@@ -49,6 +50,11 @@ type Config struct {
 
 	// Unpin Operation timeout
 	UnpinTimeout time.Duration
+
+	// HealthInterval is how often the IPFS daemon is checked. An
+	// alert is sent to the cluster peers every time it cannot be
+	// reached. Checks are disabled when 0.
+	HealthInterval time.Duration
 }
 
 type jsonConfig struct {
@@ -58,6 +64,7 @@ type jsonConfig struct {
 	IPFSRequestTimeout string `json:"ipfs_request_timeout"`
 	PinTimeout         string `json:"pin_timeout"`
 	UnpinTimeout       string `json:"unpin_timeout"`
+	HealthInterval     string `json:"health_interval"`
 
 	// Fields below are only to maintain compatibility
 	// They can be removed in future
@@ -82,6 +89,7 @@ func (cfg *Config) Default() error {
 	cfg.IPFSRequestTimeout = DefaultIPFSRequestTimeout
 	cfg.PinTimeout = DefaultPinTimeout
 	cfg.UnpinTimeout = DefaultUnpinTimeout
+	cfg.HealthInterval = DefaultHealthInterval
 
 	return nil
 }
@@ -115,6 +123,10 @@ func (cfg *Config) Validate() error {
 	if cfg.UnpinTimeout < 0 {
 		err = errors.New("ipfshttp.unpin_timeout invalid")
 	}
+
+	if cfg.HealthInterval < 0 {
+		err = errors.New("ipfshttp.health_interval invalid")
+	}
 	return err
 
 }
@@ -143,6 +155,7 @@ func (cfg *Config) LoadJSON(raw []byte) error {
 		&config.DurationOpt{Duration: jcfg.IPFSRequestTimeout, Dst: &cfg.IPFSRequestTimeout, Name: "ipfs_request_timeout"},
 		&config.DurationOpt{Duration: jcfg.PinTimeout, Dst: &cfg.PinTimeout, Name: "pin_timeout"},
 		&config.DurationOpt{Duration: jcfg.UnpinTimeout, Dst: &cfg.UnpinTimeout, Name: "unpin_timeout"},
+		&config.DurationOpt{Duration: jcfg.HealthInterval, Dst: &cfg.HealthInterval, Name: "health_interval"},
 	)
 	if err != nil {
 		return err
@@ -171,6 +184,7 @@ func (cfg *Config) ToJSON() (raw []byte, err error) {
 	jcfg.IPFSRequestTimeout = cfg.IPFSRequestTimeout.String()
 	jcfg.PinTimeout = cfg.PinTimeout.String()
 	jcfg.UnpinTimeout = cfg.UnpinTimeout.String()
+	jcfg.HealthInterval = cfg.HealthInterval.String()
 
 	raw, err = config.DefaultJSONMarshal(jcfg)
 	return
@@ -58,4 +58,10 @@ func TestDefault(t *testing.T) {
 	if cfg.Validate() == nil {
 		t.Fatal("expected error validating")
 	}
+
+	cfg.Default()
+	cfg.HealthInterval = -1
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
 }
@@ -10,6 +10,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -37,6 +38,10 @@ var logger = logging.Logger("ipfshttp")
 // only the 10th will trigger a SendInformerMetrics call.
 var updateMetricMod = 10
 
+// progressReportInterval limits how often the progress of a pin is
+// reported to the pin tracker.
+var progressReportInterval = time.Second
+
 // Connector implements the IPFSConnector interface
 // and provides a component which  is used to perform
 // on-demand requests against the configured IPFS daemom
@@ -73,6 +78,16 @@ type ipfsPinLsResp struct {
 	Keys map[string]ipfsPinType
 }
 
+type ipfsPinProgressResp struct {
+	Pins     []string
+	Progress int
+}
+
+type ipfsRefsResp struct {
+	Ref string
+	Err string
+}
+
 type ipfsIDResp struct {
 	ID        string
 	Addresses []string
@@ -110,6 +125,19 @@ type ipfsKey struct {
 	Id   string
 }
 
+type ipfsNameResolveResp struct {
+	Path string
+}
+
+type ipfsLsResp struct {
+	Objects []ipfsLsObject
+}
+
+type ipfsLsObject struct {
+	Hash  string
+	Links []api.FileLsEntrie
+}
+
 // NewConnector creates the component and leaves it ready to be started
 func NewConnector(cfg *Config) (*Connector, error) {
 	err := cfg.Validate()
@@ -181,6 +209,54 @@ func (ipfs *Connector) run() {
 			return
 		}
 	}()
+
+	if ipfs.config.HealthInterval > 0 {
+		ipfs.wg.Add(1)
+		go func() {
+			defer ipfs.wg.Done()
+			ipfs.watchHealth()
+		}()
+	}
+}
+
+// watchHealth checks that the IPFS daemon is reachable every
+// HealthInterval and sends an alert to the cluster peers otherwise.
+func (ipfs *Connector) watchHealth() {
+	ticker := time.NewTicker(ipfs.config.HealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ipfs.ctx.Done():
+			return
+		case <-ticker.C:
+			err := ipfs.checkHealth()
+			if err == nil {
+				continue
+			}
+			logger.Errorf("IPFS daemon unreachable: %s", err)
+			err = ipfs.rpcClient.Call(
+				"",
+				"Cluster",
+				"SendAlert",
+				api.Alert{
+					MetricName: api.IPFSDownAlertName,
+					Message:    err.Error(),
+				},
+				&struct{}{},
+			)
+			if err != nil {
+				logger.Error(err)
+			}
+		}
+	}
+}
+
+// checkHealth makes a quick request to the IPFS daemon.
+func (ipfs *Connector) checkHealth() error {
+	ctx, cancel := context.WithTimeout(ipfs.ctx, ipfs.config.HealthInterval)
+	defer cancel()
+	_, err := ipfs.postCtx(ctx, "id", "", nil)
+	return err
 }
 
 // SetClient makes the component ready to perform RPC
@@ -281,24 +357,160 @@ func (ipfs *Connector) Pin(ctx context.Context, hash cid.Cid, maxDepth int) erro
 		pinArgs = fmt.Sprintf("recursive=true&max-depth=%d", maxDepth)
 	}
 
+	report, flush := ipfs.progressReporter(ctx, hash)
+	defer flush()
+
+	// fetched counts the blocks obtained with refs, so that the
+	// progress keeps increasing during pin/add.
+	var fetched uint64
+
 	switch ipfs.config.PinMethod {
 	case "refs": // do refs -r first
 		path := fmt.Sprintf("refs?arg=%s&%s", hash, pinArgs)
-		err := ipfs.postDiscardBodyCtx(ctx, path)
+		fetched, err = ipfs.refsProgress(ctx, path, report)
 		if err != nil {
 			return err
 		}
 		logger.Debugf("Refs for %s sucessfully fetched", hash)
 	}
 
-	path := fmt.Sprintf("pin/add?arg=%s&%s", hash, pinArgs)
-	_, err = ipfs.postCtx(ctx, path, "", nil)
+	path := fmt.Sprintf("pin/add?arg=%s&%s&progress=true", hash, pinArgs)
+	err = ipfs.pinProgress(ctx, path, func(blocks uint64) {
+		report(fetched + blocks)
+	})
 	if err == nil {
 		logger.Info("IPFS Pin request succeeded: ", hash)
 	}
 	return err
 }
 
+// progressReporter returns a function which sends the number of blocks
+// fetched for a pin to the pin tracker, at most once every
+// progressReportInterval, and a function which sends the last number
+// given when it was held back. The latter must be called when the pin
+// request is done.
+func (ipfs *Connector) progressReporter(ctx context.Context, hash cid.Cid) (func(uint64), func()) {
+	return throttleProgress(func(blocks uint64) {
+		pinfo := api.PinInfo{
+			Cid:      hash,
+			Progress: blocks,
+		}
+		err := ipfs.rpcClient.CallContext(
+			ctx,
+			"",
+			"Cluster",
+			"TrackerSetProgress",
+			pinfo.ToSerial(),
+			&struct{}{},
+		)
+		if err != nil {
+			logger.Debugf("error reporting progress for %s: %s", hash, err)
+		}
+	})
+}
+
+// throttleProgress wraps send so that it is called at most once every
+// progressReportInterval. The returned flush function sends the latest
+// value if it was held back.
+func throttleProgress(send func(uint64)) (report func(uint64), flush func()) {
+	var last time.Time
+	var latest uint64
+	var pending bool
+
+	report = func(blocks uint64) {
+		latest = blocks
+		if time.Since(last) < progressReportInterval {
+			pending = true
+			return
+		}
+		last = time.Now()
+		pending = false
+		send(blocks)
+	}
+
+	flush = func() {
+		if pending {
+			pending = false
+			send(latest)
+		}
+	}
+	return report, flush
+}
+
+// pinProgress performs a pin/add request with progress enabled and calls
+// report with the number of blocks fetched every time IPFS sends an
+// update.
+func (ipfs *Connector) pinProgress(ctx context.Context, path string, report func(uint64)) error {
+	res, err := ipfs.doPostCtx(ctx, ipfs.client, ipfs.apiURL(), path, "", nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(res.Body)
+		return checkResponse(path, res.StatusCode, body)
+	}
+
+	dec := json.NewDecoder(res.Body)
+	for {
+		var resp ipfsPinProgressResp
+		err := dec.Decode(&resp)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if resp.Progress > 0 {
+			report(uint64(resp.Progress))
+		}
+	}
+
+	if errMsg := res.Trailer.Get("X-Stream-Error"); errMsg != "" {
+		return fmt.Errorf("IPFS unsuccessful: %s", errMsg)
+	}
+	return nil
+}
+
+// refsProgress performs a refs request, calling report with the number of
+// references fetched so far every time a new one arrives. It returns the
+// total number of references.
+func (ipfs *Connector) refsProgress(ctx context.Context, path string, report func(uint64)) (uint64, error) {
+	res, err := ipfs.doPostCtx(ctx, ipfs.client, ipfs.apiURL(), path, "", nil)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return 0, checkResponse(path, res.StatusCode, nil)
+	}
+
+	var count uint64
+	dec := json.NewDecoder(res.Body)
+	for {
+		var resp ipfsRefsResp
+		err := dec.Decode(&resp)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count, err
+		}
+		if resp.Err != "" {
+			return count, errors.New(resp.Err)
+		}
+		count++
+		report(count)
+	}
+
+	if errMsg := res.Trailer.Get("X-Stream-Error"); errMsg != "" {
+		return count, fmt.Errorf("IPFS unsuccessful: %s", errMsg)
+	}
+	return count, nil
+}
+
 // Unpin performs an unpin request against the configured IPFS
 // daemon.
 func (ipfs *Connector) Unpin(ctx context.Context, hash cid.Cid) error {
@@ -448,21 +660,6 @@ func (ipfs *Connector) postCtx(ctx context.Context, path string, contentType str
 	return body, checkResponse(path, res.StatusCode, body)
 }
 
-// postDiscardBodyCtx makes a POST requests but discards the body
-// of the response directly after reading it.
-func (ipfs *Connector) postDiscardBodyCtx(ctx context.Context, path string) error {
-	res, err := ipfs.doPostCtx(ctx, ipfs.client, ipfs.apiURL(), path, "", nil)
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-	_, err = io.Copy(ioutil.Discard, res.Body)
-	if err != nil {
-		return err
-	}
-	return checkResponse(path, res.StatusCode, nil)
-}
-
 // apiURL is a short-hand for building the url of the IPFS
 // daemon API.
 func (ipfs *Connector) apiURL() string {
@@ -638,6 +835,16 @@ func (ipfs *Connector) BlockGet(c cid.Cid) ([]byte, error) {
 	return ipfs.postCtx(ctx, url, "", nil)
 }
 
+// BlockGetLocal retrieves an ipfs block with the given cid from the
+// daemon's repo. It fails when the daemon does not hold it, rather than
+// looking for it in the network.
+func (ipfs *Connector) BlockGetLocal(c cid.Cid) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ipfs.ctx, ipfs.config.IPFSRequestTimeout)
+	defer cancel()
+	url := "block/get?offline=true&arg=" + c.String()
+	return ipfs.postCtx(ctx, url, "", nil)
+}
+
 // Returns true every updateMetricsMod-th time that we
 // call this function.
 func (ipfs *Connector) shouldUpdateMetric() bool {
@@ -783,7 +990,33 @@ func (ipfs *Connector) UidInfo(uid string) (api.UIDSecret, error) {
 	return secret, nil
 }
 
-// log in Hive cluster to recreate user home
+// KeyList returns the IDs of the keys in the keystore of the IPFS daemon,
+// by name.
+func (ipfs *Connector) KeyList() (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(ipfs.ctx, ipfs.config.IPFSRequestTimeout)
+	defer cancel()
+
+	res, err := ipfs.postCtx(ctx, "key/list", "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var keyList ipfsKeyListResp
+	err = json.Unmarshal(res, &keyList)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]string, len(keyList.Keys))
+	for _, key := range keyList.Keys {
+		keys[key.Name] = key.Id
+	}
+	return keys, nil
+}
+
+// log in Hive cluster to recreate user home. The new root is copied to a
+// staging home first, and moved into place once the former home is moved
+// away, so that the home is never missing for longer than two moves.
 func (ipfs *Connector) UidLogin(params []string) error {
 	ctx, cancel := context.WithTimeout(ipfs.ctx, ipfs.config.IPFSRequestTimeout)
 	defer cancel()
@@ -794,19 +1027,44 @@ func (ipfs *Connector) UidLogin(params []string) error {
 		hash = "/ipfs/" + hash
 	}
 
-	url := "files/rm?arg=/nodes/" + uid + "&recursive=true&force=true"
+	home := "/nodes/" + uid
+	staging := "/nodes/" + api.NewStagingHome(uid)
+	old := staging + ".old"
+
+	url := "files/cp?arg=" + hash + "&arg=" + staging
 	_, err := ipfs.postCtx(ctx, url, "", nil)
 	if err != nil {
 		logger.Error(err)
+		return hiveError(err, uid)
 	}
 
-	url = "files/cp?arg=" + hash + "&arg=" + "/nodes/" + uid
+	// There is no former home on the first login.
+	url = "files/mv?arg=" + home + "&arg=" + old
+	_, err = ipfs.postCtx(ctx, url, "", nil)
+	replaced := err == nil
+
+	url = "files/mv?arg=" + staging + "&arg=" + home
 	_, err = ipfs.postCtx(ctx, url, "", nil)
 	if err != nil {
 		logger.Error(err)
+		if replaced {
+			_, rerr := ipfs.postCtx(ctx, "files/mv?arg="+old+"&arg="+home, "", nil)
+			if rerr != nil {
+				logger.Errorf("error restoring the home of %s: %s", uid, rerr)
+			}
+		}
+		ipfs.postCtx(ctx, "files/rm?arg="+staging+"&recursive=true", "", nil)
 		return hiveError(err, uid)
 	}
 
+	if replaced {
+		url = "files/rm?arg=" + old + "&recursive=true&force=true"
+		_, err = ipfs.postCtx(ctx, url, "", nil)
+		if err != nil {
+			// Stale staging homes are removed when the peer starts.
+			logger.Errorf("error removing the former home of %s: %s", uid, err)
+		}
+	}
 	return nil
 }
 
@@ -839,6 +1097,55 @@ func (ipfs *Connector) FileGet(fg []string) ([]byte, error) {
 	return res, nil
 }
 
+// Cat reads up to fc.Length bytes of the file at fc.Path, starting at
+// fc.Offset.
+func (ipfs *Connector) Cat(fc api.FileCat) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ipfs.ctx, ipfs.config.IPFSRequestTimeout)
+	defer cancel()
+	path := fmt.Sprintf(
+		"cat?arg=%s&offset=%d&length=%d",
+		url.QueryEscape(fc.Path),
+		fc.Offset,
+		fc.Length,
+	)
+	return ipfs.postCtx(ctx, path, "", nil)
+}
+
+// Ls lists the entries of the directory at the given IPFS path.
+func (ipfs *Connector) Ls(p string) (api.FilesLs, error) {
+	ctx, cancel := context.WithTimeout(ipfs.ctx, ipfs.config.IPFSRequestTimeout)
+	defer cancel()
+	ls := api.FilesLs{}
+	res, err := ipfs.postCtx(ctx, "ls?arg="+url.QueryEscape(p), "", nil)
+	if err != nil {
+		return ls, err
+	}
+
+	var lsResp ipfsLsResp
+	err = json.Unmarshal(res, &lsResp)
+	if err != nil {
+		return ls, err
+	}
+	if len(lsResp.Objects) == 0 {
+		return ls, fmt.Errorf("no objects listed for %s", p)
+	}
+	ls.Entries = lsResp.Objects[0].Links
+	return ls, nil
+}
+
+// Stat returns the statistics of the given IPFS path.
+func (ipfs *Connector) Stat(p string) (api.FilesStat, error) {
+	ctx, cancel := context.WithTimeout(ipfs.ctx, ipfs.config.IPFSRequestTimeout)
+	defer cancel()
+	stat := api.FilesStat{}
+	res, err := ipfs.postCtx(ctx, "files/stat?arg="+url.QueryEscape(p), "", nil)
+	if err != nil {
+		return stat, err
+	}
+	err = json.Unmarshal(res, &stat)
+	return stat, err
+}
+
 // copy file to Hive
 func (ipfs *Connector) FilesCp(l []string) error {
 	ctx, cancel := context.WithTimeout(ipfs.ctx, ipfs.config.IPFSRequestTimeout)
@@ -1056,3 +1363,18 @@ func (ipfs *Connector) NamePublish(np []string) (api.NamePublish, error) {
 
 	return NamePublish, nil
 }
+
+// NameResolve resolves the given IPNS name recursively.
+func (ipfs *Connector) NameResolve(name string) (string, error) {
+	ctx, cancel := context.WithTimeout(ipfs.ctx, ipfs.config.IPFSRequestTimeout)
+	defer cancel()
+
+	res, err := ipfs.postCtx(ctx, "name/resolve?recursive=true&arg="+url.QueryEscape(name), "", nil)
+	if err != nil {
+		return "", err
+	}
+
+	var resolved ipfsNameResolveResp
+	err = json.Unmarshal(res, &resolved)
+	return resolved.Path, err
+}
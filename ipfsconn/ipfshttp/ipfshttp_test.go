@@ -103,6 +103,59 @@ func TestIPFSPin(t *testing.T) {
 	t.Run("method=refs", func(t *testing.T) { testPin(t, "refs") })
 }
 
+func TestIPFSPinProgress(t *testing.T) {
+	ctx := context.Background()
+	ipfs, mock := testIPFSConnector(t)
+	defer mock.Close()
+	defer ipfs.Shutdown()
+
+	var blocks uint64
+	report := func(n uint64) { blocks = n }
+
+	err := ipfs.pinProgress(ctx, "pin/add?arg="+test.TestCid1+"&progress=true", report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blocks != 1 {
+		t.Error("expected progress to be reported")
+	}
+
+	err = ipfs.pinProgress(ctx, "pin/add?arg="+test.ErrorCid+"&progress=true", report)
+	if err == nil {
+		t.Error("expected an error")
+	}
+
+	n, err := ipfs.refsProgress(ctx, "refs?arg="+test.TestCid1, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Error("expected one reference")
+	}
+}
+
+func TestThrottleProgress(t *testing.T) {
+	var sent []uint64
+	report, flush := throttleProgress(func(n uint64) { sent = append(sent, n) })
+
+	report(1)
+	report(2)
+	report(3)
+	if len(sent) != 1 || sent[0] != 1 {
+		t.Fatal("expected only the first update to be sent:", sent)
+	}
+
+	flush()
+	if len(sent) != 2 || sent[1] != 3 {
+		t.Fatal("expected the last update to be flushed:", sent)
+	}
+
+	flush()
+	if len(sent) != 2 {
+		t.Error("nothing should be sent when no update is pending:", sent)
+	}
+}
+
 func TestIPFSUnpin(t *testing.T) {
 	ctx := context.Background()
 	ipfs, mock := testIPFSConnector(t)
@@ -255,6 +308,49 @@ func TestBlockGet(t *testing.T) {
 	if !bytes.Equal(data, test.TestShardData) {
 		t.Fatal("unexpected data returned")
 	}
+
+	data, err = ipfs.BlockGetLocal(shardCid)
+	if err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(data, test.TestShardData) {
+		t.Fatal("unexpected data returned by BlockGetLocal")
+	}
+}
+
+func TestCatLsStat(t *testing.T) {
+	ipfs, mock := testIPFSConnector(t)
+	defer mock.Close()
+	defer ipfs.Shutdown()
+
+	data, err := ipfs.Cat(api.FileCat{Path: "/ipfs/" + test.TestCid1, Offset: 6, Length: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != test.TestCid1Data[6:10] {
+		t.Errorf("unexpected data: %s", data)
+	}
+
+	ls, err := ipfs.Ls("/ipfs/" + test.TestCid2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ls.Entries) != 1 || ls.Entries[0].Name != "file" || ls.Entries[0].Hash != test.TestCid1 {
+		t.Errorf("unexpected listing: %+v", ls)
+	}
+
+	stat, err := ipfs.Stat("/ipfs/" + test.TestCid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Type != "file" || stat.Size != uint64(len(test.TestCid1Data)) {
+		t.Errorf("unexpected stat: %+v", stat)
+	}
+
+	_, err = ipfs.Stat("/ipfs/" + test.ErrorCid)
+	if err == nil {
+		t.Error("expected an error")
+	}
 }
 
 func TestRepoStat(t *testing.T) {
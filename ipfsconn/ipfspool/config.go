@@ -0,0 +1,125 @@
+package ipfspool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/config"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+const configKey = "ipfspool"
+
+// Default values for Config.
+const (
+	DefaultCheckInterval = 30 * time.Second
+)
+
+// Config is used to initialize a pooled Connector and allows to customize
+// its behaviour. It implements the config.ComponentConfig interface.
+//
+// All other options (timeouts, pin method...) are taken from the
+// "ipfshttp" configuration and apply to every daemon in the pool.
+type Config struct {
+	config.Saver
+
+	// Host/Port for each of the IPFS daemons in the pool. When empty,
+	// the pool is disabled and the single daemon configured in the
+	// "ipfshttp" section is used.
+	NodeAddrs []ma.Multiaddr
+
+	// CheckInterval is how often the daemons in the pool are polled
+	// to find out whether they are reachable and how much free space
+	// they have.
+	CheckInterval time.Duration
+}
+
+type jsonConfig struct {
+	NodeMultiaddresses []string `json:"node_multiaddresses"`
+	CheckInterval      string   `json:"check_interval"`
+}
+
+// ConfigKey provides a human-friendly identifier for this type of Config.
+func (cfg *Config) ConfigKey() string {
+	return configKey
+}
+
+// Default sets the fields of this Config to sensible default values.
+func (cfg *Config) Default() error {
+	cfg.NodeAddrs = []ma.Multiaddr{}
+	cfg.CheckInterval = DefaultCheckInterval
+	return nil
+}
+
+// Validate checks that the fields of this Config have sensible values,
+// at least in appearance.
+func (cfg *Config) Validate() error {
+	if cfg.NodeAddrs == nil {
+		return errors.New("ipfspool.node_multiaddresses not set")
+	}
+
+	if cfg.CheckInterval <= 0 {
+		return errors.New("ipfspool.check_interval invalid")
+	}
+	return nil
+}
+
+// Enabled returns true when there are daemons configured for the pool.
+func (cfg *Config) Enabled() bool {
+	return len(cfg.NodeAddrs) > 0
+}
+
+// LoadJSON parses a JSON representation of this Config as generated by ToJSON.
+func (cfg *Config) LoadJSON(raw []byte) error {
+	jcfg := &jsonConfig{}
+	err := json.Unmarshal(raw, jcfg)
+	if err != nil {
+		logger.Error("Error unmarshaling ipfspool config")
+		return err
+	}
+
+	cfg.Default()
+
+	for _, addr := range jcfg.NodeMultiaddresses {
+		nodeAddr, err := ma.NewMultiaddr(addr)
+		if err != nil {
+			return fmt.Errorf("error parsing node_multiaddresses: %s", err)
+		}
+		cfg.NodeAddrs = append(cfg.NodeAddrs, nodeAddr)
+	}
+
+	err = config.ParseDurations(
+		"ipfspool",
+		&config.DurationOpt{Duration: jcfg.CheckInterval, Dst: &cfg.CheckInterval, Name: "check_interval"},
+	)
+	if err != nil {
+		return err
+	}
+
+	return cfg.Validate()
+}
+
+// ToJSON generates a human-friendly JSON representation of this Config.
+func (cfg *Config) ToJSON() (raw []byte, err error) {
+	// Multiaddress String() may panic
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s", r)
+		}
+	}()
+
+	jcfg := &jsonConfig{
+		NodeMultiaddresses: []string{},
+	}
+
+	for _, addr := range cfg.NodeAddrs {
+		jcfg.NodeMultiaddresses = append(jcfg.NodeMultiaddresses, addr.String())
+	}
+	jcfg.CheckInterval = cfg.CheckInterval.String()
+
+	raw, err = config.DefaultJSONMarshal(jcfg)
+	return
+}
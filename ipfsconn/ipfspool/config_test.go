@@ -0,0 +1,78 @@
+package ipfspool
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var cfgJSON = []byte(`
+{
+      "node_multiaddresses": [
+            "/ip4/127.0.0.1/tcp/5001",
+            "/ip4/127.0.0.1/tcp/5002"
+      ],
+      "check_interval": "10s"
+}
+`)
+
+func TestLoadJSON(t *testing.T) {
+	cfg := &Config{}
+	err := cfg.LoadJSON(cfgJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.NodeAddrs) != 2 || !cfg.Enabled() {
+		t.Error("expected two daemons in the pool")
+	}
+
+	j := &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.NodeMultiaddresses = []string{"abc"}
+	tst, _ := json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error in node_multiaddresses")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.CheckInterval = "-1s"
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error in check_interval")
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	cfg := &Config{}
+	cfg.LoadJSON(cfgJSON)
+	newjson, err := cfg.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = &Config{}
+	err = cfg.LoadJSON(newjson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.NodeAddrs) != 2 {
+		t.Error("expected two daemons in the pool")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	cfg := &Config{}
+	cfg.Default()
+	if cfg.Validate() != nil {
+		t.Fatal("error validating")
+	}
+	if cfg.Enabled() {
+		t.Error("the pool should be disabled by default")
+	}
+
+	cfg.CheckInterval = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+}
@@ -0,0 +1,759 @@
+// Package ipfspool implements an IPFS Cluster IPFSConnector component which
+// fronts a pool of IPFS daemons. Every daemon is handled by an ipfshttp
+// Connector.
+//
+// New pins are placed on the reachable daemon with the most free space,
+// while pin listings and repository statistics are combined across the
+// pool. User homes (/nodes/<uid>) live on a single daemon, so every Uid*,
+// Files* and NamePublish call for a UID is sent to the daemon holding its
+// home. Note that the UID keys are generated in the keystore of that
+// daemon, while the cluster peer reads keys from the keystore in
+// IPFS_PATH. The daemons in the pool must therefore share it, which is
+// checked when the Connector is created.
+//
+// When a daemon goes away, its pins are no longer listed, so the pin
+// tracker will re-pin them on the remaining daemons. The homes it held
+// are unavailable until it is back, and no new homes are created
+// meanwhile, since the missing daemon may hold them already.
+package ipfspool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/ipfsconn/ipfshttp"
+
+	cid "github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+var logger = logging.Logger("ipfspool")
+
+// ErrNoDaemons is returned when none of the daemons in the pool can be
+// reached.
+var ErrNoDaemons = errors.New("no IPFS daemon in the pool is reachable")
+
+// member is one of the IPFS daemons in the pool.
+type member struct {
+	addr string
+	conn *ipfshttp.Connector
+
+	mux  sync.RWMutex
+	up   bool
+	free uint64
+}
+
+func (m *member) isUp() bool {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	return m.up
+}
+
+func (m *member) freeSpace() uint64 {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	return m.free
+}
+
+// check updates the status of the daemon with a "repo stat" request.
+func (m *member) check() {
+	stat, err := m.conn.RepoStat()
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if err != nil {
+		if m.up {
+			logger.Warningf("IPFS daemon %s is down: %s", m.addr, err)
+		}
+		m.up = false
+		return
+	}
+	if !m.up {
+		logger.Infof("IPFS daemon %s is up", m.addr)
+	}
+	m.up = true
+	m.free = 0
+	if stat.StorageMax > stat.RepoSize {
+		m.free = stat.StorageMax - stat.RepoSize
+	}
+}
+
+// Connector implements the IPFSConnector interface on top of a pool of
+// IPFS daemons.
+type Connector struct {
+	ctx    context.Context
+	cancel func()
+
+	config  *Config
+	members []*member
+
+	homesMux sync.Mutex
+	homes    map[string]*member
+
+	shutdownLock sync.Mutex
+	shutdown     bool
+	wg           sync.WaitGroup
+}
+
+// NewConnector creates the component and leaves it ready to be started.
+// A Connector is created for every daemon in the pool, using the given
+// ipfshttp configuration with the node address replaced.
+func NewConnector(httpCfg *ipfshttp.Config, cfg *Config) (*Connector, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.Enabled() {
+		return nil, errors.New("no IPFS daemons configured for the pool")
+	}
+
+	members := make([]*member, 0, len(cfg.NodeAddrs))
+	for _, addr := range cfg.NodeAddrs {
+		mCfg := *httpCfg
+		mCfg.NodeAddr = addr
+		conn, err := ipfshttp.NewConnector(&mCfg)
+		if err != nil {
+			for _, m := range members {
+				m.conn.Shutdown()
+			}
+			return nil, fmt.Errorf("error creating connector for %s: %s", addr, err)
+		}
+		members = append(members, &member{
+			addr: addr.String(),
+			conn: conn,
+			up:   true,
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	pool := &Connector{
+		ctx:     ctx,
+		cancel:  cancel,
+		config:  cfg,
+		members: members,
+		homes:   make(map[string]*member),
+	}
+
+	pool.checkMembers()
+	err = pool.checkKeystores()
+	if err != nil {
+		cancel()
+		for _, m := range members {
+			m.conn.Shutdown()
+		}
+		return nil, err
+	}
+
+	pool.wg.Add(1)
+	go pool.run()
+	return pool, nil
+}
+
+// checkKeystores verifies that the reachable daemons share their
+// keystore, that is, that they hold the same keys besides their own
+// identity.
+func (pool *Connector) checkKeystores() error {
+	var first *member
+	var firstKeys map[string]string
+	for _, m := range pool.upMembers() {
+		keys, err := m.conn.KeyList()
+		if err != nil {
+			return fmt.Errorf("error listing the keys of %s: %s", m.addr, err)
+		}
+		delete(keys, "self")
+		if first == nil {
+			first, firstKeys = m, keys
+			continue
+		}
+		if !sameKeys(firstKeys, keys) {
+			return fmt.Errorf("the IPFS daemons %s and %s do not share their keystore", first.addr, m.addr)
+		}
+	}
+	return nil
+}
+
+func sameKeys(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, id := range a {
+		if b[name] != id {
+			return false
+		}
+	}
+	return true
+}
+
+// run checks the daemons in the pool every CheckInterval.
+func (pool *Connector) run() {
+	defer pool.wg.Done()
+
+	ticker := time.NewTicker(pool.config.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-pool.ctx.Done():
+			return
+		case <-ticker.C:
+			pool.checkMembers()
+		}
+	}
+}
+
+// checkMembers updates the status of all the daemons in parallel.
+func (pool *Connector) checkMembers() {
+	var wg sync.WaitGroup
+	for _, m := range pool.members {
+		wg.Add(1)
+		go func(m *member) {
+			defer wg.Done()
+			m.check()
+		}(m)
+	}
+	wg.Wait()
+}
+
+// SetClient makes the component ready to perform RPC requests.
+func (pool *Connector) SetClient(c *rpc.Client) {
+	for _, m := range pool.members {
+		m.conn.SetClient(c)
+	}
+}
+
+// Shutdown stops the checks and the connectors of every daemon in the
+// pool.
+func (pool *Connector) Shutdown() error {
+	pool.shutdownLock.Lock()
+	defer pool.shutdownLock.Unlock()
+
+	if pool.shutdown {
+		logger.Debug("already shutdown")
+		return nil
+	}
+
+	logger.Info("stopping IPFS pool Connector")
+
+	pool.cancel()
+	pool.wg.Wait()
+
+	for _, m := range pool.members {
+		m.conn.Shutdown()
+	}
+	pool.shutdown = true
+	return nil
+}
+
+// upMembers returns the daemons which were reachable on their last check.
+func (pool *Connector) upMembers() []*member {
+	var up []*member
+	for _, m := range pool.members {
+		if m.isUp() {
+			up = append(up, m)
+		}
+	}
+	return up
+}
+
+// mostFree returns the reachable daemon with the most free space.
+func (pool *Connector) mostFree() (*member, error) {
+	var best *member
+	for _, m := range pool.upMembers() {
+		if best == nil || m.freeSpace() > best.freeSpace() {
+			best = m
+		}
+	}
+	if best == nil {
+		return nil, ErrNoDaemons
+	}
+	return best, nil
+}
+
+// each runs f on every reachable daemon until it succeeds. A daemon is
+// checked when f fails on it, so that it is skipped from then on if it
+// has gone away.
+func (pool *Connector) each(f func(m *member) error) error {
+	err := ErrNoDaemons
+	for _, m := range pool.upMembers() {
+		err = f(m)
+		if err == nil {
+			return nil
+		}
+		m.check()
+	}
+	return err
+}
+
+// homeMember returns the daemon holding the home of the given UID. When
+// no daemon holds it, the one with the most free space is returned. It
+// is remembered as the home of the UID when create is true, which fails
+// when any daemon is unreachable, since the home may be held by it.
+// Staging homes are not placed: they live with the home they stage, so
+// that they can be moved into its place.
+func (pool *Connector) homeMember(uid string, create bool) (*member, error) {
+	if uid == "" {
+		return pool.mostFree()
+	}
+	if api.IsStagingHome(uid) {
+		return pool.homeMember(api.StagedUID(uid), false)
+	}
+
+	pool.homesMux.Lock()
+	defer pool.homesMux.Unlock()
+
+	if m, ok := pool.homes[uid]; ok {
+		if !m.isUp() {
+			return nil, fmt.Errorf("the IPFS daemon holding the home of %s is not reachable", uid)
+		}
+		return m, nil
+	}
+
+	for _, m := range pool.upMembers() {
+		_, err := m.conn.FilesStat([]string{uid, "", "", "", "", ""})
+		if err == nil {
+			pool.homes[uid] = m
+			return m, nil
+		}
+	}
+
+	m, err := pool.mostFree()
+	if err != nil {
+		return nil, err
+	}
+	if create {
+		for _, other := range pool.members {
+			if !other.isUp() {
+				return nil, fmt.Errorf("cannot create the home of %s: the IPFS daemon %s is not reachable and may hold it", uid, other.addr)
+			}
+		}
+		pool.homes[uid] = m
+	}
+	return m, nil
+}
+
+// ID returns the ID of the first reachable daemon in the pool.
+func (pool *Connector) ID() (api.IPFSID, error) {
+	id := api.IPFSID{Error: ErrNoDaemons.Error()}
+	err := pool.each(func(m *member) error {
+		var err error
+		id, err = m.conn.ID()
+		return err
+	})
+	return id, err
+}
+
+// Pin pins the given Cid on the daemon with the most free space, unless
+// it is already pinned on any of them.
+func (pool *Connector) Pin(ctx context.Context, hash cid.Cid, maxDepth int) error {
+	for _, m := range pool.upMembers() {
+		status, err := m.conn.PinLsCid(ctx, hash)
+		if err != nil {
+			m.check()
+			continue
+		}
+		if status.IsPinned(maxDepth) {
+			logger.Debugf("IPFS object is already pinned in %s: %s", m.addr, hash)
+			return nil
+		}
+	}
+
+	m, err := pool.mostFree()
+	if err != nil {
+		return err
+	}
+	logger.Debugf("pinning %s in %s", hash, m.addr)
+	return m.conn.Pin(ctx, hash, maxDepth)
+}
+
+// Unpin unpins the given Cid from every daemon in the pool.
+func (pool *Connector) Unpin(ctx context.Context, hash cid.Cid) error {
+	up := pool.upMembers()
+	if len(up) == 0 {
+		return ErrNoDaemons
+	}
+
+	var err error
+	for _, m := range up {
+		if e := m.conn.Unpin(ctx, hash); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// PinLsCid returns the status of the given Cid in the pool. It is pinned
+// if it is pinned on any of the daemons.
+func (pool *Connector) PinLsCid(ctx context.Context, hash cid.Cid) (api.IPFSPinStatus, error) {
+	status := api.IPFSPinStatusError
+	err := ErrNoDaemons
+	for _, m := range pool.upMembers() {
+		st, e := m.conn.PinLsCid(ctx, hash)
+		if e != nil {
+			m.check()
+			if status == api.IPFSPinStatusError {
+				err = e
+			}
+			continue
+		}
+		if st != api.IPFSPinStatusUnpinned {
+			return st, nil
+		}
+		status, err = st, nil
+	}
+	return status, err
+}
+
+// PinLs combines the pins of every reachable daemon in the pool.
+// Recursive pins take precedence over any other type.
+func (pool *Connector) PinLs(ctx context.Context, typeFilter string) (map[string]api.IPFSPinStatus, error) {
+	var statusMap map[string]api.IPFSPinStatus
+	err := ErrNoDaemons
+	for _, m := range pool.upMembers() {
+		pins, e := m.conn.PinLs(ctx, typeFilter)
+		if e != nil {
+			m.check()
+			err = e
+			continue
+		}
+		if statusMap == nil {
+			statusMap = make(map[string]api.IPFSPinStatus)
+		}
+		for k, v := range pins {
+			if _, ok := statusMap[k]; !ok || v == api.IPFSPinStatusRecursive {
+				statusMap[k] = v
+			}
+		}
+	}
+	if statusMap == nil {
+		return nil, err
+	}
+	return statusMap, nil
+}
+
+// ConnectSwarms connects the daemons in the pool to the IPFS daemons of
+// other cluster peers.
+func (pool *Connector) ConnectSwarms() error {
+	var err error
+	for _, m := range pool.upMembers() {
+		if e := m.conn.ConnectSwarms(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// SwarmPeers returns the peers connected to any of the daemons in the
+// pool.
+func (pool *Connector) SwarmPeers() (api.SwarmPeers, error) {
+	var swarm api.SwarmPeers
+	seen := make(map[peer.ID]struct{})
+	err := ErrNoDaemons
+	for _, m := range pool.upMembers() {
+		peers, e := m.conn.SwarmPeers()
+		if e != nil {
+			err = e
+			continue
+		}
+		err = nil
+		for _, p := range peers {
+			if _, ok := seen[p]; ok {
+				continue
+			}
+			seen[p] = struct{}{}
+			swarm = append(swarm, p)
+		}
+	}
+	if swarm != nil {
+		return swarm, nil
+	}
+	return swarm, err
+}
+
+// ConfigKey returns the value of a configuration key in the first
+// reachable daemon in the pool.
+func (pool *Connector) ConfigKey(keypath string) (interface{}, error) {
+	var v interface{}
+	err := pool.each(func(m *member) error {
+		var err error
+		v, err = m.conn.ConfigKey(keypath)
+		return err
+	})
+	return v, err
+}
+
+// RepoStat returns the sum of the repository sizes and limits of the
+// reachable daemons in the pool.
+func (pool *Connector) RepoStat() (api.IPFSRepoStat, error) {
+	var total api.IPFSRepoStat
+	ok := false
+	err := ErrNoDaemons
+	for _, m := range pool.upMembers() {
+		stat, e := m.conn.RepoStat()
+		if e != nil {
+			m.check()
+			err = e
+			continue
+		}
+		ok = true
+		total.RepoSize += stat.RepoSize
+		total.StorageMax += stat.StorageMax
+	}
+	if !ok {
+		return total, err
+	}
+	return total, nil
+}
+
+// BlockPut puts the block in the daemon with the most free space.
+func (pool *Connector) BlockPut(b api.NodeWithMeta) error {
+	m, err := pool.mostFree()
+	if err != nil {
+		return err
+	}
+	return m.conn.BlockPut(b)
+}
+
+// BlockGet retrieves the block from the first daemon able to provide it.
+func (pool *Connector) BlockGet(c cid.Cid) ([]byte, error) {
+	var data []byte
+	err := pool.each(func(m *member) error {
+		var err error
+		data, err = m.conn.BlockGet(c)
+		return err
+	})
+	return data, err
+}
+
+// BlockGetLocal retrieves the block from the first daemon holding it.
+func (pool *Connector) BlockGetLocal(c cid.Cid) ([]byte, error) {
+	var data []byte
+	err := ErrNoDaemons
+	for _, m := range pool.upMembers() {
+		data, err = m.conn.BlockGetLocal(c)
+		if err == nil {
+			return data, nil
+		}
+	}
+	return nil, err
+}
+
+// UidNew creates the new UID in the daemon with the most free space,
+// which becomes its home.
+func (pool *Connector) UidNew(name string) (api.UIDSecret, error) {
+	m, err := pool.homeMember(name, true)
+	if err != nil {
+		return api.UIDSecret{}, err
+	}
+	return m.conn.UidNew(name)
+}
+
+// UidRenew renames the UID in its home daemon.
+func (pool *Connector) UidRenew(l []string) (api.UIDRenew, error) {
+	m, err := pool.homeMember(l[0], false)
+	if err != nil {
+		return api.UIDRenew{}, err
+	}
+	res, err := m.conn.UidRenew(l)
+	if err != nil {
+		return res, err
+	}
+
+	pool.homesMux.Lock()
+	delete(pool.homes, l[0])
+	pool.homes[l[1]] = m
+	pool.homesMux.Unlock()
+	return res, nil
+}
+
+// UidInfo returns the UID information from its home daemon.
+func (pool *Connector) UidInfo(uid string) (api.UIDSecret, error) {
+	m, err := pool.homeMember(uid, false)
+	if err != nil {
+		return api.UIDSecret{}, err
+	}
+	return m.conn.UidInfo(uid)
+}
+
+// UidLogin recreates the home of the UID in its home daemon, or in the
+// daemon with the most free space if it has none yet.
+func (pool *Connector) UidLogin(params []string) error {
+	m, err := pool.homeMember(params[0], true)
+	if err != nil {
+		return err
+	}
+	return m.conn.UidLogin(params)
+}
+
+// FileGet downloads the given path from the first daemon able to
+// provide it.
+func (pool *Connector) FileGet(fg []string) ([]byte, error) {
+	var res []byte
+	err := pool.each(func(m *member) error {
+		var err error
+		res, err = m.conn.FileGet(fg)
+		return err
+	})
+	return res, err
+}
+
+// Cat reads the file from the first daemon able to provide it.
+func (pool *Connector) Cat(fc api.FileCat) ([]byte, error) {
+	var res []byte
+	err := pool.each(func(m *member) error {
+		var err error
+		res, err = m.conn.Cat(fc)
+		return err
+	})
+	return res, err
+}
+
+// Ls lists the directory from the first daemon able to provide it.
+func (pool *Connector) Ls(p string) (api.FilesLs, error) {
+	var ls api.FilesLs
+	err := pool.each(func(m *member) error {
+		var err error
+		ls, err = m.conn.Ls(p)
+		return err
+	})
+	return ls, err
+}
+
+// Stat returns the statistics from the first daemon able to provide
+// them.
+func (pool *Connector) Stat(p string) (api.FilesStat, error) {
+	var stat api.FilesStat
+	err := pool.each(func(m *member) error {
+		var err error
+		stat, err = m.conn.Stat(p)
+		return err
+	})
+	return stat, err
+}
+
+// FilesCp copies into the home of the UID.
+func (pool *Connector) FilesCp(l []string) error {
+	m, err := pool.homeMember(l[0], true)
+	if err != nil {
+		return err
+	}
+	return m.conn.FilesCp(l)
+}
+
+// FilesFlush flushes a path in the home of the UID.
+func (pool *Connector) FilesFlush(l []string) error {
+	m, err := pool.homeMember(l[0], false)
+	if err != nil {
+		return err
+	}
+	return m.conn.FilesFlush(l)
+}
+
+// FilesLs lists a path in the home of the UID. When no UID is given, the
+// homes in all the reachable daemons are listed.
+func (pool *Connector) FilesLs(l []string) (api.FilesLs, error) {
+	if l[0] != "" {
+		m, err := pool.homeMember(l[0], false)
+		if err != nil {
+			return api.FilesLs{}, err
+		}
+		return m.conn.FilesLs(l)
+	}
+
+	var ls api.FilesLs
+	ok := false
+	err := ErrNoDaemons
+	for _, m := range pool.upMembers() {
+		res, e := m.conn.FilesLs(l)
+		if e != nil {
+			err = e
+			continue
+		}
+		ok = true
+		ls.Entries = append(ls.Entries, res.Entries...)
+	}
+	if !ok {
+		return ls, err
+	}
+	return ls, nil
+}
+
+// FilesMkdir creates a directory in the home of the UID.
+func (pool *Connector) FilesMkdir(mk []string) error {
+	m, err := pool.homeMember(mk[0], true)
+	if err != nil {
+		return err
+	}
+	return m.conn.FilesMkdir(mk)
+}
+
+// FilesMv moves a file within the home of the UID.
+func (pool *Connector) FilesMv(mv []string) error {
+	m, err := pool.homeMember(mv[0], false)
+	if err != nil {
+		return err
+	}
+	return m.conn.FilesMv(mv)
+}
+
+// FilesRead reads a file in the home of the UID.
+func (pool *Connector) FilesRead(l []string) ([]byte, error) {
+	m, err := pool.homeMember(l[0], false)
+	if err != nil {
+		return nil, err
+	}
+	return m.conn.FilesRead(l)
+}
+
+// FilesRm removes a path in the home of the UID.
+func (pool *Connector) FilesRm(rm []string) error {
+	m, err := pool.homeMember(rm[0], false)
+	if err != nil {
+		return err
+	}
+	return m.conn.FilesRm(rm)
+}
+
+// FilesStat returns the statistics of a path in the home of the UID.
+func (pool *Connector) FilesStat(st []string) (api.FilesStat, error) {
+	m, err := pool.homeMember(st[0], false)
+	if err != nil {
+		return api.FilesStat{}, err
+	}
+	return m.conn.FilesStat(st)
+}
+
+// FilesWrite writes a file in the home of the UID.
+func (pool *Connector) FilesWrite(fr api.FilesWrite) error {
+	m, err := pool.homeMember(fr.Params[0], true)
+	if err != nil {
+		return err
+	}
+	return m.conn.FilesWrite(fr)
+}
+
+// NamePublish publishes a path with the key of the UID, which lives in
+// its home daemon.
+func (pool *Connector) NamePublish(np []string) (api.NamePublish, error) {
+	m, err := pool.homeMember(np[0], false)
+	if err != nil {
+		return api.NamePublish{}, err
+	}
+	return m.conn.NamePublish(np)
+}
+
+// NameResolve resolves the name with the first daemon able to do it.
+func (pool *Connector) NameResolve(name string) (string, error) {
+	var resolved string
+	err := pool.each(func(m *member) error {
+		var err error
+		resolved, err = m.conn.NameResolve(name)
+		return err
+	})
+	return resolved, err
+}
@@ -0,0 +1,245 @@
+package ipfspool
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/ipfsconn/ipfshttp"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/test"
+
+	cid "github.com/ipfs/go-cid"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func testPool(t *testing.T) (*Connector, []*test.IpfsMock) {
+	mocks := []*test.IpfsMock{test.NewIpfsMock(), test.NewIpfsMock()}
+
+	httpCfg := &ipfshttp.Config{}
+	httpCfg.Default()
+	httpCfg.ConnectSwarmsDelay = 0
+	httpCfg.HealthInterval = 0
+
+	cfg := &Config{}
+	cfg.Default()
+	for _, mock := range mocks {
+		addr, _ := ma.NewMultiaddr(fmt.Sprintf("/ip4/%s/tcp/%d", mock.Addr, mock.Port))
+		cfg.NodeAddrs = append(cfg.NodeAddrs, addr)
+	}
+
+	pool, err := NewConnector(httpCfg, cfg)
+	if err != nil {
+		t.Fatal("creating a pooled IPFSConnector should work: ", err)
+	}
+	pool.SetClient(test.NewMockRPCClient(t))
+	pool.checkMembers()
+	return pool, mocks
+}
+
+func closeMocks(mocks []*test.IpfsMock) {
+	for _, m := range mocks {
+		m.Close()
+	}
+}
+
+func TestNewConnector(t *testing.T) {
+	pool, mocks := testPool(t)
+	defer closeMocks(mocks)
+	defer pool.Shutdown()
+
+	if len(pool.upMembers()) != 2 {
+		t.Error("expected two daemons up")
+	}
+
+	_, err := NewConnector(&ipfshttp.Config{}, &Config{CheckInterval: DefaultCheckInterval})
+	if err == nil {
+		t.Error("expected an error without daemons")
+	}
+}
+
+func TestNewConnectorKeystores(t *testing.T) {
+	mocks := []*test.IpfsMock{test.NewIpfsMock(), test.NewIpfsMock()}
+	defer closeMocks(mocks)
+	mocks[0].Keys["uid-test"] = test.TestPeerID2.Pretty()
+
+	httpCfg := &ipfshttp.Config{}
+	httpCfg.Default()
+	cfg := &Config{}
+	cfg.Default()
+	for _, mock := range mocks {
+		addr, _ := ma.NewMultiaddr(fmt.Sprintf("/ip4/%s/tcp/%d", mock.Addr, mock.Port))
+		cfg.NodeAddrs = append(cfg.NodeAddrs, addr)
+	}
+
+	_, err := NewConnector(httpCfg, cfg)
+	if err == nil {
+		t.Error("expected an error when the daemons do not share their keystore")
+	}
+
+	mocks[1].Keys["uid-test"] = test.TestPeerID2.Pretty()
+	pool, err := NewConnector(httpCfg, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Shutdown()
+}
+
+func TestPinSpreadsByFreeSpace(t *testing.T) {
+	ctx := context.Background()
+	pool, mocks := testPool(t)
+	defer closeMocks(mocks)
+	defer pool.Shutdown()
+
+	c, _ := cid.Decode(test.TestCid1)
+	c2, _ := cid.Decode(test.TestCid2)
+
+	err := pool.members[0].conn.Pin(ctx, c, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.checkMembers()
+
+	err = pool.Pin(ctx, c2, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := pool.members[1].conn.PinLsCid(ctx, c2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !st.IsPinned(-1) {
+		t.Error("the pin should go to the daemon with most free space")
+	}
+
+	// Pinning again is a no-op
+	err = pool.Pin(ctx, c, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, _ = pool.members[1].conn.PinLsCid(ctx, c)
+	if st.IsPinned(-1) {
+		t.Error("an existing pin should not be pinned again")
+	}
+
+	pins, err := pool.PinLs(ctx, "recursive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 2 {
+		t.Error("PinLs should combine the pins of all daemons")
+	}
+
+	st, err = pool.PinLsCid(ctx, c2)
+	if err != nil || !st.IsPinned(-1) {
+		t.Error("c2 should be pinned in the pool")
+	}
+
+	stat, err := pool.RepoStat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.RepoSize != 2000 || stat.StorageMax != 20000000000 {
+		t.Error("RepoStat should add up the stats of all daemons:", stat)
+	}
+
+	err = pool.Unpin(ctx, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, _ = pool.PinLsCid(ctx, c)
+	if st != api.IPFSPinStatusUnpinned {
+		t.Error("c should have been unpinned")
+	}
+}
+
+func TestStagingHomeMember(t *testing.T) {
+	pool, mocks := testPool(t)
+	defer closeMocks(mocks)
+	defer pool.Shutdown()
+
+	home, err := pool.homeMember("uid-test", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	staging := api.NewStagingHome("uid-test")
+	m, err := pool.homeMember(staging, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m != home {
+		t.Error("staging homes should live with the home they stage")
+	}
+	if _, ok := pool.homes[staging]; ok {
+		t.Error("staging homes should not be remembered as homes")
+	}
+}
+
+func TestDaemonDown(t *testing.T) {
+	ctx := context.Background()
+	pool, mocks := testPool(t)
+	defer closeMocks(mocks)
+	defer pool.Shutdown()
+
+	home, err := pool.homeMember("uid-test", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var downIdx int
+	for i, m := range pool.members {
+		if m == home {
+			downIdx = i
+		}
+	}
+	mocks[downIdx].Close()
+	pool.checkMembers()
+
+	if len(pool.upMembers()) != 1 {
+		t.Fatal("expected one daemon up")
+	}
+
+	_, err = pool.homeMember("uid-test", false)
+	if err == nil {
+		t.Error("expected an error when the home daemon is down")
+	}
+
+	m, err := pool.homeMember("uid-other", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m == home {
+		t.Error("new homes should not be placed in a daemon which is down")
+	}
+
+	_, err = pool.homeMember("uid-other", true)
+	if err == nil {
+		t.Error("homes should not be created while a daemon is down")
+	}
+
+	c, _ := cid.Decode(test.TestCid1)
+	err = pool.Pin(ctx, c, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pins, err := pool.PinLs(ctx, "recursive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 1 {
+		t.Error("expected one pin")
+	}
+
+	_, err = pool.ID()
+	if err != nil {
+		t.Error("ID should be provided by the remaining daemon:", err)
+	}
+
+	mocks[1-downIdx].Close()
+	pool.checkMembers()
+	_, err = pool.RepoStat()
+	if err != ErrNoDaemons {
+		t.Error("expected ErrNoDaemons:", err)
+	}
+}
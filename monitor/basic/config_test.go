@@ -55,3 +55,28 @@ func TestDefault(t *testing.T) {
 		t.Fatal("expected error validating")
 	}
 }
+
+func TestHistoryConfig(t *testing.T) {
+	cfg := &Config{}
+	err := cfg.LoadJSON([]byte(`{"check_interval": "15s", "window_cap": 100, "history_file": "history.json"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.WindowCap != 100 {
+		t.Error("error parsing window_cap")
+	}
+
+	cfg.BaseDir = "/base"
+	if cfg.GetHistoryPath() != "/base/history.json" {
+		t.Error("unexpected history path:", cfg.GetHistoryPath())
+	}
+	cfg.HistoryFile = ""
+	if cfg.GetHistoryPath() != "" {
+		t.Error("history should not be saved without history_file")
+	}
+
+	cfg.WindowCap = 0
+	if cfg.Validate() == nil {
+		t.Error("expected error validating window_cap")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/monitor/metrics"
@@ -48,7 +49,13 @@ func NewMonitor(cfg *Config) (*Monitor, error) {
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	mtrs := metrics.NewStore()
+	mtrs := metrics.NewStoreWithCap(cfg.WindowCap)
+	if path := cfg.GetHistoryPath(); path != "" {
+		err = mtrs.LoadFile(path)
+		if err != nil {
+			logger.Errorf("error loading metrics history: %s", err)
+		}
+	}
 	checker := metrics.NewChecker(mtrs)
 
 	mon := &Monitor{
@@ -61,6 +68,8 @@ func NewMonitor(cfg *Config) (*Monitor, error) {
 		config:  cfg,
 	}
 
+	mon.wg.Add(1)
+	go mon.saveHistory()
 	go mon.run()
 	return mon, nil
 }
@@ -73,6 +82,35 @@ func (mon *Monitor) run() {
 	}
 }
 
+// saveHistory writes the metrics to the history file on every check
+// interval and when the monitor shuts down.
+func (mon *Monitor) saveHistory() {
+	defer mon.wg.Done()
+
+	path := mon.config.GetHistoryPath()
+	if path == "" {
+		return
+	}
+
+	ticker := time.NewTicker(mon.config.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-mon.ctx.Done():
+			err := mon.metrics.SaveFile(path)
+			if err != nil {
+				logger.Errorf("error saving metrics history: %s", err)
+			}
+			return
+		}
+		err := mon.metrics.SaveFile(path)
+		if err != nil {
+			logger.Errorf("error saving metrics history: %s", err)
+		}
+	}
+}
+
 // SetClient saves the given rpc.Client  for later use
 func (mon *Monitor) SetClient(c *rpc.Client) {
 	mon.rpcClient = c
@@ -195,8 +233,20 @@ func (mon *Monitor) LatestMetrics(name string) []api.Metric {
 	return metrics.PeersetFilter(latest, peers)
 }
 
+// MetricsHistory returns the recent metrics of a given type for every
+// peer which has sent them, including expired and invalid ones and peers
+// which are no longer part of the cluster.
+func (mon *Monitor) MetricsHistory(name string) []api.MetricHistory {
+	return mon.metrics.History(name)
+}
+
 // Alerts returns a channel on which alerts are sent when the
 // monitor detects a failure.
 func (mon *Monitor) Alerts() <-chan api.Alert {
 	return mon.checker.Alerts()
 }
+
+// SendAlert delivers the given alert on the Alerts channel.
+func (mon *Monitor) SendAlert(alrt api.Alert) error {
+	return mon.checker.SendAlert(alrt)
+}
@@ -3,6 +3,8 @@ package basic
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"strconv"
 	"sync"
 	"testing"
@@ -191,6 +193,56 @@ func TestPeerMonitorLogMetric(t *testing.T) {
 	}
 }
 
+func TestPeerMonitorMetricsHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monitor-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mock := test.NewMockRPCClient(t)
+	cfg := &Config{}
+	cfg.Default()
+	cfg.BaseDir = dir
+	cfg.HistoryFile = "history.json"
+	cfg.WindowCap = 2
+	pm, err := NewMonitor(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm.SetClient(mock)
+
+	mf := newMetricFactory()
+	pm.LogMetric(mf.newMetric("test", test.TestPeerID1))
+	pm.LogMetric(mf.newMetric("test", test.TestPeerID1))
+	pm.LogMetric(mf.newMetric("test", test.TestPeerID1))
+	pm.LogMetric(mf.newMetric("test", test.TestPeerID2))
+
+	history := pm.MetricsHistory("test")
+	if len(history) != 2 {
+		t.Fatal("expected history for 2 peers")
+	}
+	for _, h := range history {
+		if h.Peer == test.TestPeerID1 && (len(h.Samples) != 2 || h.Min != 1 || h.Max != 2) {
+			t.Errorf("unexpected history for peer 1: %+v", h)
+		}
+	}
+
+	pm.Shutdown()
+
+	pm, err = NewMonitor(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pm.Shutdown()
+	pm.SetClient(mock)
+
+	history = pm.MetricsHistory("test")
+	if len(history) != 2 {
+		t.Fatal("history should have been restored")
+	}
+}
+
 func TestPeerMonitorPublishMetric(t *testing.T) {
 	h, err := libp2p.New(context.Background())
 	if err != nil {
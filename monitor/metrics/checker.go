@@ -49,10 +49,15 @@ func (mc *Checker) CheckPeers(peers []peer.ID) error {
 }
 
 func (mc *Checker) alert(pid peer.ID, metricName string) error {
-	alrt := api.Alert{
+	return mc.SendAlert(api.Alert{
 		Peer:       pid,
 		MetricName: metricName,
-	}
+	})
+}
+
+// SendAlert places the given alert in the alerts channel. It returns
+// ErrAlertChannelFull if the channel cannot take it.
+func (mc *Checker) SendAlert(alrt api.Alert) error {
 	select {
 	case mc.alertCh <- alrt:
 	default:
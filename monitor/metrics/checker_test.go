@@ -81,3 +81,25 @@ func TestCheckerWatch(t *testing.T) {
 		t.Fatal("should have received an alert")
 	}
 }
+
+func TestCheckerSendAlert(t *testing.T) {
+	checker := NewChecker(NewStore())
+
+	alrt := api.Alert{
+		Peer:       test.TestPeerID1,
+		MetricName: "pin_health",
+	}
+	err := checker.SendAlert(alrt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case a := <-checker.Alerts():
+		if a != alrt {
+			t.Error("received a different alert")
+		}
+	default:
+		t.Error("an alert should have been delivered")
+	}
+}
@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+// savedMetric is the on-disk representation of a metric in a window.
+type savedMetric struct {
+	Name   string    `json:"name"`
+	Peer   string    `json:"peer"`
+	Value  string    `json:"value"`
+	Expire int64     `json:"expire"`
+	Valid  bool      `json:"valid"`
+	Added  time.Time `json:"added"`
+}
+
+// SaveFile writes all the metrics in the store to the given file, so
+// that they can be restored with LoadFile. The file is replaced
+// atomically.
+func (mtrs *Store) SaveFile(path string) error {
+	mtrs.mux.RLock()
+	var saved []savedMetric
+	for _, byPeer := range mtrs.byName {
+		for _, window := range byPeer {
+			metrics, added := window.ordered()
+			for i, m := range metrics {
+				saved = append(saved, savedMetric{
+					Name:   m.Name,
+					Peer:   peer.IDB58Encode(m.Peer),
+					Value:  m.Value,
+					Expire: m.Expire,
+					Valid:  m.Valid,
+					Added:  added[i],
+				})
+			}
+		}
+	}
+	mtrs.mux.RUnlock()
+
+	raw, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	err = ioutil.WriteFile(tmp, raw, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadFile adds the metrics saved with SaveFile to the store. It does
+// nothing if the file does not exist. The restored metrics are not
+// returned by Latest or PeerMetrics, so that they do not trigger alerts
+// or affect allocations, until new metrics are added.
+func (mtrs *Store) LoadFile(path string) error {
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var saved []savedMetric
+	err = json.Unmarshal(raw, &saved)
+	if err != nil {
+		return err
+	}
+
+	mtrs.mux.Lock()
+	defer mtrs.mux.Unlock()
+	for _, sm := range saved {
+		pid, err := peer.IDB58Decode(sm.Peer)
+		if err != nil {
+			return err
+		}
+		m := api.Metric{
+			Name:   sm.Name,
+			Peer:   pid,
+			Value:  sm.Value,
+			Expire: sm.Expire,
+			Valid:  sm.Valid,
+		}
+		window := mtrs.window(m)
+		window.AddAt(m, sm.Added)
+		window.restored = true
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package metrics
 
 import (
+	"sort"
+	"strconv"
 	"sync"
 
 	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
@@ -13,14 +15,26 @@ type PeerMetrics map[peer.ID]*Window
 
 // Store can be used to store and access metrics.
 type Store struct {
-	mux    sync.RWMutex
-	byName map[string]PeerMetrics
+	mux       sync.RWMutex
+	byName    map[string]PeerMetrics
+	windowCap int
 }
 
-// NewStore can be used to create a Store.
+// NewStore can be used to create a Store. It keeps DefaultWindowCap
+// metrics per peer and metric name.
 func NewStore() *Store {
+	return NewStoreWithCap(DefaultWindowCap)
+}
+
+// NewStoreWithCap creates a Store which keeps the given number of
+// metrics per peer and metric name.
+func NewStoreWithCap(windowCap int) *Store {
+	if windowCap <= 0 {
+		windowCap = DefaultWindowCap
+	}
 	return &Store{
-		byName: make(map[string]PeerMetrics),
+		byName:    make(map[string]PeerMetrics),
+		windowCap: windowCap,
 	}
 }
 
@@ -28,7 +42,12 @@ func NewStore() *Store {
 func (mtrs *Store) Add(m api.Metric) {
 	mtrs.mux.Lock()
 	defer mtrs.mux.Unlock()
+	mtrs.window(m).Add(m)
+}
 
+// window returns the window for the peer and name of the given metric,
+// creating it if needed. The caller must hold the lock.
+func (mtrs *Store) window(m api.Metric) *Window {
 	name := m.Name
 	peer := m.Peer
 	mbyp, ok := mtrs.byName[name]
@@ -40,15 +59,15 @@ func (mtrs *Store) Add(m api.Metric) {
 	if !ok {
 		// We always lock the outer map, so we can use unsafe
 		// Window.
-		window = NewWindow(DefaultWindowCap)
+		window = NewWindow(mtrs.windowCap)
 		mbyp[peer] = window
 	}
-
-	window.Add(m)
+	return window
 }
 
 // Latest returns all the last known valid metrics. A metric is valid
-// if it has not expired.
+// if it has not expired. Metrics restored from disk are only part of
+// the History until a new metric arrives from the same peer.
 func (mtrs *Store) Latest(name string) []api.Metric {
 	mtrs.mux.RLock()
 	defer mtrs.mux.RUnlock()
@@ -60,6 +79,9 @@ func (mtrs *Store) Latest(name string) []api.Metric {
 
 	metrics := make([]api.Metric, 0, len(byPeer))
 	for _, window := range byPeer {
+		if window.restored {
+			continue
+		}
 		m, err := window.Latest()
 		if err != nil || m.Discard() {
 			continue
@@ -79,7 +101,7 @@ func (mtrs *Store) PeerMetrics(pid peer.ID) []api.Metric {
 
 	for _, byPeer := range mtrs.byName {
 		window, ok := byPeer[pid]
-		if !ok {
+		if !ok || window.restored {
 			continue
 		}
 		metric, err := window.Latest()
@@ -90,3 +112,71 @@ func (mtrs *Store) PeerMetrics(pid peer.ID) []api.Metric {
 	}
 	return result
 }
+
+// History returns the recent values of the metrics with the given name
+// for every peer, including expired and invalid ones, along with some
+// aggregates. Results are sorted by peer.
+func (mtrs *Store) History(name string) []api.MetricHistory {
+	mtrs.mux.RLock()
+	defer mtrs.mux.RUnlock()
+
+	byPeer, ok := mtrs.byName[name]
+	if !ok {
+		return []api.MetricHistory{}
+	}
+
+	history := make([]api.MetricHistory, 0, len(byPeer))
+	for pid, window := range byPeer {
+		history = append(history, NewHistory(name, pid, window.Samples()))
+	}
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Peer < history[j].Peer
+	})
+	return history
+}
+
+// NewHistory builds a MetricHistory from the given samples, oldest
+// first, and computes the minimum, maximum, average and rate of change
+// of those which are valid and hold a number.
+func NewHistory(name string, pid peer.ID, samples []api.MetricSample) api.MetricHistory {
+	h := api.MetricHistory{
+		Name:    name,
+		Peer:    pid,
+		Samples: samples,
+	}
+
+	var first, last api.MetricSample
+	var firstV, lastV, sum float64
+	n := 0
+	for _, s := range samples {
+		if !s.Valid {
+			continue
+		}
+		v, err := strconv.ParseFloat(s.Value, 64)
+		if err != nil {
+			continue
+		}
+		if n == 0 {
+			first, firstV = s, v
+			h.Min, h.Max = v, v
+		}
+		if v < h.Min {
+			h.Min = v
+		}
+		if v > h.Max {
+			h.Max = v
+		}
+		last, lastV = s, v
+		sum += v
+		n++
+	}
+
+	if n == 0 {
+		return h
+	}
+	h.Avg = sum / float64(n)
+	if elapsed := last.TS.Sub(first.TS).Seconds(); elapsed > 0 {
+		h.Rate = (lastV - firstV) / elapsed
+	}
+	return h
+}
@@ -1,6 +1,9 @@
 package metrics
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -32,3 +35,114 @@ func TestStoreLatest(t *testing.T) {
 		t.Error("expected no metrics")
 	}
 }
+
+func TestStoreHistory(t *testing.T) {
+	store := NewStoreWithCap(3)
+
+	if len(store.History("test")) != 0 {
+		t.Error("expected no history")
+	}
+
+	start := time.Now()
+	values := []string{"10", "abc", "40", "20"}
+	for i, v := range values {
+		metr := api.Metric{
+			Name:  "test",
+			Peer:  test.TestPeerID1,
+			Value: v,
+			Valid: true,
+		}
+		metr.SetTTL(time.Minute)
+		store.mux.Lock()
+		store.window(metr).AddAt(metr, start.Add(time.Duration(i)*time.Second))
+		store.mux.Unlock()
+	}
+
+	history := store.History("test")
+	if len(history) != 1 {
+		t.Fatal("expected history for 1 peer")
+	}
+	h := history[0]
+	if h.Peer != test.TestPeerID1 || h.Name != "test" {
+		t.Error("unexpected peer or name")
+	}
+	if len(h.Samples) != 3 {
+		t.Fatal("window should keep 3 samples")
+	}
+	if h.Samples[0].Value != "abc" {
+		t.Error("samples should be sorted oldest first")
+	}
+	if h.Min != 20 || h.Max != 40 || h.Avg != 30 {
+		t.Errorf("unexpected aggregates: %+v", h)
+	}
+	// from 40 at 2s to 20 at 3s
+	if h.Rate != -20 {
+		t.Error("unexpected rate:", h.Rate)
+	}
+}
+
+func TestStoreSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "history.json")
+
+	store := NewStore()
+	err = store.LoadFile(path)
+	if err != nil {
+		t.Fatal("loading a missing file should not fail:", err)
+	}
+
+	for _, v := range []string{"1", "2"} {
+		metr := api.Metric{
+			Name:  "test",
+			Peer:  test.TestPeerID1,
+			Value: v,
+			Valid: true,
+		}
+		metr.SetTTL(time.Minute)
+		store.Add(metr)
+	}
+
+	err = store.SaveFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store2 := NewStore()
+	err = store2.LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h1 := store.History("test")
+	h2 := store2.History("test")
+	if len(h2) != 1 || len(h2[0].Samples) != 2 {
+		t.Fatal("history was not restored")
+	}
+	for i, s := range h2[0].Samples {
+		if s.Value != h1[0].Samples[i].Value || !s.TS.Equal(h1[0].Samples[i].TS) {
+			t.Error("restored samples differ")
+		}
+	}
+	if len(store2.Latest("test")) != 0 || len(store2.PeerMetrics(test.TestPeerID1)) != 0 {
+		t.Error("restored metrics should only be part of the history")
+	}
+
+	metr := api.Metric{
+		Name:  "test",
+		Peer:  test.TestPeerID1,
+		Value: "3",
+		Valid: true,
+	}
+	metr.SetTTL(time.Minute)
+	store2.Add(metr)
+	if len(store2.Latest("test")) != 1 {
+		t.Error("expected the new metric")
+	}
+	if len(store2.History("test")[0].Samples) != 3 {
+		t.Error("new metric should be added to the restored history")
+	}
+}
@@ -5,6 +5,7 @@ package metrics
 
 import (
 	"errors"
+	"time"
 
 	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
 )
@@ -15,10 +16,16 @@ var DefaultWindowCap = 25
 // ErrNoMetrics is returned when there are no metrics in a Window.
 var ErrNoMetrics = errors.New("no metrics have been added to this window")
 
-// Window implements a circular queue to store metrics.
+// Window implements a circular queue to store metrics. It also
+// remembers when each metric was added.
 type Window struct {
 	last   int
 	window []api.Metric
+	added  []time.Time
+
+	// restored is set while the window only holds metrics loaded
+	// from disk.
+	restored bool
 }
 
 // NewWindow creates an instance with the given
@@ -32,6 +39,7 @@ func NewWindow(windowCap int) *Window {
 	return &Window{
 		last:   0,
 		window: w,
+		added:  make([]time.Time, 0, windowCap),
 	}
 }
 
@@ -39,8 +47,16 @@ func NewWindow(windowCap int) *Window {
 // has been reached, the oldest metric (by the time it was added),
 // will be discarded.
 func (mw *Window) Add(m api.Metric) {
+	mw.AddAt(m, time.Now())
+	mw.restored = false
+}
+
+// AddAt works like Add, but records that the metric was added at the
+// given time. It is used to restore saved windows.
+func (mw *Window) AddAt(m api.Metric, t time.Time) {
 	if len(mw.window) < cap(mw.window) {
 		mw.window = append(mw.window, m)
+		mw.added = append(mw.added, t)
 		mw.last = len(mw.window) - 1
 		return
 	}
@@ -48,6 +64,7 @@ func (mw *Window) Add(m api.Metric) {
 	// len == cap
 	mw.last = (mw.last + 1) % cap(mw.window)
 	mw.window[mw.last] = m
+	mw.added[mw.last] = t
 	return
 }
 
@@ -77,3 +94,32 @@ func (mw *Window) All() []api.Metric {
 	}
 	return res
 }
+
+// Samples returns the values in the window along with the time they
+// were added, oldest first.
+func (mw *Window) Samples() []api.MetricSample {
+	metrics, added := mw.ordered()
+	res := make([]api.MetricSample, len(metrics))
+	for i, m := range metrics {
+		res[i] = api.MetricSample{
+			Value: m.Value,
+			Valid: m.Valid,
+			TS:    added[i],
+		}
+	}
+	return res
+}
+
+// ordered returns the metrics in the window and the time they were
+// added, oldest first.
+func (mw *Window) ordered() ([]api.Metric, []time.Time) {
+	wlen := len(mw.window)
+	metrics := make([]api.Metric, 0, wlen)
+	added := make([]time.Time, 0, wlen)
+	for i := 1; i <= wlen; i++ {
+		idx := (mw.last + i) % wlen
+		metrics = append(metrics, mw.window[idx])
+		added = append(added, mw.added[idx])
+	}
+	return metrics, added
+}
@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -70,3 +71,34 @@ func TestMetricsWindow(t *testing.T) {
 		t.Error("oldest metric should be 2")
 	}
 }
+
+func TestMetricsWindowSamples(t *testing.T) {
+	mw := NewWindow(3)
+	if len(mw.Samples()) != 0 {
+		t.Error("expected no samples")
+	}
+
+	start := time.Now()
+	for i := 1; i <= 4; i++ {
+		metr := api.Metric{
+			Name:  "test",
+			Peer:  "peer1",
+			Value: fmt.Sprintf("%d", i),
+			Valid: true,
+		}
+		mw.AddAt(metr, start.Add(time.Duration(i)*time.Second))
+	}
+
+	samples := mw.Samples()
+	if len(samples) != 3 {
+		t.Fatal("expected 3 samples")
+	}
+	for i, s := range samples {
+		if s.Value != fmt.Sprintf("%d", i+2) {
+			t.Errorf("expected samples oldest first, got %s at %d", s.Value, i)
+		}
+		if !s.TS.Equal(start.Add(time.Duration(i+2) * time.Second)) {
+			t.Error("unexpected sample timestamp")
+		}
+	}
+}
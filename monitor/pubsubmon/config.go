@@ -3,6 +3,7 @@ package pubsubmon
 import (
 	"encoding/json"
 	"errors"
+	"path/filepath"
 	"time"
 
 	"github.com/elastos/Elastos.NET.Hive.Cluster/config"
@@ -13,6 +14,7 @@ const configKey = "pubsubmon"
 // Default values for this Config.
 const (
 	DefaultCheckInterval = 15 * time.Second
+	DefaultWindowCap     = 25
 )
 
 // Config allows to initialize a Monitor and customize some parameters.
@@ -20,10 +22,20 @@ type Config struct {
 	config.Saver
 
 	CheckInterval time.Duration
+
+	// Number of metrics kept per peer and metric name.
+	WindowCap int
+
+	// File, relative to the configuration folder, where the metrics
+	// are saved so that their history survives restarts. They are
+	// only kept in memory when empty.
+	HistoryFile string
 }
 
 type jsonConfig struct {
 	CheckInterval string `json:"check_interval"`
+	WindowCap     int    `json:"window_cap"`
+	HistoryFile   string `json:"history_file,omitempty"`
 }
 
 // ConfigKey provides a human-friendly identifier for this type of Config.
@@ -34,6 +46,8 @@ func (cfg *Config) ConfigKey() string {
 // Default sets the fields of this Config to sensible values.
 func (cfg *Config) Default() error {
 	cfg.CheckInterval = DefaultCheckInterval
+	cfg.WindowCap = DefaultWindowCap
+	cfg.HistoryFile = ""
 	return nil
 }
 
@@ -43,6 +57,9 @@ func (cfg *Config) Validate() error {
 	if cfg.CheckInterval <= 0 {
 		return errors.New("basic.check_interval too low")
 	}
+	if cfg.WindowCap <= 0 {
+		return errors.New("pubsubmon.window_cap too low")
+	}
 	return nil
 }
 
@@ -59,6 +76,12 @@ func (cfg *Config) LoadJSON(raw []byte) error {
 	interval, _ := time.ParseDuration(jcfg.CheckInterval)
 	cfg.CheckInterval = interval
 
+	cfg.WindowCap = DefaultWindowCap
+	if jcfg.WindowCap != 0 {
+		cfg.WindowCap = jcfg.WindowCap
+	}
+	cfg.HistoryFile = jcfg.HistoryFile
+
 	return cfg.Validate()
 }
 
@@ -67,6 +90,19 @@ func (cfg *Config) ToJSON() ([]byte, error) {
 	jcfg := &jsonConfig{}
 
 	jcfg.CheckInterval = cfg.CheckInterval.String()
+	jcfg.WindowCap = cfg.WindowCap
+	jcfg.HistoryFile = cfg.HistoryFile
 
 	return json.MarshalIndent(jcfg, "", "    ")
 }
+
+// GetHistoryPath returns the full path of the file where the metrics
+// history is saved, obtained by concatenating HistoryFile with the
+// BaseDir of the configuration when it is relative. An empty string is
+// returned when HistoryFile is not set.
+func (cfg *Config) GetHistoryPath() string {
+	if cfg.HistoryFile == "" || filepath.IsAbs(cfg.HistoryFile) {
+		return cfg.HistoryFile
+	}
+	return filepath.Join(cfg.BaseDir, cfg.HistoryFile)
+}
@@ -0,0 +1,100 @@
+package ipfscluster
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/observations"
+)
+
+// collectMetrics updates the metrics which are computed on demand
+// right before they are served: pin tracker operations, informer values
+// and the homes hosted by this peer.
+func (c *Cluster) collectMetrics() {
+	c.collectOperationMetrics()
+	c.collectInformerMetrics()
+	c.collectHomeMetrics()
+}
+
+func (c *Cluster) collectOperationMetrics() {
+	observations.PinOperations.Reset()
+	observations.PinQueueDepth.Set(0, "pin")
+	observations.PinQueueDepth.Set(0, "unpin")
+
+	for _, op := range c.tracker.Operations() {
+		typ := strings.ToLower(strings.TrimPrefix(op.Type, "Operation"))
+		phase := phaseLabel(op.Phase)
+		observations.PinOperations.Add(1, typ, phase)
+		if phase == "queued" && (typ == "pin" || typ == "unpin") {
+			observations.PinQueueDepth.Add(1, typ)
+		}
+	}
+}
+
+// phaseLabel turns "PhaseInProgress" into "in_progress".
+func phaseLabel(phase string) string {
+	phase = strings.TrimPrefix(phase, "Phase")
+	var b strings.Builder
+	for i, r := range phase {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+	return strings.ToLower(b.String())
+}
+
+func (c *Cluster) collectInformerMetrics() {
+	for _, inf := range c.informers {
+		for _, m := range c.monitor.LatestMetrics(inf.Name()) {
+			if m.Peer != c.id || !m.Valid {
+				continue
+			}
+			v, err := strconv.ParseFloat(m.Value, 64)
+			if err != nil {
+				continue
+			}
+			observations.InformerValue.Set(v, m.Name)
+		}
+	}
+}
+
+// homeSizer is implemented by the informers which measure the size of
+// every home, like the tenants informer.
+type homeSizer interface {
+	HomeSizes() map[string]uint64
+}
+
+// collectHomeMetrics exports the number and sizes of the homes hosted by
+// this peer. They are taken from the last values of the tenants
+// informers, when this peer runs them, so that scraping does not walk
+// the homes.
+func (c *Cluster) collectHomeMetrics() {
+	for name, gauge := range map[string]*observations.Gauge{
+		"homes":     observations.UIDs,
+		"homessize": observations.HomesBytes,
+	} {
+		for _, m := range c.monitor.LatestMetrics(name) {
+			if m.Peer != c.id || !m.Valid {
+				continue
+			}
+			v, err := strconv.ParseFloat(m.Value, 64)
+			if err != nil {
+				continue
+			}
+			gauge.Set(v)
+		}
+	}
+
+	// Homes which moved away are forgotten.
+	observations.HomeBytes.Reset()
+	for _, inf := range c.informers {
+		hs, ok := inf.(homeSizer)
+		if !ok {
+			continue
+		}
+		for uid, size := range hs.HomeSizes() {
+			observations.HomeBytes.Set(float64(size), uid)
+		}
+	}
+}
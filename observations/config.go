@@ -0,0 +1,115 @@
+package observations
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/kelseyhightower/envconfig"
+	ma "github.com/multiformats/go-multiaddr"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/config"
+)
+
+const (
+	configKey    = "metrics"
+	envConfigKey = "cluster_metrics"
+)
+
+// Default values for Config.
+const (
+	DefaultEnabled    = false
+	DefaultListenAddr = "/ip4/127.0.0.1/tcp/8888"
+)
+
+// Config allows to enable and customize the Prometheus metrics endpoint.
+// It implements the config.ComponentConfig interface.
+type Config struct {
+	config.Saver
+
+	// Enabled starts the metrics listener.
+	Enabled bool
+
+	// Listen address for the HTTP server serving /metrics.
+	ListenAddr ma.Multiaddr
+}
+
+type jsonConfig struct {
+	Enabled            bool   `json:"enabled"`
+	ListenMultiaddress string `json:"listen_multiaddress"`
+}
+
+// ConfigKey provides a human-friendly identifier for this type of Config.
+func (cfg *Config) ConfigKey() string {
+	return configKey
+}
+
+// Default sets the fields of this Config to sensible default values.
+func (cfg *Config) Default() error {
+	listen, err := ma.NewMultiaddr(DefaultListenAddr)
+	if err != nil {
+		return err
+	}
+	cfg.Enabled = DefaultEnabled
+	cfg.ListenAddr = listen
+	return nil
+}
+
+// Validate checks that the fields of this Config have sensible values,
+// at least in appearance.
+func (cfg *Config) Validate() error {
+	if cfg.ListenAddr == nil {
+		return errors.New("metrics.listen_multiaddress not set")
+	}
+	return nil
+}
+
+// LoadJSON parses a JSON representation of this Config as generated by ToJSON.
+func (cfg *Config) LoadJSON(raw []byte) error {
+	jcfg := &jsonConfig{}
+	err := json.Unmarshal(raw, jcfg)
+	if err != nil {
+		logger.Error("Error unmarshaling metrics config")
+		return err
+	}
+
+	err = cfg.Default()
+	if err != nil {
+		return fmt.Errorf("error setting config to default values: %s", err)
+	}
+
+	// override json config with env var
+	err = envconfig.Process(envConfigKey, jcfg)
+	if err != nil {
+		return err
+	}
+
+	cfg.Enabled = jcfg.Enabled
+	if jcfg.ListenMultiaddress != "" {
+		listen, err := ma.NewMultiaddr(jcfg.ListenMultiaddress)
+		if err != nil {
+			return fmt.Errorf("error parsing metrics listen_multiaddress: %s", err)
+		}
+		cfg.ListenAddr = listen
+	}
+
+	return cfg.Validate()
+}
+
+// ToJSON generates a human-friendly JSON representation of this Config.
+func (cfg *Config) ToJSON() (raw []byte, err error) {
+	// Multiaddress String() may panic
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s", r)
+		}
+	}()
+
+	jcfg := &jsonConfig{
+		Enabled:            cfg.Enabled,
+		ListenMultiaddress: cfg.ListenAddr.String(),
+	}
+
+	raw, err = config.DefaultJSONMarshal(jcfg)
+	return
+}
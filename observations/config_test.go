@@ -0,0 +1,67 @@
+package observations
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var cfgJSON = []byte(`
+{
+      "enabled": true,
+      "listen_multiaddress": "/ip4/127.0.0.1/tcp/9999"
+}
+`)
+
+func TestLoadJSON(t *testing.T) {
+	cfg := &Config{}
+	err := cfg.LoadJSON(cfgJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !cfg.Enabled {
+		t.Error("expected enabled")
+	}
+	if cfg.ListenAddr.String() != "/ip4/127.0.0.1/tcp/9999" {
+		t.Error("error parsing listen_multiaddress")
+	}
+
+	j := &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.ListenMultiaddress = "abc"
+	tst, _ := json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error parsing listen_multiaddress")
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	cfg := &Config{}
+	cfg.LoadJSON(cfgJSON)
+	newjson, err := cfg.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = &Config{}
+	err = cfg.LoadJSON(newjson)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	cfg := &Config{}
+	cfg.Default()
+	if cfg.Validate() != nil {
+		t.Fatal("error validating")
+	}
+	if cfg.Enabled {
+		t.Error("metrics should be disabled by default")
+	}
+
+	cfg.ListenAddr = nil
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+}
@@ -0,0 +1,95 @@
+package observations
+
+// Metrics exposed by a cluster peer.
+var (
+	// PinOperations counts the pin tracker operations by type (pin,
+	// unpin) and phase (queued, in_progress, error...).
+	PinOperations = NewGauge(
+		"hive_cluster_pin_operations",
+		"Number of pin tracker operations by type and phase.",
+		"type", "phase",
+	)
+	// PinQueueDepth is the number of operations waiting in the pin and
+	// unpin queues.
+	PinQueueDepth = NewGauge(
+		"hive_cluster_pin_queue_depth",
+		"Number of operations waiting in the pin tracker queues.",
+		"queue",
+	)
+
+	// ConsensusCommitLatency measures how long it takes to commit an
+	// operation to the shared state, as seen by the leader.
+	ConsensusCommitLatency = NewHistogram(
+		"hive_cluster_consensus_commit_seconds",
+		"Time taken to commit an operation to the consensus log.",
+		DefaultLatencyBuckets,
+	)
+	// ConsensusLeaderChanges counts how many times this peer has seen
+	// the Raft leader change.
+	ConsensusLeaderChanges = NewCounter(
+		"hive_cluster_consensus_leader_changes_total",
+		"Number of Raft leader changes observed.",
+	)
+	// ConsensusIsLeader is 1 when this peer is the Raft leader.
+	ConsensusIsLeader = NewGauge(
+		"hive_cluster_consensus_is_leader",
+		"Whether this peer is the Raft leader.",
+	)
+
+	// InformerValue holds the last value produced by each informer of
+	// this peer.
+	InformerValue = NewGauge(
+		"hive_cluster_informer_value",
+		"Last valid value of the metrics produced by the informers of this peer.",
+		"metric",
+	)
+
+	// RPCCalls counts the RPC requests served by this peer by method.
+	RPCCalls = NewCounter(
+		"hive_cluster_rpc_calls_total",
+		"Number of RPC requests served, by method.",
+		"method",
+	)
+	// RPCErrors counts the RPC requests which returned an error by
+	// method.
+	RPCErrors = NewCounter(
+		"hive_cluster_rpc_errors_total",
+		"Number of RPC requests which returned an error, by method.",
+		"method",
+	)
+
+	// ProxyRequests counts the requests to hijacked IPFS proxy routes.
+	ProxyRequests = NewCounter(
+		"hive_cluster_proxy_requests_total",
+		"Number of requests to hijacked IPFS proxy routes.",
+		"route",
+	)
+	// ProxyRequestLatency measures how long hijacked IPFS proxy
+	// requests take.
+	ProxyRequestLatency = NewHistogram(
+		"hive_cluster_proxy_request_duration_seconds",
+		"Time taken to answer requests to hijacked IPFS proxy routes.",
+		DefaultLatencyBuckets,
+		"route",
+	)
+
+	// UIDs is the number of user homes hosted by this peer, as last
+	// measured by its "homes" informer.
+	UIDs = NewGauge(
+		"hive_cluster_uids",
+		"Number of UID homes hosted by this peer.",
+	)
+	// HomesBytes is the total cumulative size of the homes hosted by
+	// this peer, as last measured by its "homessize" informer.
+	HomesBytes = NewGauge(
+		"hive_cluster_homes_bytes",
+		"Total cumulative size in bytes of the UID homes hosted by this peer.",
+	)
+	// HomeBytes is the cumulative size of every home hosted by this
+	// peer, as last measured by its "homessize" informer.
+	HomeBytes = NewGauge(
+		"hive_cluster_home_bytes",
+		"Cumulative size in bytes of a UID home hosted by this peer.",
+		"uid",
+	)
+)
@@ -0,0 +1,398 @@
+// Package observations collects metrics about the behaviour of an IPFS
+// Cluster peer (queues, consensus, RPC, proxy, Hive homes) and exposes them
+// in the Prometheus text format.
+//
+// Metrics are defined as package variables (see metrics.go) which the
+// instrumented components update directly. Values which are cheaper to
+// compute when requested are filled by collect hooks, which run right
+// before the metrics are written.
+//
+// The Prometheus client library is not among the gx dependencies of this
+// project, so the package implements the small subset of the text
+// exposition format that it needs: counters, gauges and histograms with
+// labels.
+package observations
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultRegistry holds all the metrics defined in this package.
+var DefaultRegistry = NewRegistry()
+
+type metric interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry is a set of metrics which can be written in the Prometheus
+// text format.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+	hooks   map[int]func()
+	nextID  int
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: make(map[string]metric),
+		hooks:   make(map[int]func()),
+	}
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.metrics[m.name()]; ok {
+		panic("observations: duplicate metric " + m.name())
+	}
+	r.metrics[m.name()] = m
+}
+
+// AddCollectHook registers a function which runs every time the metrics
+// are written, so that it can update them. The returned function removes
+// the hook.
+func (r *Registry) AddCollectHook(f func()) (remove func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := r.nextID
+	r.nextID++
+	r.hooks[id] = f
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.hooks, id)
+	}
+}
+
+// WriteText runs the collect hooks and writes all the metrics in the
+// Prometheus text exposition format, sorted by name.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	hooks := make([]func(), 0, len(r.hooks))
+	for _, h := range r.hooks {
+		hooks = append(hooks, h)
+	}
+	metrics := make([]metric, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		metrics = append(metrics, m)
+	}
+	r.mu.Unlock()
+
+	for _, h := range hooks {
+		h()
+	}
+
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].name() < metrics[j].name()
+	})
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// desc holds the common parts of every metric.
+type desc struct {
+	metricName string
+	help       string
+	labels     []string
+}
+
+func (d *desc) name() string {
+	return d.metricName
+}
+
+func (d *desc) key(lvs []string) string {
+	if len(lvs) != len(d.labels) {
+		panic(fmt.Sprintf(
+			"observations: %s expects %d label values, got %d",
+			d.metricName, len(d.labels), len(lvs),
+		))
+	}
+	return strings.Join(lvs, "\xff")
+}
+
+func (d *desc) writeHeader(w *bufio.Writer, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.metricName, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.metricName, typ)
+}
+
+// writeSample writes a line for a series. extraName and extraValue add
+// one more label (used for histogram buckets).
+func (d *desc) writeSample(w *bufio.Writer, suffix, key, extraName, extraValue string, v float64) {
+	w.WriteString(d.metricName)
+	w.WriteString(suffix)
+
+	var pairs []string
+	if len(d.labels) > 0 {
+		lvs := strings.Split(key, "\xff")
+		for i, l := range d.labels {
+			pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", l, escapeLabel(lvs[i])))
+		}
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", extraName, extraValue))
+	}
+	if len(pairs) > 0 {
+		w.WriteString("{")
+		w.WriteString(strings.Join(pairs, ","))
+		w.WriteString("}")
+	}
+	w.WriteString(" ")
+	w.WriteString(formatFloat(v))
+	w.WriteString("\n")
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Counter is a metric which only goes up, optionally split by labels.
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewCounter creates a Counter and registers it in the DefaultRegistry.
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{
+		desc:   desc{name, help, labels},
+		values: make(map[string]float64),
+	}
+	if len(labels) == 0 {
+		c.values[""] = 0
+	}
+	DefaultRegistry.register(c)
+	return c
+}
+
+// Inc adds one to the series with the given label values.
+func (c *Counter) Inc(lvs ...string) {
+	c.Add(1, lvs...)
+}
+
+// Add adds v, which must not be negative, to the series with the given
+// label values.
+func (c *Counter) Add(v float64, lvs ...string) {
+	if v < 0 {
+		return
+	}
+	k := c.key(lvs)
+	c.mu.Lock()
+	c.values[k] += v
+	c.mu.Unlock()
+}
+
+// Value returns the current value of the series with the given label
+// values.
+func (c *Counter) Value(lvs ...string) float64 {
+	k := c.key(lvs)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[k]
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeHeader(w, "counter")
+	for _, k := range sortedKeys(c.values) {
+		c.writeSample(w, "", k, "", "", c.values[k])
+	}
+}
+
+// Gauge is a metric which can go up and down, optionally split by labels.
+type Gauge struct {
+	desc
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewGauge creates a Gauge and registers it in the DefaultRegistry.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{
+		desc:   desc{name, help, labels},
+		values: make(map[string]float64),
+	}
+	if len(labels) == 0 {
+		g.values[""] = 0
+	}
+	DefaultRegistry.register(g)
+	return g
+}
+
+// Set sets the value of the series with the given label values.
+func (g *Gauge) Set(v float64, lvs ...string) {
+	k := g.key(lvs)
+	g.mu.Lock()
+	g.values[k] = v
+	g.mu.Unlock()
+}
+
+// Add adds v to the series with the given label values.
+func (g *Gauge) Add(v float64, lvs ...string) {
+	k := g.key(lvs)
+	g.mu.Lock()
+	g.values[k] += v
+	g.mu.Unlock()
+}
+
+// Value returns the current value of the series with the given label
+// values.
+func (g *Gauge) Value(lvs ...string) float64 {
+	k := g.key(lvs)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.values[k]
+}
+
+// Reset removes all the series of a Gauge with labels, so that series
+// which no longer exist are not reported.
+func (g *Gauge) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.values = make(map[string]float64)
+	if len(g.labels) == 0 {
+		g.values[""] = 0
+	}
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.writeHeader(w, "gauge")
+	for _, k := range sortedKeys(g.values) {
+		g.writeSample(w, "", k, "", "", g.values[k])
+	}
+}
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, used by the
+// latency histograms in this package.
+var DefaultLatencyBuckets = []float64{
+	0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30,
+}
+
+type histogramSeries struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// Histogram counts observations in buckets, optionally split by labels.
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+// NewHistogram creates a Histogram with the given bucket upper bounds
+// and registers it in the DefaultRegistry.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	b := append([]float64{}, buckets...)
+	sort.Float64s(b)
+	h := &Histogram{
+		desc:    desc{name, help, labels},
+		buckets: b,
+		series:  make(map[string]*histogramSeries),
+	}
+	DefaultRegistry.register(h)
+	return h
+}
+
+// Observe records a value in the series with the given label values.
+func (h *Histogram) Observe(v float64, lvs ...string) {
+	k := h.key(lvs)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[k]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[k] = s
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+			break
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+// Count returns the number of observations of the series with the given
+// label values.
+func (h *Histogram) Count(lvs ...string) uint64 {
+	k := h.key(lvs)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[k]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(w, "histogram")
+
+	keys := make([]string, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := h.series[k]
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			h.writeSample(w, "_bucket", k, "le", formatFloat(upper), float64(cumulative))
+		}
+		h.writeSample(w, "_bucket", k, "le", "+Inf", float64(s.count))
+		h.writeSample(w, "_sum", k, "", "", s.sum)
+		h.writeSample(w, "_count", k, "", "", float64(s.count))
+	}
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var helpEscaper = strings.NewReplacer("\\", `\\`, "\n", `\n`)
+var labelEscaper = strings.NewReplacer("\\", `\\`, "\n", `\n`, "\"", `\"`)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
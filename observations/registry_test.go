@@ -0,0 +1,120 @@
+package observations
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var (
+	testCounter = NewCounter(
+		"test_counter_total",
+		"A test counter.",
+		"method",
+	)
+	testGauge = NewGauge(
+		"test_gauge",
+		"A \"test\" gauge\nwith two lines.",
+	)
+	testHistogram = NewHistogram(
+		"test_seconds",
+		"A test histogram.",
+		[]float64{1, 0.1},
+		"route",
+	)
+)
+
+func writeMetrics(t *testing.T) string {
+	buf := new(bytes.Buffer)
+	err := DefaultRegistry.WriteText(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func expectLines(t *testing.T, out string, lines ...string) {
+	for _, l := range lines {
+		if !strings.Contains(out, l+"\n") {
+			t.Errorf("expected line %q in:\n%s", l, out)
+		}
+	}
+}
+
+func TestCounter(t *testing.T) {
+	testCounter.Inc("a")
+	testCounter.Add(2, "a")
+	testCounter.Add(-1, "a")
+	testCounter.Inc(`b"c`)
+
+	if v := testCounter.Value("a"); v != 3 {
+		t.Error("expected 3, got", v)
+	}
+
+	expectLines(t, writeMetrics(t),
+		"# HELP test_counter_total A test counter.",
+		"# TYPE test_counter_total counter",
+		`test_counter_total{method="a"} 3`,
+		`test_counter_total{method="b\"c"} 1`,
+	)
+}
+
+func TestGauge(t *testing.T) {
+	testGauge.Set(5)
+	testGauge.Add(-1.5)
+	if v := testGauge.Value(); v != 3.5 {
+		t.Error("expected 3.5, got", v)
+	}
+
+	expectLines(t, writeMetrics(t),
+		`# HELP test_gauge A "test" gauge\nwith two lines.`,
+		"# TYPE test_gauge gauge",
+		"test_gauge 3.5",
+	)
+
+	testGauge.Reset()
+	expectLines(t, writeMetrics(t), "test_gauge 0")
+}
+
+func TestHistogram(t *testing.T) {
+	testHistogram.Observe(0.05, "/add")
+	testHistogram.Observe(0.5, "/add")
+	testHistogram.Observe(5, "/add")
+
+	if c := testHistogram.Count("/add"); c != 3 {
+		t.Error("expected 3 observations, got", c)
+	}
+
+	expectLines(t, writeMetrics(t),
+		"# TYPE test_seconds histogram",
+		`test_seconds_bucket{route="/add",le="0.1"} 1`,
+		`test_seconds_bucket{route="/add",le="1"} 2`,
+		`test_seconds_bucket{route="/add",le="+Inf"} 3`,
+		`test_seconds_sum{route="/add"} 5.55`,
+		`test_seconds_count{route="/add"} 3`,
+	)
+}
+
+func TestCollectHook(t *testing.T) {
+	calls := 0
+	remove := DefaultRegistry.AddCollectHook(func() {
+		calls++
+		testGauge.Set(42)
+	})
+
+	expectLines(t, writeMetrics(t), "test_gauge 42")
+	remove()
+	writeMetrics(t)
+	if calls != 1 {
+		t.Error("hook should have been called once, got", calls)
+	}
+}
+
+func TestWrongLabels(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	testCounter.Inc()
+}
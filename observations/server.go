@@ -0,0 +1,111 @@
+package observations
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	logging "github.com/ipfs/go-log"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+	manet "github.com/multiformats/go-multiaddr-net"
+)
+
+var logger = logging.Logger("observations")
+
+// Handler returns an http.Handler which writes the metrics in the
+// DefaultRegistry in the Prometheus text format.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		err := DefaultRegistry.WriteText(w)
+		if err != nil {
+			logger.Error(err)
+		}
+	})
+}
+
+// Server is an API component which serves the metrics on /metrics
+// when enabled in the configuration. Otherwise it does nothing.
+type Server struct {
+	config   *Config
+	server   *http.Server
+	listener net.Listener
+
+	shutdownLock sync.Mutex
+	shutdown     bool
+	wg           sync.WaitGroup
+}
+
+// New creates a metrics Server and starts listening when enabled.
+func New(cfg *Config) (*Server, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &Server{
+		config: cfg,
+	}
+	if !cfg.Enabled {
+		return srv, nil
+	}
+
+	n, addr, err := manet.DialArgs(cfg.ListenAddr)
+	if err != nil {
+		return nil, err
+	}
+	l, err := net.Listen(n, addr)
+	if err != nil {
+		return nil, err
+	}
+	srv.listener = l
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	srv.server = &http.Server{Handler: mux}
+
+	srv.wg.Add(1)
+	go srv.run()
+	return srv, nil
+}
+
+func (srv *Server) run() {
+	defer srv.wg.Done()
+	logger.Infof("Prometheus metrics: http://%s/metrics", srv.listener.Addr())
+	err := srv.server.Serve(srv.listener)
+	if err != nil && !strings.Contains(err.Error(), "closed network connection") {
+		logger.Error(err)
+	}
+}
+
+// Addr returns the address the metrics are served on, or nil when the
+// server is disabled.
+func (srv *Server) Addr() net.Addr {
+	if srv.listener == nil {
+		return nil
+	}
+	return srv.listener.Addr()
+}
+
+// SetClient is a no-op. The metrics do not need RPC.
+func (srv *Server) SetClient(c *rpc.Client) {}
+
+// Shutdown stops the metrics listener.
+func (srv *Server) Shutdown() error {
+	srv.shutdownLock.Lock()
+	defer srv.shutdownLock.Unlock()
+
+	if srv.shutdown {
+		logger.Debug("already shutdown")
+		return nil
+	}
+
+	logger.Info("stopping metrics server")
+	if srv.listener != nil {
+		srv.listener.Close()
+	}
+	srv.wg.Wait()
+	srv.shutdown = true
+	return nil
+}
@@ -0,0 +1,55 @@
+package observations
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func TestServer(t *testing.T) {
+	cfg := &Config{}
+	cfg.Default()
+	cfg.Enabled = true
+	cfg.ListenAddr, _ = ma.NewMultiaddr("/ip4/127.0.0.1/tcp/0")
+
+	srv, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Shutdown()
+
+	RPCCalls.Inc("ID")
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/metrics", srv.Addr()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status:", resp.Status)
+	}
+	if !strings.Contains(string(body), `hive_cluster_rpc_calls_total{method="ID"}`) {
+		t.Error("rpc calls metric not found in:\n", string(body))
+	}
+}
+
+func TestServerDisabled(t *testing.T) {
+	cfg := &Config{}
+	cfg.Default()
+
+	srv, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Shutdown()
+
+	if srv.Addr() != nil {
+		t.Error("a disabled server should not listen")
+	}
+}
@@ -0,0 +1,220 @@
+package ipfscluster
+
+import (
+	"time"
+
+	peer "github.com/libp2p/go-libp2p-peer"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+)
+
+// pinHealthAlertName is the MetricName of the alerts sent when the pin
+// health check finds unhealthy pins allocated to a peer.
+var pinHealthAlertName = "pin_health"
+
+// pinHealthLogLimit caps how many unhealthy pins are logged by every
+// periodic health check.
+var pinHealthLogLimit = 10
+
+// PinsHealth returns a report on the replication health of the pinset
+// as computed by the cluster leader. The report lists, at most, the given
+// number of unhealthy pins, or all of them when limit is 0.
+func (c *Cluster) PinsHealth(limit int) (api.PinsHealthReport, error) {
+	leader, err := c.consensus.Leader()
+	if err != nil {
+		return api.PinsHealthReport{}, err
+	}
+	if leader == c.id {
+		return c.PinsHealthLocal(limit)
+	}
+
+	var reportS api.PinsHealthReportSerial
+	err = c.rpcClient.Call(
+		leader,
+		"Cluster",
+		"PinsHealthLocal",
+		limit,
+		&reportS,
+	)
+	if err != nil {
+		return api.PinsHealthReport{}, err
+	}
+	return reportS.ToPinsHealthReport(), nil
+}
+
+// PinsHealthLocal computes a report on the replication health of the
+// pinset from the point of view of this peer. It combines the shared
+// state with the status of every pin in every peer and with the peers
+// which are alive according to the PeerMonitor. See PinsHealth().
+func (c *Cluster) PinsHealthLocal(limit int) (api.PinsHealthReport, error) {
+	report := api.PinsHealthReport{
+		Peer:    c.id,
+		TS:      time.Now(),
+		Summary: make(map[api.PinHealthState]int),
+		Worst:   make([]api.PinHealth, 0),
+	}
+
+	cState, err := c.consensus.State()
+	if err != nil {
+		logger.Error(err)
+		return report, err
+	}
+
+	members, err := c.consensus.Peers()
+	if err != nil {
+		logger.Error(err)
+		return report, err
+	}
+	report.ClusterSize = len(members)
+
+	live := make(map[peer.ID]bool)
+	live[c.id] = true
+	for _, m := range c.monitor.LatestMetrics(pingMetricName) {
+		live[m.Peer] = true
+	}
+
+	gpis, err := c.globalPinInfoSlice("TrackerStatusAll", struct{}{})
+	if err != nil {
+		return report, err
+	}
+	statuses := make(map[string]api.GlobalPinInfo, len(gpis))
+	for _, gpi := range gpis {
+		statuses[gpi.Cid.String()] = gpi
+	}
+
+	for _, pin := range cState.List() {
+		// Meta pins are not pinned anywhere. Their shards are.
+		if pin.Type == api.MetaType {
+			continue
+		}
+		ph := pinHealth(pin, statuses[pin.Cid.String()], members, live)
+		report.Total++
+		report.Summary[ph.State]++
+		if ph.State != api.PinHealthOK {
+			report.Worst = append(report.Worst, ph)
+		}
+	}
+
+	report.SortWorst()
+	if limit > 0 && len(report.Worst) > limit {
+		report.Worst = report.Worst[:limit]
+	}
+	return report, nil
+}
+
+// pinHealth classifies a pin given its status in every peer, the current
+// cluster peers and those among them which are alive.
+func pinHealth(pin api.Pin, gpi api.GlobalPinInfo, members []peer.ID, live map[peer.ID]bool) api.PinHealth {
+	ph := api.PinHealth{
+		Cid:                  pin.Cid,
+		Name:                 pin.Name,
+		ReplicationFactorMin: pin.ReplicationFactorMin,
+		ReplicationFactorMax: pin.ReplicationFactorMax,
+		Allocations:          pin.Allocations,
+	}
+
+	expected := pin.Allocations
+	if pin.ReplicationFactorMin < 0 {
+		expected = members
+	}
+
+	pending := 0
+	for _, p := range expected {
+		info, ok := gpi.PeerMap[p]
+		switch {
+		case !live[p] || (ok && info.Status == api.TrackerStatusClusterError):
+			ph.Down = append(ph.Down, p)
+		case !ok:
+			// not tracked yet
+			pending++
+		case info.Status == api.TrackerStatusPinned:
+			ph.Pinned++
+		case info.Status.Match(api.TrackerStatusError):
+			ph.Errored = append(ph.Errored, p)
+		default:
+			pending++
+		}
+	}
+
+	switch {
+	case ph.Pinned == 0 && pending == 0:
+		ph.State = api.PinHealthLost
+	case ph.Missing(len(members)) > 0:
+		ph.State = api.PinHealthUnderReplicated
+	case ph.Pinned < len(expected):
+		ph.State = api.PinHealthDegraded
+	default:
+		ph.State = api.PinHealthOK
+	}
+	return ph
+}
+
+// pinHealthWatcher periodically checks the health of the pinset when
+// this peer is the leader. Unhealthy pins are logged and an alert is sent
+// for every peer which is down or in error for any of them.
+func (c *Cluster) pinHealthWatcher() {
+	ticker := time.NewTicker(c.config.PinHealthInterval)
+	for {
+		select {
+		case <-c.ctx.Done():
+			ticker.Stop()
+			return
+		case <-ticker.C:
+			leader, err := c.consensus.Leader()
+			if err != nil || leader != c.id {
+				continue
+			}
+			logger.Debug("auto-triggering PinsHealthLocal()")
+			report, err := c.PinsHealthLocal(0)
+			if err != nil {
+				logger.Error(err)
+				continue
+			}
+			c.reportPinsHealth(report)
+		}
+	}
+}
+
+// reportPinsHealth logs the unhealthy pins in a report and sends alerts
+// through the PeerMonitor for the peers holding them.
+func (c *Cluster) reportPinsHealth(report api.PinsHealthReport) {
+	if len(report.Worst) == 0 {
+		return
+	}
+
+	logger.Warningf(
+		"pinset health: %d pins: %d degraded, %d under-replicated, %d lost",
+		report.Total,
+		report.Summary[api.PinHealthDegraded],
+		report.Summary[api.PinHealthUnderReplicated],
+		report.Summary[api.PinHealthLost],
+	)
+
+	alerted := make(map[peer.ID]struct{})
+	alert := func(peers []peer.ID) {
+		for _, p := range peers {
+			if _, ok := alerted[p]; ok {
+				continue
+			}
+			alerted[p] = struct{}{}
+			err := c.monitor.SendAlert(api.Alert{
+				Peer:       p,
+				MetricName: pinHealthAlertName,
+			})
+			if err != nil {
+				logger.Error(err)
+			}
+		}
+	}
+
+	for i, ph := range report.Worst {
+		if i < pinHealthLogLimit {
+			logger.Warningf(
+				"%s is %s: %d pinned, down: %s, errored: %s",
+				ph.Cid, ph.State, ph.Pinned, ph.Down, ph.Errored,
+			)
+		}
+		alert(ph.Down)
+		alert(ph.Errored)
+	}
+}
@@ -3,6 +3,7 @@ package maptracker
 import (
 	"encoding/json"
 	"errors"
+	"time"
 
 	"github.com/elastos/Elastos.NET.Hive.Cluster/config"
 )
@@ -13,6 +14,10 @@ const configKey = "maptracker"
 const (
 	DefaultMaxPinQueueSize = 50000
 	DefaultConcurrentPins  = 10
+	DefaultMaxPinRetries   = 5
+	DefaultRetryBackoff    = 30 * time.Second
+	DefaultMaxRetryBackoff = 30 * time.Minute
+	DefaultStallTimeout    = 10 * time.Minute
 )
 
 // Config allows to initialize a Monitor and customize some parameters.
@@ -25,11 +30,28 @@ type Config struct {
 	// daemon in parallel. If the pinning method is "refs", it might increase
 	// speed. Unpin requests are always processed one by one.
 	ConcurrentPins int
+	// MaxPinRetries is the number of times a failed pin or unpin
+	// operation is retried automatically before leaving it in error
+	// state. Zero or a negative value disables retries.
+	MaxPinRetries int
+	// RetryBackoff is the time to wait before the first retry of a failed
+	// operation. It doubles after every failed attempt.
+	RetryBackoff time.Duration
+	// MaxRetryBackoff caps the time to wait between retries.
+	MaxRetryBackoff time.Duration
+	// StallTimeout is the time after which an in-progress pin which
+	// has not fetched any new blocks is cancelled and retried. Zero
+	// disables the check.
+	StallTimeout time.Duration
 }
 
 type jsonConfig struct {
-	MaxPinQueueSize int `json:"max_pin_queue_size"`
-	ConcurrentPins  int `json:"concurrent_pins"`
+	MaxPinQueueSize int    `json:"max_pin_queue_size"`
+	ConcurrentPins  int    `json:"concurrent_pins"`
+	MaxPinRetries   *int   `json:"max_pin_retries,omitempty"`
+	RetryBackoff    string `json:"retry_backoff"`
+	MaxRetryBackoff string `json:"max_retry_backoff"`
+	StallTimeout    string `json:"stall_timeout"`
 }
 
 // ConfigKey provides a human-friendly identifier for this type of Config.
@@ -41,6 +63,10 @@ func (cfg *Config) ConfigKey() string {
 func (cfg *Config) Default() error {
 	cfg.MaxPinQueueSize = DefaultMaxPinQueueSize
 	cfg.ConcurrentPins = DefaultConcurrentPins
+	cfg.MaxPinRetries = DefaultMaxPinRetries
+	cfg.RetryBackoff = DefaultRetryBackoff
+	cfg.MaxRetryBackoff = DefaultMaxRetryBackoff
+	cfg.StallTimeout = DefaultStallTimeout
 	return nil
 }
 
@@ -54,6 +80,18 @@ func (cfg *Config) Validate() error {
 	if cfg.ConcurrentPins <= 0 {
 		return errors.New("maptracker.concurrent_pins is too low")
 	}
+
+	if cfg.RetryBackoff <= 0 {
+		return errors.New("maptracker.retry_backoff is too low")
+	}
+
+	if cfg.MaxRetryBackoff < cfg.RetryBackoff {
+		return errors.New("maptracker.max_retry_backoff is lower than retry_backoff")
+	}
+
+	if cfg.StallTimeout < 0 {
+		return errors.New("maptracker.stall_timeout is invalid")
+	}
 	return nil
 }
 
@@ -71,6 +109,19 @@ func (cfg *Config) LoadJSON(raw []byte) error {
 
 	config.SetIfNotDefault(jcfg.MaxPinQueueSize, &cfg.MaxPinQueueSize)
 	config.SetIfNotDefault(jcfg.ConcurrentPins, &cfg.ConcurrentPins)
+	if jcfg.MaxPinRetries != nil { // 0 disables retries
+		cfg.MaxPinRetries = *jcfg.MaxPinRetries
+	}
+
+	err = config.ParseDurations(
+		"maptracker",
+		&config.DurationOpt{Duration: jcfg.RetryBackoff, Dst: &cfg.RetryBackoff, Name: "retry_backoff"},
+		&config.DurationOpt{Duration: jcfg.MaxRetryBackoff, Dst: &cfg.MaxRetryBackoff, Name: "max_retry_backoff"},
+		&config.DurationOpt{Duration: jcfg.StallTimeout, Dst: &cfg.StallTimeout, Name: "stall_timeout"},
+	)
+	if err != nil {
+		return err
+	}
 
 	return cfg.Validate()
 }
@@ -81,6 +132,10 @@ func (cfg *Config) ToJSON() ([]byte, error) {
 
 	jcfg.MaxPinQueueSize = cfg.MaxPinQueueSize
 	jcfg.ConcurrentPins = cfg.ConcurrentPins
+	jcfg.MaxPinRetries = &cfg.MaxPinRetries
+	jcfg.RetryBackoff = cfg.RetryBackoff.String()
+	jcfg.MaxRetryBackoff = cfg.MaxRetryBackoff.String()
+	jcfg.StallTimeout = cfg.StallTimeout.String()
 
 	return config.DefaultJSONMarshal(jcfg)
 }
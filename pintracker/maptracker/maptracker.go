@@ -6,6 +6,7 @@ import (
 	"context"
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/pintracker/optracker"
@@ -35,10 +36,11 @@ type MapPinTracker struct {
 
 	rpcClient *rpc.Client
 	rpcReady  chan struct{}
+	publisher util.EventPublisher
 
-	peerID  peer.ID
-	pinCh   chan *optracker.Operation
-	unpinCh chan *optracker.Operation
+	peerID     peer.ID
+	pinQueue   *optracker.OperationQueue
+	unpinQueue *optracker.OperationQueue
 
 	shutdownLock sync.Mutex
 	shutdown     bool
@@ -51,48 +53,60 @@ func NewMapPinTracker(cfg *Config, pid peer.ID, peerName string) *MapPinTracker
 	ctx, cancel := context.WithCancel(context.Background())
 
 	mpt := &MapPinTracker{
-		ctx:       ctx,
-		cancel:    cancel,
-		config:    cfg,
-		optracker: optracker.NewOperationTracker(ctx, pid, peerName),
-		rpcReady:  make(chan struct{}, 1),
-		peerID:    pid,
-		pinCh:     make(chan *optracker.Operation, cfg.MaxPinQueueSize),
-		unpinCh:   make(chan *optracker.Operation, cfg.MaxPinQueueSize),
+		ctx:        ctx,
+		cancel:     cancel,
+		config:     cfg,
+		optracker:  optracker.NewOperationTracker(ctx, pid, peerName),
+		rpcReady:   make(chan struct{}, 1),
+		peerID:     pid,
+		pinQueue:   optracker.NewOperationQueue(cfg.MaxPinQueueSize),
+		unpinQueue: optracker.NewOperationQueue(cfg.MaxPinQueueSize),
 	}
 
 	for i := 0; i < mpt.config.ConcurrentPins; i++ {
-		go mpt.opWorker(mpt.pin, mpt.pinCh)
+		go mpt.opWorker(mpt.pin, mpt.pinQueue)
 	}
-	go mpt.opWorker(mpt.unpin, mpt.unpinCh)
+	go mpt.opWorker(mpt.unpin, mpt.unpinQueue)
+	go mpt.watchStalled()
 	return mpt
 }
 
-// receives a pin Function (pin or unpin) and a channel.
+// receives a pin Function (pin or unpin) and a queue.
 // Used for both pinning and unpinning
-func (mpt *MapPinTracker) opWorker(pinF func(*optracker.Operation) error, opChan chan *optracker.Operation) {
+func (mpt *MapPinTracker) opWorker(pinF func(*optracker.Operation) error, queue *optracker.OperationQueue) {
 	for {
 		select {
-		case op := <-opChan:
+		case <-queue.Ready():
+			op, ok := queue.Pop()
+			if !ok {
+				continue
+			}
 			if op.Cancelled() {
 				// operation was cancelled. Move on.
 				// This saves some time, but not 100% needed.
 				continue
 			}
 			op.SetPhase(optracker.PhaseInProgress)
+			op.IncAttempts()
+			util.PublishOpEvent(mpt.publisher, op)
 			err := pinF(op) // call pin/unpin
 			if err != nil {
 				if op.Cancelled() {
 					// there was an error because
-					// we were cancelled. Move on.
+					// we were cancelled. Move on,
+					// retrying stalled operations.
+					mpt.scheduleRetry(op, queue)
 					continue
 				}
 				op.SetError(err)
 				op.Cancel()
+				util.PublishOpEvent(mpt.publisher, op)
+				mpt.scheduleRetry(op, queue)
 				continue
 			}
 			op.SetPhase(optracker.PhaseDone)
 			op.Cancel()
+			util.PublishOpEvent(mpt.publisher, op)
 
 			// We keep all pinned things in the tracker,
 			// only clean unpinned things.
@@ -105,6 +119,17 @@ func (mpt *MapPinTracker) opWorker(pinF func(*optracker.Operation) error, opChan
 	}
 }
 
+// scheduleRetry re-queues a failed operation after a while, unless it has
+// been retried too many times already.
+func (mpt *MapPinTracker) scheduleRetry(op *optracker.Operation, queue *optracker.OperationQueue) {
+	attempts := op.Attempts()
+	if attempts > mpt.config.MaxPinRetries {
+		return
+	}
+	delay := optracker.RetryDelay(mpt.config.RetryBackoff, mpt.config.MaxRetryBackoff, attempts)
+	mpt.optracker.ScheduleRetry(op, queue, delay)
+}
+
 // Shutdown finishes the services provided by the MapPinTracker and cancels
 // any active context.
 func (mpt *MapPinTracker) Shutdown() error {
@@ -158,21 +183,20 @@ func (mpt *MapPinTracker) unpin(op *optracker.Operation) error {
 }
 
 // puts a new operation on the queue, unless ongoing exists
-func (mpt *MapPinTracker) enqueue(c api.Pin, typ optracker.OperationType, ch chan *optracker.Operation) error {
+func (mpt *MapPinTracker) enqueue(c api.Pin, typ optracker.OperationType, queue *optracker.OperationQueue) error {
 	op := mpt.optracker.TrackNewOperation(c, typ, optracker.PhaseQueued)
 	if op == nil {
 		return nil // ongoing pin operation.
 	}
 
-	select {
-	case ch <- op:
-	default:
-		err := errors.New("queue is full")
+	err := queue.Push(op)
+	if err != nil {
 		op.SetError(err)
 		op.Cancel()
 		logger.Error(err.Error())
 		return err
 	}
+	util.PublishOpEvent(mpt.publisher, op)
 	return nil
 }
 
@@ -208,14 +232,14 @@ func (mpt *MapPinTracker) Track(c api.Pin) error {
 		return nil
 	}
 
-	return mpt.enqueue(c, optracker.OperationPin, mpt.pinCh)
+	return mpt.enqueue(c, optracker.OperationPin, mpt.pinQueue)
 }
 
 // Untrack tells the MapPinTracker to stop managing a Cid.
 // If the Cid is pinned locally, it will be unpinned.
 func (mpt *MapPinTracker) Untrack(c cid.Cid) error {
 	logger.Debugf("untracking %s", c)
-	return mpt.enqueue(api.PinCid(c), optracker.OperationUnpin, mpt.unpinCh)
+	return mpt.enqueue(api.PinCid(c), optracker.OperationUnpin, mpt.unpinQueue)
 }
 
 // Status returns information for a Cid tracked by this
@@ -230,6 +254,12 @@ func (mpt *MapPinTracker) StatusAll() []api.PinInfo {
 	return mpt.optracker.GetAll()
 }
 
+// StatusAllPage returns information for the Cids tracked by this
+// MapPinTracker which are selected by the given page, sorted by Cid.
+func (mpt *MapPinTracker) StatusAllPage(page api.ListPage) []api.PinInfo {
+	return mpt.optracker.GetPage(page)
+}
+
 // Sync verifies that the status of a Cid matches that of
 // the IPFS daemon. If not, it will be transitioned
 // to PinError or UnpinError.
@@ -377,9 +407,9 @@ func (mpt *MapPinTracker) Recover(c cid.Cid) (api.PinInfo, error) {
 
 	switch pInfo.Status {
 	case api.TrackerStatusPinError:
-		err = mpt.enqueue(api.PinCid(c), optracker.OperationPin, mpt.pinCh)
+		err = mpt.enqueue(api.PinCid(c), optracker.OperationPin, mpt.pinQueue)
 	case api.TrackerStatusUnpinError:
-		err = mpt.enqueue(api.PinCid(c), optracker.OperationUnpin, mpt.unpinCh)
+		err = mpt.enqueue(api.PinCid(c), optracker.OperationUnpin, mpt.unpinQueue)
 	}
 	return mpt.optracker.Get(c), err
 }
@@ -398,6 +428,75 @@ func (mpt *MapPinTracker) RecoverAll() ([]api.PinInfo, error) {
 	return results, nil
 }
 
+// Operations returns the operations handled by this MapPinTracker which
+// are queued, in progress or in error.
+func (mpt *MapPinTracker) Operations() []api.Operation {
+	return mpt.optracker.Operations()
+}
+
+// CancelOperation cancels the queued or in-progress operation for
+// the given Cid, which is set in error state. Queued operations are
+// removed from the queue. In-progress ones are aborted through their
+// context.
+func (mpt *MapPinTracker) CancelOperation(c cid.Cid) error {
+	op, err := mpt.optracker.Cancel(c)
+	if err != nil {
+		return err
+	}
+	mpt.queueFor(op.Type()).Remove(op)
+	return nil
+}
+
+// SetOperationPriority changes the priority of the queued operation for
+// the given Cid.
+func (mpt *MapPinTracker) SetOperationPriority(c cid.Cid, priority int) error {
+	op, ok := mpt.optracker.Find(c)
+	if !ok || op.Phase() != optracker.PhaseQueued {
+		return optracker.ErrOperationNotQueued
+	}
+	if !mpt.queueFor(op.Type()).SetPriority(op, priority) {
+		return optracker.ErrOperationNotQueued
+	}
+	return nil
+}
+
+// SetProgress records the number of blocks fetched so far by the
+// in-progress pin operation for the given Cid.
+func (mpt *MapPinTracker) SetProgress(c cid.Cid, blocks uint64) error {
+	if !mpt.optracker.SetProgress(c, blocks) {
+		return optracker.ErrNoOngoingOperation
+	}
+	return nil
+}
+
+// watchStalled periodically cancels the pins which make no progress, so
+// that they are retried.
+func (mpt *MapPinTracker) watchStalled() {
+	timeout := mpt.config.StallTimeout
+	if timeout <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			mpt.optracker.CancelStalled(timeout)
+		case <-mpt.ctx.Done():
+			return
+		}
+	}
+}
+
+// queueFor returns the queue used for operations of the given type.
+func (mpt *MapPinTracker) queueFor(typ optracker.OperationType) *optracker.OperationQueue {
+	if typ == optracker.OperationUnpin {
+		return mpt.unpinQueue
+	}
+	return mpt.pinQueue
+}
+
 // SetClient makes the MapPinTracker ready to perform RPC requests to
 // other components.
 func (mpt *MapPinTracker) SetClient(c *rpc.Client) {
@@ -405,6 +504,12 @@ func (mpt *MapPinTracker) SetClient(c *rpc.Client) {
 	mpt.rpcReady <- struct{}{}
 }
 
+// SetEventPublisher makes the tracker publish the phase changes of its
+// operations directly in the event bus of the peer.
+func (mpt *MapPinTracker) SetEventPublisher(publish func(api.Event)) {
+	mpt.publisher = util.EventPublisherFunc(publish)
+}
+
 // OpContext exports the internal optracker's OpContext method.
 // For testing purposes only.
 func (mpt *MapPinTracker) OpContext(c cid.Cid) context.Context {
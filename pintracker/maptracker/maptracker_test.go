@@ -138,6 +138,39 @@ func TestTrack(t *testing.T) {
 	}
 }
 
+func TestTrackEvents(t *testing.T) {
+	mpt := testMapPinTracker(t)
+	defer mpt.Shutdown()
+
+	var mu sync.Mutex
+	var events []api.EventType
+	mpt.SetEventPublisher(func(ev api.Event) {
+		mu.Lock()
+		events = append(events, ev.Type)
+		mu.Unlock()
+	})
+
+	h, _ := cid.Decode(test.TestCid1)
+	err := mpt.Track(testPin(h, -1, -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(200 * time.Millisecond) // let it be pinned
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []api.EventType{api.EventPinQueued, api.EventPinning, api.EventPinDone}
+	if len(events) != len(expected) {
+		t.Fatalf("unexpected events: %s", events)
+	}
+	for i, et := range expected {
+		if events[i] != et {
+			t.Errorf("expected %s and got %s", et, events[i])
+		}
+	}
+}
+
 func TestUntrack(t *testing.T) {
 	mpt := testMapPinTracker(t)
 	defer mpt.Shutdown()
@@ -57,25 +57,39 @@ type Operation struct {
 	opType OperationType
 	pin    api.Pin
 
+	created time.Time
+
 	// RW fields
-	mu    sync.RWMutex
-	phase Phase
-	error string
-	ts    time.Time
+	mu        sync.RWMutex
+	phase     Phase
+	priority  int
+	attempts  int
+	error     string
+	ts        time.Time
+	nextRetry time.Time
+
+	progress     uint64
+	lastProgress time.Time
 }
 
-// NewOperation creates a new Operation.
+// NewOperation creates a new Operation. Its priority is that of the
+// given pin.
 func NewOperation(ctx context.Context, pin api.Pin, typ OperationType, ph Phase) *Operation {
 	ctx, cancel := context.WithCancel(ctx)
+	now := time.Now()
 	return &Operation{
 		ctx:    ctx,
 		cancel: cancel,
 
-		pin:    pin,
-		opType: typ,
-		phase:  ph,
-		ts:     time.Now(),
-		error:  "",
+		pin:      pin,
+		opType:   typ,
+		created:  now,
+		phase:    ph,
+		priority: int(pin.Priority),
+		ts:       now,
+		error:    "",
+
+		lastProgress: now,
 	}
 }
 
@@ -109,6 +123,81 @@ func (op *Operation) SetPhase(ph Phase) {
 	defer op.mu.Unlock()
 	op.phase = ph
 	op.ts = time.Now()
+	op.lastProgress = op.ts
+}
+
+// Progress returns the number of blocks fetched so far by the operation.
+func (op *Operation) Progress() uint64 {
+	op.mu.RLock()
+	defer op.mu.RUnlock()
+	return op.progress
+}
+
+// SetProgress records the number of blocks fetched so far by the
+// operation. The time of the last progress is updated when the number
+// increases.
+func (op *Operation) SetProgress(blocks uint64) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	if blocks > op.progress {
+		op.lastProgress = time.Now()
+	}
+	op.progress = blocks
+}
+
+// LastProgress returns the last time the operation made progress or
+// changed phase.
+func (op *Operation) LastProgress() time.Time {
+	op.mu.RLock()
+	defer op.mu.RUnlock()
+	return op.lastProgress
+}
+
+// Priority returns the priority of the operation. Operations with higher
+// priority are processed first.
+func (op *Operation) Priority() int {
+	op.mu.RLock()
+	defer op.mu.RUnlock()
+	return op.priority
+}
+
+// SetPriority changes the priority of the operation. Use
+// OperationQueue.SetPriority for operations which are queued.
+func (op *Operation) SetPriority(p int) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	op.priority = p
+}
+
+// Attempts returns the number of times that this operation has been
+// started.
+func (op *Operation) Attempts() int {
+	op.mu.RLock()
+	defer op.mu.RUnlock()
+	return op.attempts
+}
+
+// IncAttempts increases the number of attempts for this operation.
+func (op *Operation) IncAttempts() {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	op.attempts++
+}
+
+// NextRetry returns the time at which a failed operation will be retried.
+// It is zero when no retry is scheduled.
+func (op *Operation) NextRetry() time.Time {
+	op.mu.RLock()
+	defer op.mu.RUnlock()
+	return op.nextRetry
+}
+
+// SetNextRetry sets the time at which a failed operation will be retried.
+// A zero time means that no retry is scheduled.
+func (op *Operation) SetNextRetry(t time.Time) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	op.nextRetry = t
 }
 
 // Error returns any error message attached to the operation.
@@ -138,6 +227,11 @@ func (op *Operation) Pin() api.Pin {
 	return op.pin
 }
 
+// Created returns the time when this operation was created.
+func (op *Operation) Created() time.Time {
+	return op.created
+}
+
 // Timestamp returns the time when this operation was
 // last modified (phase changed, error was set...).
 func (op *Operation) Timestamp() time.Time {
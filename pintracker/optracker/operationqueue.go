@@ -0,0 +1,173 @@
+package optracker
+
+import (
+	"container/heap"
+	"errors"
+	"sync"
+)
+
+// ErrQueueFull is returned when trying to push operations into a full
+// OperationQueue.
+var ErrQueueFull = errors.New("queue is full")
+
+// OperationQueue is a bounded priority queue of Operations. Operations with
+// a higher priority are popped first. Operations with the same priority are
+// popped in the order they were pushed.
+//
+// Workers wait on the channel returned by Ready() and then call Pop().
+// Operations can be removed from the queue or have their priority
+// changed while they wait in it.
+type OperationQueue struct {
+	mu    sync.Mutex
+	items opHeap
+	index map[*Operation]*queueItem
+	seq   uint64
+	max   int
+
+	// tokens holds at least as many elements as there are queued
+	// operations.
+	tokens chan struct{}
+}
+
+type queueItem struct {
+	op       *Operation
+	priority int
+	seq      uint64
+	index    int
+}
+
+// NewOperationQueue returns a new queue which can hold up to max
+// operations.
+func NewOperationQueue(max int) *OperationQueue {
+	return &OperationQueue{
+		index:  make(map[*Operation]*queueItem),
+		max:    max,
+		tokens: make(chan struct{}, max),
+	}
+}
+
+// Push adds an operation to the queue using its current priority.
+// It returns ErrQueueFull if the queue holds its maximum number of
+// operations already.
+func (q *OperationQueue) Push(op *Operation) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.index[op]; ok {
+		return nil
+	}
+
+	if len(q.items) >= q.max {
+		return ErrQueueFull
+	}
+
+	q.seq++
+	item := &queueItem{
+		op:       op,
+		priority: op.Priority(),
+		seq:      q.seq,
+	}
+	heap.Push(&q.items, item)
+	q.index[op] = item
+
+	select {
+	case q.tokens <- struct{}{}:
+	default:
+		// there are already as many tokens as the queue can
+		// hold operations.
+	}
+	return nil
+}
+
+// Ready returns a channel which is signaled when an operation may be
+// available. Receiving from it should be followed by a call to Pop().
+func (q *OperationQueue) Ready() <-chan struct{} {
+	return q.tokens
+}
+
+// Pop removes and returns the operation with the highest priority. It
+// returns false when the queue is empty.
+func (q *OperationQueue) Pop() (*Operation, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) == 0 {
+		return nil, false
+	}
+	item := heap.Pop(&q.items).(*queueItem)
+	delete(q.index, item.op)
+	return item.op, true
+}
+
+// Remove takes an operation out of the queue. It returns false if the
+// operation was not queued.
+func (q *OperationQueue) Remove(op *Operation) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	item, ok := q.index[op]
+	if !ok {
+		return false
+	}
+	heap.Remove(&q.items, item.index)
+	delete(q.index, op)
+	return true
+}
+
+// SetPriority changes the priority of a queued operation and re-orders
+// the queue accordingly. It returns false if the operation was not
+// queued.
+func (q *OperationQueue) SetPriority(op *Operation, priority int) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	item, ok := q.index[op]
+	if !ok {
+		return false
+	}
+	op.SetPriority(priority)
+	item.priority = priority
+	heap.Fix(&q.items, item.index)
+	return true
+}
+
+// Len returns the number of queued operations.
+func (q *OperationQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// opHeap implements heap.Interface.
+type opHeap []*queueItem
+
+func (h opHeap) Len() int { return len(h) }
+
+func (h opHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h opHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *opHeap) Push(x interface{}) {
+	item := x.(*queueItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *opHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*h = old[:n-1]
+	return item
+}
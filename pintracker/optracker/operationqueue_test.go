@@ -0,0 +1,99 @@
+package optracker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/test"
+)
+
+func testQueueOperation(t *testing.T, c string, priority int) *Operation {
+	h := test.MustDecodeCid(c)
+	op := NewOperation(context.Background(), api.PinCid(h), OperationPin, PhaseQueued)
+	op.SetPriority(priority)
+	return op
+}
+
+func TestOperationQueue(t *testing.T) {
+	q := NewOperationQueue(3)
+	op1 := testQueueOperation(t, test.TestCid1, 0)
+	op2 := testQueueOperation(t, test.TestCid2, 5)
+	op3 := testQueueOperation(t, test.TestCid3, 0)
+
+	for _, op := range []*Operation{op1, op2, op3} {
+		if err := q.Push(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if q.Len() != 3 {
+		t.Fatal("expected three queued operations")
+	}
+
+	err := q.Push(testQueueOperation(t, test.TestCid4, 0))
+	if err != ErrQueueFull {
+		t.Error("expected ErrQueueFull")
+	}
+
+	// higher priority first, then in push order
+	for _, expected := range []*Operation{op2, op1, op3} {
+		<-q.Ready()
+		op, ok := q.Pop()
+		if !ok {
+			t.Fatal("expected an operation")
+		}
+		if op != expected {
+			t.Errorf("expected %s, got %s", expected.Cid(), op.Cid())
+		}
+	}
+
+	_, ok := q.Pop()
+	if ok {
+		t.Error("queue should be empty")
+	}
+}
+
+func TestOperationQueue_Remove(t *testing.T) {
+	q := NewOperationQueue(3)
+	op1 := testQueueOperation(t, test.TestCid1, 0)
+	op2 := testQueueOperation(t, test.TestCid2, 0)
+	q.Push(op1)
+	q.Push(op2)
+
+	if !q.Remove(op1) {
+		t.Fatal("should have removed the operation")
+	}
+	if q.Remove(op1) {
+		t.Error("should not remove an operation twice")
+	}
+
+	op, ok := q.Pop()
+	if !ok || op != op2 {
+		t.Error("expected the remaining operation")
+	}
+}
+
+func TestOperationQueue_SetPriority(t *testing.T) {
+	q := NewOperationQueue(3)
+	op1 := testQueueOperation(t, test.TestCid1, 0)
+	op2 := testQueueOperation(t, test.TestCid2, 0)
+	q.Push(op1)
+	q.Push(op2)
+
+	if !q.SetPriority(op2, 10) {
+		t.Fatal("should have changed the priority")
+	}
+	if op2.Priority() != 10 {
+		t.Error("operation priority not updated")
+	}
+
+	op, _ := q.Pop()
+	if op != op2 {
+		t.Error("reprioritized operation should be popped first")
+	}
+
+	if q.SetPriority(op2, 1) {
+		t.Error("should not change the priority of operations not queued")
+	}
+}
@@ -7,6 +7,7 @@ package optracker
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"time"
 
@@ -19,6 +20,22 @@ import (
 
 var logger = logging.Logger("optracker")
 
+// Errors returned when handling operations.
+var (
+	// ErrNoOngoingOperation is returned when there is no queued or
+	// in-progress operation for a Cid.
+	ErrNoOngoingOperation = errors.New("no queued or in-progress operation for the given cid")
+	// ErrOperationNotQueued is returned when trying to modify an
+	// operation which is not waiting in a queue.
+	ErrOperationNotQueued = errors.New("the operation for the given cid is not queued")
+	// ErrOperationCancelled is set as error on operations that have
+	// been cancelled by the user.
+	ErrOperationCancelled = errors.New("operation cancelled")
+	// ErrOperationStalled is set as error on in-progress operations
+	// which have been cancelled because they made no progress.
+	ErrOperationStalled = errors.New("operation stalled: no progress")
+)
+
 // OperationTracker tracks and manages all inflight Operations.
 type OperationTracker struct {
 	ctx      context.Context // parent context for all ops
@@ -131,6 +148,24 @@ func (opt *OperationTracker) unsafePinInfo(op *Operation) api.PinInfo {
 		Status:   op.ToTrackerStatus(),
 		TS:       op.Timestamp(),
 		Error:    op.Error(),
+		Progress: op.Progress(),
+	}
+}
+
+func (opt *OperationTracker) unsafeOperation(op *Operation) api.Operation {
+	return api.Operation{
+		Cid:       op.Cid(),
+		Peer:      opt.pid,
+		PeerName:  opt.peerName,
+		Type:      operationTypeString(op.Type()),
+		Phase:     phaseString(op.Phase()),
+		Priority:  op.Priority(),
+		Attempts:  op.Attempts(),
+		Progress:  op.Progress(),
+		Error:     op.Error(),
+		Created:   op.Created(),
+		TS:        op.Timestamp(),
+		NextRetry: op.NextRetry(),
 	}
 }
 
@@ -170,6 +205,171 @@ func (opt *OperationTracker) GetAll() []api.PinInfo {
 	return pinfos
 }
 
+// GetPage returns PinInfo objects for the known operations selected by
+// the given page, sorted by Cid. Only those are built.
+func (opt *OperationTracker) GetPage(page api.ListPage) []api.PinInfo {
+	opt.mu.RLock()
+	defer opt.mu.RUnlock()
+	keys := make([]string, 0, len(opt.operations))
+	for k := range opt.operations {
+		keys = append(keys, k)
+	}
+	keys = page.Cids(keys)
+	pinfos := make([]api.PinInfo, len(keys))
+	for i, k := range keys {
+		pinfos[i] = opt.unsafePinInfo(opt.operations[k])
+	}
+	return pinfos
+}
+
+// Find returns the Operation associated to a Cid, if any.
+func (opt *OperationTracker) Find(c cid.Cid) (*Operation, bool) {
+	opt.mu.RLock()
+	defer opt.mu.RUnlock()
+	op, ok := opt.operations[c.String()]
+	return op, ok
+}
+
+// Operations returns api.Operation objects for all the operations which
+// are not done (queued, in progress or in error).
+func (opt *OperationTracker) Operations() []api.Operation {
+	var ops []api.Operation
+	opt.mu.RLock()
+	defer opt.mu.RUnlock()
+	for _, op := range opt.operations {
+		if op.Phase() == PhaseDone {
+			continue
+		}
+		ops = append(ops, opt.unsafeOperation(op))
+	}
+	return ops
+}
+
+// Cancel cancels the context of a queued or in-progress pin or unpin
+// operation for a Cid and sets it in PhaseError with
+// ErrOperationCancelled. Failed operations waiting to be retried are
+// cancelled too. The cancelled operation is returned so that it can be
+// removed from any queues.
+func (opt *OperationTracker) Cancel(c cid.Cid) (*Operation, error) {
+	op, ok := opt.Find(c)
+	if !ok {
+		return nil, ErrNoOngoingOperation
+	}
+
+	if ty := op.Type(); ty != OperationPin && ty != OperationUnpin {
+		return nil, ErrNoOngoingOperation
+	}
+
+	ph := op.Phase()
+	retrying := ph == PhaseError && !op.NextRetry().IsZero()
+	if ph != PhaseQueued && ph != PhaseInProgress && !retrying {
+		return nil, ErrNoOngoingOperation
+	}
+
+	op.Cancel()
+	op.SetNextRetry(time.Time{})
+	op.SetError(ErrOperationCancelled)
+	logger.Infof("'%s' on cid '%s' has been cancelled", op.Type(), c)
+	return op, nil
+}
+
+// SetProgress records the number of blocks fetched by the in-progress
+// operation for a Cid. It returns false if there is no such operation.
+func (opt *OperationTracker) SetProgress(c cid.Cid, blocks uint64) bool {
+	op, ok := opt.Find(c)
+	if !ok || op.Phase() != PhaseInProgress {
+		return false
+	}
+	op.SetProgress(blocks)
+	return true
+}
+
+// CancelStalled cancels the in-progress pin operations which have not
+// made any progress during the given time and sets them in PhaseError
+// with ErrOperationStalled. The cancelled operations are returned.
+func (opt *OperationTracker) CancelStalled(timeout time.Duration) []*Operation {
+	var stalled []*Operation
+	for _, op := range opt.filterOps(OperationPin, PhaseInProgress) {
+		if time.Since(op.LastProgress()) < timeout {
+			continue
+		}
+		op.SetError(ErrOperationStalled)
+		op.Cancel()
+		logger.Warningf(
+			"'%s' on cid '%s' made no progress for %s and has been cancelled",
+			op.Type(), op.Cid(), timeout,
+		)
+		stalled = append(stalled, op)
+	}
+	return stalled
+}
+
+// RetryDelay returns how long to wait before retrying an operation which
+// has failed the given number of attempts. The delay starts at base and
+// doubles with every attempt, up to max.
+func RetryDelay(base, max time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= max || delay <= 0 {
+			return max
+		}
+	}
+	if delay > max {
+		return max
+	}
+	return delay
+}
+
+// ScheduleRetry arranges for a failed operation to be retried after the
+// given delay. When the time comes, a new operation carrying over the
+// priority and attempts of the failed one replaces it in the tracker and
+// is pushed to the given queue. Nothing is scheduled for operations which
+// are not in PhaseError or have been cancelled. The retry does not happen
+// if the operation has been replaced or cancelled in the meantime.
+func (opt *OperationTracker) ScheduleRetry(op *Operation, queue *OperationQueue, delay time.Duration) {
+	if op.Phase() != PhaseError || op.Error() == ErrOperationCancelled.Error() {
+		return
+	}
+
+	op.SetNextRetry(time.Now().Add(delay))
+	logger.Infof(
+		"'%s' on cid '%s' failed (attempt %d). Retrying in %s",
+		op.Type(), op.Cid(), op.Attempts(), delay,
+	)
+	time.AfterFunc(delay, func() { opt.retry(op, queue) })
+}
+
+func (opt *OperationTracker) retry(op *Operation, queue *OperationQueue) {
+	cidStr := op.Cid().String()
+
+	opt.mu.Lock()
+	if opt.ctx.Err() != nil {
+		opt.mu.Unlock()
+		return
+	}
+	op2, ok := opt.operations[cidStr]
+	if !ok || op2 != op || op.Phase() != PhaseError || op.NextRetry().IsZero() {
+		opt.mu.Unlock()
+		return
+	}
+
+	retryOp := NewOperation(opt.ctx, op.Pin(), op.Type(), PhaseQueued)
+	retryOp.priority = op.Priority()
+	retryOp.attempts = op.Attempts()
+	opt.operations[cidStr] = retryOp
+	opt.mu.Unlock()
+
+	op.Cancel()
+	logger.Debugf("retrying '%s' on cid '%s'", retryOp.Type(), cidStr)
+	err := queue.Push(retryOp)
+	if err != nil {
+		retryOp.SetError(err)
+		retryOp.Cancel()
+		logger.Error(err.Error())
+	}
+}
+
 // CleanError removes the associated Operation, if it is
 // in PhaseError.
 func (opt *OperationTracker) CleanError(c cid.Cid) {
@@ -270,3 +470,35 @@ func filter(in, out map[string]*Operation, filter interface{}) {
 		}
 	}
 }
+
+// operationTypeString returns the name used for OperationTypes in the APIs.
+func operationTypeString(typ OperationType) string {
+	switch typ {
+	case OperationPin:
+		return "pin"
+	case OperationUnpin:
+		return "unpin"
+	case OperationRemote:
+		return "remote"
+	case OperationShard:
+		return "shard"
+	default:
+		return "unknown"
+	}
+}
+
+// phaseString returns the name used for Phases in the APIs.
+func phaseString(ph Phase) string {
+	switch ph {
+	case PhaseError:
+		return "error"
+	case PhaseQueued:
+		return "queued"
+	case PhaseInProgress:
+		return "in_progress"
+	case PhaseDone:
+		return "done"
+	default:
+		return "unknown"
+	}
+}
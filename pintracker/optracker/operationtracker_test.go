@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/test"
@@ -257,3 +258,174 @@ func TestOperationTracker_filterOps(t *testing.T) {
 		}
 	})
 }
+
+func TestOperationTracker_Operations(t *testing.T) {
+	opt := testOperationTracker(t)
+	h1 := test.MustDecodeCid(test.TestCid1)
+	h2 := test.MustDecodeCid(test.TestCid2)
+	opt.TrackNewOperation(api.PinCid(h1), OperationPin, PhaseQueued)
+	opt.TrackNewOperation(api.PinCid(h2), OperationUnpin, PhaseDone)
+
+	ops := opt.Operations()
+	if len(ops) != 1 {
+		t.Fatal("expected one operation")
+	}
+	if !ops[0].Cid.Equals(h1) || ops[0].Type != "pin" || ops[0].Phase != "queued" {
+		t.Error("unexpected operation: ", ops[0])
+	}
+	if ops[0].Peer != test.TestPeerID1 || ops[0].PeerName != test.TestPeerName1 {
+		t.Error("bad peer information")
+	}
+}
+
+func TestOperationTracker_Cancel(t *testing.T) {
+	opt := testOperationTracker(t)
+	h1 := test.MustDecodeCid(test.TestCid1)
+	h2 := test.MustDecodeCid(test.TestCid2)
+	h3 := test.MustDecodeCid(test.TestCid3)
+	op1 := opt.TrackNewOperation(api.PinCid(h1), OperationPin, PhaseInProgress)
+	opt.TrackNewOperation(api.PinCid(h2), OperationRemote, PhaseQueued)
+
+	op, err := opt.Cancel(h1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op != op1 || !op.Cancelled() {
+		t.Error("should have cancelled the operation")
+	}
+	if op.Phase() != PhaseError || op.Error() != ErrOperationCancelled.Error() {
+		t.Error("cancelled operation should be in error")
+	}
+
+	_, err = opt.Cancel(h1)
+	if err != ErrNoOngoingOperation {
+		t.Error("should not cancel operations twice")
+	}
+
+	_, err = opt.Cancel(h2)
+	if err != ErrNoOngoingOperation {
+		t.Error("should not cancel remote operations")
+	}
+
+	_, err = opt.Cancel(h3)
+	if err != ErrNoOngoingOperation {
+		t.Error("should not cancel missing operations")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	base := time.Second
+	max := 10 * time.Second
+	expected := map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		3:  4 * time.Second,
+		4:  8 * time.Second,
+		5:  10 * time.Second,
+		80: 10 * time.Second,
+	}
+	for attempts, d := range expected {
+		if rd := RetryDelay(base, max, attempts); rd != d {
+			t.Errorf("attempts %d: expected %s, got %s", attempts, d, rd)
+		}
+	}
+}
+
+func TestOperationTracker_ScheduleRetry(t *testing.T) {
+	opt := testOperationTracker(t)
+	q := NewOperationQueue(10)
+	h1 := test.MustDecodeCid(test.TestCid1)
+	h2 := test.MustDecodeCid(test.TestCid2)
+
+	pin := api.PinCid(h1)
+	pin.Priority = api.PriorityHigh
+	op := opt.TrackNewOperation(pin, OperationPin, PhaseInProgress)
+	if op.Priority() != int(api.PriorityHigh) {
+		t.Fatal("operation should take the pin priority")
+	}
+	op.IncAttempts()
+	op.SetError(errors.New("fake error"))
+	op.Cancel()
+
+	op2 := opt.TrackNewOperation(api.PinCid(h2), OperationPin, PhaseInProgress)
+	op2.SetError(errors.New("fake error"))
+	op2.Cancel()
+
+	opt.ScheduleRetry(op, q, 50*time.Millisecond)
+	opt.ScheduleRetry(op2, q, 50*time.Millisecond)
+	if op.NextRetry().IsZero() {
+		t.Fatal("a retry should be scheduled")
+	}
+
+	_, err := opt.Cancel(h2)
+	if err != nil {
+		t.Fatal("should be able to cancel scheduled retries: ", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	if q.Len() != 1 {
+		t.Fatal("expected one queued retry")
+	}
+	retryOp, _ := q.Pop()
+	if retryOp == op || !retryOp.Cid().Equals(h1) {
+		t.Fatal("expected a new operation for the failed one")
+	}
+	if retryOp.Phase() != PhaseQueued || retryOp.Attempts() != 1 || retryOp.Priority() != int(api.PriorityHigh) {
+		t.Error("retry should carry over attempts and priority")
+	}
+	if found, _ := opt.Find(h1); found != retryOp {
+		t.Error("retry should replace the failed operation")
+	}
+
+	opt.ScheduleRetry(op2, q, 10*time.Millisecond)
+	if !op2.NextRetry().IsZero() {
+		t.Error("cancelled operations should not be retried")
+	}
+}
+
+func TestOperationTracker_SetProgress(t *testing.T) {
+	opt := testOperationTracker(t)
+	h1 := test.MustDecodeCid(test.TestCid1)
+	h2 := test.MustDecodeCid(test.TestCid2)
+	op := opt.TrackNewOperation(api.PinCid(h1), OperationPin, PhaseInProgress)
+	opt.TrackNewOperation(api.PinCid(h2), OperationPin, PhaseQueued)
+
+	last := op.LastProgress()
+	time.Sleep(10 * time.Millisecond)
+	if !opt.SetProgress(h1, 5) {
+		t.Fatal("should have set the progress")
+	}
+	if op.Progress() != 5 || !op.LastProgress().After(last) {
+		t.Error("progress not recorded")
+	}
+	if opt.Get(h1).Progress != 5 {
+		t.Error("progress should be part of the PinInfo")
+	}
+
+	if opt.SetProgress(h2, 5) {
+		t.Error("should not set the progress of queued operations")
+	}
+}
+
+func TestOperationTracker_CancelStalled(t *testing.T) {
+	opt := testOperationTracker(t)
+	h1 := test.MustDecodeCid(test.TestCid1)
+	h2 := test.MustDecodeCid(test.TestCid2)
+	op1 := opt.TrackNewOperation(api.PinCid(h1), OperationPin, PhaseInProgress)
+	op2 := opt.TrackNewOperation(api.PinCid(h2), OperationPin, PhaseInProgress)
+
+	time.Sleep(100 * time.Millisecond)
+	op2.SetProgress(1)
+
+	stalled := opt.CancelStalled(50 * time.Millisecond)
+	if len(stalled) != 1 || stalled[0] != op1 {
+		t.Fatal("expected one stalled operation")
+	}
+	if !op1.Cancelled() || op1.Error() != ErrOperationStalled.Error() {
+		t.Error("stalled operation should be cancelled and in error")
+	}
+	if op2.Cancelled() {
+		t.Error("operations making progress should not be cancelled")
+	}
+}
@@ -14,6 +14,7 @@ import (
 	ipfscluster "github.com/elastos/Elastos.NET.Hive.Cluster"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/pintracker/maptracker"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/pintracker/optracker"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/pintracker/stateless"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/test"
 
@@ -1030,3 +1031,137 @@ func TestPinTracker_RemoteIgnoresError(t *testing.T) {
 		testF(t, pt)
 	})
 }
+
+func TestPinTracker_CancelOperation(t *testing.T) {
+	testF := func(t *testing.T, pt ipfscluster.PinTracker) {
+		slowCid := test.MustDecodeCid(test.TestSlowCid1)
+		err := pt.Track(api.PinWithOpts(slowCid, pinOpts))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(100 * time.Millisecond) // let pinning start
+
+		ops := pt.Operations()
+		if len(ops) != 1 || ops[0].Phase != "in_progress" {
+			t.Fatal("expected an in-progress operation: ", ops)
+		}
+
+		err = pt.SetOperationPriority(slowCid, 10)
+		if err == nil {
+			t.Error("should not reprioritize in-progress operations")
+		}
+
+		err = pt.CancelOperation(slowCid)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pi := pt.Status(slowCid)
+		if pi.Status != api.TrackerStatusPinError {
+			t.Error("cancelled operation should be in error: ", pi.Status)
+		}
+
+		err = pt.CancelOperation(slowCid)
+		if err == nil {
+			t.Error("should not cancel an operation twice")
+		}
+	}
+
+	t.Run("basic pintracker", func(t *testing.T) {
+		pt := testSlowMapPinTracker(t)
+		testF(t, pt)
+	})
+
+	t.Run("stateless pintracker", func(t *testing.T) {
+		pt := testSlowStatelessPinTracker(t)
+		testF(t, pt)
+	})
+}
+
+func TestPinTracker_RetryFailed(t *testing.T) {
+	testF := func(t *testing.T, pt ipfscluster.PinTracker) {
+		failCid := test.MustDecodeCid(pinCancelCid)
+		err := pt.Track(api.PinWithOpts(failCid, pinOpts))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(time.Second)
+
+		ops := pt.Operations()
+		if len(ops) != 1 {
+			t.Fatal("expected one operation: ", ops)
+		}
+		if ops[0].Phase != "error" {
+			t.Error("operation should be in error: ", ops[0].Phase)
+		}
+		if ops[0].Attempts != 3 {
+			t.Error("expected 3 attempts: ", ops[0].Attempts)
+		}
+		if !ops[0].NextRetry.IsZero() {
+			t.Error("no more retries should be scheduled")
+		}
+	}
+
+	t.Run("basic pintracker", func(t *testing.T) {
+		cfg := &maptracker.Config{}
+		cfg.Default()
+		cfg.MaxPinRetries = 2
+		cfg.RetryBackoff = 100 * time.Millisecond
+		cfg.MaxRetryBackoff = 200 * time.Millisecond
+		pt := maptracker.NewMapPinTracker(cfg, test.TestPeerID1, test.TestPeerName1)
+		pt.SetClient(mockRPCClient(t))
+		defer pt.Shutdown()
+		testF(t, pt)
+	})
+
+	t.Run("stateless pintracker", func(t *testing.T) {
+		cfg := &stateless.Config{}
+		cfg.Default()
+		cfg.MaxPinRetries = 2
+		cfg.RetryBackoff = 100 * time.Millisecond
+		cfg.MaxRetryBackoff = 200 * time.Millisecond
+		pt := stateless.New(cfg, test.TestPeerID1, test.TestPeerName1)
+		pt.SetClient(mockRPCClient(t))
+		defer pt.Shutdown()
+		testF(t, pt)
+	})
+}
+
+func TestPinTracker_StalledPin(t *testing.T) {
+	testF := func(t *testing.T, pt ipfscluster.PinTracker) {
+		slowCid := test.MustDecodeCid(test.TestSlowCid1)
+		err := pt.Track(api.PinWithOpts(slowCid, pinOpts))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(500 * time.Millisecond)
+
+		pi := pt.Status(slowCid)
+		if pi.Status != api.TrackerStatusPinError || pi.Error != optracker.ErrOperationStalled.Error() {
+			t.Errorf("stalled pin should be in error: %s: %s", pi.Status, pi.Error)
+		}
+	}
+
+	t.Run("basic pintracker", func(t *testing.T) {
+		cfg := &maptracker.Config{}
+		cfg.Default()
+		cfg.StallTimeout = 200 * time.Millisecond
+		pt := maptracker.NewMapPinTracker(cfg, test.TestPeerID1, test.TestPeerName1)
+		pt.SetClient(mockRPCClient(t))
+		defer pt.Shutdown()
+		testF(t, pt)
+	})
+
+	t.Run("stateless pintracker", func(t *testing.T) {
+		cfg := &stateless.Config{}
+		cfg.Default()
+		cfg.StallTimeout = 200 * time.Millisecond
+		pt := stateless.New(cfg, test.TestPeerID1, test.TestPeerName1)
+		pt.SetClient(mockRPCClient(t))
+		defer pt.Shutdown()
+		testF(t, pt)
+	})
+}
@@ -3,12 +3,17 @@ package stateless
 import (
 	"encoding/json"
 	"testing"
+	"time"
 )
 
 var cfgJSON = []byte(`
 {
 	"max_pin_queue_size": 4092,
-	"concurrent_pins": 2
+	"concurrent_pins": 2,
+	"max_pin_retries": 3,
+	"retry_backoff": "10s",
+	"max_retry_backoff": "1m",
+	"stall_timeout": "0s"
 }
 `)
 
@@ -31,6 +36,30 @@ func TestLoadJSON(t *testing.T) {
 	if cfg.ConcurrentPins != 10 {
 		t.Error("expected 10 concurrent pins")
 	}
+	if cfg.MaxPinRetries != 3 ||
+		cfg.RetryBackoff != 10*time.Second ||
+		cfg.MaxRetryBackoff != time.Minute ||
+		cfg.StallTimeout != 0 {
+		t.Error("retry options not parsed correctly")
+	}
+
+	zero := 0
+	j.MaxPinRetries = &zero
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MaxPinRetries != 0 {
+		t.Error("0 should disable retries")
+	}
+
+	j.RetryBackoff = "abc"
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected an error parsing retry_backoff")
+	}
 }
 
 func TestToJSON(t *testing.T) {
@@ -58,4 +87,10 @@ func TestDefault(t *testing.T) {
 	if cfg.Validate() == nil {
 		t.Fatal("expected error validating")
 	}
+
+	cfg.Default()
+	cfg.MaxRetryBackoff = cfg.RetryBackoff / 2
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
 }
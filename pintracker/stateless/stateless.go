@@ -11,6 +11,7 @@ import (
 
 	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
 	"github.com/elastos/Elastos.NET.Hive.Cluster/pintracker/optracker"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/pintracker/util"
 
 	cid "github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log"
@@ -34,9 +35,10 @@ type Tracker struct {
 
 	rpcClient *rpc.Client
 	rpcReady  chan struct{}
+	publisher util.EventPublisher
 
-	pinCh   chan *optracker.Operation
-	unpinCh chan *optracker.Operation
+	pinQueue   *optracker.OperationQueue
+	unpinQueue *optracker.OperationQueue
 
 	shutdownMu sync.Mutex
 	shutdown   bool
@@ -48,26 +50,27 @@ func New(cfg *Config, pid peer.ID, peerName string) *Tracker {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	spt := &Tracker{
-		config:    cfg,
-		peerID:    pid,
-		ctx:       ctx,
-		cancel:    cancel,
-		optracker: optracker.NewOperationTracker(ctx, pid, peerName),
-		rpcReady:  make(chan struct{}, 1),
-		pinCh:     make(chan *optracker.Operation, cfg.MaxPinQueueSize),
-		unpinCh:   make(chan *optracker.Operation, cfg.MaxPinQueueSize),
+		config:     cfg,
+		peerID:     pid,
+		ctx:        ctx,
+		cancel:     cancel,
+		optracker:  optracker.NewOperationTracker(ctx, pid, peerName),
+		rpcReady:   make(chan struct{}, 1),
+		pinQueue:   optracker.NewOperationQueue(cfg.MaxPinQueueSize),
+		unpinQueue: optracker.NewOperationQueue(cfg.MaxPinQueueSize),
 	}
 
 	for i := 0; i < spt.config.ConcurrentPins; i++ {
-		go spt.opWorker(spt.pin, spt.pinCh)
+		go spt.opWorker(spt.pin, spt.pinQueue)
 	}
-	go spt.opWorker(spt.unpin, spt.unpinCh)
+	go spt.opWorker(spt.unpin, spt.unpinQueue)
+	go spt.watchStalled()
 	return spt
 }
 
-// receives a pin Function (pin or unpin) and a channel.
+// receives a pin Function (pin or unpin) and a queue.
 // Used for both pinning and unpinning
-func (spt *Tracker) opWorker(pinF func(*optracker.Operation) error, opChan chan *optracker.Operation) {
+func (spt *Tracker) opWorker(pinF func(*optracker.Operation) error, queue *optracker.OperationQueue) {
 	logger.Debug("entering opworker")
 	ticker := time.NewTicker(10 * time.Second) //TODO(ajl): make config var
 	for {
@@ -75,8 +78,22 @@ func (spt *Tracker) opWorker(pinF func(*optracker.Operation) error, opChan chan
 		case <-ticker.C:
 			// every tick, clear out all Done operations
 			spt.optracker.CleanAllDone()
-		case op := <-opChan:
-			if cont := applyPinF(pinF, op); cont {
+		case <-queue.Ready():
+			op, ok := queue.Pop()
+			if !ok {
+				continue
+			}
+			skipped := op.Cancelled()
+			if !skipped {
+				op.SetPhase(optracker.PhaseInProgress)
+				util.PublishOpEvent(spt.publisher, op)
+			}
+			cont := applyPinF(pinF, op)
+			if ph := op.Phase(); !skipped && (ph == optracker.PhaseDone || ph == optracker.PhaseError) {
+				util.PublishOpEvent(spt.publisher, op)
+			}
+			if cont {
+				spt.scheduleRetry(op, queue)
 				continue
 			}
 
@@ -87,6 +104,18 @@ func (spt *Tracker) opWorker(pinF func(*optracker.Operation) error, opChan chan
 	}
 }
 
+// scheduleRetry re-queues a failed operation after a while, unless it has
+// been retried too many times already. Operations which did not fail are
+// ignored.
+func (spt *Tracker) scheduleRetry(op *optracker.Operation, queue *optracker.OperationQueue) {
+	attempts := op.Attempts()
+	if attempts > spt.config.MaxPinRetries {
+		return
+	}
+	delay := optracker.RetryDelay(spt.config.RetryBackoff, spt.config.MaxRetryBackoff, attempts)
+	spt.optracker.ScheduleRetry(op, queue, delay)
+}
+
 // applyPinF returns true if caller should call `continue` inside calling loop.
 func applyPinF(pinF func(*optracker.Operation) error, op *optracker.Operation) bool {
 	if op.Cancelled() {
@@ -95,6 +124,7 @@ func applyPinF(pinF func(*optracker.Operation) error, op *optracker.Operation) b
 		return true
 	}
 	op.SetPhase(optracker.PhaseInProgress)
+	op.IncAttempts()
 	err := pinF(op) // call pin/unpin
 	if err != nil {
 		if op.Cancelled() {
@@ -151,26 +181,25 @@ func (spt *Tracker) enqueue(c api.Pin, typ optracker.OperationType) error {
 		return nil // ongoing pin operation.
 	}
 
-	var ch chan *optracker.Operation
+	var queue *optracker.OperationQueue
 
 	switch typ {
 	case optracker.OperationPin:
-		ch = spt.pinCh
+		queue = spt.pinQueue
 	case optracker.OperationUnpin:
-		ch = spt.unpinCh
+		queue = spt.unpinQueue
 	default:
-		return errors.New("operation doesn't have a associated channel")
+		return errors.New("operation doesn't have a associated queue")
 	}
 
-	select {
-	case ch <- op:
-	default:
-		err := errors.New("queue is full")
+	err := queue.Push(op)
+	if err != nil {
 		op.SetError(err)
 		op.Cancel()
 		logger.Error(err.Error())
 		return err
 	}
+	util.PublishOpEvent(spt.publisher, op)
 	return nil
 }
 
@@ -181,6 +210,12 @@ func (spt *Tracker) SetClient(c *rpc.Client) {
 	spt.rpcReady <- struct{}{}
 }
 
+// SetEventPublisher makes the tracker publish the phase changes of its
+// operations directly in the event bus of the peer.
+func (spt *Tracker) SetEventPublisher(publish func(api.Event)) {
+	spt.publisher = util.EventPublisherFunc(publish)
+}
+
 // Shutdown finishes the services provided by the StatelessPinTracker
 // and cancels any active context.
 func (spt *Tracker) Shutdown() error {
@@ -301,7 +336,13 @@ func (spt *Tracker) Status(c cid.Cid) api.PinInfo {
 		}
 	}
 
-	gpin := gpinS.ToPin()
+	return spt.pinStatus(gpinS.ToPin())
+}
+
+// pinStatus returns the local status of a pin from the shared state
+// which has no operation in the optracker.
+func (spt *Tracker) pinStatus(gpin api.Pin) api.PinInfo {
+	c := gpin.Cid
 
 	// check if pin is a meta pin
 	if gpin.Type == api.MetaType {
@@ -325,7 +366,7 @@ func (spt *Tracker) Status(c cid.Cid) api.PinInfo {
 
 	// else attempt to get status from ipfs node
 	var ips api.IPFSPinStatus
-	err = spt.rpcClient.Call(
+	err := spt.rpcClient.Call(
 		"",
 		"Cluster",
 		"IPFSPinLsCid",
@@ -337,14 +378,74 @@ func (spt *Tracker) Status(c cid.Cid) api.PinInfo {
 		return api.PinInfo{}
 	}
 
-	pi := api.PinInfo{
+	return api.PinInfo{
 		Cid:    c,
 		Peer:   spt.peerID,
 		Status: ips.ToTrackerStatus(),
 		TS:     time.Now(),
 	}
+}
+
+// StatusAllPage returns information for the pins selected by the given
+// page, sorted by Cid. Only the pins in the page are looked up in the
+// IPFS daemon, one by one, so a page without limit falls back to
+// StatusAll(). As with StatusAll(), pins allocated to this peer which are
+// not pinned in IPFS are left out, and further pages of the shared state
+// are looked up until the page is full.
+func (spt *Tracker) StatusAllPage(page api.ListPage) []api.PinInfo {
+	if page.Limit <= 0 {
+		return page.PinInfos(spt.StatusAll())
+	}
+
+	// The inflight operations take precedence over the state, and
+	// include unpins of Cids which are no longer in it.
+	pininfos := make(map[string]api.PinInfo)
+	for _, infop := range spt.optracker.GetPage(page) {
+		pininfos[infop.Cid.String()] = infop
+	}
+
+	statePage := page
+	found := 0
+	for found < page.Limit {
+		var statePinsSerial []api.PinSerial
+		err := spt.rpcClient.Call(
+			"",
+			"Cluster",
+			"PinsPage",
+			statePage,
+			&statePinsSerial,
+		)
+		if err != nil {
+			logger.Error(err)
+			return nil
+		}
 
-	return pi
+		for _, pinS := range statePinsSerial {
+			pin := pinS.ToPin()
+			if _, ok := pininfos[pin.Cid.String()]; ok {
+				found++
+				continue
+			}
+			pi := spt.pinStatus(pin)
+			if pi.Cid.Defined() && pi.Status != api.TrackerStatusUnpinned {
+				pininfos[pin.Cid.String()] = pi
+				found++
+			}
+		}
+
+		n := len(statePinsSerial)
+		if n < statePage.Limit {
+			break
+		}
+		statePage.Cursor = statePinsSerial[n-1].Cid
+	}
+
+	pis := make([]api.PinInfo, 0, len(pininfos))
+	for _, pi := range pininfos {
+		pis = append(pis, pi)
+	}
+	// Both selections are only complete up to the page.Limit-th Cid.
+	return page.PinInfos(pis)
 }
 
 // SyncAll verifies that the statuses of all tracked Cids (from the shared state)
@@ -598,6 +699,75 @@ func (spt *Tracker) getErrorsAll() []api.PinInfo {
 	return spt.optracker.Filter(optracker.PhaseError)
 }
 
+// Operations returns the operations handled by this StatelessPinTracker
+// which are queued, in progress or in error.
+func (spt *Tracker) Operations() []api.Operation {
+	return spt.optracker.Operations()
+}
+
+// CancelOperation cancels the queued or in-progress operation for
+// the given Cid, which is set in error state. Queued operations are
+// removed from the queue. In-progress ones are aborted through their
+// context.
+func (spt *Tracker) CancelOperation(c cid.Cid) error {
+	op, err := spt.optracker.Cancel(c)
+	if err != nil {
+		return err
+	}
+	spt.queueFor(op.Type()).Remove(op)
+	return nil
+}
+
+// SetOperationPriority changes the priority of the queued operation for
+// the given Cid.
+func (spt *Tracker) SetOperationPriority(c cid.Cid, priority int) error {
+	op, ok := spt.optracker.Find(c)
+	if !ok || op.Phase() != optracker.PhaseQueued {
+		return optracker.ErrOperationNotQueued
+	}
+	if !spt.queueFor(op.Type()).SetPriority(op, priority) {
+		return optracker.ErrOperationNotQueued
+	}
+	return nil
+}
+
+// SetProgress records the number of blocks fetched so far by the
+// in-progress pin operation for the given Cid.
+func (spt *Tracker) SetProgress(c cid.Cid, blocks uint64) error {
+	if !spt.optracker.SetProgress(c, blocks) {
+		return optracker.ErrNoOngoingOperation
+	}
+	return nil
+}
+
+// watchStalled periodically cancels the pins which make no progress, so
+// that they are retried.
+func (spt *Tracker) watchStalled() {
+	timeout := spt.config.StallTimeout
+	if timeout <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			spt.optracker.CancelStalled(timeout)
+		case <-spt.ctx.Done():
+			return
+		}
+	}
+}
+
+// queueFor returns the queue used for operations of the given type.
+func (spt *Tracker) queueFor(typ optracker.OperationType) *optracker.OperationQueue {
+	if typ == optracker.OperationUnpin {
+		return spt.unpinQueue
+	}
+	return spt.pinQueue
+}
+
 // OpContext exports the internal optracker's OpContext method.
 // For testing purposes only.
 func (spt *Tracker) OpContext(c cid.Cid) context.Context {
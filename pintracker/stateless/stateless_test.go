@@ -87,6 +87,21 @@ func (mock *mockService) Pins(ctx context.Context, in struct{}, out *[]api.PinSe
 	return nil
 }
 
+func (mock *mockService) PinsPage(ctx context.Context, in api.ListPage, out *[]api.PinSerial) error {
+	var pinsS []api.PinSerial
+	mock.Pins(ctx, struct{}{}, &pinsS)
+	pins := make([]api.Pin, len(pinsS), len(pinsS))
+	for i, p := range pinsS {
+		pins[i] = p.ToPin()
+	}
+	pins = in.Pins(pins)
+	*out = make([]api.PinSerial, len(pins), len(pins))
+	for i, p := range pins {
+		(*out)[i] = p.ToSerial()
+	}
+	return nil
+}
+
 func (mock *mockService) PinGet(ctx context.Context, in api.PinSerial, out *api.PinSerial) error {
 	switch in.Cid {
 	case test.ErrorCid:
@@ -360,6 +375,58 @@ var sortPinInfoByCid = func(p []api.PinInfo) {
 	})
 }
 
+func TestStatelessTracker_StatusAllPage(t *testing.T) {
+	spt := testStatelessPinTracker(t)
+	defer spt.Shutdown()
+
+	all := api.ListPage{}.PinInfos(spt.StatusAll())
+	if len(all) != 3 {
+		t.Fatal("expected 3 pins:", all)
+	}
+
+	page := spt.StatusAllPage(api.ListPage{Limit: 2})
+	if len(page) != 2 {
+		t.Fatal("expected a page of 2 pins:", page)
+	}
+	for i := range page {
+		if !page[i].Cid.Equals(all[i].Cid) || page[i].Status != all[i].Status {
+			t.Errorf("got %s %s, want %s %s", page[i].Cid, page[i].Status, all[i].Cid, all[i].Status)
+		}
+	}
+
+	page = spt.StatusAllPage(api.ListPage{Cursor: page[1].Cid.String(), Limit: 2})
+	if len(page) != 1 || !page[0].Cid.Equals(all[2].Cid) {
+		t.Error("unexpected last page:", page)
+	}
+}
+
+func TestStatelessTracker_StatusAllPageUnpinned(t *testing.T) {
+	// TestCid3 is allocated to the peer but not pinned in IPFS.
+	spt := testSlowStatelessPinTracker(t)
+	defer spt.Shutdown()
+
+	all := api.ListPage{}.PinInfos(spt.StatusAll())
+	var paged []api.PinInfo
+	page := api.ListPage{Limit: 1}
+	for {
+		pis := spt.StatusAllPage(page)
+		if len(pis) == 0 {
+			break
+		}
+		paged = append(paged, pis...)
+		page.Cursor = pis[len(pis)-1].Cid.String()
+	}
+
+	if len(paged) != len(all) {
+		t.Fatalf("pages list %d pins, StatusAll %d", len(paged), len(all))
+	}
+	for i := range all {
+		if !paged[i].Cid.Equals(all[i].Cid) || paged[i].Status != all[i].Status {
+			t.Errorf("got %s %s, want %s %s", paged[i].Cid, paged[i].Status, all[i].Cid, all[i].Status)
+		}
+	}
+}
+
 func TestStatelessTracker_SyncAll(t *testing.T) {
 	type args struct {
 		cs      []cid.Cid
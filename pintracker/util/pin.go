@@ -2,6 +2,7 @@ package util
 
 import (
 	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/pintracker/optracker"
 
 	peer "github.com/libp2p/go-libp2p-peer"
 )
@@ -20,3 +21,56 @@ func IsRemotePin(c api.Pin, pid peer.ID) bool {
 	}
 	return true
 }
+
+// EventPublisher places events in the event bus of a cluster peer.
+type EventPublisher interface {
+	PublishEvent(api.Event)
+}
+
+// EventPublisherFunc adapts a function to the EventPublisher interface.
+type EventPublisherFunc func(api.Event)
+
+// PublishEvent calls f(ev).
+func (f EventPublisherFunc) PublishEvent(ev api.Event) {
+	f(ev)
+}
+
+// PublishOpEvent publishes an event describing the current phase of a
+// pin or unpin operation.
+func PublishOpEvent(pub EventPublisher, op *optracker.Operation) {
+	if pub == nil {
+		return
+	}
+
+	var pinEv, unpinEv api.EventType
+	switch op.Phase() {
+	case optracker.PhaseQueued:
+		pinEv, unpinEv = api.EventPinQueued, api.EventUnpinQueued
+	case optracker.PhaseInProgress:
+		pinEv, unpinEv = api.EventPinning, api.EventUnpinning
+	case optracker.PhaseDone:
+		pinEv, unpinEv = api.EventPinDone, api.EventUnpinDone
+	case optracker.PhaseError:
+		pinEv, unpinEv = api.EventPinError, api.EventUnpinError
+	default:
+		return
+	}
+
+	ev := api.Event{
+		Cid:  op.Cid(),
+		Name: op.Pin().Name,
+	}
+	if op.Phase() == optracker.PhaseError {
+		ev.Message = op.Error()
+	}
+	switch op.Type() {
+	case optracker.OperationPin:
+		ev.Type = pinEv
+	case optracker.OperationUnpin:
+		ev.Type = unpinEv
+	default:
+		return
+	}
+
+	pub.PublishEvent(ev)
+}
@@ -0,0 +1,330 @@
+package ipfscluster
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-peer"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+)
+
+// rebalancePollInterval is how often the status of moved allocations is
+// checked while they are pinned in their new peers.
+var rebalancePollInterval = 5 * time.Second
+
+// Rebalance plans moving allocations from the most loaded peer to the least
+// loaded one, according to the RebalanceMetric, and, unless dryRun is set,
+// starts moving them. The cluster leader does the work. The report lists
+// the planned moves, which happen in the background: every allocation is
+// pinned in the new peer before it is removed from the old one.
+func (c *Cluster) Rebalance(dryRun bool) (api.RebalanceReport, error) {
+	leader, err := c.consensus.Leader()
+	if err != nil {
+		return api.RebalanceReport{}, err
+	}
+	if leader == c.id {
+		return c.RebalanceLocal(dryRun)
+	}
+
+	var reportS api.RebalanceReportSerial
+	err = c.rpcClient.Call(
+		leader,
+		"Cluster",
+		"RebalanceLocal",
+		dryRun,
+		&reportS,
+	)
+	if err != nil {
+		return api.RebalanceReport{}, err
+	}
+	return reportS.ToRebalanceReport(), nil
+}
+
+// RebalanceLocal performs a rebalancing round from this peer. See
+// Rebalance().
+func (c *Cluster) RebalanceLocal(dryRun bool) (api.RebalanceReport, error) {
+	if !dryRun && !atomic.CompareAndSwapInt32(&c.rebalancing, 0, 1) {
+		return api.RebalanceReport{}, errors.New("a rebalance is already in progress")
+	}
+
+	report, err := c.planRebalance()
+	report.DryRun = dryRun
+	if dryRun {
+		return report, err
+	}
+	if err != nil || len(report.Moves) == 0 {
+		atomic.StoreInt32(&c.rebalancing, 0)
+		return report, err
+	}
+
+	logger.Infof("rebalancing: moving %d allocations", len(report.Moves))
+	go func() {
+		defer atomic.StoreInt32(&c.rebalancing, 0)
+		c.moveAllocations(report.Moves, c.config.RebalancePinTimeout)
+	}()
+	return report, nil
+}
+
+// rebalanceLoads returns the load of every peer which can take part in a
+// rebalance, as given by its metric, and records it in the report.
+// Draining peers and peers which cannot be allocated pins because of an
+// alert are left out, so that no pins are moved to them.
+func (c *Cluster) rebalanceLoads(metrics []api.Metric, report *api.RebalanceReport) map[peer.ID]float64 {
+	draining := c.drainingPeers()
+	loads := make(map[peer.ID]float64)
+	for _, m := range metrics {
+		if m.Discard() || containsPeer(draining, m.Peer) || c.allocationBlocked(m.Peer) {
+			continue
+		}
+		v, err := strconv.ParseFloat(m.Value, 64)
+		if err != nil {
+			logger.Debugf("%s: bad %s metric value: %s", m.Peer.Pretty(), m.Name, m.Value)
+			continue
+		}
+		report.Loads[m.Peer] = m.Value
+		if c.config.RebalanceMetricInverse {
+			v = -v
+		}
+		loads[m.Peer] = v
+	}
+	return loads
+}
+
+// planRebalance compares the RebalanceMetric of all peers and, when the
+// difference between the most and the least loaded peers is above
+// RebalanceThreshold, picks pins allocated to the former which can be
+// moved to the latter.
+func (c *Cluster) planRebalance() (api.RebalanceReport, error) {
+	report := api.RebalanceReport{
+		Peer:   c.id,
+		TS:     time.Now(),
+		Metric: c.config.RebalanceMetric,
+		Loads:  make(map[peer.ID]string),
+		Moves:  make([]api.RebalanceMove, 0),
+	}
+
+	if !c.hasInformer(c.config.RebalanceMetric) {
+		return report, fmt.Errorf("no informer provides the rebalance metric: %s", c.config.RebalanceMetric)
+	}
+
+	loads := c.rebalanceLoads(c.monitor.LatestMetrics(c.config.RebalanceMetric), &report)
+	most, least, ok := mostAndLeastLoaded(loads, c.config.RebalanceThreshold)
+	if !ok {
+		return report, nil
+	}
+
+	cState, err := c.consensus.State()
+	if err != nil {
+		logger.Error(err)
+		return report, err
+	}
+
+	var candidates []api.Pin
+	mostAllocs := 0
+	for _, pin := range cState.List() {
+		if !containsPeer(pin.Allocations, most) {
+			continue
+		}
+		mostAllocs++
+		if pin.Type == api.MetaType || pin.HasTagConstraints() {
+			// tag-constrained pins stay where the allocator put them
+			continue
+		}
+		if containsPeer(pin.Allocations, least) {
+			continue
+		}
+		candidates = append(candidates, pin)
+	}
+
+	limit := c.config.RebalanceMaxMoves
+	if !c.config.RebalanceMetricInverse && mostAllocs > 0 {
+		// Estimate how much every allocation adds to the metric
+		// so that the round does not overshoot.
+		perAlloc := math.Abs(loads[most]) / float64(mostAllocs)
+		if perAlloc > 0 {
+			needed := int((loads[most] - loads[least]) / 2 / perAlloc)
+			if needed < 1 {
+				needed = 1
+			}
+			if needed < limit {
+				limit = needed
+			}
+		}
+	}
+
+	for _, pin := range candidates {
+		if len(report.Moves) >= limit {
+			break
+		}
+		report.Moves = append(report.Moves, api.RebalanceMove{
+			Cid:  pin.Cid,
+			Name: pin.Name,
+			From: most,
+			To:   least,
+		})
+	}
+	return report, nil
+}
+
+// mostAndLeastLoaded returns the peers with the largest and the smallest
+// loads, as long as their relative difference is above the threshold.
+func mostAndLeastLoaded(loads map[peer.ID]float64, threshold float64) (peer.ID, peer.ID, bool) {
+	if len(loads) < 2 {
+		return "", "", false
+	}
+
+	peers := make([]peer.ID, 0, len(loads))
+	for p := range loads {
+		peers = append(peers, p)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		li, lj := loads[peers[i]], loads[peers[j]]
+		if li != lj {
+			return li > lj
+		}
+		return peers[i] < peers[j]
+	})
+
+	most := peers[0]
+	least := peers[len(peers)-1]
+	diff := loads[most] - loads[least]
+	scale := math.Max(math.Abs(loads[most]), math.Abs(loads[least]))
+	if diff <= 0 || scale == 0 || diff/scale <= threshold {
+		return "", "", false
+	}
+	return most, least, true
+}
+
+// moveAllocations adds the new peer to the allocations of every move and,
+// once it has pinned the content, removes the old one. Moves which fail
+// or do not complete within the given timeout are reverted.
+func (c *Cluster) moveAllocations(moves []api.RebalanceMove, pinTimeout time.Duration) {
+	pending := make([]api.RebalanceMove, 0, len(moves))
+	for _, m := range moves {
+		pin, err := c.PinGet(m.Cid)
+		if err != nil || !containsPeer(pin.Allocations, m.From) || containsPeer(pin.Allocations, m.To) {
+			logger.Debugf("rebalancing: skipping %s, allocations changed", m.Cid)
+			continue
+		}
+		pin.Allocations = append(append([]peer.ID{}, pin.Allocations...), m.To)
+		err = c.consensus.LogPin(pin)
+		if err != nil {
+			logger.Error(err)
+			continue
+		}
+		pending = append(pending, m)
+	}
+
+	ticker := time.NewTicker(rebalancePollInterval)
+	defer ticker.Stop()
+	timeout := time.NewTimer(pinTimeout)
+	defer timeout.Stop()
+
+	for len(pending) > 0 {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-timeout.C:
+			for _, m := range pending {
+				logger.Warningf("rebalancing: %s not pinned in %s in time. Reverting", m.Cid, m.To.Pretty())
+				c.replaceAllocation(m.Cid, m.To, "")
+			}
+			return
+		case <-ticker.C:
+			stillPending := pending[:0]
+			for _, m := range pending {
+				switch status := c.moveStatus(m); {
+				case status == api.TrackerStatusPinned:
+					logger.Infof("rebalancing: moved %s from %s to %s", m.Cid, m.From.Pretty(), m.To.Pretty())
+					c.replaceAllocation(m.Cid, m.From, "")
+				case status.Match(api.TrackerStatusError):
+					logger.Warningf("rebalancing: %s failed to pin in %s. Reverting", m.Cid, m.To.Pretty())
+					c.replaceAllocation(m.Cid, m.To, "")
+				default:
+					stillPending = append(stillPending, m)
+				}
+			}
+			pending = stillPending
+		}
+	}
+}
+
+// moveStatus returns the status of the pin of a move in its new peer.
+func (c *Cluster) moveStatus(m api.RebalanceMove) api.TrackerStatus {
+	var pinfo api.PinInfoSerial
+	err := c.rpcClient.Call(
+		m.To,
+		"Cluster",
+		"TrackerStatus",
+		api.PinCid(m.Cid).ToSerial(),
+		&pinfo,
+	)
+	if err != nil {
+		logger.Debug(err)
+		return api.TrackerStatusUndefined
+	}
+	return pinfo.ToPinInfo().Status
+}
+
+// replaceAllocation removes a peer from the allocations of a pin and,
+// when given, adds another one.
+func (c *Cluster) replaceAllocation(h cid.Cid, remove, add peer.ID) {
+	pin, err := c.PinGet(h)
+	if err != nil {
+		// unpinned meanwhile
+		return
+	}
+
+	allocs := make([]peer.ID, 0, len(pin.Allocations))
+	for _, p := range pin.Allocations {
+		if p != remove {
+			allocs = append(allocs, p)
+		}
+	}
+	if add != "" && !containsPeer(allocs, add) {
+		allocs = append(allocs, add)
+	}
+	pin.Allocations = allocs
+
+	err = c.consensus.LogPin(pin)
+	if err != nil {
+		logger.Error(err)
+	}
+}
+
+// rebalanceWatcher triggers a rebalancing round every RebalanceInterval
+// when this peer is the cluster leader.
+func (c *Cluster) rebalanceWatcher() {
+	if c.config.RebalanceInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(c.config.RebalanceInterval)
+	for {
+		select {
+		case <-c.ctx.Done():
+			ticker.Stop()
+			return
+		case <-ticker.C:
+			leader, err := c.consensus.Leader()
+			if err != nil || leader != c.id {
+				continue
+			}
+			if atomic.LoadInt32(&c.rebalancing) == 1 {
+				continue
+			}
+			logger.Debug("auto-triggering RebalanceLocal()")
+			_, err = c.RebalanceLocal(false)
+			if err != nil {
+				logger.Error(err)
+			}
+		}
+	}
+}
@@ -2,10 +2,12 @@ package ipfscluster
 
 import (
 	"context"
+	"errors"
 
 	peer "github.com/libp2p/go-libp2p-peer"
 
 	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/observations"
 )
 
 // RPCAPI is a go-libp2p-gorpc service which provides the internal ipfs-cluster
@@ -19,30 +21,84 @@ type RPCAPI struct {
 	c *Cluster
 }
 
+// observeRPC counts a request served by the RPC API and whether it
+// returned an error.
+func observeRPC(method string, err *error) {
+	observations.RPCCalls.Inc(method)
+	if *err != nil {
+		observations.RPCErrors.Inc(method)
+	}
+}
+
 /*
    Cluster components methods
 */
 
 // ID runs Cluster.ID()
-func (rpcapi *RPCAPI) ID(ctx context.Context, in struct{}, out *api.IDSerial) error {
+func (rpcapi *RPCAPI) ID(ctx context.Context, in struct{}, out *api.IDSerial) (err error) {
+	defer observeRPC("ID", &err)
 	id := rpcapi.c.ID().ToSerial()
 	*out = id
 	return nil
 }
 
 // Pin runs Cluster.Pin().
-func (rpcapi *RPCAPI) Pin(ctx context.Context, in api.PinSerial, out *struct{}) error {
+func (rpcapi *RPCAPI) Pin(ctx context.Context, in api.PinSerial, out *struct{}) (err error) {
+	defer observeRPC("Pin", &err)
 	return rpcapi.c.Pin(in.ToPin())
 }
 
 // Unpin runs Cluster.Unpin().
-func (rpcapi *RPCAPI) Unpin(ctx context.Context, in api.PinSerial, out *struct{}) error {
+func (rpcapi *RPCAPI) Unpin(ctx context.Context, in api.PinSerial, out *struct{}) (err error) {
+	defer observeRPC("Unpin", &err)
 	c := in.DecodeCid()
 	return rpcapi.c.Unpin(c)
 }
 
+// Operations runs Cluster.Operations().
+func (rpcapi *RPCAPI) Operations(ctx context.Context, in struct{}, out *[]api.OperationSerial) (err error) {
+	defer observeRPC("Operations", &err)
+	ops, err := rpcapi.c.Operations()
+	*out = operationSliceToSerial(ops)
+	return err
+}
+
+// OperationsLocal runs Cluster.OperationsLocal().
+func (rpcapi *RPCAPI) OperationsLocal(ctx context.Context, in struct{}, out *[]api.OperationSerial) (err error) {
+	defer observeRPC("OperationsLocal", &err)
+	*out = operationSliceToSerial(rpcapi.c.OperationsLocal())
+	return nil
+}
+
+// CancelOperation runs Cluster.CancelOperation().
+func (rpcapi *RPCAPI) CancelOperation(ctx context.Context, in api.OperationSerial, out *struct{}) (err error) {
+	defer observeRPC("CancelOperation", &err)
+	return rpcapi.c.CancelOperation(in.ToOperation().Cid)
+}
+
+// CancelOperationLocal runs Cluster.CancelOperationLocal().
+func (rpcapi *RPCAPI) CancelOperationLocal(ctx context.Context, in api.OperationSerial, out *struct{}) (err error) {
+	defer observeRPC("CancelOperationLocal", &err)
+	return rpcapi.c.CancelOperationLocal(in.ToOperation().Cid)
+}
+
+// SetOperationPriority runs Cluster.SetOperationPriority().
+func (rpcapi *RPCAPI) SetOperationPriority(ctx context.Context, in api.OperationSerial, out *struct{}) (err error) {
+	defer observeRPC("SetOperationPriority", &err)
+	op := in.ToOperation()
+	return rpcapi.c.SetOperationPriority(op.Cid, op.Priority)
+}
+
+// SetOperationPriorityLocal runs Cluster.SetOperationPriorityLocal().
+func (rpcapi *RPCAPI) SetOperationPriorityLocal(ctx context.Context, in api.OperationSerial, out *struct{}) (err error) {
+	defer observeRPC("SetOperationPriorityLocal", &err)
+	op := in.ToOperation()
+	return rpcapi.c.SetOperationPriorityLocal(op.Cid, op.Priority)
+}
+
 // Pins runs Cluster.Pins().
-func (rpcapi *RPCAPI) Pins(ctx context.Context, in struct{}, out *[]api.PinSerial) error {
+func (rpcapi *RPCAPI) Pins(ctx context.Context, in struct{}, out *[]api.PinSerial) (err error) {
+	defer observeRPC("Pins", &err)
 	cidList := rpcapi.c.Pins()
 	cidSerialList := make([]api.PinSerial, 0, len(cidList))
 	for _, c := range cidList {
@@ -52,8 +108,21 @@ func (rpcapi *RPCAPI) Pins(ctx context.Context, in struct{}, out *[]api.PinSeria
 	return nil
 }
 
+// PinsPage runs Cluster.PinsPage().
+func (rpcapi *RPCAPI) PinsPage(ctx context.Context, in api.ListPage, out *[]api.PinSerial) (err error) {
+	defer observeRPC("PinsPage", &err)
+	cidList := rpcapi.c.PinsPage(in)
+	cidSerialList := make([]api.PinSerial, 0, len(cidList))
+	for _, c := range cidList {
+		cidSerialList = append(cidSerialList, c.ToSerial())
+	}
+	*out = cidSerialList
+	return nil
+}
+
 // PinGet runs Cluster.PinGet().
-func (rpcapi *RPCAPI) PinGet(ctx context.Context, in api.PinSerial, out *api.PinSerial) error {
+func (rpcapi *RPCAPI) PinGet(ctx context.Context, in api.PinSerial, out *api.PinSerial) (err error) {
+	defer observeRPC("PinGet", &err)
 	cidarg := in.ToPin()
 	pin, err := rpcapi.c.PinGet(cidarg.Cid)
 	if err == nil {
@@ -63,7 +132,8 @@ func (rpcapi *RPCAPI) PinGet(ctx context.Context, in api.PinSerial, out *api.Pin
 }
 
 // Version runs Cluster.Version().
-func (rpcapi *RPCAPI) Version(ctx context.Context, in struct{}, out *api.Version) error {
+func (rpcapi *RPCAPI) Version(ctx context.Context, in struct{}, out *api.Version) (err error) {
+	defer observeRPC("Version", &err)
 	*out = api.Version{
 		Version: rpcapi.c.Version(),
 	}
@@ -71,7 +141,8 @@ func (rpcapi *RPCAPI) Version(ctx context.Context, in struct{}, out *api.Version
 }
 
 // Peers runs Cluster.Peers().
-func (rpcapi *RPCAPI) Peers(ctx context.Context, in struct{}, out *[]api.IDSerial) error {
+func (rpcapi *RPCAPI) Peers(ctx context.Context, in struct{}, out *[]api.IDSerial) (err error) {
+	defer observeRPC("Peers", &err)
 	peers := rpcapi.c.Peers()
 	var sPeers []api.IDSerial
 	for _, p := range peers {
@@ -82,7 +153,8 @@ func (rpcapi *RPCAPI) Peers(ctx context.Context, in struct{}, out *[]api.IDSeria
 }
 
 // PeerAdd runs Cluster.PeerAdd().
-func (rpcapi *RPCAPI) PeerAdd(ctx context.Context, in string, out *api.IDSerial) error {
+func (rpcapi *RPCAPI) PeerAdd(ctx context.Context, in string, out *api.IDSerial) (err error) {
+	defer observeRPC("PeerAdd", &err)
 	pid, _ := peer.IDB58Decode(in)
 	id, err := rpcapi.c.PeerAdd(pid)
 	*out = id.ToSerial()
@@ -90,40 +162,146 @@ func (rpcapi *RPCAPI) PeerAdd(ctx context.Context, in string, out *api.IDSerial)
 }
 
 // ConnectGraph runs Cluster.GetConnectGraph().
-func (rpcapi *RPCAPI) ConnectGraph(ctx context.Context, in struct{}, out *api.ConnectGraphSerial) error {
+func (rpcapi *RPCAPI) ConnectGraph(ctx context.Context, in struct{}, out *api.ConnectGraphSerial) (err error) {
+	defer observeRPC("ConnectGraph", &err)
 	graph, err := rpcapi.c.ConnectGraph()
 	*out = graph.ToSerial()
 	return err
 }
 
+// PinsHealth runs Cluster.PinsHealth().
+func (rpcapi *RPCAPI) PinsHealth(ctx context.Context, in int, out *api.PinsHealthReportSerial) (err error) {
+	defer observeRPC("PinsHealth", &err)
+	report, err := rpcapi.c.PinsHealth(in)
+	*out = report.ToSerial()
+	return err
+}
+
+// PinsHealthLocal runs Cluster.PinsHealthLocal().
+func (rpcapi *RPCAPI) PinsHealthLocal(ctx context.Context, in int, out *api.PinsHealthReportSerial) (err error) {
+	defer observeRPC("PinsHealthLocal", &err)
+	report, err := rpcapi.c.PinsHealthLocal(in)
+	*out = report.ToSerial()
+	return err
+}
+
+// Rebalance runs Cluster.Rebalance().
+func (rpcapi *RPCAPI) Rebalance(ctx context.Context, in bool, out *api.RebalanceReportSerial) (err error) {
+	defer observeRPC("Rebalance", &err)
+	report, err := rpcapi.c.Rebalance(in)
+	*out = report.ToSerial()
+	return err
+}
+
+// RebalanceLocal runs Cluster.RebalanceLocal().
+func (rpcapi *RPCAPI) RebalanceLocal(ctx context.Context, in bool, out *api.RebalanceReportSerial) (err error) {
+	defer observeRPC("RebalanceLocal", &err)
+	report, err := rpcapi.c.RebalanceLocal(in)
+	*out = report.ToSerial()
+	return err
+}
+
 // PeerRemove runs Cluster.PeerRm().
-func (rpcapi *RPCAPI) PeerRemove(ctx context.Context, in peer.ID, out *struct{}) error {
+func (rpcapi *RPCAPI) PeerRemove(ctx context.Context, in peer.ID, out *struct{}) (err error) {
+	defer observeRPC("PeerRemove", &err)
 	return rpcapi.c.PeerRemove(in)
 }
 
+// PublishEvent runs Cluster.PublishEvent().
+func (rpcapi *RPCAPI) PublishEvent(ctx context.Context, in api.EventSerial, out *struct{}) (err error) {
+	defer observeRPC("PublishEvent", &err)
+	rpcapi.c.PublishEvent(in.ToEvent())
+	return nil
+}
+
+// SendAlert runs Cluster.SendAlert().
+func (rpcapi *RPCAPI) SendAlert(ctx context.Context, in api.Alert, out *struct{}) (err error) {
+	defer observeRPC("SendAlert", &err)
+	return rpcapi.c.SendAlert(in)
+}
+
+// Alerts runs Cluster.Alerts().
+func (rpcapi *RPCAPI) Alerts(ctx context.Context, in struct{}, out *[]api.AlertSerial) (err error) {
+	defer observeRPC("Alerts", &err)
+	alerts := rpcapi.c.Alerts()
+	alertsS := make([]api.AlertSerial, len(alerts), len(alerts))
+	for i, a := range alerts {
+		alertsS[i] = a.ToSerial()
+	}
+	*out = alertsS
+	return nil
+}
+
+// PeerDrain runs Cluster.PeerDrain().
+func (rpcapi *RPCAPI) PeerDrain(ctx context.Context, in peer.ID, out *struct{}) (err error) {
+	defer observeRPC("PeerDrain", &err)
+	return rpcapi.c.PeerDrain(in)
+}
+
+// PeerUndrain runs Cluster.PeerUndrain().
+func (rpcapi *RPCAPI) PeerUndrain(ctx context.Context, in peer.ID, out *struct{}) (err error) {
+	defer observeRPC("PeerUndrain", &err)
+	return rpcapi.c.PeerUndrain(in)
+}
+
+// DrainStatus runs Cluster.DrainStatus().
+func (rpcapi *RPCAPI) DrainStatus(ctx context.Context, in struct{}, out *[]api.DrainInfoSerial) (err error) {
+	defer observeRPC("DrainStatus", &err)
+	infos, err := rpcapi.c.DrainStatus()
+	if err != nil {
+		return err
+	}
+	infosS := make([]api.DrainInfoSerial, len(infos), len(infos))
+	for i, di := range infos {
+		infosS[i] = di.ToSerial()
+	}
+	*out = infosS
+	return nil
+}
+
 // Join runs Cluster.Join().
-func (rpcapi *RPCAPI) Join(ctx context.Context, in api.MultiaddrSerial, out *struct{}) error {
+func (rpcapi *RPCAPI) Join(ctx context.Context, in api.MultiaddrSerial, out *struct{}) (err error) {
+	defer observeRPC("Join", &err)
 	addr := in.ToMultiaddr()
-	err := rpcapi.c.Join(addr)
+	err = rpcapi.c.Join(addr)
 	return err
 }
 
 // StatusAll runs Cluster.StatusAll().
-func (rpcapi *RPCAPI) StatusAll(ctx context.Context, in struct{}, out *[]api.GlobalPinInfoSerial) error {
+func (rpcapi *RPCAPI) StatusAll(ctx context.Context, in struct{}, out *[]api.GlobalPinInfoSerial) (err error) {
+	defer observeRPC("StatusAll", &err)
 	pinfos, err := rpcapi.c.StatusAll()
 	*out = GlobalPinInfoSliceToSerial(pinfos)
 	return err
 }
 
+// StatusAllPage runs Cluster.StatusAllPage().
+func (rpcapi *RPCAPI) StatusAllPage(ctx context.Context, in api.ListPage, out *[]api.GlobalPinInfoSerial) (err error) {
+	defer observeRPC("StatusAllPage", &err)
+	pinfos, err := rpcapi.c.StatusAllPage(in)
+	*out = GlobalPinInfoSliceToSerial(pinfos)
+	return err
+}
+
 // StatusAllLocal runs Cluster.StatusAllLocal().
-func (rpcapi *RPCAPI) StatusAllLocal(ctx context.Context, in struct{}, out *[]api.PinInfoSerial) error {
+func (rpcapi *RPCAPI) StatusAllLocal(ctx context.Context, in struct{}, out *[]api.PinInfoSerial) (err error) {
+	defer observeRPC("StatusAllLocal", &err)
 	pinfos := rpcapi.c.StatusAllLocal()
 	*out = pinInfoSliceToSerial(pinfos)
 	return nil
 }
 
+// StatusAllLocalPage runs Cluster.StatusAllLocalPage().
+func (rpcapi *RPCAPI) StatusAllLocalPage(ctx context.Context, in api.ListPage, out *[]api.PinInfoSerial) (err error) {
+	defer observeRPC("StatusAllLocalPage", &err)
+	pinfos := rpcapi.c.StatusAllLocalPage(in)
+	*out = pinInfoSliceToSerial(pinfos)
+	return nil
+}
+
 // Status runs Cluster.Status().
-func (rpcapi *RPCAPI) Status(ctx context.Context, in api.PinSerial, out *api.GlobalPinInfoSerial) error {
+func (rpcapi *RPCAPI) Status(ctx context.Context, in api.PinSerial, out *api.GlobalPinInfoSerial) (err error) {
+	defer observeRPC("Status", &err)
 	c := in.DecodeCid()
 	pinfo, err := rpcapi.c.Status(c)
 	*out = pinfo.ToSerial()
@@ -131,7 +309,8 @@ func (rpcapi *RPCAPI) Status(ctx context.Context, in api.PinSerial, out *api.Glo
 }
 
 // StatusLocal runs Cluster.StatusLocal().
-func (rpcapi *RPCAPI) StatusLocal(ctx context.Context, in api.PinSerial, out *api.PinInfoSerial) error {
+func (rpcapi *RPCAPI) StatusLocal(ctx context.Context, in api.PinSerial, out *api.PinInfoSerial) (err error) {
+	defer observeRPC("StatusLocal", &err)
 	c := in.DecodeCid()
 	pinfo := rpcapi.c.StatusLocal(c)
 	*out = pinfo.ToSerial()
@@ -139,21 +318,24 @@ func (rpcapi *RPCAPI) StatusLocal(ctx context.Context, in api.PinSerial, out *ap
 }
 
 // SyncAll runs Cluster.SyncAll().
-func (rpcapi *RPCAPI) SyncAll(ctx context.Context, in struct{}, out *[]api.GlobalPinInfoSerial) error {
+func (rpcapi *RPCAPI) SyncAll(ctx context.Context, in struct{}, out *[]api.GlobalPinInfoSerial) (err error) {
+	defer observeRPC("SyncAll", &err)
 	pinfos, err := rpcapi.c.SyncAll()
 	*out = GlobalPinInfoSliceToSerial(pinfos)
 	return err
 }
 
 // SyncAllLocal runs Cluster.SyncAllLocal().
-func (rpcapi *RPCAPI) SyncAllLocal(ctx context.Context, in struct{}, out *[]api.PinInfoSerial) error {
+func (rpcapi *RPCAPI) SyncAllLocal(ctx context.Context, in struct{}, out *[]api.PinInfoSerial) (err error) {
+	defer observeRPC("SyncAllLocal", &err)
 	pinfos, err := rpcapi.c.SyncAllLocal()
 	*out = pinInfoSliceToSerial(pinfos)
 	return err
 }
 
 // Sync runs Cluster.Sync().
-func (rpcapi *RPCAPI) Sync(ctx context.Context, in api.PinSerial, out *api.GlobalPinInfoSerial) error {
+func (rpcapi *RPCAPI) Sync(ctx context.Context, in api.PinSerial, out *api.GlobalPinInfoSerial) (err error) {
+	defer observeRPC("Sync", &err)
 	c := in.DecodeCid()
 	pinfo, err := rpcapi.c.Sync(c)
 	*out = pinfo.ToSerial()
@@ -161,7 +343,8 @@ func (rpcapi *RPCAPI) Sync(ctx context.Context, in api.PinSerial, out *api.Globa
 }
 
 // SyncLocal runs Cluster.SyncLocal().
-func (rpcapi *RPCAPI) SyncLocal(ctx context.Context, in api.PinSerial, out *api.PinInfoSerial) error {
+func (rpcapi *RPCAPI) SyncLocal(ctx context.Context, in api.PinSerial, out *api.PinInfoSerial) (err error) {
+	defer observeRPC("SyncLocal", &err)
 	c := in.DecodeCid()
 	pinfo, err := rpcapi.c.SyncLocal(c)
 	*out = pinfo.ToSerial()
@@ -169,14 +352,16 @@ func (rpcapi *RPCAPI) SyncLocal(ctx context.Context, in api.PinSerial, out *api.
 }
 
 // RecoverAllLocal runs Cluster.RecoverAllLocal().
-func (rpcapi *RPCAPI) RecoverAllLocal(ctx context.Context, in struct{}, out *[]api.PinInfoSerial) error {
+func (rpcapi *RPCAPI) RecoverAllLocal(ctx context.Context, in struct{}, out *[]api.PinInfoSerial) (err error) {
+	defer observeRPC("RecoverAllLocal", &err)
 	pinfos, err := rpcapi.c.RecoverAllLocal()
 	*out = pinInfoSliceToSerial(pinfos)
 	return err
 }
 
 // Recover runs Cluster.Recover().
-func (rpcapi *RPCAPI) Recover(ctx context.Context, in api.PinSerial, out *api.GlobalPinInfoSerial) error {
+func (rpcapi *RPCAPI) Recover(ctx context.Context, in api.PinSerial, out *api.GlobalPinInfoSerial) (err error) {
+	defer observeRPC("Recover", &err)
 	c := in.DecodeCid()
 	pinfo, err := rpcapi.c.Recover(c)
 	*out = pinfo.ToSerial()
@@ -184,7 +369,8 @@ func (rpcapi *RPCAPI) Recover(ctx context.Context, in api.PinSerial, out *api.Gl
 }
 
 // RecoverLocal runs Cluster.RecoverLocal().
-func (rpcapi *RPCAPI) RecoverLocal(ctx context.Context, in api.PinSerial, out *api.PinInfoSerial) error {
+func (rpcapi *RPCAPI) RecoverLocal(ctx context.Context, in api.PinSerial, out *api.PinInfoSerial) (err error) {
+	defer observeRPC("RecoverLocal", &err)
 	c := in.DecodeCid()
 	pinfo, err := rpcapi.c.RecoverLocal(c)
 	*out = pinfo.ToSerial()
@@ -193,9 +379,10 @@ func (rpcapi *RPCAPI) RecoverLocal(ctx context.Context, in api.PinSerial, out *a
 
 // BlockAllocate returns allocations for blocks. This is used in the adders.
 // It's different from pin allocations when ReplicationFactor < 0.
-func (rpcapi *RPCAPI) BlockAllocate(ctx context.Context, in api.PinSerial, out *[]string) error {
+func (rpcapi *RPCAPI) BlockAllocate(ctx context.Context, in api.PinSerial, out *[]string) (err error) {
+	defer observeRPC("BlockAllocate", &err)
 	pin := in.ToPin()
-	err := rpcapi.c.setupPin(&pin)
+	err = rpcapi.c.setupPin(&pin)
 	if err != nil {
 		return err
 	}
@@ -215,9 +402,7 @@ func (rpcapi *RPCAPI) BlockAllocate(ctx context.Context, in api.PinSerial, out *
 	}
 
 	allocs, err := rpcapi.c.allocate(
-		pin.Cid,
-		pin.ReplicationFactorMin,
-		pin.ReplicationFactorMax,
+		pin,
 		[]peer.ID{}, // blacklist
 		[]peer.ID{}, // prio list
 	)
@@ -231,7 +416,8 @@ func (rpcapi *RPCAPI) BlockAllocate(ctx context.Context, in api.PinSerial, out *
 }
 
 // SendInformerMetric runs Cluster.sendInformerMetric().
-func (rpcapi *RPCAPI) SendInformerMetric(ctx context.Context, in struct{}, out *api.Metric) error {
+func (rpcapi *RPCAPI) SendInformerMetric(ctx context.Context, in struct{}, out *api.Metric) (err error) {
+	defer observeRPC("SendInformerMetric", &err)
 	m, err := rpcapi.c.sendInformerMetric()
 	*out = m
 	return err
@@ -242,24 +428,55 @@ func (rpcapi *RPCAPI) SendInformerMetric(ctx context.Context, in struct{}, out *
 */
 
 // Track runs PinTracker.Track().
-func (rpcapi *RPCAPI) Track(ctx context.Context, in api.PinSerial, out *struct{}) error {
+func (rpcapi *RPCAPI) Track(ctx context.Context, in api.PinSerial, out *struct{}) (err error) {
+	defer observeRPC("Track", &err)
 	return rpcapi.c.tracker.Track(in.ToPin())
 }
 
 // Untrack runs PinTracker.Untrack().
-func (rpcapi *RPCAPI) Untrack(ctx context.Context, in api.PinSerial, out *struct{}) error {
+func (rpcapi *RPCAPI) Untrack(ctx context.Context, in api.PinSerial, out *struct{}) (err error) {
+	defer observeRPC("Untrack", &err)
 	c := in.DecodeCid()
 	return rpcapi.c.tracker.Untrack(c)
 }
 
 // TrackerStatusAll runs PinTracker.StatusAll().
-func (rpcapi *RPCAPI) TrackerStatusAll(ctx context.Context, in struct{}, out *[]api.PinInfoSerial) error {
+func (rpcapi *RPCAPI) TrackerStatusAll(ctx context.Context, in struct{}, out *[]api.PinInfoSerial) (err error) {
+	defer observeRPC("TrackerStatusAll", &err)
 	*out = pinInfoSliceToSerial(rpcapi.c.tracker.StatusAll())
 	return nil
 }
 
+// TrackerOperations runs PinTracker.Operations().
+func (rpcapi *RPCAPI) TrackerOperations(ctx context.Context, in struct{}, out *[]api.OperationSerial) (err error) {
+	defer observeRPC("TrackerOperations", &err)
+	*out = operationSliceToSerial(rpcapi.c.tracker.Operations())
+	return nil
+}
+
+// TrackerCancelOperation runs PinTracker.CancelOperation().
+func (rpcapi *RPCAPI) TrackerCancelOperation(ctx context.Context, in api.OperationSerial, out *struct{}) (err error) {
+	defer observeRPC("TrackerCancelOperation", &err)
+	return rpcapi.c.tracker.CancelOperation(in.ToOperation().Cid)
+}
+
+// TrackerSetOperationPriority runs PinTracker.SetOperationPriority().
+func (rpcapi *RPCAPI) TrackerSetOperationPriority(ctx context.Context, in api.OperationSerial, out *struct{}) (err error) {
+	defer observeRPC("TrackerSetOperationPriority", &err)
+	op := in.ToOperation()
+	return rpcapi.c.tracker.SetOperationPriority(op.Cid, op.Priority)
+}
+
+// TrackerSetProgress runs PinTracker.SetProgress().
+func (rpcapi *RPCAPI) TrackerSetProgress(ctx context.Context, in api.PinInfoSerial, out *struct{}) (err error) {
+	defer observeRPC("TrackerSetProgress", &err)
+	pinfo := in.ToPinInfo()
+	return rpcapi.c.tracker.SetProgress(pinfo.Cid, pinfo.Progress)
+}
+
 // TrackerStatus runs PinTracker.Status().
-func (rpcapi *RPCAPI) TrackerStatus(ctx context.Context, in api.PinSerial, out *api.PinInfoSerial) error {
+func (rpcapi *RPCAPI) TrackerStatus(ctx context.Context, in api.PinSerial, out *api.PinInfoSerial) (err error) {
+	defer observeRPC("TrackerStatus", &err)
 	c := in.DecodeCid()
 	pinfo := rpcapi.c.tracker.Status(c)
 	*out = pinfo.ToSerial()
@@ -267,14 +484,16 @@ func (rpcapi *RPCAPI) TrackerStatus(ctx context.Context, in api.PinSerial, out *
 }
 
 // TrackerRecoverAll runs PinTracker.RecoverAll().f
-func (rpcapi *RPCAPI) TrackerRecoverAll(ctx context.Context, in struct{}, out *[]api.PinInfoSerial) error {
+func (rpcapi *RPCAPI) TrackerRecoverAll(ctx context.Context, in struct{}, out *[]api.PinInfoSerial) (err error) {
+	defer observeRPC("TrackerRecoverAll", &err)
 	pinfos, err := rpcapi.c.tracker.RecoverAll()
 	*out = pinInfoSliceToSerial(pinfos)
 	return err
 }
 
 // TrackerRecover runs PinTracker.Recover().
-func (rpcapi *RPCAPI) TrackerRecover(ctx context.Context, in api.PinSerial, out *api.PinInfoSerial) error {
+func (rpcapi *RPCAPI) TrackerRecover(ctx context.Context, in api.PinSerial, out *api.PinInfoSerial) (err error) {
+	defer observeRPC("TrackerRecover", &err)
 	c := in.DecodeCid()
 	pinfo, err := rpcapi.c.tracker.Recover(c)
 	*out = pinfo.ToSerial()
@@ -282,44 +501,58 @@ func (rpcapi *RPCAPI) TrackerRecover(ctx context.Context, in api.PinSerial, out
 }
 
 // FindKey finds user key from IFPS keystore
-func (rpcapi *RPCAPI) FindKey(ctx context.Context, in string, out *api.UIDKey) error {
+func (rpcapi *RPCAPI) FindKey(ctx context.Context, in string, out *api.UIDKey) (err error) {
+	defer observeRPC("FindKey", &err)
 	key, err := rpcapi.c.FindKey(in)
 	*out = key
 	return err
 }
 
 // SyncKey runs Cluster.SyncKey().
-func (rpcapi *RPCAPI) SyncKey(ctx context.Context, in string, out *struct{}) error {
-	err := rpcapi.c.SyncKey(in)
+func (rpcapi *RPCAPI) SyncKey(ctx context.Context, in string, out *struct{}) (err error) {
+	defer observeRPC("SyncKey", &err)
+	err = rpcapi.c.SyncKey(in)
 	return err
 }
 
 // SyncUidRenew runs Cluster.SyncUidRenew().
-func (rpcapi *RPCAPI) SyncUidRenew(ctx context.Context, in []string, out *api.UIDRenew) error {
+func (rpcapi *RPCAPI) SyncUidRenew(ctx context.Context, in []string, out *api.UIDRenew) (err error) {
+	defer observeRPC("SyncUidRenew", &err)
 	res, err := rpcapi.c.SyncUidRenew(in)
 	*out = res
 	return err
 }
 
+// UidNew runs Cluster.UidNew().
+func (rpcapi *RPCAPI) UidNew(ctx context.Context, in string, out *api.UIDSecret) (err error) {
+	defer observeRPC("UidNew", &err)
+	res, err := rpcapi.c.UidNew(in)
+	*out = res
+	return err
+}
+
 /*
    IPFS Connector component methods
 */
 
 // IPFSPin runs IPFSConnector.Pin().
-func (rpcapi *RPCAPI) IPFSPin(ctx context.Context, in api.PinSerial, out *struct{}) error {
+func (rpcapi *RPCAPI) IPFSPin(ctx context.Context, in api.PinSerial, out *struct{}) (err error) {
+	defer observeRPC("IPFSPin", &err)
 	c := in.DecodeCid()
 	depth := in.ToPin().MaxDepth
 	return rpcapi.c.ipfs.Pin(ctx, c, depth)
 }
 
 // IPFSUnpin runs IPFSConnector.Unpin().
-func (rpcapi *RPCAPI) IPFSUnpin(ctx context.Context, in api.PinSerial, out *struct{}) error {
+func (rpcapi *RPCAPI) IPFSUnpin(ctx context.Context, in api.PinSerial, out *struct{}) (err error) {
+	defer observeRPC("IPFSUnpin", &err)
 	c := in.DecodeCid()
 	return rpcapi.c.ipfs.Unpin(ctx, c)
 }
 
 // IPFSPinLsCid runs IPFSConnector.PinLsCid().
-func (rpcapi *RPCAPI) IPFSPinLsCid(ctx context.Context, in api.PinSerial, out *api.IPFSPinStatus) error {
+func (rpcapi *RPCAPI) IPFSPinLsCid(ctx context.Context, in api.PinSerial, out *api.IPFSPinStatus) (err error) {
+	defer observeRPC("IPFSPinLsCid", &err)
 	c := in.DecodeCid()
 	b, err := rpcapi.c.ipfs.PinLsCid(ctx, c)
 	*out = b
@@ -327,178 +560,354 @@ func (rpcapi *RPCAPI) IPFSPinLsCid(ctx context.Context, in api.PinSerial, out *a
 }
 
 // IPFSPinLs runs IPFSConnector.PinLs().
-func (rpcapi *RPCAPI) IPFSPinLs(ctx context.Context, in string, out *map[string]api.IPFSPinStatus) error {
+func (rpcapi *RPCAPI) IPFSPinLs(ctx context.Context, in string, out *map[string]api.IPFSPinStatus) (err error) {
+	defer observeRPC("IPFSPinLs", &err)
 	m, err := rpcapi.c.ipfs.PinLs(ctx, in)
 	*out = m
 	return err
 }
 
 // IPFSConnectSwarms runs IPFSConnector.ConnectSwarms().
-func (rpcapi *RPCAPI) IPFSConnectSwarms(ctx context.Context, in struct{}, out *struct{}) error {
-	err := rpcapi.c.ipfs.ConnectSwarms()
+func (rpcapi *RPCAPI) IPFSConnectSwarms(ctx context.Context, in struct{}, out *struct{}) (err error) {
+	defer observeRPC("IPFSConnectSwarms", &err)
+	err = rpcapi.c.ipfs.ConnectSwarms()
 	return err
 }
 
 // IPFSConfigKey runs IPFSConnector.ConfigKey().
-func (rpcapi *RPCAPI) IPFSConfigKey(ctx context.Context, in string, out *interface{}) error {
+func (rpcapi *RPCAPI) IPFSConfigKey(ctx context.Context, in string, out *interface{}) (err error) {
+	defer observeRPC("IPFSConfigKey", &err)
 	res, err := rpcapi.c.ipfs.ConfigKey(in)
 	*out = res
 	return err
 }
 
 // IPFSRepoStat runs IPFSConnector.RepoStat().
-func (rpcapi *RPCAPI) IPFSRepoStat(ctx context.Context, in struct{}, out *api.IPFSRepoStat) error {
+func (rpcapi *RPCAPI) IPFSRepoStat(ctx context.Context, in struct{}, out *api.IPFSRepoStat) (err error) {
+	defer observeRPC("IPFSRepoStat", &err)
 	res, err := rpcapi.c.ipfs.RepoStat()
 	*out = res
 	return err
 }
 
 // IPFSSwarmPeers runs IPFSConnector.SwarmPeers().
-func (rpcapi *RPCAPI) IPFSSwarmPeers(ctx context.Context, in struct{}, out *api.SwarmPeersSerial) error {
+func (rpcapi *RPCAPI) IPFSSwarmPeers(ctx context.Context, in struct{}, out *api.SwarmPeersSerial) (err error) {
+	defer observeRPC("IPFSSwarmPeers", &err)
 	res, err := rpcapi.c.ipfs.SwarmPeers()
 	*out = res.ToSerial()
 	return err
 }
 
 // IPFSBlockPut runs IPFSConnector.BlockPut().
-func (rpcapi *RPCAPI) IPFSBlockPut(ctx context.Context, in api.NodeWithMeta, out *struct{}) error {
+func (rpcapi *RPCAPI) IPFSBlockPut(ctx context.Context, in api.NodeWithMeta, out *struct{}) (err error) {
+	defer observeRPC("IPFSBlockPut", &err)
 	return rpcapi.c.ipfs.BlockPut(in)
 }
 
 // IPFSBlockGet runs IPFSConnector.BlockGet().
-func (rpcapi *RPCAPI) IPFSBlockGet(ctx context.Context, in api.PinSerial, out *[]byte) error {
+func (rpcapi *RPCAPI) IPFSBlockGet(ctx context.Context, in api.PinSerial, out *[]byte) (err error) {
+	defer observeRPC("IPFSBlockGet", &err)
 	c := in.DecodeCid()
 	res, err := rpcapi.c.ipfs.BlockGet(c)
 	*out = res
 	return err
 }
 
-// UidNew runs IPFSConnector.UidNew().
-func (rpcapi *RPCAPI) UidNew(ctx context.Context, in string, out *api.UIDSecret) error {
-	res, err := rpcapi.c.ipfs.UidNew(in)
+// IPFSCat runs IPFSConnector.Cat().
+func (rpcapi *RPCAPI) IPFSCat(ctx context.Context, in api.FileCat, out *[]byte) (err error) {
+	defer observeRPC("IPFSCat", &err)
+	res, err := rpcapi.c.ipfs.Cat(in)
+	*out = res
+	return err
+}
+
+// IPFSLs runs IPFSConnector.Ls().
+func (rpcapi *RPCAPI) IPFSLs(ctx context.Context, in string, out *api.FilesLs) (err error) {
+	defer observeRPC("IPFSLs", &err)
+	res, err := rpcapi.c.ipfs.Ls(in)
+	*out = res
+	return err
+}
+
+// IPFSStat runs IPFSConnector.Stat().
+func (rpcapi *RPCAPI) IPFSStat(ctx context.Context, in string, out *api.FilesStat) (err error) {
+	defer observeRPC("IPFSStat", &err)
+	res, err := rpcapi.c.ipfs.Stat(in)
+	*out = res
+	return err
+}
+
+// IPFSBlockGetLocal runs IPFSConnector.BlockGetLocal().
+func (rpcapi *RPCAPI) IPFSBlockGetLocal(ctx context.Context, in api.PinSerial, out *[]byte) (err error) {
+	defer observeRPC("IPFSBlockGetLocal", &err)
+	c := in.DecodeCid()
+	res, err := rpcapi.c.ipfs.BlockGetLocal(c)
 	*out = res
 	return err
 }
 
 // UidRenew runs IPFSConnector.UidRenew().
-func (rpcapi *RPCAPI) UidRenew(ctx context.Context, in []string, out *api.UIDRenew) error {
+func (rpcapi *RPCAPI) UidRenew(ctx context.Context, in []string, out *api.UIDRenew) (err error) {
+	defer observeRPC("UidRenew", &err)
+	defer rpcapi.c.homeLocks.lock(in[0])()
 	res, err := rpcapi.c.ipfs.UidRenew(in)
 	*out = res
 	return err
 }
 
 // UidInfo runs IPFSConnector.UidInfo().
-func (rpcapi *RPCAPI) UidInfo(ctx context.Context, in string, out *api.UIDSecret) error {
+func (rpcapi *RPCAPI) UidInfo(ctx context.Context, in string, out *api.UIDSecret) (err error) {
+	defer observeRPC("UidInfo", &err)
 	res, err := rpcapi.c.ipfs.UidInfo(in)
 	*out = res
 	return err
 }
 
-// UidLogin runs IPFSConnector.UidLogin().
-func (rpcapi *RPCAPI) UidLogin(ctx context.Context, in []string, out *struct{}) error {
-	err := rpcapi.c.ipfs.UidLogin(in)
+// UidLogin runs Cluster.UidLogin(). It takes the UID, the new root of its
+// home and, optionally, "true" to replace the former home permanently
+// instead of keeping it in the trash.
+func (rpcapi *RPCAPI) UidLogin(ctx context.Context, in []string, out *struct{}) (err error) {
+	defer observeRPC("UidLogin", &err)
+	if len(in) < 2 {
+		return errors.New("UidLogin needs a uid and a hash")
+	}
+	defer rpcapi.c.homeLocks.lock(in[0])()
+	permanent := len(in) > 2 && in[2] == "true"
+	err = rpcapi.c.UidLogin(in[0], in[1], permanent)
 	return err
 }
 
 // IPFSFileGet runs IPFSConnector.IPFSFileGet().
-func (rpcapi *RPCAPI) IPFSFileGet(ctx context.Context, in []string, out *[]byte) error {
+func (rpcapi *RPCAPI) IPFSFileGet(ctx context.Context, in []string, out *[]byte) (err error) {
+	defer observeRPC("IPFSFileGet", &err)
 	res, err := rpcapi.c.ipfs.FileGet(in)
 	*out = res
 	return err
 }
 
 // FilesCp runs IPFSConnector.FilesCp().
-func (rpcapi *RPCAPI) IPFSFilesCp(ctx context.Context, in []string, out *struct{}) error {
-	err := rpcapi.c.ipfs.FilesCp(in)
+func (rpcapi *RPCAPI) IPFSFilesCp(ctx context.Context, in []string, out *struct{}) (err error) {
+	defer observeRPC("IPFSFilesCp", &err)
+	defer rpcapi.c.homeLocks.lock(in[0])()
+	err = rpcapi.c.ipfs.FilesCp(in)
+	if err == nil && len(in) > 2 {
+		rpcapi.c.publishFileOp("cp", in[0], in[2])
+	}
 	return err
 }
 
 // FilesFlush runs IPFSConnector.FilesFlush().
-func (rpcapi *RPCAPI) IPFSFilesFlush(ctx context.Context, in []string, out *struct{}) error {
-	err := rpcapi.c.ipfs.FilesFlush(in)
+func (rpcapi *RPCAPI) IPFSFilesFlush(ctx context.Context, in []string, out *struct{}) (err error) {
+	defer observeRPC("IPFSFilesFlush", &err)
+	defer rpcapi.c.homeLocks.lock(in[0])()
+	err = rpcapi.c.ipfs.FilesFlush(in)
 	return err
 }
 
 // FilesLs runs IPFSConnector.FilesLs().
-func (rpcapi *RPCAPI) IPFSFilesLs(ctx context.Context, in []string, out *api.FilesLs) error {
+func (rpcapi *RPCAPI) IPFSFilesLs(ctx context.Context, in []string, out *api.FilesLs) (err error) {
+	defer observeRPC("IPFSFilesLs", &err)
+	defer rpcapi.c.homeLocks.rlock(in[0])()
 	res, err := rpcapi.c.ipfs.FilesLs(in)
 	*out = res
 	return err
 }
 
 // FilesMkdir runs IPFSConnector.FilesMkdir().
-func (rpcapi *RPCAPI) IPFSFilesMkdir(ctx context.Context, in []string, out *struct{}) error {
-	err := rpcapi.c.ipfs.FilesMkdir(in)
+func (rpcapi *RPCAPI) IPFSFilesMkdir(ctx context.Context, in []string, out *struct{}) (err error) {
+	defer observeRPC("IPFSFilesMkdir", &err)
+	defer rpcapi.c.homeLocks.lock(in[0])()
+	err = rpcapi.c.ipfs.FilesMkdir(in)
+	if err == nil && len(in) > 1 {
+		rpcapi.c.publishFileOp("mkdir", in[0], in[1])
+	}
 	return err
 }
 
 // FilesMv runs IPFSConnector.FilesMv().
-func (rpcapi *RPCAPI) IPFSFilesMv(ctx context.Context, in []string, out *struct{}) error {
-	err := rpcapi.c.ipfs.FilesMv(in)
+func (rpcapi *RPCAPI) IPFSFilesMv(ctx context.Context, in []string, out *struct{}) (err error) {
+	defer observeRPC("IPFSFilesMv", &err)
+	defer rpcapi.c.homeLocks.lock(in[0])()
+	err = rpcapi.c.ipfs.FilesMv(in)
+	if err == nil && len(in) > 2 {
+		rpcapi.c.search.update(in[0], in[1])
+		rpcapi.c.publishFileOp("mv", in[0], in[2])
+	}
 	return err
 }
 
 // FilesRead runs IPFSConnector.FilesRead().
-func (rpcapi *RPCAPI) IPFSFilesRead(ctx context.Context, in []string, out *[]byte) error {
+func (rpcapi *RPCAPI) IPFSFilesRead(ctx context.Context, in []string, out *[]byte) (err error) {
+	defer observeRPC("IPFSFilesRead", &err)
+	defer rpcapi.c.homeLocks.rlock(in[0])()
 	res, err := rpcapi.c.ipfs.FilesRead(in)
 	*out = res
 	return err
 }
 
 // FilesRm runs IPFSConnector.FilesRm().
-func (rpcapi *RPCAPI) IPFSFilesRm(ctx context.Context, in []string, out *struct{}) error {
-	err := rpcapi.c.ipfs.FilesRm(in)
+func (rpcapi *RPCAPI) IPFSFilesRm(ctx context.Context, in []string, out *struct{}) (err error) {
+	defer observeRPC("IPFSFilesRm", &err)
+	defer rpcapi.c.homeLocks.lock(in[0])()
+	err = rpcapi.c.ipfs.FilesRm(in)
+	if err == nil && len(in) > 1 {
+		rpcapi.c.publishFileOp("rm", in[0], in[1])
+	}
+	return err
+}
+
+// Trash runs Cluster.Trash(). It takes the UID, the path and "true" to
+// move directories.
+func (rpcapi *RPCAPI) Trash(ctx context.Context, in []string, out *api.TrashEntry) (err error) {
+	defer observeRPC("Trash", &err)
+	if len(in) < 3 {
+		return errors.New("Trash needs a uid, a path and the recursive flag")
+	}
+	defer rpcapi.c.homeLocks.lock(in[0])()
+	entry, err := rpcapi.c.Trash(in[0], in[1], in[2] == "true")
+	*out = entry
+	return err
+}
+
+// TrashList runs Cluster.TrashList().
+func (rpcapi *RPCAPI) TrashList(ctx context.Context, in string, out *[]api.TrashEntry) (err error) {
+	defer observeRPC("TrashList", &err)
+	defer rpcapi.c.homeLocks.rlock(in)()
+	entries, err := rpcapi.c.TrashList(in)
+	*out = entries
+	return err
+}
+
+// TrashRestore runs Cluster.TrashRestore(). It takes the UID, the ID of
+// the trash entry and the destination, which may be empty.
+func (rpcapi *RPCAPI) TrashRestore(ctx context.Context, in []string, out *string) (err error) {
+	defer observeRPC("TrashRestore", &err)
+	if len(in) < 3 {
+		return errors.New("TrashRestore needs a uid, an id and a destination")
+	}
+	defer rpcapi.c.homeLocks.lock(in[0])()
+	dest, err := rpcapi.c.TrashRestore(in[0], in[1], in[2])
+	*out = dest
+	return err
+}
+
+// TrashEmpty runs Cluster.TrashEmpty(). It takes the UID and the ID of the
+// trash entry to remove, or an empty ID to remove all of them.
+func (rpcapi *RPCAPI) TrashEmpty(ctx context.Context, in []string, out *struct{}) (err error) {
+	defer observeRPC("TrashEmpty", &err)
+	if len(in) < 2 {
+		return errors.New("TrashEmpty needs a uid and an id")
+	}
+	defer rpcapi.c.homeLocks.lock(in[0])()
+	return rpcapi.c.TrashEmpty(in[0], in[1])
+}
+
+// FilesBatch runs Cluster.FilesBatch().
+func (rpcapi *RPCAPI) FilesBatch(ctx context.Context, in api.FilesBatch, out *api.FilesBatchResult) (err error) {
+	defer observeRPC("FilesBatch", &err)
+	defer rpcapi.c.homeLocks.lock(in.UID)()
+	res, err := rpcapi.c.FilesBatch(in.UID, in.Ops)
+	*out = res
+	return err
+}
+
+// FilesSearch runs Cluster.FilesSearch().
+func (rpcapi *RPCAPI) FilesSearch(ctx context.Context, in api.SearchQuery, out *[]api.FileMeta) (err error) {
+	defer observeRPC("FilesSearch", &err)
+	res, err := rpcapi.c.FilesSearch(in)
+	*out = res
+	return err
+}
+
+// FilesSearchRebuild runs Cluster.FilesSearchRebuild().
+func (rpcapi *RPCAPI) FilesSearchRebuild(ctx context.Context, in string, out *int) (err error) {
+	defer observeRPC("FilesSearchRebuild", &err)
+	n, err := rpcapi.c.FilesSearchRebuild(in)
+	*out = n
 	return err
 }
 
 // FilesStat runs IPFSConnector.FilesStat().
-func (rpcapi *RPCAPI) IPFSFilesStat(ctx context.Context, in []string, out *api.FilesStat) error {
+func (rpcapi *RPCAPI) IPFSFilesStat(ctx context.Context, in []string, out *api.FilesStat) (err error) {
+	defer observeRPC("IPFSFilesStat", &err)
+	defer rpcapi.c.homeLocks.rlock(in[0])()
 	res, err := rpcapi.c.ipfs.FilesStat(in)
 	*out = res
 	return err
 }
 
 // FilesWrite runs IPFSConnector.FilesWrite().
-func (rpcapi *RPCAPI) IPFSFilesWrite(ctx context.Context, in api.FilesWrite, out *struct{}) error {
-	err := rpcapi.c.ipfs.FilesWrite(in)
+func (rpcapi *RPCAPI) IPFSFilesWrite(ctx context.Context, in api.FilesWrite, out *struct{}) (err error) {
+	defer observeRPC("IPFSFilesWrite", &err)
+	defer rpcapi.c.homeLocks.lock(in.Params[0])()
+	err = rpcapi.c.ipfs.FilesWrite(in)
+	if err == nil && len(in.Params) > 1 {
+		rpcapi.c.publishFileOp("write", in.Params[0], in.Params[1])
+	}
 	return err
 }
 
 // IPFSNamePublish runs IPFSConnector.IPFSNamePublish().
-func (rpcapi *RPCAPI) IPFSNamePublish(ctx context.Context, in []string, out *api.NamePublish) error {
+func (rpcapi *RPCAPI) IPFSNamePublish(ctx context.Context, in []string, out *api.NamePublish) (err error) {
+	defer observeRPC("IPFSNamePublish", &err)
 	res, err := rpcapi.c.ipfs.NamePublish(in)
 	*out = res
 	return err
 }
 
+// IPFSNameResolve runs IPFSConnector.NameResolve().
+func (rpcapi *RPCAPI) IPFSNameResolve(ctx context.Context, in string, out *string) (err error) {
+	defer observeRPC("IPFSNameResolve", &err)
+	res, err := rpcapi.c.ipfs.NameResolve(in)
+	*out = res
+	return err
+}
+
 /*
    Consensus component methods
 */
 
 // ConsensusLogPin runs Consensus.LogPin().
-func (rpcapi *RPCAPI) ConsensusLogPin(ctx context.Context, in api.PinSerial, out *struct{}) error {
+func (rpcapi *RPCAPI) ConsensusLogPin(ctx context.Context, in api.PinSerial, out *struct{}) (err error) {
+	defer observeRPC("ConsensusLogPin", &err)
 	c := in.ToPin()
 	return rpcapi.c.consensus.LogPin(c)
 }
 
 // ConsensusLogUnpin runs Consensus.LogUnpin().
-func (rpcapi *RPCAPI) ConsensusLogUnpin(ctx context.Context, in api.PinSerial, out *struct{}) error {
+func (rpcapi *RPCAPI) ConsensusLogUnpin(ctx context.Context, in api.PinSerial, out *struct{}) (err error) {
+	defer observeRPC("ConsensusLogUnpin", &err)
 	c := in.ToPin()
 	return rpcapi.c.consensus.LogUnpin(c)
 }
 
+// ConsensusLogDrain runs Consensus.LogDrain().
+func (rpcapi *RPCAPI) ConsensusLogDrain(ctx context.Context, in peer.ID, out *struct{}) (err error) {
+	defer observeRPC("ConsensusLogDrain", &err)
+	return rpcapi.c.consensus.LogDrain(in)
+}
+
+// ConsensusLogUndrain runs Consensus.LogUndrain().
+func (rpcapi *RPCAPI) ConsensusLogUndrain(ctx context.Context, in peer.ID, out *struct{}) (err error) {
+	defer observeRPC("ConsensusLogUndrain", &err)
+	return rpcapi.c.consensus.LogUndrain(in)
+}
+
 // ConsensusAddPeer runs Consensus.AddPeer().
-func (rpcapi *RPCAPI) ConsensusAddPeer(ctx context.Context, in peer.ID, out *struct{}) error {
+func (rpcapi *RPCAPI) ConsensusAddPeer(ctx context.Context, in peer.ID, out *struct{}) (err error) {
+	defer observeRPC("ConsensusAddPeer", &err)
 	return rpcapi.c.consensus.AddPeer(in)
 }
 
 // ConsensusRmPeer runs Consensus.RmPeer().
-func (rpcapi *RPCAPI) ConsensusRmPeer(ctx context.Context, in peer.ID, out *struct{}) error {
+func (rpcapi *RPCAPI) ConsensusRmPeer(ctx context.Context, in peer.ID, out *struct{}) (err error) {
+	defer observeRPC("ConsensusRmPeer", &err)
 	return rpcapi.c.consensus.RmPeer(in)
 }
 
 // ConsensusPeers runs Consensus.Peers().
-func (rpcapi *RPCAPI) ConsensusPeers(ctx context.Context, in struct{}, out *[]peer.ID) error {
+func (rpcapi *RPCAPI) ConsensusPeers(ctx context.Context, in struct{}, out *[]peer.ID) (err error) {
+	defer observeRPC("ConsensusPeers", &err)
 	peers, err := rpcapi.c.consensus.Peers()
 	*out = peers
 	return err
@@ -509,13 +918,33 @@ func (rpcapi *RPCAPI) ConsensusPeers(ctx context.Context, in struct{}, out *[]pe
 */
 
 // PeerMonitorLogMetric runs PeerMonitor.LogMetric().
-func (rpcapi *RPCAPI) PeerMonitorLogMetric(ctx context.Context, in api.Metric, out *struct{}) error {
+func (rpcapi *RPCAPI) PeerMonitorLogMetric(ctx context.Context, in api.Metric, out *struct{}) (err error) {
+	defer observeRPC("PeerMonitorLogMetric", &err)
 	rpcapi.c.monitor.LogMetric(in)
 	return nil
 }
 
 // PeerMonitorLatestMetrics runs PeerMonitor.LatestMetrics().
-func (rpcapi *RPCAPI) PeerMonitorLatestMetrics(ctx context.Context, in string, out *[]api.Metric) error {
+func (rpcapi *RPCAPI) PeerMonitorLatestMetrics(ctx context.Context, in string, out *[]api.Metric) (err error) {
+	defer observeRPC("PeerMonitorLatestMetrics", &err)
 	*out = rpcapi.c.monitor.LatestMetrics(in)
 	return nil
 }
+
+// PeerMonitorMetricsHistory runs PeerMonitor.MetricsHistory().
+func (rpcapi *RPCAPI) PeerMonitorMetricsHistory(ctx context.Context, in string, out *[]api.MetricHistorySerial) (err error) {
+	defer observeRPC("PeerMonitorMetricsHistory", &err)
+	history := rpcapi.c.monitor.MetricsHistory(in)
+	historyS := make([]api.MetricHistorySerial, len(history), len(history))
+	for i, h := range history {
+		historyS[i] = h.ToSerial()
+	}
+	*out = historyS
+	return nil
+}
+
+// PeerMonitorSendAlert runs PeerMonitor.SendAlert().
+func (rpcapi *RPCAPI) PeerMonitorSendAlert(ctx context.Context, in api.Alert, out *struct{}) (err error) {
+	defer observeRPC("PeerMonitorSendAlert", &err)
+	return rpcapi.c.monitor.SendAlert(in)
+}
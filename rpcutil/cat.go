@@ -0,0 +1,101 @@
+package rpcutil
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+)
+
+// CatChunkSize is the number of bytes requested by every IPFSCat call
+// made by a CatReader.
+var CatChunkSize int64 = 1024 * 1024
+
+// CatReader is an io.ReadSeeker over a file in IPFS. The file is read
+// through the IPFSCat RPC method of the local peer, one chunk at a time,
+// so that it is never held in memory as a whole.
+type CatReader struct {
+	ctx       context.Context
+	rpcClient *rpc.Client
+	path      string
+	size      int64
+
+	pos int64
+	buf []byte
+}
+
+// NewCatReader returns a CatReader for the file of the given size at the
+// given IPFS path.
+func NewCatReader(ctx context.Context, rpcClient *rpc.Client, path string, size int64) *CatReader {
+	return &CatReader{
+		ctx:       ctx,
+		rpcClient: rpcClient,
+		path:      path,
+		size:      size,
+	}
+}
+
+// Read reads from the current chunk, fetching the next one when it has
+// been consumed.
+func (cr *CatReader) Read(p []byte) (int, error) {
+	if cr.pos >= cr.size {
+		return 0, io.EOF
+	}
+	if len(cr.buf) == 0 {
+		length := CatChunkSize
+		if rest := cr.size - cr.pos; rest < length {
+			length = rest
+		}
+		var data []byte
+		err := cr.rpcClient.CallContext(
+			cr.ctx,
+			"",
+			"Cluster",
+			"IPFSCat",
+			api.FileCat{
+				Path:   cr.path,
+				Offset: uint64(cr.pos),
+				Length: uint64(length),
+			},
+			&data,
+		)
+		if err != nil {
+			return 0, err
+		}
+		if len(data) == 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		cr.buf = data
+	}
+	n := copy(p, cr.buf)
+	cr.buf = cr.buf[n:]
+	cr.pos += int64(n)
+	return n, nil
+}
+
+// Seek sets the offset for the next Read. The current chunk is dropped
+// when the offset changes.
+func (cr *CatReader) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = cr.pos + offset
+	case io.SeekEnd:
+		pos = cr.size + offset
+	default:
+		return cr.pos, errors.New("invalid whence")
+	}
+	if pos < 0 {
+		return cr.pos, errors.New("negative position")
+	}
+	if pos != cr.pos {
+		cr.buf = nil
+		cr.pos = pos
+	}
+	return pos, nil
+}
@@ -112,6 +112,17 @@ func CopyPinInfoSerialSliceToIfaces(in [][]api.PinInfoSerial) []interface{} {
 	return ifaces
 }
 
+// CopyOperationSerialSliceToIfaces converts an api.OperationSerial slice of
+// slices to an empty interface slice using pointers to each elements of the
+// original slice. Useful to handle gorpc.MultiCall() replies.
+func CopyOperationSerialSliceToIfaces(in [][]api.OperationSerial) []interface{} {
+	ifaces := make([]interface{}, len(in), len(in))
+	for i := range in {
+		ifaces[i] = &in[i]
+	}
+	return ifaces
+}
+
 // CopyEmptyStructToIfaces converts an empty struct slice to an empty interface
 // slice using pointers to each elements of the original slice.
 // Useful to handle gorpc.MultiCall() replies.
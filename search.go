@@ -0,0 +1,367 @@
+package ipfscluster
+
+import (
+	"errors"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+)
+
+// errSearchDisabled is returned by the search methods when the search
+// index is not enabled in the configuration.
+var errSearchDisabled = errors.New("the search index is disabled in this peer")
+
+// searchIndex keeps the metadata of the files in the user homes of this
+// peer so that they can be searched without walking their MFS trees. The
+// index of a home is built the first time it is searched. From then on,
+// it is updated in the background after every modification of the home,
+// so searches may miss the latest ones. Entries in the trash are not
+// indexed.
+//
+// All methods can be called on a nil index, which does nothing.
+type searchIndex struct {
+	ipfs IPFSConnector
+
+	mux   sync.Mutex
+	homes map[string]*homeIndex
+	// paths waiting to be refreshed, by UID. A UID is in the map
+	// while its updates are running.
+	pending map[string]map[string]struct{}
+	wg      sync.WaitGroup
+}
+
+// homeIndex holds the entries of a home by path, and the paths of the
+// entries in every directory, so that subtrees can be removed without
+// scanning all the entries.
+type homeIndex struct {
+	mux      sync.Mutex
+	entries  map[string]api.FileMeta
+	children map[string]map[string]struct{}
+	// previous entries while rebuilding, to keep their
+	// modification times.
+	prev map[string]api.FileMeta
+}
+
+func newSearchIndex(ipfs IPFSConnector) *searchIndex {
+	return &searchIndex{
+		ipfs:    ipfs,
+		homes:   make(map[string]*homeIndex),
+		pending: make(map[string]map[string]struct{}),
+	}
+}
+
+func newHomeIndex() *homeIndex {
+	return &homeIndex{
+		entries:  make(map[string]api.FileMeta),
+		children: make(map[string]map[string]struct{}),
+	}
+}
+
+// update queues the given paths of a home to be refreshed after they
+// were modified. They are refreshed by a background worker for the home,
+// which refreshes paths queued several times only once. Homes which have
+// not been indexed yet are left alone, as well as staging homes.
+func (idx *searchIndex) update(uid string, paths ...string) {
+	if idx == nil || api.IsStagingHome(uid) {
+		return
+	}
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+	if _, ok := idx.homes[uid]; !ok {
+		return
+	}
+
+	queue, running := idx.pending[uid]
+	if !running {
+		queue = make(map[string]struct{})
+		idx.pending[uid] = queue
+	}
+	for _, p := range paths {
+		queue[cleanHomePath(p)] = struct{}{}
+	}
+	if !running {
+		idx.wg.Add(1)
+		go idx.runUpdates(uid)
+	}
+}
+
+// runUpdates refreshes the queued paths of a home until there are none
+// left.
+func (idx *searchIndex) runUpdates(uid string) {
+	defer idx.wg.Done()
+	for {
+		idx.mux.Lock()
+		queue := idx.pending[uid]
+		h, ok := idx.homes[uid]
+		if len(queue) == 0 || !ok {
+			delete(idx.pending, uid)
+			idx.mux.Unlock()
+			return
+		}
+		idx.pending[uid] = make(map[string]struct{})
+		idx.mux.Unlock()
+
+		h.mux.Lock()
+		now := time.Now()
+		for _, p := range updateRoots(queue) {
+			h.refresh(idx.ipfs, uid, p, now)
+		}
+		h.mux.Unlock()
+	}
+}
+
+// updateRoots returns the queued paths which are not below another
+// queued path, since refreshing a directory refreshes its subtree.
+func updateRoots(queue map[string]struct{}) []string {
+	paths := make([]string, 0, len(queue))
+	for p := range queue {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	roots := paths[:0]
+	for _, p := range paths {
+		if len(roots) > 0 && isBelow(p, roots[len(roots)-1]) {
+			continue
+		}
+		roots = append(roots, p)
+	}
+	return roots
+}
+
+// isBelow returns true when p is inside the directory dir.
+func isBelow(p, dir string) bool {
+	return strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// wait waits for the queued updates to be done.
+func (idx *searchIndex) wait() {
+	if idx == nil {
+		return
+	}
+	idx.wg.Wait()
+}
+
+// drop forgets the index of the given homes.
+func (idx *searchIndex) drop(uids ...string) {
+	if idx == nil {
+		return
+	}
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+	for _, uid := range uids {
+		delete(idx.homes, uid)
+	}
+}
+
+// rebuild walks the whole MFS tree of a home to index it from scratch.
+func (idx *searchIndex) rebuild(uid string) (*homeIndex, error) {
+	if idx == nil {
+		return nil, errSearchDisabled
+	}
+	if api.IsStagingHome(uid) {
+		return nil, errors.New("staging homes are not indexed: " + uid)
+	}
+
+	stat, err := idx.ipfs.FilesStat([]string{uid, "", "", "", "", ""})
+	if err != nil {
+		return nil, err
+	}
+
+	idx.mux.Lock()
+	h, ok := idx.homes[uid]
+	if !ok {
+		h = newHomeIndex()
+		idx.homes[uid] = h
+	}
+	idx.mux.Unlock()
+
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.prev = h.entries
+	h.entries = make(map[string]api.FileMeta)
+	h.children = make(map[string]map[string]struct{})
+	h.walk(idx.ipfs, uid, "/", stat, time.Now())
+	h.prev = nil
+	return h, nil
+}
+
+// search returns the entries of a home matching the query, sorted by
+// path. The home is indexed first if needed.
+func (idx *searchIndex) search(q api.SearchQuery) ([]api.FileMeta, error) {
+	if idx == nil {
+		return nil, errSearchDisabled
+	}
+
+	idx.mux.Lock()
+	h, ok := idx.homes[q.UID]
+	idx.mux.Unlock()
+	if !ok {
+		var err error
+		h, err = idx.rebuild(q.UID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	h.mux.Lock()
+	results := make([]api.FileMeta, 0)
+	for p, m := range h.entries {
+		if p != "/" && q.Match(m) {
+			results = append(results, m)
+		}
+	}
+	h.mux.Unlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Path < results[j].Path
+	})
+	if q.Limit > 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results, nil
+}
+
+// refresh updates the entry at p, its subtree and its parent directories.
+// Entries which no longer exist are removed.
+func (h *homeIndex) refresh(ipfs IPFSConnector, uid, p string, now time.Time) {
+	p = cleanHomePath(p)
+	if inTrash(p) {
+		return
+	}
+
+	// The parent directories change with their children.
+	var parents []string
+	for dir := p; dir != "/"; {
+		dir = path.Dir(dir)
+		parents = append([]string{dir}, parents...)
+	}
+	for _, dir := range parents {
+		stat, err := ipfs.FilesStat([]string{uid, dir, "", "", "", ""})
+		if err != nil {
+			h.remove(dir)
+			return
+		}
+		h.set(dir, stat, now)
+	}
+
+	stat, err := ipfs.FilesStat([]string{uid, p, "", "", "", ""})
+	if err != nil {
+		h.remove(p)
+		return
+	}
+	h.walk(ipfs, uid, p, stat, now)
+}
+
+// walk indexes p and, for directories which changed, everything below.
+func (h *homeIndex) walk(ipfs IPFSConnector, uid, p string, stat api.FilesStat, now time.Time) {
+	old, indexed := h.entries[p]
+	h.set(p, stat, now)
+
+	if stat.Type != "directory" {
+		h.removeChildren(p, nil)
+		return
+	}
+	if indexed && old.Type == "directory" && old.Hash == stat.Hash {
+		// Nothing changed below.
+		return
+	}
+
+	ls, err := ipfs.FilesLs([]string{uid, p})
+	if err != nil {
+		logger.Errorf("error listing %s of %s for the search index: %s", p, uid, err)
+		return
+	}
+
+	children := make(map[string]struct{}, len(ls.Entries))
+	for _, e := range ls.Entries {
+		child := path.Join(p, e.Name)
+		if inTrash(child) {
+			continue
+		}
+		children[child] = struct{}{}
+
+		cstat, err := ipfs.FilesStat([]string{uid, child, "", "", "", ""})
+		if err != nil {
+			logger.Errorf("error indexing %s of %s: %s", child, uid, err)
+			continue
+		}
+		h.walk(ipfs, uid, child, cstat, now)
+	}
+	h.removeChildren(p, children)
+}
+
+// set stores the entry for p. Since MFS does not keep modification times,
+// entries are given the time when the index finds them changed: now, or
+// the time they had when their hash did not change.
+func (h *homeIndex) set(p string, stat api.FilesStat, now time.Time) {
+	m := api.FileMeta{
+		Path:     p,
+		Name:     path.Base(p),
+		Size:     stat.Size,
+		Type:     stat.Type,
+		Hash:     stat.Hash,
+		Modified: now,
+	}
+	if stat.Type == "directory" {
+		m.Size = stat.CumulativeSize
+	}
+
+	if old, ok := h.entries[p]; ok && old.Hash == m.Hash {
+		m.Modified = old.Modified
+	} else if old, ok := h.prev[p]; ok && old.Hash == m.Hash {
+		m.Modified = old.Modified
+	}
+	h.entries[p] = m
+
+	if p != "/" {
+		dir := path.Dir(p)
+		if h.children[dir] == nil {
+			h.children[dir] = make(map[string]struct{})
+		}
+		h.children[dir][p] = struct{}{}
+	}
+}
+
+// remove deletes p and everything below.
+func (h *homeIndex) remove(p string) {
+	h.removeChildren(p, nil)
+	delete(h.entries, p)
+	delete(h.children, p)
+	if p != "/" {
+		delete(h.children[path.Dir(p)], p)
+	}
+}
+
+// removeChildren deletes the subtrees of the direct children of dir which
+// are not in keep.
+func (h *homeIndex) removeChildren(dir string, keep map[string]struct{}) {
+	for child := range h.children[dir] {
+		if _, ok := keep[child]; !ok {
+			h.remove(child)
+		}
+	}
+}
+
+// FilesSearch returns the files and directories in the home of a UID
+// which match the given query. It needs the search index to be enabled.
+func (c *Cluster) FilesSearch(q api.SearchQuery) ([]api.FileMeta, error) {
+	return c.search.search(q)
+}
+
+// FilesSearchRebuild indexes the home of a UID from scratch, walking its
+// whole MFS tree. It returns the number of indexed entries.
+func (c *Cluster) FilesSearchRebuild(uid string) (int, error) {
+	h, err := c.search.rebuild(uid)
+	if err != nil {
+		return 0, err
+	}
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	// The home root is not an entry.
+	return len(h.entries) - 1, nil
+}
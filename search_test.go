@@ -0,0 +1,183 @@
+package ipfscluster
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+)
+
+// mfsConnector fakes the MFS tree of a single home. Only FilesStat and
+// FilesLs are implemented.
+type mfsConnector struct {
+	IPFSConnector
+	files map[string]string // path -> content
+}
+
+func (mfs *mfsConnector) children(dir string) []string {
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	seen := make(map[string]bool)
+	var names []string
+	for p := range mfs.files {
+		if !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		name := strings.SplitN(p[len(prefix):], "/", 2)[0]
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (mfs *mfsConnector) FilesStat(st []string) (api.FilesStat, error) {
+	p := cleanHomePath(st[1])
+	if content, ok := mfs.files[p]; ok {
+		return api.FilesStat{
+			Hash: fmt.Sprintf("%x", sha256.Sum256([]byte(content))),
+			Size: uint64(len(content)),
+			Type: "file",
+		}, nil
+	}
+
+	names := mfs.children(p)
+	if len(names) == 0 && p != "/" {
+		return api.FilesStat{}, errors.New("file does not exist")
+	}
+	hash := ""
+	var size uint64
+	for _, name := range names {
+		stat, _ := mfs.FilesStat([]string{st[0], path.Join(p, name)})
+		hash += name + stat.Hash
+		size += stat.Size
+	}
+	return api.FilesStat{
+		Hash:           fmt.Sprintf("%x", sha256.Sum256([]byte(hash))),
+		CumulativeSize: size,
+		Type:           "directory",
+	}, nil
+}
+
+func (mfs *mfsConnector) FilesLs(l []string) (api.FilesLs, error) {
+	var ls api.FilesLs
+	for _, name := range mfs.children(cleanHomePath(l[1])) {
+		ls.Entries = append(ls.Entries, api.FileLsEntrie{Name: name})
+	}
+	return ls, nil
+}
+
+func searchPaths(t *testing.T, idx *searchIndex, q api.SearchQuery) []string {
+	q.UID = "uid-test"
+	results, err := idx.search(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := make([]string, len(results))
+	for i, m := range results {
+		paths[i] = m.Path
+	}
+	return paths
+}
+
+func TestSearchIndex(t *testing.T) {
+	mfs := &mfsConnector{files: map[string]string{
+		"/docs/a.txt":     "hello",
+		"/docs/b.jpg":     "image",
+		"/music/song.mp3": "la la",
+		"/.trash/1-L3g":   "deleted",
+	}}
+	idx := newSearchIndex(mfs)
+
+	paths := searchPaths(t, idx, api.SearchQuery{})
+	if strings.Join(paths, " ") != "/docs /docs/a.txt /docs/b.jpg /music /music/song.mp3" {
+		t.Fatal("unexpected entries:", paths)
+	}
+	a, _ := idx.search(api.SearchQuery{UID: "uid-test", Name: "a.txt"})
+	modified := a[0].Modified
+
+	// Add, remove and move some files
+	mfs.files["/docs/c.txt"] = "new"
+	delete(mfs.files, "/music/song.mp3")
+	mfs.files["/pics/b.jpg"] = mfs.files["/docs/b.jpg"]
+	delete(mfs.files, "/docs/b.jpg")
+	idx.update("uid-test", "/docs/c.txt", "/music/song.mp3", "/docs/b.jpg", "/pics/b.jpg")
+	idx.wait()
+
+	paths = searchPaths(t, idx, api.SearchQuery{Name: "*.txt"})
+	if strings.Join(paths, " ") != "/docs/a.txt /docs/c.txt" {
+		t.Error("unexpected txt files:", paths)
+	}
+	paths = searchPaths(t, idx, api.SearchQuery{Type: "directory"})
+	if strings.Join(paths, " ") != "/docs /pics" {
+		t.Error("unexpected directories:", paths)
+	}
+	paths = searchPaths(t, idx, api.SearchQuery{Path: "/pics"})
+	if strings.Join(paths, " ") != "/pics/b.jpg" {
+		t.Error("unexpected files in /pics:", paths)
+	}
+	paths = searchPaths(t, idx, api.SearchQuery{MinSize: 4, Type: "file"})
+	if strings.Join(paths, " ") != "/docs/a.txt /pics/b.jpg" {
+		t.Error("unexpected large files:", paths)
+	}
+
+	a, _ = idx.search(api.SearchQuery{UID: "uid-test", Name: "a.txt"})
+	if !a[0].Modified.Equal(modified) {
+		t.Error("the modification time of unchanged files should be kept")
+	}
+
+	// Changes to the whole home are found by refreshing its root.
+	mfs.files["/music/other.mp3"] = "tra la"
+	idx.update("uid-test", "/")
+	idx.wait()
+	paths = searchPaths(t, idx, api.SearchQuery{Name: "*.mp3"})
+	if strings.Join(paths, " ") != "/music/other.mp3" {
+		t.Error("unexpected mp3 files:", paths)
+	}
+
+	paths = searchPaths(t, idx, api.SearchQuery{Limit: 2})
+	if len(paths) != 2 {
+		t.Error("the results should be limited:", paths)
+	}
+
+	_, err := idx.search(api.SearchQuery{UID: api.NewStagingHome("uid-test")})
+	if err == nil {
+		t.Error("staging homes should not be indexed")
+	}
+}
+
+func TestUpdateRoots(t *testing.T) {
+	queue := map[string]struct{}{
+		"/docs/a.txt": {},
+		"/docs":       {},
+		"/docsa":      {},
+		"/music/x":    {},
+	}
+	roots := updateRoots(queue)
+	if strings.Join(roots, " ") != "/docs /docsa /music/x" {
+		t.Error("unexpected roots:", roots)
+	}
+
+	queue["/"] = struct{}{}
+	roots = updateRoots(queue)
+	if strings.Join(roots, " ") != "/" {
+		t.Error("the home root should cover everything:", roots)
+	}
+}
+
+func TestSearchIndexDisabled(t *testing.T) {
+	var idx *searchIndex
+	idx.update("uid-test", "/docs")
+	idx.wait()
+	idx.drop("uid-test")
+	_, err := idx.search(api.SearchQuery{UID: "uid-test"})
+	if err != errSearchDisabled {
+		t.Error("expected errSearchDisabled")
+	}
+}
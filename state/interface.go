@@ -7,6 +7,7 @@ import (
 	"io"
 
 	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-peer"
 
 	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
 )
@@ -24,6 +25,13 @@ type State interface {
 	Has(cid.Cid) bool
 	// Get returns the information attacthed to this pin
 	Get(cid.Cid) (api.Pin, bool)
+	// Drain marks a peer as draining, so that it receives no new
+	// allocations and its pins are moved elsewhere
+	Drain(peer.ID) error
+	// Undrain clears the draining mark of a peer
+	Undrain(peer.ID) error
+	// Draining lists the peers marked as draining
+	Draining() []peer.ID
 	// Migrate restores the serialized format of an outdated state to the current version
 	Migrate(r io.Reader) error
 	// Return the version of this state
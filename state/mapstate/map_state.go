@@ -13,6 +13,7 @@ import (
 
 	cid "github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log"
+	peer "github.com/libp2p/go-libp2p-peer"
 
 	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
 )
@@ -26,16 +27,20 @@ var logger = logging.Logger("mapstate")
 // MapState is a very simple database to store the state of the system
 // using a Go map. It is thread safe. It implements the State interface.
 type MapState struct {
-	pinMux  sync.RWMutex
-	PinMap  map[string]api.PinSerial
-	Version int
+	pinMux sync.RWMutex
+	PinMap map[string]api.PinSerial
+	// DrainMap is left out of the encoding when empty so that states
+	// without draining peers are unchanged.
+	DrainMap map[string]bool `codec:",omitempty"`
+	Version  int
 }
 
 // NewMapState initializes the internal map and returns a new MapState object.
 func NewMapState() *MapState {
 	return &MapState{
-		PinMap:  make(map[string]api.PinSerial),
-		Version: Version,
+		PinMap:   make(map[string]api.PinSerial),
+		DrainMap: make(map[string]bool),
+		Version:  Version,
 	}
 }
 
@@ -95,6 +100,41 @@ func (st *MapState) List() []api.Pin {
 	return cids
 }
 
+// Drain marks a peer as draining.
+func (st *MapState) Drain(p peer.ID) error {
+	st.pinMux.Lock()
+	defer st.pinMux.Unlock()
+	if st.DrainMap == nil {
+		st.DrainMap = make(map[string]bool)
+	}
+	st.DrainMap[peer.IDB58Encode(p)] = true
+	return nil
+}
+
+// Undrain clears the draining mark of a peer.
+func (st *MapState) Undrain(p peer.ID) error {
+	st.pinMux.Lock()
+	defer st.pinMux.Unlock()
+	delete(st.DrainMap, peer.IDB58Encode(p))
+	return nil
+}
+
+// Draining provides the list of peers marked as draining.
+func (st *MapState) Draining() []peer.ID {
+	st.pinMux.RLock()
+	defer st.pinMux.RUnlock()
+	peers := make([]peer.ID, 0, len(st.DrainMap))
+	for k := range st.DrainMap {
+		p, err := peer.IDB58Decode(k)
+		if err != nil {
+			logger.Error(err)
+			continue
+		}
+		peers = append(peers, p)
+	}
+	return peers
+}
+
 // Migrate restores a snapshot from the state's internal bytes and if
 // necessary migrates the format to the current version.
 func (st *MapState) Migrate(r io.Reader) error {
@@ -169,6 +209,10 @@ func (st *MapState) Unmarshal(bs []byte) error {
 	}
 
 	st.PinMap = newState.PinMap
+	st.DrainMap = newState.DrainMap
+	if st.DrainMap == nil {
+		st.DrainMap = make(map[string]bool)
+	}
 	st.Version = newState.Version
 	return err
 }
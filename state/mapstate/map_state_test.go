@@ -77,6 +77,62 @@ func TestList(t *testing.T) {
 	}
 }
 
+func TestDrain(t *testing.T) {
+	ms := NewMapState()
+	ms.Drain(testPeerID1)
+	draining := ms.Draining()
+	if len(draining) != 1 || draining[0] != testPeerID1 {
+		t.Error("should have marked the peer as draining")
+	}
+
+	b, err := ms.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms2 := NewMapState()
+	err = ms2.Unmarshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms2.Draining()) != 1 {
+		t.Error("draining peers should survive marshaling")
+	}
+
+	ms.Undrain(testPeerID1)
+	if len(ms.Draining()) != 0 {
+		t.Error("should have cleared the draining mark")
+	}
+}
+
+func TestUnmarshalWithoutDrainMap(t *testing.T) {
+	// States written before drain marks existed have no DrainMap.
+	old := struct {
+		PinMap  map[string]api.PinSerial
+		Version int
+	}{
+		PinMap:  map[string]api.PinSerial{c.Cid.String(): c.ToSerial()},
+		Version: Version,
+	}
+	buf := new(bytes.Buffer)
+	enc := msgpack.Multicodec(msgpack.DefaultMsgpackHandle()).Encoder(buf)
+	err := enc.Encode(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ms := NewMapState()
+	err = ms.Unmarshal(append([]byte{byte(Version)}, buf.Bytes()...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ms.Has(c.Cid) {
+		t.Error("unmarshaled state does not contain cid")
+	}
+	if len(ms.Draining()) != 0 {
+		t.Error("unmarshaled state should have no draining peers")
+	}
+}
+
 func TestMarshalUnmarshal(t *testing.T) {
 	ms := NewMapState()
 	ms.Add(c)
@@ -0,0 +1,234 @@
+package ipfscluster
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+	"github.com/elastos/Elastos.NET.Hive.Cluster/version"
+
+	inet "github.com/libp2p/go-libp2p-net"
+	peer "github.com/libp2p/go-libp2p-peer"
+)
+
+// statusStreamPageSize is the number of items which are fetched from the
+// tracker at a time while streaming the status of a peer.
+var statusStreamPageSize = 500
+
+// statusStreamBuffer is the number of items which are read ahead from the
+// status stream of every peer while merging them.
+var statusStreamBuffer = 64
+
+// handleStatusStream serves the status requests of other peers. A request
+// is a JSON-encoded api.ListPage. It is answered with the PinInfos tracked
+// by this peer which are selected by the page, sorted by Cid, one JSON
+// object per line. Without a page limit, the whole listing is sent, unless
+// the requesting peer resets the stream first.
+func (c *Cluster) handleStatusStream(s inet.Stream) {
+	var page api.ListPage
+	err := json.NewDecoder(s).Decode(&page)
+	if err != nil {
+		logger.Errorf("error reading status request: %s", err)
+		s.Reset()
+		return
+	}
+
+	enc := json.NewEncoder(s)
+	err = c.walkStatusLocal(page, func(pi api.PinInfo) error {
+		return enc.Encode(pi.ToSerial())
+	})
+	if err != nil {
+		logger.Debugf("status stream to %s interrupted: %s", s.Conn().RemotePeer().Pretty(), err)
+		s.Reset()
+		return
+	}
+	s.Close()
+}
+
+// walkStatusLocal calls f with the PinInfos tracked by this peer which are
+// selected by the given page, in Cid order. They are fetched from the
+// tracker a few at a time. It stops at the first error returned by f.
+func (c *Cluster) walkStatusLocal(page api.ListPage, f func(api.PinInfo) error) error {
+	trackerPage := api.ListPage{
+		Cursor: page.Cursor,
+		Limit:  statusStreamPageSize,
+	}
+	sent := 0
+	for {
+		if page.Limit > 0 && page.Limit-sent < trackerPage.Limit {
+			trackerPage.Limit = page.Limit - sent
+		}
+
+		pinfos := c.tracker.StatusAllPage(trackerPage)
+		for _, pi := range pinfos {
+			err := f(pi)
+			if err != nil {
+				return err
+			}
+		}
+		sent += len(pinfos)
+
+		n := len(pinfos)
+		if n < trackerPage.Limit || (page.Limit > 0 && sent >= page.Limit) {
+			return nil
+		}
+		trackerPage.Cursor = pinfos[n-1].Cid.String()
+	}
+}
+
+// streamStatus sends to out the PinInfos tracked by the given peer which
+// are selected by the page, in Cid order, as they are received. It returns
+// when the listing is over, or with the error which interrupted it.
+func (c *Cluster) streamStatus(ctx context.Context, p peer.ID, page api.ListPage, out chan<- api.PinInfo) error {
+	send := func(pi api.PinInfo) error {
+		select {
+		case out <- pi:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if p == c.id {
+		return c.walkStatusLocal(page, send)
+	}
+
+	s, err := c.host.NewStream(ctx, p, version.StatusProtocol)
+	if err != nil {
+		return err
+	}
+
+	// Reading from the stream does not watch the context.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Reset()
+		case <-done:
+		}
+	}()
+
+	err = json.NewEncoder(s).Encode(page)
+	if err != nil {
+		s.Reset()
+		return err
+	}
+
+	dec := json.NewDecoder(s)
+	for {
+		var pis api.PinInfoSerial
+		err := dec.Decode(&pis)
+		if err == io.EOF {
+			s.Close()
+			return nil
+		}
+		if err == nil {
+			err = send(pis.ToPinInfo())
+		}
+		if err != nil {
+			s.Reset()
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+	}
+}
+
+// StatusAllStream sends to out the GlobalPinInfo for the tracked Cids
+// selected by the given page, sorted by Cid, and closes it when done. The
+// status of every peer is streamed from it and merged as it arrives, so
+// the listing is never held at once. Peers whose stream fails are reported
+// with a ClusterError status for the Cids sent after the failure.
+func (c *Cluster) StatusAllStream(ctx context.Context, page api.ListPage, out chan<- api.GlobalPinInfo) error {
+	defer close(out)
+
+	members, err := c.consensus.Peers()
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+	lenMembers := len(members)
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	ins := make([]chan api.PinInfo, lenMembers, lenMembers)
+	errs := make([]error, lenMembers, lenMembers)
+	for i, p := range members {
+		ins[i] = make(chan api.PinInfo, statusStreamBuffer)
+		wg.Add(1)
+		go func(i int, p peer.ID) {
+			defer wg.Done()
+			defer close(ins[i])
+			errs[i] = c.streamStatus(ctx, p, page, ins[i])
+		}(i, p)
+	}
+
+	// heads holds the next PinInfo from every peer. A peer is done
+	// once its stream is closed, and errs can then be read.
+	heads := make([]api.PinInfo, lenMembers, lenMembers)
+	done := make([]bool, lenMembers, lenMembers)
+	next := func(i int) {
+		pi, ok := <-ins[i]
+		heads[i] = pi
+		if !ok {
+			done[i] = true
+			if e := errs[i]; e != nil && e != context.Canceled {
+				logger.Errorf("%s: error in status stream from %s: %s ", c.id, members[i], e)
+			}
+		}
+	}
+	for i := range members {
+		next(i)
+	}
+
+	sent := 0
+	for page.Limit <= 0 || sent < page.Limit {
+		min := ""
+		for i, pi := range heads {
+			if !done[i] && (min == "" || pi.Cid.String() < min) {
+				min = pi.Cid.String()
+			}
+		}
+		if min == "" {
+			return nil
+		}
+
+		var gpi api.GlobalPinInfo
+		gpi.PeerMap = make(map[peer.ID]api.PinInfo)
+		for i, pi := range heads {
+			if done[i] || pi.Cid.String() != min {
+				continue
+			}
+			gpi.Cid = pi.Cid
+			gpi.PeerMap[pi.Peer] = pi
+			next(i)
+		}
+		for i, p := range members {
+			if done[i] && errs[i] != nil {
+				gpi.PeerMap[p] = api.PinInfo{
+					Cid:    gpi.Cid,
+					Peer:   p,
+					Status: api.TrackerStatusClusterError,
+					TS:     time.Now(),
+					Error:  errs[i].Error(),
+				}
+			}
+		}
+
+		select {
+		case out <- gpi:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		sent++
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 // Common variables used all around tests.
 var (
 	TestCid1     = "QmP63DkAFEnDYNjDYBpyNDfttu1fvUw99x1brscPzpqmmq"
+	TestCid1Data = "<html><body>Cid1Data</body></html>" // Served by the ipfs mock on cat and get
 	TestCid2     = "QmP63DkAFEnDYNjDYBpyNDfttu1fvUw99x1brscPzpqmma"
 	TestCid3     = "QmP63DkAFEnDYNjDYBpyNDfttu1fvUw99x1brscPzpqmmb"
 	TestCid4     = "zb2rhiKhUepkTMw7oFfBUnChAN7ABAvg2hXUwmTBtZ6yxuc57"
@@ -23,6 +24,10 @@ var (
 	TestPeerID5, _ = peer.IDB58Decode("QmZVAo3wd8s5eTTy2kPYs34J9PvfxpKPuYsePPYGjgRRjg")
 	TestPeerID6, _ = peer.IDB58Decode("QmR8Vu6kZk7JvAN2rWVWgiduHatgBq2bb15Yyq8RRhYSbx")
 
+	TestUID = "uid-test"
+	// TestTrashID is the trash entry of /docs/file in the home of TestUID.
+	TestTrashID = "1540000000000000000-L2RvY3MvZmlsZQ"
+
 	TestPeerName1 = "TestPeer1"
 	TestPeerName2 = "TestPeer2"
 	TestPeerName3 = "TestPeer3"
@@ -1,6 +1,7 @@
 package test
 
 import (
+	"archive/tar"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -35,12 +36,19 @@ type IpfsMock struct {
 	Port       int
 	pinMap     *mapstate.MapState
 	BlockStore map[string][]byte
+	// Keys holds the IDs of the keys listed by "key/list", by name,
+	// besides "self".
+	Keys map[string]string
 }
 
 type mockPinResp struct {
 	Pins []string
 }
 
+type mockPinProgressResp struct {
+	Progress int
+}
+
 type mockPinType struct {
 	Type string
 }
@@ -94,6 +102,41 @@ type mockBlockPutResp struct {
 	Key string
 }
 
+type mockFilesStatResp struct {
+	Hash string
+	Size uint64
+	Type string
+}
+
+type mockLsLink struct {
+	Name string
+	Hash string
+	Size uint64
+	Type int
+}
+
+type mockLsObject struct {
+	Hash  string
+	Links []mockLsLink
+}
+
+type mockLsResp struct {
+	Objects []mockLsObject
+}
+
+type mockNameResolveResp struct {
+	Path string
+}
+
+type mockKey struct {
+	Name string
+	Id   string
+}
+
+type mockKeyListResp struct {
+	Keys []mockKey
+}
+
 // NewIpfsMock returns a new mock.
 func NewIpfsMock() *IpfsMock {
 	st := mapstate.NewMapState()
@@ -101,6 +144,7 @@ func NewIpfsMock() *IpfsMock {
 	m := &IpfsMock{
 		pinMap:     st,
 		BlockStore: blocks,
+		Keys:       make(map[string]string),
 	}
 
 	mux := http.NewServeMux()
@@ -157,6 +201,10 @@ func (m *IpfsMock) handler(w http.ResponseWriter, r *http.Request) {
 			goto ERROR
 		}
 		m.pinMap.Add(api.PinCid(c))
+		if r.URL.Query().Get("progress") == "true" {
+			j, _ := json.Marshal(mockPinProgressResp{Progress: 1})
+			w.Write(j)
+		}
 		resp := mockPinResp{
 			Pins: []string{arg},
 		}
@@ -322,6 +370,103 @@ func (m *IpfsMock) handler(w http.ResponseWriter, r *http.Request) {
 		w.Write(j)
 	case "version":
 		w.Write([]byte("{\"Version\":\"m.o.c.k\"}"))
+	case "key/list":
+		resp := mockKeyListResp{
+			Keys: []mockKey{{Name: "self", Id: TestPeerID1.Pretty()}},
+		}
+		for name, id := range m.Keys {
+			resp.Keys = append(resp.Keys, mockKey{Name: name, Id: id})
+		}
+		j, _ := json.Marshal(resp)
+		w.Write(j)
+	case "files/stat":
+		arg, ok := extractCid(r.URL)
+		if !ok {
+			goto ERROR
+		}
+		hash, ok := mockPath(arg)
+		if !ok {
+			goto ERROR
+		}
+		resp := mockFilesStatResp{
+			Hash: hash,
+			Type: "directory",
+		}
+		if hash == TestCid1 {
+			resp.Size = uint64(len(TestCid1Data))
+			resp.Type = "file"
+		}
+		j, _ := json.Marshal(resp)
+		w.Write(j)
+	case "ls":
+		arg, ok := extractCid(r.URL)
+		if !ok {
+			goto ERROR
+		}
+		hash, ok := mockPath(arg)
+		if !ok || hash != TestCid2 {
+			goto ERROR
+		}
+		resp := mockLsResp{
+			Objects: []mockLsObject{
+				{
+					Hash: TestCid2,
+					Links: []mockLsLink{
+						{
+							Name: "file",
+							Hash: TestCid1,
+							Size: uint64(len(TestCid1Data)),
+							Type: 2,
+						},
+					},
+				},
+			},
+		}
+		j, _ := json.Marshal(resp)
+		w.Write(j)
+	case "name/resolve":
+		arg, ok := extractCid(r.URL)
+		if !ok || strings.TrimPrefix(arg, "/ipns/") != TestPeerID1.Pretty() {
+			goto ERROR
+		}
+		j, _ := json.Marshal(mockNameResolveResp{Path: "/ipfs/" + TestCid2})
+		w.Write(j)
+	case "cat":
+		arg, ok := extractCid(r.URL)
+		if !ok {
+			goto ERROR
+		}
+		hash, ok := mockPath(arg)
+		if !ok || hash != TestCid1 {
+			goto ERROR
+		}
+		data := TestCid1Data
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		if offset > len(data) {
+			offset = len(data)
+		}
+		data = data[offset:]
+		if l := r.URL.Query().Get("length"); l != "" {
+			length, _ := strconv.Atoi(l)
+			if length < len(data) {
+				data = data[:length]
+			}
+		}
+		w.Write([]byte(data))
+	case "get":
+		arg, ok := extractCid(r.URL)
+		if !ok || strings.TrimPrefix(arg, "/ipfs/") != TestCid1 {
+			goto ERROR
+		}
+		tw := tar.NewWriter(w)
+		tw.WriteHeader(&tar.Header{
+			Name:     TestCid1,
+			Mode:     0644,
+			Size:     int64(len(TestCid1Data)),
+			Typeflag: tar.TypeReg,
+		})
+		tw.Write([]byte(TestCid1Data))
+		tw.Close()
 	default:
 		w.WriteHeader(http.StatusNotFound)
 	}
@@ -336,6 +481,30 @@ func (m *IpfsMock) Close() {
 	m.server.Close()
 }
 
+// mockPath resolves the IPFS and MFS paths known by the mock: TestCid1 is a
+// file holding TestCid1Data and TestCid2 a directory holding it as "file".
+// The "public" folder of every UID home is that same directory. It returns
+// the CID of the given path.
+func mockPath(p string) (string, bool) {
+	segs := strings.Split(strings.Trim(p, "/"), "/")
+	switch {
+	case len(segs) >= 2 && segs[0] == "ipfs":
+		segs = segs[1:]
+	case len(segs) >= 3 && segs[0] == "nodes" && segs[2] == "public":
+		segs = append([]string{TestCid2}, segs[3:]...)
+	case len(segs) > 1:
+		return "", false
+	}
+
+	switch {
+	case len(segs) == 1 && (segs[0] == TestCid1 || segs[0] == TestCid2):
+		return segs[0], true
+	case len(segs) == 2 && segs[0] == TestCid2 && segs[1] == "file":
+		return TestCid1, true
+	}
+	return "", false
+}
+
 // extractCid extracts the cid argument from a url.URL, either via
 // the query string parameters or from the url path itself.
 func extractCid(u *url.URL) (string, bool) {
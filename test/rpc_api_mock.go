@@ -3,15 +3,18 @@ package test
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
 
 	cid "github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
 	rpc "github.com/libp2p/go-libp2p-gorpc"
 	host "github.com/libp2p/go-libp2p-host"
 	peer "github.com/libp2p/go-libp2p-peer"
+	mh "github.com/multiformats/go-multihash"
 )
 
 // ErrBadCid is returned when using ErrorCid. Operations with that CID always
@@ -20,6 +23,23 @@ var ErrBadCid = errors.New("this is an expected error when using ErrorCid")
 
 type mockService struct{}
 
+var mockTrashEntry = api.TrashEntry{
+	ID:      TestTrashID,
+	Path:    "/docs/file",
+	Deleted: time.Unix(0, 1540000000000000000),
+	Type:    "file",
+	Size:    34,
+	Hash:    TestCid1,
+}
+
+// testClusterDAG is the Cluster DAG of TestCid4, which the mock tracks
+// as a meta-pin with a single shard (TestCid2).
+var testClusterDAG, _ = cbor.WrapObject(
+	map[string]cid.Cid{"0": MustDecodeCid(TestCid2)},
+	mh.SHA2_256,
+	mh.DefaultLengths[mh.SHA2_256],
+)
+
 // NewMockRPCClient creates a mock ipfs-cluster RPC server and returns
 // a client to it.
 func NewMockRPCClient(t testing.TB) *rpc.Client {
@@ -66,21 +86,67 @@ func (mock *mockService) Pins(ctx context.Context, in struct{}, out *[]api.PinSe
 	return nil
 }
 
+func (mock *mockService) PinsPage(ctx context.Context, in api.ListPage, out *[]api.PinSerial) error {
+	var pinsS []api.PinSerial
+	mock.Pins(ctx, struct{}{}, &pinsS)
+	pins := make([]api.Pin, len(pinsS), len(pinsS))
+	for i, p := range pinsS {
+		pins[i] = p.ToPin()
+	}
+	pins = in.Pins(pins)
+	*out = make([]api.PinSerial, len(pins), len(pins))
+	for i, p := range pins {
+		(*out)[i] = p.ToSerial()
+	}
+	return nil
+}
+
+func (mock *mockService) Operations(ctx context.Context, in struct{}, out *[]api.OperationSerial) error {
+	return mock.TrackerOperations(ctx, in, out)
+}
+
+func (mock *mockService) OperationsLocal(ctx context.Context, in struct{}, out *[]api.OperationSerial) error {
+	return mock.TrackerOperations(ctx, in, out)
+}
+
+func (mock *mockService) CancelOperation(ctx context.Context, in api.OperationSerial, out *struct{}) error {
+	return mock.TrackerCancelOperation(ctx, in, out)
+}
+
+func (mock *mockService) CancelOperationLocal(ctx context.Context, in api.OperationSerial, out *struct{}) error {
+	return mock.TrackerCancelOperation(ctx, in, out)
+}
+
+func (mock *mockService) SetOperationPriority(ctx context.Context, in api.OperationSerial, out *struct{}) error {
+	return mock.TrackerSetOperationPriority(ctx, in, out)
+}
+
+func (mock *mockService) SetOperationPriorityLocal(ctx context.Context, in api.OperationSerial, out *struct{}) error {
+	return mock.TrackerSetOperationPriority(ctx, in, out)
+}
+
 func (mock *mockService) PinGet(ctx context.Context, in api.PinSerial, out *api.PinSerial) error {
 	switch in.Cid {
 	case ErrorCid:
 		return errors.New("this is an expected error when using ErrorCid")
-	case TestCid1, TestCid3:
+	case TestCid1, TestCid3: // TestCid1 is public, TestCid3 is not
 		p := api.PinCid(MustDecodeCid(in.Cid)).ToSerial()
 		p.ReplicationFactorMin = -1
 		p.ReplicationFactorMax = -1
+		p.Public = in.Cid == TestCid1
 		*out = p
 		return nil
-	case TestCid2: // This is a remote pin
+	case TestCid2: // This is a remote, public pin
 		p := api.PinCid(MustDecodeCid(in.Cid)).ToSerial()
 		p.ReplicationFactorMin = 1
 		p.ReplicationFactorMax = 1
+		p.Public = true
 		*out = p
+	case TestCid4: // This is a meta-pin
+		p := api.PinCid(MustDecodeCid(in.Cid))
+		p.Type = api.MetaType
+		p.Reference = testClusterDAG.Cid()
+		*out = p.ToSerial()
 	default:
 		return errors.New("not found")
 	}
@@ -131,6 +197,48 @@ func (mock *mockService) PeerRemove(ctx context.Context, in peer.ID, out *struct
 	return nil
 }
 
+func (mock *mockService) PublishEvent(ctx context.Context, in api.EventSerial, out *struct{}) error {
+	return nil
+}
+
+func (mock *mockService) SendAlert(ctx context.Context, in api.Alert, out *struct{}) error {
+	return nil
+}
+
+func (mock *mockService) Alerts(ctx context.Context, in struct{}, out *[]api.AlertSerial) error {
+	now := time.Now()
+	*out = []api.AlertSerial{
+		api.Alert{
+			Peer:       TestPeerID2,
+			MetricName: "freespace",
+			Value:      "1024",
+			Message:    "freespace is below 2048",
+			Policy:     "stop_allocating",
+			TS:         now,
+			Expire:     now.Add(time.Minute),
+		}.ToSerial(),
+	}
+	return nil
+}
+
+func (mock *mockService) PeerDrain(ctx context.Context, in peer.ID, out *struct{}) error {
+	return nil
+}
+
+func (mock *mockService) PeerUndrain(ctx context.Context, in peer.ID, out *struct{}) error {
+	return nil
+}
+
+func (mock *mockService) DrainStatus(ctx context.Context, in struct{}, out *[]api.DrainInfoSerial) error {
+	*out = []api.DrainInfoSerial{
+		api.DrainInfo{
+			Peer:      TestPeerID2,
+			Remaining: 2,
+		}.ToSerial(),
+	}
+	return nil
+}
+
 func (mock *mockService) ConnectGraph(ctx context.Context, in struct{}, out *api.ConnectGraphSerial) error {
 	*out = api.ConnectGraphSerial{
 		ClusterID: TestPeerID1.Pretty(),
@@ -153,6 +261,80 @@ func (mock *mockService) ConnectGraph(ctx context.Context, in struct{}, out *api
 	return nil
 }
 
+func (mock *mockService) PinsHealth(ctx context.Context, in int, out *api.PinsHealthReportSerial) error {
+	c1, _ := cid.Decode(TestCid1)
+	c2, _ := cid.Decode(TestCid2)
+	report := api.PinsHealthReport{
+		Peer:        TestPeerID1,
+		TS:          time.Now(),
+		ClusterSize: 3,
+		Total:       3,
+		Summary: map[api.PinHealthState]int{
+			api.PinHealthOK:              1,
+			api.PinHealthDegraded:        1,
+			api.PinHealthUnderReplicated: 1,
+		},
+		Worst: []api.PinHealth{
+			{
+				Cid:                  c1,
+				ReplicationFactorMin: 2,
+				ReplicationFactorMax: 3,
+				Allocations:          []peer.ID{TestPeerID1, TestPeerID2, TestPeerID3},
+				Pinned:               1,
+				Down:                 []peer.ID{TestPeerID2},
+				Errored:              []peer.ID{TestPeerID3},
+				State:                api.PinHealthUnderReplicated,
+			},
+			{
+				Cid:                  c2,
+				ReplicationFactorMin: 1,
+				ReplicationFactorMax: 2,
+				Allocations:          []peer.ID{TestPeerID1, TestPeerID2},
+				Pinned:               1,
+				Down:                 []peer.ID{TestPeerID2},
+				State:                api.PinHealthDegraded,
+			},
+		},
+	}
+	if in > 0 && len(report.Worst) > in {
+		report.Worst = report.Worst[:in]
+	}
+	*out = report.ToSerial()
+	return nil
+}
+
+func (mock *mockService) PinsHealthLocal(ctx context.Context, in int, out *api.PinsHealthReportSerial) error {
+	return mock.PinsHealth(ctx, in, out)
+}
+
+func (mock *mockService) Rebalance(ctx context.Context, in bool, out *api.RebalanceReportSerial) error {
+	c1, _ := cid.Decode(TestCid1)
+	report := api.RebalanceReport{
+		Peer:   TestPeerID1,
+		TS:     time.Now(),
+		Metric: "numpin",
+		DryRun: in,
+		Loads: map[peer.ID]string{
+			TestPeerID1: "10",
+			TestPeerID2: "2",
+		},
+		Moves: []api.RebalanceMove{
+			{
+				Cid:  c1,
+				Name: "a",
+				From: TestPeerID1,
+				To:   TestPeerID2,
+			},
+		},
+	}
+	*out = report.ToSerial()
+	return nil
+}
+
+func (mock *mockService) RebalanceLocal(ctx context.Context, in bool, out *api.RebalanceReportSerial) error {
+	return mock.Rebalance(ctx, in, out)
+}
+
 func (mock *mockService) StatusAll(ctx context.Context, in struct{}, out *[]api.GlobalPinInfoSerial) error {
 	c1, _ := cid.Decode(TestCid1)
 	c2, _ := cid.Decode(TestCid2)
@@ -173,10 +355,11 @@ func (mock *mockService) StatusAll(ctx context.Context, in struct{}, out *[]api.
 			Cid: c2,
 			PeerMap: map[peer.ID]api.PinInfo{
 				TestPeerID1: {
-					Cid:    c2,
-					Peer:   TestPeerID1,
-					Status: api.TrackerStatusPinning,
-					TS:     time.Now(),
+					Cid:      c2,
+					Peer:     TestPeerID1,
+					Status:   api.TrackerStatusPinning,
+					TS:       time.Now(),
+					Progress: 42,
 				},
 			},
 		},
@@ -195,10 +378,32 @@ func (mock *mockService) StatusAll(ctx context.Context, in struct{}, out *[]api.
 	return nil
 }
 
+func (mock *mockService) StatusAllPage(ctx context.Context, in api.ListPage, out *[]api.GlobalPinInfoSerial) error {
+	var gpisS []api.GlobalPinInfoSerial
+	mock.StatusAll(ctx, struct{}{}, &gpisS)
+	gpis := make([]api.GlobalPinInfo, len(gpisS), len(gpisS))
+	for i, gpi := range gpisS {
+		gpis[i] = gpi.ToGlobalPinInfo()
+	}
+	*out = globalPinInfoSliceToSerial(in.GlobalPinInfos(gpis))
+	return nil
+}
+
 func (mock *mockService) StatusAllLocal(ctx context.Context, in struct{}, out *[]api.PinInfoSerial) error {
 	return mock.TrackerStatusAll(ctx, in, out)
 }
 
+func (mock *mockService) StatusAllLocalPage(ctx context.Context, in api.ListPage, out *[]api.PinInfoSerial) error {
+	var pisS []api.PinInfoSerial
+	mock.TrackerStatusAll(ctx, struct{}{}, &pisS)
+	pis := make([]api.PinInfo, len(pisS), len(pisS))
+	for i, pi := range pisS {
+		pis[i] = pi.ToPinInfo()
+	}
+	*out = pinInfoSliceToSerial(in.PinInfos(pis))
+	return nil
+}
+
 func (mock *mockService) Status(ctx context.Context, in api.PinSerial, out *api.GlobalPinInfoSerial) error {
 	if in.Cid == ErrorCid {
 		return ErrBadCid
@@ -293,6 +498,50 @@ func (mock *mockService) TrackerStatusAll(ctx context.Context, in struct{}, out
 	return nil
 }
 
+func (mock *mockService) TrackerOperations(ctx context.Context, in struct{}, out *[]api.OperationSerial) error {
+	*out = []api.OperationSerial{
+		api.Operation{
+			Cid:      MustDecodeCid(TestCid1),
+			Peer:     TestPeerID1,
+			PeerName: TestPeerName1,
+			Type:     "pin",
+			Phase:    "queued",
+			Created:  time.Now(),
+			TS:       time.Now(),
+		}.ToSerial(),
+		api.Operation{
+			Cid:      MustDecodeCid(TestCid3),
+			Peer:     TestPeerID1,
+			PeerName: TestPeerName1,
+			Type:     "pin",
+			Phase:    "error",
+			Attempts: 1,
+			Error:    "an error",
+			Created:  time.Now(),
+			TS:       time.Now(),
+		}.ToSerial(),
+	}
+	return nil
+}
+
+func (mock *mockService) TrackerCancelOperation(ctx context.Context, in api.OperationSerial, out *struct{}) error {
+	if in.Cid != TestCid1 {
+		return errors.New("no queued or in-progress operation for the given cid")
+	}
+	return nil
+}
+
+func (mock *mockService) TrackerSetOperationPriority(ctx context.Context, in api.OperationSerial, out *struct{}) error {
+	if in.Cid != TestCid1 {
+		return errors.New("the operation for the given cid is not queued")
+	}
+	return nil
+}
+
+func (mock *mockService) TrackerSetProgress(ctx context.Context, in api.PinInfoSerial, out *struct{}) error {
+	return nil
+}
+
 func (mock *mockService) TrackerStatus(ctx context.Context, in api.PinSerial, out *api.PinInfoSerial) error {
 	if in.Cid == ErrorCid {
 		return ErrBadCid
@@ -345,6 +594,30 @@ func (mock *mockService) PeerMonitorLatestMetrics(ctx context.Context, in string
 	return nil
 }
 
+// PeerMonitorMetricsHistory runs PeerMonitor.MetricsHistory().
+func (mock *mockService) PeerMonitorMetricsHistory(ctx context.Context, in string, out *[]api.MetricHistorySerial) error {
+	now := time.Now()
+	h := api.MetricHistory{
+		Name: in,
+		Peer: TestPeerID1,
+		Samples: []api.MetricSample{
+			{Value: "10", Valid: true, TS: now.Add(-time.Second)},
+			{Value: "20", Valid: true, TS: now},
+		},
+		Min:  10,
+		Max:  20,
+		Avg:  15,
+		Rate: 10,
+	}
+	*out = []api.MetricHistorySerial{h.ToSerial()}
+	return nil
+}
+
+// PeerMonitorSendAlert runs PeerMonitor.SendAlert().
+func (mock *mockService) PeerMonitorSendAlert(ctx context.Context, in api.Alert, out *struct{}) error {
+	return nil
+}
+
 /* IPFSConnector methods */
 
 func (mock *mockService) IPFSPin(ctx context.Context, in api.PinSerial, out *struct{}) error {
@@ -406,6 +679,207 @@ func (mock *mockService) IPFSBlockPut(ctx context.Context, in api.NodeWithMeta,
 	return nil
 }
 
+func (mock *mockService) IPFSBlockGet(ctx context.Context, in api.PinSerial, out *[]byte) error {
+	switch in.Cid {
+	case TestCid4:
+		*out = []byte(TestCid4Data)
+	case testClusterDAG.Cid().String():
+		*out = testClusterDAG.RawData()
+	default:
+		return errors.New("block not found")
+	}
+	return nil
+}
+
+// IPFSBlockGetLocal behaves as if the local IPFS daemon only held the
+// Cluster DAG of TestCid4, and not its shard.
+func (mock *mockService) IPFSBlockGetLocal(ctx context.Context, in api.PinSerial, out *[]byte) error {
+	if in.Cid == TestCid4 {
+		return errors.New("block not found")
+	}
+	return mock.IPFSBlockGet(ctx, in, out)
+}
+
+// IPFSCat, IPFSLs and IPFSStat serve the same paths as the ipfs mock.
+func (mock *mockService) IPFSCat(ctx context.Context, in api.FileCat, out *[]byte) error {
+	hash, ok := mockPath(in.Path)
+	if !ok || hash != TestCid1 {
+		return errors.New("file does not exist")
+	}
+	data := []byte(TestCid1Data)
+	if in.Offset > uint64(len(data)) {
+		in.Offset = uint64(len(data))
+	}
+	data = data[in.Offset:]
+	if in.Length < uint64(len(data)) {
+		data = data[:in.Length]
+	}
+	*out = data
+	return nil
+}
+
+func (mock *mockService) IPFSLs(ctx context.Context, in string, out *api.FilesLs) error {
+	hash, ok := mockPath(in)
+	if !ok || hash != TestCid2 {
+		return errors.New("not a directory")
+	}
+	*out = api.FilesLs{
+		Entries: []api.FileLsEntrie{
+			{
+				Name: "file",
+				Hash: TestCid1,
+				Size: uint64(len(TestCid1Data)),
+				Type: 2,
+			},
+		},
+	}
+	return nil
+}
+
+func (mock *mockService) IPFSStat(ctx context.Context, in string, out *api.FilesStat) error {
+	hash, ok := mockPath(in)
+	if !ok {
+		return errors.New("file does not exist")
+	}
+	*out = api.FilesStat{
+		Hash: hash,
+		Type: "directory",
+	}
+	if hash == TestCid1 {
+		out.Size = uint64(len(TestCid1Data))
+		out.Type = "file"
+	}
+	return nil
+}
+
+func (mock *mockService) IPFSNameResolve(ctx context.Context, in string, out *string) error {
+	if strings.TrimPrefix(in, "/ipns/") != TestPeerID1.Pretty() {
+		return errors.New("could not resolve name")
+	}
+	*out = "/ipfs/" + TestCid2
+	return nil
+}
+
+func (mock *mockService) IPFSFilesCp(ctx context.Context, in []string, out *struct{}) error {
+	if strings.HasPrefix(in[2], "/missing/") {
+		return errors.New("file does not exist")
+	}
+	return nil
+}
+
+func (mock *mockService) IPFSFilesMkdir(ctx context.Context, in []string, out *struct{}) error {
+	return nil
+}
+
+func (mock *mockService) IPFSFilesRm(ctx context.Context, in []string, out *struct{}) error {
+	return nil
+}
+
+func (mock *mockService) IPFSFilesStat(ctx context.Context, in []string, out *api.FilesStat) error {
+	if strings.HasPrefix(in[1], "/missing/") {
+		return errors.New("file does not exist")
+	}
+	if _, ok := mockPath("/nodes/" + in[0] + in[1]); ok {
+		return mock.IPFSStat(ctx, "/nodes/"+in[0]+in[1], out)
+	}
+	*out = api.FilesStat{
+		Hash:           TestCid1,
+		CumulativeSize: 1024,
+		Type:           "directory",
+	}
+	return nil
+}
+
+func (mock *mockService) Trash(ctx context.Context, in []string, out *api.TrashEntry) error {
+	if in[1] != "/docs/file" {
+		return errors.New("file does not exist")
+	}
+	*out = mockTrashEntry
+	return nil
+}
+
+func (mock *mockService) TrashList(ctx context.Context, in string, out *[]api.TrashEntry) error {
+	*out = []api.TrashEntry{}
+	if in == TestUID {
+		*out = []api.TrashEntry{mockTrashEntry}
+	}
+	return nil
+}
+
+func (mock *mockService) TrashRestore(ctx context.Context, in []string, out *string) error {
+	if in[1] != TestTrashID {
+		return errors.New("trash entry does not exist")
+	}
+	*out = mockTrashEntry.Path
+	if in[2] != "" {
+		*out = in[2]
+	}
+	return nil
+}
+
+func (mock *mockService) TrashEmpty(ctx context.Context, in []string, out *struct{}) error {
+	if in[1] != "" && in[1] != TestTrashID {
+		return errors.New("trash entry does not exist")
+	}
+	return nil
+}
+
+func (mock *mockService) FilesBatch(ctx context.Context, in api.FilesBatch, out *api.FilesBatchResult) error {
+	*out = api.FilesBatchResult{Trashed: []api.TrashEntry{}}
+	for _, op := range in.Ops {
+		if op.Path == "/missing" {
+			return errors.New("file does not exist")
+		}
+		if op.Op == "rm" && !op.Permanent {
+			out.Trashed = append(out.Trashed, mockTrashEntry)
+		}
+	}
+	out.Root = TestCid3
+	return nil
+}
+
+func (mock *mockService) FilesSearch(ctx context.Context, in api.SearchQuery, out *[]api.FileMeta) error {
+	*out = []api.FileMeta{}
+	if in.UID != TestUID {
+		return nil
+	}
+	m := api.FileMeta{
+		Path:     "/docs/file",
+		Name:     "file",
+		Size:     uint64(len(TestCid1Data)),
+		Type:     "file",
+		Hash:     TestCid1,
+		Modified: time.Unix(1540000000, 0),
+	}
+	if in.Match(m) {
+		*out = append(*out, m)
+	}
+	return nil
+}
+
+func (mock *mockService) FilesSearchRebuild(ctx context.Context, in string, out *int) error {
+	*out = 0
+	if in == TestUID {
+		*out = 2
+	}
+	return nil
+}
+
+func (mock *mockService) SyncKey(ctx context.Context, in string, out *struct{}) error {
+	return nil
+}
+
+func (mock *mockService) UidInfo(ctx context.Context, in string, out *api.UIDSecret) error {
+	// Like IPFS, unknown UIDs return an empty secret.
+	if in == TestUID {
+		*out = api.UIDSecret{
+			UID:    TestUID,
+			PeerID: TestPeerID1.Pretty(),
+		}
+	}
+	return nil
+}
+
 func (mock *mockService) ConsensusAddPeer(ctx context.Context, in peer.ID, out *struct{}) error {
 	return errors.New("mock rpc cannot redirect")
 }
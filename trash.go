@@ -0,0 +1,327 @@
+package ipfscluster
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastos/Elastos.NET.Hive.Cluster/api"
+)
+
+// trashFolder is the folder, inside every UID home, holding the entries
+// deleted from it. Since it lives in the home, it counts towards the home
+// size like any other file.
+const trashFolder = "/.trash"
+
+// trashPurgeInterval is the maximum time between two purges of the
+// expired trash entries.
+var trashPurgeInterval = time.Hour
+
+// trashName returns the name of the trash entry for a path deleted at the
+// given time. Both are encoded in the name so that the trash needs no
+// separate index: "<unix nanoseconds>-<base64url of the path>".
+func trashName(p string, deleted time.Time) string {
+	return fmt.Sprintf(
+		"%d-%s",
+		deleted.UnixNano(),
+		base64.RawURLEncoding.EncodeToString([]byte(p)),
+	)
+}
+
+// parseTrashName returns the original path and the deletion time encoded
+// in the name of a trash entry.
+func parseTrashName(name string) (string, time.Time, error) {
+	parts := strings.SplitN(name, "-", 2)
+	if len(parts) != 2 {
+		return "", time.Time{}, errors.New("invalid trash entry: " + name)
+	}
+	ns, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return "", time.Time{}, errors.New("invalid trash entry: " + name)
+	}
+	p, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !strings.HasPrefix(string(p), "/") {
+		return "", time.Time{}, errors.New("invalid trash entry: " + name)
+	}
+	return string(p), time.Unix(0, ns), nil
+}
+
+// cleanHomePath returns p as an absolute, clean path inside a home.
+func cleanHomePath(p string) string {
+	return path.Clean("/" + p)
+}
+
+// inTrash returns true when p is the trash folder or something inside it.
+func inTrash(p string) bool {
+	return p == trashFolder || strings.HasPrefix(p, trashFolder+"/")
+}
+
+// Trash moves a file or directory of a UID home to its trash, from where
+// it can be restored until it is purged. Directories are only moved when
+// recursive is set, like with "files rm".
+func (c *Cluster) Trash(uid, p string, recursive bool) (api.TrashEntry, error) {
+	entry, err := c.moveToTrash(uid, p, recursive)
+	if err != nil {
+		return entry, err
+	}
+
+	logger.Infof("moved %s of %s to the trash", entry.Path, uid)
+	c.publishFileOp("trash", uid, entry.Path)
+	return entry, nil
+}
+
+// moveToTrash moves p to the trash of the given home.
+func (c *Cluster) moveToTrash(home, p string, recursive bool) (api.TrashEntry, error) {
+	var entry api.TrashEntry
+
+	p = cleanHomePath(p)
+	if p == "/" {
+		return entry, errors.New("can not remove path: " + p)
+	}
+	if inTrash(p) {
+		return entry, errors.New("entries in the trash can only be removed by emptying it")
+	}
+
+	stat, err := c.ipfs.FilesStat([]string{home, p, "", "", "", ""})
+	if err != nil {
+		return entry, err
+	}
+	if stat.Type == "directory" && !recursive {
+		return entry, fmt.Errorf("%s is a directory, use recursive to remove directories", p)
+	}
+
+	err = c.ipfs.FilesMkdir([]string{home, trashFolder, "true"})
+	if err != nil {
+		return entry, err
+	}
+
+	deleted := time.Now()
+	entry = api.TrashEntry{
+		ID:      trashName(p, deleted),
+		Path:    p,
+		Deleted: deleted,
+		Type:    stat.Type,
+		Size:    stat.CumulativeSize,
+		Hash:    stat.Hash,
+	}
+	err = c.ipfs.FilesMv([]string{home, p, path.Join(trashFolder, entry.ID)})
+	if err != nil {
+		return api.TrashEntry{}, err
+	}
+	return entry, nil
+}
+
+// TrashList returns the entries in the trash of a UID home, the most
+// recently deleted first.
+func (c *Cluster) TrashList(uid string) ([]api.TrashEntry, error) {
+	entries := make([]api.TrashEntry, 0)
+
+	_, err := c.ipfs.FilesStat([]string{uid, trashFolder, "", "", "", ""})
+	if err != nil {
+		// Nothing was ever deleted.
+		return entries, nil
+	}
+
+	ls, err := c.ipfs.FilesLs([]string{uid, trashFolder})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range ls.Entries {
+		p, deleted, err := parseTrashName(e.Name)
+		if err != nil {
+			logger.Warning(err)
+			continue
+		}
+		entry := api.TrashEntry{
+			ID:      e.Name,
+			Path:    p,
+			Deleted: deleted,
+		}
+		stat, err := c.ipfs.FilesStat([]string{uid, path.Join(trashFolder, e.Name), "", "", "", ""})
+		if err == nil {
+			entry.Type = stat.Type
+			entry.Size = stat.CumulativeSize
+			entry.Hash = stat.Hash
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Deleted.After(entries[j].Deleted)
+	})
+	return entries, nil
+}
+
+// TrashRestore moves an entry from the trash of a UID home back to dest,
+// or to where it was deleted from when dest is empty. It returns the path
+// where the entry was restored. Existing files are never overwritten.
+func (c *Cluster) TrashRestore(uid, id, dest string) (string, error) {
+	orig, _, err := parseTrashName(id)
+	if err != nil {
+		return "", err
+	}
+
+	if dest == "" {
+		dest = orig
+	}
+	dest = cleanHomePath(dest)
+	if dest == "/" {
+		return "", errors.New("a destination is needed to restore a whole home")
+	}
+	if inTrash(dest) {
+		return "", errors.New("can not restore to the trash: " + dest)
+	}
+
+	_, err = c.ipfs.FilesStat([]string{uid, dest, "", "", "", ""})
+	if err == nil {
+		return "", errors.New("destination already exists: " + dest)
+	}
+
+	if dir := path.Dir(dest); dir != "/" {
+		err = c.ipfs.FilesMkdir([]string{uid, dir, "true"})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = c.ipfs.FilesMv([]string{uid, path.Join(trashFolder, id), dest})
+	if err != nil {
+		return "", err
+	}
+
+	logger.Infof("restored %s of %s from the trash", dest, uid)
+	c.publishFileOp("restore", uid, dest)
+	return dest, nil
+}
+
+// TrashEmpty permanently removes an entry from the trash of a UID home, or
+// the whole trash when id is empty.
+func (c *Cluster) TrashEmpty(uid, id string) error {
+	p := trashFolder
+	if id != "" {
+		if _, _, err := parseTrashName(id); err != nil {
+			return err
+		}
+		p = path.Join(trashFolder, id)
+	} else if _, err := c.ipfs.FilesStat([]string{uid, p, "", "", "", ""}); err != nil {
+		// Nothing to empty.
+		return nil
+	}
+
+	err := c.ipfs.FilesRm([]string{uid, p, "true"})
+	if err != nil {
+		return err
+	}
+	c.publishFileOp("rm", uid, p)
+	return nil
+}
+
+// UidLogin replaces the home of a UID with the given root. The former
+// home is copied to the trash of the new home, where it counts towards its
+// size, so that it can be restored. It is lost if permanent is set.
+func (c *Cluster) UidLogin(uid, hash string, permanent bool) error {
+	var old api.FilesStat
+	if !permanent {
+		old, _ = c.ipfs.FilesStat([]string{uid, "", "", "", "", ""})
+	}
+
+	err := c.ipfs.UidLogin([]string{uid, hash})
+	if err != nil {
+		return err
+	}
+	defer c.search.update(uid, "/")
+
+	if old.Hash == "" || old.Hash == strings.TrimPrefix(hash, "/ipfs/") {
+		return nil
+	}
+
+	err = c.ipfs.FilesMkdir([]string{uid, trashFolder, "true"})
+	if err == nil {
+		err = c.ipfs.FilesCp([]string{
+			uid,
+			"/ipfs/" + old.Hash,
+			path.Join(trashFolder, trashName("/", time.Now())),
+		})
+	}
+	if err != nil {
+		// The login succeeded anyway.
+		logger.Errorf("error moving the former home of %s to the trash: %s", uid, err)
+	}
+	return nil
+}
+
+// purgeTrash permanently removes the trash entries of a UID home which
+// were deleted before the given time. It returns how many were removed.
+func (c *Cluster) purgeTrash(uid string, before time.Time) (int, error) {
+	entries, err := c.TrashList(uid)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, e := range entries {
+		if !e.Deleted.Before(before) {
+			continue
+		}
+		p := path.Join(trashFolder, e.ID)
+		err = c.ipfs.FilesRm([]string{uid, p, "true"})
+		if err != nil {
+			logger.Errorf("error purging %s from the trash of %s: %s", e.ID, uid, err)
+			continue
+		}
+		c.publishFileOp("rm", uid, p)
+		n++
+	}
+	return n, nil
+}
+
+// trashWatcher purges the trash entries older than TrashRetention from
+// all the homes in this peer. Homes are replicated in several peers, so
+// every peer purges its own copies.
+func (c *Cluster) trashWatcher() {
+	if c.config.TrashRetention <= 0 {
+		return
+	}
+
+	interval := trashPurgeInterval
+	if c.config.TrashRetention < interval {
+		interval = c.config.TrashRetention
+	}
+
+	ticker := time.NewTicker(interval)
+	for {
+		select {
+		case <-c.ctx.Done():
+			ticker.Stop()
+			return
+		case <-ticker.C:
+			homes, err := c.ipfs.FilesLs([]string{"", ""})
+			if err != nil {
+				logger.Errorf("error listing homes to purge their trash: %s", err)
+				continue
+			}
+			before := time.Now().Add(-c.config.TrashRetention)
+			for _, home := range homes.Entries {
+				if api.IsStagingHome(home.Name) {
+					continue
+				}
+				unlock := c.homeLocks.lock(home.Name)
+				n, err := c.purgeTrash(home.Name, before)
+				unlock()
+				if err != nil {
+					logger.Errorf("error purging the trash of %s: %s", home.Name, err)
+					continue
+				}
+				if n > 0 {
+					logger.Infof("purged %d entries from the trash of %s", n, home.Name)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package ipfscluster
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrashName(t *testing.T) {
+	deleted := time.Unix(0, 1540000000000000000)
+	name := trashName("/docs/a file+b?.txt", deleted)
+
+	p, ts, err := parseTrashName(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != "/docs/a file+b?.txt" {
+		t.Error("unexpected path:", p)
+	}
+	if !ts.Equal(deleted) {
+		t.Error("unexpected deletion time:", ts)
+	}
+
+	for _, bad := range []string{"", "abc", "123", "abc-L2RvY3M", "123-!!", "123-ZG9jcw"} {
+		if _, _, err := parseTrashName(bad); err == nil {
+			t.Errorf("%q should not be a valid trash entry", bad)
+		}
+	}
+}
+
+func TestInTrash(t *testing.T) {
+	if !inTrash(cleanHomePath(".trash/x")) || !inTrash("/.trash") {
+		t.Error("paths in the trash not detected")
+	}
+	if inTrash("/.trashcan") || inTrash("/docs/.trash") {
+		t.Error("paths outside the trash detected as inside")
+	}
+}
@@ -80,6 +80,16 @@ func GlobalPinInfoSliceToSerial(gpi []api.GlobalPinInfo) []api.GlobalPinInfoSeri
 	return gpis
 }
 
+// operationSliceToSerial is a helper function for serializing a slice of
+// api.Operations.
+func operationSliceToSerial(ops []api.Operation) []api.OperationSerial {
+	opss := make([]api.OperationSerial, len(ops), len(ops))
+	for i, v := range ops {
+		opss[i] = v.ToSerial()
+	}
+	return opss
+}
+
 func logError(fmtstr string, args ...interface{}) error {
 	msg := fmt.Sprintf(fmtstr, args...)
 	logger.Error(msg)
@@ -15,3 +15,9 @@ var Version = semver.MustParse("0.8.0")
 var RPCProtocol = protocol.ID(
 	fmt.Sprintf("/hivecluster/%d.%d/rpc", Version.Major, Version.Minor),
 )
+
+// StatusProtocol is used by cluster peers to stream the status of the
+// items they track to each other.
+var StatusProtocol = protocol.ID(
+	fmt.Sprintf("/hivecluster/%d.%d/status", Version.Major, Version.Minor),
+)